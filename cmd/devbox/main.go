@@ -1,6 +1,8 @@
 // Copyright 2024 Jetify Inc. and contributors. All rights reserved.
 // Use of this source code is governed by the license in the LICENSE file.
 
+//go:build !windows
+
 package main
 
 import (
@@ -0,0 +1,13 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package main
+
+import (
+	"go.jetpack.io/devbox/internal/wsl"
+)
+
+// Devbox needs Nix, so on Windows it runs in WSL.
+func main() {
+	wsl.Main()
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package attest creates and verifies signed provenance attestations for
+// devbox environments. Attestations are in-toto statements with a SLSA
+// provenance predicate, signed with Ed25519 and wrapped in a DSSE envelope so
+// that they can be consumed by existing supply chain tooling.
+//
+// See https://slsa.dev/spec/v1.0/provenance and
+// https://github.com/secure-systems-lab/dsse.
+package attest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+const (
+	// PayloadType is the DSSE payload type of in-toto statements.
+	PayloadType   = "application/vnd.in-toto+json"
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType identifies how devbox environments are described, so that
+	// verifiers know how to interpret the build definition.
+	BuildType = "https://www.jetify.com/devbox/attestation/v1"
+	BuilderID = "https://www.jetify.com/devbox"
+)
+
+// Envelope is a DSSE envelope. Payload and signatures are base64 encoded in
+// JSON.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// Statement is an in-toto statement about the subjects.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Provenance           `json:"predicate"`
+}
+
+// ResourceDescriptor identifies a file or an artifact by its digests.
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Provenance is a SLSA v1 provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]string    `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder  `json:"builder"`
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type Metadata struct {
+	StartedOn  *time.Time `json:"startedOn,omitempty"`
+	FinishedOn *time.Time `json:"finishedOn,omitempty"`
+}
+
+// Sign encodes the statement and signs it with key.
+func Sign(stmt *Statement, key ed25519.PrivateKey) (*Envelope, error) {
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		return nil, redact.Errorf("encode attestation: %w", err)
+	}
+	pub, _ := key.Public().(ed25519.PublicKey)
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     payload,
+		Signatures: []Signature{{
+			KeyID: keyID,
+			Sig:   ed25519.Sign(key, pae(PayloadType, payload)),
+		}},
+	}, nil
+}
+
+// Verify checks that the envelope was signed by pub and returns the
+// statement it contains.
+func Verify(env *Envelope, pub ed25519.PublicKey) (*Statement, error) {
+	if env.PayloadType != PayloadType {
+		return nil, usererr.New("Attestation has payload type %q, expected %q", env.PayloadType, PayloadType)
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, sig := range env.Signatures {
+		if sig.KeyID != "" && sig.KeyID != keyID {
+			continue
+		}
+		if ed25519.Verify(pub, pae(env.PayloadType, env.Payload), sig.Sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, usererr.New("Attestation isn't signed by key %s", keyID)
+	}
+
+	stmt := &Statement{}
+	if err := json.Unmarshal(env.Payload, stmt); err != nil {
+		return nil, usererr.WithUserMessage(err, "Attestation payload isn't a valid in-toto statement")
+	}
+	if stmt.Type != StatementType || stmt.PredicateType != PredicateType ||
+		stmt.Predicate.BuildDefinition.BuildType != BuildType {
+		return nil, usererr.New(
+			"Attestation isn't a devbox provenance attestation (statement %q, predicate %q, build type %q)",
+			stmt.Type, stmt.PredicateType, stmt.Predicate.BuildDefinition.BuildType,
+		)
+	}
+	return stmt, nil
+}
+
+// pae is the DSSE pre-authentication encoding, which is what's actually
+// signed.
+func pae(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// KeyID identifies a public key by the SHA-256 of its PKIX encoding.
+func KeyID(pub ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", redact.Errorf("encode public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GenerateKey returns a new PEM encoded signing key and its public key.
+func GenerateKey() (private, public []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, redact.Errorf("generate signing key: %w", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, redact.Errorf("encode signing key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, redact.Errorf("encode public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+		nil
+}
+
+// ParsePrivateKey parses a PEM encoded Ed25519 signing key.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, usererr.New("Signing key must be a PEM encoded PRIVATE KEY")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to parse the signing key")
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, usererr.New("Signing key must be an Ed25519 key")
+	}
+	return priv, nil
+}
+
+// ParsePublicKey parses a PEM encoded Ed25519 public key. A signing key is
+// accepted too, in which case its public key is returned.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block != nil && block.Type == "PRIVATE KEY" {
+		priv, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		pub, _ := priv.Public().(ed25519.PublicKey)
+		return pub, nil
+	}
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, usererr.New("Public key must be a PEM encoded PUBLIC KEY")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to parse the public key")
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, usererr.New("Public key must be an Ed25519 key")
+	}
+	return pub, nil
+}
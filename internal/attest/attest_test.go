@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package attest
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testStatement() *Statement {
+	return &Statement{
+		Type: StatementType,
+		Subject: []ResourceDescriptor{{
+			Name:   "devbox.json",
+			Digest: map[string]string{"sha256": "0123"},
+		}},
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{BuildType: BuildType},
+			RunDetails:      RunDetails{Builder: Builder{ID: BuilderID}},
+		},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	private, public, err := GenerateKey()
+	require.NoError(t, err)
+	key, err := ParsePrivateKey(private)
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(public)
+	require.NoError(t, err)
+
+	envelope, err := Sign(testStatement(), key)
+	require.NoError(t, err)
+	stmt, err := Verify(envelope, pub)
+	require.NoError(t, err)
+	require.Equal(t, testStatement(), stmt)
+
+	// The signing key can be used to verify too.
+	pubFromPrivate, err := ParsePublicKey(private)
+	require.NoError(t, err)
+	require.Equal(t, pub, pubFromPrivate)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	private, _, err := GenerateKey()
+	require.NoError(t, err)
+	key, err := ParsePrivateKey(private)
+	require.NoError(t, err)
+	_, otherPublic, err := GenerateKey()
+	require.NoError(t, err)
+	other, err := ParsePublicKey(otherPublic)
+	require.NoError(t, err)
+
+	envelope, err := Sign(testStatement(), key)
+	require.NoError(t, err)
+	_, err = Verify(envelope, other)
+	require.Error(t, err, "signed by another key")
+
+	envelope.Payload = []byte(string(envelope.Payload[:len(envelope.Payload)-1]) + " }")
+	pub, _ := key.Public().(ed25519.PublicKey)
+	_, err = Verify(envelope, pub)
+	require.Error(t, err, "tampered payload")
+}
+
+func TestPAE(t *testing.T) {
+	// Test vector from the DSSE specification.
+	require.Equal(t,
+		"DSSEv1 29 http://example.com/HelloWorld 11 hello world",
+		string(pae("http://example.com/HelloWorld", []byte("hello world"))),
+	)
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type activateCmdFlags struct {
+	config      configFlags
+	runInitHook bool
+	shell       string
+}
+
+func activateCmd() *cobra.Command {
+	flags := activateCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "activate",
+		Short: "Print shell commands that apply the devbox environment to the current shell",
+		Long: heredoc.Doc(`
+			Print the shell commands that apply the project's environment to the
+			current shell, instead of starting a nested one like devbox shell. Job
+			control, the prompt and the shell's history stay as they are.
+
+			The init hooks run in sh, and the variables that they set are applied
+			too. Aliases and functions that they define aren't. Devbox deactivate
+			restores the variables as they were before, and activating another
+			project deactivates the current one first.
+		`),
+		Example: heredoc.Doc(`
+			  eval "$(devbox activate)"
+			  eval "$(devbox deactivate)"
+
+			  # fish
+			  devbox activate | source
+		`),
+		Args:    cobra.NoArgs,
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			exports, err := box.Activate(cmd.Context(), flags.shell, flags.runInitHook)
+			if err != nil {
+				return err
+			}
+			return printActivateExports(cmd, flags.shell, exports)
+		},
+	}
+	cmd.Flags().BoolVar(
+		&flags.runInitHook, "init-hook", true, "run the init hooks and apply the variables that they set")
+	cmd.Flags().StringVar(
+		&flags.shell, "shell", "", "shell to print the commands for: bash, zsh, sh or fish. Defaults to the current shell")
+	flags.config.register(cmd)
+	return cmd
+}
+
+func deactivateCmd() *cobra.Command {
+	shell := ""
+	cmd := &cobra.Command{
+		Use:   "deactivate",
+		Short: "Print shell commands that undo devbox activate",
+		Long: heredoc.Doc(`
+			Print the shell commands that restore the variables that devbox activate
+			changed in the current shell to their values from before.
+		`),
+		Example: `  eval "$(devbox deactivate)"`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exports, err := devbox.Deactivate(shell)
+			if err != nil {
+				return err
+			}
+			return printActivateExports(cmd, shell, exports)
+		},
+	}
+	cmd.Flags().StringVar(
+		&shell, "shell", "", "shell to print the commands for: bash, zsh, sh or fish. Defaults to the current shell")
+	return cmd
+}
+
+// printActivateExports prints the exports, and makes POSIX shells forget the
+// commands that they found in the old PATH.
+func printActivateExports(cmd *cobra.Command, shell, exports string) error {
+	if exports != "" {
+		fmt.Fprintln(cmd.OutOrStdout(), exports)
+	}
+	if dialect, _ := devbox.ParseShellDialect(shell); dialect == devbox.DialectPOSIX {
+		fmt.Fprintln(cmd.OutOrStdout(), "hash -r")
+	}
+	return nil
+}
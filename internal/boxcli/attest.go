@@ -0,0 +1,230 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/attest"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+const defaultAttestationFile = "devbox.attestation.json"
+
+type attestCmdFlags struct {
+	config configFlags
+	key    string
+	output string
+}
+
+func attestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attest",
+		Short: "Create and verify signed provenance attestations of the environment",
+		Long: heredoc.Doc(`
+			Create and verify signed attestations that bind devbox.json, devbox.lock,
+			the Nix store paths they resolve to and the devbox version that produced
+			them. Attestations are SLSA provenance statements in a DSSE envelope,
+			signed with an Ed25519 key.
+		`),
+	}
+	cmd.AddCommand(attestCreateCmd())
+	cmd.AddCommand(attestVerifyCmd())
+	cmd.AddCommand(attestKeygenCmd())
+	return cmd
+}
+
+func attestCreateCmd() *cobra.Command {
+	flags := attestCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a signed attestation of the environment",
+		Long: heredoc.Docf(`
+			Create a signed attestation of the environment. The signing key is read
+			from --key, or from the %s environment variable.
+		`, envir.DevboxAttestationKey),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyPEM := []byte(os.Getenv(envir.DevboxAttestationKey))
+			if flags.key != "" {
+				var err error
+				if keyPEM, err = os.ReadFile(flags.key); err != nil {
+					return usererr.WithUserMessage(err, "Unable to read the signing key %s", flags.key)
+				}
+			}
+			if len(keyPEM) == 0 {
+				return usererr.New(
+					"A signing key is required. Use --key or set %s. "+
+						"Create one with `devbox attest keygen`.",
+					envir.DevboxAttestationKey,
+				)
+			}
+			key, err := attest.ParsePrivateKey(keyPEM)
+			if err != nil {
+				return err
+			}
+
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			stmt, err := box.Attestation()
+			if err != nil {
+				return err
+			}
+			envelope, err := attest.Sign(stmt, key)
+			if err != nil {
+				return err
+			}
+			out, err := json.MarshalIndent(envelope, "", "  ")
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if flags.output == "-" {
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return nil
+			}
+			output := flags.output
+			if output == "" {
+				output = filepath.Join(box.ProjectDir(), defaultAttestationFile)
+			}
+			if err := os.WriteFile(output, append(out, '\n'), 0o644); err != nil {
+				return errors.WithStack(err)
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Wrote attestation to %s\n", output)
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVar(&flags.key, "key", "", "path to the PEM encoded signing key")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "",
+		"file to write the attestation to, or - for stdout (default: "+defaultAttestationFile+" in the project)")
+	return cmd
+}
+
+func attestVerifyCmd() *cobra.Command {
+	flags := attestCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "verify [attestation]",
+		Short: "Verify an attestation against the current environment",
+		Long: heredoc.Doc(`
+			Verify that an attestation was signed by the given public key, and that
+			devbox.json, devbox.lock and the store paths they resolve to haven't
+			changed since it was created.
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.key == "" {
+				return usererr.New("A public key is required to verify attestations. Use --key.")
+			}
+			keyPEM, err := os.ReadFile(flags.key)
+			if err != nil {
+				return usererr.WithUserMessage(err, "Unable to read the public key %s", flags.key)
+			}
+			pub, err := attest.ParsePublicKey(keyPEM)
+			if err != nil {
+				return err
+			}
+
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			path := filepath.Join(box.ProjectDir(), defaultAttestationFile)
+			if len(args) > 0 {
+				path = args[0]
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return usererr.WithUserMessage(err, "Unable to read the attestation %s", path)
+			}
+			envelope := &attest.Envelope{}
+			if err := json.Unmarshal(data, envelope); err != nil {
+				return usererr.WithUserMessage(err, "%s isn't a DSSE envelope", path)
+			}
+			stmt, err := attest.Verify(envelope, pub)
+			if err != nil {
+				return err
+			}
+			problems, err := box.VerifyAttestation(stmt)
+			if err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return usererr.New(
+					"The environment doesn't match the attestation:\n  %s",
+					strings.Join(problems, "\n  "),
+				)
+			}
+
+			builder := stmt.Predicate.RunDetails.Builder
+			ux.Fsuccess(cmd.ErrOrStderr(),
+				"Attestation verified. It was created by devbox %s", builder.Version["devbox"])
+			if started := stmt.Predicate.RunDetails.Metadata.StartedOn; started != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), " on %s", started.Format("2006-01-02 15:04:05 MST"))
+			}
+			fmt.Fprintln(cmd.ErrOrStderr(), ".")
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVar(&flags.key, "key", "", "path to the PEM encoded public key")
+	return cmd
+}
+
+func attestKeygenCmd() *cobra.Command {
+	flags := attestCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a key pair for signing attestations",
+		Long: heredoc.Doc(`
+			Generate an Ed25519 key pair for signing attestations. The signing key
+			is written to <output>.key and the public key to <output>.pub. Keep the
+			signing key secret, for example in your CI system's secret store.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			private, public, err := attest.GenerateKey()
+			if err != nil {
+				return err
+			}
+			keyPath, pubPath := flags.output+".key", flags.output+".pub"
+			for _, path := range []string{keyPath, pubPath} {
+				if _, err := os.Stat(path); err == nil {
+					return usererr.New("%s already exists", path)
+				}
+			}
+			if err := os.WriteFile(keyPath, private, 0o600); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := os.WriteFile(pubPath, public, 0o644); err != nil {
+				return errors.WithStack(err)
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Wrote signing key to %s and public key to %s\n", keyPath, pubPath)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "devbox-attestation",
+		"path prefix of the key files")
+	return cmd
+}
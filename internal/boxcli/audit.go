@@ -0,0 +1,155 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/internal/vuln"
+)
+
+type auditCmdFlags struct {
+	config  configFlags
+	closure bool
+	failOn  string
+	json    bool
+	sources []string
+}
+
+func auditCmd() *cobra.Command {
+	flags := auditCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Check the locked packages for known vulnerabilities",
+		Long: "Check the packages in devbox.lock for known vulnerabilities by looking up " +
+			"their names and versions in vulnerability databases. Use --closure to also " +
+			"check the runtime dependencies of installed packages, and --fail-on to exit " +
+			"with an error in CI when a vulnerability is at least as severe as the given level.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditCmd(cmd, flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.closure, "closure", false,
+		"also check the runtime dependencies of installed packages")
+	cmd.Flags().StringVar(&flags.failOn, "fail-on", "",
+		"exit with an error if a vulnerability is this severe or worse: low, medium, high or critical")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the findings as JSON")
+	cmd.Flags().StringSliceVar(&flags.sources, "source", vuln.DefaultSources,
+		"vulnerability databases to query: search, osv, nvd or vulnix")
+	return cmd
+}
+
+func runAuditCmd(cmd *cobra.Command, flags auditCmdFlags) error {
+	var failOn vuln.Severity
+	if flags.failOn != "" {
+		var err error
+		if failOn, err = vuln.ParseSeverity(flags.failOn); err != nil {
+			return err
+		}
+	}
+	var sources []vuln.Source
+	for _, name := range flags.sources {
+		source, err := vuln.NewSource(name)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+	if slices.Contains(flags.sources, vuln.SourceNVD) && vuln.NVDRateLimited() && !flags.json {
+		ux.Finfo(cmd.ErrOrStderr(),
+			"NVD limits queries without an API key, so this may take a while. "+
+				"Set %s to speed it up.\n", envir.NVDAPIKey)
+	}
+
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	findings, err := box.Audit(cmd.Context(), sources, flags.closure)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		if findings == nil {
+			findings = []vuln.Finding{}
+		}
+		out, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	} else {
+		printFindings(cmd.OutOrStdout(), findings)
+	}
+
+	if failOn == vuln.SeverityUnknown {
+		return nil
+	}
+	failing := 0
+	for _, f := range findings {
+		if f.Vulnerability.Severity >= failOn {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return usererr.New("Found %d vulnerabilities with %s severity or higher", failing, failOn)
+	}
+	return nil
+}
+
+func printFindings(w io.Writer, findings []vuln.Finding) {
+	if len(findings) == 0 {
+		ux.Fsuccess(w, "No known vulnerabilities found.\n")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tVERSION\tVULNERABILITY\tSEVERITY\tFIXED IN\tSUMMARY")
+	for _, f := range findings {
+		name := f.Package.Name
+		if f.Package.DependencyOf != "" {
+			name += " (via " + f.Package.DependencyOf + ")"
+		}
+		severity := f.Vulnerability.Severity.String()
+		if f.Vulnerability.Score > 0 {
+			severity += fmt.Sprintf(" (%.1f)", f.Vulnerability.Score)
+		}
+		fixedIn := strings.Join(f.Vulnerability.FixedIn, ", ")
+		if fixedIn == "" {
+			fixedIn = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			name, f.Package.Version, f.Vulnerability.ID, severity, fixedIn,
+			truncate(f.Vulnerability.Summary, 60))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d vulnerabilities found.\n", len(findings))
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -4,16 +4,21 @@
 package boxcli
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devbox/providers/identity"
+	"go.jetpack.io/devbox/internal/searcher"
 	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/pkg/api"
 )
@@ -28,6 +33,7 @@ func authCmd() *cobra.Command {
 	cmd.AddCommand(logoutCmd())
 	cmd.AddCommand(whoAmICmd())
 	cmd.AddCommand(authNewTokenCommand())
+	cmd.AddCommand(authSearchTokenCmd())
 
 	return cmd
 }
@@ -67,6 +73,9 @@ func logoutCmd() *cobra.Command {
 				return err
 			}
 			err = c.LogoutFlow()
+			if err == nil {
+				err = identity.DeleteAPIToken()
+			}
 			if err == nil {
 				fmt.Fprintln(cmd.OutOrStdout(), "Logged out successfully")
 			}
@@ -117,10 +126,13 @@ func authNewTokenCommand() *cobra.Command {
 		Short: "Manage devbox auth tokens",
 	}
 
+	var save bool
 	newCmd := &cobra.Command{
 		Use:   "new",
 		Short: "Create a new token",
-		Args:  cobra.ExactArgs(0),
+		Long: "Create a new token. Set it as DEVBOX_API_TOKEN to authenticate, or use --save " +
+			"to store it in the OS keychain, where devbox uses it when DEVBOX_API_TOKEN isn't set.",
+		Args: cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			token, err := identity.GenSession(ctx)
@@ -142,6 +154,13 @@ func authNewTokenCommand() *cobra.Command {
 				}
 				return err
 			}
+			if save {
+				if err := identity.SaveAPIToken(pat.GetToken().GetSecret()); err != nil {
+					return err
+				}
+				ux.Fsuccess(cmd.OutOrStdout(), "Token created and saved to the keychain.\n\n")
+				return nil
+			}
 			ux.Fsuccess(cmd.OutOrStdout(), "Token created.\n\n")
 			table := tablewriter.NewWriter(cmd.OutOrStdout())
 			table.SetRowLine(true)
@@ -154,7 +173,80 @@ func authNewTokenCommand() *cobra.Command {
 		},
 	}
 
+	newCmd.Flags().BoolVar(&save, "save", false, "save the token in the OS keychain instead of printing it")
 	tokensCmd.AddCommand(newCmd)
 
 	return tokensCmd
 }
+
+type searchTokenCmdFlags struct {
+	host string
+}
+
+func authSearchTokenCmd() *cobra.Command {
+	flags := &searchTokenCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "search-token",
+		Short: "Manage the token for a private search service",
+		Long: "Manage the bearer token that devbox sends to a private search service, which is " +
+			"set with DEVBOX_SEARCH_HOST or the network policy. DEVBOX_SEARCH_TOKEN takes precedence " +
+			"over a saved token.",
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set",
+		Short: "Save a token, read from stdin, in the OS keychain",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host, err := searchTokenHost(flags.host)
+			if err != nil {
+				return err
+			}
+			token, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return errors.WithStack(err)
+			}
+			if err := searcher.SaveToken(host, strings.TrimSpace(token)); err != nil {
+				return err
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Saved the token for %s.\n", host)
+			return nil
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the saved token",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host, err := searchTokenHost(flags.host)
+			if err != nil {
+				return err
+			}
+			if err := searcher.DeleteToken(host); err != nil {
+				return err
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Deleted the token for %s.\n", host)
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(
+		&flags.host, "host", "",
+		"host of the search service, such as search.acme.internal. Defaults to the configured search service",
+	)
+	cmd.AddCommand(setCmd, deleteCmd)
+	return cmd
+}
+
+// searchTokenHost returns the host that a search token is saved for.
+func searchTokenHost(host string) (string, error) {
+	if host != "" {
+		return host, nil
+	}
+	u, err := url.Parse(searcher.Host())
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return u.Host, nil
+}
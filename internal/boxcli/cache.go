@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/user"
+	"path/filepath"
 	"slices"
 
 	"github.com/MakeNowJust/heredoc/v2"
@@ -16,6 +17,9 @@ import (
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/ux"
 	nixv1alpha1 "go.jetpack.io/pkg/api/gen/priv/nix/v1alpha1"
 )
 
@@ -24,6 +28,13 @@ type cacheFlags struct {
 	to string
 }
 
+type cachePushFlags struct {
+	pathFlag
+	to         string
+	signingKey string
+	builtOnly  bool
+}
+
 type credentialsFlags struct {
 	format string
 }
@@ -70,6 +81,8 @@ func cacheCmd() *cobra.Command {
 		&flags.to, "to", "", "URI of the cache to copy to")
 
 	cacheCommand.AddCommand(uploadCommand)
+	cacheCommand.AddCommand(cachePushCmd())
+	cacheCommand.AddCommand(cacheClearCmd())
 	cacheCommand.AddCommand(cacheConfigureCmd())
 	cacheCommand.AddCommand(cacheCredentialsCmd())
 	cacheCommand.AddCommand(cacheInfoCmd())
@@ -77,6 +90,82 @@ func cacheCmd() *cobra.Command {
 	return cacheCommand
 }
 
+func cachePushCmd() *cobra.Command {
+	flags := cachePushFlags{}
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Sign and push the project's packages to a team binary cache",
+		Long: heredoc.Doc(`
+			Sign the store paths of the project's packages and copy them, with
+			their dependencies, to the binary cache in push_cache in devbox.json,
+			or to the store in --to. Teammates who add the cache to binary_caches
+			download the packages instead of building them.
+
+			Only the store paths in the local Nix store are pushed, so run it after
+			devbox install. With --built-only, it pushes only the paths that were
+			built on this machine, which is what auto_push in push_cache does after
+			each build.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if flags.signingKey != "" {
+				if flags.signingKey, err = filepath.Abs(flags.signingKey); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			return box.PushToCache(cmd.Context(), devopt.CachePushOpts{
+				To:         flags.to,
+				SigningKey: flags.signingKey,
+				BuiltOnly:  flags.builtOnly,
+			})
+		},
+	}
+	flags.pathFlag.register(cmd)
+	cmd.Flags().StringVar(&flags.to, "to", "", "URI of the store to push to, instead of push_cache in devbox.json")
+	cmd.Flags().StringVar(&flags.signingKey, "signing-key", "",
+		"path of the secret key to sign store paths with, instead of push_cache.signing_key")
+	cmd.Flags().BoolVar(&flags.builtOnly, "built-only", false,
+		"only push store paths that were built on this machine")
+	return cmd
+}
+
+func cacheClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Clear the cache of package resolutions from the search service",
+		Long: heredoc.Doc(`
+			Clear the package resolutions and search responses that devbox caches
+			on disk, so that the next install, update or search asks the search
+			service again.
+
+			Devbox reuses a resolution of a package version for an hour, or for as
+			long as DEVBOX_RESOLVE_CACHE_TTL says. It reuses other responses from the
+			search service for 5 minutes, or for as long as DEVBOX_SEARCH_CACHE_TTL
+			says, and then revalidates them. Set either to 0 to turn its cache off.
+		`),
+		Args: cobra.ExactArgs(0),
+		// Clearing the cache doesn't need Nix.
+		PersistentPreRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := lock.ClearResolveCache(); err != nil {
+				return err
+			}
+			if err := searcher.ClearResponseCache(); err != nil {
+				return err
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Cleared the cache of package resolutions and search responses\n")
+			return nil
+		},
+	}
+}
+
 func cacheConfigureCmd() *cobra.Command {
 	username := ""
 	cmd := &cobra.Command{
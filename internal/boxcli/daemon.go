@@ -0,0 +1,77 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/daemon"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type daemonCmdFlags struct {
+	config configFlags
+	socket string
+}
+
+func daemonCmd() *cobra.Command {
+	flags := daemonCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve the project's environment to editors over a unix socket",
+		Long: heredoc.Doc(`
+			Run a daemon that serves the project's environment, packages, services
+			and scripts over a unix socket, so that editor extensions don't have to
+			run devbox for every query. The daemon recomputes the environment when
+			devbox.json, devbox.lock, a local plugin or an env_from file changes.
+
+			The daemon speaks JSON-RPC 2.0 with one message per line. Its methods
+			are devbox.info, env.get, env.watch, packages.list, services.list and
+			scripts.list. After env.watch, the daemon sends an env.changed
+			notification whenever the environment changes.
+
+			The socket is .devbox/daemon.sock in the project directory, unless
+			--socket is set.
+		`),
+		Args:    cobra.ExactArgs(0),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			open := func() (*devbox.Devbox, error) {
+				return devbox.Open(&devopt.Opts{
+					Dir:         flags.config.path,
+					Environment: flags.config.environment,
+					Stderr:      cmd.ErrOrStderr(),
+				})
+			}
+			box, err := open()
+			if err != nil {
+				return err
+			}
+			socket := flags.socket
+			if socket == "" {
+				socket = daemon.SocketPath(box.ProjectDir())
+			}
+			l, err := daemon.Listen(socket)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(socket)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			ux.Finfo(cmd.ErrOrStderr(), "Listening on %s\n", socket)
+			return daemon.New(open).Serve(ctx, l)
+		},
+	}
+	cmd.Flags().StringVar(&flags.socket, "socket", "", "path of the unix socket to listen on")
+	flags.config.register(cmd)
+	return cmd
+}
@@ -0,0 +1,303 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/netpolicy"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func debugToolsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Troubleshoot how devbox behaves on this machine",
+	}
+	cmd.AddCommand(debugHooksCmd())
+	cmd.AddCommand(debugNetworkCmd())
+	cmd.AddCommand(debugStackCmd())
+	return cmd
+}
+
+// hookPhaseDescriptions say when each hook phase runs.
+var hookPhaseDescriptions = map[configfile.HookPhase]string{
+	configfile.HookPreInit:     "before the init hooks",
+	configfile.HookInit:        "when devbox shell starts, and before scripts and services (sourced)",
+	configfile.HookPostPackage: "after packages are installed, updated or removed",
+	configfile.HookPreScript:   "before each script of devbox run, after the init hooks",
+}
+
+type debugHooksCmdFlags struct {
+	config  configFlags
+	jsonOut bool
+}
+
+func debugHooksCmd() *cobra.Command {
+	flags := debugHooksCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Print the hooks that the project runs, in the order that they run",
+		Long: heredoc.Doc(`
+			Print the hooks of each phase, in the order that the phases first run,
+			with the commands of each plugin and of devbox.json and what happens
+			when they fail. Within a phase, the hooks of included plugins run first,
+			in the order that they're included, and then the hooks of devbox.json.
+
+			A hook that fails stops at its first failing command. Then its on_failure
+			policy decides what happens: fail stops the devbox command and skips the
+			rest of the phase, warn prints a warning, and ignore continues silently.
+			The init hooks are sourced into the shell, so they always continue.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			plans := box.HookPlans()
+			if flags.jsonOut {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(plans))
+			}
+			printHookPlans(cmd.OutOrStdout(), plans)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&flags.jsonOut, "json", false, "print the hooks as JSON")
+	flags.config.register(cmd)
+	return cmd
+}
+
+func printHookPlans(w io.Writer, plans []devbox.HookPlan) {
+	for i, plan := range plans {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%d. %s: runs %s\n", i+1, plan.Phase, hookPhaseDescriptions[plan.Phase])
+		if len(plan.Hooks) == 0 {
+			fmt.Fprintln(w, "   (no hooks)")
+		}
+		for _, hook := range plan.Hooks {
+			fmt.Fprintf(w, "   %s (on failure: %s)\n", hook.Source, hook.OnFailure)
+			for _, line := range strings.Split(strings.TrimRight(hook.Commands.String(), "\n"), "\n") {
+				fmt.Fprintf(w, "       %s\n", line)
+			}
+		}
+	}
+}
+
+type networkEndpoint struct {
+	Class        netpolicy.Class `json:"class"`
+	Description  string          `json:"description"`
+	URL          string          `json:"url"`
+	Hosts        []string        `json:"hosts"`
+	Enabled      bool            `json:"enabled"`
+	Redirectable bool            `json:"redirectable"`
+	// Proxy is the proxy requests go through, if any.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+type networkReport struct {
+	Policy    string            `json:"policy"`
+	Endpoints []networkEndpoint `json:"endpoints"`
+	// ProxyEnv are the proxy variables that devbox and Nix honor.
+	ProxyEnv map[string]string `json:"proxy_env,omitempty"`
+	// NixSubstituters are the binary caches Nix downloads packages from.
+	// Nix contacts them itself, with its own configuration.
+	NixSubstituters []string `json:"nix_substituters,omitempty"`
+}
+
+func debugNetworkCmd() *cobra.Command {
+	jsonOut := false
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "List the network endpoints devbox may contact",
+		Long: heredoc.Docf(`
+			List every class of network endpoints devbox may contact, whether it's
+			enabled, where requests go and whether they go through a proxy.
+
+			Classes can be disabled or redirected to mirrors with a network policy in
+			%s (or the file in DEVBOX_NETWORK_POLICY), for example:
+
+			    {
+			      "disable": ["telemetry", "runx"],
+			      "redirect": {"search": "https://search.mirror.acme.internal"}
+			    }
+
+			Devbox honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY for all of its requests.
+			Nix downloads packages from its substituters itself, so configure those and
+			Nix's proxy in nix.conf and the Nix daemon's environment.
+		`, netpolicy.Path()),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := buildNetworkReport(cmd)
+			if err != nil {
+				return err
+			}
+			if jsonOut {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(report))
+			}
+			return printNetworkReport(cmd.OutOrStdout(), report)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the endpoints as JSON")
+	return cmd
+}
+
+func buildNetworkReport(cmd *cobra.Command) (*networkReport, error) {
+	policy, err := netpolicy.Current()
+	if err != nil {
+		return nil, err
+	}
+	report := &networkReport{Policy: netpolicy.Path(), ProxyEnv: map[string]string{}}
+	for _, endpoint := range policy.Endpoints() {
+		report.Endpoints = append(report.Endpoints, networkEndpoint{
+			Class:        endpoint.Class,
+			Description:  endpoint.Description,
+			URL:          endpoint.URL,
+			Hosts:        endpoint.Hosts,
+			Enabled:      !policy.Disabled(endpoint.Class),
+			Redirectable: endpoint.Redirectable,
+			Proxy:        proxyFor(endpoint.URL),
+		})
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY", "https_proxy", "http_proxy", "no_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			report.ProxyEnv[name] = redactProxy(value)
+		}
+	}
+	if cfg, err := nix.CurrentConfig(cmd.Context()); err == nil {
+		report.NixSubstituters = cfg.Substituters.Value
+	}
+	return report, nil
+}
+
+// proxyFor returns the proxy that devbox's HTTP client uses for rawURL.
+func proxyFor(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: u})
+	if err != nil || proxy == nil {
+		return ""
+	}
+	return redactProxy(proxy.String())
+}
+
+// redactProxy hides passwords in proxy URLs.
+func redactProxy(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return value
+}
+
+func printNetworkReport(w io.Writer, report *networkReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLASS\tSTATUS\tURL\tPROXY\tDESCRIPTION")
+	for _, e := range report.Endpoints {
+		status := "enabled"
+		if !e.Enabled {
+			status = "disabled"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Class, status, e.URL, cmp.Or(e.Proxy, "direct"), e.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	fmt.Fprintf(w, "\nNetwork policy: %s\n", report.Policy)
+	if len(report.ProxyEnv) == 0 {
+		fmt.Fprintln(w, "Proxy: none set (HTTPS_PROXY, HTTP_PROXY, NO_PROXY)")
+	} else {
+		names := lo.Keys(report.ProxyEnv)
+		slices.Sort(names)
+		var vars []string
+		for _, name := range names {
+			vars = append(vars, name+"="+report.ProxyEnv[name])
+		}
+		fmt.Fprintf(w, "Proxy: %s\n", strings.Join(vars, " "))
+	}
+	if len(report.NixSubstituters) > 0 {
+		fmt.Fprintf(w, "Nix substituters (contacted by Nix): %s\n", strings.Join(report.NixSubstituters, " "))
+	}
+	return nil
+}
+
+func debugStackCmd() *cobra.Command {
+	jsonOut := false
+	cmd := &cobra.Command{
+		Use:   "stack",
+		Short: "Print the stack of nested devbox shells that devbox runs in",
+		Long: heredoc.Doc(`
+			Print the projects of the nested devbox shells that devbox runs in,
+			innermost first, with the PATH entries that each one adds.
+
+			devbox shell --stack starts a shell of a project inside the current
+			devbox shell. The inner project's variables replace the outer ones', and
+			its PATH entries come before theirs, so the projects higher in the stack
+			take precedence. Run exit to return to the shell below.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			layers := devbox.ShellStack()
+			if jsonOut {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(layers))
+			}
+			printShellStack(cmd.OutOrStdout(), layers)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the stack as JSON")
+	return cmd
+}
+
+func printShellStack(w io.Writer, layers []devbox.ShellLayer) {
+	if len(layers) == 0 {
+		fmt.Fprintln(w, "Not in a devbox shell.")
+		return
+	}
+	for i, layer := range layers {
+		precedence := ""
+		switch {
+		case i == 0:
+			precedence = " (current, highest precedence)"
+		case i == len(layers)-1:
+			precedence = " (outermost)"
+		}
+		fmt.Fprintf(w, "%d. %s%s\n", i+1, layer.ProjectDir, precedence)
+		for _, dir := range layer.Path {
+			fmt.Fprintf(w, "   %s\n", dir)
+		}
+	}
+	fmt.Fprintf(w, "%d. host environment (lowest precedence)\n", len(layers)+1)
+}
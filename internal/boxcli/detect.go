@@ -0,0 +1,105 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type detectCmdFlags struct {
+	config configFlags
+	yes    bool
+}
+
+func detectCmd() *cobra.Command {
+	flags := detectCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "detect",
+		Short: "Propose packages for the language versions pinned in the project",
+		Long: heredoc.Doc(`
+			Read .nvmrc, .node-version, .python-version, .ruby-version, go.mod and
+			rust-toolchain.toml in the project directory, and propose the packages
+			that match the versions they pin. You're asked which packages to add,
+			unless --yes is set.
+		`),
+		Args:    cobra.ExactArgs(0),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			tools, err := devbox.DetectToolVersions(box.ProjectDir())
+			if err != nil {
+				return err
+			}
+			if len(tools) == 0 {
+				ux.Finfo(cmd.ErrOrStderr(), "No language version files were found in %s.\n", box.ProjectDir())
+				return nil
+			}
+			return addDetectedPackages(cmd, box, tools, flags.yes)
+		},
+	}
+	cmd.Flags().BoolVarP(&flags.yes, "yes", "y", false, "add every proposed package without asking")
+	flags.config.register(cmd)
+	return cmd
+}
+
+// addDetectedPackages proposes packages for the detected tools and adds the
+// ones the user accepts.
+func addDetectedPackages(cmd *cobra.Command, box *devbox.Devbox, tools []devbox.ToolVersion, yes bool) error {
+	proposed := devbox.ResolveToolVersions(tools)
+	w := tabwriter.NewWriter(cmd.ErrOrStderr(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tVERSION\tPACKAGE\tNOTE")
+	var options []string
+	for _, tool := range proposed {
+		pkg := tool.Package
+		if pkg == "" {
+			pkg = "-"
+		} else {
+			options = append(options, tool.Package)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tool.Source, tool.Version, pkg, tool.Reason)
+	}
+	w.Flush()
+	if len(options) == 0 {
+		return nil
+	}
+
+	selected := options
+	if !yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			ux.Finfo(cmd.ErrOrStderr(), "Run `devbox detect --yes` to add these packages.\n")
+			return nil
+		}
+		prompt := &survey.MultiSelect{
+			Message: "Add these packages to devbox.json?",
+			Options: options,
+			Default: options,
+		}
+		if err := survey.AskOne(prompt, &selected); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return box.Add(cmd.Context(), selected, devopt.AddOpts{})
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type devcontainerEntrypointFlags struct {
+	config configFlags
+}
+
+func devcontainerIntegrationCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "devcontainer",
+		Short: "Run devbox inside dev containers and GitHub Codespaces",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(devcontainerEntrypointCmd())
+	return cmd
+}
+
+func devcontainerEntrypointCmd() *cobra.Command {
+	flags := devcontainerEntrypointFlags{}
+	cmd := &cobra.Command{
+		Use:   "entrypoint [-- <command>...]",
+		Short: "Set up the project when a dev container starts",
+		Long: heredoc.Doc(`
+			Set up the project when a dev container starts, then run the command,
+			if there's one. Packages are installed from the mounted devbox.lock,
+			which is skipped when they're already installed, and the container's
+			default shell is set up to activate the environment with devbox
+			shellenv.
+
+			Use it as the ENTRYPOINT of the container's Dockerfile:
+
+			    ENTRYPOINT ["devbox", "devcontainer", "entrypoint", "--"]
+
+			In GitHub Codespaces, the project defaults to the repository's
+			directory.
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := setupDevcontainer(cmd, flags); err != nil {
+				// Failing would stop the container from starting, which
+				// leaves no way to fix the project from inside it.
+				ux.Fwarning(cmd.ErrOrStderr(), "Failed to set up the devbox project: %v\n", err)
+			}
+			if len(args) == 0 {
+				return nil
+			}
+			path, err := exec.LookPath(args[0])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			// Replace this process, so that the command receives the
+			// container's signals.
+			return errors.WithStack(syscall.Exec(path, args, os.Environ()))
+		},
+	}
+	flags.config.register(cmd)
+	return cmd
+}
+
+func setupDevcontainer(cmd *cobra.Command, flags devcontainerEntrypointFlags) error {
+	dir := flags.config.path
+	if dir == "" && envir.IsCodespaces() {
+		dir = os.Getenv(envir.CodespaceVSCodeFolder)
+	}
+	if err := ensureNixInstalled(cmd, nil); err != nil {
+		return err
+	}
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         dir,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return err
+	}
+	rcFile, err := box.DevcontainerSetup(cmd.Context())
+	if err != nil {
+		return err
+	}
+	ux.Fsuccess(cmd.ErrOrStderr(), "New shells activate the devbox environment of %s, set up in %s.\n", box.ProjectDir(), rcFile)
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+func direnvIntegrationCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "direnv",
+		Short: "Integrate devbox with direnv",
+		Long: heredoc.Doc(`
+			Commands used by the .envrc that ` + "`devbox generate direnv`" + ` creates.
+		`),
+	}
+	cmd.AddCommand(direnvExportCmd())
+	return cmd
+}
+
+type direnvExportCmdFlags struct {
+	envFlag
+	config      configFlags
+	runInitHook bool
+	regenerate  bool
+}
+
+func direnvExportCmd() *cobra.Command {
+	flags := direnvExportCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the devbox environment for direnv",
+		Long: heredoc.Doc(`
+			Print the devbox environment as shell code for an .envrc to evaluate,
+			along with watch_file entries for the files it depends on.
+
+			The environment is loaded from a cache, so that entering the directory
+			is fast. When devbox.json, devbox.lock, a local plugin or an env_from
+			file changes, the cached environment is still loaded and an updated one
+			is computed in the background. direnv reloads it when it's ready.
+		`),
+		Args:    cobra.ExactArgs(0),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := flags.Env(flags.config.path)
+			if err != nil {
+				return err
+			}
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+				Env:         env,
+			})
+			if err != nil {
+				return err
+			}
+			out, err := box.DirenvExport(cmd.Context(), devopt.DirenvExportOpts{
+				Regenerate: flags.regenerate,
+				RunHooks:   flags.runInitHook,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(cmd.OutOrStdout(), out)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&flags.runInitHook, "init-hook", false, "run the init hook after loading the environment")
+	cmd.Flags().BoolVar(&flags.regenerate, "regenerate", false, "recompute the cached environment")
+	_ = cmd.Flags().MarkHidden("regenerate")
+	flags.config.register(cmd)
+	flags.envFlag.register(cmd)
+	return cmd
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+type doctorCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func doctorCmd() *cobra.Command {
+	flags := doctorCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that Nix, the project and your shell are set up to use devbox",
+		Long: "Check the Nix installation and its daemon, whether devbox.lock and the installed " +
+			"packages match devbox.json, whether the binary caches are reachable, old or broken " +
+			"profiles, PATH entries that hide the project's packages, and whether your shell " +
+			"loads direnv and the global packages. Each failed check says how to fix it. " +
+			"Use --json to attach the report to a bug report. It exits with an error if a check fails.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			switch {
+			case errors.Is(err, devbox.ErrProjectNotFound) && flags.config.path == "":
+				// Outside of a project, only Nix and the shell are checked.
+				debug.Log("doctor: no project: %v", err)
+				err = nil
+			case errors.Is(err, devbox.ErrProjectNotFound):
+				return errors.WithStack(err)
+			}
+			// Any other error that opening the project returns is a failed
+			// check.
+
+			report := devbox.Doctor(cmd.Context(), box, err)
+			if flags.json {
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			} else {
+				printDoctorReport(cmd.OutOrStdout(), report)
+			}
+			if failed := report.Failed(); failed > 0 {
+				return usererr.New("%d check(s) failed.", failed)
+			}
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the report as JSON")
+	return cmd
+}
+
+func printDoctorReport(w io.Writer, report *devbox.DoctorReport) {
+	sections := []struct {
+		title  string
+		checks []nix.Check
+	}{
+		{"Nix", report.Nix},
+		{strings.TrimSpace("Project " + report.ProjectDir), report.Project},
+		{"Shell", report.Shell},
+	}
+	first := true
+	for _, section := range sections {
+		if len(section.checks) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "%s\n\n", section.title)
+		printNixChecks(w, section.checks)
+	}
+}
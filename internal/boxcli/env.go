@@ -4,12 +4,23 @@
 package boxcli
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devbox/dotenv"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/redact"
 )
 
 // to be composed into xyzCmdFlags structs
@@ -44,3 +55,141 @@ func (f *envFlag) Env(path string) (map[string]string, error) {
 
 	return envs, nil
 }
+
+type envExplainCmdFlags struct {
+	config configFlags
+}
+
+type envSchemaCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func envCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Inspect the environment variables of a devbox project",
+	}
+	cmd.AddCommand(envExplainCmd())
+	cmd.AddCommand(envSchemaCmd())
+	return cmd
+}
+
+func envExplainCmd() *cobra.Command {
+	flags := envExplainCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "explain <VAR>",
+		Short: "Show which layer sets an environment variable",
+		Long: "Show every value assigned to an environment variable by the env_from " +
+			"files (e.g. .env and .env.local), the process environment and devbox.json, " +
+			"from lowest to highest precedence, and which one is in effect.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			origins, err := box.ExplainEnv(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			printEnvOrigins(cmd.OutOrStdout(), args[0], origins)
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	return cmd
+}
+
+func printEnvOrigins(w io.Writer, key string, origins []dotenv.Origin) {
+	if len(origins) == 0 {
+		fmt.Fprintf(w, "%s is not set by devbox.json, env_from or the process environment.\n", key)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, o := range origins {
+		layer := o.Layer
+		if o.Line > 0 {
+			layer = fmt.Sprintf("%s:%d", o.Layer, o.Line)
+		}
+		value := o.Raw
+		if o.Sensitive {
+			value = redact.SecretMask
+		} else if o.Value != o.Raw {
+			value = fmt.Sprintf("%s => %s", o.Raw, o.Value)
+		}
+		status := "overridden"
+		if i == len(origins)-1 {
+			status = "in effect"
+		}
+		fmt.Fprintf(tw, "%s\t%s=%s\t(%s)\n", layer, key, value, status)
+	}
+	tw.Flush()
+}
+
+func envSchemaCmd() *cobra.Command {
+	flags := envSchemaCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Show the environment variables declared in env_schema",
+		Long: "Show the environment variables that devbox.json and its plugins declare in " +
+			"env_schema. Use --json to export the declarations for other tools.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			schema := map[string]configfile.EnvVarSpec{}
+			for name, spec := range box.EnvSchema() {
+				s := *spec
+				if s.Type == "" {
+					s.Type = configfile.EnvTypeString
+				}
+				schema[name] = s
+			}
+
+			if flags.json {
+				out, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return nil
+			}
+			printEnvSchema(cmd.OutOrStdout(), schema)
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the schema as JSON")
+	return cmd
+}
+
+func printEnvSchema(w io.Writer, schema map[string]configfile.EnvVarSpec) {
+	if len(schema) == 0 {
+		fmt.Fprintln(w, "No environment variables are declared in env_schema.")
+		return
+	}
+
+	names := lo.Keys(schema)
+	slices.Sort(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tREQUIRED\tDESCRIPTION")
+	for _, name := range names {
+		spec := schema[name]
+		typ := spec.Type
+		if len(spec.Enum) > 0 {
+			typ = strings.Join(spec.Enum, "|")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", name, typ, spec.Required, spec.Description)
+	}
+	tw.Flush()
+}
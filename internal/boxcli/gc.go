@@ -0,0 +1,100 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type gcCmdFlags struct {
+	config    configFlags
+	olderThan string
+	dryRun    bool
+	json      bool
+}
+
+func gcCmd() *cobra.Command {
+	flags := gcCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old profile generations and the store paths only they use",
+		Long: "Remove the old generations of the project's profile, the global profile and " +
+			"devbox's own tools, then delete the store paths that only those generations " +
+			"kept alive. Store paths that other projects or Nix users still need are never " +
+			"deleted, unlike with nix-collect-garbage.",
+		Args:              cobra.ExactArgs(0),
+		PersistentPreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			olderThan, err := parseOlderThan(flags.olderThan)
+			if err != nil {
+				return err
+			}
+			opts := devopt.GCOpts{OlderThan: olderThan, DryRun: flags.dryRun}
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err == nil {
+				opts.ProjectDir = box.ProjectDir()
+			} else if flags.config.path != "" {
+				return errors.WithStack(err)
+			} else {
+				// Outside of a project, only the global profile and
+				// devbox's tools are collected.
+				debug.Log("gc: no project: %v", err)
+			}
+
+			report, err := devbox.GC(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			if flags.json {
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return nil
+			}
+			devbox.PrintGC(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVar(&flags.olderThan, "older-than", "",
+		"only remove generations older than a number of days (30d) or a duration (12h). "+
+			"By default every generation except the current one is removed")
+	cmd.Flags().BoolVar(&flags.dryRun, "dry-run", false, "show what would be removed without removing it")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output what was removed as JSON")
+	return cmd
+}
+
+// parseOlderThan parses the --older-than flag, which takes days the way
+// nix-collect-garbage does, or a Go duration.
+func parseOlderThan(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, usererr.New("--older-than must be a number of days such as 30d or a duration such as 12h, not %q", s)
+}
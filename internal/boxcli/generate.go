@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
@@ -17,6 +18,7 @@ import (
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devbox/docgen"
+	"go.jetpack.io/devbox/internal/ux"
 )
 
 type generateCmdFlags struct {
@@ -56,11 +58,15 @@ func generateCmd() *cobra.Command {
 		PersistentPreRunE: ensureNixInstalled,
 	}
 	command.AddCommand(genAliasCmd())
+	command.AddCommand(bazelCmd())
+	command.AddCommand(codespacesCmd())
 	command.AddCommand(devcontainerCmd())
 	command.AddCommand(dockerfileCmd())
 	command.AddCommand(debugCmd())
 	command.AddCommand(direnvCmd())
+	command.AddCommand(gitpodCmd())
 	command.AddCommand(genReadmeCmd())
+	command.AddCommand(jetbrainsCmd())
 	command.AddCommand(sshConfigCmd())
 	flags.config.register(command)
 
@@ -98,6 +104,55 @@ func devcontainerCmd() *cobra.Command {
 	return command
 }
 
+func codespacesCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "codespaces",
+		Short: "Generate .devcontainer/ files that install packages in Codespaces prebuilds",
+		Long: heredoc.Doc(`
+			Generate the Dockerfile and devcontainer.json of a GitHub Codespaces dev
+			container. The image installs the project's packages when it's built,
+			and "devbox install" runs as the onCreateCommand and
+			updateContentCommand, so that prebuilds snapshot the environment of the
+			prebuilt commit. Enable prebuilds in the repository's Codespaces
+			settings for codespaces to start with the environment ready.
+		`),
+		Args: cobra.MaximumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerateCmd(cmd, flags)
+		},
+	}
+	command.Flags().BoolVarP(
+		&flags.force, "force", "f", false, "force overwrite on existing files")
+	command.Flags().BoolVar(
+		&flags.rootUser, "root-user", false, "Use root as default user inside the container")
+	return command
+}
+
+func gitpodCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "gitpod",
+		Short: "Generate a .gitpod.yml that installs packages in Gitpod prebuilds",
+		Long: heredoc.Doc(`
+			Generate a .gitpod.yml and the .gitpod.Dockerfile of its workspace
+			image. The init task, which Gitpod runs in prebuilds, runs "devbox
+			install" and copies the packages to a Nix binary cache in /workspace,
+			which prebuilds persist. Workspaces then install the packages from that
+			cache instead of downloading them again. Enable prebuilds in the
+			project's Gitpod settings for workspaces to start with the environment
+			ready.
+		`),
+		Args: cobra.MaximumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerateCmd(cmd, flags)
+		},
+	}
+	command.Flags().BoolVarP(
+		&flags.force, "force", "f", false, "force overwrite on existing files")
+	return command
+}
+
 func dockerfileCmd() *cobra.Command {
 	flags := &generateDockerfileCmdFlags{}
 	command := &cobra.Command{
@@ -158,6 +213,69 @@ func direnvCmd() *cobra.Command {
 	return command
 }
 
+func jetbrainsCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "jetbrains",
+		Short: "Point JetBrains IDEs at the SDKs in this devbox project",
+		Long: "Set the Go SDK, project JDK, Python interpreter and Node.js interpreter " +
+			"in the .idea directory to the ones installed by devbox, so that IDE builds " +
+			"use the same toolchains as devbox run. The settings are updated when devbox.lock changes.",
+		Args: cobra.MaximumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return box.GenerateJetBrains(cmd.Context())
+		},
+	}
+	flags.config.register(command)
+	return command
+}
+
+func bazelCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	workspace := false
+	command := &cobra.Command{
+		Use:   "bazel",
+		Short: "Register the toolchains in this devbox project with Bazel",
+		Long: "Write Bazel toolchains for the Go SDK, JDK, Python interpreter and Node.js " +
+			"installed by devbox to .devbox/gen/bazel, and print the lines that register them " +
+			"in MODULE.bazel, so that Bazel builds use the same toolchains as devbox run instead " +
+			"of the host's. The toolchains are updated when devbox.lock changes.",
+		Args: cobra.MaximumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			snippets, err := box.GenerateBazel(cmd.Context())
+			if err != nil {
+				return err
+			}
+			file, snippet := "MODULE.bazel", snippets.Module
+			if workspace {
+				file, snippet = "WORKSPACE", snippets.Workspace
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Wrote the Bazel toolchains. Add these lines to your %s:\n\n", file)
+			fmt.Fprint(cmd.OutOrStdout(), snippet)
+			return nil
+		},
+	}
+	command.Flags().BoolVar(&workspace, "workspace", false, "print the lines for a WORKSPACE file, for workspaces without bzlmod")
+	flags.config.register(command)
+	return command
+}
+
 func sshConfigCmd() *cobra.Command {
 	flags := &generateCmdFlags{}
 	command := &cobra.Command{
@@ -283,8 +401,12 @@ func runGenerateCmd(cmd *cobra.Command, flags *generateCmdFlags) error {
 	switch cmd.Use {
 	case "debug":
 		return box.Generate(cmd.Context())
+	case "codespaces":
+		return box.GenerateCodespaces(cmd.Context(), generateOpts)
 	case "devcontainer":
 		return box.GenerateDevcontainer(cmd.Context(), generateOpts)
+	case "gitpod":
+		return box.GenerateGitpod(cmd.Context(), generateOpts)
 	}
 	return nil
 }
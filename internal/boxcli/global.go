@@ -41,6 +41,7 @@ func globalCmd() *cobra.Command {
 	}
 
 	addCommandAndHideConfigFlag(globalCmd, addCmd())
+	addCommandAndHideConfigFlag(globalCmd, importCmd())
 	addCommandAndHideConfigFlag(globalCmd, installCmd())
 	addCommandAndHideConfigFlag(globalCmd, pathCmd())
 	addCommandAndHideConfigFlag(globalCmd, pullCmd())
@@ -57,7 +58,15 @@ func globalCmd() *cobra.Command {
 
 func addCommandAndHideConfigFlag(parent, child *cobra.Command) {
 	parent.AddCommand(child)
-	_ = child.Flags().MarkHidden("config")
+	hideConfigFlag(child)
+}
+
+// hideConfigFlag hides the config flag of cmd and its subcommands.
+func hideConfigFlag(cmd *cobra.Command) {
+	_ = cmd.Flags().MarkHidden("config")
+	for _, c := range cmd.Commands() {
+		hideConfigFlag(c)
+	}
 }
 
 var globalConfigPath string
@@ -87,15 +96,23 @@ func setGlobalConfigForDelegatedCommands(
 			return err
 		}
 
-		for _, c := range globalCmd.Commands() {
-			if f := c.Flag("config"); f != nil && f.Value.Type() == "string" {
-				if err := f.Value.Set(globalPath); err != nil {
-					return errors.WithStack(err)
-				}
+		return setConfigFlag(globalCmd.Commands(), globalPath)
+	}
+}
+
+// setConfigFlag sets the config flag of cmds and their subcommands to path.
+func setConfigFlag(cmds []*cobra.Command, path string) error {
+	for _, c := range cmds {
+		if f := c.Flag("config"); f != nil && f.Value.Type() == "string" {
+			if err := f.Value.Set(path); err != nil {
+				return errors.WithStack(err)
 			}
 		}
-		return nil
+		if err := setConfigFlag(c.Commands(), path); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func ensureGlobalEnvEnabled(cmd *cobra.Command, args []string) error {
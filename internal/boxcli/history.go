@@ -0,0 +1,162 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type historyCmdFlags struct {
+	config  configFlags
+	pkg     string
+	since   string
+	limit   int
+	jsonOut bool
+}
+
+func historyCmd() *cobra.Command {
+	flags := historyCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the changes made to the project's environment",
+		Long: heredoc.Doc(`
+			Show when packages, plugins and environment variables of the project
+			were added, removed or changed, who changed them, and their versions
+			before and after.
+
+			Devbox records the changes in .devbox/history.jsonl whenever a command
+			such as add, rm or update runs. Changes made by editing devbox.json
+			are recorded the next time devbox runs, with the action "edit".
+		`),
+		Example: heredoc.Doc(`
+			devbox history --package nodejs
+			devbox history --since 168h
+			devbox history --since 2024-06-01 --json
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			entries, err := box.History()
+			if err != nil {
+				return err
+			}
+			entries, err = filterHistory(entries, flags, time.Now())
+			if err != nil {
+				return err
+			}
+
+			if flags.jsonOut {
+				if entries == nil {
+					entries = []devbox.HistoryEntry{}
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(entries))
+			}
+			if len(entries) == 0 {
+				ux.Finfo(cmd.ErrOrStderr(), "No changes recorded.\n")
+				return nil
+			}
+			return printHistory(cmd.OutOrStdout(), entries)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVarP(&flags.pkg, "package", "p", "", "only show changes to this package")
+	cmd.Flags().StringVar(&flags.since, "since", "",
+		"only show changes after a date (2024-06-01) or within a duration (24h)")
+	cmd.Flags().IntVarP(&flags.limit, "limit", "n", 0, "only show the most recent changes")
+	cmd.Flags().BoolVar(&flags.jsonOut, "json", false, "print the changes as JSON")
+	return cmd
+}
+
+// filterHistory keeps the entries and changes that match the flags.
+func filterHistory(
+	entries []devbox.HistoryEntry,
+	flags historyCmdFlags,
+	now time.Time,
+) ([]devbox.HistoryEntry, error) {
+	var since time.Time
+	if flags.since != "" {
+		if d, err := time.ParseDuration(flags.since); err == nil {
+			since = now.Add(-d)
+		} else if t, err := time.Parse(time.DateOnly, flags.since); err == nil {
+			since = t
+		} else if t, err := time.Parse(time.RFC3339, flags.since); err == nil {
+			since = t
+		} else {
+			return nil, usererr.New("--since must be a date such as 2024-06-01 or a duration such as 24h, not %q", flags.since)
+		}
+	}
+
+	var result []devbox.HistoryEntry
+	for _, entry := range entries {
+		if entry.Time.Before(since) {
+			continue
+		}
+		if flags.pkg != "" {
+			var changes []devbox.Change
+			for _, change := range entry.Changes {
+				if change.Kind == devbox.ChangePackage && change.Name == flags.pkg {
+					changes = append(changes, change)
+				}
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			entry.Changes = changes
+		}
+		result = append(result, entry)
+	}
+	if flags.limit > 0 && len(result) > flags.limit {
+		result = result[len(result)-flags.limit:]
+	}
+	return result, nil
+}
+
+func printHistory(w io.Writer, entries []devbox.HistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tACTION\tCHANGE")
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+				entry.Time.Local().Format(time.DateTime), entry.User, entry.Action, formatChange(change))
+		}
+	}
+	return errors.WithStack(tw.Flush())
+}
+
+func formatChange(c devbox.Change) string {
+	change := c.Name
+	if c.Kind != devbox.ChangePackage {
+		change = c.Kind + " " + c.Name
+	}
+	switch c.Op {
+	case devbox.OpAdded:
+		return strings.TrimSpace("+ " + change + " " + c.After)
+	case devbox.OpRemoved:
+		return strings.TrimSpace("- " + change + " " + c.Before)
+	default:
+		return fmt.Sprintf("~ %s %s -> %s", change, c.Before, c.After)
+	}
+}
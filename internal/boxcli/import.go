@@ -0,0 +1,210 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+func importCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import packages from other tools' configuration",
+	}
+	cmd.AddCommand(importCondaCmd())
+	cmd.AddCommand(importHomeManagerCmd())
+	cmd.AddCommand(importToolVersionsCmd())
+	return cmd
+}
+
+type importCmdFlags struct {
+	config       configFlags
+	requirements string
+}
+
+func importToolVersionsCmd() *cobra.Command {
+	flags := importCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "tool-versions [path]",
+		Short: "Import the tools in an asdf .tool-versions file",
+		Long: heredoc.Doc(`
+			Add the tools in an asdf .tool-versions file to devbox.json. Plugins are
+			mapped to devbox packages, and versions that aren't available are
+			replaced with the closest one that is, such as the latest 3.11 release
+			for 3.11.4. Entries that can't be mapped are reported and skipped.
+
+			The path defaults to the .tool-versions file in the project directory.
+		`),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(box.ProjectDir(), ".tool-versions")
+			if len(args) > 0 {
+				path = args[0]
+			}
+			imported, err := box.ImportToolVersions(cmd.Context(), path)
+			printImportedTools(cmd, imported)
+			return err
+		},
+	}
+	flags.config.register(cmd)
+	return cmd
+}
+
+func importHomeManagerCmd() *cobra.Command {
+	flags := importCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "home-manager [config]",
+		Short: "Import the packages of a Home Manager configuration",
+		Long: heredoc.Doc(`
+			Add the packages in the home.packages list of a Home Manager
+			configuration to devbox.json. Use "devbox global import home-manager" to
+			move them to the global profile. Packages are pinned to their latest
+			version, and versioned attributes that devbox doesn't know, such as
+			nodejs_20, are mapped to the closest version of the package.
+
+			The configuration isn't evaluated, so home.packages must be a list of
+			package names. Elements that are Nix expressions, such as
+			(python3.withPackages ...), are reported and skipped.
+
+			The config defaults to ~/.config/home-manager/home.nix.
+		`),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(xdg.ConfigSubpath("home-manager"), "home.nix")
+			if len(args) > 0 {
+				path = args[0]
+			}
+			imported, err := box.ImportHomeManager(cmd.Context(), path)
+			printImportedTools(cmd, imported)
+			return err
+		},
+	}
+	flags.config.register(cmd)
+	return cmd
+}
+
+func importCondaCmd() *cobra.Command {
+	flags := importCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "conda [environment.yml]",
+		Short: "Import the dependencies of a conda environment.yml",
+		Long: heredoc.Doc(`
+			Add the dependencies of a conda environment.yml to devbox.json. Python,
+			and packages that aren't Python libraries, such as cmake or r-base, are
+			mapped to devbox packages. Python libraries, and the pip section of the
+			file, are written to a pip requirements file for the python plugin's
+			virtual environment, with an install-requirements script that installs
+			them.
+
+			Each dependency is reported with how confident the mapping is: high for
+			packages devbox knows, medium when the version differs, and low for
+			packages mapped by their name alone. Check the low ones before
+			committing the changes.
+
+			The file defaults to environment.yml in the project directory.
+		`),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(box.ProjectDir(), "environment.yml")
+			if len(args) > 0 {
+				path = args[0]
+			}
+			imported, err := box.ImportConda(cmd.Context(), path, flags.requirements)
+			printImportedCondaPackages(cmd, imported)
+			return err
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVar(&flags.requirements, "requirements", "requirements.txt",
+		"pip requirements file to write the Python libraries to, relative to the project directory")
+	return cmd
+}
+
+func printImportedCondaPackages(cmd *cobra.Command, imported []devbox.ImportedCondaPackage) {
+	if len(imported) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPENDENCY\tIMPORTED AS\tCONFIDENCE\tNOTE")
+	skipped := 0
+	for _, pkg := range imported {
+		name := pkg.Name + pkg.Spec
+		as, confidence := "-", "-"
+		switch {
+		case pkg.Package != "":
+			as = pkg.Package
+		case pkg.Requirement != "":
+			as = "pip: " + pkg.Requirement
+		default:
+			skipped++
+		}
+		if pkg.Confidence != "" {
+			confidence = pkg.Confidence
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, as, confidence, pkg.Reason)
+	}
+	w.Flush()
+	if skipped > 0 {
+		ux.Finfo(cmd.ErrOrStderr(), "%d of %d dependencies weren't imported.\n", skipped, len(imported))
+	}
+}
+
+func printImportedTools(cmd *cobra.Command, imported []devbox.ImportedTool) {
+	if len(imported) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tVERSION\tPACKAGE\tNOTE")
+	skipped := 0
+	for _, tool := range imported {
+		pkg := tool.Package
+		if pkg == "" {
+			pkg = "-"
+			skipped++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tool.Plugin, tool.Version, pkg, tool.Reason)
+	}
+	w.Flush()
+	if skipped > 0 {
+		ux.Fwarning(cmd.ErrOrStderr(), "%d of %d tools weren't imported. Use `devbox search` to find packages for them.\n",
+			skipped, len(imported))
+	}
+}
@@ -4,31 +4,75 @@
 package boxcli
 
 import (
+	"os"
+
+	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
 )
 
+type initCmdFlags struct {
+	yes bool
+}
+
 func initCmd() *cobra.Command {
+	flags := initCmdFlags{}
 	command := &cobra.Command{
 		Use:   "init [<dir>]",
 		Short: "Initialize a directory as a devbox project",
 		Long: "Initialize a directory as a devbox project. " +
 			"This will create an empty devbox.json in the current directory. " +
-			"You can then add packages using `devbox add`",
+			"You can then add packages using `devbox add`. " +
+			"If the directory has language version files, such as .nvmrc or go.mod, " +
+			"devbox proposes packages that match them.",
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInitCmd(args)
+			return runInitCmd(cmd, args, flags)
 		},
 	}
+	command.Flags().BoolVarP(&flags.yes, "yes", "y", false,
+		"add the packages proposed for language version files without asking")
 
 	return command
 }
 
-func runInitCmd(args []string) error {
+func runInitCmd(cmd *cobra.Command, args []string, flags initCmdFlags) error {
 	path := pathArg(args)
 
-	_, err := devbox.InitConfig(path)
-	return errors.WithStack(err)
+	created, err := devbox.InitConfig(path)
+	if err != nil || !created {
+		return errors.WithStack(err)
+	}
+
+	// devbox.json is written by now, so proposing packages for the
+	// language version files is best-effort: problems only get a warning.
+	skip := func(err error) error {
+		ux.Fwarning(cmd.ErrOrStderr(), "Not proposing packages for language version files: %v\n", err)
+		return nil
+	}
+	tools, err := devbox.DetectToolVersions(path)
+	if err != nil {
+		return skip(err)
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+	if !flags.yes && !isatty.IsTerminal(os.Stdin.Fd()) {
+		// Resolving the packages needs Nix and the network, which
+		// isn't worth it if nobody can be asked.
+		ux.Finfo(cmd.ErrOrStderr(), "Run `devbox detect` to add packages for the language version files.\n")
+		return nil
+	}
+	if err := ensureNixInstalled(cmd, args); err != nil {
+		return skip(err)
+	}
+	box, err := devbox.Open(&devopt.Opts{Dir: path, Stderr: cmd.ErrOrStderr()})
+	if err != nil {
+		return skip(err)
+	}
+	return addDetectedPackages(cmd, box, tools, flags.yes)
 }
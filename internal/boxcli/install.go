@@ -13,11 +13,20 @@ import (
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 )
 
+type installCmdFlags struct {
+	runCmdFlags
+	frozen bool
+}
+
 func installCmd() *cobra.Command {
-	flags := runCmdFlags{}
+	flags := installCmdFlags{}
 	command := &cobra.Command{
-		Use:     "install",
-		Short:   "Install all packages mentioned in devbox.json",
+		Use:   "install",
+		Short: "Install all packages mentioned in devbox.json",
+		Long: "Install all packages mentioned in devbox.json.\n\n" +
+			"With --frozen, the install fails instead of changing devbox.lock, and " +
+			"if devbox.lock is missing packages or locks them to references that " +
+			"aren't pinned to a revision. Use it in CI to install exactly what was reviewed.",
 		Args:    cobra.MaximumNArgs(0),
 		PreRunE: ensureNixInstalled,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -26,11 +35,13 @@ func installCmd() *cobra.Command {
 	}
 
 	flags.config.register(command)
+	command.Flags().BoolVar(&flags.frozen, "frozen", false,
+		"fail instead of resolving packages or changing devbox.lock")
 
 	return command
 }
 
-func installCmdFunc(cmd *cobra.Command, flags runCmdFlags) error {
+func installCmdFunc(cmd *cobra.Command, flags installCmdFlags) error {
 	// Check the directory exists.
 	box, err := devbox.Open(&devopt.Opts{
 		Dir:         flags.config.path,
@@ -40,7 +51,7 @@ func installCmdFunc(cmd *cobra.Command, flags runCmdFlags) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	if err = box.Install(cmd.Context()); err != nil {
+	if err = box.Install(cmd.Context(), devopt.InstallOpts{Frozen: flags.frozen}); err != nil {
 		return errors.WithStack(err)
 	}
 	fmt.Fprintln(cmd.ErrOrStderr(), "Finished installing packages.")
@@ -36,10 +36,51 @@ func integrateCmd() *cobra.Command {
 			return cmd.Help()
 		},
 	}
+	command.AddCommand(integrateTmuxCmd())
 	command.AddCommand(integrateVSCodeCmd())
 	return command
 }
 
+type integrateTmuxCmdFlags struct {
+	config  configFlags
+	remove  bool
+	session string
+}
+
+func integrateTmuxCmd() *cobra.Command {
+	flags := integrateTmuxCmdFlags{}
+	command := &cobra.Command{
+		Use:   "tmux",
+		Short: "Start new tmux or screen windows and panes in the devbox environment",
+		Long: "Share the devbox environment with the tmux session or screen that devbox runs in, " +
+			"so that new windows, splits and panes start in it. Run it in a devbox shell, or add " +
+			"`[ -n \"$TMUX\" ] && devbox integrate tmux` to your init_hook to share the environment " +
+			"every time a devbox shell starts.",
+		Args:    cobra.NoArgs,
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			if flags.remove {
+				return box.UnshareEnvWithMultiplexer(cmd.Context(), flags.session)
+			}
+			return box.ShareEnvWithMultiplexer(cmd.Context(), flags.session)
+		},
+	}
+	command.Flags().BoolVar(&flags.remove, "remove", false, "stop sharing the environment with new windows and panes")
+	// The client-attached hook passes the session, since it doesn't run in a pane.
+	command.Flags().StringVar(&flags.session, "session", "", "tmux session to share the environment with")
+	_ = command.Flags().MarkHidden("session")
+	flags.config.register(command)
+	return command
+}
+
 func integrateVSCodeCmd() *cobra.Command {
 	flags := integrateCmdFlags{}
 	command := &cobra.Command{
@@ -5,16 +5,20 @@ package boxcli
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/lock"
 )
 
 type listCmdFlags struct {
 	config configFlags
+	tree   bool
 }
 
 func listCmd() *cobra.Command {
@@ -32,6 +36,10 @@ func listCmd() *cobra.Command {
 			if err != nil {
 				return errors.WithStack(err)
 			}
+			if flags.tree {
+				printPackageTree(cmd.OutOrStdout(), box.Config().PackageTree(), box.Lockfile())
+				return nil
+			}
 			for _, p := range box.AllPackageNamesIncludingRemovedTriggerPackages() {
 				fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p)
 			}
@@ -39,5 +47,37 @@ func listCmd() *cobra.Command {
 		},
 	}
 	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.tree, "tree", false,
+		"show which config added each package: devbox.json, a plugin or an included config")
 	return cmd
 }
+
+// printPackageTree prints the configs that add packages to the project, with
+// the version that each package is locked to.
+func printPackageTree(w io.Writer, tree *devconfig.PackageTree, lockfile *lock.File) {
+	fmt.Fprintln(w, tree.Source)
+	printPackageSubtree(w, tree, lockfile, "")
+}
+
+func printPackageSubtree(w io.Writer, tree *devconfig.PackageTree, lockfile *lock.File, indent string) {
+	n := len(tree.Packages) + len(tree.Included)
+	branch := func(i int) (string, string) {
+		if i == n-1 {
+			return indent + "└── ", indent + "    "
+		}
+		return indent + "├── ", indent + "│   "
+	}
+	for i, pkg := range tree.Packages {
+		prefix, _ := branch(i)
+		if locked := lockfile.Get(pkg); locked != nil && locked.Version != "" {
+			fmt.Fprintf(w, "%s%s (%s)\n", prefix, pkg, locked.Version)
+		} else {
+			fmt.Fprintf(w, "%s%s\n", prefix, pkg)
+		}
+	}
+	for i, included := range tree.Included {
+		prefix, childIndent := branch(len(tree.Packages) + i)
+		fmt.Fprintf(w, "%s%s\n", prefix, included.Source)
+		printPackageSubtree(w, included, lockfile, childIndent)
+	}
+}
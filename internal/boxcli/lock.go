@@ -0,0 +1,357 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type lockExplainCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+type lockPruneCmdFlags struct {
+	config configFlags
+	dryRun bool
+}
+
+type lockRollbackCmdFlags struct {
+	config configFlags
+	list   bool
+}
+
+type lockResolveCmdFlags struct {
+	config     configFlags
+	allSystems bool
+}
+
+func lockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage devbox.lock",
+	}
+	cmd.AddCommand(lockExplainCmd())
+	cmd.AddCommand(lockPruneCmd())
+	cmd.AddCommand(lockResolveCmd())
+	cmd.AddCommand(lockRollbackCmd())
+	return cmd
+}
+
+func lockExplainCmd() *cobra.Command {
+	flags := lockExplainCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "explain <pkg>",
+		Short: "Show where a package's entry in devbox.lock came from",
+		Long: heredoc.Doc(`
+			Show where the entry of a package in devbox.lock came from: the version
+			that devbox asked the search service for, the installable it resolved
+			to, the store paths it has for each system and when it was last
+			modified.
+
+			It also says whether devbox will fetch the package's store paths from a
+			binary cache on this machine, or whether Nix has to evaluate nixpkgs and
+			may build it, and why. Use it to find out why devbox is rebuilding a
+			package.
+		`),
+		Example: "  devbox lock explain python@3.12",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockExplainCmd(cmd, args[0], flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the explanation as JSON")
+	return cmd
+}
+
+func runLockExplainCmd(cmd *cobra.Command, pkg string, flags lockExplainCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	explanation, err := box.ExplainLock(pkg)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		out, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	}
+	printLockExplanation(cmd.OutOrStdout(), explanation)
+	return nil
+}
+
+func printLockExplanation(w io.Writer, e *devbox.LockExplanation) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Package:\t%s\n", e.Package)
+	fmt.Fprintf(tw, "Source:\t%s\n", lockSourceDescription(e.Source))
+	if e.Query != "" {
+		fmt.Fprintf(tw, "Search query:\t%s\n", e.Query)
+	}
+	fmt.Fprintf(tw, "Resolved (%s):\t%s\n", e.System, e.Resolved)
+	if e.Version != "" {
+		fmt.Fprintf(tw, "Version:\t%s\n", e.Version)
+	}
+	if e.LastModified != "" {
+		fmt.Fprintf(tw, "Last modified:\t%s\n", e.LastModified)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if len(e.Systems) == 0 {
+		fmt.Fprintln(w, "devbox.lock doesn't have store paths for any system.")
+	} else {
+		fmt.Fprintln(w, "Store paths:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, sys := range e.Systems {
+			for _, out := range sys.Outputs {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\n", sys.System, out.Name, out.Path)
+			}
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintln(w)
+	switch e.InstallPath {
+	case devbox.InstallFromStorePath:
+		fmt.Fprintf(w, "Install: fast path, fetching store paths, because %s.\n", e.Reason)
+	case devbox.InstallFromEval:
+		fmt.Fprintf(w, "Install: slow path, evaluating with Nix, because %s.\n", e.Reason)
+	case devbox.InstallFromRunX:
+		fmt.Fprintln(w, "Install: RunX downloads it from the package's GitHub release.")
+	}
+}
+
+func lockSourceDescription(source string) string {
+	switch source {
+	case "devbox-search":
+		return "devbox-search (resolved by the search service)"
+	case "pinned":
+		return "pinned (devbox.json pins it to a nixpkgs commit)"
+	case "flake":
+		return "flake (locked to a revision of the flake)"
+	case "nixpkg":
+		return "nixpkg (resolved against the project's nixpkgs)"
+	case "":
+		return "unknown"
+	}
+	return source
+}
+
+func lockPruneCmd() *cobra.Command {
+	flags := lockPruneCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove packages that devbox.json no longer uses from devbox.lock",
+		Long: heredoc.Doc(`
+			Remove the packages in devbox.lock that neither devbox.json nor its
+			plugins reference anymore, and list what was removed.
+
+			Devbox also removes them the next time it installs the project, but
+			prune works without Nix and doesn't install anything. Use --dry-run to
+			only list them.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockPruneCmd(cmd, flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.dryRun, "dry-run", false,
+		"list the packages that would be removed without changing devbox.lock")
+	return cmd
+}
+
+func runLockPruneCmd(cmd *cobra.Command, flags lockPruneCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pruned, err := box.PruneLockfile(flags.dryRun)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.ErrOrStderr()
+	if len(pruned) == 0 {
+		ux.Finfo(w, "devbox.lock doesn't have any packages that devbox.json no longer uses.\n")
+		return nil
+	}
+	for _, pkg := range pruned {
+		if flags.dryRun {
+			ux.Finfo(w, "Would remove %s\n", pkg)
+		} else {
+			ux.Finfo(w, "Removed %s\n", pkg)
+		}
+	}
+	if !flags.dryRun {
+		ux.Fsuccess(w, "Removed %d packages from devbox.lock\n", len(pruned))
+	}
+	return nil
+}
+
+func lockResolveCmd() *cobra.Command {
+	flags := lockResolveCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "resolve",
+		Short: "Lock the packages in devbox.json without installing them",
+		Long: heredoc.Doc(`
+			Resolve the packages of devbox.json and its plugins that aren't in
+			devbox.lock yet, and write devbox.lock without installing anything.
+
+			With --all-systems, devbox also adds store paths for every system that
+			the search service has each package for, not only the systems that were
+			locked when the package was resolved. Run it on macOS before committing
+			devbox.lock so that Linux CI can install the packages from a binary
+			cache without resolving them again. Packages keep their locked versions.
+		`),
+		Example: "  devbox lock resolve --all-systems",
+		Args:    cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockResolveCmd(cmd, flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.allSystems, "all-systems", false,
+		"lock store paths for every system that the search service has, not just this one")
+	return cmd
+}
+
+func runLockResolveCmd(cmd *cobra.Command, flags lockResolveCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	missing, err := box.ResolveLock(cmd.Context(), flags.allSystems)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.ErrOrStderr()
+	pkgs := lo.Keys(missing)
+	slices.Sort(pkgs)
+	for _, pkg := range pkgs {
+		ux.Fwarning(w, "devbox.lock doesn't have store paths for %s on %s, which will install "+
+			"by evaluating nixpkgs.\n", pkg, strings.Join(missing[pkg], ", "))
+	}
+	ux.Fsuccess(w, "devbox.lock is up to date\n")
+	return nil
+}
+
+func lockRollbackCmd() *cobra.Command {
+	flags := lockRollbackCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "rollback [<id>]",
+		Short: "Restore devbox.lock from the lock history",
+		Long: heredoc.Doc(`
+			Restore devbox.lock to a snapshot from the lock history. Each time devbox
+			changes devbox.lock, it keeps a copy in .devbox/lock-history, up to the
+			last 20.
+
+			Without an ID, rollback restores devbox.lock to what it was before its
+			last change, such as a devbox update that broke something. Use --list to
+			see the snapshots and their IDs. Rolling back is itself recorded, so it
+			can be undone the same way. Run devbox install afterwards to install the
+			restored packages.
+		`),
+		Example: heredoc.Doc(`
+			  devbox lock rollback
+			  devbox lock rollback --list
+			  devbox lock rollback 20241014T150405.123456Z
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockRollbackCmd(cmd, args, flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.list, "list", false, "list the snapshots in the lock history")
+	return cmd
+}
+
+func runLockRollbackCmd(cmd *cobra.Command, args []string, flags lockRollbackCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if flags.list {
+		snapshots, err := box.LockHistory()
+		if err != nil {
+			return err
+		}
+		printLockHistory(cmd.OutOrStdout(), snapshots)
+		return nil
+	}
+
+	id := ""
+	if len(args) > 0 {
+		id = args[0]
+	}
+	snapshot, changes, err := box.RollbackLockfile(id)
+	if err != nil {
+		return err
+	}
+	w := cmd.ErrOrStderr()
+	for _, change := range changes {
+		switch change.Op {
+		case devbox.OpAdded:
+			ux.Finfo(w, "Restored %s %s\n", change.Name, change.After)
+		case devbox.OpRemoved:
+			ux.Finfo(w, "Removed %s %s\n", change.Name, change.Before)
+		default:
+			ux.Finfo(w, "Rolled back %s from %s to %s\n", change.Name, change.Before, change.After)
+		}
+	}
+	ux.Fsuccess(w, "Restored devbox.lock from %s. Run `devbox install` to install it.\n",
+		snapshot.Time.Local().Format(time.DateTime))
+	return nil
+}
+
+func printLockHistory(w io.Writer, snapshots []*lock.Snapshot) {
+	if len(snapshots) == 0 {
+		fmt.Fprintln(w, "The lock history is empty. Devbox adds to it each time it changes devbox.lock.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tPACKAGES")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", snapshot.ID,
+			snapshot.Time.Local().Format(time.DateTime), len(snapshot.Packages))
+	}
+	tw.Flush()
+}
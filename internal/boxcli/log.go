@@ -4,29 +4,63 @@
 package boxcli
 
 import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/telemetry"
 )
 
 func logCmd() *cobra.Command {
+	var list bool
 	cmd := &cobra.Command{
-		Use:    "log <event-name> [<event-specific-args>]",
-		Hidden: true,
+		Use:   "log",
+		Short: "Show the full log of the last Nix build",
+		Long: "Show the full log of the last nix command that devbox ran to install packages, " +
+			"including the output of the builds that devbox install doesn't show.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return doLogCommand(cmd, args)
+			// Devbox's shell hooks also run devbox log <event-name> to
+			// record telemetry events.
+			if len(args) > 0 {
+				return doLogCommand(cmd, args)
+			}
+			return showNixLog(cmd, list)
 		},
 	}
-
+	cmd.Flags().BoolVar(&list, "list", false, "list the saved logs, newest first")
 	return cmd
 }
 
-func doLogCommand(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
-		return usererr.New("expect an <event-name> arg for command: %s", cmd.CommandPath())
+func showNixLog(cmd *cobra.Command, list bool) error {
+	logs, err := nix.Logs()
+	if err != nil {
+		return err
+	}
+	if list {
+		for _, log := range logs {
+			fmt.Fprintln(cmd.OutOrStdout(), log)
+		}
+		return nil
+	}
+	if len(logs) == 0 {
+		return usererr.New("There are no Nix logs yet. Devbox saves one each time it installs packages.")
 	}
+	f, err := os.Open(logs[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	_, err = io.Copy(cmd.OutOrStdout(), f)
+	return errors.WithStack(err)
+}
 
+func doLogCommand(cmd *cobra.Command, args []string) error {
 	switch eventName := args[0]; eventName {
 	case "shell-ready":
 		if len(args) < 2 {
@@ -42,6 +76,11 @@ func doLogCommand(cmd *cobra.Command, args []string) error {
 		telemetry.Event(telemetry.EventShellInteractive, telemetry.Metadata{
 			EventStart: telemetry.ParseShellStart(args[1]),
 		})
+	case "record-env":
+		if len(args) < 2 {
+			return usererr.New("expected a stage argument for recording the environment")
+		}
+		return devbox.RecordShellStage(args[1])
 	}
 	return usererr.New("unrecognized event-name %s for command: %s", args[0], cmd.CommandPath())
 }
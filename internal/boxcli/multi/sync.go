@@ -44,7 +44,8 @@ func SyncLockfiles(pkgs []string) error {
 				if pkg.LastModified != latestPkg.LastModified {
 					lockFile.Packages[key].AllowInsecure = latestPkg.AllowInsecure
 					lockFile.Packages[key].LastModified = latestPkg.LastModified
-					// PluginVersion is intentionally omitted
+					lockFile.Packages[key].License = latestPkg.License
+					// PluginVersion and SourceOf are intentionally omitted
 					lockFile.Packages[key].Resolved = latestPkg.Resolved
 					lockFile.Packages[key].Source = latestPkg.Source
 					lockFile.Packages[key].Version = latestPkg.Version
@@ -0,0 +1,133 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+type nixInstallFlags struct {
+	installer string
+	version   string
+}
+
+func nixCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nix",
+		Short: "Install, upgrade and check the Nix that devbox uses",
+	}
+	cmd.AddCommand(nixInstallCmd())
+	cmd.AddCommand(nixUpgradeCmd())
+	cmd.AddCommand(nixDoctorCmd())
+	cmd.AddCommand(nixUninstallCmd())
+	return cmd
+}
+
+func nixInstallCmd() *cobra.Command {
+	flags := nixInstallFlags{}
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install Nix",
+		Long: fmt.Sprintf("Install Nix %s, or the version given by --version, with the nixos.org "+
+			"install script or the Determinate installer. Nothing is changed if Nix is already installed.",
+			nix.InstallVersion),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstallNixCmd(cmd, nix.InstallOpts{
+				Version:   flags.version,
+				Installer: flags.installer,
+			})
+		},
+	}
+	cmd.Flags().Bool(nixDaemonFlag, false, "Install Nix in multi-user mode.")
+	cmd.Flags().StringVar(&flags.installer, "installer", nix.InstallerNixOS,
+		fmt.Sprintf("installer to use: %q or %q", nix.InstallerNixOS, nix.InstallerDeterminate))
+	cmd.Flags().StringVar(&flags.version, "version", nix.InstallVersion, "Nix version to install")
+	return cmd
+}
+
+func nixUpgradeCmd() *cobra.Command {
+	var version string
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade Nix",
+		Long: "Upgrade the installed Nix to the latest stable release, or to the release given by " +
+			"--version. Multi-user installs are upgraded with sudo.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nix.Upgrade(cmd.Context(), cmd.ErrOrStderr(), version)
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", "", "Nix version to upgrade to, such as "+nix.InstallVersion)
+	return cmd
+}
+
+func nixUninstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "Uninstall Nix",
+		Long: "Uninstall Nix and everything in the Nix store. Only installs made by the " +
+			"Determinate installer can be uninstalled this way.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return nix.Uninstall(cmd.Context(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+func nixDoctorCmd() *cobra.Command {
+	var jsonOut bool
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that Nix is installed and works with devbox",
+		Long: "Check the Nix binary, its version, how it was installed and whether devbox can " +
+			"reach the store. It exits with an error if a check fails.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checks := nix.Doctor(cmd.Context())
+			if jsonOut {
+				out, err := json.MarshalIndent(checks, "", "  ")
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+			} else {
+				printNixChecks(cmd.OutOrStdout(), checks)
+			}
+			failed := lo.CountBy(checks, func(c nix.Check) bool { return !c.OK })
+			if failed > 0 {
+				return usererr.New("%d Nix check(s) failed.", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output the checks as JSON")
+	return cmd
+}
+
+func printNixChecks(w io.Writer, checks []nix.Check) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", lo.Ternary(c.OK, "ok", "FAIL"), c.Name, c.Detail)
+	}
+	tw.Flush()
+
+	fixes := lo.Filter(checks, func(c nix.Check, _ int) bool { return c.Fix != "" })
+	if len(fixes) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, c := range fixes {
+		fmt.Fprintf(w, "%s: %s\n", c.Name, c.Fix)
+	}
+}
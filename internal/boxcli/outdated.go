@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type outdatedCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func outdatedCmd() *cobra.Command {
+	flags := outdatedCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "List the locked packages that have newer versions",
+		Long: "Resolve the latest version of each package in devbox.json and compare it to " +
+			"the version in devbox.lock. Nothing is installed or changed, so it's safe to " +
+			"run on a schedule to see which packages to update.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			report, err := box.Outdated(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if flags.json {
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(out))
+				return nil
+			}
+			devbox.PrintOutdated(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the current and latest version of each package as JSON")
+	return cmd
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"os"
+	"text/template"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+const defaultPromptFormat = `{{ .Name }}{{ if .Stale }}*{{ end }}{{ if .RunningServices }} [{{ .RunningServices }} running]{{ end }}`
+
+type promptCmdFlags struct {
+	config configFlags
+	format string
+	json   bool
+}
+
+func promptCmd() *cobra.Command {
+	flags := promptCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Print the status of the devbox project for shell prompts",
+		Long: heredoc.Doc(`
+			Print the name of the devbox project, whether its environment is out of
+			date, and the number of services that are running, for prompts such as
+			starship and powerlevel10k. It prints nothing outside of a project, and
+			it doesn't run Nix, so it's fast enough to run for every prompt.
+
+			The project is the one whose environment is active, or else the one in
+			the current directory. An active environment is stale (*) once
+			devbox.json or devbox.lock changes, until you run refresh.
+
+			--format is a Go template with the fields .Name, .ProjectDir, .Active,
+			.Stale and .RunningServices.
+		`),
+		Example: heredoc.Doc(`
+			# starship.toml
+			[custom.devbox]
+			command = "devbox prompt"
+			when = "test -n \"$DEVBOX_PROJECT_ROOT\""
+			format = "[📦 $output]($style) "
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return promptCmdFunc(cmd, flags)
+		},
+	}
+	cmd.Flags().StringVar(&flags.format, "format", defaultPromptFormat, "Go template of the output")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "print the status as JSON")
+	flags.config.register(cmd)
+	return cmd
+}
+
+func promptCmdFunc(cmd *cobra.Command, flags promptCmdFlags) error {
+	tmpl, err := template.New("prompt").Parse(flags.format)
+	if err != nil {
+		return usererr.WithUserMessage(err, "Invalid --format template.")
+	}
+
+	dir := flags.config.path
+	if dir == "" {
+		// Show the active project, even outside of its directory.
+		dir = os.Getenv("DEVBOX_PROJECT_ROOT")
+	}
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:            dir,
+		Environment:    flags.config.environment,
+		Stderr:         cmd.ErrOrStderr(),
+		IgnoreWarnings: true,
+	})
+	if err != nil {
+		// A prompt shouldn't print errors outside of a project.
+		debug.Log("prompt: no project: %v", err)
+		return nil
+	}
+	status, err := box.PromptStatus(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		return json.NewEncoder(cmd.OutOrStdout()).Encode(status)
+	}
+	return tmpl.Execute(cmd.OutOrStdout(), status)
+}
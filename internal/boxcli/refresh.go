@@ -0,0 +1,73 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type refreshCmdFlags struct {
+	config configFlags
+	shell  string
+}
+
+func refreshCmd() *cobra.Command {
+	flags := refreshCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "Print shell commands that update the active environment after devbox.json changes",
+		Long: heredoc.Doc(`
+			Print the shell commands that update the environment of the current shell
+			after devbox.json or devbox.lock changed, for example after a git pull or
+			a devbox add in another terminal. It installs new packages, exports only
+			the variables that changed, and unsets the ones that were removed.
+
+			In a devbox shell, the refresh alias runs it. A devbox shell also notices
+			when devbox.json or devbox.lock changes, and refreshes itself before the
+			next prompt when DEVBOX_AUTO_REFRESH is set.
+		`),
+		Example: `  eval "$(devbox refresh)"`,
+		Args:    cobra.NoArgs,
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return refreshCmdFunc(cmd, flags)
+		},
+	}
+	cmd.Flags().StringVar(
+		&flags.shell, "shell", "", "shell to print the commands for: bash, zsh, sh, fish or nu. Defaults to the current shell")
+	flags.config.register(cmd)
+	return cmd
+}
+
+func refreshCmdFunc(cmd *cobra.Command, flags refreshCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:               flags.config.path,
+		Environment:       flags.config.environment,
+		Stderr:            cmd.ErrOrStderr(),
+		PreservePathStack: true,
+	})
+	if err != nil {
+		return err
+	}
+	exports, err := box.RefreshExports(cmd.Context(), flags.shell)
+	if err != nil {
+		return err
+	}
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		ux.Finfo(cmd.ErrOrStderr(), "Evaluate the output to update your shell, for example with eval \"$(devbox refresh)\".\n")
+	}
+	if exports != "" {
+		fmt.Fprintln(cmd.OutOrStdout(), exports)
+	}
+	return nil
+}
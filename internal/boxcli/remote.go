@@ -0,0 +1,81 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type remoteRunCmdFlags struct {
+	config configFlags
+	dir    string
+	noCopy bool
+}
+
+func remoteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remote",
+		Short: "Run the project's environment on another machine",
+	}
+	cmd.AddCommand(remoteRunCmd())
+	return cmd
+}
+
+func remoteRunCmd() *cobra.Command {
+	flags := remoteRunCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "run <host> -- <cmd> [args]",
+		Short: "Run a command in the locked environment on another machine over SSH",
+		Long: heredoc.Doc(`
+			Run a command on another machine, such as a shared build server, in the
+			same environment as devbox run. Devbox installs the packages locally,
+			syncs devbox.json, devbox.lock and local flakes to the host, and copies
+			the packages to the host's Nix store with nix copy. The host downloads
+			the packages that its binary caches have instead of receiving them.
+
+			The host is anything ssh accepts, such as user@server or a Host in
+			~/.ssh/config. It needs Nix and devbox in the PATH of SSH sessions. The
+			project is synced to ~/.cache/devbox/remote/ on the host, unless --dir
+			sets another directory.
+		`),
+		Example: heredoc.Doc(`
+			devbox remote run build-server -- make -j32
+		`),
+		Args:    cobra.MinimumNArgs(2),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.ArgsLenAtDash() != 1 {
+				return usererr.New("Separate the command from the host with --, as in: devbox remote run %s -- %s",
+					args[0], strings.Join(args[1:], " "))
+			}
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+			return box.RemoteRun(cmd.Context(), devopt.RemoteRunOpts{
+				Host:   args[0],
+				Dir:    flags.dir,
+				Cmd:    args[1:],
+				NoCopy: flags.noCopy,
+			})
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().StringVar(&flags.dir, "dir", "",
+		"directory on the host to sync the project to, relative to the remote user's home directory")
+	cmd.Flags().BoolVar(&flags.noCopy, "no-copy", false,
+		"don't copy the packages to the host, which then installs them from its binary caches")
+	return cmd
+}
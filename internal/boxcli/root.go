@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +19,11 @@ import (
 	"go.jetpack.io/devbox/internal/cloud/openssh/sshshim"
 	"go.jetpack.io/devbox/internal/cmdutil"
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/telemetry"
+	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/devbox/internal/vercheck"
 )
 
@@ -55,21 +60,41 @@ func RootCmd() *cobra.Command {
 	}
 
 	// Stable commands
+	command.AddCommand(activateCmd())
 	command.AddCommand(addCmd())
+	command.AddCommand(attestCmd())
+	command.AddCommand(auditCmd())
 	if featureflag.Auth.Enabled() {
 		command.AddCommand(authCmd())
 	}
 	command.AddCommand(cacheCmd())
 	command.AddCommand(createCmd())
+	command.AddCommand(daemonCmd())
+	command.AddCommand(deactivateCmd())
+	command.AddCommand(debugToolsCmd())
+	command.AddCommand(detectCmd())
+	command.AddCommand(devcontainerIntegrationCmd())
+	command.AddCommand(direnvIntegrationCmd())
 	command.AddCommand(secretsCmd())
+	command.AddCommand(envCmd())
+	command.AddCommand(doctorCmd())
+	command.AddCommand(gcCmd())
 	command.AddCommand(generateCmd())
 	command.AddCommand(globalCmd())
+	command.AddCommand(historyCmd())
+	command.AddCommand(importCmd())
 	command.AddCommand(infoCmd())
 	command.AddCommand(initCmd())
 	command.AddCommand(installCmd())
 	command.AddCommand(integrateCmd())
 	command.AddCommand(listCmd())
+	command.AddCommand(lockCmd())
 	command.AddCommand(logCmd())
+	command.AddCommand(nixCmd())
+	command.AddCommand(outdatedCmd())
+	command.AddCommand(promptCmd())
+	command.AddCommand(refreshCmd())
+	command.AddCommand(remoteCmd())
 	command.AddCommand(removeCmd())
 	command.AddCommand(runCmd())
 	command.AddCommand(searchCmd())
@@ -77,8 +102,12 @@ func RootCmd() *cobra.Command {
 	command.AddCommand(setupCmd())
 	command.AddCommand(shellCmd())
 	command.AddCommand(shellEnvCmd())
+	command.AddCommand(trustCmd())
 	command.AddCommand(updateCmd())
+	command.AddCommand(verifyCmd())
 	command.AddCommand(versionCmd())
+	command.AddCommand(vmCmd())
+	command.AddCommand(whichCmd())
 	// Preview commands
 	command.AddCommand(cloudCmd())
 	// Internal commands
@@ -97,12 +126,31 @@ func RootCmd() *cobra.Command {
 
 	command.PersistentFlags().BoolVarP(
 		&flags.quiet, "quiet", "q", false, "suppresses logs")
+	offline := command.PersistentFlags().VarPF(offlineFlag{}, "offline", "",
+		"resolve packages only from devbox.lock and don't contact the network (same as DEVBOX_OFFLINE=1)")
+	offline.NoOptDefVal = "true"
 	debugMiddleware.AttachToFlag(command.PersistentFlags(), "debug")
 	traceMiddleware.AttachToFlag(command.PersistentFlags(), "trace")
 
 	return command
 }
 
+// offlineFlag sets DEVBOX_OFFLINE as soon as cobra parses --offline, so that
+// it also applies to subcommands that override the root's PersistentPreRun.
+type offlineFlag struct{}
+
+func (offlineFlag) String() string   { return strconv.FormatBool(envir.IsOffline()) }
+func (offlineFlag) Type() string     { return "bool" }
+func (offlineFlag) IsBoolFlag() bool { return true }
+
+func (offlineFlag) Set(value string) error {
+	offline, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	return os.Setenv(envir.DevboxOffline, strconv.FormatBool(offline))
+}
+
 func Execute(ctx context.Context, args []string) int {
 	defer debug.Recover()
 	rootCmd := RootCmd()
@@ -116,6 +164,7 @@ func Execute(ctx context.Context, args []string) int {
 func Main() {
 	timer := debug.Timer(strings.Join(os.Args, " "))
 	setSystemBinaryPaths()
+	httpclient.SetDefault()
 	ctx := context.Background()
 	if strings.HasSuffix(os.Args[0], "ssh") ||
 		strings.HasSuffix(os.Args[0], "scp") {
@@ -133,6 +182,15 @@ func Main() {
 		return
 	}
 
+	// With a user-owned Nix store, devbox runs in a namespace that has the
+	// store at /nix, so the programs it installs can run.
+	if code, ran, err := nix.ReexecInUserStore(); err != nil {
+		ux.Ferror(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	} else if ran {
+		os.Exit(code)
+	}
+
 	code := Execute(ctx, os.Args[1:])
 	// Run out here instead of as a middleware so we can capture any time we spend
 	// in middlewares as well.
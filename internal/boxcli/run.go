@@ -23,6 +23,8 @@ type runCmdFlags struct {
 	envFlag
 	config      configFlags
 	pure        bool
+	sandbox     bool
+	inContainer bool
 	listScripts bool
 }
 
@@ -48,6 +50,14 @@ func runCmd() *cobra.Command {
 	flags.config.register(command)
 	command.Flags().BoolVar(
 		&flags.pure, "pure", false, "if this flag is specified, devbox runs the script in an isolated environment inheriting almost no variables from the current environment. A few variables, in particular HOME, USER and DISPLAY, are retained.")
+	command.Flags().BoolVar(
+		&flags.sandbox, "sandbox", false, "run the script in a sandbox that can only access the project directory, "+
+			"the project's packages and the network and paths declared in shell.sandbox in devbox.json. "+
+			"Uses bubblewrap on Linux and sandbox-exec on macOS.")
+	command.Flags().BoolVar(
+		&flags.inContainer, "in-container", false, "run the script in a Linux container with the project directory mounted, "+
+			"using the packages locked for Linux. Useful for Linux-only packages on macOS. Requires docker or podman.")
+	command.MarkFlagsMutuallyExclusive("in-container", "sandbox")
 	command.Flags().BoolVarP(
 		&flags.listScripts, "list", "l", false, "list all scripts defined in devbox.json")
 
@@ -104,12 +114,16 @@ func runScriptCmd(cmd *cobra.Command, args []string, flags runCmdFlags) error {
 		Environment: flags.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
 		Pure:        flags.pure,
+		Sandbox:     flags.sandbox,
 		Env:         env,
 	})
 	if err != nil {
 		return redact.Errorf("error reading devbox.json: %w", err)
 	}
 
+	if flags.inContainer {
+		return box.RunInContainer(cmd.Context(), script, scriptArgs)
+	}
 	if err := box.RunScript(cmd.Context(), script, scriptArgs); err != nil {
 		return redact.Errorf("error running script %q in Devbox: %w", script, err)
 	}
@@ -4,15 +4,25 @@
 package boxcli
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
+	"path"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/searcher"
 	"go.jetpack.io/devbox/internal/ux"
 )
@@ -20,7 +30,12 @@ import (
 const trimmedVersionsLength = 10
 
 type searchCmdFlags struct {
-	showAll bool
+	showAll  bool
+	licenses []string
+	versions bool
+	json     bool
+	provides string
+	category string
 }
 
 func searchCmd() *cobra.Command {
@@ -28,19 +43,52 @@ func searchCmd() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "search <pkg>",
 		Short: "Search for nix packages",
-		Args:  cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if flags.provides != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			if flags.category != "" {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.provides != "" {
+				return runSearchProvides(cmd, flags.provides, flags.json)
+			}
+			policy, err := licenseFilter(flags.licenses)
+			if err != nil {
+				return err
+			}
+			if flags.category != "" {
+				return runSearchCategory(cmd, flags.category, strings.Join(args, ""), policy, flags.showAll)
+			}
 			query := args[0]
+			alias, isAlias := expandSearchAlias(query)
+			if isAlias {
+				ux.Finfo(cmd.ErrOrStderr(), "%s is an alias for %s in devbox.json\n", query, alias)
+				query = alias
+			}
+			if flags.versions {
+				name, _, _ := searcher.ParseVersionedPackage(query)
+				return runSearchVersions(cmd, cmp.Or(name, query), policy, flags.json)
+			}
 			name, version, isVersioned := searcher.ParseVersionedPackage(query)
 			if !isVersioned {
-				results, err := searcher.Client().Search(query)
+				results, err := searcher.Client().Search(cmd.Context(), query)
 				if err != nil {
 					return err
 				}
+				if policy != nil {
+					results = filterByLicense(results, policy)
+				}
+				if isAlias {
+					promotePackage(results, query)
+				}
 				return printSearchResults(
 					cmd.OutOrStdout(), query, results, flags.showAll)
 			}
-			packageVersion, err := searcher.Client().Resolve(name, version)
+			packageVersion, err := searcher.Client().ResolveContext(cmd.Context(), name, version)
 			if err != nil {
 				// This is not ideal. Search service should return valid response we
 				// can parse
@@ -48,11 +96,23 @@ func searchCmd() *cobra.Command {
 			}
 			fmt.Fprintf(
 				cmd.OutOrStdout(),
-				"%s resolves to: %s@%s\n",
+				"%s resolves to: %s@%s%s%s\n",
 				query,
 				packageVersion.Name,
 				packageVersion.Version,
+				licenseString(packageVersion.License),
+				vulnerabilityString(packageVersion.Vulnerabilities),
 			)
+			for _, v := range packageVersion.Vulnerabilities {
+				fmt.Fprintf(cmd.OutOrStdout(), "  %s%s: %s\n", v.ID,
+					lo.Ternary(v.Severity != "", " ("+v.Severity+")", ""), v.Summary)
+			}
+			if policy != nil {
+				if reason := policy.Check(packageVersion.License); reason != "" {
+					ux.Fwarning(cmd.ErrOrStderr(), "%s@%s doesn't match --license: %s.\n",
+						packageVersion.Name, packageVersion.Version, reason)
+				}
+			}
 			return nil
 		},
 	}
@@ -61,10 +121,55 @@ func searchCmd() *cobra.Command {
 		&flags.showAll, "show-all", false,
 		"show all available templates",
 	)
+	command.Flags().BoolVar(
+		&flags.versions, "versions", false,
+		"list every version of the package that devbox can lock, with its systems",
+	)
+	command.Flags().BoolVar(
+		&flags.json, "json", false,
+		"output the versions as JSON, with --versions or --provides",
+	)
+	command.Flags().StringVar(
+		&flags.provides, "provides", "",
+		"find the packages that install a command, such as dig, instead of searching package names",
+	)
+	command.Flags().StringVar(
+		&flags.category, "category", "",
+		"list the packages in a category, such as databases. With a query, only list the "+
+			"packages in the category whose names contain it",
+	)
+	command.Flags().StringSliceVar(
+		&flags.licenses, "license", nil,
+		"only show package versions with one of these licenses, such as MIT,Apache-2.0. "+
+			"Licenses are SPDX identifiers and can end in * to match a family, such as BSD-*",
+	)
 
 	return command
 }
 
+// expandSearchAlias returns the package that query refers to if it's an
+// alias in the devbox.json of the current project.
+func expandSearchAlias(query string) (string, bool) {
+	box, err := devbox.Open(&devopt.Opts{Stderr: io.Discard})
+	if err != nil {
+		// Searching works outside of a project, which just doesn't
+		// have aliases.
+		return query, false
+	}
+	return box.Config().Root.ExpandAlias(query)
+}
+
+// promotePackage moves the package named name to the top of results, so that
+// the package an alias refers to is listed first.
+func promotePackage(results *searcher.SearchResults, name string) {
+	i := slices.IndexFunc(results.Packages, func(p searcher.Package) bool { return p.Name == name })
+	if i > 0 {
+		pkg := results.Packages[i]
+		copy(results.Packages[1:i+1], results.Packages[:i])
+		results.Packages[0] = pkg
+	}
+}
+
 func printSearchResults(
 	w io.Writer,
 	query string,
@@ -106,7 +211,14 @@ func printSearchResults(
 			ellipses := lo.Ternary(resultsAreTrimmed && pkg.NumVersions > trimmedVersionsLength, " ...", "")
 			versionString = fmt.Sprintf(" (%s%s)", strings.Join(nonEmptyVersions, ", "), ellipses)
 		}
-		fmt.Fprintf(w, "* %s %s\n", pkg.Name, versionString)
+		// The newest version's license and vulnerabilities stand for
+		// the package's.
+		license, vulns := "", ""
+		if len(pkg.Versions) > 0 {
+			license = licenseString(pkg.Versions[0].License)
+			vulns = vulnerabilityString(pkg.Versions[0].Vulnerabilities)
+		}
+		fmt.Fprintf(w, "* %s %s%s%s%s\n", pkg.Name, versionString, license, vulns, tagString(pkg.Tags))
 	}
 
 	if resultsAreTrimmed {
@@ -120,3 +232,169 @@ func printSearchResults(
 
 	return nil
 }
+
+// runSearchVersions lists the versions of the package with the given name
+// that the search service can resolve.
+func runSearchVersions(
+	cmd *cobra.Command,
+	name string,
+	policy *configfile.LicensePolicy,
+	jsonOut bool,
+) error {
+	versions, err := searcher.Client().Versions(cmd.Context(), name)
+	if errors.Is(err, searcher.ErrNotFound) {
+		return usererr.New("No versions of %q found. Run `devbox search %s` to search by name.", name, name)
+	}
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		versions = lo.Filter(versions, func(v searcher.ResolvableVersion, _ int) bool {
+			return policy.Check(v.License) == ""
+		})
+	}
+
+	if jsonOut {
+		out, err := json.MarshalIndent(versions, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	}
+	if len(versions) == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "No versions of %q match --license\n", name)
+		return nil
+	}
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tLAST UPDATED\tVULNERABILITIES\tSYSTEMS")
+	for _, v := range versions {
+		updated := "-"
+		if !v.LastUpdated.IsZero() {
+			updated = v.LastUpdated.Format(time.DateOnly)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", v.Version, updated, len(v.Vulnerabilities), strings.Join(v.Systems, ", "))
+	}
+	return tw.Flush()
+}
+
+// runSearchCategory lists the packages in category, with only the ones whose
+// name contains query if it's set. An unknown category lists the known ones.
+func runSearchCategory(
+	cmd *cobra.Command,
+	category, query string,
+	policy *configfile.LicensePolicy,
+	showAll bool,
+) error {
+	results, err := searcher.Client().Category(cmd.Context(), category)
+	if errors.Is(err, searcher.ErrNotFound) {
+		categories, listErr := searcher.Client().Categories(cmd.Context())
+		if listErr != nil || len(categories) == 0 {
+			return usererr.New("No category named %q found.", category)
+		}
+		names := lo.Map(categories, func(c searcher.Category, _ int) string { return c.Name })
+		return usererr.New("No category named %q found. Categories are: %s", category, strings.Join(names, ", "))
+	}
+	if err != nil {
+		return err
+	}
+	if query != "" {
+		results.Packages = lo.Filter(results.Packages, func(p searcher.Package, _ int) bool {
+			return strings.Contains(p.Name, query)
+		})
+		results.NumResults = len(results.Packages)
+	}
+	if policy != nil {
+		results = filterByLicense(results, policy)
+	}
+	return printSearchResults(cmd.OutOrStdout(), category, results, showAll)
+}
+
+// runSearchProvides lists the packages that install the given program.
+func runSearchProvides(cmd *cobra.Command, program string, jsonOut bool) error {
+	providers, err := searcher.Client().Provides(cmd.Context(), program)
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		out, err := json.MarshalIndent(providers, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	}
+
+	w := cmd.OutOrStdout()
+	if len(providers) == 0 {
+		fmt.Fprintf(w, "No packages found that provide %q\n", program)
+		return nil
+	}
+	fmt.Fprintf(w, "Packages that provide %q:\n\n", program)
+	for _, p := range providers {
+		mainProgram := lo.Ternary(p.MainProgram, " (main program)", "")
+		fmt.Fprintf(w, "* %s@%s%s\n", p.Name, p.Version, mainProgram)
+		if p.Summary != "" {
+			fmt.Fprintf(w, "  %s\n", p.Summary)
+		}
+	}
+	fmt.Fprintf(w, "\nRun `devbox add %s` to add the first one.\n", providers[0].Name)
+	return nil
+}
+
+// licenseFilter returns the license policy that --license filters search
+// results with, or nil if it isn't set.
+func licenseFilter(licenses []string) (*configfile.LicensePolicy, error) {
+	if len(licenses) == 0 {
+		return nil, nil
+	}
+	for _, license := range licenses {
+		if _, err := path.Match(license, ""); err != nil {
+			return nil, usererr.New("--license has an invalid pattern %q", license)
+		}
+	}
+	return &configfile.LicensePolicy{Allow: licenses}, nil
+}
+
+// filterByLicense returns the results with only the package versions whose
+// licenses match policy. A package with several licenses matches if any of
+// them does, and packages without a matching version are left out.
+func filterByLicense(results *searcher.SearchResults, policy *configfile.LicensePolicy) *searcher.SearchResults {
+	filtered := &searcher.SearchResults{}
+	for _, pkg := range results.Packages {
+		pkg.Versions = lo.Filter(pkg.Versions, func(v searcher.PackageVersion, _ int) bool {
+			return policy.Check(v.License) == ""
+		})
+		if len(pkg.Versions) == 0 {
+			continue
+		}
+		pkg.NumVersions = len(pkg.Versions)
+		filtered.Packages = append(filtered.Packages, pkg)
+	}
+	filtered.NumResults = len(filtered.Packages)
+	return filtered
+}
+
+func licenseString(licenses []string) string {
+	if len(licenses) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(licenses, ", ") + "]"
+}
+
+func vulnerabilityString(vulns []searcher.Vulnerability) string {
+	switch len(vulns) {
+	case 0:
+		return ""
+	case 1:
+		return " (1 known vulnerability)"
+	}
+	return fmt.Sprintf(" (%d known vulnerabilities)", len(vulns))
+}
+
+func tagString(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " #" + strings.Join(tags, " #")
+}
@@ -4,32 +4,75 @@
 package boxcli
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devbox/dotenv"
 	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/envsec/pkg/envsec"
 )
 
 type secretsFlags struct {
 	config configFlags
+	local  bool
+
+	// notedLocal is set once the user has been told that secrets are stored
+	// on this machine because the project doesn't use jetify cloud.
+	notedLocal bool
 }
 
-func (f *secretsFlags) envsec(cmd *cobra.Command) (*envsec.Envsec, error) {
+func (f *secretsFlags) box(cmd *cobra.Command) (*devbox.Devbox, error) {
 	box, err := devbox.Open(&devopt.Opts{
 		Dir:         f.config.path,
 		Environment: f.config.environment,
 		Stderr:      cmd.ErrOrStderr(),
 	})
+	return box, errors.WithStack(err)
+}
+
+func (f *secretsFlags) envsec(cmd *cobra.Command) (*envsec.Envsec, error) {
+	box, err := f.box(cmd)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
 
 	return box.Secrets(cmd.Context())
 }
 
+// useLocal returns true if secrets should be stored on this machine instead
+// of in jetify cloud: when --local is set, or the project doesn't use jetify
+// cloud. In the second case it tells the user which store is used.
+func (f *secretsFlags) useLocal(cmd *cobra.Command, box *devbox.Devbox) bool {
+	if f.local {
+		return true
+	}
+	if box.Config().IsEnvsecEnabled() {
+		return false
+	}
+	if !f.notedLocal {
+		ux.Finfo(
+			cmd.ErrOrStderr(),
+			"This project doesn't use jetify cloud, so secrets are stored on this machine. "+
+				"Run `devbox secrets init` to store them in jetify cloud, or pass --local "+
+				"to hide this message.\n",
+		)
+		f.notedLocal = true
+	}
+	return true
+}
+
 type secretsInitCmdFlags struct {
 	force bool
 }
@@ -50,10 +93,19 @@ type secretsUploadFlags struct {
 func secretsCmd() *cobra.Command {
 	flags := &secretsFlags{}
 	cmd := &cobra.Command{
-		Use:               "secrets",
-		Aliases:           []string{"envsec"},
-		Short:             "Interact with devbox secrets in jetify cloud.",
-		PersistentPreRunE: ensureNixInstalled,
+		Use:     "secrets",
+		Aliases: []string{"envsec"},
+		Short:   "Interact with devbox secrets in jetify cloud.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Local secrets are just a file, so they don't need nix.
+			localCmds := []string{"set", "remove", "list"}
+			if slices.Contains(localCmds, cmd.Name()) {
+				if box, err := flags.box(cmd); err == nil && flags.useLocal(cmd, box) {
+					return nil
+				}
+			}
+			return ensureNixInstalled(cmd, args)
+		},
 	}
 	cmd.AddCommand(secretsDownloadCmd(flags))
 	cmd.AddCommand(secretsInitCmd(flags))
@@ -90,16 +142,27 @@ func secretsInitCmd(secretsFlags *secretsFlags) *cobra.Command {
 }
 
 func secretsSetCmd(flags *secretsFlags) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "set <NAME1>=<value1> [<NAME2>=<value2>]...",
 		Short: "Securely store one or more environment variables",
-		Long:  "Securely store one or more environment variables. To test contents of a file as a secret use set=@<file>",
-		Args:  cobra.MinimumNArgs(1),
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return envsec.ValidateSetArgs(args)
-		},
+		Long: "Securely store one or more environment variables. To test contents of a file as a secret use set=@<file>\n\n" +
+			"With --local, or if the project doesn't use jetify cloud, values are stored for this project " +
+			"in your user state directory instead, and override every other source of env vars. " +
+			"Local secrets can also be set as `devbox secrets set <NAME> <value>`.",
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			secrets, err := flags.envsec(cmd)
+			box, err := flags.box(cmd)
+			if err != nil {
+				return err
+			}
+			if flags.useLocal(cmd, box) {
+				return secretsSetLocal(cmd, box, args)
+			}
+
+			if err := envsec.ValidateSetArgs(args); err != nil {
+				return err
+			}
+			secrets, err := box.Secrets(cmd.Context())
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -107,16 +170,55 @@ func secretsSetCmd(flags *secretsFlags) *cobra.Command {
 			return secrets.SetFromArgs(cmd.Context(), args)
 		},
 	}
+	cmd.Flags().BoolVar(&flags.local, "local", false, "store the secrets on this machine only")
+	return cmd
+}
+
+func secretsSetLocal(cmd *cobra.Command, box *devbox.Devbox, args []string) error {
+	secrets := map[string]string{}
+	if len(args) == 2 && !strings.Contains(args[0], "=") {
+		secrets[args[0]] = args[1]
+	} else {
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return usererr.New("Expected <NAME>=<value> or <NAME> <value>, got %q", arg)
+			}
+			secrets[name] = value
+		}
+	}
+	if err := box.SetLocalSecrets(secrets); err != nil {
+		return err
+	}
+	names := lo.Keys(secrets)
+	slices.Sort(names)
+	ux.Fsuccess(cmd.ErrOrStderr(), "Stored %s on this machine.\n", strings.Join(names, ", "))
+	return nil
 }
 
 func secretsRemoveCmd(flags *secretsFlags) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "remove <NAME1> [<NAME2>]...",
 		Short:   "Remove one or more environment variables",
 		Aliases: []string{"rm"},
 		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			secrets, err := flags.envsec(cmd)
+			box, err := flags.box(cmd)
+			if err != nil {
+				return err
+			}
+			if flags.useLocal(cmd, box) {
+				notFound, err := box.RemoveLocalSecrets(args...)
+				if err != nil {
+					return err
+				}
+				if len(notFound) > 0 {
+					ux.Fwarning(cmd.ErrOrStderr(), "No local secrets named %s.\n", strings.Join(notFound, ", "))
+				}
+				return nil
+			}
+
+			secrets, err := box.Secrets(cmd.Context())
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -124,6 +226,8 @@ func secretsRemoveCmd(flags *secretsFlags) *cobra.Command {
 			return secrets.DeleteAll(cmd.Context(), args...)
 		},
 	}
+	cmd.Flags().BoolVar(&flags.local, "local", false, "remove secrets stored on this machine")
+	return cmd
 }
 
 func secretsListCmd(commonFlags *secretsFlags) *cobra.Command {
@@ -134,7 +238,19 @@ func secretsListCmd(commonFlags *secretsFlags) *cobra.Command {
 		Short:   "List all secrets",
 		Args:    cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			secrets, err := commonFlags.envsec(cmd)
+			box, err := commonFlags.box(cmd)
+			if err != nil {
+				return err
+			}
+			if commonFlags.useLocal(cmd, box) {
+				local, err := box.LocalSecrets()
+				if err != nil {
+					return err
+				}
+				return printLocalSecrets(cmd.OutOrStdout(), local, flags.show, flags.format)
+			}
+
+			secrets, err := box.Secrets(cmd.Context())
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -163,9 +279,48 @@ func secretsListCmd(commonFlags *secretsFlags) *cobra.Command {
 		"table",
 		"Display the key values of each secret in the specified format, one of: table | dotenv | json.",
 	)
+	cmd.Flags().BoolVar(&commonFlags.local, "local", false, "list secrets stored on this machine")
 	return cmd
 }
 
+// printLocalSecrets prints secrets in format, which is the same as for
+// secrets in jetify cloud: table, dotenv or json. Values are masked unless
+// show is set.
+func printLocalSecrets(w io.Writer, secrets map[string]string, show bool, format string) error {
+	if !show {
+		secrets = lo.MapValues(secrets, func(string, string) string { return redact.SecretMask })
+	}
+	switch format {
+	case "table":
+		if len(secrets) == 0 {
+			fmt.Fprintln(w, "No local secrets are set for this project.")
+			return nil
+		}
+		names := lo.Keys(secrets)
+		slices.Sort(names)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, name := range names {
+			fmt.Fprintf(tw, "%s\t%s\n", name, secrets[name])
+		}
+		return tw.Flush()
+	case "dotenv":
+		data, err := dotenv.Format(secrets)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "json":
+		data, err := json.MarshalIndent(secrets, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	return usererr.New("unknown format %q, it must be one of: table | dotenv | json", format)
+}
+
 func secretsDownloadCmd(commonFlags *secretsFlags) *cobra.Command {
 	flags := secretsDownloadFlags{}
 	command := &cobra.Command{
@@ -25,7 +25,7 @@ func setupCmd() *cobra.Command {
 		Use:   "nix",
 		Short: "Install Nix",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInstallNixCmd(cmd)
+			return runInstallNixCmd(cmd, nix.InstallOpts{})
 		},
 	}
 
@@ -34,7 +34,7 @@ func setupCmd() *cobra.Command {
 	return setupCommand
 }
 
-func runInstallNixCmd(cmd *cobra.Command) error {
+func runInstallNixCmd(cmd *cobra.Command, opts nix.InstallOpts) error {
 	if nix.BinaryInstalled() {
 		ux.Finfo(
 			cmd.ErrOrStderr(),
@@ -43,7 +43,8 @@ func runInstallNixCmd(cmd *cobra.Command) error {
 		)
 		return nil
 	}
-	return nix.Install(cmd.ErrOrStderr(), nixDaemonFlagVal(cmd)())
+	opts.Daemon = nixDaemonFlagVal(cmd)()
+	return nix.InstallWith(cmd.ErrOrStderr(), opts)
 }
 
 // ensureNixInstalled verifies that nix is installed and that it is of a supported version
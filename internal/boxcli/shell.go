@@ -13,6 +13,7 @@ import (
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
 )
 
 type shellCmdFlags struct {
@@ -20,6 +21,8 @@ type shellCmdFlags struct {
 	config   configFlags
 	printEnv bool
 	pure     bool
+	record   string
+	stack    bool
 }
 
 func shellCmd() *cobra.Command {
@@ -42,6 +45,13 @@ func shellCmd() *cobra.Command {
 	command.Flags().BoolVar(
 		&flags.pure, "pure", false, "if this flag is specified, devbox creates an isolated shell inheriting almost no variables from the current environment. A few variables, in particular HOME, USER and DISPLAY, are retained.")
 
+	command.Flags().StringVar(
+		&flags.record, "record", "", "write a report of how the environment changes while the shell starts, including the hooks that run and how long they take, to this file")
+
+	command.Flags().BoolVar(
+		&flags.stack, "stack", false, "start the shell inside the current devbox shell, with this project's packages and env taking precedence over the current shell's")
+	command.MarkFlagsMutuallyExclusive("stack", "pure")
+
 	flags.config.register(command)
 	flags.envFlag.register(command)
 	return command
@@ -76,11 +86,16 @@ func runShellCmd(cmd *cobra.Command, flags shellCmdFlags) error {
 		return nil // return here to prevent opening a devbox shell
 	}
 
-	if envir.IsDevboxShellEnabled() {
-		return shellInceptionErrorMsg("devbox shell")
+	if envir.IsDevboxShellEnabled() && !flags.stack {
+		return usererr.New("You are already in an active devbox shell.\n" +
+			"Run `exit` before calling `devbox shell` again, or run `devbox shell --stack` " +
+			"to layer this project's environment over the current one.")
 	}
 
-	return box.Shell(cmd.Context())
+	if flags.record != "" {
+		ux.Finfo(cmd.ErrOrStderr(), "Recording the environment to %s\n", flags.record)
+	}
+	return box.Shell(cmd.Context(), devopt.ShellOpts{Record: flags.record})
 }
 
 func shellInceptionErrorMsg(cmdPath string) error {
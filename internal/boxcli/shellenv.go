@@ -5,8 +5,6 @@ package boxcli
 
 import (
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"go.jetpack.io/devbox/internal/devbox"
@@ -22,6 +20,7 @@ type shellEnvCmdFlags struct {
 	pure              bool
 	recomputeEnv      bool
 	runInitHook       bool
+	shell             string
 }
 
 func shellEnvCmd() *cobra.Command {
@@ -37,7 +36,7 @@ func shellEnvCmd() *cobra.Command {
 				return err
 			}
 			fmt.Fprintln(cmd.OutOrStdout(), s)
-			if !strings.HasSuffix(os.Getenv("SHELL"), "fish") {
+			if dialect, _ := devbox.ParseShellDialect(flags.shell); dialect == devbox.DialectPOSIX {
 				fmt.Fprintln(cmd.OutOrStdout(), "hash -r")
 			}
 			return nil
@@ -48,6 +47,10 @@ func shellEnvCmd() *cobra.Command {
 		&flags.runInitHook, "init-hook", false, "runs init hook after exporting shell environment")
 	command.Flags().BoolVar(
 		&flags.install, "install", false, "install packages before exporting shell environment")
+	command.Flags().StringVar(
+		&flags.shell, "shell", "",
+		"shell to print the commands for: bash, zsh, sh, fish, nu, or json for a JSON object of the variables. "+
+			"Defaults to the current shell")
 
 	command.Flags().BoolVar(
 		&flags.pure, "pure", false, "if this flag is specified, devbox creates an isolated environment inheriting almost no variables from the current environment. A few variables, in particular HOME, USER and DISPLAY, are retained.")
@@ -95,7 +98,7 @@ func shellEnvFunc(
 	}
 
 	if flags.install {
-		if err := box.Install(cmd.Context()); err != nil {
+		if err := box.Install(cmd.Context(), devopt.InstallOpts{}); err != nil {
 			return "", err
 		}
 	}
@@ -104,6 +107,7 @@ func shellEnvFunc(
 		DontRecomputeEnvironment: !flags.recomputeEnv,
 		NoRefreshAlias:           flags.noRefreshAlias,
 		RunHooks:                 flags.runInitHook,
+		Shell:                    flags.shell,
 	})
 	if err != nil {
 		return "", err
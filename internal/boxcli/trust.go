@@ -0,0 +1,78 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type trustCmdFlags struct {
+	config configFlags
+	show   bool
+	revoke bool
+}
+
+func trustCmd() *cobra.Command {
+	flags := trustCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Approve the hooks and scripts of a project",
+		Long: heredoc.Doc(`
+			Show the init hooks, scripts and plugin files of a project and approve
+			them to run.
+
+			Devbox asks for approval before it runs a project's code for the first
+			time, and again whenever that code changes. Approving with this command
+			is useful before running devbox non-interactively.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if flags.revoke {
+				if err := box.Untrust(); err != nil {
+					return err
+				}
+				ux.Fsuccess(cmd.ErrOrStderr(), "Removed approval of %s\n", box.ProjectDir())
+				return nil
+			}
+
+			code, err := box.ProjectCode()
+			if err != nil {
+				return err
+			}
+			if code == "" {
+				ux.Finfo(cmd.ErrOrStderr(), "This project doesn't have any hooks, scripts or plugin files to approve.\n")
+				return nil
+			}
+			fmt.Fprint(cmd.OutOrStdout(), code)
+			if flags.show {
+				return nil
+			}
+			if err := box.Trust(); err != nil {
+				return err
+			}
+			ux.Fsuccess(cmd.ErrOrStderr(), "Approved the code above for %s\n", box.ProjectDir())
+			return nil
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.show, "show", false, "show the project's code without approving it")
+	cmd.Flags().BoolVar(&flags.revoke, "revoke", false, "remove approval of the project")
+	return cmd
+}
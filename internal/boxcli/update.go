@@ -4,6 +4,9 @@
 package boxcli
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
@@ -17,6 +20,16 @@ type updateCmdFlags struct {
 	config      configFlags
 	sync        bool
 	allProjects bool
+	all         bool
+	dryRun      bool
+	json        bool
+}
+
+// updateReport is the --json output of devbox update for one project.
+type updateReport struct {
+	Project  string                 `json:"project"`
+	DryRun   bool                   `json:"dry_run"`
+	Packages []devbox.PackageUpdate `json:"packages"`
 }
 
 func updateCmd() *cobra.Command {
@@ -26,7 +39,8 @@ func updateCmd() *cobra.Command {
 		Use:   "update [pkg]...",
 		Short: "Update packages in your devbox",
 		Long: "Update one, many, or all packages in your devbox. " +
-			"If no packages are specified, all packages will be updated. " +
+			"If no packages are specified, only the packages that were added to devbox.json, or whose " +
+			"version changed, since devbox.lock was written are resolved, and --all resolves every package again. " +
 			"Legacy non-versioned packages will be converted to @latest versioned " +
 			"packages resolved to their current version.",
 		PreRunE: ensureNixInstalled,
@@ -49,6 +63,27 @@ func updateCmd() *cobra.Command {
 		false,
 		"update all projects in the working directory, recursively.",
 	)
+	command.Flags().BoolVar(
+		&flags.all,
+		"all",
+		false,
+		"resolve every package again, including the ones that devbox.lock already locks.",
+	)
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"resolve the packages and print how devbox.lock would change, without changing it.",
+	)
+	command.Flags().BoolVar(
+		&flags.json,
+		"json",
+		false,
+		"print how each package's lock entry changed, or would change with --dry-run, as JSON.",
+	)
+	command.MarkFlagsMutuallyExclusive("dry-run", "sync-lock")
+	command.MarkFlagsMutuallyExclusive("json", "sync-lock")
+	command.MarkFlagsMutuallyExclusive("all", "sync-lock")
 	return command
 }
 
@@ -56,9 +91,12 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	if len(args) > 0 && flags.sync {
 		return usererr.New("cannot specify both a package and --sync")
 	}
+	if len(args) > 0 && flags.all {
+		return usererr.New("cannot specify both a package and --all")
+	}
 
 	if flags.allProjects {
-		return updateAllProjects(cmd, args)
+		return updateAllProjects(cmd, args, flags)
 	}
 
 	if flags.sync {
@@ -74,25 +112,75 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 		return errors.WithStack(err)
 	}
 
-	return box.Update(cmd.Context(), devopt.UpdateOpts{
-		Pkgs: args,
-	})
+	report, err := runUpdate(cmd, box, devopt.UpdateOpts{Pkgs: args, All: flags.all}, flags)
+	if err != nil || !flags.json {
+		return err
+	}
+	return printJSON(cmd, report)
+}
+
+// runUpdate updates box, or prints its update plan with --dry-run. With
+// --json, the changes are returned instead of printed.
+func runUpdate(
+	cmd *cobra.Command, box *devbox.Devbox, opts devopt.UpdateOpts, flags *updateCmdFlags,
+) (*updateReport, error) {
+	report := &updateReport{Project: box.ProjectDir(), DryRun: flags.dryRun}
+	var err error
+	if flags.dryRun {
+		report.Packages, err = box.UpdatePlan(cmd.Context(), opts)
+		if err == nil && !flags.json {
+			devbox.PrintUpdatePlan(cmd.OutOrStdout(), report.Packages)
+		}
+	} else {
+		report.Packages, err = box.Update(cmd.Context(), opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Packages == nil {
+		report.Packages = []devbox.PackageUpdate{}
+	}
+	return report, nil
+}
+
+func printJSON(cmd *cobra.Command, v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	return nil
 }
 
-func updateAllProjects(cmd *cobra.Command, args []string) error {
+func updateAllProjects(cmd *cobra.Command, args []string, flags *updateCmdFlags) error {
 	boxes, err := multi.Open(&devopt.Opts{
 		Stderr: cmd.ErrOrStderr(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	for _, box := range boxes {
-		if err := box.Update(cmd.Context(), devopt.UpdateOpts{
-			Pkgs:                  args,
-			IgnoreMissingPackages: true,
-		}); err != nil {
+	reports := []*updateReport{}
+	for i, box := range boxes {
+		if flags.dryRun && !flags.json {
+			if i > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s:\n", box.ProjectDir())
+		}
+		opts := devopt.UpdateOpts{Pkgs: args, All: flags.all, IgnoreMissingPackages: true}
+		report, err := runUpdate(cmd, box, opts, flags)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	if !flags.dryRun {
+		if err := multi.SyncLockfiles(args); err != nil {
 			return err
 		}
 	}
-	return multi.SyncLockfiles(args)
+	if flags.json {
+		return printJSON(cmd, reports)
+	}
+	return nil
 }
@@ -6,6 +6,7 @@ package usererr
 import (
 	"errors"
 	"os/exec"
+	"syscall"
 )
 
 // ExitError is an ExitError for a command run on behalf of a user
@@ -13,6 +14,15 @@ type ExitError struct {
 	*exec.ExitError
 }
 
+// ExitCode returns the command's exit code, or 128 plus the number of the
+// signal that killed it, the same as a shell.
+func (e *ExitError) ExitCode() int {
+	if status, ok := e.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return e.ExitError.ExitCode()
+}
+
 func NewExecError(source error) error {
 	if source == nil {
 		return nil
@@ -0,0 +1,32 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package usererr
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestExitErrorExitCode(t *testing.T) {
+	tests := map[string]int{
+		"exit 3":        3,
+		"kill -TERM $$": 143,
+		"kill -KILL $$": 137,
+	}
+	for script, want := range tests {
+		t.Run(script, func(t *testing.T) {
+			err := NewExecError(exec.Command("sh", "-c", script).Run())
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("got error %v, want an ExitError", err)
+			}
+			if got := exitErr.ExitCode(); got != want {
+				t.Errorf("got exit code %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+type verifyCmdFlags struct {
+	config configFlags
+	json   bool
+	strict bool
+}
+
+func verifyCmd() *cobra.Command {
+	flags := verifyCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the Nix store against the hashes in devbox.lock",
+		Long: heredoc.Doc(`
+			Check that the store paths devbox.lock locks for this system are in the
+			Nix store, that their NAR hashes match the ones in devbox.lock, and that
+			their contents haven't been modified since Nix added them.
+
+			Devbox records the hash of each output the first time it installs it.
+			Commit devbox.lock afterwards and run devbox install and devbox verify in
+			CI to check that every machine gets the same packages. Use --strict to
+			also fail on outputs that don't have a hash yet.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerifyCmd(cmd, flags)
+		},
+	}
+	flags.config.register(cmd)
+	cmd.Flags().BoolVar(&flags.json, "json", false, "output the results as JSON")
+	cmd.Flags().BoolVar(&flags.strict, "strict", false,
+		"fail if an output doesn't have a hash in devbox.lock")
+	return cmd
+}
+
+func runVerifyCmd(cmd *cobra.Command, flags verifyCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.config.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	results, err := box.Verify(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		if results == nil {
+			results = []devbox.OutputVerification{}
+		}
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+	} else {
+		printVerification(cmd.OutOrStdout(), results, flags.strict)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Failed(flags.strict) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return usererr.New("%d of %d store paths don't match devbox.lock", failed, len(results))
+	}
+	return nil
+}
+
+func printVerification(w io.Writer, results []devbox.OutputVerification, strict bool) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "devbox.lock doesn't lock any store paths for this system.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tOUTPUT\tSTATUS\tSTORE PATH")
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Package, result.Output, result.Status, result.Path)
+	}
+	tw.Flush()
+
+	var issues []string
+	unhashed := 0
+	for _, result := range results {
+		switch result.Status {
+		case devbox.VerifyUnhashed:
+			unhashed++
+		case devbox.VerifyMismatch:
+			issues = append(issues, fmt.Sprintf("%s: devbox.lock has %s, but the store has %s",
+				result.Path, result.Locked, result.Actual))
+		case devbox.VerifyModified:
+			issues = append(issues, fmt.Sprintf("%s: its contents were modified after Nix added it to the store",
+				result.Path))
+		case devbox.VerifyMissing:
+			issues = append(issues, fmt.Sprintf("%s: isn't in the Nix store. Run `devbox install` first",
+				result.Path))
+		}
+	}
+	fmt.Fprintln(w)
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+	}
+	if unhashed > 0 {
+		fmt.Fprintf(w, "%d store paths don't have a hash in devbox.lock yet. "+
+			"Run `devbox install` to record them.\n", unhashed)
+	}
+	if len(issues) == 0 && (unhashed == 0 || !strict) {
+		ux.Fsuccess(w, "The store paths in the Nix store match devbox.lock.\n")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/vm"
+)
+
+type vmCmdFlags struct {
+	config configFlags
+}
+
+func vmCmd() *cobra.Command {
+	flags := &vmCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "vm",
+		Short: "Manage the Colima VM that runs the project's containers",
+		Long: heredoc.Doc(`
+			Manage a Colima VM for the project, as configured by the "vm" section of
+			devbox.json. Colima runs Docker or containerd in a Lima VM, which
+			replaces Docker Desktop on macOS. The devbox environment sets
+			DOCKER_HOST to the VM's Docker socket, so docker and docker compose use
+			the VM.
+
+			Add colima and a Docker client to the project first:
+
+			    devbox add colima docker
+		`),
+		PersistentPreRunE: ensureNixInstalled,
+	}
+	cmd.AddCommand(vmStartCmd(flags))
+	cmd.AddCommand(vmStopCmd(flags))
+	cmd.AddCommand(vmStatusCmd(flags))
+	cmd.AddCommand(vmDeleteCmd(flags))
+	cmd.AddCommand(vmSSHCmd(flags))
+	flags.config.registerPersistent(cmd)
+	return cmd
+}
+
+func (f *vmCmdFlags) colima(cmd *cobra.Command) (*vm.Colima, error) {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         f.config.path,
+		Environment: f.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return box.VM(cmd.Context())
+}
+
+func vmStartCmd(flags *vmCmdFlags) *cobra.Command {
+	foreground := false
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Create the VM if needed and start it",
+		Long: heredoc.Doc(`
+			Create the VM if needed and start it. Changes to the VM's CPUs, memory
+			or mounts take effect the next time it starts, so stop it first to
+			apply them.
+		`),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colima, err := flags.colima(cmd)
+			if err != nil {
+				return err
+			}
+			return colima.Start(cmd.Context(), foreground, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+	cmd.Flags().BoolVar(&foreground, "foreground", false, "keep running until the VM stops, for process managers")
+	return cmd
+}
+
+func vmStopCmd(flags *vmCmdFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the VM",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colima, err := flags.colima(cmd)
+			if err != nil {
+				return err
+			}
+			return colima.Stop(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+func vmDeleteCmd(flags *vmCmdFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the VM, including its containers and images",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colima, err := flags.colima(cmd)
+			if err != nil {
+				return err
+			}
+			return colima.Delete(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+func vmSSHCmd(flags *vmCmdFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ssh [-- <command>...]",
+		Short: "Open a shell in the VM, or run a command in it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colima, err := flags.colima(cmd)
+			if err != nil {
+				return err
+			}
+			return colima.SSH(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args...)
+		},
+	}
+}
+
+func vmStatusCmd(flags *vmCmdFlags) *cobra.Command {
+	jsonOut := false
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the VM is running and its resources",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			colima, err := flags.colima(cmd)
+			if err != nil {
+				return err
+			}
+			status, err := colima.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if jsonOut {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(status)
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			state := "stopped"
+			if status.Running {
+				state = "running"
+			}
+			fmt.Fprintf(w, "Profile:\t%s\n", status.Profile)
+			fmt.Fprintf(w, "State:\t%s\n", state)
+			if status.Running {
+				fmt.Fprintf(w, "Runtime:\t%s (%s)\n", status.Runtime, status.Arch)
+				fmt.Fprintf(w, "Resources:\t%d CPUs, %d GiB memory, %d GiB disk\n",
+					status.CPUs, status.Memory>>30, status.Disk>>30)
+			}
+			fmt.Fprintf(w, "DOCKER_HOST:\t%s\n", status.DockerHost)
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the status as JSON")
+	return cmd
+}
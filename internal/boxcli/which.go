@@ -0,0 +1,129 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type whichCmdFlags struct {
+	config  configFlags
+	explain bool
+	json    bool
+}
+
+func whichCmd() *cobra.Command {
+	flags := whichCmdFlags{}
+	cmd := &cobra.Command{
+		Use:   "which <command>",
+		Short: "Print where a command resolves from in the devbox environment",
+		Long: heredoc.Doc(`
+			Print the path that a command resolves to on the PATH of the devbox
+			environment.
+
+			With --explain, print every directory on the PATH in order, where each
+			one comes from (the devbox profile, the project's plugins, the Nix store
+			or the host), and which ones have the command, so that you can see what
+			shadows what. With a hermetic PATH (shell.path.hermetic in devbox.json),
+			it also lists the host directories that were removed.
+		`),
+		Example: "  devbox which --explain python",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return whichCmdFunc(cmd, args[0], flags)
+		},
+	}
+	cmd.Flags().BoolVar(&flags.explain, "explain", false, "print every directory on the PATH, where it comes from, and which ones have the command")
+	cmd.Flags().BoolVar(&flags.json, "json", false, "print the directories on the PATH as JSON")
+	flags.config.register(cmd)
+	return cmd
+}
+
+func whichCmdFunc(cmd *cobra.Command, command string, flags whichCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return err
+	}
+	lookups, err := box.Which(cmd.Context(), command)
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return errors.WithStack(enc.Encode(lookups))
+	}
+
+	var found *devbox.PathLookup
+	for i := range lookups {
+		if lookups[i].Path != "" && !lookups[i].Removed {
+			found = &lookups[i]
+			break
+		}
+	}
+	if flags.explain {
+		return printWhichExplanation(cmd.OutOrStdout(), command, found, lookups)
+	}
+	if found == nil {
+		return usererr.New("%s isn't on the PATH of the devbox environment.", command)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), found.Path)
+	return nil
+}
+
+func printWhichExplanation(w io.Writer, command string, found *devbox.PathLookup, lookups []devbox.PathLookup) error {
+	if found == nil {
+		fmt.Fprintf(w, "%s isn't on the PATH of the devbox environment.\n", command)
+	} else {
+		fmt.Fprintf(w, "%s resolves to %s (%s)\n", command, found.Path, found.Source)
+		if found.Target != "" {
+			fmt.Fprintf(w, "  which links to %s\n", found.Target)
+		}
+	}
+
+	fmt.Fprintf(w, "\nPATH of the devbox environment, in order:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	removed := false
+	for i, lookup := range lookups {
+		if lookup.Removed && !removed {
+			if err := tw.Flush(); err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprintf(w, "\nHost directories removed by the hermetic PATH:\n")
+			removed = true
+		}
+		status := ""
+		switch {
+		case lookup.Path == "":
+		case &lookups[i] == found:
+			status = command + " (used)"
+		case lookup.Removed:
+			status = command + " (removed)"
+		default:
+			status = command + " (shadowed)"
+		}
+		index := fmt.Sprintf("%d.", i+1)
+		if lookup.Removed {
+			index = "-"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", index, lookup.Dir, lookup.Source, status)
+	}
+	return errors.WithStack(tw.Flush())
+}
@@ -103,6 +103,27 @@ func TestJSONFile(t *testing.T) {
 	}
 }
 
+func TestJSONMatchesJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value.json")
+	err := os.WriteFile(path, []byte(`{ "key": "value" }`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileHash, err := JSONFile(path)
+	if err != nil {
+		t.Fatalf("got JSONFile error: %v", err)
+	}
+	// The hashes that are already saved in projects depend on both
+	// hashing the same bytes, without a trailing newline.
+	valueHash, err := JSON(map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf("got JSON error: %v", err)
+	}
+	if valueHash != fileHash {
+		t.Errorf("got JSON hash %q, want the JSONFile hash %q", valueHash, fileHash)
+	}
+}
+
 func TestJSONFileNotExist(t *testing.T) {
 	t.TempDir()
 	hash, err := JSONFile(t.TempDir() + "/notafile")
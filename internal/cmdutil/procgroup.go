@@ -0,0 +1,176 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package cmdutil
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// forwardedSignals are the signals that devbox passes on to the process
+// group of the command that it runs, instead of exiting.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// ProcessGroup is a command that runs in its own process group, so that
+// signals reach the command's children as well as the command.
+type ProcessGroup struct {
+	cmd     *exec.Cmd
+	signals chan os.Signal
+	done    chan struct{}
+
+	// group is false if the command stays in devbox's process group, which
+	// then only forwards the signals that the terminal doesn't send it.
+	group bool
+
+	// tty is the terminal that the group is the foreground of, or -1.
+	tty   int
+	state *term.State
+}
+
+// StartProcessGroup starts cmd in a new process group. The signals in
+// forwardedSignals that devbox gets while the group runs go to the whole
+// group.
+//
+// If cmd's stdin is the terminal that devbox runs in the foreground of, the
+// group becomes the terminal's foreground group, so that Ctrl-C and the
+// other keys that send signals reach it. When the group stops, as with
+// Ctrl-Z, devbox stops too, so that the shell that runs devbox gets the
+// terminal back. Only Linux can tell when the group stops, so elsewhere a
+// command in the terminal's foreground stays in devbox's process group.
+func StartProcessGroup(cmd *exec.Cmd) (*ProcessGroup, error) {
+	g := &ProcessGroup{
+		cmd:     cmd,
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+		group:   true,
+		tty:     foregroundTTY(cmd.Stdin),
+	}
+	if g.tty >= 0 && !detectsStops {
+		g.group = false
+		g.tty = -1
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = g.group
+	if g.tty >= 0 {
+		g.state, _ = term.GetState(g.tty)
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = g.tty
+		// Devbox changes the terminal's foreground group back from the
+		// background, which sends it SIGTTOU.
+		signal.Ignore(syscall.SIGTTOU)
+	}
+
+	signal.Notify(g.signals, forwardedSignals...)
+	if g.tty >= 0 {
+		signal.Notify(g.signals, syscall.SIGCHLD)
+	}
+	if err := cmd.Start(); err != nil {
+		g.stop()
+		return nil, err
+	}
+	go g.forward()
+	return g, nil
+}
+
+// RunProcessGroup runs cmd in a new process group and waits for it to exit.
+// See StartProcessGroup.
+func RunProcessGroup(cmd *exec.Cmd) error {
+	g, err := StartProcessGroup(cmd)
+	if err != nil {
+		return err
+	}
+	return g.Wait()
+}
+
+// Wait waits for the command to exit. If the group was the terminal's
+// foreground group, devbox's group becomes the foreground again, and the
+// terminal's state is restored in case the command changed it and exited
+// without restoring it, for example because it crashed.
+func (g *ProcessGroup) Wait() error {
+	err := g.cmd.Wait()
+	close(g.done)
+	g.stop()
+	return err
+}
+
+func (g *ProcessGroup) forward() {
+	pgid := g.cmd.Process.Pid
+	for {
+		select {
+		case <-g.done:
+			return
+		case sig := <-g.signals:
+			if sig == syscall.SIGCHLD {
+				if childStopped(pgid) {
+					g.suspend(pgid)
+				}
+				continue
+			}
+			if !g.group {
+				// The terminal sends SIGINT, SIGQUIT and SIGHUP to
+				// its whole foreground group, command included.
+				if sig == syscall.SIGTERM {
+					debug.Log("forwarding %s to process %d", sig, pgid)
+					_ = syscall.Kill(pgid, syscall.SIGTERM)
+				}
+				continue
+			}
+			debug.Log("forwarding %s to process group %d", sig, pgid)
+			_ = syscall.Kill(-pgid, sig.(syscall.Signal))
+		}
+	}
+}
+
+// suspend stops devbox after the group stopped, and resumes the group when
+// devbox continues.
+func (g *ProcessGroup) suspend(pgid int) {
+	g.restoreTerminal()
+	_ = syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	// The shell continued devbox, as with fg.
+	_ = unix.IoctlSetPointerInt(g.tty, unix.TIOCSPGRP, pgid)
+	_ = syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+func (g *ProcessGroup) stop() {
+	signal.Stop(g.signals)
+	if g.tty >= 0 {
+		g.restoreTerminal()
+		signal.Reset(syscall.SIGTTOU)
+	}
+}
+
+func (g *ProcessGroup) restoreTerminal() {
+	if err := unix.IoctlSetPointerInt(g.tty, unix.TIOCSPGRP, unix.Getpgrp()); err != nil {
+		debug.Log("failed to make devbox the terminal's foreground process group: %v", err)
+	}
+	if g.state != nil {
+		_ = term.Restore(g.tty, g.state)
+	}
+}
+
+// foregroundTTY returns the file descriptor of stdin if it's a terminal that
+// devbox's process group is the foreground of, or -1.
+func foregroundTTY(stdin any) int {
+	f, ok := stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return -1
+	}
+	fd := int(f.Fd())
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil || pgrp != unix.Getpgrp() {
+		return -1
+	}
+	return fd
+}
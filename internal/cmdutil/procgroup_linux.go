@@ -0,0 +1,20 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package cmdutil
+
+import "golang.org/x/sys/unix"
+
+// detectsStops is true because childStopped can tell when the group stops, so
+// that devbox can give the terminal back to the shell.
+const detectsStops = true
+
+// childStopped reports whether the child with pid stopped, without waiting
+// for it or reaping it if it exited.
+func childStopped(pid int) bool {
+	var info unix.Siginfo
+	if err := unix.Waitid(unix.P_PID, pid, &info, unix.WSTOPPED|unix.WNOHANG, nil); err != nil {
+		return false
+	}
+	return info.Signo == int32(unix.SIGCHLD)
+}
@@ -0,0 +1,16 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows && !linux
+
+package cmdutil
+
+// detectsStops is false because only Linux can check whether a child stopped
+// without reaping a child that exited. A command that runs in the terminal's
+// foreground therefore stays in devbox's process group, so that Ctrl-Z stops
+// devbox and the command together and the shell gets the terminal back.
+const detectsStops = false
+
+func childStopped(int) bool {
+	return false
+}
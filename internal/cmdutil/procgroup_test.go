@@ -0,0 +1,176 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package cmdutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// helperScriptEnvVar makes TestProcessGroupHelper run a script in a process
+// group, as devbox run does, from a terminal that the test controls.
+const helperScriptEnvVar = "DEVBOX_TEST_PROCESS_GROUP_SCRIPT"
+
+func TestProcessGroupHelper(t *testing.T) {
+	script := os.Getenv(helperScriptEnvVar)
+	if script == "" {
+		t.Skip("run by the process group tests")
+	}
+	before := sttyState()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := RunProcessGroup(cmd)
+
+	foreground := foregroundTTY(os.Stdin) >= 0
+	fmt.Printf("restored=%t foreground=%t\n", sttyState() == before, foreground)
+	code := 0
+	if exitErr := (&usererr.ExitError{}); errors.As(usererr.NewExecError(err), &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func sttyState() string {
+	cmd := exec.Command("stty", "-g")
+	cmd.Stdin = os.Stdin
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out))
+}
+
+// startInPTY runs the helper with script in a new terminal session, and
+// returns the terminal.
+func startInPTY(t *testing.T, script string) (*exec.Cmd, *os.File) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestProcessGroupHelper$")
+	cmd.Env = append(os.Environ(), helperScriptEnvVar+"="+script)
+	tty, err := pty.Start(cmd)
+	if err != nil {
+		t.Skipf("can't start a pty: %v", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return cmd, tty
+}
+
+// readPTY reads the terminal's output until the helper exits, and returns it
+// and the helper's exit code.
+func readPTY(t *testing.T, cmd *exec.Cmd, tty *os.File, output *ptyOutput) int {
+	t.Helper()
+	done := make(chan int)
+	go func() {
+		_ = cmd.Wait()
+		done <- cmd.ProcessState.ExitCode()
+	}()
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(20 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatalf("helper didn't exit, output:\n%s", output)
+	}
+	return -1
+}
+
+// ptyOutput is the output of the terminal so far.
+type ptyOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *ptyOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func copyPTY(tty *os.File) (*ptyOutput, chan struct{}) {
+	output := &ptyOutput{}
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 1024)
+		for {
+			n, err := tty.Read(buf)
+			output.mu.Lock()
+			output.buf.Write(buf[:n])
+			output.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return output, copied
+}
+
+func TestProcessGroupRestoresTerminalAfterCrash(t *testing.T) {
+	cmd, tty := startInPTY(t, "stty raw -echo; kill -KILL $$")
+	output, copied := copyPTY(tty)
+	code := readPTY(t, cmd, tty, output)
+	tty.Close()
+	<-copied
+
+	require.Equal(t, 128+int(syscall.SIGKILL), code, output.String())
+	require.Contains(t, output.String(), "restored=true foreground=true")
+}
+
+func TestProcessGroupInterruptFromTerminal(t *testing.T) {
+	cmd, tty := startInPTY(t, "echo ready; sleep 30")
+	output, copied := copyPTY(tty)
+	require.Eventually(t, func() bool {
+		return strings.Contains(output.String(), "ready")
+	}, 10*time.Second, 10*time.Millisecond)
+	// Ctrl-C reaches sleep as well as sh, because their group is the
+	// terminal's foreground group.
+	_, err := tty.Write([]byte{3})
+	require.NoError(t, err)
+	code := readPTY(t, cmd, tty, output)
+	tty.Close()
+	<-copied
+
+	require.Equal(t, 128+int(syscall.SIGINT), code, output.String())
+	require.Contains(t, output.String(), "restored=true foreground=true")
+}
+
+func TestProcessGroupForwardsSignals(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	// The grandchild, which sh doesn't pass signals on to, notes that it
+	// got SIGTERM.
+	script := fmt.Sprintf(`sh -c 'trap "echo terminated > %s; exit 0" TERM; echo started > %[1]s; while :; do sleep 0.1; done' & wait`, out)
+	cmd := exec.Command("sh", "-c", script)
+	group, err := StartProcessGroup(cmd)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(out)
+		return string(data) == "started\n"
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	err = usererr.NewExecError(group.Wait())
+	var exitErr *usererr.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 128+int(syscall.SIGTERM), exitErr.ExitCode())
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(out)
+		return string(data) == "terminated\n"
+	}, 10*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package cmdutil
+
+import (
+	"io"
+	"os/exec"
+)
+
+// ProcessGroup is a command that devbox runs. Windows has no process groups
+// to forward signals to, so it's the command alone.
+type ProcessGroup struct {
+	cmd *exec.Cmd
+}
+
+func StartProcessGroup(cmd *exec.Cmd) (*ProcessGroup, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &ProcessGroup{cmd: cmd}, nil
+}
+
+func RunProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+func (g *ProcessGroup) Wait() error {
+	return g.cmd.Wait()
+}
+
+// RunInPTY runs cmd with its output going to out. Windows has no pseudo-
+// terminals to run it in, so the command's output isn't a terminal.
+func RunInPTY(cmd *exec.Cmd, out io.Writer) error {
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package cmdutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// RunInPTY runs cmd in a terminal of its own and waits for it to exit. The
+// terminal's output goes to out instead of straight to devbox's terminal, so
+// that it can be filtered, for example to mask secrets, without the command
+// losing its terminal. cmd's stdin must be devbox's terminal, whose input
+// goes to the command's terminal.
+//
+// Devbox's terminal is in raw mode while the command runs, so Ctrl-C and the
+// other keys that send signals go to the command through its own terminal.
+// The signals in forwardedSignals that devbox gets go to the command's
+// process group, and the command's terminal is resized along with devbox's.
+func RunInPTY(cmd *exec.Cmd, out io.Writer) error {
+	stdin, ok := cmd.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(stdin.Fd())) {
+		return errors.New("stdin isn't a terminal")
+	}
+	size, err := pty.GetsizeFull(stdin)
+	if err != nil {
+		size = nil
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, nil, nil
+	tty, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, append([]os.Signal{syscall.SIGWINCH}, forwardedSignals...)...)
+	defer func() {
+		signal.Stop(signals)
+		close(done)
+	}()
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGWINCH {
+					_ = pty.InheritSize(stdin, tty)
+					continue
+				}
+				debug.Log("forwarding %s to process group %d", sig, cmd.Process.Pid)
+				_ = syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+			}
+		}
+	}()
+
+	if state, err := term.MakeRaw(int(stdin.Fd())); err == nil {
+		defer func() { _ = term.Restore(int(stdin.Fd()), state) }()
+	}
+	go func() { _, _ = io.Copy(tty, stdin) }()
+	// Reading fails once the command and everything it started have closed
+	// the terminal.
+	_, _ = io.Copy(out, tty)
+	return cmd.Wait()
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package cmdutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/stretchr/testify/require"
+)
+
+// helperPTYScriptEnvVar makes TestRunInPTYHelper run a script with
+// RunInPTY, from a terminal that the test controls.
+const helperPTYScriptEnvVar = "DEVBOX_TEST_PTY_SCRIPT"
+
+// maskWriter replaces "hunter2" in the output, like a secret.
+type maskWriter struct{ w io.Writer }
+
+func (m maskWriter) Write(p []byte) (int, error) {
+	_, err := m.w.Write(bytes.ReplaceAll(p, []byte("hunter2"), []byte("*******")))
+	return len(p), err
+}
+
+func TestRunInPTYHelper(t *testing.T) {
+	script := os.Getenv(helperPTYScriptEnvVar)
+	if script == "" {
+		t.Skip("run by TestRunInPTY")
+	}
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Stdin = os.Stdin
+	if err := RunInPTY(cmd, maskWriter{os.Stdout}); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// startPTYHelper runs TestRunInPTYHelper with script in a new terminal
+// session, and returns the terminal.
+func startPTYHelper(t *testing.T, script string) (*exec.Cmd, *os.File) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunInPTYHelper$")
+	cmd.Env = append(os.Environ(), helperPTYScriptEnvVar+"="+script)
+	tty, err := pty.Start(cmd)
+	if err != nil {
+		t.Skipf("can't start a pty: %v", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return cmd, tty
+}
+
+func TestRunInPTY(t *testing.T) {
+	cmd, tty := startPTYHelper(t, "test -t 1 && test -t 2 && echo terminal hunter2")
+	output, copied := copyPTY(tty)
+	code := readPTY(t, cmd, tty, output)
+	tty.Close()
+	<-copied
+
+	require.Equal(t, 0, code, output.String())
+	require.Contains(t, output.String(), "terminal *******")
+	require.NotContains(t, output.String(), "hunter2")
+}
+
+func TestRunInPTYInterruptFromTerminal(t *testing.T) {
+	cmd, tty := startPTYHelper(t, "echo ready; sleep 30")
+	output, copied := copyPTY(tty)
+	require.Eventually(t, func() bool {
+		return strings.Contains(output.String(), "ready")
+	}, 10*time.Second, 10*time.Millisecond)
+	// Devbox's terminal is raw, so Ctrl-C goes to the command's terminal,
+	// which interrupts it.
+	_, err := tty.Write([]byte{3})
+	require.NoError(t, err)
+	code := readPTY(t, cmd, tty, output)
+	tty.Close()
+	<-copied
+
+	require.Equal(t, 1, code, output.String())
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package credstore stores credentials and tokens in the operating system's
+// keychain: the macOS Keychain, the Secret Service (libsecret) on Linux or
+// the Windows Credential Manager. When no keychain is available, such as on
+// a headless Linux machine, credentials are kept in an encrypted file in the
+// user's state directory instead.
+package credstore
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// service is the name devbox's credentials are grouped under in the keychain.
+const service = "devbox"
+
+// ErrNotFound is returned by Get when no credential is stored for a key.
+var ErrNotFound = errors.New("credential not found")
+
+// Backends that can be selected with DEVBOX_CREDENTIAL_STORE.
+const (
+	BackendKeychain = "keychain"
+	BackendFile     = "file"
+)
+
+// store is implemented by each backend.
+type store interface {
+	get(key string) (string, error)
+	set(key, value string) error
+	delete(key string) error
+}
+
+var defaultStore = sync.OnceValues(func() (store, error) {
+	switch backend := os.Getenv(envir.DevboxCredentialStore); backend {
+	case BackendKeychain:
+		return keychain{}, nil
+	case BackendFile:
+		return newFileStore(), nil
+	case "":
+		if keychainAvailable() {
+			return keychain{}, nil
+		}
+		debug.Log("credstore: no keychain available, using encrypted file")
+		return newFileStore(), nil
+	default:
+		return nil, usererr.New(
+			"Unknown %s %q. Supported values are %q and %q.",
+			envir.DevboxCredentialStore, backend, BackendKeychain, BackendFile,
+		)
+	}
+})
+
+// Get returns the credential stored for key, or ErrNotFound.
+func Get(key string) (string, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return "", err
+	}
+	return s.get(key)
+}
+
+// Set stores a credential for key, replacing any existing one.
+func Set(key, value string) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.set(key, value)
+}
+
+// Delete removes the credential stored for key. It's not an error if there
+// is none.
+func Delete(key string) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.delete(key)
+}
+
+type keychain struct{}
+
+// keychainAvailable checks that the keychain can be reached by looking up a
+// key that doesn't exist. Reaching the Secret Service fails when there's no
+// D-Bus session, for example over SSH or in a container.
+func keychainAvailable() bool {
+	_, err := keyring.Get(service, "availability-check")
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		debug.Log("credstore: keychain unavailable: %v", err)
+		return false
+	}
+	return true
+}
+
+func (keychain) get(key string) (string, error) {
+	value, err := keyring.Get(service, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", redact.Errorf("read %s from keychain: %w", key, err)
+	}
+	return value, nil
+}
+
+func (keychain) set(key, value string) error {
+	if err := keyring.Set(service, key, value); err != nil {
+		return redact.Errorf("save %s to keychain: %w", key, err)
+	}
+	return nil
+}
+
+func (keychain) delete(key string) error {
+	err := keyring.Delete(service, key)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return redact.Errorf("delete %s from keychain: %w", key, err)
+	}
+	return nil
+}
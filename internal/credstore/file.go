@@ -0,0 +1,168 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package credstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// fileStore keeps credentials in a file encrypted with AES-256-GCM. The key
+// is stored separately in the user's config directory, so copying or backing
+// up the state directory alone doesn't expose the credentials.
+type fileStore struct {
+	mu      sync.Mutex
+	path    string
+	keyPath string
+}
+
+func newFileStore() *fileStore {
+	return &fileStore{
+		path:    xdg.StateSubpath(filepath.Join("devbox", "credentials.enc")),
+		keyPath: xdg.ConfigSubpath(filepath.Join("devbox", "credentials.key")),
+	}
+}
+
+func (f *fileStore) get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := creds[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (f *fileStore) set(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.read()
+	if err != nil {
+		return err
+	}
+	creds[key] = value
+	return f.write(creds)
+}
+
+func (f *fileStore) delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := creds[key]; !ok {
+		return nil
+	}
+	delete(creds, key)
+	return f.write(creds)
+}
+
+func (f *fileStore) read() (map[string]string, error) {
+	creds := map[string]string{}
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, redact.Errorf("read credentials file: %w", err)
+	}
+
+	aead, err := f.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, redact.Errorf("credentials file %s is corrupt", f.path)
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, redact.Errorf(
+			"decrypt credentials file %s (was %s changed?): %w", f.path, f.keyPath, err,
+		)
+	}
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, redact.Errorf("parse credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+func (f *fileStore) write(creds map[string]string) error {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return redact.Errorf("encode credentials: %w", err)
+	}
+	aead, err := f.cipher(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return redact.Errorf("generate nonce: %w", err)
+	}
+	return writeFileAtomic(f.path, aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+// cipher loads the encryption key, generating one first if create is set and
+// there isn't one yet.
+func (f *fileStore) cipher(create bool) (cipher.AEAD, error) {
+	key, err := os.ReadFile(f.keyPath)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, redact.Errorf("generate credentials key: %w", err)
+		}
+		err = writeFileAtomic(f.keyPath, key)
+	}
+	if err != nil {
+		return nil, redact.Errorf("read credentials key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, redact.Errorf("credentials key %s is invalid: %w", f.keyPath, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes data to a file that only the current user can read.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return redact.Errorf("create directory for %s: %w", filepath.Base(path), err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return redact.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return redact.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return redact.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return redact.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
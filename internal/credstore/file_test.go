@@ -0,0 +1,54 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package credstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	f := newFileStore()
+
+	_, err := f.get("token")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, f.delete("token"))
+
+	require.NoError(t, f.set("token", "hunter2"))
+	require.NoError(t, f.set("other", "value"))
+	got, err := f.get("token")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", got)
+
+	data, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "hunter2")
+	for _, path := range []string{f.path, f.keyPath} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm(), path)
+	}
+
+	require.NoError(t, f.delete("token"))
+	_, err = f.get("token")
+	require.ErrorIs(t, err, ErrNotFound)
+	got, err = f.get("other")
+	require.NoError(t, err)
+	require.Equal(t, "value", got)
+}
+
+func TestFileStoreWrongKey(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	f := newFileStore()
+	require.NoError(t, f.set("token", "hunter2"))
+
+	require.NoError(t, os.WriteFile(f.keyPath, make([]byte, 32), 0o600))
+	_, err := f.get("token")
+	require.ErrorContains(t, err, "decrypt credentials file")
+}
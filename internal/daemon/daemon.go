@@ -0,0 +1,357 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package daemon serves a devbox project's environment to editors over a unix
+// socket, so that extensions can query it without running devbox and
+// recomputing the environment every time.
+//
+// The daemon speaks JSON-RPC 2.0, one message per line. It has these methods:
+//
+//	devbox.info    the devbox version and project directory
+//	env.get        the environment, waiting for it to be computed if needed
+//	env.watch      sends env.changed notifications when the environment changes
+//	packages.list  the packages in devbox.json, with their locked versions
+//	services.list  the services in the project and its plugins
+//	scripts.list   the scripts in devbox.json
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/samber/lo"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// SocketPath returns the default socket path of a project's daemon.
+func SocketPath(projectDir string) string {
+	return filepath.Join(projectDir, ".devbox", "daemon.sock")
+}
+
+// debounce is how long the daemon waits after a file changes before
+// reloading, since editors and devbox write files in several steps.
+const debounce = 250 * time.Millisecond
+
+// Daemon serves one project.
+type Daemon struct {
+	open   func() (*devbox.Devbox, error)
+	server *Server
+
+	mu       sync.Mutex
+	box      *devbox.Devbox
+	env      *envState
+	revision int
+	// hashes are the hashes of the watched files when the environment was
+	// last computed.
+	hashes map[string]string
+	// dirty is set when a file changed while the environment was computed.
+	dirty bool
+}
+
+// envState is one computation of the environment.
+type envState struct {
+	revision int
+	done     chan struct{}
+	env      map[string]string
+	err      error
+}
+
+// New returns a daemon for the project that open opens. It's reopened every
+// time the project's config changes.
+func New(open func() (*devbox.Devbox, error)) *Daemon {
+	d := &Daemon{open: open, server: NewServer()}
+	d.server.Handle("devbox.info", d.info)
+	d.server.Handle("env.get", d.getEnv)
+	d.server.Handle("env.watch", d.watchEnv)
+	d.server.Handle("packages.list", d.listPackages)
+	d.server.Handle("services.list", d.listServices)
+	d.server.Handle("scripts.list", d.listScripts)
+	return d
+}
+
+// Listen creates the unix socket at path. It fails if another daemon is
+// already listening on it.
+func Listen(path string) (net.Listener, error) {
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return nil, usererr.New("A devbox daemon is already listening on %s", path)
+	}
+	// The socket is left behind if a daemon is killed.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to listen on %s. Try a shorter path with --socket.", path)
+	}
+	// Only the user may connect, since the environment can contain secrets.
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve loads the project and serves requests on l until ctx is done.
+func (d *Daemon) Serve(ctx context.Context, l net.Listener) error {
+	if err := d.reload(ctx); err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	d.watch(watcher)
+	go d.handleEvents(ctx, watcher)
+	return d.server.Serve(ctx, l)
+}
+
+// reload reopens the project and computes its environment in the background.
+func (d *Daemon) reload(ctx context.Context) error {
+	box, err := d.open()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.box = box
+	d.revision++
+	state := &envState{revision: d.revision, done: make(chan struct{})}
+	d.env = state
+	before := d.hashFiles(box)
+	go func() {
+		env, err := box.EnvVars(ctx)
+		state.env, state.err = envir.PairsToMap(env), err
+		close(state.done)
+		d.computed(ctx, box, before)
+	}()
+	return nil
+}
+
+// computed runs after the environment is computed. It decides whether the
+// files changed in a way that needs another reload.
+func (d *Daemon) computed(ctx context.Context, box *devbox.Devbox, before map[string]string) {
+	d.mu.Lock()
+	after := d.hashFiles(box)
+	d.hashes = after
+	dirty := d.dirty
+	d.dirty = false
+	revision := d.revision
+	d.mu.Unlock()
+
+	d.server.Broadcast("env.changed", map[string]int{"revision": revision}, (*Conn).isWatching)
+	if !dirty {
+		return
+	}
+
+	// Computing the environment can update the lockfile, which isn't a
+	// reason to compute it again. Other files changing means the user
+	// edited the project in the meantime.
+	lockfile := filepath.Join(box.ProjectDir(), "devbox.lock")
+	for path, hash := range after {
+		if path != lockfile && before[path] != hash {
+			if err := d.reload(ctx); err != nil {
+				debug.Log("daemon: reload: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func (d *Daemon) hashFiles(box *devbox.Devbox) map[string]string {
+	hashes := map[string]string{}
+	for _, path := range box.WatchFiles() {
+		hash, err := cachehash.File(path)
+		if err != nil {
+			debug.Log("daemon: hash %s: %v", path, err)
+		}
+		hashes[path] = hash
+	}
+	return hashes
+}
+
+// watch watches the directories of the files that change the environment.
+// It watches directories instead of files because editors often replace
+// files when saving them.
+func (d *Daemon) watch(watcher *fsnotify.Watcher) {
+	d.mu.Lock()
+	dirs := lo.Uniq(lo.Map(d.box.WatchFiles(), func(path string, _ int) string {
+		return filepath.Dir(path)
+	}))
+	d.mu.Unlock()
+	for _, dir := range dirs {
+		if slices.Contains(watcher.WatchList(), dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			debug.Log("daemon: watch %s: %v", dir, err)
+		}
+	}
+}
+
+func (d *Daemon) handleEvents(ctx context.Context, watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-watcher.Errors:
+			debug.Log("daemon: watch: %v", err)
+		case event := <-watcher.Events:
+			box, _ := d.current()
+			if !slices.Contains(box.WatchFiles(), event.Name) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(debounce, func() {
+				d.filesChanged(ctx)
+				// The config may include new files to watch.
+				d.watch(watcher)
+			})
+		}
+	}
+}
+
+func (d *Daemon) filesChanged(ctx context.Context) {
+	d.mu.Lock()
+	select {
+	case <-d.env.done:
+	default:
+		// Let computed decide once the current computation finishes.
+		d.dirty = true
+		d.mu.Unlock()
+		return
+	}
+	changed := !maps.Equal(d.hashes, d.hashFiles(d.box))
+	d.mu.Unlock()
+	if !changed {
+		return
+	}
+	if err := d.reload(ctx); err != nil {
+		debug.Log("daemon: reload: %v", err)
+	}
+}
+
+func (d *Daemon) current() (*devbox.Devbox, *envState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.box, d.env
+}
+
+type infoResult struct {
+	Version    string `json:"version"`
+	ProjectDir string `json:"project_dir"`
+}
+
+func (d *Daemon) info(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	box, _ := d.current()
+	return infoResult{Version: build.Version, ProjectDir: box.ProjectDir()}, nil
+}
+
+type envResult struct {
+	Revision int               `json:"revision"`
+	Env      map[string]string `json:"env"`
+}
+
+func (d *Daemon) getEnv(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	_, state := d.current()
+	select {
+	case <-state.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-conn.Done():
+		return nil, context.Canceled
+	}
+	if state.err != nil {
+		return nil, state.err
+	}
+	return envResult{Revision: state.revision, Env: state.env}, nil
+}
+
+func (d *Daemon) watchEnv(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	conn.setWatching()
+	_, state := d.current()
+	return map[string]int{"revision": state.revision}, nil
+}
+
+type packageResult struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Resolved string `json:"resolved,omitempty"`
+}
+
+func (d *Daemon) listPackages(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	box, _ := d.current()
+	result := []packageResult{}
+	for _, pkg := range box.TopLevelPackages() {
+		p := packageResult{Name: pkg.Raw}
+		if locked := box.Lockfile().Get(pkg.Raw); locked != nil {
+			p.Version, p.Resolved = locked.Version, locked.Resolved
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+type serviceResult struct {
+	Name               string `json:"name"`
+	ProcessComposePath string `json:"process_compose_path"`
+}
+
+func (d *Daemon) listServices(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	box, _ := d.current()
+	services, err := box.Services()
+	if err != nil {
+		return nil, err
+	}
+	names := lo.Keys(services)
+	slices.Sort(names)
+	result := make([]serviceResult, 0, len(names))
+	for _, name := range names {
+		result = append(result, serviceResult{Name: name, ProcessComposePath: services[name].ProcessComposePath})
+	}
+	return result, nil
+}
+
+type scriptResult struct {
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+	Comments string   `json:"comments,omitempty"`
+}
+
+func (d *Daemon) listScripts(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+	box, _ := d.current()
+	scripts := box.Config().Scripts()
+	names := lo.Keys(scripts)
+	slices.Sort(names)
+	result := make([]scriptResult, 0, len(names))
+	for _, name := range names {
+		result = append(result, scriptResult{
+			Name:     name,
+			Commands: scripts[name].Cmds,
+			Comments: scripts[name].Comments,
+		})
+	}
+	return result, nil
+}
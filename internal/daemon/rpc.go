@@ -0,0 +1,211 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is a JSON-RPC error. Handlers can return one to choose the code sent
+// to the client. Other errors are sent as internal errors.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Handler answers a request. params is nil if the request has none.
+type Handler func(ctx context.Context, conn *Conn, params json.RawMessage) (any, error)
+
+// Server serves JSON-RPC 2.0 over stream connections. Each message is a
+// single line of JSON, so clients can read responses with a line reader.
+type Server struct {
+	handlers map[string]Handler
+
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+}
+
+func NewServer() *Server {
+	return &Server{handlers: map[string]Handler{}, conns: map[*Conn]struct{}{}}
+}
+
+// Handle registers the handler for method. It must be called before Serve.
+func (s *Server) Handle(method string, h Handler) {
+	s.handlers[method] = h
+}
+
+// Serve accepts connections until ctx is done.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(ctx, c)
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, c net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	conn := &Conn{c: c, closed: ctx.Done()}
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		cancel()
+		c.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			conn.send(&message{ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: err.Error()}})
+			continue
+		}
+		go s.dispatch(ctx, conn, &req)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+		debug.Log("daemon: read: %v", err)
+	}
+}
+
+func (s *Server) dispatch(ctx context.Context, conn *Conn, req *message) {
+	reply := func(result any, err error) {
+		if req.ID == nil {
+			// Notifications don't get a response.
+			return
+		}
+		if result == nil {
+			// A successful response must have a result, even if it's null.
+			result = json.RawMessage("null")
+		}
+		resp := &message{ID: req.ID, Result: result}
+		if err != nil {
+			rpcErr := &Error{}
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+			resp.Result, resp.Error = nil, rpcErr
+		}
+		conn.send(resp)
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		reply(nil, &Error{Code: CodeInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+		return
+	}
+	h, ok := s.handlers[req.Method]
+	if !ok {
+		reply(nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + req.Method})
+		return
+	}
+	reply(h(ctx, conn, req.Params))
+}
+
+// Broadcast sends a notification to every connection for which include
+// returns true.
+func (s *Server) Broadcast(method string, params any, include func(*Conn) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		if include(conn) {
+			if err := conn.Notify(method, params); err != nil {
+				debug.Log("daemon: notify %s: %v", method, err)
+			}
+		}
+	}
+}
+
+// Conn is a client connection.
+type Conn struct {
+	c      net.Conn
+	closed <-chan struct{}
+
+	mu sync.Mutex
+	// watching is set by clients that asked to be told about changes.
+	watching bool
+}
+
+// Notify sends a notification, which is a request without an ID.
+func (c *Conn) Notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.send(&message{Method: method, Params: data})
+}
+
+// Done is closed when the client disconnects.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *Conn) setWatching() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watching = true
+}
+
+func (c *Conn) isWatching() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watching
+}
+
+func (c *Conn) send(m *message) error {
+	m.JSONRPC = "2.0"
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.c.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	s.Handle("echo", func(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+		return params, nil
+	})
+	s.Handle("watch", func(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+		conn.setWatching()
+		return nil, nil
+	})
+	s.Handle("fail", func(ctx context.Context, conn *Conn, params json.RawMessage) (any, error) {
+		return nil, errors.New("boom")
+	})
+
+	client, server := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.serveConn(ctx, server)
+	responses := bufio.NewScanner(client)
+	call := func(req string) string {
+		_, err := client.Write([]byte(req + "\n"))
+		require.NoError(t, err)
+		require.True(t, responses.Scan())
+		return responses.Text()
+	}
+
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"a":1}}`,
+		call(`{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":1}}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":"x","error":{"code":-32601,"message":"unknown method nope"}}`,
+		call(`{"jsonrpc":"2.0","id":"x","method":"nope"}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"error":{"code":-32603,"message":"boom"}}`,
+		call(`{"jsonrpc":"2.0","id":2,"method":"fail"}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid character 'n' looking for beginning of object key string"}}`,
+		call(`{not json}`))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":null}`,
+		call(`{"jsonrpc":"2.0","id":3,"method":"watch"}`))
+
+	go s.Broadcast("changed", map[string]int{"revision": 2}, (*Conn).isWatching)
+	require.True(t, responses.Scan())
+	require.JSONEq(t, `{"jsonrpc":"2.0","method":"changed","params":{"revision":2}}`, responses.Text())
+}
@@ -10,14 +10,19 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/redact"
 )
 
 const DevboxDebug = "DEVBOX_DEBUG"
 
-var enabled bool
+var (
+	enabled bool
+	secrets atomic.Pointer[redact.Secrets]
+)
 
 func init() {
 	enabled, _ = strconv.ParseBool(os.Getenv(DevboxDebug))
@@ -36,11 +41,21 @@ func SetOutput(w io.Writer) {
 	log.SetOutput(w)
 }
 
+// SetSecrets sets values that are masked in debug logs, such as env vars read
+// from a secrets manager.
+func SetSecrets(s redact.Secrets) {
+	secrets.Store(&s)
+}
+
 func Log(format string, v ...any) {
 	if !enabled {
 		return
 	}
-	_ = log.Output(2, fmt.Sprintf(format, v...))
+	msg := fmt.Sprintf(format, v...)
+	if s := secrets.Load(); s != nil {
+		msg = s.Mask(msg)
+	}
+	_ = log.Output(2, msg)
 }
 
 func Recover() {
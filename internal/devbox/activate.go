@@ -0,0 +1,192 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// activateStateEnvVar holds what devbox deactivate needs to restore the shell
+// that devbox activate changed: the value of each variable before.
+const activateStateEnvVar = "__DEVBOX_ACTIVATE_STATE"
+
+type activateState struct {
+	ProjectDir string `json:"project_dir"`
+	// Saved is the value of each variable that activate changed, or nil if
+	// it wasn't set.
+	Saved map[string]*string `json:"saved"`
+}
+
+// Activate returns the commands that apply the project's environment to the
+// shell that runs devbox, instead of starting a new one. The init hooks run
+// in sh, and the variables that they set or unset are applied along with
+// the rest. If another project is active, its changes are undone first.
+func (d *Devbox) Activate(ctx context.Context, shell string, runHooks bool) (string, error) {
+	dialect, err := activateDialect(shell)
+	if err != nil {
+		return "", err
+	}
+
+	// The environment is computed from the shell as it was before any
+	// activation, like a new devbox shell would be.
+	current := envir.PairsToMap(os.Environ())
+	before := maps.Clone(current)
+	delete(before, activateStateEnvVar)
+	state, err := currentActivateState()
+	if err != nil {
+		return "", err
+	}
+	if state != nil {
+		restoreActivateState(before, state)
+		for k := range state.Saved {
+			if v, ok := before[k]; ok {
+				os.Setenv(k, v)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+		os.Unsetenv(activateStateEnvVar)
+	}
+
+	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return "", err
+	}
+	var hookUnset []string
+	if runHooks {
+		var hookSet map[string]string
+		if hookSet, hookUnset, err = d.hookEnv(ctx, env); err != nil {
+			return "", err
+		}
+		maps.Copy(env, hookSet)
+		for _, k := range hookUnset {
+			delete(env, k)
+		}
+	}
+
+	state = &activateState{ProjectDir: d.projectDir, Saved: map[string]*string{}}
+	target := maps.Clone(before)
+	for k, v := range env {
+		if shellStateVars[k] {
+			continue
+		}
+		if old, ok := before[k]; !ok || old != v {
+			state.Saved[k] = savedValue(before, k)
+			target[k] = v
+		}
+	}
+	for _, k := range hookUnset {
+		state.Saved[k] = savedValue(before, k)
+		delete(target, k)
+	}
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	target[activateStateEnvVar] = base64.StdEncoding.EncodeToString(encoded)
+	return activateExports(dialect, current, target), nil
+}
+
+// Deactivate returns the commands that undo the changes of Activate.
+func Deactivate(shell string) (string, error) {
+	dialect, err := activateDialect(shell)
+	if err != nil {
+		return "", err
+	}
+	state, err := currentActivateState()
+	if err != nil {
+		return "", err
+	}
+	if state == nil {
+		return "", usererr.New(`No devbox environment is active in this shell. Activate one with eval "$(devbox activate)".`)
+	}
+
+	current := envir.PairsToMap(os.Environ())
+	target := maps.Clone(current)
+	delete(target, activateStateEnvVar)
+	restoreActivateState(target, state)
+	return activateExports(dialect, current, target), nil
+}
+
+// activateExports returns the commands that change the shell's environment
+// from current to target.
+func activateExports(dialect ShellDialect, current, target map[string]string) string {
+	set := map[string]string{}
+	for k, v := range target {
+		if old, ok := current[k]; !ok || old != v {
+			set[k] = v
+		}
+	}
+	var unset []string
+	for _, k := range sortedKeys(current) {
+		if _, ok := target[k]; !ok {
+			unset = append(unset, k)
+		}
+	}
+	var exports []string
+	if len(set) > 0 {
+		exports = append(exports, exportEnv(dialect, set))
+	}
+	if u := unsetEnv(dialect, unset); u != "" {
+		exports = append(exports, u)
+	}
+	return strings.Join(exports, "\n")
+}
+
+// activateDialect returns the dialect of shell, or an error if the shell
+// can't evaluate the output of devbox activate.
+func activateDialect(shell string) (ShellDialect, error) {
+	dialect, err := ParseShellDialect(shell)
+	if err != nil {
+		return "", err
+	}
+	if dialect == DialectJSON || dialect == DialectNushell {
+		return "", usererr.New("devbox activate supports bash, zsh, sh and fish. Use devbox shell instead.")
+	}
+	return dialect, nil
+}
+
+func currentActivateState() (*activateState, error) {
+	encoded := os.Getenv(activateStateEnvVar)
+	if encoded == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "%s is invalid. Unset it, or start a new shell.", activateStateEnvVar)
+	}
+	state := &activateState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, usererr.WithUserMessage(err, "%s is invalid. Unset it, or start a new shell.", activateStateEnvVar)
+	}
+	return state, nil
+}
+
+// restoreActivateState changes env back to how it was before activation.
+func restoreActivateState(env map[string]string, state *activateState) {
+	for k, v := range state.Saved {
+		if v != nil {
+			env[k] = *v
+		} else {
+			delete(env, k)
+		}
+	}
+}
+
+func savedValue(env map[string]string, k string) *string {
+	if v, ok := env[k]; ok {
+		return &v
+	}
+	return nil
+}
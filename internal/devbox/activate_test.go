@@ -0,0 +1,43 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivateExports(t *testing.T) {
+	current := map[string]string{"PATH": "/usr/bin", "KEPT": "1", "GONE": "1"}
+	target := map[string]string{"PATH": "/nix/bin:/usr/bin", "KEPT": "1", "NEW": "a b"}
+	require.Equal(t, "export NEW=\"a b\";\nexport PATH=\"/nix/bin:/usr/bin\";\nunset GONE;",
+		activateExports(DialectPOSIX, current, target))
+	require.Empty(t, activateExports(DialectPOSIX, current, current))
+}
+
+func TestDeactivate(t *testing.T) {
+	_, err := Deactivate("bash")
+	require.Error(t, err, "nothing is active")
+
+	old := "old value"
+	state, err := json.Marshal(activateState{
+		ProjectDir: "/project",
+		Saved:      map[string]*string{"CHANGED": &old, "ADDED": nil},
+	})
+	require.NoError(t, err)
+	t.Setenv(activateStateEnvVar, base64.StdEncoding.EncodeToString(state))
+	t.Setenv("CHANGED", "from devbox")
+	t.Setenv("ADDED", "1")
+
+	exports, err := Deactivate("bash")
+	require.NoError(t, err)
+	out, err := exec.Command("sh", "-c", exports+`
+echo "CHANGED=$CHANGED ADDED=${ADDED-unset} STATE=${`+activateStateEnvVar+`-unset}"`).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "CHANGED=old value ADDED=unset STATE=unset\n", string(out))
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/attest"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+const (
+	configSubject = "devbox.json"
+	lockSubject   = "devbox.lock"
+
+	// nixStoreHashDigest is the digest algorithm name used for the hash part
+	// of Nix store paths, which covers the package's inputs.
+	nixStoreHashDigest = "nixStoreHash"
+)
+
+// Attestation describes the project's environment as a provenance statement:
+// the digests of devbox.json and devbox.lock, the store paths they resolve
+// to and the version of devbox that produced them.
+func (d *Devbox) Attestation() (*attest.Statement, error) {
+	start := time.Now().UTC()
+	subjects, err := d.attestationSubjects()
+	if err != nil {
+		return nil, err
+	}
+	finish := time.Now().UTC()
+
+	return &attest.Statement{
+		Type:          attest.StatementType,
+		Subject:       subjects,
+		PredicateType: attest.PredicateType,
+		Predicate: attest.Provenance{
+			BuildDefinition: attest.BuildDefinition{
+				BuildType: attest.BuildType,
+				ExternalParameters: map[string]string{
+					"config":   configSubject,
+					"lockfile": lockSubject,
+				},
+				ResolvedDependencies: d.resolvedDependencies(),
+			},
+			RunDetails: attest.RunDetails{
+				Builder: attest.Builder{
+					ID: attest.BuilderID,
+					Version: map[string]string{
+						"devbox": build.Version,
+						"commit": build.Commit,
+					},
+				},
+				Metadata: attest.Metadata{StartedOn: &start, FinishedOn: &finish},
+			},
+		},
+	}, nil
+}
+
+func (d *Devbox) attestationSubjects() ([]attest.ResourceDescriptor, error) {
+	var subjects []attest.ResourceDescriptor
+	for _, name := range []string{configSubject, lockSubject} {
+		data, err := os.ReadFile(filepath.Join(d.projectDir, name))
+		if os.IsNotExist(err) && name == lockSubject {
+			return nil, usererr.New("%s doesn't exist. Run `devbox install` to create it.", lockSubject)
+		}
+		if err != nil {
+			return nil, usererr.WithUserMessage(err, "Unable to read %s", name)
+		}
+		sum := sha256.Sum256(data)
+		subjects = append(subjects, attest.ResourceDescriptor{
+			Name:   name,
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		})
+	}
+	return subjects, nil
+}
+
+// resolvedDependencies lists the store path of every output of every locked
+// package on every system, sorted so that attestations of the same lockfile
+// are identical.
+func (d *Devbox) resolvedDependencies() []attest.ResourceDescriptor {
+	var deps []attest.ResourceDescriptor
+	keys := lo.Keys(d.lockfile.Packages)
+	slices.Sort(keys)
+	for _, key := range keys {
+		pkg := d.lockfile.Packages[key]
+		systems := lo.Keys(pkg.Systems)
+		slices.Sort(systems)
+		if len(systems) == 0 {
+			deps = append(deps, attest.ResourceDescriptor{Name: key, URI: pkg.Resolved})
+			continue
+		}
+		for _, sys := range systems {
+			for _, out := range pkg.Systems[sys].Outputs {
+				deps = append(deps, attest.ResourceDescriptor{
+					Name:   out.Path,
+					URI:    pkg.Resolved,
+					Digest: map[string]string{nixStoreHashDigest: nix.NewStorePathParts(out.Path).Hash},
+					Annotations: map[string]string{
+						"package": key,
+						"system":  sys,
+						"output":  out.Name,
+					},
+				})
+			}
+		}
+	}
+	return deps
+}
+
+// VerifyAttestation compares an attestation with the project's current state
+// and returns the differences.
+func (d *Devbox) VerifyAttestation(stmt *attest.Statement) ([]string, error) {
+	current, err := d.Attestation()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, want := range stmt.Subject {
+		got, ok := lo.Find(current.Subject, func(s attest.ResourceDescriptor) bool {
+			return s.Name == want.Name
+		})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s isn't part of a devbox project", want.Name))
+			continue
+		}
+		if got.Digest["sha256"] != want.Digest["sha256"] {
+			problems = append(problems, fmt.Sprintf("%s has changed", want.Name))
+		}
+	}
+
+	key := func(r attest.ResourceDescriptor) string { return r.Name + " " + r.URI }
+	attested := lo.SliceToMap(stmt.Predicate.BuildDefinition.ResolvedDependencies,
+		func(r attest.ResourceDescriptor) (string, bool) { return key(r), true })
+	resolved := lo.SliceToMap(current.Predicate.BuildDefinition.ResolvedDependencies,
+		func(r attest.ResourceDescriptor) (string, bool) { return key(r), true })
+	for _, r := range current.Predicate.BuildDefinition.ResolvedDependencies {
+		if !attested[key(r)] {
+			problems = append(problems, fmt.Sprintf("%s is resolved but wasn't attested", r.Name))
+		}
+	}
+	for _, r := range stmt.Predicate.BuildDefinition.ResolvedDependencies {
+		if !resolved[key(r)] {
+			problems = append(problems, fmt.Sprintf("%s was attested but is no longer resolved", r.Name))
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/vuln"
+)
+
+// outputSuffixes are conventional output names that Nix appends to store
+// path names, which would otherwise be parsed as part of the version.
+var outputSuffixes = []string{
+	"bin", "dev", "doc", "info", "lib", "man", "out", "debug", "static", "devdoc",
+}
+
+// Audit checks the locked packages for known vulnerabilities. If closure is
+// set, the runtime dependencies of packages that are installed are checked
+// too.
+func (d *Devbox) Audit(ctx context.Context, sources []vuln.Source, closure bool) ([]vuln.Finding, error) {
+	defer debug.FunctionTimer().End()
+
+	pkgs := lockedPackages(d.lockfile)
+	if closure {
+		deps, err := closurePackages(ctx, pkgs)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, deps...)
+	}
+	return vuln.Scan(ctx, pkgs, sources)
+}
+
+// lockedPackages returns the versioned nixpkgs packages in the lockfile.
+func lockedPackages(lockfile *lock.File) []vuln.Package {
+	keys := lo.Keys(lockfile.Packages)
+	slices.Sort(keys)
+
+	var pkgs []vuln.Package
+	for _, key := range keys {
+		locked := lockfile.Packages[key]
+		if locked.Version == "" || pkgtype.IsRunX(key) || pkgtype.IsFlake(key) {
+			continue
+		}
+		name := key
+		if i := strings.LastIndex(key, "@"); i > 0 {
+			name = key[:i]
+		}
+		pkg := vuln.Package{Name: name, Version: locked.VersionFor(nix.System())}
+		if sys := locked.Systems[nix.System()]; sys != nil && len(sys.Outputs) > 0 {
+			pkg.StorePath = sys.DefaultOutputs()[0].Path
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// closurePackages returns the runtime dependencies of pkgs that are in the
+// Nix store, named and versioned by parsing their store paths.
+func closurePackages(ctx context.Context, pkgs []vuln.Package) ([]vuln.Package, error) {
+	paths := lo.FilterMap(pkgs, func(p vuln.Package, _ int) (string, bool) {
+		return p.StorePath, p.StorePath != ""
+	})
+	inStore, err := nix.StorePathsAreInStore(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, p := range pkgs {
+		seen[p.StorePath] = true
+	}
+	var deps []vuln.Package
+	for _, p := range pkgs {
+		if !inStore[p.StorePath] {
+			if p.StorePath != "" {
+				debug.Log("audit: %s isn't installed, skipping its dependencies", p.Name)
+			}
+			continue
+		}
+		closure, err := nix.StorePathClosure(ctx, p.StorePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range closure {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			parts := nix.NewStorePathParts(path)
+			version := trimOutputSuffix(parts.Version)
+			if version == "" {
+				// Not a package, such as a script or a config file.
+				continue
+			}
+			deps = append(deps, vuln.Package{
+				Name:         parts.Name,
+				Version:      version,
+				StorePath:    path,
+				DependencyOf: p.Name,
+			})
+		}
+	}
+	return deps, nil
+}
+
+func trimOutputSuffix(version string) string {
+	for _, suffix := range outputSuffixes {
+		if trimmed, ok := strings.CutSuffix(version, "-"+suffix); ok {
+			return trimmed
+		}
+	}
+	return version
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/vuln"
+)
+
+func TestLockedPackages(t *testing.T) {
+	lockfile := &lock.File{Packages: map[string]*lock.Package{
+		"python312Packages.requests@2.31.0": {Version: "2.31.0"},
+		"curl@latest": {
+			Version: "8.4.0",
+			Systems: map[string]*lock.SystemInfo{nix.System(): {Outputs: []lock.Output{
+				{Name: "bin", Path: "/nix/store/00000000000000000000000000000000-curl-8.4.0-bin", Default: true},
+				{Name: "dev", Path: "/nix/store/00000000000000000000000000000000-curl-8.4.0-dev"},
+			}}},
+		},
+		"github:NixOS/nixpkgs/nixpkgs-unstable#hello": {},
+		"runx:golangci/golangci-lint@latest":          {Version: "v1.55.2"},
+	}}
+	require.Equal(t, []vuln.Package{
+		{Name: "curl", Version: "8.4.0", StorePath: "/nix/store/00000000000000000000000000000000-curl-8.4.0-bin"},
+		{Name: "python312Packages.requests", Version: "2.31.0"},
+	}, lockedPackages(lockfile))
+}
+
+func TestTrimOutputSuffix(t *testing.T) {
+	require.Equal(t, "3.0.13", trimOutputSuffix("3.0.13-bin"))
+	require.Equal(t, "1.3", trimOutputSuffix("1.3"))
+	require.Equal(t, "2.38-44", trimOutputSuffix("2.38-44"))
+}
@@ -0,0 +1,290 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// bazelDir is the Bazel repository, and bzlmod module, that `devbox generate
+// bazel` writes the project's toolchains to. It's regenerated when the
+// lockfile changes.
+const bazelDir = ".devbox/gen/bazel"
+
+// BazelSnippets are the lines that a Bazel workspace adds to use the
+// toolchains in bazelDir.
+type BazelSnippets struct {
+	// Module is for the root MODULE.bazel of bzlmod workspaces.
+	Module string
+	// Workspace is for the WORKSPACE file of workspaces without bzlmod.
+	Workspace string
+}
+
+type bazelPlan struct {
+	Dir        string
+	Go         string
+	JDK        string
+	Python     string
+	Node       string
+	Compatible []string
+}
+
+// GenerateBazel installs the project's packages and writes Bazel toolchains
+// for the Go SDK, JDK, Python interpreter and Node.js in the profile, so that
+// Bazel builds use them instead of the host's. It returns the snippets that
+// register the toolchains in the Bazel workspace.
+func (d *Devbox) GenerateBazel(ctx context.Context) (*BazelSnippets, error) {
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return nil, err
+	}
+	plan := d.bazelPlan()
+	if plan.Go == "" && plan.JDK == "" && plan.Python == "" && plan.Node == "" {
+		return nil, usererr.New("The project has no Go, JDK, Python or Node.js packages to register as Bazel toolchains.")
+	}
+	if err := writeBazelFiles(filepath.Join(d.projectDir, bazelDir), plan); err != nil {
+		return nil, err
+	}
+	var module, workspace bytes.Buffer
+	if err := bazelTemplates.ExecuteTemplate(&module, "module_snippet", plan); err != nil {
+		return nil, redact.Errorf("execute module_snippet template: %w", err)
+	}
+	if err := bazelTemplates.ExecuteTemplate(&workspace, "workspace_snippet", plan); err != nil {
+		return nil, redact.Errorf("execute workspace_snippet template: %w", err)
+	}
+	return &BazelSnippets{Module: module.String(), Workspace: workspace.String()}, nil
+}
+
+// bazelPlan returns the toolchains to generate. Paths are resolved to the
+// store, unlike the toolchains the IDE integration uses, so that Bazel sees
+// a new path and rebuilds when a package changes.
+func (d *Devbox) bazelPlan() *bazelPlan {
+	plan := &bazelPlan{Dir: bazelDir, Compatible: bazelConstraints(nix.System())}
+	for _, tc := range d.Toolchains() {
+		home, err := filepath.EvalSymlinks(tc.Home)
+		if err != nil {
+			home = tc.Home
+		}
+		switch tc.Kind {
+		case "go":
+			// The Go SDK is only in the snippet for the user's
+			// WORKSPACE, which isn't regenerated, so it keeps the
+			// profile's path.
+			plan.Go = tc.Home
+		case "jdk":
+			plan.JDK = home
+		case "python":
+			plan.Python = home
+		case "node":
+			plan.Node = home
+		}
+	}
+	return plan
+}
+
+// bazelConstraints returns the constraints of the @platforms module that
+// match a Nix system, such as aarch64-darwin.
+func bazelConstraints(system string) []string {
+	arch, kernel, _ := strings.Cut(system, "-")
+	var constraints []string
+	switch kernel {
+	case "darwin":
+		constraints = append(constraints, "@platforms//os:macos")
+	case "linux":
+		constraints = append(constraints, "@platforms//os:linux")
+	}
+	switch arch {
+	case "aarch64":
+		constraints = append(constraints, "@platforms//cpu:arm64")
+	case "x86_64":
+		constraints = append(constraints, "@platforms//cpu:x86_64")
+	}
+	return constraints
+}
+
+// syncBazel regenerates the Bazel toolchains of projects that ran `devbox
+// generate bazel`, so that they follow the lockfile.
+func (d *Devbox) syncBazel() {
+	dir := filepath.Join(d.projectDir, bazelDir)
+	if !fileutil.IsDir(dir) {
+		return
+	}
+	if err := writeBazelFiles(dir, d.bazelPlan()); err != nil {
+		ux.Fwarning(d.stderr, "Unable to update the Bazel toolchains: %v\n", err)
+	}
+}
+
+func writeBazelFiles(dir string, plan *bazelPlan) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return redact.Errorf("create %s: %w", dir, err)
+	}
+	for name, tmpl := range map[string]string{
+		"MODULE.bazel": "module",
+		"WORKSPACE":    "workspace",
+		"BUILD.bazel":  "build",
+	} {
+		var buf bytes.Buffer
+		if err := bazelTemplates.ExecuteTemplate(&buf, tmpl, plan); err != nil {
+			return redact.Errorf("execute %s template: %w", tmpl, err)
+		}
+		path := filepath.Join(dir, name)
+		// Only write changed files, since Bazel refetches the repository
+		// when they change.
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, buf.Bytes()) {
+			continue
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return redact.Errorf("read %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return redact.Errorf("write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+var bazelTemplates = template.Must(template.New("bazel").Parse(`
+{{- define "header" -}}
+# Generated by ` + "`devbox generate bazel`" + `. Don't edit this file: devbox
+# regenerates it when devbox.lock changes.
+{{ end -}}
+
+{{- define "module" -}}
+{{ template "header" }}
+module(name = "devbox_toolchains")
+
+bazel_dep(name = "platforms", version = "0.0.10")
+{{- if .Python }}
+bazel_dep(name = "rules_python", version = "0.31.0")
+{{- end }}
+{{- if .Node }}
+bazel_dep(name = "rules_nodejs", version = "6.0.5")
+{{- end }}
+{{ end -}}
+
+{{- define "workspace" -}}
+{{ template "header" }}
+workspace(name = "devbox_toolchains")
+{{ end -}}
+
+{{- define "compatible" }}
+    exec_compatible_with = [
+{{- range .Compatible }}
+        "{{ . }}",
+{{- end }}
+    ],
+    target_compatible_with = [
+{{- range .Compatible }}
+        "{{ . }}",
+{{- end }}
+    ],
+{{- end -}}
+
+{{- define "build" -}}
+{{ template "header" }}
+{{- if .Python }}
+load("@rules_python//python:defs.bzl", "py_runtime", "py_runtime_pair")
+{{- end }}
+{{- if .Node }}
+load("@rules_nodejs//nodejs:toolchain.bzl", "nodejs_toolchain")
+{{- end }}
+
+package(default_visibility = ["//visibility:public"])
+{{- if .JDK }}
+
+java_runtime(
+    name = "jdk",
+    java_home = "{{ .JDK }}",
+)
+
+toolchain(
+    name = "jdk_toolchain",
+{{- template "compatible" . }}
+    toolchain = ":jdk",
+    toolchain_type = "@bazel_tools//tools/jdk:runtime_toolchain_type",
+)
+{{- end }}
+{{- if .Python }}
+
+py_runtime(
+    name = "python3",
+    interpreter_path = "{{ .Python }}",
+    python_version = "PY3",
+)
+
+py_runtime_pair(
+    name = "python_runtimes",
+    py3_runtime = ":python3",
+)
+
+toolchain(
+    name = "python_toolchain",
+{{- template "compatible" . }}
+    toolchain = ":python_runtimes",
+    toolchain_type = "@bazel_tools//tools/python:toolchain_type",
+)
+{{- end }}
+{{- if .Node }}
+
+nodejs_toolchain(
+    name = "node",
+    node_path = "{{ .Node }}",
+)
+
+toolchain(
+    name = "node_toolchain",
+{{- template "compatible" . }}
+    toolchain = ":node",
+    toolchain_type = "@rules_nodejs//nodejs:toolchain_type",
+)
+{{- end }}
+{{ end -}}
+
+{{- define "module_snippet" -}}
+bazel_dep(name = "devbox_toolchains")
+local_path_override(
+    module_name = "devbox_toolchains",
+    path = "{{ .Dir }}",
+)
+register_toolchains("@devbox_toolchains//:all")
+{{- if .Go }}
+
+# rules_go's bzlmod extension can't point at an SDK directory. The host SDK
+# is the go in PATH, so run bazel in ` + "`devbox shell`" + ` or with ` + "`devbox run`" + `.
+go_sdk = use_extension("@rules_go//go:extensions.bzl", "go_sdk")
+go_sdk.host()
+{{- end }}
+{{ end -}}
+
+{{- define "workspace_snippet" -}}
+local_repository(
+    name = "devbox_toolchains",
+    path = "{{ .Dir }}",
+)
+register_toolchains("@devbox_toolchains//:all")
+{{- if .Go }}
+
+load("@io_bazel_rules_go//go:deps.bzl", "go_local_sdk", "go_register_toolchains", "go_rules_dependencies")
+
+go_rules_dependencies()
+
+go_local_sdk(
+    name = "go_sdk",
+    path = "{{ .Go }}",
+)
+
+go_register_toolchains()
+{{- end }}
+{{ end -}}
+`))
@@ -0,0 +1,49 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBazelConstraints(t *testing.T) {
+	require.Equal(t, []string{"@platforms//os:macos", "@platforms//cpu:arm64"}, bazelConstraints("aarch64-darwin"))
+	require.Equal(t, []string{"@platforms//os:linux", "@platforms//cpu:x86_64"}, bazelConstraints("x86_64-linux"))
+}
+
+func TestWriteBazelFiles(t *testing.T) {
+	dir := t.TempDir()
+	plan := &bazelPlan{
+		Dir:        bazelDir,
+		JDK:        "/nix/store/abc-openjdk-21/lib/openjdk",
+		Python:     "/nix/store/def-python3-3.12.2/bin/python3.12",
+		Compatible: bazelConstraints("x86_64-linux"),
+	}
+	require.NoError(t, writeBazelFiles(dir, plan))
+
+	build, err := os.ReadFile(filepath.Join(dir, "BUILD.bazel"))
+	require.NoError(t, err)
+	require.Contains(t, string(build), `java_home = "/nix/store/abc-openjdk-21/lib/openjdk",`)
+	require.Contains(t, string(build), `interpreter_path = "/nix/store/def-python3-3.12.2/bin/python3.12",`)
+	require.Contains(t, string(build), `        "@platforms//os:linux",`)
+	require.NotContains(t, string(build), "nodejs_toolchain")
+
+	module, err := os.ReadFile(filepath.Join(dir, "MODULE.bazel"))
+	require.NoError(t, err)
+	require.Contains(t, string(module), `bazel_dep(name = "rules_python"`)
+	require.NotContains(t, string(module), "rules_nodejs")
+
+	// Unchanged files aren't rewritten, so that Bazel doesn't refetch them.
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "BUILD.bazel"), old, old))
+	require.NoError(t, writeBazelFiles(dir, plan))
+	info, err := os.Stat(filepath.Join(dir, "BUILD.bazel"))
+	require.NoError(t, err)
+	require.True(t, info.ModTime().Equal(old))
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"cmp"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// PushToCache signs the store paths of the project's packages and copies
+// them to opts.To, or to push_cache in devbox.json. Only the paths that are
+// in the local Nix store are pushed, which are the ones that devbox install
+// downloaded or built.
+func (d *Devbox) PushToCache(ctx context.Context, opts devopt.CachePushOpts) error {
+	defer debug.FunctionTimer().End()
+	cache := d.pushCache(opts)
+	if cache.URI == "" {
+		return usererr.New(
+			"Devbox doesn't know which cache to push to. Set push_cache in devbox.json, or use --to.")
+	}
+	packages := lo.Filter(d.InstallablePackages(), devpkg.IsNix)
+	return d.pushPackages(ctx, cache, packages, opts.BuiltOnly)
+}
+
+// pushCache is push_cache in devbox.json with the options of opts in place
+// of its own.
+func (d *Devbox) pushCache(opts devopt.CachePushOpts) configfile.PushCache {
+	cache := configfile.PushCache{}
+	if d.cfg.Root.PushCache != nil {
+		cache = *d.cfg.Root.PushCache
+	}
+	if cache.SigningKey != "" {
+		cache.SigningKey = os.ExpandEnv(cache.SigningKey)
+		if !filepath.IsAbs(cache.SigningKey) {
+			cache.SigningKey = filepath.Join(d.projectDir, cache.SigningKey)
+		}
+	}
+	cache.URI = cmp.Or(opts.To, cache.URI)
+	cache.SigningKey = cmp.Or(opts.SigningKey, cache.SigningKey)
+	return cache
+}
+
+// pushPackages copies the store paths of packages that are in the local
+// store to cache. If builtOnly is set, it leaves out the paths that were
+// downloaded from a binary cache, which the cache doesn't need.
+func (d *Devbox) pushPackages(
+	ctx context.Context,
+	cache configfile.PushCache,
+	packages []*devpkg.Package,
+	builtOnly bool,
+) error {
+	var paths []string
+	for _, pkg := range packages {
+		storePaths, err := pkg.GetStorePaths(ctx, d.stderr)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, storePaths...)
+	}
+	paths = lo.Uniq(paths)
+	infos, err := nix.PathInfos(ctx, paths...)
+	if err != nil {
+		return err
+	}
+	if missing := len(paths) - len(infos); missing > 0 && !builtOnly {
+		ux.Fwarning(d.stderr,
+			"%d store path(s) of the project's packages aren't in the Nix store, so they won't be "+
+				"pushed. Run `devbox install` to download or build them.\n", missing)
+	}
+
+	var push []string
+	for _, info := range infos {
+		if !builtOnly || info.Ultimate {
+			push = append(push, info.Path)
+		}
+	}
+	if len(push) == 0 {
+		if !builtOnly {
+			ux.Finfo(d.stderr, "There are no store paths to push to %s\n", cache.URI)
+		}
+		return nil
+	}
+	slices.Sort(push)
+
+	to, err := signingStore(ctx, cache, push)
+	if err != nil {
+		return err
+	}
+	ux.Finfo(d.stderr, "Pushing %d store path(s) and their dependencies to %s\n", len(push), cache.URI)
+	if err := nix.CopyPathsToCache(ctx, d.stderr, to, nil, push...); err != nil {
+		return usererr.WithUserMessage(err, "Devbox couldn't push the store paths to %s.", cache.URI)
+	}
+	ux.Fsuccess(d.stderr, "Pushed %d store path(s) to %s\n", len(push), cache.URI)
+	return nil
+}
+
+// signsOnCopy are the schemes of the binary cache stores, which sign paths
+// as nix copy uploads them if the store URI has a secret-key.
+var signsOnCopy = []string{"file", "http", "https", "s3"}
+
+// signingStore returns the store URI to copy paths to, after making sure
+// that they'll be signed with the cache's signing key. Binary caches sign
+// the paths as they're uploaded, which doesn't need the local store to
+// trust the user. Other stores get paths that are signed in the local store
+// first.
+func signingStore(ctx context.Context, cache configfile.PushCache, paths []string) (string, error) {
+	if cache.SigningKey == "" {
+		return cache.URI, nil
+	}
+	if _, err := os.Stat(cache.SigningKey); err != nil {
+		return "", usererr.WithUserMessage(err,
+			"Devbox can't read the signing key %s. Create one with `nix key generate-secret`.",
+			cache.SigningKey)
+	}
+	u, err := url.Parse(cache.URI)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !slices.Contains(signsOnCopy, u.Scheme) {
+		return cache.URI, nix.SignPaths(ctx, cache.SigningKey, paths...)
+	}
+	query := u.Query()
+	query.Set("secret-key", cache.SigningKey)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// autoPush pushes the packages that were built from source to push_cache,
+// if it has auto_push. Failing to push only warns, since the packages are
+// installed.
+func (d *Devbox) autoPush(ctx context.Context, packages []*devpkg.Package) {
+	if d.cfg.Root.PushCache == nil || !d.cfg.Root.PushCache.AutoPush {
+		return
+	}
+	cache := d.pushCache(devopt.CachePushOpts{})
+	if err := d.pushPackages(ctx, cache, packages, true); err != nil {
+		ux.Fwarning(d.stderr, "Devbox couldn't push the packages it built to %s: %v\n", cache.URI, err)
+	}
+}
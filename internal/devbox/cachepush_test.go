@@ -0,0 +1,58 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestPushCache(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{
+		"push_cache": {
+			"uri": "s3://acme-nix-cache?region=us-west-2",
+			"signing_key": "keys/${CACHE_KEY_NAME}.sec",
+			"auto_push": true
+		}
+	}`), 0o644)
+	require.NoError(t, err)
+	t.Setenv("CACHE_KEY_NAME", "acme")
+	d, err := Open(&devopt.Opts{Dir: dir, Stderr: os.Stderr})
+	require.NoError(t, err)
+
+	cache := d.pushCache(devopt.CachePushOpts{})
+	require.Equal(t, "s3://acme-nix-cache?region=us-west-2", cache.URI)
+	require.Equal(t, filepath.Join(dir, "keys", "acme.sec"), cache.SigningKey)
+	require.True(t, cache.AutoPush)
+
+	cache = d.pushCache(devopt.CachePushOpts{To: "ssh-ng://builder", SigningKey: "/etc/nix/key.sec"})
+	require.Equal(t, "ssh-ng://builder", cache.URI)
+	require.Equal(t, "/etc/nix/key.sec", cache.SigningKey)
+}
+
+func TestSigningStore(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "acme.sec")
+	ctx := context.Background()
+
+	to, err := signingStore(ctx, configfile.PushCache{URI: "s3://acme-nix-cache"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "s3://acme-nix-cache", to, "paths aren't signed without a key")
+
+	_, err = signingStore(ctx, configfile.PushCache{URI: "s3://acme-nix-cache", SigningKey: key}, nil)
+	require.Error(t, err, "the key doesn't exist")
+
+	require.NoError(t, os.WriteFile(key, []byte("acme-1:secret"), 0o600))
+	to, err = signingStore(ctx, configfile.PushCache{URI: "s3://acme-nix-cache?region=us-west-2", SigningKey: key}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "s3://acme-nix-cache?region=us-west-2&secret-key="+url.QueryEscape(key), to)
+}
@@ -0,0 +1,394 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// Confidence levels of how a conda package was mapped.
+const (
+	// ConfidenceHigh means devbox knows the equivalent package.
+	ConfidenceHigh = "high"
+	// ConfidenceMedium means the package is equivalent, but its version
+	// differs from the one in environment.yml.
+	ConfidenceMedium = "medium"
+	// ConfidenceLow means the package was mapped by its name alone.
+	ConfidenceLow = "low"
+)
+
+// condaNixPackages maps conda packages that aren't Python libraries to
+// devbox packages.
+var condaNixPackages = map[string]string{
+	"boost":       "boost",
+	"clang":       "clang",
+	"cmake":       "cmake",
+	"cudatoolkit": "cudatoolkit",
+	"curl":        "curl",
+	"eigen":       "eigen",
+	"ffmpeg":      "ffmpeg",
+	"fftw":        "fftw",
+	"gcc":         "gcc",
+	"gdal":        "gdal",
+	"geos":        "geos",
+	"git":         "git",
+	"go":          "go",
+	"graphviz":    "graphviz",
+	"gxx":         "gcc",
+	"hdf5":        "hdf5",
+	"imagemagick": "imagemagick",
+	"jq":          "jq",
+	"julia":       "julia",
+	"libnetcdf":   "netcdf",
+	"make":        "gnumake",
+	"ninja":       "ninja",
+	"nodejs":      "nodejs",
+	"octave":      "octave",
+	"openblas":    "openblas",
+	"openjdk":     "jdk",
+	"pandoc":      "pandoc",
+	"pkg-config":  "pkg-config",
+	"postgresql":  "postgresql",
+	"proj":        "proj",
+	"r-base":      "R",
+	"redis":       "redis",
+	"rust":        "rustc",
+	"sqlite":      "sqlite",
+	"tesseract":   "tesseract",
+	"wget":        "wget",
+}
+
+// condaPipPackages maps conda Python libraries to their PyPI names. Python
+// libraries are installed with pip in the python plugin's virtual
+// environment, since it doesn't see Python libraries from nixpkgs.
+var condaPipPackages = map[string]string{
+	"beautifulsoup4":  "beautifulsoup4",
+	"black":           "black",
+	"bokeh":           "bokeh",
+	"cython":          "cython",
+	"dask":            "dask",
+	"fastapi":         "fastapi",
+	"flask":           "flask",
+	"geopandas":       "geopandas",
+	"h5py":            "h5py",
+	"ipykernel":       "ipykernel",
+	"ipython":         "ipython",
+	"jupyter":         "jupyter",
+	"jupyterlab":      "jupyterlab",
+	"keras":           "keras",
+	"lxml":            "lxml",
+	"matplotlib":      "matplotlib",
+	"matplotlib-base": "matplotlib",
+	"msgpack-python":  "msgpack",
+	"mypy":            "mypy",
+	"netcdf4":         "netCDF4",
+	"networkx":        "networkx",
+	"notebook":        "notebook",
+	"numba":           "numba",
+	"numpy":           "numpy",
+	"opencv":          "opencv-python",
+	"pandas":          "pandas",
+	"pillow":          "pillow",
+	"plotly":          "plotly",
+	"polars":          "polars",
+	"py-opencv":       "opencv-python",
+	"pyarrow":         "pyarrow",
+	"pybind11":        "pybind11",
+	"pyproj":          "pyproj",
+	"pytables":        "tables",
+	"pytest":          "pytest",
+	"python-dateutil": "python-dateutil",
+	"pytorch":         "torch",
+	"pytz":            "pytz",
+	"pyyaml":          "pyyaml",
+	"requests":        "requests",
+	"scikit-image":    "scikit-image",
+	"scikit-learn":    "scikit-learn",
+	"scipy":           "scipy",
+	"seaborn":         "seaborn",
+	"shapely":         "shapely",
+	"sqlalchemy":      "sqlalchemy",
+	"statsmodels":     "statsmodels",
+	"sympy":           "sympy",
+	"tensorflow":      "tensorflow",
+	"torchaudio":      "torchaudio",
+	"torchvision":     "torchvision",
+	"tqdm":            "tqdm",
+	"transformers":    "transformers",
+	"xarray":          "xarray",
+}
+
+// condaRuntimePackages are parts of conda's own runtime, which devbox
+// environments don't need.
+var condaRuntimePackages = map[string]bool{
+	"_libgcc_mutex":    true,
+	"_openmp_mutex":    true,
+	"blas":             true,
+	"ca-certificates":  true,
+	"conda":            true,
+	"ld_impl_linux-64": true,
+	"libgcc-ng":        true,
+	"libstdcxx-ng":     true,
+	"mkl":              true,
+	"mkl-service":      true,
+	"nomkl":            true,
+	"pip":              true,
+	"python_abi":       true,
+	"setuptools":       true,
+	"tzdata":           true,
+	"wheel":            true,
+}
+
+// CondaDependency is a package in the dependencies of a conda
+// environment.yml.
+type CondaDependency struct {
+	Name string
+	// Spec is the version specification, such as "=3.11" or ">=1.24".
+	Spec string
+	// Pip is true for the packages in the pip section, which are PyPI
+	// requirements.
+	Pip  bool
+	Line int
+}
+
+// ImportedCondaPackage is how a conda dependency was imported.
+type ImportedCondaPackage struct {
+	CondaDependency
+	// Package is the devbox package it was mapped to, if any.
+	Package string
+	// Requirement is the pip requirement it was mapped to, if any.
+	Requirement string
+	// Confidence is one of ConfidenceHigh, ConfidenceMedium or
+	// ConfidenceLow. It's empty for dependencies that weren't imported.
+	Confidence string
+	Reason     string
+}
+
+// ParseCondaEnvironment returns the dependencies of a conda environment.yml.
+func ParseCondaEnvironment(data []byte) ([]CondaDependency, error) {
+	var env struct {
+		Dependencies []yaml.Node `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, usererr.WithUserMessage(err, "environment.yml isn't valid YAML")
+	}
+	var deps []CondaDependency
+	for _, node := range env.Dependencies {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			deps = append(deps, parseCondaSpec(node.Value, node.Line))
+		case yaml.MappingNode:
+			var section struct {
+				Pip []yaml.Node `yaml:"pip"`
+			}
+			if err := node.Decode(&section); err != nil {
+				return nil, usererr.WithUserMessage(err, "Line %d of environment.yml has an invalid dependency", node.Line)
+			}
+			for _, req := range section.Pip {
+				deps = append(deps, CondaDependency{Name: req.Value, Pip: true, Line: req.Line})
+			}
+		default:
+			return nil, usererr.New("Line %d of environment.yml has an invalid dependency", node.Line)
+		}
+	}
+	return deps, nil
+}
+
+// parseCondaSpec parses a conda match spec, such as "conda-forge::numpy=1.24"
+// or "python 3.11.*".
+func parseCondaSpec(spec string, line int) CondaDependency {
+	if _, after, ok := strings.Cut(spec, "::"); ok {
+		spec = after
+	}
+	spec = strings.TrimSpace(spec)
+	i := strings.IndexAny(spec, "=<>!~ ")
+	if i < 0 {
+		return CondaDependency{Name: strings.ToLower(spec), Line: line}
+	}
+	version := strings.TrimSpace(spec[i:])
+	if version != "" && !strings.ContainsAny(version[:1], "=<>!~") {
+		// "python 3.11" is the same as "python=3.11".
+		version = "=" + version
+	}
+	return CondaDependency{Name: strings.ToLower(spec[:i]), Spec: version, Line: line}
+}
+
+// ImportConda adds the packages in a conda environment.yml to devbox.json.
+// Python libraries are written to a pip requirements file instead, with a
+// script that installs them in the python plugin's virtual environment. It
+// returns how each dependency was imported.
+func (d *Devbox) ImportConda(ctx context.Context, path, requirementsFile string) ([]ImportedCondaPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to read %s", path)
+	}
+	deps, err := ParseCondaEnvironment(data)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make([]ImportedCondaPackage, 0, len(deps))
+	var pkgs, requirements []string
+	hasPython := false
+	for _, dep := range deps {
+		pkg := mapCondaDependency(dep, searcher.Client().Resolve)
+		imported = append(imported, pkg)
+		if pkg.Package != "" {
+			pkgs = append(pkgs, pkg.Package)
+			hasPython = hasPython || dep.Name == "python"
+		}
+		if pkg.Requirement != "" {
+			requirements = append(requirements, pkg.Requirement)
+		}
+	}
+	if len(requirements) > 0 && !hasPython {
+		pkgs = append(pkgs, "python@latest")
+	}
+	if len(pkgs) == 0 {
+		ux.Fwarning(d.stderr, "None of the dependencies in %s could be mapped to devbox packages.\n", path)
+		return imported, nil
+	}
+
+	if len(requirements) > 0 {
+		if err := d.writeCondaRequirements(requirementsFile, requirements); err != nil {
+			return imported, err
+		}
+	}
+	return imported, d.Add(ctx, pkgs, devopt.AddOpts{})
+}
+
+// condaRequirementsScript is the script that installs the pip requirements
+// of an imported conda environment.
+const condaRequirementsScript = "install-requirements"
+
+func (d *Devbox) writeCondaRequirements(file string, requirements []string) error {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(d.projectDir, file)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return usererr.New("%s already exists. Use --requirements to write the pip requirements to another file.", file)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return redact.Errorf("stat %s: %w", path, err)
+	}
+	data := "# Imported from a conda environment.yml by devbox.\n" + strings.Join(requirements, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		return redact.Errorf("write %s: %w", path, err)
+	}
+	if rel, err := filepath.Rel(d.projectDir, path); err == nil {
+		file = rel
+	}
+	d.cfg.Root.SetScript(
+		condaRequirementsScript,
+		fmt.Sprintf(". $VENV_DIR/bin/activate && pip install -r %s", shellQuote(file)),
+	)
+	ux.Finfo(d.stderr,
+		"Wrote the Python libraries to %s. Run `devbox run %s` to install them in the virtual environment.\n",
+		file, condaRequirementsScript)
+	return nil
+}
+
+// mapCondaDependency maps a conda dependency to a devbox package or a pip
+// requirement.
+func mapCondaDependency(dep CondaDependency, resolve resolveFunc) ImportedCondaPackage {
+	result := ImportedCondaPackage{CondaDependency: dep}
+	switch {
+	case dep.Pip:
+		result.Requirement = dep.Name
+		result.Confidence = ConfidenceHigh
+		return result
+	case condaRuntimePackages[dep.Name]:
+		result.Reason = "is part of conda, which devbox doesn't need"
+		return result
+	case strings.HasPrefix(dep.Name, "r-") && dep.Name != "r-base":
+		result.Reason = "is an R package; install it with install.packages() in R"
+		return result
+	}
+
+	if name, ok := condaPipPackages[dep.Name]; ok {
+		result.Requirement = name + pipSpec(dep.Spec)
+		result.Confidence = ConfidenceHigh
+		return result
+	}
+	name, known := condaNixPackages[dep.Name]
+	if dep.Name == "python" {
+		name, known = "python", true
+	}
+	if !known {
+		name = dep.Name
+	}
+
+	version := nixVersion(dep.Spec)
+	tool := mapToolVersion(ToolVersion{Plugin: name, Version: version, Line: dep.Line}, resolve)
+	if _, resolved, _ := strings.Cut(tool.Package, "@"); strings.HasPrefix(resolved, version+".") {
+		// "=3.11" matches any 3.11 release.
+		tool.Reason = ""
+	}
+	switch {
+	case tool.Package == "" && strings.HasPrefix(tool.Reason, "search failed"):
+		result.Reason = tool.Reason
+	case tool.Package != "" && known:
+		result.Package = tool.Package
+		result.Confidence = ConfidenceHigh
+		if tool.Reason != "" {
+			result.Confidence = ConfidenceMedium
+		}
+		result.Reason = tool.Reason
+	case tool.Package != "":
+		result.Package = tool.Package
+		result.Confidence = ConfidenceLow
+		result.Reason = "a package with the same name was found"
+	case known:
+		result.Reason = tool.Reason
+	default:
+		// Most conda packages that nixpkgs doesn't have by name are
+		// Python libraries.
+		result.Requirement = dep.Name + pipSpec(dep.Spec)
+		result.Confidence = ConfidenceLow
+		result.Reason = "isn't a known package, so it's assumed to be a Python library"
+	}
+	return result
+}
+
+// nixVersion returns the version to look up for a conda version spec. Specs
+// other than a version, such as ranges, use the latest version.
+func nixVersion(spec string) string {
+	version := strings.TrimLeft(spec, "=")
+	version, _, _ = strings.Cut(version, "=") // build string
+	version = strings.TrimSuffix(strings.TrimSuffix(version, "*"), ".")
+	if version == "" || strings.ContainsAny(version, "<>!~,|*") {
+		return "latest"
+	}
+	return version
+}
+
+// pipSpec converts a conda version spec to a pip one. In conda, "=1.24"
+// matches any 1.24 release, which is "==1.24.*" in pip.
+func pipSpec(spec string) string {
+	switch {
+	case spec == "":
+		return ""
+	case strings.HasPrefix(spec, "=="), !strings.HasPrefix(spec, "="):
+		return spec
+	}
+	version, _, _ := strings.Cut(spec[1:], "=") // build string
+	if strings.HasSuffix(version, "*") {
+		return "==" + version
+	}
+	return "==" + version + ".*"
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestParseCondaEnvironment(t *testing.T) {
+	deps, err := ParseCondaEnvironment([]byte(`name: science
+channels:
+  - conda-forge
+dependencies:
+  - python=3.11
+  - conda-forge::numpy>=1.24
+  - pandas 2.1.*
+  - r-base
+  - pip
+  - pip:
+      - requests==2.31.0
+`))
+	require.NoError(t, err)
+	require.Equal(t, []CondaDependency{
+		{Name: "python", Spec: "=3.11", Line: 5},
+		{Name: "numpy", Spec: ">=1.24", Line: 6},
+		{Name: "pandas", Spec: "=2.1.*", Line: 7},
+		{Name: "r-base", Line: 8},
+		{Name: "pip", Line: 9},
+		{Name: "requests==2.31.0", Pip: true, Line: 11},
+	}, deps)
+
+	_, err = ParseCondaEnvironment([]byte("dependencies: [[python]]"))
+	require.Error(t, err)
+}
+
+func TestPipSpec(t *testing.T) {
+	require.Equal(t, "", pipSpec(""))
+	require.Equal(t, "==1.24.*", pipSpec("=1.24"))
+	require.Equal(t, "==2.1.*", pipSpec("=2.1.*"))
+	require.Equal(t, "==1.24.3", pipSpec("==1.24.3"))
+	require.Equal(t, "==1.24.*", pipSpec("=1.24=py311h64a7726_0"))
+	require.Equal(t, ">=1.24", pipSpec(">=1.24"))
+}
+
+func TestMapCondaDependency(t *testing.T) {
+	available := map[string]string{
+		"python@3.11":    "3.11.9",
+		"cmake@latest":   "3.29.2",
+		"R@4":            "4.3.3",
+		"ripgrep@latest": "14.1.0",
+	}
+	resolve := func(name, version string) (*searcher.PackageVersion, error) {
+		if v, ok := available[name+"@"+version]; ok {
+			return &searcher.PackageVersion{PackageInfo: searcher.PackageInfo{Version: v}}, nil
+		}
+		return nil, searcher.ErrNotFound
+	}
+
+	tests := []struct {
+		dep         CondaDependency
+		pkg         string
+		requirement string
+		confidence  string
+	}{
+		{CondaDependency{Name: "python", Spec: "=3.11"}, "python@3.11.9", "", ConfidenceHigh},
+		{CondaDependency{Name: "cmake", Spec: ">=3.20"}, "cmake@3.29.2", "", ConfidenceHigh},
+		{CondaDependency{Name: "r-base", Spec: "=4.2.1"}, "R@4.3.3", "", ConfidenceMedium},
+		{CondaDependency{Name: "numpy", Spec: "=1.24"}, "", "numpy==1.24.*", ConfidenceHigh},
+		{CondaDependency{Name: "pytorch"}, "", "torch", ConfidenceHigh},
+		{CondaDependency{Name: "ripgrep"}, "ripgrep@14.1.0", "", ConfidenceLow},
+		{CondaDependency{Name: "tabulate", Spec: ">=0.9"}, "", "tabulate>=0.9", ConfidenceLow},
+		{CondaDependency{Name: "black==24.2.0", Pip: true}, "", "black==24.2.0", ConfidenceHigh},
+		{CondaDependency{Name: "setuptools"}, "", "", ""},
+		{CondaDependency{Name: "r-ggplot2"}, "", "", ""},
+	}
+	for _, test := range tests {
+		got := mapCondaDependency(test.dep, resolve)
+		require.Equal(t, test.pkg, got.Package, test.dep)
+		require.Equal(t, test.requirement, got.Requirement, test.dep)
+		require.Equal(t, test.confidence, got.Confidence, test.dep)
+	}
+}
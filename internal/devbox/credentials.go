@@ -0,0 +1,46 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/providers/credhelper"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// startCredentials mints credentials with the helpers in devbox.json that
+// apply to script (or to devbox shell if script is empty), and adds them to
+// env. Credentials a parent devbox shell or script already minted are
+// inherited instead. The caller must close the returned session when the
+// script or shell exits.
+func (d *Devbox) startCredentials(
+	ctx context.Context,
+	env map[string]string,
+	script string,
+	refresh bool,
+) (*credhelper.Session, error) {
+	parentEnv := envir.PairsToMap(os.Environ())
+	helpers := map[string]credhelper.Helper{}
+	for name, spec := range d.cfg.Credentials() {
+		if !spec.AppliesTo(script) {
+			continue
+		}
+		if credhelper.Inherited(name, parentEnv) {
+			debug.Log("credentials: using %s credentials from the parent environment", name)
+			continue
+		}
+		helpers[name] = credhelper.New(spec)
+	}
+
+	session, err := credhelper.Start(ctx, helpers, refresh)
+	if err != nil {
+		return nil, err
+	}
+	markSecrets(env, session.Apply(env)...)
+	debug.SetSecrets(secretsIn(env))
+	return session, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/mod/modfile"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// versionFiles are the language version files DetectToolVersions reads, in
+// the order it reads them. Each returns the asdf plugin name and version in
+// the file, or an empty version if the file doesn't pin one devbox can use.
+var versionFiles = []struct {
+	name  string
+	parse func(data []byte) (plugin, version string, err error)
+}{
+	{".nvmrc", parseNodeVersion},
+	{".node-version", parseNodeVersion},
+	{".python-version", parsePythonVersion},
+	{".ruby-version", parseRubyVersion},
+	{"go.mod", parseGoMod},
+	{"rust-toolchain.toml", parseRustToolchainTOML},
+	{"rust-toolchain", parseRustToolchain},
+}
+
+// DetectToolVersions reads the language version files in dir, such as .nvmrc
+// and go.mod, and returns the tools they pin. Tools are named after their
+// asdf plugins, so that they're mapped to packages the same way as the
+// entries of a .tool-versions file.
+func DetectToolVersions(dir string) ([]ToolVersion, error) {
+	var tools []ToolVersion
+	seen := map[string]bool{}
+	for _, file := range versionFiles {
+		data, err := os.ReadFile(filepath.Join(dir, file.name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, redact.Errorf("read %s: %w", file.name, err)
+		}
+		plugin, version, err := file.parse(data)
+		if err != nil {
+			return nil, redact.Errorf("parse %s: %w", file.name, err)
+		}
+		if version == "" {
+			debug.Log("detect: %s doesn't pin a version devbox can use", file.name)
+			continue
+		}
+		// .nvmrc takes precedence over .node-version, and
+		// rust-toolchain.toml over rust-toolchain.
+		if seen[plugin] {
+			continue
+		}
+		seen[plugin] = true
+		tools = append(tools, ToolVersion{Plugin: plugin, Version: version, Source: file.name})
+	}
+	return tools, nil
+}
+
+// ResolveToolVersions maps tools to devbox packages, using the closest
+// version the search service has.
+func ResolveToolVersions(tools []ToolVersion) []ImportedTool {
+	imported := make([]ImportedTool, 0, len(tools))
+	for _, tool := range tools {
+		imported = append(imported, mapToolVersion(tool, searcher.Client().Resolve))
+	}
+	return imported
+}
+
+func firstLine(data []byte) string {
+	line, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+	line, _, _ = strings.Cut(line, "#")
+	return strings.TrimSpace(line)
+}
+
+func parseNodeVersion(data []byte) (string, string, error) {
+	version := strings.TrimPrefix(firstLine(data), "v")
+	switch {
+	case version == "node" || version == "stable":
+		version = "latest"
+	case strings.HasPrefix(version, "lts/"):
+		// mapToolVersion uses the latest version for LTS aliases.
+		version = "lts"
+	case version == "system" || version == "iojs":
+		version = ""
+	}
+	return "nodejs", version, nil
+}
+
+func parsePythonVersion(data []byte) (string, string, error) {
+	// pyenv also accepts names such as pypy3.10 or a virtualenv, which
+	// aren't CPython versions.
+	version := firstLine(data)
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return "python", "", nil
+	}
+	return "python", version, nil
+}
+
+func parseRubyVersion(data []byte) (string, string, error) {
+	version := strings.TrimPrefix(firstLine(data), "ruby-")
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		// Other implementations, such as jruby-9.4.
+		return "ruby", "", nil
+	}
+	return "ruby", version, nil
+}
+
+// parseGoMod returns the toolchain directive's version, or the go
+// directive's version if there's no toolchain directive.
+func parseGoMod(data []byte) (string, string, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if f.Toolchain != nil {
+		if version, ok := strings.CutPrefix(f.Toolchain.Name, "go"); ok {
+			return "golang", version, nil
+		}
+	}
+	if f.Go != nil {
+		return "golang", f.Go.Version, nil
+	}
+	return "golang", "", nil
+}
+
+func parseRustToolchainTOML(data []byte) (string, string, error) {
+	var file struct {
+		Toolchain struct {
+			Channel string `toml:"channel"`
+		} `toml:"toolchain"`
+	}
+	if err := toml.Unmarshal(data, &file); err != nil {
+		return "", "", err
+	}
+	return "rust", rustChannelVersion(file.Toolchain.Channel), nil
+}
+
+func parseRustToolchain(data []byte) (string, string, error) {
+	// The legacy file is either TOML or just the channel name.
+	if bytes.Contains(data, []byte("[toolchain]")) {
+		return parseRustToolchainTOML(data)
+	}
+	return "rust", rustChannelVersion(firstLine(data)), nil
+}
+
+// rustChannelVersion returns the version of a rustup channel. nixpkgs only
+// has stable releases, so beta and nightly channels have none.
+func rustChannelVersion(channel string) string {
+	switch {
+	case channel == "stable":
+		return "latest"
+	case channel == "" || channel[0] < '0' || channel[0] > '9':
+		return ""
+	}
+	// Channels can have a host triple, such as 1.75.0-x86_64-unknown-linux-gnu.
+	version, _, _ := strings.Cut(channel, "-")
+	return version
+}
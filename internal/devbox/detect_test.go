@@ -0,0 +1,58 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectToolVersions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".nvmrc":              "v20.11.0\n",
+		".node-version":       "18.0.0\n",
+		".python-version":     "pypy3.10\n",
+		".ruby-version":       "ruby-3.2.2\n",
+		"go.mod":              "module example.com/m\n\ngo 1.21\n\ntoolchain go1.22.1\n",
+		"rust-toolchain.toml": "[toolchain]\nchannel = \"1.75.0\"\ncomponents = [\"clippy\"]\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	tools, err := DetectToolVersions(dir)
+	require.NoError(t, err)
+	require.Equal(t, []ToolVersion{
+		{Plugin: "nodejs", Version: "20.11.0", Source: ".nvmrc"},
+		{Plugin: "ruby", Version: "3.2.2", Source: ".ruby-version"},
+		{Plugin: "golang", Version: "1.22.1", Source: "go.mod"},
+		{Plugin: "rust", Version: "1.75.0", Source: "rust-toolchain.toml"},
+	}, tools)
+}
+
+func TestVersionFileParsers(t *testing.T) {
+	tests := []struct {
+		parse   func([]byte) (string, string, error)
+		content string
+		version string
+	}{
+		{parseNodeVersion, "lts/iron", "lts"},
+		{parseNodeVersion, "node", "latest"},
+		{parsePythonVersion, "3.12.1\n3.11\n", "3.12.1"},
+		{parseRubyVersion, "jruby-9.4", ""},
+		{parseGoMod, "module m\n\ngo 1.22\n", "1.22"},
+		{parseRustToolchain, "stable\n", "latest"},
+		{parseRustToolchain, "nightly-2024-01-01\n", ""},
+		{parseRustToolchain, "1.76-aarch64-apple-darwin\n", "1.76"},
+	}
+	for _, tt := range tests {
+		_, version, err := tt.parse([]byte(tt.content))
+		require.NoError(t, err)
+		require.Equal(t, tt.version, version, tt.content)
+	}
+}
@@ -68,6 +68,7 @@ type Devbox struct {
 	pluginManager            *plugin.Manager
 	preservePathStack        bool
 	pure                     bool
+	sandbox                  bool
 	customProcessComposeFile string
 
 	// This is needed because of the --quiet flag.
@@ -96,6 +97,10 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, err
 	}
 
+	// Mask secrets set by the devbox shell or script this runs in until the
+	// environment is computed.
+	debug.SetSecrets(secretsIn(envir.PairsToMap(os.Environ())))
+
 	box := &Devbox{
 		cfg:                      cfg,
 		env:                      opts.Env,
@@ -106,6 +111,7 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		stderr:                   opts.Stderr,
 		preservePathStack:        opts.PreservePathStack,
 		pure:                     opts.Pure,
+		sandbox:                  opts.Sandbox,
 		customProcessComposeFile: opts.CustomProcessComposeFile,
 	}
 
@@ -135,6 +141,7 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		plugin.WithLockfile(lock),
 	)
 	box.lockfile = lock
+	box.setSubstituters()
 
 	if !opts.IgnoreWarnings &&
 		!legacyPackagesWarningHasBeenShown &&
@@ -189,6 +196,24 @@ func (d *Devbox) ConfigHash() (string, error) {
 	return cachehash.Bytes(buf.Bytes()), nil
 }
 
+// PackagesHash is a hash of the packages the project installs, including
+// those from plugins and local flakes. Unlike ConfigHash, it doesn't change
+// when only env, scripts or init hooks are edited.
+func (d *Devbox) PackagesHash() (string, error) {
+	h, err := cachehash.JSON(d.cfg.Packages(true /*includeRemovedTriggerPackages*/))
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(h)
+	buf.WriteString(d.NixPkgsCommitHash())
+	for _, pkg := range d.AllPackages() {
+		buf.WriteString(pkg.Hash())
+	}
+	return cachehash.Bytes(buf.Bytes()), nil
+}
+
 func (d *Devbox) NixPkgsCommitHash() string {
 	return d.cfg.NixPkgsCommitHash()
 }
@@ -200,15 +225,28 @@ func (d *Devbox) Generate(ctx context.Context) error {
 	return errors.WithStack(shellgen.GenerateForPrintEnv(ctx, d))
 }
 
-func (d *Devbox) Shell(ctx context.Context) error {
+func (d *Devbox) Shell(ctx context.Context, shellOpts devopt.ShellOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxShell")
 	defer task.End()
 
+	host := envir.PairsToMap(os.Environ())
+	stack, err := d.pushShellStack()
+	if err != nil {
+		return err
+	}
 	envs, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
 	if err != nil {
 		return err
 	}
 
+	d.warnEnvSchema(d.stderr, envs)
+
+	creds, err := d.startCredentials(ctx, envs, "", true)
+	if err != nil {
+		return err
+	}
+	defer creds.Close()
+
 	fmt.Fprintln(d.stderr, "Starting a devbox shell...")
 
 	// Used to determine whether we're inside a shell (e.g. to prevent shell inception)
@@ -216,6 +254,7 @@ func (d *Devbox) Shell(ctx context.Context) error {
 	// the user does shell-ception. One option is to leave the current shell and
 	// join a new one (that way they are not in nested shells.)
 	envs[envir.DevboxShellEnabled] = "1"
+	envs[envir.DevboxShellStack] = stack
 
 	if err = createDevboxSymlink(d); err != nil {
 		return err
@@ -233,18 +272,46 @@ func (d *Devbox) Shell(ctx context.Context) error {
 		return err
 	}
 
-	return shell.Run()
+	if shellOpts.Record != "" {
+		dir, err := d.startRecording(shellOpts.Record, shell.binPath, host, envs)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		shell.record = true
+	}
+
+	return shell.Run(ctx)
 }
 
 func (d *Devbox) RunScript(ctx context.Context, cmdName string, cmdArgs []string) error {
+	return d.runScript(ctx, cmdName, cmdArgs, scriptOpts{refreshCredentials: true})
+}
+
+type scriptOpts struct {
+	// refreshCredentials keeps credentials from credential helpers valid
+	// while the script runs. Scripts that start something in the background
+	// exit right away, so they get credentials that aren't refreshed.
+	refreshCredentials bool
+}
+
+func (d *Devbox) runScript(ctx context.Context, cmdName string, cmdArgs []string, opts scriptOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxRun")
 	defer task.End()
 
-	if err := shellgen.WriteScriptsToFiles(d); err != nil {
-		return err
+	lock.SetIgnoreShellMismatch(true)
+
+	// Scripts are generated from the config, so they only need to be rewritten
+	// if it changed since they were last written, a different devbox version
+	// wrote them (its templates may differ), or they've gone missing.
+	drift, err := d.lockfile.StateDrift(isFishShell())
+	if err != nil || drift.Config || drift.Version ||
+		!fileutil.Exists(shellgen.ScriptPath(d.projectDir, shellgen.HooksFilename)) {
+		if err := shellgen.WriteScriptsToFiles(d); err != nil {
+			return err
+		}
 	}
 
-	lock.SetIgnoreShellMismatch(true)
 	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
 	if err != nil {
 		return err
@@ -282,15 +349,37 @@ func (d *Devbox) RunScript(ctx context.Context, cmdName string, cmdArgs []string
 		env["DEVBOX_RUN_CMD"] = strings.Join(append([]string{cmdName}, cmdArgs...), " ")
 	}
 
-	return nix.RunScript(d.projectDir, strings.Join(cmdWithArgs, " "), env)
+	creds, err := d.startCredentials(ctx, env, cmdName, opts.refreshCredentials)
+	if err != nil {
+		return err
+	}
+	defer creds.Close()
+
+	var wrapper []string
+	if d.sandbox {
+		if wrapper, err = d.sandboxWrapper(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	var secrets redact.Secrets
+	if !envir.ShowSecrets() {
+		secrets = secretsIn(env)
+	}
+	return nix.RunScript(d.projectDir, strings.Join(cmdWithArgs, " "), env, secrets, wrapper...)
 }
 
 // Install ensures that all the packages in the config are installed
 // but does not run init hooks. It is used to power devbox install cli command.
-func (d *Devbox) Install(ctx context.Context) error {
+func (d *Devbox) Install(ctx context.Context, opts devopt.InstallOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxInstall")
 	defer task.End()
 
+	if opts.Frozen {
+		if err := d.checkFrozenLockfile(); err != nil {
+			return err
+		}
+	}
 	return d.ensureStateIsUpToDate(ctx, ensure)
 }
 
@@ -312,15 +401,22 @@ func (d *Devbox) EnvExports(ctx context.Context, opts devopt.EnvExportsOpts) (st
 	ctx, task := trace.NewTask(ctx, "devboxEnvExports")
 	defer task.End()
 
+	dialect, err := ParseShellDialect(opts.Shell)
+	if err != nil {
+		return "", err
+	}
+
 	var envs map[string]string
-	var err error
 
 	if opts.DontRecomputeEnvironment {
 		upToDate, _ := d.lockfile.IsUpToDateAndInstalled(isFishShell())
 		if !upToDate {
 			cmd := `eval "$(devbox global shellenv --recompute)"`
-			if isFishShell() {
+			switch dialect {
+			case DialectFish:
 				cmd = `devbox global shellenv --recompute | source`
+			case DialectNushell:
+				cmd = nuRefreshCmd("global shellenv --recompute")
 			}
 			ux.Finfo(
 				d.stderr,
@@ -338,15 +434,32 @@ func (d *Devbox) EnvExports(ctx context.Context, opts devopt.EnvExportsOpts) (st
 		return "", err
 	}
 
-	envStr := exportify(envs)
+	// Fish and nushell can't source the init hooks, which are POSIX shell
+	// scripts, so their changes to the environment are exported instead.
+	if opts.RunHooks && dialect == DialectJSON {
+		if err := d.mergeHookEnv(ctx, envs); err != nil {
+			return "", err
+		}
+	}
+
+	envStr := exportEnv(dialect, envs)
 
 	if opts.RunHooks {
-		hooksStr := ". " + shellgen.ScriptPath(d.ProjectDir(), shellgen.HooksFilename)
-		envStr = fmt.Sprintf("%s\n%s;\n", envStr, hooksStr)
+		switch dialect {
+		case DialectPOSIX:
+			hooksStr := ". " + shellgen.ScriptPath(d.ProjectDir(), shellgen.HooksFilename)
+			envStr = fmt.Sprintf("%s\n%s;\n", envStr, hooksStr)
+		case DialectFish, DialectNushell:
+			hookExports, err := d.hookExports(ctx, dialect, envs)
+			if err != nil {
+				return "", err
+			}
+			envStr += "\n" + hookExports + "\n"
+		}
 	}
 
-	if !opts.NoRefreshAlias {
-		envStr += "\n" + d.refreshAlias()
+	if !opts.NoRefreshAlias && dialect != DialectJSON {
+		envStr += "\n" + d.refreshAlias(dialect)
 	}
 
 	return envStr, nil
@@ -378,7 +491,7 @@ func (d *Devbox) Info(ctx context.Context, pkg string, markdown bool) (string, e
 		version = "latest"
 	}
 
-	packageVersion, err := searcher.Client().Resolve(name, version)
+	packageVersion, err := searcher.Client().ResolveContext(ctx, name, version)
 	if err != nil {
 		if !errors.Is(err, searcher.ErrNotFound) {
 			return "", usererr.WithUserMessage(err, "Package %q not found\n", pkg)
@@ -417,6 +530,19 @@ func (d *Devbox) Info(ctx context.Context, pkg string, markdown bool) (string, e
 func (d *Devbox) GenerateDevcontainer(ctx context.Context, generateOpts devopt.GenerateOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxGenerateDevcontainer")
 	defer task.End()
+	return d.generateDevcontainer(ctx, generateOpts, false /*codespaces*/)
+}
+
+// GenerateCodespaces generates the .devcontainer/ files of GitHub Codespaces.
+// They're the dev container's files, with lifecycle commands that install
+// the packages in prebuilds.
+func (d *Devbox) GenerateCodespaces(ctx context.Context, generateOpts devopt.GenerateOpts) error {
+	ctx, task := trace.NewTask(ctx, "devboxGenerateCodespaces")
+	defer task.End()
+	return d.generateDevcontainer(ctx, generateOpts, true /*codespaces*/)
+}
+
+func (d *Devbox) generateDevcontainer(ctx context.Context, generateOpts devopt.GenerateOpts, codespaces bool) error {
 
 	// construct path to devcontainer directory
 	devContainerPath := filepath.Join(d.projectDir, ".devcontainer/")
@@ -444,6 +570,7 @@ func (d *Devbox) GenerateDevcontainer(ctx context.Context, generateOpts devopt.G
 		Path:           devContainerPath,
 		RootUser:       generateOpts.RootUser,
 		IsDevcontainer: true,
+		Codespaces:     codespaces,
 		Pkgs:           d.AllPackageNamesIncludingRemovedTriggerPackages(),
 		LocalFlakeDirs: d.getLocalFlakesDirs(),
 	}
@@ -463,6 +590,24 @@ func (d *Devbox) GenerateDevcontainer(ctx context.Context, generateOpts devopt.G
 	return nil
 }
 
+// GenerateGitpod generates a .gitpod.yml, and the Dockerfile of its image,
+// that install the packages in Gitpod prebuilds.
+func (d *Devbox) GenerateGitpod(ctx context.Context, generateOpts devopt.GenerateOpts) error {
+	ctx, task := trace.NewTask(ctx, "devboxGenerateGitpod")
+	defer task.End()
+
+	for name := range generate.GitpodFiles {
+		if !generateOpts.Force && fileutil.Exists(filepath.Join(d.projectDir, name)) {
+			return usererr.New(
+				"%s is already present in the current directory. "+
+					"Remove it or use --force to overwrite it.", name,
+			)
+		}
+	}
+	gen := &generate.Options{Path: d.projectDir}
+	return errors.WithStack(gen.CreateGitpod(ctx))
+}
+
 // GenerateDockerfile generates a Dockerfile that replicates the devbox shell
 func (d *Devbox) GenerateDockerfile(ctx context.Context, generateOpts devopt.GenerateOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxGenerateDockerfile")
@@ -743,7 +888,7 @@ func (d *Devbox) StartProcessManager(
 		if background {
 			args = append(args, "--background")
 		}
-		return d.RunScript(ctx, "devbox", args)
+		return d.runScript(ctx, "devbox", args, scriptOpts{refreshCredentials: !background})
 	}
 
 	svcs, err := d.Services()
@@ -840,26 +985,125 @@ func (d *Devbox) StartProcessManager(
 func (d *Devbox) computeEnv(ctx context.Context, usePrintDevEnvCache bool) (map[string]string, error) {
 	defer trace.StartRegion(ctx, "devboxComputeEnv").End()
 
+	env, originalEnv, err := d.baseEnv(ctx, usePrintDevEnvCache)
+	if err != nil {
+		return nil, err
+	}
+
+	// Include env variables in devbox.json
+	configEnv, err := d.configEnvs(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	addEnvIfNotPreviouslySetByDevbox(env, configEnv)
+
+	markEnvsAsSetByDevbox(configEnv)
+
+	// devboxEnvPath starts with the initial PATH from print-dev-env, and is
+	// transformed to be the "PATH of the Devbox environment"
+	// TODO: The prior statement is not fully true,
+	//  since env["PATH"] is written to above and so it is already no longer "PATH
+	//  from print-dev-env". Consider moving devboxEnvPath higher up in this function
+	//  where env["PATH"] is written to.
+	devboxEnvPath := env["PATH"]
+	debug.Log("PATH after plugins and config is: %s", devboxEnvPath)
+
+	// We filter out nix store paths of devbox-packages (represented here as buildInputs).
+	// Motivation: if a user removes a package from their devbox it should no longer
+	// be available in their environment.
+	buildInputs := strings.Split(env["buildInputs"], " ")
+	var glibcPatchPath []string
+	devboxEnvPath = filterPathList(devboxEnvPath, func(path string) bool {
+		// TODO(gcurtis): this is a massive hack. Please get rid
+		// of this and install the package to the profile.
+		if strings.Contains(path, "patched-glibc") {
+			glibcPatchPath = append(glibcPatchPath, path)
+			return true
+		}
+		for _, input := range buildInputs {
+			// input is of the form: /nix/store/<hash>-<package-name>-<version>
+			// path is of the form: /nix/store/<hash>-<package-name>-<version>/bin
+			if strings.TrimSpace(input) != "" && strings.HasPrefix(path, input) {
+				debug.Log("returning false for path %s and input %s\n", path, input)
+				return false
+			}
+		}
+		return true
+	})
+	debug.Log("PATH after filtering with buildInputs (%v) is: %s", buildInputs, devboxEnvPath)
+
+	// TODO(gcurtis): this is a massive hack. Please get rid
+	// of this and install the package to the profile.
+	if len(glibcPatchPath) != 0 {
+		patchedPath := strings.Join(glibcPatchPath, string(filepath.ListSeparator))
+		devboxEnvPath = envpath.JoinPathLists(patchedPath, devboxEnvPath)
+		debug.Log("PATH after glibc-patch hack is: %s", devboxEnvPath)
+	}
+
+	runXPaths, err := d.RunXPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devboxEnvPath = envpath.JoinPathLists(devboxEnvPath, runXPaths)
+
+	pathStack := envpath.Stack(env, originalEnv)
+	pathStack.Push(env, d.ProjectDirHash(), devboxEnvPath, d.preservePathStack)
+	env["PATH"] = pathStack.Path(env)
+	debug.Log("New path stack is: %s", pathStack)
+	if d.cfg.Root.Path().Hermetic {
+		if err := createDevboxSymlink(d); err != nil {
+			return nil, err
+		}
+		env["PATH"] = d.hermeticPath(env["PATH"], devboxEnvPath)
+	}
+
+	debug.Log("computed environment PATH is: %s", env["PATH"])
+
+	if !d.pure {
+		// preserve the original XDG_DATA_DIRS by prepending to it
+		env["XDG_DATA_DIRS"] = envpath.JoinPathLists(env["XDG_DATA_DIRS"], os.Getenv("XDG_DATA_DIRS"))
+	}
+
+	for k, v := range d.env {
+		env[k] = v
+	}
+
+	return env, d.addHashToEnv(env)
+}
+
+// baseEnv returns the environment that devbox.json values are expanded
+// against: the current environment (see parseEnvAndExcludeSpecialCases), the
+// variables from "nix print-dev-env" and the DEVBOX_* variables. It also
+// returns a copy of the current environment before anything was added to it.
+func (d *Devbox) baseEnv(
+	ctx context.Context,
+	usePrintDevEnvCache bool,
+) (env, originalEnv map[string]string, err error) {
 	// Append variables from current env if --pure is not passed
 	currentEnv := os.Environ()
-	env, err := d.parseEnvAndExcludeSpecialCases(currentEnv)
+	env, err = d.parseEnvAndExcludeSpecialCases(currentEnv)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// check if contents of .envrc is old and print warning
 	if !usePrintDevEnvCache {
 		err := d.checkOldEnvrc()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	debug.Log("current environment PATH is: %s", env["PATH"])
 
-	originalEnv := make(map[string]string, len(env))
+	originalEnv = make(map[string]string, len(env))
 	maps.Copy(originalEnv, env)
 
+	// The cached environment is reused until the lockfile or the flake changes.
+	lockfileHash, err := cachehash.JSONFile(filepath.Join(d.projectDir, "devbox.lock"))
+	if err != nil {
+		return nil, nil, err
+	}
 	var spinny *spinner.Spinner
 	if !usePrintDevEnvCache {
 		spinny = spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(d.stderr))
@@ -872,12 +1116,13 @@ func (d *Devbox) computeEnv(ctx context.Context, usePrintDevEnvCache bool) (map[
 		FlakeDir:             d.flakeDir(),
 		PrintDevEnvCachePath: d.nixPrintDevEnvCachePath(),
 		UsePrintDevEnvCache:  usePrintDevEnvCache,
+		LockfileHash:         lockfileHash,
 	})
 	if spinny != nil {
 		spinny.Stop()
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Add environment variables from "nix print-dev-env" except for a few
@@ -915,7 +1160,7 @@ func (d *Devbox) computeEnv(ctx context.Context, usePrintDevEnvCache bool) (map[
 	// for both shell and run in order to be as identical as possible.
 	env["__ETC_PROFILE_NIX_SOURCED"] = "1" // Prevent user init file from loading nix profiles
 
-	debug.Log("nix environment PATH is: %s", env)
+	debug.Log("nix environment PATH is: %s", env["PATH"])
 
 	env["PATH"] = envpath.JoinPathLists(
 		nix.ProfileBinPath(d.projectDir),
@@ -926,80 +1171,12 @@ func (d *Devbox) computeEnv(ctx context.Context, usePrintDevEnvCache bool) (map[
 	env["DEVBOX_PROJECT_ROOT"] = d.projectDir
 	env["DEVBOX_CONFIG_DIR"] = d.projectDir + "/devbox.d"
 	env["DEVBOX_PACKAGES_DIR"] = d.projectDir + "/" + nix.ProfilePath
-
-	// Include env variables in devbox.json
-	configEnv, err := d.configEnvs(ctx, env)
-	if err != nil {
-		return nil, err
+	env[envir.DevboxProjectName] = d.projectName()
+	if hash, err := d.envHash(); err == nil {
+		env[envir.DevboxEnvHash] = hash
 	}
-	addEnvIfNotPreviouslySetByDevbox(env, configEnv)
 
-	markEnvsAsSetByDevbox(configEnv)
-
-	// devboxEnvPath starts with the initial PATH from print-dev-env, and is
-	// transformed to be the "PATH of the Devbox environment"
-	// TODO: The prior statement is not fully true,
-	//  since env["PATH"] is written to above and so it is already no longer "PATH
-	//  from print-dev-env". Consider moving devboxEnvPath higher up in this function
-	//  where env["PATH"] is written to.
-	devboxEnvPath := env["PATH"]
-	debug.Log("PATH after plugins and config is: %s", devboxEnvPath)
-
-	// We filter out nix store paths of devbox-packages (represented here as buildInputs).
-	// Motivation: if a user removes a package from their devbox it should no longer
-	// be available in their environment.
-	buildInputs := strings.Split(env["buildInputs"], " ")
-	var glibcPatchPath []string
-	devboxEnvPath = filterPathList(devboxEnvPath, func(path string) bool {
-		// TODO(gcurtis): this is a massive hack. Please get rid
-		// of this and install the package to the profile.
-		if strings.Contains(path, "patched-glibc") {
-			glibcPatchPath = append(glibcPatchPath, path)
-			return true
-		}
-		for _, input := range buildInputs {
-			// input is of the form: /nix/store/<hash>-<package-name>-<version>
-			// path is of the form: /nix/store/<hash>-<package-name>-<version>/bin
-			if strings.TrimSpace(input) != "" && strings.HasPrefix(path, input) {
-				debug.Log("returning false for path %s and input %s\n", path, input)
-				return false
-			}
-		}
-		return true
-	})
-	debug.Log("PATH after filtering with buildInputs (%v) is: %s", buildInputs, devboxEnvPath)
-
-	// TODO(gcurtis): this is a massive hack. Please get rid
-	// of this and install the package to the profile.
-	if len(glibcPatchPath) != 0 {
-		patchedPath := strings.Join(glibcPatchPath, string(filepath.ListSeparator))
-		devboxEnvPath = envpath.JoinPathLists(patchedPath, devboxEnvPath)
-		debug.Log("PATH after glibc-patch hack is: %s", devboxEnvPath)
-	}
-
-	runXPaths, err := d.RunXPaths(ctx)
-	if err != nil {
-		return nil, err
-	}
-	devboxEnvPath = envpath.JoinPathLists(devboxEnvPath, runXPaths)
-
-	pathStack := envpath.Stack(env, originalEnv)
-	pathStack.Push(env, d.ProjectDirHash(), devboxEnvPath, d.preservePathStack)
-	env["PATH"] = pathStack.Path(env)
-	debug.Log("New path stack is: %s", pathStack)
-
-	debug.Log("computed environment PATH is: %s", env["PATH"])
-
-	if !d.pure {
-		// preserve the original XDG_DATA_DIRS by prepending to it
-		env["XDG_DATA_DIRS"] = envpath.JoinPathLists(env["XDG_DATA_DIRS"], os.Getenv("XDG_DATA_DIRS"))
-	}
-
-	for k, v := range d.env {
-		env[k] = v
-	}
-
-	return env, d.addHashToEnv(env)
+	return env, originalEnv, nil
 }
 
 // ensureStateIsUpToDateAndComputeEnv will return a map of the env-vars for the Devbox  Environment
@@ -1060,6 +1237,49 @@ func (d *Devbox) AllPackageNamesIncludingRemovedTriggerPackages() []string {
 	return result
 }
 
+// PinnedCommit returns the nixpkgs commit that devbox.json or a plugin pins
+// the package to, or "".
+func (d *Devbox) PinnedCommit(pkg string) string {
+	for _, p := range d.cfg.Packages(true /*includeRemovedTriggerPackages*/) {
+		if p.VersionedName() == pkg {
+			return p.Commit
+		}
+	}
+	return ""
+}
+
+// BinaryCaches returns the binary_caches in devbox.json that the source
+// policy allows.
+func (d *Devbox) BinaryCaches() []string {
+	if len(d.cfg.Root.BinaryCaches) == 0 {
+		return nil
+	}
+	return d.allowedCaches(d.cfg.Root.BinaryCacheURLs())
+}
+
+// ResolverConfig returns the resolver that devbox.json selects, with the
+// path of its package index made absolute.
+func (d *Devbox) ResolverConfig() lock.ResolverConfig {
+	r := d.cfg.Root.Resolver
+	if r == nil {
+		return lock.ResolverConfig{}
+	}
+	cfg := lock.ResolverConfig{Backend: r.Backend, Nixpkgs: r.Nixpkgs, Options: r.Options}
+	if r.Index != "" {
+		cfg.Index = filepath.Join(d.projectDir, r.Index)
+		if filepath.IsAbs(r.Index) {
+			cfg.Index = r.Index
+		}
+	}
+	return cfg
+}
+
+// SearchPublicKeys returns the keys in devbox.json that search service
+// resolutions must be signed by.
+func (d *Devbox) SearchPublicKeys() []nix.PublicKey {
+	return d.cfg.Root.SearchPublicKeys()
+}
+
 // AllPackages returns the packages that are defined in devbox.json and
 // recursively added by plugins.
 // NOTE: This will not return packages removed by their plugin with the
@@ -1148,12 +1368,18 @@ func (d *Devbox) checkOldEnvrc() error {
 // their value in the existing env variables. Note, this doesn't
 // allow env variables from outside the shell to be referenced so
 // no leaked variables are caused by this function.
+//
+// If env_from points to a dotenv or YAML file, its layers are loaded first and
+// devbox.json values take precedence over them. See loadDotenv. Local secrets
+// take precedence over everything.
 func (d *Devbox) configEnvs(
 	ctx context.Context,
 	existingEnv map[string]string,
 ) (map[string]string, error) {
 	defer debug.FunctionTimer().End()
 	env := map[string]string{}
+	envFromFiles := map[string]string{}
+	var secretKeys []string
 	if d.cfg.IsEnvsecEnabled() {
 		secrets, err := d.Secrets(ctx)
 		// TODO: replace this with error.Is check once envsec exports it.
@@ -1176,20 +1402,59 @@ func (d *Devbox) configEnvs(
 			} else {
 				for _, secret := range cloudSecrets {
 					env[secret.Name] = secret.Value
+					secretKeys = append(secretKeys, secret.Name)
 				}
 			}
 		}
+	} else if d.cfg.Root.IsEnvFromFile() {
+		dotenvEnv, err := d.loadDotenv(ctx)
+		if _, ok := usererr.Extract(err); ok {
+			return nil, err
+		} else if err != nil {
+			return nil, usererr.WithUserMessage(err, "Failed to load env_from %s", d.cfg.Root.EnvFrom)
+		}
+		envFromFiles = dotenvEnv.Values()
+		for key := range envFromFiles {
+			if dotenvEnv.Sensitive(key) {
+				secretKeys = append(secretKeys, key)
+			}
+		}
 	} else if d.cfg.Root.EnvFrom != "" {
 		return nil, usererr.New(
-			"unknown from_env value: %s. Supported value is: %q.",
+			"unknown from_env value: %s. Supported values are %q or a path to a dotenv or YAML file (e.g. %q).",
 			d.cfg.Root.EnvFrom,
 			"jetpack-cloud",
+			".env",
 		)
 	}
+	maps.Copy(env, d.vmEnv())
 	for k, v := range d.cfg.Env() {
 		env[k] = v
 	}
-	return conf.OSExpandEnvMap(env, existingEnv, d.ProjectDir()), nil
+
+	// Values from env files are already interpolated, so they're not expanded
+	// again, but devbox.json values can reference them.
+	expandFrom := map[string]string{}
+	maps.Copy(expandFrom, existingEnv)
+	maps.Copy(expandFrom, envFromFiles)
+	maps.Copy(envFromFiles, conf.OSExpandEnvMap(env, expandFrom, d.ProjectDir()))
+
+	// Secrets set with `devbox secrets set --local` override everything.
+	localSecrets, err := d.LocalSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range localSecrets {
+		envFromFiles[key] = value
+		secretKeys = append(secretKeys, key)
+	}
+
+	if err := d.resolveSecretReferences(ctx, envFromFiles); err != nil {
+		return nil, err
+	}
+	markSecrets(envFromFiles, secretKeys...)
+	debug.SetSecrets(secretsIn(envFromFiles))
+	return envFromFiles, nil
 }
 
 // ignoreCurrentEnvVar contains environment variables that Devbox should remove
@@ -1309,7 +1574,7 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 		if !pkg.IsRunX() {
 			continue
 		}
-		lockedPkg, err := d.lockfile.Resolve(pkg.Raw)
+		lockedPkg, err := d.lockfile.ResolveContext(ctx, pkg.Raw)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,107 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+const (
+	shellHookStart = "# >>> devbox shellenv >>>"
+	shellHookEnd   = "# <<< devbox shellenv <<<"
+)
+
+// DevcontainerSetup prepares the project in a dev container. It installs the
+// packages in the mounted devbox.lock, which does nothing when the state in
+// .devbox is already up to date, such as when the container was built from
+// the same lockfile. It then adds a hook to the rc file of the container's
+// default shell, so that new shells activate the environment.
+func (d *Devbox) DevcontainerSetup(ctx context.Context) (rcFile string, err error) {
+	defer debug.FunctionTimer().End()
+	if err := d.ensureStateIsUpToDate(ctx, ensure); err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", redact.Errorf("find home directory: %w", err)
+	}
+	shell := filepath.Base(os.Getenv("SHELL"))
+	rcFile = shellRCFile(shell, home)
+	return rcFile, writeShellHook(rcFile, shellHook(shell, d.projectDir))
+}
+
+// shellRCFile returns the file that interactive shells of a kind read on
+// startup.
+func shellRCFile(shell, home string) string {
+	switch shell {
+	case "bash":
+		return filepath.Join(home, ".bashrc")
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc")
+		}
+		return filepath.Join(home, ".zshrc")
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "conf.d", "devbox.fish")
+	default:
+		return filepath.Join(home, ".profile")
+	}
+}
+
+// shellHook returns the rc file block that activates the project's
+// environment. It does nothing if devbox isn't in the PATH, so that the rc
+// file still works when it's shared with an image without devbox.
+func shellHook(shell, projectDir string) string {
+	if shell == "fish" {
+		return fmt.Sprintf(
+			"if command -q devbox\n    devbox shellenv --init-hook -c %s | source\nend",
+			shellQuote(projectDir),
+		)
+	}
+	return fmt.Sprintf(
+		"if command -v devbox >/dev/null 2>&1; then\n    eval \"$(devbox shellenv --init-hook -c %s)\"\nfi",
+		shellQuote(projectDir),
+	)
+}
+
+// writeShellHook adds hook to an rc file, between markers so that running
+// it again replaces the hook instead of adding another one.
+func writeShellHook(path, hook string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return redact.Errorf("read %s: %w", path, err)
+	}
+	block := []byte(shellHookStart + "\n" + hook + "\n" + shellHookEnd + "\n")
+
+	var updated []byte
+	before, rest, found := bytes.Cut(data, []byte(shellHookStart))
+	if _, after, ok := bytes.Cut(rest, []byte(shellHookEnd+"\n")); found && ok {
+		updated = slices.Concat(before, block, after)
+	} else {
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		updated = slices.Concat(data, block)
+	}
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return redact.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, updated, 0o644); err != nil {
+		return redact.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
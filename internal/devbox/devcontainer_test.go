@@ -0,0 +1,61 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellRCFile(t *testing.T) {
+	t.Setenv("ZDOTDIR", "")
+	require.Equal(t, "/home/me/.bashrc", shellRCFile("bash", "/home/me"))
+	require.Equal(t, "/home/me/.zshrc", shellRCFile("zsh", "/home/me"))
+	require.Equal(t, "/home/me/.config/fish/conf.d/devbox.fish", shellRCFile("fish", "/home/me"))
+	require.Equal(t, "/home/me/.profile", shellRCFile("", "/home/me"))
+
+	t.Setenv("ZDOTDIR", "/home/me/.config/zsh")
+	require.Equal(t, "/home/me/.config/zsh/.zshrc", shellRCFile("zsh", "/home/me"))
+}
+
+func TestShellHook(t *testing.T) {
+	require.Equal(t,
+		"if command -v devbox >/dev/null 2>&1; then\n"+
+			"    eval \"$(devbox shellenv --init-hook -c '/workspaces/it'\\''s')\"\n"+
+			"fi",
+		shellHook("bash", "/workspaces/it's"),
+	)
+	require.Contains(t, shellHook("fish", "/code"), "devbox shellenv --init-hook -c '/code' | source")
+}
+
+func TestWriteShellHook(t *testing.T) {
+	rc := filepath.Join(t.TempDir(), ".bashrc")
+	require.NoError(t, os.WriteFile(rc, []byte("export EDITOR=vim"), 0o644))
+
+	require.NoError(t, writeShellHook(rc, "echo one"))
+	require.NoError(t, writeShellHook(rc, "echo one"))
+	data, err := os.ReadFile(rc)
+	require.NoError(t, err)
+	require.Equal(t, "export EDITOR=vim\n"+shellHookStart+"\necho one\n"+shellHookEnd+"\n", string(data))
+
+	// A changed hook replaces the previous one, keeping what follows it.
+	require.NoError(t, os.WriteFile(rc, append(data, "alias ll='ls -l'\n"...), 0o644))
+	require.NoError(t, writeShellHook(rc, "echo two"))
+	data, err = os.ReadFile(rc)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(data), shellHookStart))
+	require.Equal(t,
+		"export EDITOR=vim\n"+shellHookStart+"\necho two\n"+shellHookEnd+"\nalias ll='ls -l'\n",
+		string(data),
+	)
+
+	// The rc file of fish is created with its directory.
+	fish := filepath.Join(t.TempDir(), "fish", "conf.d", "devbox.fish")
+	require.NoError(t, writeShellHook(fish, "echo three"))
+	require.FileExists(t, fish)
+}
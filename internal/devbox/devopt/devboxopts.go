@@ -2,6 +2,7 @@ package devopt
 
 import (
 	"io"
+	"time"
 )
 
 // Naming Convention:
@@ -14,6 +15,7 @@ type Opts struct {
 	Environment              string
 	PreservePathStack        bool
 	Pure                     bool
+	Sandbox                  bool
 	IgnoreWarnings           bool
 	CustomProcessComposeFile string
 	Stderr                   io.Writer
@@ -52,13 +54,71 @@ type AddOpts struct {
 	Outputs          []string
 }
 
+type InstallOpts struct {
+	// Frozen fails the install if devbox.lock would change or packages would
+	// need to be resolved.
+	Frozen bool
+}
+
+type RemoteRunOpts struct {
+	Host string
+	// Dir is the project's directory on the host. Relative paths are
+	// relative to the remote user's home directory.
+	Dir string
+	Cmd []string
+	// NoCopy doesn't copy the packages to the host, which then installs
+	// them from its binary caches.
+	NoCopy bool
+}
+
+type CachePushOpts struct {
+	// To is the store to push to instead of push_cache in devbox.json.
+	To string
+	// SigningKey is the path of the secret key to sign store paths with,
+	// instead of the one in devbox.json.
+	SigningKey string
+	// BuiltOnly pushes only the store paths that were built on this
+	// machine, and not the ones that were downloaded from a cache.
+	BuiltOnly bool
+}
+
+type GCOpts struct {
+	// ProjectDir is the project whose profile is collected along with the
+	// global profile. It's empty outside of a project.
+	ProjectDir string
+	// OlderThan keeps the generations that are newer than it. Zero removes
+	// every generation except the current one.
+	OlderThan time.Duration
+	// DryRun reports what would be removed without removing it.
+	DryRun bool
+}
+
 type UpdateOpts struct {
-	Pkgs                  []string
+	Pkgs []string
+	// All resolves every package again, instead of only the ones whose
+	// spec in devbox.json changed since they were locked.
+	All                   bool
 	IgnoreMissingPackages bool
 }
 
+type DirenvExportOpts struct {
+	// Regenerate recomputes the cached environment. It's set for the
+	// background process that updates a stale environment.
+	Regenerate bool
+	RunHooks   bool
+}
+
+type ShellOpts struct {
+	// Record is the path of a report of how the environment changes while
+	// the shell starts.
+	Record string
+}
+
 type EnvExportsOpts struct {
 	DontRecomputeEnvironment bool
 	NoRefreshAlias           bool
 	RunHooks                 bool
+	// Shell is the name of the shell to print the exports for, such as
+	// "fish" or "nu". It defaults to the shell that runs devbox.
+	Shell string
 }
@@ -14,6 +14,10 @@ import (
 	"go.jetpack.io/devbox/internal/fileutil"
 )
 
+// ErrProjectNotFound is the error that Open returns when it doesn't find a
+// devbox.json.
+var ErrProjectNotFound = errors.New("no devbox.json found")
+
 // findProjectDir walks up the directory tree looking for a devbox.json
 // and upon finding it, will return the directory-path.
 //
@@ -101,7 +105,8 @@ func missingConfigError(path string, didCheckParents bool) error {
 		parentDirCheckAddendum = ", or any parent directories"
 	}
 
-	return usererr.New(
+	return usererr.WithUserMessage(
+		ErrProjectNotFound,
 		"No devbox.json found in %s%s. Did you run `devbox init` yet?",
 		path,
 		parentDirCheckAddendum,
@@ -0,0 +1,327 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/plugin"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/shellgen"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+const (
+	// direnvCacheFile holds the environment `devbox direnv export` last
+	// computed. It's watched, so that direnv reloads once a background
+	// regeneration finishes.
+	direnvCacheFile = ".devbox/direnv-env.json"
+	// direnvLockFile exists while a background regeneration runs.
+	direnvLockFile = ".devbox/direnv-regenerate.lock"
+	direnvLogFile  = ".devbox/direnv-regenerate.log"
+
+	// direnvLockTimeout is how long a regeneration can run before another
+	// one may start, in case it was killed without removing its lock.
+	direnvLockTimeout = 15 * time.Minute
+)
+
+// direnvCache is the part of the devbox environment that devbox set, as
+// `devbox direnv export` last computed it. PATH is stored relative to the PATH
+// it was computed in, so that it can be applied in any shell.
+type direnvCache struct {
+	// Hash is the hash of the watched files the environment was computed
+	// from.
+	Hash string `json:"hash"`
+	// Env holds the variables that devbox set or changed, except PATH.
+	// Variables inherited unchanged from the environment it was computed
+	// in, such as credentials or SSH_AUTH_SOCK, are never stored.
+	Env map[string]string `json:"env"`
+	// PathPrefix is prepended to the shell's PATH.
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// FullPath replaces the shell's PATH when the devbox PATH doesn't end
+	// with it, for example in a pure environment.
+	FullPath string `json:"full_path,omitempty"`
+	// HasSecrets is true if the environment has secret values, which are
+	// never written to disk. Those environments are recomputed every time.
+	HasSecrets bool `json:"has_secrets,omitempty"`
+
+	// FailedHash and Error record a failed background regeneration, so that
+	// it isn't retried until the watched files change again.
+	FailedHash string `json:"failed_hash,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DirenvExport returns the shell code that an .envrc evaluates to load the
+// devbox environment. It loads the environment from a cache when possible,
+// and when the cache is stale it still returns it and regenerates it in the
+// background, so that direnv doesn't block the prompt while packages
+// install. direnv reloads once the regeneration updates the cache.
+func (d *Devbox) DirenvExport(ctx context.Context, opts devopt.DirenvExportOpts) (string, error) {
+	defer debug.FunctionTimer().End()
+	watched := d.WatchFiles()
+	hash, err := d.direnvHash(watched)
+	if err != nil {
+		return "", err
+	}
+	if opts.Regenerate {
+		return "", d.regenerateDirenvCache(ctx, hash)
+	}
+
+	cache := d.readDirenvCache()
+	if cache == nil || cache.HasSecrets {
+		// There's no environment to show while waiting, so compute it now.
+		if cache, err = d.computeDirenvCache(ctx, hash); err != nil {
+			return "", err
+		}
+	} else if cache.Hash != hash {
+		switch {
+		case cache.FailedHash == hash:
+			ux.Fwarning(d.stderr,
+				"devbox: updating the environment failed, so it's out of date: %s\n"+
+					"Run `devbox install` to see the full error.\n", cache.Error)
+		case d.startDirenvRegeneration():
+			ux.Finfo(d.stderr, "devbox: updating the environment in the background. direnv reloads it when it's done.\n")
+		}
+	}
+	return d.renderDirenvExport(cache, watched, opts.RunHooks), nil
+}
+
+// WatchFiles returns the files that change the environment: the config,
+// the lockfile, local plugins and env_from files.
+func (d *Devbox) WatchFiles() []string {
+	files := []string{
+		filepath.Join(d.projectDir, "devbox.json"),
+		filepath.Join(d.projectDir, "devbox.lock"),
+	}
+	for _, cfg := range d.cfg.IncludedPluginConfigs() {
+		if local, ok := cfg.Source.(*plugin.LocalPlugin); ok {
+			files = append(files, local.Path())
+		}
+	}
+	if d.cfg.Root.IsEnvFromFile() {
+		for _, layer := range d.dotenvLayers(context.Background()) {
+			files = append(files, layer.Path)
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files)
+}
+
+func (d *Devbox) direnvHash(watched []string) (string, error) {
+	hashes := map[string]string{}
+	for _, path := range watched {
+		hash, err := cachehash.File(path)
+		if err != nil {
+			return "", redact.Errorf("hash %s: %w", path, err)
+		}
+		hashes[path] = hash
+	}
+	return cachehash.JSON(struct {
+		Files       map[string]string
+		Env         map[string]string
+		Environment string
+		Version     string
+	}{hashes, d.env, d.environment, build.Version})
+}
+
+func (d *Devbox) readDirenvCache() *direnvCache {
+	data, err := os.ReadFile(filepath.Join(d.projectDir, direnvCacheFile))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			debug.Log("direnv: read cache: %v", err)
+		}
+		return nil
+	}
+	cache := &direnvCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		debug.Log("direnv: parse cache: %v", err)
+		return nil
+	}
+	return cache
+}
+
+func (d *Devbox) writeDirenvCache(cache *direnvCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return redact.Errorf("encode direnv cache: %w", err)
+	}
+	path := filepath.Join(d.projectDir, direnvCacheFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return redact.Errorf("write direnv cache: %w", err)
+	}
+	// Write atomically, since direnv may read it while it's written.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return redact.Errorf("write direnv cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return redact.Errorf("write direnv cache: %w", err)
+	}
+	return nil
+}
+
+// computeDirenvCache computes the environment, installing packages if
+// needed, and caches it.
+func (d *Devbox) computeDirenvCache(ctx context.Context, hash string) (*direnvCache, error) {
+	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache := newDirenvCache(hash, env, envir.PairsToMap(os.Environ()), lo.Keys(d.cfg.Env()))
+	if cache.HasSecrets {
+		// Use the secrets for this export without writing them to disk.
+		stored := *cache
+		stored.Env = withoutSecrets(cache.Env)
+		return cache, d.writeDirenvCache(&stored)
+	}
+	return cache, d.writeDirenvCache(cache)
+}
+
+// newDirenvCache keeps the variables in env that differ from processEnv, the
+// environment env was computed in, and the ones in setByDevbox (e.g. from
+// devbox.json) even if processEnv already has the same value.
+func newDirenvCache(hash string, env, processEnv map[string]string, setByDevbox []string) *direnvCache {
+	cache := &direnvCache{Hash: hash, Env: map[string]string{}}
+	for key, value := range env {
+		if key == "PATH" {
+			continue
+		}
+		current, ok := processEnv[key]
+		if !ok || current != value || slices.Contains(setByDevbox, key) || strings.HasPrefix(key, "DEVBOX_") {
+			cache.Env[key] = value
+		}
+	}
+	cache.HasSecrets = env[secretKeysEnv] != ""
+
+	path, processPath := env["PATH"], processEnv["PATH"]
+	if prefix, ok := strings.CutSuffix(path, string(filepath.ListSeparator)+processPath); ok && processPath != "" {
+		cache.PathPrefix = prefix
+	} else if path != processPath {
+		cache.FullPath = path
+	}
+	return cache
+}
+
+func withoutSecrets(env map[string]string) map[string]string {
+	secrets := strings.Split(env[secretKeysEnv], ",")
+	result := make(map[string]string, len(env))
+	for key, value := range env {
+		if !slices.Contains(secrets, key) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func (d *Devbox) renderDirenvExport(cache *direnvCache, watched []string, runHooks bool) string {
+	var b strings.Builder
+	if exports := exportify(cache.Env); exports != "" {
+		b.WriteString(exports)
+		b.WriteString("\n")
+	}
+	switch {
+	case cache.FullPath != "":
+		b.WriteString(exportify(map[string]string{"PATH": cache.FullPath}))
+		b.WriteString("\n")
+	case cache.PathPrefix != "":
+		// Keep $PATH unescaped so that the shell expands it.
+		prefix := exportify(map[string]string{"PATH": cache.PathPrefix})
+		b.WriteString(strings.TrimSuffix(prefix, `";`))
+		fmt.Fprintf(&b, "%c${PATH}\";\n", filepath.ListSeparator)
+	}
+	for _, path := range append(watched, filepath.Join(d.projectDir, direnvCacheFile)) {
+		fmt.Fprintf(&b, "watch_file %s\n", shellQuote(path))
+	}
+	if runHooks {
+		fmt.Fprintf(&b, ". %s\n", shellQuote(shellgen.ScriptPath(d.projectDir, shellgen.HooksFilename)))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// startDirenvRegeneration starts `devbox direnv export --regenerate` in the
+// background, unless one is already running. It returns false if it didn't
+// start one.
+func (d *Devbox) startDirenvRegeneration() bool {
+	lockPath := filepath.Join(d.projectDir, direnvLockFile)
+	if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) < direnvLockTimeout {
+		debug.Log("direnv: regeneration already running")
+		return false
+	}
+	_ = os.Remove(lockPath)
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		debug.Log("direnv: create lock: %v", err)
+		return false
+	}
+	lock.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		_ = os.Remove(lockPath)
+		debug.Log("direnv: find devbox: %v", err)
+		return false
+	}
+	logFile, err := os.Create(filepath.Join(d.projectDir, direnvLogFile))
+	if err != nil {
+		_ = os.Remove(lockPath)
+		debug.Log("direnv: create log: %v", err)
+		return false
+	}
+	defer logFile.Close()
+
+	// Run the same command line, so that flags such as --env-file apply.
+	cmd := exec.Command(exe, append(os.Args[1:], "--regenerate")...)
+	cmd.Dir = d.projectDir
+	// direnv waits until stdout is closed, so the regeneration mustn't
+	// inherit it.
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		_ = os.Remove(lockPath)
+		debug.Log("direnv: start regeneration: %v", err)
+		return false
+	}
+	_ = cmd.Process.Release()
+	return true
+}
+
+// regenerateDirenvCache recomputes the environment in the background. If it
+// fails, the previous environment stays in the cache along with the error.
+func (d *Devbox) regenerateDirenvCache(ctx context.Context, hash string) error {
+	defer os.Remove(filepath.Join(d.projectDir, direnvLockFile))
+	_, err := d.computeDirenvCache(ctx, hash)
+	if err == nil {
+		return nil
+	}
+	cache := d.readDirenvCache()
+	if cache == nil {
+		return err
+	}
+	cache.FailedHash = hash
+	cache.Error = err.Error()
+	if writeErr := d.writeDirenvCache(cache); writeErr != nil {
+		debug.Log("direnv: %v", writeErr)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig"
+)
+
+func TestNewDirenvCache(t *testing.T) {
+	processEnv := map[string]string{
+		"HOME":                  "/home/me",
+		"PATH":                  "/usr/bin:/bin",
+		"TERM":                  "xterm",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"SSH_AUTH_SOCK":         "/tmp/ssh-agent",
+		"EDITOR":                "vim",
+	}
+	cache := newDirenvCache("abc", map[string]string{
+		"HOME":                  "/home/me",
+		"FOO":                   "bar",
+		"TERM":                  "dumb",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"SSH_AUTH_SOCK":         "/tmp/ssh-agent",
+		"EDITOR":                "vim",
+		"PATH":                  "/nix/store/aaa-go/bin:/usr/bin:/bin",
+	}, processEnv, []string{"EDITOR"})
+	require.Equal(t, map[string]string{"FOO": "bar", "TERM": "dumb", "EDITOR": "vim"}, cache.Env,
+		"only variables devbox set or changed are stored")
+	require.Equal(t, "/nix/store/aaa-go/bin", cache.PathPrefix)
+	require.Empty(t, cache.FullPath)
+	require.False(t, cache.HasSecrets)
+
+	cache = newDirenvCache("abc", map[string]string{
+		"PATH":        "/nix/store/aaa-go/bin",
+		"TOKEN":       "hunter2",
+		secretKeysEnv: "TOKEN",
+	}, processEnv, nil)
+	require.Equal(t, "/nix/store/aaa-go/bin", cache.FullPath)
+	require.True(t, cache.HasSecrets)
+	require.Equal(t, map[string]string{
+		"TOKEN":       "hunter2",
+		secretKeysEnv: "TOKEN",
+	}, cache.Env)
+	require.Equal(t, map[string]string{secretKeysEnv: "TOKEN"}, withoutSecrets(cache.Env))
+}
+
+func TestRenderDirenvExport(t *testing.T) {
+	d := &Devbox{projectDir: "/work/my project"}
+	out := d.renderDirenvExport(&direnvCache{
+		Env:        map[string]string{"FOO": "a $b"},
+		PathPrefix: "/nix/store/aaa-go/bin",
+	}, []string{"/work/my project/devbox.json"}, false)
+	require.Equal(t, `export FOO="a \$b";
+export PATH="/nix/store/aaa-go/bin:${PATH}";
+watch_file '/work/my project/devbox.json'
+watch_file '/work/my project/.devbox/direnv-env.json'
+`, out)
+}
+
+func TestDirenvWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"env_from": ".env"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(config), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("FOO=1\n"), 0o644))
+	cfg, err := devconfig.Open(dir)
+	require.NoError(t, err)
+	d := &Devbox{cfg: cfg, projectDir: dir}
+
+	require.Equal(t, []string{
+		filepath.Join(dir, ".env"),
+		filepath.Join(dir, ".env.local"),
+		filepath.Join(dir, "devbox.json"),
+		filepath.Join(dir, "devbox.lock"),
+	}, d.WatchFiles())
+}
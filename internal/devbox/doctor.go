@@ -0,0 +1,388 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// DoctorReport is what devbox doctor checked. It has the devbox version and
+// platform as well, so that it can be attached to a bug report as is.
+type DoctorReport struct {
+	DevboxVersion string `json:"devbox_version"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	ProjectDir    string `json:"project_dir,omitempty"`
+
+	Nix []nix.Check `json:"nix"`
+	// Project is empty outside of a project.
+	Project []nix.Check `json:"project,omitempty"`
+	Shell   []nix.Check `json:"shell,omitempty"`
+}
+
+// Failed returns the number of checks that aren't OK.
+func (r *DoctorReport) Failed() int {
+	checks := slices.Concat(r.Nix, r.Project, r.Shell)
+	return lo.CountBy(checks, func(c nix.Check) bool { return !c.OK })
+}
+
+// Doctor checks the Nix installation, the project of box, unless it's nil,
+// and how the user's shell is set up to use devbox. If the project couldn't
+// be opened, openErr is why, and the project's checks are a failed config
+// check instead.
+func Doctor(ctx context.Context, box *Devbox, openErr error) *DoctorReport {
+	report := &DoctorReport{
+		DevboxVersion: build.Version,
+		OS:            build.OS(),
+		Arch:          runtime.GOARCH,
+		Nix:           nix.Doctor(ctx),
+	}
+	switch {
+	case box != nil:
+		report.ProjectDir = box.projectDir
+		report.Project = box.doctorChecks(ctx)
+	case openErr != nil:
+		report.Project = []nix.Check{configCheck(openErr)}
+	}
+	report.Shell = shellChecks()
+	return report
+}
+
+func (d *Devbox) doctorChecks(ctx context.Context) []nix.Check {
+	checks := []nix.Check{d.lockfileCheck(), d.installCheck()}
+	checks = append(checks, d.profileChecks()...)
+	checks = append(checks, d.cacheChecks(ctx)...)
+	if check, ok := d.pathCheck(); ok {
+		checks = append(checks, check)
+	}
+	if check, ok := d.direnvCheck(); ok {
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// configCheck is the failed check for a project that devbox can't open, for
+// example because devbox.json isn't valid.
+func configCheck(openErr error) nix.Check {
+	detail := openErr.Error()
+	if userErr, ok := usererr.Extract(openErr); ok {
+		detail = userErr.Error()
+	}
+	return nix.Check{
+		Name:   "config",
+		Detail: "can't open the project: " + detail,
+		Fix:    "Fix the error in devbox.json or devbox.lock.",
+	}
+}
+
+// lockfileCheck checks that devbox.lock has an entry for every package that
+// needs one, and none for packages that devbox.json no longer has.
+func (d *Devbox) lockfileCheck() nix.Check {
+	check := nix.Check{Name: "lockfile"}
+	names := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	if len(names) > 0 && !fileutil.Exists(filepath.Join(d.projectDir, "devbox.lock")) {
+		check.Detail = "devbox.lock doesn't exist"
+		check.Fix = "Run `devbox install` to create it, and commit it."
+		return check
+	}
+
+	missing := lo.Filter(names, func(name string, _ int) bool {
+		if lock.IsLegacyPackage(name) || (pkgtype.IsFlake(name) && !lock.IsLockableFlake(name)) {
+			return false
+		}
+		return d.lockfile.Get(name) == nil
+	})
+	stale := d.lockfile.StalePackages()
+	switch {
+	case len(missing) > 0:
+		check.Detail = "devbox.lock has no entry for " + strings.Join(missing, ", ")
+		check.Fix = "Run `devbox install` to lock them, and commit devbox.lock."
+	case len(stale) > 0:
+		check.OK = true
+		check.Detail = "devbox.lock has entries for " + strings.Join(stale, ", ") + ", which devbox.json doesn't have"
+		check.Fix = "Run `devbox lock prune` to remove them."
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("devbox.lock matches devbox.json (%d packages)", len(names))
+	}
+	return check
+}
+
+// installCheck checks that the installed packages and the saved environment
+// are up to date with devbox.json and devbox.lock.
+func (d *Devbox) installCheck() nix.Check {
+	check := nix.Check{Name: "install"}
+	drift, err := d.lockfile.StateDrift(isFishShell())
+	switch {
+	case err != nil:
+		check.Detail = fmt.Sprintf("can't compare the project with its installed state: %v", err)
+		check.Fix = "Run `devbox install`."
+	case drift.NeedsInstall():
+		check.Detail = "the installed packages don't match devbox.json and devbox.lock"
+		check.Fix = "Run `devbox install`."
+	case drift.Any():
+		check.OK = true
+		check.Detail = "packages are installed, but the environment will be computed again"
+		check.Fix = "Run `devbox shell` or `devbox refresh` to update it."
+	default:
+		check.OK = true
+		check.Detail = "packages and environment are up to date"
+	}
+	return check
+}
+
+// profileChecks checks that the project's profile still points to a store
+// path, and counts the old generations of the profiles that devbox created.
+func (d *Devbox) profileChecks() []nix.Check {
+	var checks []nix.Check
+	profile := filepath.Join(d.projectDir, nix.ProfilePath)
+	if _, err := os.Lstat(profile); err == nil {
+		check := nix.Check{Name: "profile", OK: true, Detail: profile}
+		if _, err := os.Stat(profile); err != nil {
+			check.OK = false
+			check.Detail = "the project's profile points to a store path that was garbage collected"
+			check.Fix = "Run `devbox install` to install the packages again."
+		}
+		checks = append(checks, check)
+	}
+
+	profiles, err := gcProfiles(d.projectDir)
+	if err != nil {
+		debug.Log("doctor: listing profiles: %v", err)
+		return checks
+	}
+	var old []nix.Generation
+	for _, profile := range profiles {
+		gens, err := nix.ProfileGenerations(profile)
+		if err != nil {
+			debug.Log("doctor: listing generations of %s: %v", profile, err)
+			continue
+		}
+		old = append(old, generationsToRemove(gens, time.Time{})...)
+	}
+	check := nix.Check{Name: "generations", OK: true, Detail: "no old profile generations"}
+	if len(old) > 0 {
+		check.Detail = fmt.Sprintf("%d old profile generations keep their packages in the store", len(old))
+		check.Fix = "Run `devbox gc` to remove them."
+	}
+	return append(checks, check)
+}
+
+// cacheChecks checks that devbox can reach the binary caches that it looks
+// store paths up in.
+func (d *Devbox) cacheChecks(ctx context.Context) []nix.Check {
+	var checks []nix.Check
+	for _, cache := range d.lockfile.BinaryCaches() {
+		checks = append(checks, cacheCheck(ctx, cache))
+	}
+	return checks
+}
+
+func cacheCheck(ctx context.Context, cache string) nix.Check {
+	check := nix.Check{Name: "cache " + cache}
+	if envir.IsOffline() {
+		check.OK = true
+		check.Detail = "not checked, since devbox is offline"
+		return check
+	}
+	if !strings.HasPrefix(cache, "http://") && !strings.HasPrefix(cache, "https://") {
+		check.OK = true
+		check.Detail = "not checked, since it isn't an HTTP cache"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cache, "/")+"/nix-cache-info", nil)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "Fix the URL in binary_caches in devbox.json."
+		return check
+	}
+	nix.SetNetrcAuth(req)
+	res, err := httpclient.Client().Do(req)
+	if err != nil {
+		check.Detail = fmt.Sprintf("can't reach the cache: %v", err)
+		check.Fix = "Check your network connection and proxy settings."
+		return check
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusOK:
+		check.OK = true
+		check.Detail = "reachable"
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		check.Detail = "the cache refused the request: " + res.Status
+		check.Fix = "Set the cache's credentials with netrc_file or password_env in binary_caches in devbox.json."
+	default:
+		check.Detail = "the cache responded with " + res.Status
+		check.Fix = "Check the URL in binary_caches in devbox.json."
+	}
+	return check
+}
+
+// pathCheck checks that no other PATH entry comes before the project's
+// packages in the project's devbox shell. It only applies in that shell.
+func (d *Devbox) pathCheck() (nix.Check, bool) {
+	if !envir.IsDevboxShellEnabled() || os.Getenv("DEVBOX_PROJECT_ROOT") != d.projectDir {
+		return nix.Check{}, false
+	}
+	shadowed := shadowedBinaries(nix.ProfileBinPath(d.projectDir))
+	if len(shadowed) == 0 {
+		return nix.Check{Name: "path", OK: true, Detail: "PATH finds the project's packages first"}, true
+	}
+	return nix.Check{
+		Name:   "path",
+		Detail: "PATH finds other binaries first: " + strings.Join(shadowed, ", "),
+		Fix: "Remove the PATH entries that your shell's rc file or the init hooks add " +
+			"before " + nix.ProfileBinPath(d.projectDir) + ".",
+	}, true
+}
+
+// shadowedBinaries returns the executables in binDir that PATH resolves to
+// another file, in the form "name (path)".
+func shadowedBinaries(binDir string) []string {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil
+	}
+	var shadowed []string
+	for _, entry := range entries {
+		path, err := exec.LookPath(entry.Name())
+		if err != nil || filepath.Dir(path) == binDir {
+			continue
+		}
+		if same, _ := sameFile(path, filepath.Join(binDir, entry.Name())); !same {
+			shadowed = append(shadowed, fmt.Sprintf("%s (%s)", entry.Name(), path))
+		}
+	}
+	return shadowed
+}
+
+func sameFile(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
+
+// direnvCheck checks that direnv can load the project's .envrc, if it has
+// one.
+func (d *Devbox) direnvCheck() (nix.Check, bool) {
+	if !fileutil.Exists(filepath.Join(d.projectDir, ".envrc")) {
+		return nix.Check{}, false
+	}
+	check := nix.Check{Name: "direnv"}
+	if _, err := exec.LookPath("direnv"); err != nil {
+		check.Detail = "the project has an .envrc, but direnv isn't in your PATH"
+		check.Fix = "Install direnv, or remove .envrc."
+		return check, true
+	}
+	shell := initShellBinaryFields(os.Getenv(envir.Shell))
+	if shell.userShellrcPath == "" || shell.name == shPosix {
+		check.OK = true
+		check.Detail = "direnv is installed, but devbox can't tell whether your shell loads it"
+		return check, true
+	}
+	if !fileContains(shell.userShellrcPath, "direnv hook") {
+		check.Detail = fmt.Sprintf("%s doesn't hook direnv into your shell", shell.userShellrcPath)
+		check.Fix = fmt.Sprintf("Add %s to %s.", direnvHookCommand(shell.name), shell.userShellrcPath)
+		return check, true
+	}
+	check.OK = true
+	check.Detail = "direnv is hooked into your shell"
+	return check, true
+}
+
+func direnvHookCommand(sh name) string {
+	if sh == shFish {
+		return "`direnv hook fish | source`"
+	}
+	return fmt.Sprintf("`eval \"$(direnv hook %s)\"`", sh)
+}
+
+// shellChecks checks that the user's shell loads the global packages, if
+// there are any.
+func shellChecks() []nix.Check {
+	binDir := nix.ProfileBinPath(xdg.DataSubpath(filepath.Join("devbox/global", currentGlobalProfile)))
+	if entries, err := os.ReadDir(binDir); err != nil || len(entries) == 0 {
+		return nil
+	}
+	return []nix.Check{globalShellenvCheck(binDir, initShellBinaryFields(os.Getenv(envir.Shell)))}
+}
+
+func globalShellenvCheck(binDir string, shell *DevboxShell) nix.Check {
+	check := nix.Check{Name: "global shellenv"}
+	if slices.Contains(filepath.SplitList(os.Getenv("PATH")), binDir) {
+		check.OK = true
+		check.Detail = "the global packages are in your PATH"
+		return check
+	}
+	rcPath := shell.userShellrcPath
+	if shell.name == shNushell {
+		rcPath = nushellConfig()
+	}
+	if rcPath == "" || shell.name == shPosix || shell.name == shUnknown {
+		check.Detail = "the global packages aren't in your PATH"
+		check.Fix = "Add `eval \"$(devbox global shellenv --init-hook)\"` to your shell's rc file."
+		return check
+	}
+	if fileContains(rcPath, "devbox global shellenv") {
+		check.OK = true
+		check.Detail = fmt.Sprintf("%s loads the global packages", rcPath)
+		check.Fix = "Restart your shell to load them."
+		return check
+	}
+	check.Detail = fmt.Sprintf("the global packages aren't in your PATH, and %s doesn't load them", rcPath)
+	check.Fix = fmt.Sprintf("Add %s to %s.", globalShellenvCommand(shell.name), rcPath)
+	return check
+}
+
+func globalShellenvCommand(sh name) string {
+	switch sh {
+	case shFish:
+		return "`devbox global shellenv --init-hook | source`"
+	case shNushell:
+		return "`" + nuRefreshCmd("global shellenv --init-hook") + "`"
+	default:
+		return "`eval \"$(devbox global shellenv --init-hook)\"`"
+	}
+}
+
+func nushellConfig() string {
+	return xdg.ConfigSubpath("nushell/config.nu")
+}
+
+func fileContains(path, s string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Contains(data, []byte(s))
+}
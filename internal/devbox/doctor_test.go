@@ -0,0 +1,104 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+func TestCacheCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok/nix-cache-info":
+			w.Write([]byte("StoreDir: /nix/store\n"))
+		case "/private/nix-cache-info":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	check := cacheCheck(ctx, server.URL+"/ok")
+	require.True(t, check.OK, check.Detail)
+
+	check = cacheCheck(ctx, server.URL+"/private")
+	require.False(t, check.OK)
+	require.Contains(t, check.Fix, "password_env")
+
+	check = cacheCheck(ctx, server.URL+"/missing")
+	require.False(t, check.OK)
+	require.Contains(t, check.Detail, "404")
+
+	check = cacheCheck(ctx, "s3://acme-nix-cache")
+	require.True(t, check.OK)
+}
+
+func TestConfigCheck(t *testing.T) {
+	_, err := Open(&devopt.Opts{Dir: t.TempDir(), Stderr: io.Discard})
+	require.ErrorIs(t, err, ErrProjectNotFound)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{"packages": [`), 0o644))
+	_, err = Open(&devopt.Opts{Dir: dir, Stderr: io.Discard})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrProjectNotFound)
+
+	check := configCheck(err)
+	require.Equal(t, "config", check.Name)
+	require.False(t, check.OK)
+	require.Contains(t, check.Detail, "can't open the project")
+}
+
+func writeExecutable(t *testing.T, dir, name string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755))
+}
+
+func TestShadowedBinaries(t *testing.T) {
+	binDir := filepath.Join(t.TempDir(), "bin")
+	hostDir := t.TempDir()
+	writeExecutable(t, binDir, "python3")
+	writeExecutable(t, binDir, "go")
+	writeExecutable(t, hostDir, "python3")
+
+	t.Setenv("PATH", binDir+string(filepath.ListSeparator)+hostDir)
+	require.Empty(t, shadowedBinaries(binDir))
+
+	t.Setenv("PATH", hostDir+string(filepath.ListSeparator)+binDir)
+	require.Equal(t, []string{"python3 (" + filepath.Join(hostDir, "python3") + ")"}, shadowedBinaries(binDir))
+}
+
+func TestGlobalShellenvCheck(t *testing.T) {
+	binDir := filepath.Join(t.TempDir(), "bin")
+	rc := filepath.Join(t.TempDir(), ".zshrc")
+	shell := &DevboxShell{name: shZsh, userShellrcPath: rc}
+	t.Setenv("PATH", "/usr/bin")
+
+	check := globalShellenvCheck(binDir, shell)
+	require.False(t, check.OK)
+	require.Contains(t, check.Fix, `eval "$(devbox global shellenv --init-hook)"`)
+
+	require.NoError(t, os.WriteFile(rc, []byte(`eval "$(devbox global shellenv --init-hook)"`+"\n"), 0o644))
+	check = globalShellenvCheck(binDir, shell)
+	require.True(t, check.OK)
+
+	t.Setenv("PATH", strings.Join([]string{binDir, "/usr/bin"}, string(filepath.ListSeparator)))
+	check = globalShellenvCheck(binDir, &DevboxShell{name: shFish, userShellrcPath: filepath.Join(t.TempDir(), "config.fish")})
+	require.True(t, check.OK)
+	require.Empty(t, check.Fix)
+}
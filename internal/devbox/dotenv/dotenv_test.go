@@ -0,0 +1,150 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package dotenv
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	src := `# comment
+export A=plain
+B = spaced value  # trailing comment
+C='single $A #kept'
+D="double\n$A ${B}\$"
+E=${A}-${UNDEFINED}-$FROM_LOOKUP
+URL=http://host/#anchor
+MULTI="line1
+line2"
+EMPTY=
+`
+	lookup := func(key string) (string, bool) {
+		if key == "FROM_LOOKUP" {
+			return "looked-up", true
+		}
+		return "", false
+	}
+	vars, err := Parse(strings.NewReader(src), lookup)
+	require.NoError(t, err)
+
+	got := map[string]string{}
+	for _, v := range vars {
+		got[v.Key] = v.Value
+	}
+	require.Equal(t, map[string]string{
+		"A":     "plain",
+		"B":     "spaced value",
+		"C":     "single $A #kept",
+		"D":     "double\nplain spaced value$",
+		"E":     "plain--looked-up",
+		"URL":   "http://host/#anchor",
+		"MULTI": "line1\nline2",
+		"EMPTY": "",
+	}, got)
+
+	require.Equal(t, "B", vars[1].Key)
+	require.Equal(t, 3, vars[1].Line)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"NOEQUALS\n",
+		"BAD-KEY=x\n",
+		`A="unterminated`,
+		"A='unterminated",
+	} {
+		_, err := Parse(strings.NewReader(src), nil)
+		require.Error(t, err, src)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	require.NoError(t, os.WriteFile(base, []byte("HOST=localhost\nPORT=8080\nUSER=base\n"), 0o644))
+	require.NoError(t, os.WriteFile(local, []byte("PORT=9090\nURL=http://$HOST:${PORT}/${USER}\n"), 0o644))
+
+	processEnv := func(key string) (string, bool) {
+		if key == "USER" {
+			return "process", true
+		}
+		return "", false
+	}
+	env, err := Load([]Layer{
+		{Name: ".env", Path: base},
+		{Name: ".env.local", Path: local},
+		{Name: ".env.missing", Path: filepath.Join(dir, ".env.missing")},
+	}, processEnv)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{
+		"HOST": "localhost",
+		"PORT": "9090",
+		"USER": "process",
+		"URL":  "http://localhost:9090/process",
+	}, env.Values())
+
+	require.Equal(t, []Origin{
+		{Layer: ".env", Line: 2, Raw: "8080", Value: "8080"},
+		{Layer: ".env.local", Line: 1, Raw: "9090", Value: "9090"},
+	}, env.Origins("PORT"))
+	require.Equal(t, []Origin{
+		{Layer: ".env", Line: 3, Raw: "base", Value: "base"},
+		{Layer: ProcessEnvLayer, Raw: "process", Value: "process"},
+	}, env.Origins("USER"))
+	require.Empty(t, env.Origins("NOPE"))
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	want := map[string]string{
+		"PLAIN":         "value",
+		"QUOTES":        `say "hi" \o/`,
+		"DOLLAR":        "$NOT_A_REFERENCE ${EITHER}",
+		"NEWLINE":       "line1\nline2\ttabbed",
+		"EMPTY":         "",
+		"SINGLE":        `it's $HOME\ok`,
+		"CR":            "line1\r\nline2",
+		"BACKSLASH":     `C:\Users\`,
+		"BACKSLASH_REF": `$HOME\tmp#1\`,
+		"QUOTE_END":     `it's "quoted"`,
+	}
+	data, err := Format(want)
+	require.NoError(t, err)
+	vars, err := Parse(bytes.NewReader(data), nil)
+	require.NoError(t, err)
+
+	got := map[string]string{}
+	for _, v := range vars {
+		got[v.Key] = v.Value
+	}
+	require.Equal(t, want, got)
+
+	for _, value := range []string{"line1\nline2\\", ` leading\`, `"quoted\`, `a #comment\`} {
+		_, err := Format(map[string]string{"KEY": value})
+		require.Error(t, err, "%q can't be written", value)
+	}
+}
+
+func TestLoadSensitiveLayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("ciphertext"), 0o644))
+
+	decode := func(_ string, data []byte) ([]byte, bool, error) {
+		require.Equal(t, "ciphertext", string(data))
+		return []byte("TOKEN=hunter2\n"), true, nil
+	}
+	env, err := Load([]Layer{{Name: ".env", Path: path, Decode: decode}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"TOKEN": "hunter2"}, env.Values())
+	require.True(t, env.Origins("TOKEN")[0].Sensitive)
+	require.True(t, env.ContainsSensitive("postgres://admin:hunter2@db"))
+	require.False(t, env.ContainsSensitive("postgres://admin@db"))
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package dotenv
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// ProcessEnvLayer is the name of the layer for values that come from the
+// environment devbox was started in.
+const ProcessEnvLayer = "process environment"
+
+// Layer is a dotenv file in a stack of layers. Files that don't exist are
+// treated as empty, so optional layers such as .env.local can always be listed.
+type Layer struct {
+	// Name is how the layer is shown to users (e.g. ".env.local").
+	Name string
+	Path string
+
+	// Decode, if set, converts the file's contents to dotenv format before
+	// they're parsed. It's used for files in other formats and for encrypted
+	// files. Values from a layer that Decode reports as sensitive are
+	// masked in Origins.
+	Decode func(path string, data []byte) (dotenv []byte, sensitive bool, err error)
+}
+
+// Origin describes a value a layer assigned to a variable.
+type Origin struct {
+	Layer string
+	// Line is the line in the layer's file. It's 0 for the process environment.
+	Line  int
+	Raw   string
+	Value string
+	// Sensitive is true if the value was decrypted and must not be shown.
+	Sensitive bool
+}
+
+// Env is the result of loading a stack of layers.
+type Env struct {
+	values  map[string]string
+	origins map[string][]Origin
+}
+
+// Load reads layers from lowest to highest precedence. Values from the
+// process environment (looked up with processEnv, which may be nil) take
+// precedence over every layer.
+//
+// References to other variables are resolved against earlier assignments in
+// the same layer, then the process environment and then lower layers. So
+// .env.local can build on values from .env, but not the other way around.
+func Load(layers []Layer, processEnv func(string) (string, bool)) (*Env, error) {
+	if processEnv == nil {
+		processEnv = func(string) (string, bool) { return "", false }
+	}
+	env := &Env{
+		values:  map[string]string{},
+		origins: map[string][]Origin{},
+	}
+	lookup := func(key string) (string, bool) {
+		if v, ok := processEnv(key); ok {
+			return v, true
+		}
+		v, ok := env.values[key]
+		return v, ok
+	}
+
+	for _, layer := range layers {
+		data, err := os.ReadFile(layer.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, redact.Errorf("read env file %s: %w", layer.Name, err)
+		}
+		sensitive := false
+		if layer.Decode != nil {
+			data, sensitive, err = layer.Decode(layer.Path, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		vars, err := Parse(bytes.NewReader(data), lookup)
+		if err != nil {
+			return nil, redact.Errorf("parse env file %s: %w", layer.Name, err)
+		}
+
+		for _, v := range vars {
+			env.values[v.Key] = v.Value
+			env.origins[v.Key] = append(env.origins[v.Key], Origin{
+				Layer:     layer.Name,
+				Line:      v.Line,
+				Raw:       v.Value,
+				Value:     v.Value,
+				Sensitive: sensitive,
+			})
+		}
+	}
+
+	for key := range env.values {
+		if v, ok := processEnv(key); ok {
+			env.values[key] = v
+			env.origins[key] = append(env.origins[key], Origin{
+				Layer: ProcessEnvLayer,
+				Raw:   v,
+				Value: v,
+			})
+		}
+	}
+	return env, nil
+}
+
+// Values returns the effective value of every variable set by a layer.
+func (e *Env) Values() map[string]string {
+	return maps.Clone(e.values)
+}
+
+// Sensitive returns true if the effective value of key came from a sensitive
+// layer.
+func (e *Env) Sensitive(key string) bool {
+	origins := e.origins[key]
+	return len(origins) > 0 && origins[len(origins)-1].Sensitive
+}
+
+// ContainsSensitive returns true if s includes the effective value of a
+// variable that came from a sensitive layer.
+func (e *Env) ContainsSensitive(s string) bool {
+	for key, value := range e.values {
+		if e.Sensitive(key) && value != "" && strings.Contains(s, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Origins returns every value assigned to key, from lowest to highest
+// precedence. The last origin is the one in effect.
+func (e *Env) Origins(key string) []Origin {
+	return e.origins[key]
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package dotenv reads layered dotenv files (e.g. .env and .env.local) and
+// interpolates variable references across layers.
+package dotenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/samber/lo"
+)
+
+// Var is a single assignment in a dotenv file.
+type Var struct {
+	Key string
+	// Value is the value after unquoting and interpolation.
+	Value string
+	Line  int
+}
+
+var (
+	// referenceRegex matches the variable references that godotenv expands.
+	referenceRegex = regexp.MustCompile(`\$\{?([A-Z0-9_]+)`)
+
+	assignmentRegex = regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?([A-Za-z0-9_.]+)[ \t]*[=:]`)
+
+	keyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Parse reads dotenv assignments from r, ordered by the line they appear on.
+// It uses godotenv, so env_from files follow the same quoting and expansion
+// rules as --env-file.
+//
+// Unquoted and double quoted values may reference other variables as $VAR or
+// ${VAR}. References resolve to variables defined earlier in the same file
+// first, and then to lookup. Undefined variables expand to an empty string.
+func Parse(r io.Reader, lookup func(string) (string, bool)) ([]Var, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := godotenv.UnmarshalBytes(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// godotenv only expands references to variables assigned earlier in the
+	// same file, so the values lookup has for them are prepended.
+	lookedUp := map[string]string{}
+	if lookup != nil {
+		for _, match := range referenceRegex.FindAllSubmatch(src, -1) {
+			if v, ok := lookup(string(match[1])); ok {
+				lookedUp[string(match[1])] = v
+			}
+		}
+	}
+	prelude, err := Format(lookedUp)
+	if err != nil {
+		return nil, err
+	}
+	values, err := godotenv.UnmarshalBytes(slices.Concat(prelude, src))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := assignmentLines(src)
+	vars := make([]Var, 0, len(assigned))
+	for key := range assigned {
+		vars = append(vars, Var{Key: key, Value: values[key], Line: lines[key]})
+	}
+	slices.SortFunc(vars, func(a, b Var) int { return a.Line - b.Line })
+	return vars, nil
+}
+
+// assignmentLines returns the line of the last assignment to each key in src.
+func assignmentLines(src []byte) map[string]int {
+	lines := map[string]int{}
+	for _, loc := range assignmentRegex.FindAllSubmatchIndex(src, -1) {
+		key := string(src[loc[2]:loc[3]])
+		lines[key] = bytes.Count(src[:loc[0]], []byte("\n")) + 1
+	}
+	return lines
+}
+
+// IsValidKey returns true if key can be used as a variable name: letters,
+// digits and underscores, not starting with a digit.
+func IsValidKey(key string) bool {
+	return keyRegex.MatchString(key)
+}
+
+var formatEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// unquotedEscaper escapes the $ in an unquoted value, which is otherwise
+// taken literally.
+var unquotedEscaper = strings.NewReplacer(`$`, `\$`)
+
+// commentRegex matches the start of a comment in an unquoted value.
+var commentRegex = regexp.MustCompile("[\t\v\f \u0085\u00a0]#")
+
+// Format encodes vars as a dotenv file that Parse reads back without changes.
+// Keys are sorted. Values are single quoted so they're taken literally, or
+// double quoted with escapes if they include a single quote or a carriage
+// return.
+//
+// godotenv can't read back a quoted value that ends with a backslash, or a
+// double quoted one that ends with a double quote, so those are written
+// unquoted with their $ escaped. That doesn't work for values on more than
+// one line, with leading spaces or quotes, or with a comment, which Format
+// returns an error for.
+func Format(vars map[string]string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	keys := lo.Keys(vars)
+	slices.Sort(keys)
+	for _, key := range keys {
+		value := vars[key]
+		buf.WriteString(key)
+		doubleQuoted := strings.ContainsAny(value, "'\r")
+		switch {
+		case strings.HasSuffix(value, `\`) || doubleQuoted && strings.HasSuffix(value, `"`):
+			if !isUnquotable(value) {
+				return nil, fmt.Errorf("the value of %s can't be written to a dotenv file", key)
+			}
+			buf.WriteString("=")
+			buf.WriteString(unquotedEscaper.Replace(value))
+			buf.WriteString("\n")
+		case doubleQuoted:
+			buf.WriteString(`="`)
+			buf.WriteString(formatEscaper.Replace(value))
+			buf.WriteString("\"\n")
+		default:
+			buf.WriteString(`='`)
+			buf.WriteString(value)
+			buf.WriteString("'\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// isUnquotable returns true if godotenv reads value back as is when it's
+// written without quotes.
+func isUnquotable(value string) bool {
+	return !strings.ContainsAny(value, "\n\r") &&
+		strings.TrimSpace(value) == value &&
+		!strings.HasPrefix(value, `'`) && !strings.HasPrefix(value, `"`) &&
+		!commentRegex.MatchString(value)
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/conf"
+	"go.jetpack.io/devbox/internal/devbox/dotenv"
+	"go.jetpack.io/devbox/internal/devbox/providers/sops"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvLayer is the name shown for values set in the env section of
+// devbox.json (or included plugins), which take precedence over env_from.
+const configEnvLayer = "devbox.json"
+
+// dotenvLayers returns the env files that env_from points to, from lowest
+// to highest precedence. A ".local" variant of the file can hold per-developer
+// values that shouldn't be committed.
+func (d *Devbox) dotenvLayers(ctx context.Context) []dotenv.Layer {
+	name := d.cfg.Root.EnvFrom
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(d.projectDir, path)
+	}
+	decode := func(path string, data []byte) ([]byte, bool, error) {
+		return decodeEnvFile(ctx, path, data)
+	}
+	return []dotenv.Layer{
+		{Name: name, Path: path, Decode: decode},
+		{Name: name + ".local", Path: path + ".local", Decode: decode},
+	}
+}
+
+// loadDotenv loads the layered env files from env_from. It returns an empty
+// env if env_from doesn't point to a dotenv or YAML file.
+func (d *Devbox) loadDotenv(ctx context.Context) (*dotenv.Env, error) {
+	if !d.cfg.Root.IsEnvFromFile() {
+		return dotenv.Load(nil, nil)
+	}
+	return dotenv.Load(d.dotenvLayers(ctx), d.userProcessEnv)
+}
+
+// decodeEnvFile converts an env_from file to dotenv format, decrypting it
+// first if it was encrypted with SOPS. Decrypted values are reported as
+// sensitive and are never written to disk.
+func decodeEnvFile(ctx context.Context, path string, data []byte) ([]byte, bool, error) {
+	format := sops.FormatDotenv
+	switch filepath.Ext(strings.TrimSuffix(path, ".local")) {
+	case ".yaml", ".yml":
+		format = sops.FormatYAML
+	}
+
+	encrypted := sops.IsEncrypted(data, format)
+	if encrypted {
+		var err error
+		if data, err = sops.Decrypt(ctx, path, format); err != nil {
+			return nil, false, err
+		}
+	}
+	if format == sops.FormatYAML {
+		vars, err := yamlEnvVars(path, data)
+		if err != nil {
+			return nil, false, err
+		}
+		if data, err = dotenv.Format(vars); err != nil {
+			return nil, false, usererr.WithUserMessage(err, "Failed to read env_from file %s", path)
+		}
+	}
+	return data, encrypted, nil
+}
+
+// yamlEnvVars reads a YAML file whose top-level keys are variable names.
+// Scalars are used as is and other values are encoded as JSON.
+func yamlEnvVars(path string, data []byte) (map[string]string, error) {
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, usererr.WithUserMessage(err, "Failed to parse env_from file %s", path)
+	}
+	delete(doc, "sops")
+
+	vars := make(map[string]string, len(doc))
+	for key, value := range doc {
+		switch v := value.(type) {
+		case nil:
+			vars[key] = ""
+		case string:
+			vars[key] = v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, usererr.New("Value of %s in %s can't be used as an env var", key, path)
+			}
+			vars[key] = string(b)
+		}
+	}
+	return vars, nil
+}
+
+// userProcessEnv looks up key in the environment devbox was started in. Values
+// that devbox itself set (e.g. in the shell a refresh is running in) are
+// ignored so that edits to the env files still take effect. Pure shells don't
+// inherit anything from the process environment.
+func (d *Devbox) userProcessEnv(key string) (string, bool) {
+	if d.pure {
+		return "", false
+	}
+	if _, setByDevbox := os.LookupEnv(devboxSetPrefix + key); setByDevbox {
+		return "", false
+	}
+	return os.LookupEnv(key)
+}
+
+// ExplainEnv returns every value assigned to key by the env_from layers, the
+// process environment, devbox.json and local secrets, from lowest to highest
+// precedence. The last entry is the value in effect. Values decrypted from a
+// SOPS file, and devbox.json values that include them, are marked as
+// sensitive.
+func (d *Devbox) ExplainEnv(ctx context.Context, key string) ([]dotenv.Origin, error) {
+	env, err := d.loadDotenv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	origins := env.Origins(key)
+
+	if raw, ok := d.cfg.Env()[key]; ok {
+		// Expand against the same environment as configEnvs so the
+		// explained value matches the one in the shell.
+		expandFrom, _, err := d.baseEnv(ctx, true /*usePrintDevEnvCache*/)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(expandFrom, env.Values())
+		expanded := conf.OSExpandEnvMap(
+			map[string]string{key: raw}, expandFrom, d.projectDir,
+		)
+		origins = append(origins, dotenv.Origin{
+			Layer:     configEnvLayer,
+			Raw:       raw,
+			Value:     expanded[key],
+			Sensitive: env.ContainsSensitive(expanded[key]),
+		})
+	} else if len(origins) == 0 {
+		if v, ok := d.userProcessEnv(key); ok {
+			origins = append(origins, dotenv.Origin{
+				Layer: dotenv.ProcessEnvLayer,
+				Raw:   v,
+				Value: v,
+			})
+		}
+	}
+
+	local, err := d.loadLocalSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range local.Origins(key) {
+		o.Sensitive = true
+		origins = append(origins, o)
+	}
+	return origins, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/dotenv"
+)
+
+func TestDecodeEnvFileYAML(t *testing.T) {
+	data := []byte("HOST: localhost\nPORT: 5432\nDEBUG: true\nLIST: [a, b]\nEMPTY:\n")
+	got, sensitive, err := decodeEnvFile(context.Background(), "env.yaml", data)
+	require.NoError(t, err)
+	require.False(t, sensitive)
+
+	env := parseEnv(t, got)
+	require.Equal(t, map[string]string{
+		"HOST":  "localhost",
+		"PORT":  "5432",
+		"DEBUG": "true",
+		"LIST":  `["a","b"]`,
+		"EMPTY": "",
+	}, env)
+}
+
+func TestDecodeEnvFileSOPS(t *testing.T) {
+	// Fake sops CLI that "decrypts" by printing a fixed plaintext.
+	bin := t.TempDir()
+	script := "#!/bin/sh\nprintf 'PASSWORD: hunter2\\n'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sops"), []byte(script), 0o755))
+	t.Setenv("PATH", bin)
+
+	data := []byte("PASSWORD: ENC[AES256_GCM,data:abc]\nsops:\n    version: 3.8.1\n")
+	got, sensitive, err := decodeEnvFile(context.Background(), "secrets.enc.yaml.local", data)
+	require.NoError(t, err)
+	require.True(t, sensitive)
+	require.Equal(t, map[string]string{"PASSWORD": "hunter2"}, parseEnv(t, got))
+}
+
+func parseEnv(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	vars, err := dotenv.Parse(bytes.NewReader(data), nil)
+	require.NoError(t, err)
+	env := map[string]string{}
+	for _, v := range vars {
+		env[v.Key] = v.Value
+	}
+	return env
+}
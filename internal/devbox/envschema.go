@@ -0,0 +1,89 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// EnvProblem is an env var that doesn't match its declaration in env_schema.
+type EnvProblem struct {
+	Name    string
+	Spec    *configfile.EnvVarSpec
+	Problem string
+}
+
+// EnvSchema returns the env vars declared in env_schema by devbox.json and
+// its plugins.
+func (d *Devbox) EnvSchema() map[string]*configfile.EnvVarSpec {
+	return d.cfg.EnvSchema()
+}
+
+// CheckEnvSchema returns the env vars in the schema that are missing from
+// env or have an invalid value, sorted by name.
+func (d *Devbox) CheckEnvSchema(env map[string]string) []EnvProblem {
+	schema := d.cfg.EnvSchema()
+	names := lo.Keys(schema)
+	slices.Sort(names)
+
+	var problems []EnvProblem
+	for _, name := range names {
+		spec := schema[name]
+		value, ok := env[name]
+		problem := ""
+		if ok && value != "" {
+			problem = spec.Check(value)
+		} else if spec.Required {
+			problem = "is required but not set"
+		}
+		if problem != "" {
+			problems = append(problems, EnvProblem{Name: name, Spec: spec, Problem: problem})
+		}
+	}
+	return problems
+}
+
+// warnEnvSchema tells the user which env vars don't match the schema and
+// where they can be set. Values aren't printed, since they may be secrets.
+func (d *Devbox) warnEnvSchema(w io.Writer, env map[string]string) {
+	problems := d.CheckEnvSchema(env)
+	if len(problems) == 0 {
+		return
+	}
+
+	ux.Fwarning(w, "Some environment variables don't match env_schema in devbox.json:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range problems {
+		fmt.Fprintf(tw, "  %s\t%s", p.Name, p.Problem)
+		if p.Spec.Description != "" {
+			fmt.Fprintf(tw, "\t(%s)", p.Spec.Description)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "Set them %s.\n\n", d.envSetHint())
+}
+
+// envSetHint describes where the user can set an env var for this project.
+func (d *Devbox) envSetHint() string {
+	places := []string{"in the env section of devbox.json"}
+	if d.cfg.Root.IsEnvFromFile() {
+		places = append(places, "in "+d.cfg.Root.EnvFrom+".local")
+	}
+	if !d.pure {
+		places = append(places, "by exporting them before starting the shell")
+	}
+	if len(places) == 1 {
+		return places[0]
+	}
+	return strings.Join(places[:len(places)-1], ", ") + " or " + places[len(places)-1]
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// checkFrozenLockfile checks that devbox.lock pins every package in
+// devbox.json to an immutable reference, so that installing can't resolve
+// anything over the network or change the lockfile. It then freezes the
+// lockfile to make sure nothing else changes it during the install.
+func (d *Devbox) checkFrozenLockfile() error {
+	if !fileutil.Exists(filepath.Join(d.projectDir, "devbox.lock")) {
+		return usererr.New("devbox.lock doesn't exist. Run `devbox install` without --frozen to create it.")
+	}
+
+	names := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	var problems []string
+	for _, name := range names {
+		if problem := frozenPackageProblem(d.lockfile, name); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	stale := lo.Without(lo.Keys(d.lockfile.Packages), names...)
+	slices.Sort(stale)
+	for _, name := range stale {
+		problems = append(problems, fmt.Sprintf("devbox.lock has an entry for %s, which isn't in devbox.json", name))
+	}
+	if len(problems) > 0 {
+		return usererr.New(
+			"devbox.lock doesn't match devbox.json:\n  %s\n\n"+
+				"Run `devbox install` without --frozen to update devbox.lock, and commit it.",
+			strings.Join(problems, "\n  "),
+		)
+	}
+
+	d.lockfile.Freeze()
+	return nil
+}
+
+func frozenPackageProblem(lockfile *lock.File, name string) string {
+	if pkgtype.IsFlake(name) {
+		// Flakes are pinned by their lock entry, which resolving needs
+		// even if devbox.json pins them too. Local paths aren't locked.
+		raw := name
+		if lock.IsLockableFlake(name) {
+			locked := lockfile.Get(name)
+			if locked == nil || locked.Resolved == "" {
+				return fmt.Sprintf("%s isn't in devbox.lock", name)
+			}
+			raw = locked.Resolved
+		}
+		if installable, err := flake.ParseInstallable(raw); err == nil && !isPinnedRef(installable.Ref) {
+			return fmt.Sprintf("%s isn't pinned to a revision", name)
+		}
+		return ""
+	}
+	if lock.IsLegacyPackage(name) {
+		// These resolve to the nixpkgs commit in devbox.json, which doesn't
+		// need the network.
+		return ""
+	}
+
+	locked := lockfile.Get(name)
+	if locked == nil {
+		return fmt.Sprintf("%s isn't in devbox.lock", name)
+	}
+	if pkgtype.IsRunX(name) {
+		return ""
+	}
+	installable, err := flake.ParseInstallable(locked.Resolved)
+	if err != nil || !isPinnedRef(installable.Ref) {
+		return fmt.Sprintf("%s is locked to %s, which isn't pinned to a revision", name, locked.Resolved)
+	}
+	return ""
+}
+
+// isPinnedRef reports whether a flake reference always refers to the same
+// source. Local paths are part of the project, so they count as pinned.
+func isPinnedRef(ref flake.Ref) bool {
+	switch ref.Type {
+	case flake.TypePath:
+		return true
+	case flake.TypeGitHub, flake.TypeGit:
+		return ref.Rev != ""
+	default:
+		return false
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestFrozenPackageProblem(t *testing.T) {
+	lockfile := &lock.File{Packages: map[string]*lock.Package{
+		"go@1.22": {
+			Resolved: "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#go",
+			Version:  "1.22.1",
+		},
+		"hello@latest": {
+			Resolved: "github:NixOS/nixpkgs/nixpkgs-unstable#hello",
+			Version:  "2.12.1",
+		},
+		"github:numtide/flake-utils#lib": {
+			Resolved: "github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a?narHash=sha256-abc%3D#lib",
+			Source:   "flake",
+		},
+		"github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a#lib": {Source: "flake"},
+		"runx:golangci/golangci-lint@latest": {
+			Resolved: "golangci/golangci-lint@v1.59.1",
+			Version:  "v1.59.1",
+		},
+	}}
+
+	tests := []struct {
+		pkg     string
+		problem bool
+	}{
+		{"go@1.22", false},
+		{"hello@latest", true},
+		{"python@3.12", true},
+		{"runx:golangci/golangci-lint@latest", false},
+		{"ripgrep", false},
+		{"github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a", true},
+		{"github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a#lib", true},
+		{"github:numtide/flake-utils", true},
+		{"github:numtide/flake-utils#lib", false},
+		{"nixpkgs#hello", true},
+		{"path:./my-flake#hello", false},
+	}
+	for _, test := range tests {
+		t.Run(test.pkg, func(t *testing.T) {
+			problem := frozenPackageProblem(lockfile, test.pkg)
+			if test.problem {
+				require.NotEmpty(t, problem)
+			} else {
+				require.Empty(t, problem)
+			}
+		})
+	}
+}
+
+func TestFrozenLicensePolicy(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	eval := evalLicenses
+	t.Cleanup(func() { evalLicenses = eval })
+	evalLicenses = func(context.Context, string) ([]string, error) {
+		return []string{"GPL-3.0-or-later"}, nil
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{
+		"packages": ["hello@2.12.1"],
+		"license_policy": {"deny": ["GPL-3.0-or-later"], "enforcement": "block"}
+	}`), 0o644))
+	lockfile := []byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "hello@2.12.1": {
+      "last_modified": "2024-03-21T09:22:22Z",
+      "resolved": "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+      "source": "devbox-search",
+      "version": "2.12.1"
+    }
+  }
+}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.lock"), lockfile, 0o644))
+	d, err := Open(&devopt.Opts{Dir: dir, Stderr: io.Discard})
+	require.NoError(t, err)
+	require.NoError(t, d.checkFrozenLockfile())
+
+	err = d.enforceLicensePolicy(context.Background())
+	require.ErrorContains(t, err, "hello@2.12.1 violates the license policy",
+		"a frozen lockfile doesn't stop the policy from blocking the package")
+	require.NoError(t, d.lockfile.Save(), "the licenses aren't saved to a frozen lockfile")
+	data, err := os.ReadFile(filepath.Join(dir, "devbox.lock"))
+	require.NoError(t, err)
+	require.Equal(t, string(lockfile), string(data))
+
+	d.cfg.Root.LicensePolicy.Enforcement = ""
+	var stderr bytes.Buffer
+	d.stderr = &stderr
+	require.NoError(t, d.enforceLicensePolicy(context.Background()))
+	require.Contains(t, stderr.String(), "hello@2.12.1 violates the license policy")
+}
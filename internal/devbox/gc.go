@@ -0,0 +1,191 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// GCReport is what devbox gc removed, or would remove with --dry-run.
+type GCReport struct {
+	Generations []nix.Generation `json:"generations"`
+	// StorePaths are the store paths that only the removed generations
+	// kept alive.
+	StorePaths []string `json:"store_paths"`
+	// Size is the size of StorePaths in bytes.
+	Size   int64 `json:"size"`
+	DryRun bool  `json:"dry_run"`
+}
+
+// GC removes the old generations of the profiles that devbox created, and
+// then deletes the store paths that only those generations kept alive. Unlike
+// nix store gc, it leaves alone every store path that was already garbage or
+// that another root still uses, so it's safe to run next to other users of
+// Nix.
+func GC(ctx context.Context, opts devopt.GCOpts) (*GCReport, error) {
+	profiles, err := gcProfiles(opts.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+	var gens []nix.Generation
+	for _, profile := range profiles {
+		g, err := nix.ProfileGenerations(profile)
+		if err != nil {
+			return nil, err
+		}
+		gens = append(gens, g...)
+	}
+
+	var cutoff time.Time
+	if opts.OlderThan > 0 {
+		cutoff = time.Now().Add(-opts.OlderThan)
+	}
+	report := &GCReport{Generations: generationsToRemove(gens, cutoff), DryRun: opts.DryRun}
+	if len(report.Generations) == 0 {
+		return report, nil
+	}
+
+	report.StorePaths, err = unreachableAfterRemoving(ctx, report.Generations)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.StorePaths) > 0 {
+		infos, err := nix.PathInfos(ctx, report.StorePaths...)
+		if err != nil {
+			return nil, err
+		}
+		report.Size = lo.SumBy(infos, func(info nix.PathInfo) int64 { return info.NarSize })
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// Nix only deletes paths that no root reaches, so the links go first. If
+	// deleting fails, they're put back so that the generations aren't lost
+	// while their store paths are still there.
+	var removed []nix.Generation
+	restore := func() {
+		for _, gen := range removed {
+			if err := os.Symlink(gen.Path, gen.Link); err != nil {
+				debug.Log("gc: restoring %s: %v", gen.Link, err)
+			}
+		}
+	}
+	for _, gen := range report.Generations {
+		if err := os.Remove(gen.Link); err != nil && !errors.Is(err, os.ErrNotExist) {
+			restore()
+			return nil, errors.WithStack(err)
+		}
+		removed = append(removed, gen)
+	}
+	if err := nix.DeleteStorePaths(ctx, report.StorePaths...); err != nil {
+		restore()
+		return nil, err
+	}
+	return report, nil
+}
+
+// gcProfiles returns the profiles that devbox created: the project's, the
+// global one and the one devbox installs its own tools to.
+func gcProfiles(projectDir string) ([]string, error) {
+	var profiles []string
+	if projectDir != "" {
+		profiles = append(profiles, filepath.Join(projectDir, nix.ProfilePath))
+	}
+	global, err := GlobalDataPath()
+	if err != nil {
+		return nil, err
+	}
+	util, err := utilityNixProfilePath()
+	if err != nil {
+		return nil, err
+	}
+	return append(profiles, filepath.Join(global, nix.ProfilePath), util), nil
+}
+
+// generationsToRemove returns the generations that aren't current and were
+// created before cutoff. A zero cutoff removes every generation that isn't
+// current.
+func generationsToRemove(gens []nix.Generation, cutoff time.Time) []nix.Generation {
+	return lo.Filter(gens, func(gen nix.Generation, _ int) bool {
+		return !gen.Current && (cutoff.IsZero() || gen.Created.Before(cutoff))
+	})
+}
+
+// unreachableAfterRemoving returns the store paths in the closures of gens that
+// no other garbage collector root reaches.
+func unreachableAfterRemoving(ctx context.Context, gens []nix.Generation) ([]string, error) {
+	roots, err := nix.GCRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	removed := map[string]bool{}
+	for _, gen := range gens {
+		removed[canonicalLink(gen.Link)] = true
+	}
+	var kept []string
+	for _, root := range roots {
+		if !removed[canonicalLink(root.Link)] {
+			kept = append(kept, root.Path)
+		}
+	}
+
+	closure, err := nix.StorePathClosure(ctx, lo.Uniq(lo.Map(gens, func(gen nix.Generation, _ int) string { return gen.Path }))...)
+	if err != nil {
+		return nil, err
+	}
+	var keptClosure []string
+	if len(kept) > 0 {
+		keptClosure, err = nix.StorePathClosure(ctx, lo.Uniq(kept)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keep := lo.SliceToMap(keptClosure, func(path string) (string, bool) { return path, true })
+	return lo.Reject(closure, func(path string, _ int) bool { return keep[path] }), nil
+}
+
+// canonicalLink resolves the symlinks in the directory of link, but not link
+// itself, so that a generation is recognized in the roots that Nix prints
+// even if the project is reached through a symlink.
+func canonicalLink(link string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Dir(link))
+	if err != nil {
+		debug.Log("gc: resolving %s: %v", link, err)
+		return link
+	}
+	return filepath.Join(dir, filepath.Base(link))
+}
+
+// PrintGC writes what report removed.
+func PrintGC(w io.Writer, report *GCReport) {
+	if len(report.Generations) == 0 {
+		fmt.Fprintln(w, "There are no old profile generations to remove.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tGENERATION\tCREATED")
+	for _, gen := range report.Generations {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", gen.Profile, gen.Number, gen.Created.Format(time.DateTime))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	verb := lo.Ternary(report.DryRun, "Would remove", "Removed")
+	fmt.Fprintf(w, "%s %d generation(s) and %d store path(s), freeing %.1f MiB.\n",
+		verb, len(report.Generations), len(report.StorePaths), float64(report.Size)/(1<<20))
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestGenerationsToRemove(t *testing.T) {
+	now := time.Now()
+	gens := []nix.Generation{
+		{Number: 1, Created: now.Add(-60 * 24 * time.Hour)},
+		{Number: 2, Created: now.Add(-40 * 24 * time.Hour), Current: true},
+		{Number: 3, Created: now.Add(-10 * 24 * time.Hour)},
+		{Number: 4, Created: now.Add(-time.Hour)},
+	}
+	numbers := func(gens []nix.Generation) []int {
+		return lo.Map(gens, func(gen nix.Generation, _ int) int { return gen.Number })
+	}
+
+	require.Equal(t, []int{1, 3, 4}, numbers(generationsToRemove(gens, time.Time{})))
+	require.Equal(t, []int{1, 3}, numbers(generationsToRemove(gens, now.Add(-24*time.Hour))))
+	require.Equal(t, []int{1}, numbers(generationsToRemove(gens, now.Add(-30*24*time.Hour))))
+}
@@ -32,15 +32,23 @@ type Options struct {
 	Path           string
 	RootUser       bool
 	IsDevcontainer bool
+	// Codespaces generates a devcontainer.json for GitHub Codespaces, which
+	// installs the packages during prebuilds.
+	Codespaces     bool
 	Pkgs           []string
 	LocalFlakeDirs []string
 }
 
 type devcontainerObject struct {
-	Name           string          `json:"name"`
-	Build          *build          `json:"build"`
-	Customizations *customizations `json:"customizations"`
-	RemoteUser     string          `json:"remoteUser"`
+	Name                 string          `json:"name"`
+	Build                *build          `json:"build"`
+	WorkspaceMount       string          `json:"workspaceMount,omitempty"`
+	WorkspaceFolder      string          `json:"workspaceFolder,omitempty"`
+	Mounts               []string        `json:"mounts"`
+	OnCreateCommand      string          `json:"onCreateCommand,omitempty"`
+	UpdateContentCommand string          `json:"updateContentCommand,omitempty"`
+	Customizations       *customizations `json:"customizations"`
+	RemoteUser           string          `json:"remoteUser"`
 }
 
 type build struct {
@@ -179,6 +187,14 @@ func (g *Options) getDevcontainerContent() *devcontainerObject {
 			Dockerfile: "./Dockerfile",
 			Context:    "..",
 		},
+		// Mount the project where the Dockerfile installed it, so that the
+		// installed packages match the mounted devbox.lock. Codespaces
+		// ignores this and mounts it in /workspaces.
+		WorkspaceMount:  "source=${localWorkspaceFolder},target=/code,type=bind",
+		WorkspaceFolder: "/code",
+		Mounts: []string{
+			"source=devbox-cache-${devcontainerId},target=/home/devbox/.cache,type=volume",
+		},
 		Customizations: &customizations{
 			Vscode: &vscode{
 				Settings: map[string]any{
@@ -194,6 +210,21 @@ func (g *Options) getDevcontainerContent() *devcontainerObject {
 	}
 	if g.RootUser {
 		devcontainerContent.RemoteUser = "root"
+		devcontainerContent.Mounts = []string{
+			"source=devbox-cache-${devcontainerId},target=/root/.cache,type=volume",
+		}
+	}
+	if g.Codespaces {
+		// Codespaces always mounts the repository in /workspaces, and
+		// snapshots the container after onCreateCommand and
+		// updateContentCommand run in a prebuild. Installing there
+		// means codespaces start with the packages of the prebuilt
+		// commit's devbox.lock, not only the ones baked into the image.
+		devcontainerContent.Name = "Devbox Codespace"
+		devcontainerContent.WorkspaceMount = ""
+		devcontainerContent.WorkspaceFolder = ""
+		devcontainerContent.OnCreateCommand = "devbox install"
+		devcontainerContent.UpdateContentCommand = "devbox install"
 	}
 
 	// match only python3 or python3xx as package names
@@ -206,7 +237,8 @@ func (g *Options) getDevcontainerContent() *devcontainerObject {
 		if py3pattern.MatchString(pkg) {
 			// Setup python3 interpreter path to devbox in the container
 			devcontainerContent.Customizations.Vscode.Settings = map[string]any{
-				"python.defaultInterpreterPath": "/code/.devbox/nix/profile/default/bin/python3",
+				"python.defaultInterpreterPath": cmp.Or(devcontainerContent.WorkspaceFolder, "${containerWorkspaceFolder}") +
+					"/.devbox/nix/profile/default/bin/python3",
 			}
 			// add python extension if a python3 package is installed
 			devcontainerContent.Customizations.Vscode.Extensions = append(devcontainerContent.Customizations.Vscode.Extensions, "ms-python.python")
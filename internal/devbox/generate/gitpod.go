@@ -0,0 +1,49 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package generate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"text/template"
+)
+
+// GitpodFiles maps the files that CreateGitpod writes to the project
+// directory to their templates.
+var GitpodFiles = map[string]string{
+	".gitpod.yml":        "gitpod.yml.tmpl",
+	".gitpod.Dockerfile": "gitpod.Dockerfile.tmpl",
+}
+
+// gitpodNixCache is a binary cache in Gitpod's /workspace directory, which
+// is the only one that prebuilds persist. The prebuild's init task copies
+// the installed packages to it, so that workspaces substitute them instead
+// of downloading or building them again.
+const gitpodNixCache = "/workspace/.devbox-nix-cache"
+
+// CreateGitpod writes a .gitpod.yml that installs the packages during
+// prebuilds, and the .gitpod.Dockerfile of its workspace image.
+func (g *Options) CreateGitpod(ctx context.Context) error {
+	defer trace.StartRegion(ctx, "createGitpod").End()
+
+	t := template.Must(template.ParseFS(tmplFS, "tmpl/gitpod.yml.tmpl", "tmpl/gitpod.Dockerfile.tmpl"))
+	for name, tmpl := range GitpodFiles {
+		file, err := os.Create(filepath.Join(g.Path, name))
+		if err != nil {
+			return err
+		}
+		err = t.ExecuteTemplate(file, tmpl, map[string]any{
+			"NixCache": gitpodNixCache,
+		})
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// historyFile is an append-only log of changes to the project's environment,
+// one JSON HistoryEntry per line.
+const historyFile = ".devbox/history.jsonl"
+
+// Kinds of environment changes.
+const (
+	ChangePackage = "package"
+	ChangePlugin  = "plugin"
+	ChangeEnv     = "env"
+)
+
+// Operations of environment changes.
+const (
+	OpAdded   = "added"
+	OpRemoved = "removed"
+	OpChanged = "changed"
+)
+
+// HistoryEntry records the changes one devbox command made to the project's
+// environment.
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	// Action is the command that made the changes, such as "add", or "edit"
+	// for changes made to devbox.json outside of devbox.
+	Action  string   `json:"action"`
+	Changes []Change `json:"changes"`
+
+	// State is the environment after the changes, which the next entry is
+	// compared with.
+	State historyState `json:"state"`
+}
+
+// Change is a package, plugin or env var that was added, removed or changed.
+// Before and After are the versions of packages and the values of env vars.
+type Change struct {
+	Kind   string `json:"kind"`
+	Op     string `json:"op"`
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+type historyState struct {
+	Packages map[string]string `json:"packages"`
+	Plugins  []string          `json:"plugins,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+func (d *Devbox) historyPath() string {
+	return filepath.Join(d.projectDir, historyFile)
+}
+
+func (d *Devbox) historyState() historyState {
+	state := historyState{
+		Packages: map[string]string{},
+		Plugins:  slices.Clone(d.cfg.Root.Include),
+		Env:      maps.Clone(d.cfg.Root.Env),
+	}
+	for _, pkg := range d.cfg.Root.TopLevelPackages() {
+		version := pkg.Version
+		if locked := d.lockfile.Get(pkg.VersionedName()); locked != nil && locked.Version != "" {
+			version = locked.Version
+		}
+		state.Packages[pkg.Name] = version
+	}
+	return state
+}
+
+func diffHistoryStates(before, after historyState) []Change {
+	var changes []Change
+	diffMaps := func(kind string, before, after map[string]string) {
+		names := lo.Uniq(append(lo.Keys(before), lo.Keys(after)...))
+		slices.Sort(names)
+		for _, name := range names {
+			b, inBefore := before[name]
+			a, inAfter := after[name]
+			op := OpChanged
+			switch {
+			case !inBefore:
+				op = OpAdded
+			case !inAfter:
+				op = OpRemoved
+			case a == b:
+				continue
+			}
+			changes = append(changes, Change{Kind: kind, Op: op, Name: name, Before: b, After: a})
+		}
+	}
+	diffMaps(ChangePackage, before.Packages, after.Packages)
+
+	removed, added := lo.Difference(before.Plugins, after.Plugins)
+	for _, plugin := range removed {
+		changes = append(changes, Change{Kind: ChangePlugin, Op: OpRemoved, Name: plugin})
+	}
+	for _, plugin := range added {
+		changes = append(changes, Change{Kind: ChangePlugin, Op: OpAdded, Name: plugin})
+	}
+
+	diffMaps(ChangeEnv, before.Env, after.Env)
+	return changes
+}
+
+// recordHistory appends the changes to the environment since the last entry
+// in the history to it. Failing to record history doesn't fail the command
+// that made the changes.
+func (d *Devbox) recordHistory(action string) {
+	if d.isGlobal() {
+		return
+	}
+	if err := d.appendHistory(action); err != nil {
+		debug.Log("history: %v", err)
+	}
+}
+
+func (d *Devbox) appendHistory(action string) error {
+	last, err := d.lastHistoryEntry()
+	if err != nil {
+		return err
+	}
+	state := d.historyState()
+	previous := historyState{}
+	if last != nil {
+		previous = last.State
+	} else {
+		// Start the history with the environment as it was before it was
+		// recorded.
+		action = "baseline"
+	}
+	changes := diffHistoryStates(previous, state)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	entry := HistoryEntry{
+		Time:    time.Now().UTC(),
+		User:    currentUsername(),
+		Action:  action,
+		Changes: changes,
+		State:   state,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return redact.Errorf("encode history entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(d.historyPath()), 0o755); err != nil {
+		return redact.Errorf("create history: %w", err)
+	}
+	f, err := os.OpenFile(d.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return redact.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return redact.Errorf("write history: %w", err)
+	}
+	return nil
+}
+
+func (d *Devbox) lastHistoryEntry() (*HistoryEntry, error) {
+	entries, err := d.History()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[len(entries)-1], nil
+}
+
+// History returns the recorded changes to the project's environment, oldest
+// first.
+func (d *Devbox) History() ([]HistoryEntry, error) {
+	f, err := os.Open(d.historyPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, redact.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		entry := HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip lines that were only partially written.
+			debug.Log("history: skipping invalid entry: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, redact.Errorf("read history: %w", err)
+	}
+	return entries, nil
+}
+
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestRecordHistory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	lockfile := &lock.File{Packages: map[string]*lock.Package{
+		"go@latest": {Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.22.1"},
+	}}
+	open := func(config string) *Devbox {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(config), 0o644))
+		cfg, err := devconfig.Open(dir)
+		require.NoError(t, err)
+		return &Devbox{cfg: cfg, projectDir: dir, lockfile: lockfile}
+	}
+
+	d := open(`{"packages": ["go@latest"], "env": {"FOO": "1"}}`)
+	d.recordHistory("add")
+	d.recordHistory("add")
+	entries, err := d.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "unchanged environments aren't recorded")
+	require.Equal(t, "baseline", entries[0].Action)
+	require.Equal(t, []Change{
+		{Kind: ChangePackage, Op: OpAdded, Name: "go", After: "1.22.1"},
+		{Kind: ChangeEnv, Op: OpAdded, Name: "FOO", After: "1"},
+	}, entries[0].Changes)
+
+	lockfile.Packages["go@latest"].Version = "1.23.0"
+	lockfile.Packages["ripgrep@14"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#ripgrep", Version: "14.1.0"}
+	d = open(`{
+		"packages": ["go@latest", "ripgrep@14"],
+		"env": {"FOO": "2"},
+		"include": ["plugin:nginx"]
+	}`)
+	d.recordHistory("update")
+	entries, err = d.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "update", entries[1].Action)
+	require.Equal(t, []Change{
+		{Kind: ChangePackage, Op: OpChanged, Name: "go", Before: "1.22.1", After: "1.23.0"},
+		{Kind: ChangePackage, Op: OpAdded, Name: "ripgrep", After: "14.1.0"},
+		{Kind: ChangePlugin, Op: OpAdded, Name: "plugin:nginx"},
+		{Kind: ChangeEnv, Op: OpChanged, Name: "FOO", Before: "1", After: "2"},
+	}, entries[1].Changes)
+
+	d = open(`{"packages": ["go@latest"]}`)
+	d.recordHistory("rm")
+	entries, err = d.History()
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Kind: ChangePackage, Op: OpRemoved, Name: "ripgrep", Before: "14.1.0"},
+		{Kind: ChangePlugin, Op: OpRemoved, Name: "plugin:nginx"},
+		{Kind: ChangeEnv, Op: OpRemoved, Name: "FOO", Before: "2"},
+	}, entries[2].Changes)
+}
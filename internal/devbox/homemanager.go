@@ -0,0 +1,223 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// HomeManagerPackage is an element of a Home Manager home.packages list.
+type HomeManagerPackage struct {
+	// Attr is the package's attribute path in nixpkgs, such as "nodejs_20"
+	// or "nodePackages.pnpm".
+	Attr string
+	// Expr is set instead of Attr when the element is an expression, such
+	// as (python3.withPackages (ps: [ ps.requests ])).
+	Expr string
+	Line int
+}
+
+var homePackagesAttr = regexp.MustCompile(`\bhome\.packages\s*=`)
+
+// ParseHomeManagerPackages returns the packages in the home.packages lists
+// of a Home Manager configuration. It reads the Nix file without evaluating
+// it, so it only understands lists of package names, such as
+//
+//	home.packages = with pkgs; [ git ripgrep ] ++ [ pkgs.nodejs_20 ];
+//
+// Conditional lists, such as lib.optionals, are imported as if their
+// condition were true.
+func ParseHomeManagerPackages(data []byte) ([]HomeManagerPackage, error) {
+	src := stripNixComments(string(data))
+	matches := homePackagesAttr.FindAllStringIndex(src, -1)
+	if len(matches) == 0 {
+		return nil, usererr.New("The Home Manager configuration doesn't set home.packages.")
+	}
+	var pkgs []HomeManagerPackage
+	for _, match := range matches {
+		pkgs = append(pkgs, parseNixPackageLists(src, match[1])...)
+	}
+	return pkgs, nil
+}
+
+// stripNixComments replaces comments with spaces, keeping newlines so that
+// line numbers don't change.
+func stripNixComments(src string) string {
+	out := []byte(src)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+		case out[i] == '"':
+			inString = true
+		case out[i] == '#':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			for j := i; j < i+end+4 && j < len(out); j++ {
+				if out[j] != '\n' {
+					out[j] = ' '
+				}
+			}
+			i += end + 3
+		}
+	}
+	return string(out)
+}
+
+// parseNixPackageLists returns the elements of the lists in the expression
+// that starts at src[start:] and ends at the first semicolon outside of
+// brackets. Semicolons of "with pkgs;" don't end the expression.
+func parseNixPackageLists(src string, start int) []HomeManagerPackage {
+	var pkgs []HomeManagerPackage
+	line := strings.Count(src[:start], "\n") + 1
+	// depth counts brackets of all kinds, and listDepth the depth of the
+	// innermost list, so that elements are only read directly inside one.
+	depth, listDepth := 0, -1
+	var listStack []int
+	for i := start; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == ';' && depth == 0:
+			if !withStatement.MatchString(src[start : i+1]) {
+				return pkgs
+			}
+		case c == '[':
+			listStack = append(listStack, listDepth)
+			depth++
+			listDepth = depth
+		case c == ']':
+			if len(listStack) > 0 {
+				listDepth = listStack[len(listStack)-1]
+				listStack = listStack[:len(listStack)-1]
+			}
+			depth--
+		case c == '(' && depth == listDepth:
+			end := matchingParen(src, i)
+			expr := strings.Join(strings.Fields(src[i:end]), " ")
+			pkgs = append(pkgs, HomeManagerPackage{Expr: expr, Line: line})
+			line += strings.Count(src[i:end], "\n")
+			i = end - 1
+		case c == '(' || c == '{':
+			depth++
+		case c == ')' || c == '}':
+			depth--
+		case depth == listDepth && isNixIdentChar(c):
+			end := i
+			for end < len(src) && (isNixIdentChar(src[end]) || src[end] == '.') {
+				end++
+			}
+			attr := strings.TrimPrefix(src[i:end], "pkgs.")
+			pkgs = append(pkgs, HomeManagerPackage{Attr: attr, Line: line})
+			i = end - 1
+		}
+	}
+	return pkgs
+}
+
+// withStatement matches expressions that so far only have "with" statements,
+// such as "with pkgs;".
+var withStatement = regexp.MustCompile(`^\s*(with\s+[\w.]+\s*;\s*)+$`)
+
+func matchingParen(src string, start int) int {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(src)
+}
+
+func isNixIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c == '\'' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// ImportHomeManager adds the packages in the home.packages list of a Home
+// Manager configuration to devbox.json. It returns how each package was
+// imported.
+func (d *Devbox) ImportHomeManager(ctx context.Context, path string) ([]ImportedTool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to read %s", path)
+	}
+	pkgs, err := ParseHomeManagerPackages(data)
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]ImportedTool, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		imported = append(imported, mapHomeManagerPackage(pkg, searcher.Client().Resolve))
+	}
+	return imported, d.addImportedTools(ctx, imported, path)
+}
+
+// versionedAttr matches attributes of packages that nixpkgs has several
+// versions of, such as nodejs_20, go_1_21 and python311.
+var versionedAttr = regexp.MustCompile(`^([a-zA-Z][a-zA-Z-]*?)([-_]?)([0-9]+(?:_[0-9]+)*)(?:_x)?$`)
+
+// mapHomeManagerPackage maps a home.packages element to a devbox package.
+// Attributes are pinned to the latest version the search service has. If it
+// doesn't have the attribute, versioned attributes such as nodejs_20 are
+// mapped to the closest version of the unversioned package.
+func mapHomeManagerPackage(pkg HomeManagerPackage, resolve resolveFunc) ImportedTool {
+	tool := ToolVersion{Plugin: pkg.Attr, Version: "latest", Line: pkg.Line}
+	if pkg.Attr == "" {
+		tool.Plugin = pkg.Expr
+		return ImportedTool{ToolVersion: tool, Reason: "is a Nix expression, not a package"}
+	}
+
+	resolved, err := resolve(pkg.Attr, "latest")
+	if err == nil {
+		return ImportedTool{ToolVersion: tool, Package: pkg.Attr + "@" + resolved.Version}
+	}
+	if !errors.Is(err, searcher.ErrNotFound) {
+		return ImportedTool{ToolVersion: tool, Reason: fmt.Sprintf("search failed: %v", err)}
+	}
+
+	match := versionedAttr.FindStringSubmatch(pkg.Attr)
+	if match == nil {
+		return ImportedTool{ToolVersion: tool, Reason: "no package has this name"}
+	}
+	name, separator, version := match[1], match[2], strings.ReplaceAll(match[3], "_", ".")
+	if separator == "" && len(version) > 1 {
+		// python311 and php82 squash the major and minor versions.
+		version = version[:1] + "." + version[1:]
+	}
+	result := mapToolVersion(ToolVersion{Plugin: name, Version: version, Line: pkg.Line}, resolve)
+	result.Plugin = pkg.Attr
+	if _, resolvedVersion, _ := strings.Cut(result.Package, "@"); strings.HasPrefix(resolvedVersion, version+".") {
+		// The attribute only has the major or minor version, so any
+		// release of it is a match.
+		result.Reason = ""
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestParseHomeManagerPackages(t *testing.T) {
+	pkgs, err := ParseHomeManagerPackages([]byte(`{ config, pkgs, lib, ... }:
+{
+  home.username = "me";
+  programs.git.enable = true; # not a package
+  home.packages = with pkgs; [
+    git
+    ripgrep # search
+    /* node */ nodejs_20
+    pkgs.nodePackages.pnpm
+    (python3.withPackages (ps: [ ps.requests ]))
+  ] ++ lib.optionals stdenv.isDarwin [ coreutils ];
+  home.stateVersion = "23.11";
+}
+`))
+	require.NoError(t, err)
+	require.Equal(t, []HomeManagerPackage{
+		{Attr: "git", Line: 6},
+		{Attr: "ripgrep", Line: 7},
+		{Attr: "nodejs_20", Line: 8},
+		{Attr: "nodePackages.pnpm", Line: 9},
+		{Expr: "(python3.withPackages (ps: [ ps.requests ]))", Line: 10},
+		{Attr: "coreutils", Line: 11},
+	}, pkgs)
+
+	_, err = ParseHomeManagerPackages([]byte(`{ pkgs, ... }: { programs.git.enable = true; }`))
+	require.Error(t, err)
+}
+
+func TestMapHomeManagerPackage(t *testing.T) {
+	available := map[string]string{
+		"ripgrep@latest": "14.1.0",
+		"go@1.21":        "1.21.9",
+		"python@3.11":    "3.11.9",
+	}
+	resolve := func(name, version string) (*searcher.PackageVersion, error) {
+		if v, ok := available[name+"@"+version]; ok {
+			return &searcher.PackageVersion{PackageInfo: searcher.PackageInfo{Version: v}}, nil
+		}
+		return nil, searcher.ErrNotFound
+	}
+
+	tests := []struct {
+		pkg    HomeManagerPackage
+		want   string
+		reason string
+	}{
+		{HomeManagerPackage{Attr: "ripgrep"}, "ripgrep@14.1.0", ""},
+		{HomeManagerPackage{Attr: "go_1_21"}, "go@1.21.9", ""},
+		{HomeManagerPackage{Attr: "python311"}, "python@3.11.9", ""},
+		{HomeManagerPackage{Attr: "nodejs_20"}, "", "no package named nodejs has version 20"},
+		{HomeManagerPackage{Attr: "unknown"}, "", "no package has this name"},
+		{HomeManagerPackage{Expr: "(foo.override { })"}, "", "is a Nix expression, not a package"},
+	}
+	for _, test := range tests {
+		got := mapHomeManagerPackage(test.pkg, resolve)
+		require.Equal(t, test.want, got.Package, test.pkg)
+		require.Equal(t, test.reason, got.Reason, test.pkg)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os/exec"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// HookPlan is the hooks of a phase, in the order that they run.
+type HookPlan struct {
+	Phase configfile.HookPhase  `json:"phase"`
+	Hooks []devconfig.PhaseHook `json:"hooks"`
+}
+
+// HookPlans returns the hooks of each phase, in the order that the phases
+// first run. Phases without hooks are included, so that the order is clear.
+func (d *Devbox) HookPlans() []HookPlan {
+	plans := make([]HookPlan, 0, len(configfile.HookPhases))
+	for _, phase := range configfile.HookPhases {
+		plans = append(plans, HookPlan{Phase: phase, Hooks: d.cfg.Hooks(phase)})
+	}
+	return plans
+}
+
+// runPostPackageHooks runs the post_package hooks in the project's
+// environment, after its packages changed.
+func (d *Devbox) runPostPackageHooks(ctx context.Context) error {
+	defer debug.FunctionTimer().End()
+
+	hooks := d.cfg.Hooks(configfile.HookPostPackage)
+	if len(hooks) == 0 {
+		return nil
+	}
+	env, err := d.computeEnv(ctx, false /*usePrintDevEnvCache*/)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		cmd := exec.CommandContext(ctx, "sh", "-ec", hook.Commands.String())
+		cmd.Dir = d.projectDir
+		cmd.Env = envir.MapToPairs(env)
+		cmd.Stdout = d.stderr
+		cmd.Stderr = d.stderr
+		err := cmd.Run()
+		if err == nil {
+			continue
+		}
+		switch hook.OnFailure {
+		case configfile.HookFailureIgnore:
+			debug.Log("ignoring failed post_package hook of %s: %v", hook.Source, err)
+		case configfile.HookFailureWarn:
+			ux.Fwarning(d.stderr, "The post_package hook of %s failed: %v\n", hook.Source, err)
+		default:
+			return usererr.WithUserMessage(err, "The post_package hook of %s failed.", hook.Source)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/shellgen"
+)
+
+func TestHookPhases(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{
+		"shell": {
+			"init_hook": ["echo init", "export FROM_INIT=1"],
+			"hooks": {
+				"pre_init": "echo pre_init",
+				"pre_script": "echo pre_script $FROM_INIT"
+			},
+			"scripts": {"hello": "echo hello"}
+		}
+	}`), 0o644)
+	require.NoError(t, err)
+	d, err := Open(&devopt.Opts{Dir: dir, Stderr: os.Stderr})
+	require.NoError(t, err)
+
+	plans := d.HookPlans()
+	require.Len(t, plans, len(configfile.HookPhases))
+	require.Equal(t, configfile.HookPreInit, plans[0].Phase)
+	require.Equal(t, "devbox.json", plans[0].Hooks[0].Source)
+	require.Empty(t, plans[2].Hooks)
+
+	require.NoError(t, shellgen.WriteScriptsToFiles(d))
+	out, err := exec.Command("sh", shellgen.ScriptPath(dir, "hello")).CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.Equal(t, "pre_init\ninit\npre_script 1\nhello\n", string(out))
+
+	// A failing pre_init hook skips the init hooks and the script.
+	err = os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{
+		"shell": {
+			"init_hook": "echo init",
+			"hooks": {"pre_init": "exit 1"},
+			"scripts": {"hello": "echo hello"}
+		}
+	}`), 0o644)
+	require.NoError(t, err)
+	d, err = Open(&devopt.Opts{Dir: dir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	require.NoError(t, shellgen.WriteScriptsToFiles(d))
+	out, err = exec.Command("sh", shellgen.ScriptPath(dir, "hello")).CombinedOutput()
+	require.Error(t, err)
+	require.Equal(t, "Error: the pre_init hook of devbox.json failed.\n", string(out))
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/mattn/go-isatty"
+	"github.com/samber/lo"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+const (
+	// containerImage runs as root so that it can write to the bind-mounted
+	// project regardless of the host user's UID.
+	containerImage      = "jetpackio/devbox-root-user:latest"
+	containerProjectDir = "/code"
+	// containerStoreVolume is shared by all projects, so that packages that
+	// one project installed don't need to be fetched again by another.
+	// Docker copies the image's /nix into the volume when it's created.
+	containerStoreVolume = "devbox-nix-store"
+	containerCacheVolume = "devbox-cache"
+)
+
+// RunInContainer runs a script or command in the project's environment in a
+// Linux container, so that Linux-only packages can run on macOS. The project
+// directory is mounted in the container, which installs the packages locked
+// for Linux into a Nix store volume that's reused across runs.
+//
+// The container's .devbox directory is a volume of its own, since its
+// profiles point at Linux store paths that the host doesn't have.
+func (d *Devbox) RunInContainer(ctx context.Context, cmdName string, cmdArgs []string) error {
+	defer debug.FunctionTimer().End()
+
+	runtime, err := containerRuntime()
+	if err != nil {
+		return err
+	}
+	// The container has a trust store of its own, so the project is
+	// approved here instead.
+	if err := d.ensureTrusted(); err != nil {
+		return err
+	}
+	args := d.containerRunArgs(isatty.IsTerminal(os.Stdin.Fd()), append([]string{cmdName}, cmdArgs...))
+	debug.Log("in-container: %s %v", runtime, args)
+	cmd := exec.CommandContext(ctx, runtime, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return usererr.NewExecError(cmd.Run())
+}
+
+// containerRuntime returns the Docker-compatible CLI that runs containers.
+// DOCKER_HOST makes docker use the Colima VM of `devbox vm`.
+func containerRuntime() (string, error) {
+	for _, runtime := range []string{"docker", "podman"} {
+		if path, err := exec.LookPath(runtime); err == nil {
+			return path, nil
+		}
+	}
+	return "", usererr.New("Running in a container requires docker or podman. " +
+		"Install Docker, or add colima and docker to the project and run `devbox vm start`.")
+}
+
+func (d *Devbox) containerRunArgs(tty bool, cmd []string) []string {
+	args := []string{"run", "--rm", "--interactive"}
+	if tty {
+		args = append(args, "--tty")
+	}
+	args = append(args,
+		"--volume", d.projectDir+":"+containerProjectDir,
+		"--volume", "devbox-state-"+cachehash.Bytes6([]byte(d.projectDir))+":"+containerProjectDir+"/.devbox",
+		"--volume", containerStoreVolume+":/nix",
+		"--volume", containerCacheVolume+":/root/.cache",
+		"--workdir", containerProjectDir,
+		"--env", envir.DevboxTrustAll+"=1",
+	)
+	keys := lo.Keys(d.env)
+	slices.Sort(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k+"="+d.env[k])
+	}
+	args = append(args, containerImage, "devbox", "run")
+	if d.pure {
+		args = append(args, "--pure")
+	}
+	if d.environment != "" && d.environment != "dev" {
+		args = append(args, "--environment", d.environment)
+	}
+	return append(append(args, "--"), cmd...)
+}
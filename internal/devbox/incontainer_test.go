@@ -0,0 +1,24 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerRunArgs(t *testing.T) {
+	d := devboxForTesting(t)
+	d.env = map[string]string{"B": "2", "A": "1"}
+	d.environment = "prod"
+
+	args := strings.Join(d.containerRunArgs(false /*tty*/, []string{"make", "test"}), " ")
+	require.Contains(t, args, "--volume "+d.projectDir+":/code ")
+	require.Contains(t, args, "--volume devbox-nix-store:/nix ")
+	require.Contains(t, args, "--env A=1 --env B=2 ")
+	require.NotContains(t, args, "--tty")
+	require.True(t, strings.HasSuffix(args, containerImage+" devbox run --environment prod -- make test"), args)
+}
@@ -0,0 +1,252 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sync/errgroup"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// defaultInstallConcurrency is how many packages devbox install realizes at
+// the same time, unless DEVBOX_INSTALL_CONCURRENCY says otherwise. Each one
+// is a nix build with its own downloads, so a few are enough to keep the
+// network busy without starving the builds.
+const defaultInstallConcurrency = 4
+
+// installConcurrency returns the maximum number of packages that devbox
+// realizes in the store at the same time.
+func installConcurrency() int {
+	env := os.Getenv(envir.DevboxInstallConcurrency)
+	if env == "" {
+		return defaultInstallConcurrency
+	}
+	n, err := strconv.Atoi(env)
+	if err != nil || n < 1 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxInstallConcurrency, env)
+		return defaultInstallConcurrency
+	}
+	return n
+}
+
+// buildPackagesConcurrently runs a nix build for each package, up to
+// installConcurrency at a time. Nix locks each store path while it's being
+// realized, so packages that share dependencies wait on each other instead of
+// fetching them twice. The output of each build is kept and only shown if the
+// build fails, so that the progress of every package stays readable.
+func (d *Devbox) buildPackagesConcurrently(
+	ctx context.Context,
+	args nix.BuildArgs,
+	packages []*devpkg.Package,
+) error {
+	progress := newInstallProgress(d.stderr, packages)
+	defer progress.stop()
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(installConcurrency())
+	for i, pkg := range packages {
+		group.Go(func() error {
+			installables, err := pkg.Installables()
+			if err != nil {
+				return err
+			}
+			state := installBuilding
+			entry := d.lockfile.Packages[pkg.Raw]
+			if path, _ := installPath(pkg, entry, nix.System()); path == InstallFromStorePath {
+				state = installDownloading
+			}
+			progress.set(i, state)
+
+			var out bytes.Buffer
+			args := args
+			args.AllowInsecure = pkg.HasAllowInsecure()
+			args.Writer = &out
+			args.OnProgress = func(p nix.BuildProgress) { progress.setDetail(i, p.String()) }
+			if err := nix.Build(ctx, &args, installables...); err != nil {
+				if ctx.Err() != nil {
+					// Another package failed first and canceled
+					// this one.
+					progress.set(i, installCanceled)
+					return ctx.Err()
+				}
+				progress.set(i, installFailed)
+				progress.stop()
+				fmt.Fprintf(d.stderr, "%s", out.Bytes())
+				return fmt.Errorf("error installing %s: %w", pkg.Raw, err)
+			}
+			progress.set(i, installDone)
+			return nil
+		})
+	}
+	return group.Wait()
+}
+
+// installState is what devbox install is doing with a package.
+type installState int
+
+const (
+	installWaiting installState = iota
+	installDownloading
+	installBuilding
+	installDone
+	installFailed
+	installCanceled
+)
+
+var installStateNames = map[installState]string{
+	installWaiting:     "waiting",
+	installDownloading: "downloading",
+	installBuilding:    "building",
+	installDone:        "done",
+	installFailed:      "failed",
+	installCanceled:    "canceled",
+}
+
+// installProgress shows the state of each package that's being installed. On
+// a terminal it redraws a line per package in place. Otherwise, or with
+// --debug, it prints a line each time a package changes state.
+type installProgress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	redraw  bool
+	names   []string
+	states  []installState
+	started []time.Time
+	elapsed []time.Duration
+	// details are what Nix last reported about each package that's
+	// downloading or building.
+	details []string
+	// drawn is the number of lines that the last redraw wrote.
+	drawn    int
+	lastDraw time.Time
+	stopped  bool
+}
+
+// minRedrawInterval limits how often Nix's progress reports redraw the lines,
+// since they come in much faster than anyone can read them.
+const minRedrawInterval = 100 * time.Millisecond
+
+func newInstallProgress(w io.Writer, packages []*devpkg.Package) *installProgress {
+	p := &installProgress{
+		w:       w,
+		names:   make([]string, len(packages)),
+		states:  make([]installState, len(packages)),
+		started: make([]time.Time, len(packages)),
+		elapsed: make([]time.Duration, len(packages)),
+		details: make([]string, len(packages)),
+	}
+	for i, pkg := range packages {
+		p.names[i] = pkg.Raw
+	}
+	if f, ok := w.(*os.File); ok && isatty.IsTerminal(f.Fd()) && !debug.IsEnabled() {
+		p.redraw = true
+		p.draw()
+	}
+	return p
+}
+
+// set moves package i to state.
+func (p *installProgress) set(i int, state installState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || p.states[i] == state {
+		return
+	}
+	switch state {
+	case installDownloading, installBuilding:
+		p.started[i] = time.Now()
+	case installDone, installFailed:
+		p.elapsed[i] = time.Since(p.started[i]).Round(time.Second)
+	}
+	p.states[i] = state
+	p.details[i] = ""
+	if p.redraw {
+		p.draw()
+		return
+	}
+	fmt.Fprintf(p.w, "%s: %s\n", p.names[i], p.status(i))
+}
+
+// setDetail shows what Nix reports about package i next to its state. Only
+// the lines on a terminal show it.
+func (p *installProgress) setDetail(i int, detail string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || !p.redraw || p.details[i] == detail {
+		return
+	}
+	p.details[i] = detail
+	if time.Since(p.lastDraw) >= minRedrawInterval {
+		p.draw()
+	}
+}
+
+// stop stops updating the progress, so that other output can follow it.
+func (p *installProgress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+}
+
+func (p *installProgress) status(i int) string {
+	status := installStateNames[p.states[i]]
+	if p.states[i] == installDone || p.states[i] == installFailed {
+		status += " in " + p.elapsed[i].String()
+	}
+	if p.details[i] != "" {
+		status += ": " + p.details[i]
+	}
+	return status
+}
+
+// draw rewrites the lines of the last draw. The caller must hold p.mu.
+func (p *installProgress) draw() {
+	if p.drawn > 0 {
+		// Move the cursor up to the first line of the last draw.
+		fmt.Fprintf(p.w, "\x1b[%dA", p.drawn)
+	}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for i, name := range p.names {
+		fmt.Fprintf(tw, "%s %s\t%s\n", p.symbol(i), name, p.status(i))
+	}
+	tw.Flush()
+	for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+		if len(line) > 0 {
+			// Clear the rest of the line in case the last draw was
+			// longer.
+			fmt.Fprintf(p.w, "\x1b[2K%s", line)
+		}
+	}
+	p.drawn = len(p.names)
+	p.lastDraw = time.Now()
+}
+
+func (p *installProgress) symbol(i int) string {
+	switch p.states[i] {
+	case installDone:
+		return color.GreenString("✓")
+	case installFailed:
+		return color.RedString("✘")
+	case installDownloading, installBuilding:
+		return color.MagentaString("→")
+	default:
+		return " "
+	}
+}
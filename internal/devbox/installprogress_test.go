@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestInstallConcurrency(t *testing.T) {
+	require.Equal(t, defaultInstallConcurrency, installConcurrency())
+	t.Setenv(envir.DevboxInstallConcurrency, "1")
+	require.Equal(t, 1, installConcurrency())
+	t.Setenv(envir.DevboxInstallConcurrency, "0")
+	require.Equal(t, defaultInstallConcurrency, installConcurrency())
+	t.Setenv(envir.DevboxInstallConcurrency, "many")
+	require.Equal(t, defaultInstallConcurrency, installConcurrency())
+}
+
+func TestInstallProgress(t *testing.T) {
+	var out bytes.Buffer
+	progress := newInstallProgress(&out, []*devpkg.Package{{Raw: "go@1.22"}, {Raw: "python@3.12"}})
+	progress.set(0, installDownloading)
+	progress.set(1, installBuilding)
+	progress.set(1, installBuilding)
+	progress.set(0, installDone)
+	progress.set(1, installFailed)
+	progress.stop()
+	progress.set(0, installCanceled)
+
+	require.Equal(t, []string{
+		"go@1.22: downloading",
+		"python@3.12: building",
+		"go@1.22: done in 0s",
+		"python@3.12: failed in 0s",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+}
+
+func TestInstallProgressRedraw(t *testing.T) {
+	var out bytes.Buffer
+	progress := newInstallProgress(&out, []*devpkg.Package{{Raw: "go@1.22"}, {Raw: "python@3.12"}})
+	progress.redraw = true
+	progress.set(0, installDownloading)
+	progress.set(0, installDone)
+
+	// The second draw moves back up over the two lines of the first.
+	require.Equal(t, 1, strings.Count(out.String(), "\x1b[2A"))
+	lines := strings.Split(out.String(), "\x1b[2A")
+	require.Contains(t, lines[1], "go@1.22")
+	require.Contains(t, lines[1], "done in 0s")
+	require.Contains(t, lines[1], "python@3.12  waiting")
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// jetbrainsStateFile records the toolchains that were last written to .idea,
+// so that they're updated when the lockfile changes. Projects without it
+// never have their .idea directory touched.
+const jetbrainsStateFile = ".devbox/jetbrains-toolchains.json"
+
+// Toolchain is a language SDK in the devbox profile that an IDE can use.
+type Toolchain struct {
+	// Kind is one of "go", "jdk", "python" or "node".
+	Kind string `json:"kind"`
+	// Name is the SDK name the IDE shows. JetBrains IDEs look up the
+	// project JDK and Python interpreter by name.
+	Name string `json:"name"`
+	// Home is the SDK directory or interpreter path. Paths in the profile
+	// are kept as is, so that they stay valid when packages are updated.
+	Home string `json:"home"`
+}
+
+// Toolchains returns the language SDKs installed in the project's profile.
+func (d *Devbox) Toolchains() []Toolchain {
+	profile := filepath.Join(d.projectDir, nix.ProfilePath)
+	var toolchains []Toolchain
+	if goroot := filepath.Join(profile, "share", "go"); fileutil.IsDir(goroot) {
+		toolchains = append(toolchains, Toolchain{Kind: "go", Name: "Go (devbox)", Home: goroot})
+	}
+	// The JDK home is the parent of the directory java is in, which the
+	// profile doesn't link to, so it's resolved to the store path.
+	if java, err := filepath.EvalSymlinks(filepath.Join(profile, "bin", "java")); err == nil {
+		home := filepath.Dir(filepath.Dir(java))
+		if fileutil.IsDir(filepath.Join(home, "lib")) {
+			name := "JDK (devbox)"
+			if version := jdkVersion(home); version != "" {
+				name = fmt.Sprintf("JDK %s (devbox)", version)
+			}
+			toolchains = append(toolchains, Toolchain{Kind: "jdk", Name: name, Home: home})
+		}
+	}
+	if python := filepath.Join(profile, "bin", "python3"); fileutil.IsFile(python) {
+		name := "Python (devbox)"
+		if resolved, err := filepath.EvalSymlinks(python); err == nil {
+			if version, ok := strings.CutPrefix(filepath.Base(resolved), "python"); ok && version != "" {
+				name = fmt.Sprintf("Python %s (devbox)", version)
+			}
+		}
+		toolchains = append(toolchains, Toolchain{Kind: "python", Name: name, Home: python})
+	}
+	if node := filepath.Join(profile, "bin", "node"); fileutil.IsFile(node) {
+		toolchains = append(toolchains, Toolchain{Kind: "node", Name: "Node.js (devbox)", Home: node})
+	}
+	return toolchains
+}
+
+// jdkVersion reads JAVA_VERSION from the release file of a JDK.
+func jdkVersion(home string) string {
+	f, err := os.Open(filepath.Join(home, "release"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "JAVA_VERSION="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// GenerateJetBrains installs the project's packages and points the JetBrains
+// project in .idea at the Go SDK, JDK, Python interpreter and Node.js in the
+// devbox profile. The settings are updated whenever the lockfile changes.
+func (d *Devbox) GenerateJetBrains(ctx context.Context) error {
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return err
+	}
+	toolchains := d.Toolchains()
+	if len(toolchains) == 0 {
+		return usererr.New("The project has no Go, JDK, Python or Node.js packages to configure the IDE with.")
+	}
+	if err := d.writeJetBrainsToolchains(toolchains); err != nil {
+		return err
+	}
+	for _, tc := range toolchains {
+		ux.Fsuccess(d.stderr, "Set the %s toolchain to %s\n", tc.Kind, tc.Home)
+	}
+	for _, tc := range toolchains {
+		if tc.Kind == "jdk" || tc.Kind == "python" {
+			ux.Finfo(d.stderr,
+				"If the IDE can't find the SDK %q, add it in Project Structure > SDKs from %s with that name.\n",
+				tc.Name, tc.Home)
+		}
+	}
+	return nil
+}
+
+// syncJetBrains updates the .idea settings of projects that ran
+// `devbox generate jetbrains`, if the toolchains changed.
+func (d *Devbox) syncJetBrains() {
+	data, err := os.ReadFile(filepath.Join(d.projectDir, jetbrainsStateFile))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			debug.Log("jetbrains: %v", err)
+		}
+		return
+	}
+	var previous []Toolchain
+	if err := json.Unmarshal(data, &previous); err != nil {
+		debug.Log("jetbrains: %v", err)
+	}
+	toolchains := d.Toolchains()
+	if slices.Equal(previous, toolchains) {
+		return
+	}
+	if err := d.writeJetBrainsToolchains(toolchains); err != nil {
+		ux.Fwarning(d.stderr, "Unable to update the JetBrains IDE settings: %v\n", err)
+	}
+}
+
+func (d *Devbox) writeJetBrainsToolchains(toolchains []Toolchain) error {
+	ideaDir := filepath.Join(d.projectDir, ".idea")
+	if err := os.MkdirAll(ideaDir, 0o755); err != nil {
+		return redact.Errorf("create .idea: %w", err)
+	}
+
+	var projectSDK *Toolchain
+	for i, tc := range toolchains {
+		switch tc.Kind {
+		case "jdk":
+			projectSDK = &toolchains[i]
+		case "python":
+			if projectSDK == nil {
+				projectSDK = &toolchains[i]
+			}
+		}
+	}
+	err := editIdeaFile(filepath.Join(ideaDir, "misc.xml"), func(doc string) string {
+		if projectSDK == nil {
+			return doc
+		}
+		sdkType := "JavaSDK"
+		if projectSDK.Kind == "python" {
+			sdkType = "Python SDK"
+		}
+		return setIdeaComponent(doc, "ProjectRootManager", [][2]string{
+			{"version", "2"},
+			{"project-jdk-name", projectSDK.Name},
+			{"project-jdk-type", sdkType},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = editIdeaFile(filepath.Join(ideaDir, "workspace.xml"), func(doc string) string {
+		for _, tc := range toolchains {
+			switch tc.Kind {
+			case "go":
+				doc = setIdeaComponent(doc, "GOROOT", [][2]string{{"url", "file://" + tc.Home}})
+			case "node":
+				doc = setIdeaProperty(doc, "nodejs_interpreter_path", tc.Home)
+			}
+		}
+		return doc
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(toolchains, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.projectDir, jetbrainsStateFile), data, 0o644)
+}
+
+const emptyIdeaFile = `<?xml version="1.0" encoding="UTF-8"?>
+<project version="4">
+</project>
+`
+
+// editIdeaFile applies edit to an IDE settings file, creating it if needed.
+func editIdeaFile(path string, edit func(string) string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = []byte(emptyIdeaFile), nil
+	}
+	if err != nil {
+		return redact.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	doc := edit(string(data))
+	if doc == string(data) {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		return redact.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// setIdeaComponent sets attributes on a <component> element, adding it if
+// it's missing. The rest of the file, including the component's other
+// attributes and children, is kept as is, since the IDE owns it.
+func setIdeaComponent(doc, name string, attrs [][2]string) string {
+	re := regexp.MustCompile(`<component\s+name="` + regexp.QuoteMeta(name) + `"[^>]*>`)
+	loc := re.FindStringIndex(doc)
+	if loc == nil {
+		var b strings.Builder
+		fmt.Fprintf(&b, `  <component name="%s"`, html.EscapeString(name))
+		for _, attr := range attrs {
+			fmt.Fprintf(&b, ` %s="%s"`, attr[0], html.EscapeString(attr[1]))
+		}
+		b.WriteString(" />\n")
+		return insertBeforeProjectEnd(doc, b.String())
+	}
+	tag := doc[loc[0]:loc[1]]
+	for _, attr := range attrs {
+		value := html.EscapeString(attr[1])
+		attrRe := regexp.MustCompile(`\s` + regexp.QuoteMeta(attr[0]) + `="[^"]*"`)
+		if attrRe.MatchString(tag) {
+			tag = attrRe.ReplaceAllLiteralString(tag, fmt.Sprintf(` %s="%s"`, attr[0], value))
+			continue
+		}
+		// Add the attribute before the tag's closing "/>" or ">".
+		if rest, ok := strings.CutSuffix(tag, "/>"); ok {
+			tag = fmt.Sprintf(`%s %s="%s" />`, strings.TrimRight(rest, " "), attr[0], value)
+		} else {
+			tag = fmt.Sprintf(`%s %s="%s">`, strings.TrimSuffix(tag, ">"), attr[0], value)
+		}
+	}
+	return doc[:loc[0]] + tag + doc[loc[1]:]
+}
+
+// setIdeaProperty sets a key of the PropertiesComponent in workspace.xml.
+// Recent IDEs store the properties as JSON, and older ones as <property>
+// elements.
+func setIdeaProperty(doc, key, value string) string {
+	re := regexp.MustCompile(`(?s)<component\s+name="PropertiesComponent"(?:\s[^>]*)?(?:/>|>(.*?)</component>)`)
+	loc := re.FindStringSubmatchIndex(doc)
+	if loc == nil {
+		props := map[string]map[string]string{"keyToString": {key: value}}
+		data, _ := json.MarshalIndent(props, "  ", "  ")
+		return insertBeforeProjectEnd(doc,
+			fmt.Sprintf("  <component name=\"PropertiesComponent\"><![CDATA[%s]]></component>\n", data))
+	}
+	body := ""
+	if loc[2] >= 0 {
+		body = doc[loc[2]:loc[3]]
+	}
+	if jsonBody, ok := strings.CutPrefix(strings.TrimSpace(body), "<![CDATA["); ok {
+		jsonBody = strings.TrimSuffix(jsonBody, "]]>")
+		props := map[string]any{}
+		if err := json.Unmarshal([]byte(jsonBody), &props); err != nil {
+			debug.Log("jetbrains: parse PropertiesComponent: %v", err)
+			return doc
+		}
+		keyToString, _ := props["keyToString"].(map[string]any)
+		if keyToString == nil {
+			keyToString = map[string]any{}
+		}
+		keyToString[key] = value
+		props["keyToString"] = keyToString
+		data, _ := json.MarshalIndent(props, "  ", "  ")
+		return doc[:loc[2]] + "<![CDATA[" + string(data) + "]]>" + doc[loc[3]:]
+	}
+
+	property := fmt.Sprintf(`<property name="%s" value="%s" />`, html.EscapeString(key), html.EscapeString(value))
+	propRe := regexp.MustCompile(`<property\s+name="` + regexp.QuoteMeta(key) + `"[^>]*/>`)
+	if propRe.MatchString(body) {
+		body = propRe.ReplaceAllLiteralString(body, property)
+	} else {
+		body = strings.TrimRight(body, " \n") + "\n    " + property + "\n  "
+	}
+	component := `<component name="PropertiesComponent">` + body + `</component>`
+	return doc[:loc[0]] + component + doc[loc[1]:]
+}
+
+func insertBeforeProjectEnd(doc, element string) string {
+	i := strings.LastIndex(doc, "</project>")
+	if i < 0 {
+		return doc + element
+	}
+	return doc[:i] + element + doc[i:]
+}
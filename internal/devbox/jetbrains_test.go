@@ -0,0 +1,94 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestSetIdeaComponent(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<project version="4">
+  <component name="ProjectRootManager" version="2" languageLevel="JDK_17" project-jdk-name="17" project-jdk-type="JavaSDK">
+    <output url="file://$PROJECT_DIR$/out" />
+  </component>
+</project>
+`
+	doc = setIdeaComponent(doc, "ProjectRootManager", [][2]string{
+		{"project-jdk-name", "JDK 21.0.2 (devbox)"},
+		{"project-jdk-type", "JavaSDK"},
+	})
+	doc = setIdeaComponent(doc, "GOROOT", [][2]string{{"url", "file:///p/share/go"}})
+	doc = setIdeaComponent(doc, "GOROOT", [][2]string{{"url", "file:///q/share/go"}, {"extra", "a&b"}})
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<project version="4">
+  <component name="ProjectRootManager" version="2" languageLevel="JDK_17" project-jdk-name="JDK 21.0.2 (devbox)" project-jdk-type="JavaSDK">
+    <output url="file://$PROJECT_DIR$/out" />
+  </component>
+  <component name="GOROOT" url="file:///q/share/go" extra="a&amp;b" />
+</project>
+`, doc)
+}
+
+func TestSetIdeaProperty(t *testing.T) {
+	doc := setIdeaProperty(emptyIdeaFile, "nodejs_interpreter_path", "/p/bin/node")
+	require.Contains(t, doc, `<component name="PropertiesComponent"><![CDATA[{`)
+	require.Contains(t, doc, `"nodejs_interpreter_path": "/p/bin/node"`)
+
+	doc = `<project version="4">
+  <component name="PropertiesComponent"><![CDATA[{
+  "keyToString": {
+    "RunOnceActivity.ShowReadmeOnStart": "true"
+  }
+}]]></component>
+</project>`
+	doc = setIdeaProperty(doc, "nodejs_interpreter_path", "/q/bin/node")
+	require.Contains(t, doc, `"RunOnceActivity.ShowReadmeOnStart": "true"`)
+	require.Contains(t, doc, `"nodejs_interpreter_path": "/q/bin/node"`)
+
+	doc = `<project version="4">
+  <component name="PropertiesComponent">
+    <property name="nodejs_interpreter_path" value="/old/node" />
+  </component>
+</project>`
+	require.Equal(t, `<project version="4">
+  <component name="PropertiesComponent">
+    <property name="nodejs_interpreter_path" value="/q/bin/node" />
+  </component>
+</project>`, setIdeaProperty(doc, "nodejs_interpreter_path", "/q/bin/node"))
+}
+
+func TestToolchains(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "store")
+	profile := filepath.Join(dir, nix.ProfilePath)
+	for _, path := range []string{
+		filepath.Join(store, "jdk", "lib", "openjdk", "bin"),
+		filepath.Join(store, "jdk", "lib", "openjdk", "lib"),
+		filepath.Join(store, "python", "bin"),
+		filepath.Join(profile, "bin"),
+		filepath.Join(profile, "share", "go"),
+	} {
+		require.NoError(t, os.MkdirAll(path, 0o755))
+	}
+	jdk := filepath.Join(store, "jdk", "lib", "openjdk")
+	require.NoError(t, os.WriteFile(filepath.Join(jdk, "bin", "java"), nil, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(jdk, "release"), []byte("JAVA_VERSION=\"21.0.2\"\n"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(jdk, "bin", "java"), filepath.Join(profile, "bin", "java")))
+	python := filepath.Join(store, "python", "bin", "python3.12")
+	require.NoError(t, os.WriteFile(python, nil, 0o755))
+	require.NoError(t, os.Symlink(python, filepath.Join(profile, "bin", "python3")))
+
+	d := &Devbox{projectDir: dir}
+	require.Equal(t, []Toolchain{
+		{Kind: "go", Name: "Go (devbox)", Home: filepath.Join(profile, "share", "go")},
+		{Kind: "jdk", Name: "JDK 21.0.2 (devbox)", Home: jdk},
+		{Kind: "python", Name: "Python 3.12 (devbox)", Home: filepath.Join(profile, "bin", "python3")},
+	}, d.Toolchains())
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tailscale/hujson"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/internal/xdg"
+	"go.jetpack.io/pkg/filecache"
+)
+
+// licenseCacheTTL is how long the licenses that Nix evaluated for a package,
+// including unknown ones, are reused. Installs that can't save them to
+// devbox.lock, because it's frozen or Nix didn't find a license, would
+// otherwise evaluate the package again every time.
+const licenseCacheTTL = 24 * time.Hour
+
+var licenseCache = filecache.New(
+	"devbox/licenses",
+	filecache.WithCacheDir[[]string](xdg.CacheSubpath("")),
+)
+
+// evalLicenses looks up the licenses of an installable with Nix. It's a
+// variable so tests can replace it.
+var evalLicenses = nix.PackageLicenses
+
+// LicenseViolation is a package whose licenses aren't allowed by a license
+// policy.
+type LicenseViolation struct {
+	Package  string
+	Licenses []string
+	Reason   string
+	// Policy is where the policy came from: devbox.json or the path of the
+	// organization's policy file.
+	Policy   string
+	Blocking bool
+}
+
+type sourcedPolicy struct {
+	*configfile.LicensePolicy
+	source string
+}
+
+// orgLicensePolicyPath returns the path of the organization's license
+// policy, which is set with DEVBOX_LICENSE_POLICY or installed in the user's
+// config directory.
+func orgLicensePolicyPath() string {
+	return orgPolicyPath(envir.DevboxLicensePolicy, "license-policy.json")
+}
+
+func loadOrgLicensePolicy() (*configfile.LicensePolicy, error) {
+	policy := &configfile.LicensePolicy{}
+	path := orgLicensePolicyPath()
+	found, err := readOrgPolicy(path, envir.DevboxLicensePolicy, policy)
+	if err != nil || !found {
+		return nil, err
+	}
+	return policy, configfile.ValidateLicensePolicy(policy, path)
+}
+
+func orgPolicyPath(envVar, name string) string {
+	if path := os.Getenv(envVar); path != "" {
+		return path
+	}
+	return xdg.ConfigSubpath(filepath.Join("devbox", name))
+}
+
+// readOrgPolicy reads an organization policy file into policy. It returns
+// false if there's no policy file, which is only an error if the file was set
+// explicitly with envVar.
+func readOrgPolicy(path, envVar string, policy any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv(envVar) == "" {
+		return false, nil
+	}
+	if err != nil {
+		return false, usererr.WithUserMessage(err, "Unable to read the policy in %s", path)
+	}
+	data, err = hujson.Standardize(data)
+	if err != nil {
+		return false, usererr.WithUserMessage(err, "The policy in %s isn't valid JSON", path)
+	}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return false, usererr.WithUserMessage(err, "The policy in %s isn't valid", path)
+	}
+	return true, nil
+}
+
+func (d *Devbox) licensePolicies() ([]sourcedPolicy, error) {
+	var policies []sourcedPolicy
+	org, err := loadOrgLicensePolicy()
+	if err != nil {
+		return nil, err
+	}
+	if org != nil {
+		policies = append(policies, sourcedPolicy{org, orgLicensePolicyPath()})
+	}
+	if d.cfg.Root.LicensePolicy != nil {
+		policies = append(policies, sourcedPolicy{d.cfg.Root.LicensePolicy, "devbox.json"})
+	}
+	return policies, nil
+}
+
+// CheckLicenses returns the packages that violate the project's or the
+// organization's license policy. Licenses are read from devbox.lock. Packages
+// that were locked before licenses were recorded have them looked up with
+// Nix and saved to the lockfile, unless it's frozen.
+func (d *Devbox) CheckLicenses(ctx context.Context) ([]LicenseViolation, error) {
+	defer debug.FunctionTimer().End()
+
+	policies, err := d.licensePolicies()
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+
+	var violations []LicenseViolation
+	for _, pkg := range d.InstallablePackages() {
+		if pkg.IsRunX() {
+			continue
+		}
+		licenses, err := d.packageLicenses(ctx, pkg)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range policies {
+			if policy.IsException(pkg.CanonicalName()) || policy.IsException(pkg.Raw) {
+				continue
+			}
+			if reason := policy.Check(licenses); reason != "" {
+				violations = append(violations, LicenseViolation{
+					Package:  pkg.Raw,
+					Licenses: licenses,
+					Reason:   reason,
+					Policy:   policy.source,
+					Blocking: policy.Blocks(),
+				})
+			}
+		}
+	}
+	return violations, nil
+}
+
+func (d *Devbox) packageLicenses(ctx context.Context, pkg *devpkg.Package) ([]string, error) {
+	locked, err := d.lockfile.ResolveContext(ctx, pkg.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(locked.License) > 0 {
+		return locked.License, nil
+	}
+
+	installable := locked.ResolvedFor(nix.System())
+	if installable == "" {
+		installable = pkg.Raw
+	}
+	licenses, err := licenseCache.GetOrSet(cachehash.Bytes([]byte(installable)), func() ([]string, time.Duration, error) {
+		licenses, err := evalLicenses(ctx, installable)
+		if err != nil {
+			// Treat the license as unknown rather than failing, since
+			// the policy decides whether unknown licenses are
+			// acceptable.
+			debug.Log("license policy: %v", err)
+			return nil, licenseCacheTTL, nil
+		}
+		return licenses, licenseCacheTTL, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(licenses) > 0 && !d.lockfile.IsFrozen() {
+		locked.License = licenses
+	}
+	return licenses, nil
+}
+
+// enforceLicensePolicy warns about packages that violate a license policy,
+// and fails if any policy they violate blocks them.
+func (d *Devbox) enforceLicensePolicy(ctx context.Context) error {
+	violations, err := d.CheckLicenses(ctx)
+	if err != nil {
+		return err
+	}
+
+	var blocked []string
+	for _, v := range violations {
+		msg := fmt.Sprintf("%s violates the license policy in %s: %s", v.Package, v.Policy, v.Reason)
+		if v.Blocking {
+			blocked = append(blocked, msg)
+			continue
+		}
+		ux.Fwarning(d.stderr, "%s\n", msg)
+	}
+	if len(blocked) > 0 {
+		return usererr.New(
+			"%s\n\nTo install these packages anyway, add them to the exceptions of the policy that blocks them.",
+			strings.Join(blocked, "\n"),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/dotenv"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// localSecretsLayer is the name shown for values set with
+// `devbox secrets set --local`, which take precedence over everything else.
+const localSecretsLayer = "local secrets"
+
+// localSecretsPath is the file that holds this developer's secrets for the
+// project. It lives in the user's state directory, so it's never committed.
+func (d *Devbox) localSecretsPath() string {
+	return xdg.StateSubpath(filepath.Join("devbox", "secrets", d.ProjectDirHash()+".env"))
+}
+
+func (d *Devbox) loadLocalSecrets() (*dotenv.Env, error) {
+	return dotenv.Load([]dotenv.Layer{{
+		Name: localSecretsLayer,
+		Path: d.localSecretsPath(),
+	}}, nil)
+}
+
+// LocalSecrets returns the secrets stored for this project on this machine.
+func (d *Devbox) LocalSecrets() (map[string]string, error) {
+	env, err := d.loadLocalSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return env.Values(), nil
+}
+
+// SetLocalSecrets stores secrets for this project on this machine. They
+// override values from every other source when the environment is computed.
+func (d *Devbox) SetLocalSecrets(secrets map[string]string) error {
+	for key := range secrets {
+		if !dotenv.IsValidKey(key) {
+			return usererr.New("%q is not a valid environment variable name", key)
+		}
+	}
+	current, err := d.LocalSecrets()
+	if err != nil {
+		return err
+	}
+	for key, value := range secrets {
+		current[key] = value
+	}
+	return d.writeLocalSecrets(current)
+}
+
+// RemoveLocalSecrets deletes secrets stored for this project on this
+// machine. It returns the keys that weren't set.
+func (d *Devbox) RemoveLocalSecrets(keys ...string) (notFound []string, err error) {
+	current, err := d.LocalSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if _, ok := current[key]; !ok {
+			notFound = append(notFound, key)
+		}
+		delete(current, key)
+	}
+	return notFound, d.writeLocalSecrets(current)
+}
+
+func (d *Devbox) writeLocalSecrets(secrets map[string]string) error {
+	path := d.localSecretsPath()
+	if len(secrets) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return redact.Errorf("remove local secrets: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return redact.Errorf("create local secrets directory: %w", err)
+	}
+	data, err := dotenv.Format(secrets)
+	if err != nil {
+		return usererr.WithUserMessage(err, "Failed to store local secrets")
+	}
+	// Write to a temp file first, so that a failed write doesn't lose the
+	// existing secrets.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".secrets-*")
+	if err != nil {
+		return redact.Errorf("write local secrets: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return redact.Errorf("write local secrets: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return redact.Errorf("write local secrets: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return redact.Errorf("write local secrets: %w", err)
+	}
+	return nil
+}
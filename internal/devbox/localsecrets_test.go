@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalSecrets(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	d := &Devbox{projectDir: t.TempDir()}
+
+	require.NoError(t, d.SetLocalSecrets(map[string]string{
+		"TOKEN": `quoted "$value"`,
+		"USER":  "admin",
+		"DIR":   `C:\Users\`,
+	}))
+	require.Error(t, d.SetLocalSecrets(map[string]string{"NOT VALID": "x"}))
+
+	got, err := d.LocalSecrets()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"TOKEN": `quoted "$value"`, "USER": "admin", "DIR": `C:\Users\`}, got)
+
+	info, err := os.Stat(d.localSecretsPath())
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	require.Error(t, d.SetLocalSecrets(map[string]string{"MULTILINE": "line1\nline2\\"}),
+		"values that dotenv can't read back aren't stored")
+	got, err = d.LocalSecrets()
+	require.NoError(t, err)
+	require.NotContains(t, got, "MULTILINE")
+
+	notFound, err := d.RemoveLocalSecrets("TOKEN", "MISSING", "DIR")
+	require.NoError(t, err)
+	require.Equal(t, []string{"MISSING"}, notFound)
+
+	_, err = d.RemoveLocalSecrets("USER")
+	require.NoError(t, err)
+	require.NoFileExists(t, d.localSecretsPath())
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// PruneLockfile removes the packages in devbox.lock that devbox.json and its
+// plugins no longer reference, and returns them. With dryRun, it only
+// returns them.
+func (d *Devbox) PruneLockfile(dryRun bool) ([]string, error) {
+	stale := d.lockfile.StalePackages()
+	if dryRun || len(stale) == 0 {
+		return stale, nil
+	}
+	d.lockfile.Prune()
+	if err := d.lockfile.Save(); err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// ResolveLock locks the packages of devbox.json and its plugins without
+// installing them. With allSystems, it also locks every system that the
+// search service has each package for, and returns the systems that it
+// couldn't lock, keyed by package. Packages that are fully locked keep their
+// lock entries.
+func (d *Devbox) ResolveLock(ctx context.Context, allSystems bool) (map[string][]string, error) {
+	missing := map[string][]string{}
+	for _, pkg := range d.AllPackages() {
+		if !allSystems {
+			if _, err := d.lockfile.ResolveContext(ctx, pkg.Raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		systems, err := d.lockfile.LockAllSystems(ctx, pkg.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(systems) > 0 {
+			missing[pkg.Raw] = systems
+		}
+	}
+	return missing, d.lockfile.Save()
+}
+
+// LockHistory returns the snapshots of devbox.lock that devbox kept each
+// time it wrote it, newest first.
+func (d *Devbox) LockHistory() ([]*lock.Snapshot, error) {
+	return lock.Snapshots(d.projectDir)
+}
+
+// RollbackLockfile restores devbox.lock to the snapshot with the given ID, or
+// to what it was before the last change if id is "". It returns the
+// snapshot and how the locked versions changed. The packages aren't
+// installed until the next install.
+func (d *Devbox) RollbackLockfile(id string) (*lock.Snapshot, []Change, error) {
+	if id == "" {
+		previous, err := d.lockfile.PreviousSnapshot()
+		if err != nil {
+			return nil, nil, err
+		}
+		if previous == nil {
+			return nil, nil, usererr.New("The lock history doesn't have an earlier devbox.lock to roll back to.")
+		}
+		id = previous.ID
+	}
+
+	before := historyState{Packages: map[string]string{}}
+	for key, pkg := range d.lockfile.Packages {
+		before.Packages[key] = pkg.Version
+	}
+	snapshot, err := d.lockfile.Rollback(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes := diffHistoryStates(before, historyState{Packages: snapshot.Packages})
+	return snapshot, changes, nil
+}
+
+// How devbox installs a locked package on this machine.
+const (
+	// InstallFromStorePath fetches the locked store paths from a binary
+	// cache without evaluating nixpkgs.
+	InstallFromStorePath = "store-path"
+	// InstallFromEval has Nix evaluate the resolved installable, which
+	// downloads nixpkgs and may build the package.
+	InstallFromEval = "nixpkgs-eval"
+	// InstallFromRunX downloads the package's GitHub release.
+	InstallFromRunX = "runx"
+)
+
+// LockExplanation describes where a lock entry came from and how devbox
+// will install it.
+type LockExplanation struct {
+	Package string `json:"package"`
+	Source  string `json:"source"`
+	// Query is the name@version that devbox asked the search service to
+	// resolve. Pinned packages and flakes don't have one.
+	Query        string         `json:"query,omitempty"`
+	Resolved     string         `json:"resolved,omitempty"`
+	Version      string         `json:"version,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	Systems      []LockedSystem `json:"systems,omitempty"`
+
+	// System is the current system, which Resolved and Version are for.
+	System      string `json:"system"`
+	InstallPath string `json:"install_path"`
+	// Reason says why devbox takes InstallPath.
+	Reason string `json:"reason"`
+}
+
+// LockedSystem is the store paths that a lock entry has for one system.
+type LockedSystem struct {
+	System  string        `json:"system"`
+	Outputs []lock.Output `json:"outputs"`
+}
+
+// ExplainLock describes the lock entry of the package with the given name or
+// name@version.
+func (d *Devbox) ExplainLock(name string) (*LockExplanation, error) {
+	pkg, err := d.findPackageByName(name)
+	if err != nil {
+		return nil, err
+	}
+	entry := d.lockfile.Get(pkg.LockfileKey())
+	if entry == nil {
+		return nil, usererr.New(
+			"%s isn't in devbox.lock yet. Run `devbox install` to lock it.", pkg.Raw)
+	}
+
+	system := nix.System()
+	explanation := explainLockEntry(pkg.LockfileKey(), entry, system)
+	explanation.InstallPath, explanation.Reason = installPath(pkg, entry, system)
+	return explanation, nil
+}
+
+// explainLockEntry describes entry, the lock entry of key, without deciding
+// how it's installed.
+func explainLockEntry(key string, entry *lock.Package, system string) *LockExplanation {
+	explanation := &LockExplanation{
+		Package:      key,
+		Source:       entry.Source,
+		Resolved:     entry.ResolvedFor(system),
+		Version:      entry.VersionFor(system),
+		LastModified: entry.LastModified,
+		System:       system,
+	}
+	if entry.IsFromSearch() {
+		name, version, _ := searcher.ParseVersionedPackage(key)
+		if versions, ok := searcher.ParseSystemVersions(version); ok {
+			version = versions[system]
+		}
+		if version != "" {
+			explanation.Query = name + "@" + version
+		}
+	}
+
+	systems := lo.Keys(entry.Systems)
+	slices.Sort(systems)
+	for _, sys := range systems {
+		if info := entry.Systems[sys]; info != nil && len(info.Outputs) > 0 {
+			explanation.Systems = append(explanation.Systems, LockedSystem{
+				System:  sys,
+				Outputs: info.Outputs,
+			})
+		}
+	}
+	return explanation
+}
+
+// installPath returns how devbox installs pkg on system and why.
+func installPath(pkg *devpkg.Package, entry *lock.Package, system string) (string, string) {
+	if pkg.IsRunX() {
+		return InstallFromRunX, "it's a RunX package"
+	}
+	if reason := evalReason(pkg, entry, system); reason != "" {
+		return InstallFromEval, reason
+	}
+	if version, err := nix.Version(); err == nil && !version.AtLeast(nix.Version2_17) {
+		return InstallFromEval, fmt.Sprintf(
+			"fetching store paths needs Nix %s or later, and this machine has Nix %s",
+			nix.Version2_17, version.Version)
+	}
+	inCache, err := pkg.IsInBinaryCache()
+	if err != nil {
+		return InstallFromEval, fmt.Sprintf("devbox couldn't check the binary caches: %v", err)
+	}
+	if !inCache {
+		return InstallFromEval, fmt.Sprintf(
+			"the store paths for %s aren't in the local Nix store or any of the binary caches "+
+				"that devbox uses", system)
+	}
+	return InstallFromStorePath, fmt.Sprintf(
+		"devbox.lock has store paths for %s, and they're in the local Nix store or a binary cache", system)
+}
+
+// evalReason returns why Nix has to evaluate pkg on system, without asking a
+// binary cache, or "" if devbox can fetch its store paths.
+func evalReason(pkg *devpkg.Package, entry *lock.Package, system string) string {
+	switch {
+	case !featureflag.RemoveNixpkgs.Enabled():
+		return "fetching store paths is disabled by the REMOVE_NIXPKGS feature flag"
+	case !pkg.IsDevboxPackage:
+		return "it's a flake installable, which Nix always evaluates"
+	case entry.IsPinned():
+		return "it's pinned to a nixpkgs commit, so the search service doesn't give it store paths"
+	case pkg.PatchGlibc():
+		return "patch_glibc rebuilds it against a newer glibc"
+	}
+	info := entry.Systems[system]
+	if info == nil || !slices.ContainsFunc(info.Outputs, func(out lock.Output) bool { return out.Path != "" }) {
+		return fmt.Sprintf("devbox.lock doesn't have store paths for %s", system)
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestPruneLockfile(t *testing.T) {
+	d := devboxForTesting(t)
+	d.lockfile.Packages["go@1.21"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#go",
+		Version:  "1.21.8",
+	}
+	require.NoError(t, d.lockfile.Save())
+
+	pruned, err := d.PruneLockfile(true /*dryRun*/)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21"}, pruned)
+	require.Contains(t, d.lockfile.Packages, "go@1.21", "a dry run doesn't remove anything")
+
+	pruned, err = d.PruneLockfile(false /*dryRun*/)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21"}, pruned)
+	require.Empty(t, d.lockfile.Packages)
+
+	reopened, err := lock.GetFile(d)
+	require.NoError(t, err)
+	require.Empty(t, reopened.Packages, "the pruned lockfile is saved")
+}
+
+func TestExplainLockEntry(t *testing.T) {
+	entry := &lock.Package{
+		LastModified: "2024-03-21T09:22:22Z",
+		Resolved:     "github:NixOS/nixpkgs/abc#nodejs_20",
+		Source:       "devbox-search",
+		Version:      "20.11.1",
+		Systems: map[string]*lock.SystemInfo{
+			"x86_64-linux": {
+				Resolved: "github:NixOS/nixpkgs/def#nodejs_18",
+				Version:  "18.19.1",
+				Outputs:  []lock.Output{{Name: "out", Path: "/nix/store/x-nodejs-18.19.1", Default: true}},
+			},
+			"aarch64-darwin": {
+				Outputs: []lock.Output{{Name: "out", Path: "/nix/store/y-nodejs-20.11.1", Default: true}},
+			},
+			"aarch64-linux": {},
+		},
+	}
+
+	explanation := explainLockEntry("nodejs@aarch64-darwin=20,x86_64-linux=18", entry, "x86_64-linux")
+	require.Equal(t, "nodejs@18", explanation.Query)
+	require.Equal(t, "github:NixOS/nixpkgs/def#nodejs_18", explanation.Resolved)
+	require.Equal(t, "18.19.1", explanation.Version)
+	require.Equal(t, "2024-03-21T09:22:22Z", explanation.LastModified)
+	require.Equal(t, []string{"aarch64-darwin", "x86_64-linux"},
+		lo.Map(explanation.Systems, func(s LockedSystem, _ int) string { return s.System }),
+		"systems without store paths aren't listed")
+
+	entry.Source = "pinned"
+	require.Empty(t, explainLockEntry("hello@2.12", entry, "x86_64-linux").Query,
+		"pinned packages aren't resolved by the search service")
+}
+
+func TestEvalReason(t *testing.T) {
+	d := devboxForTesting(t)
+	withPaths := &lock.Package{
+		Source: "devbox-search",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: "/nix/store/x-go-1.22.1"}}},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		pkg   string
+		entry *lock.Package
+		slow  bool
+	}{
+		{"store paths", "go@1.22", withPaths, false},
+		{"no store paths", "go@1.22", &lock.Package{Source: "devbox-search"}, true},
+		{"pinned", "go@1.22", &lock.Package{Source: "pinned", Systems: withPaths.Systems}, true},
+		{"flake", "github:numtide/flake-utils#lib", &lock.Package{Source: "flake"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := evalReason(devpkg.PackageFromStringWithDefaults(test.pkg, d.lockfile), test.entry, nix.System())
+			if test.slow {
+				require.NotEmpty(t, reason)
+			} else {
+				require.Empty(t, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// multiplexerVarsEnvVar lists the variables that devbox shared with the tmux
+// session or screen, so that they can be unshared.
+const multiplexerVarsEnvVar = "__DEVBOX_MULTIPLEXER_VARS"
+
+// tmuxHook is the index of devbox's client-attached hook in the session's
+// hook array, so that it doesn't replace the user's own hooks.
+const tmuxHook = "client-attached[79]"
+
+// multiplexerSkippedVars are specific to each shell, window or pane.
+var multiplexerSkippedVars = map[string]bool{
+	"PWD": true, "OLDPWD": true, "SHLVL": true, "_": true,
+	"TMUX": true, "TMUX_PANE": true, "STY": true, "WINDOW": true, "TERM": true,
+	shellrcEnvVar: true,
+}
+
+// ShareEnvWithMultiplexer shares the project's environment with the tmux
+// session or screen that devbox runs in, so that new windows and panes
+// start in the environment instead of the host's. In tmux, a hook shares the
+// environment again when a client attaches, since attaching updates the
+// session's environment from the client's.
+func (d *Devbox) ShareEnvWithMultiplexer(ctx context.Context, tmuxSession string) error {
+	defer debug.FunctionTimer().End()
+
+	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case os.Getenv("TMUX") != "" || tmuxSession != "":
+		return d.shareEnvWithTmux(ctx, tmuxSession, env)
+	case os.Getenv("STY") != "":
+		return d.shareEnvWithScreen(ctx, env)
+	}
+	return usererr.New("Devbox isn't running in tmux or screen.")
+}
+
+// UnshareEnvWithMultiplexer removes the variables that
+// ShareEnvWithMultiplexer shared, and the tmux hook.
+func (d *Devbox) UnshareEnvWithMultiplexer(ctx context.Context, tmuxSession string) error {
+	switch {
+	case os.Getenv("TMUX") != "" || tmuxSession != "":
+		session, err := currentTmuxSession(ctx, tmuxSession)
+		if err != nil {
+			return err
+		}
+		out, _ := exec.CommandContext(ctx, "tmux", "show-environment", "-t", session, multiplexerVarsEnvVar).Output()
+		_, shared, _ := strings.Cut(strings.TrimSpace(string(out)), "=")
+		args := []string{"set-hook", "-u", "-t", session, tmuxHook}
+		for _, k := range append(strings.Fields(shared), multiplexerVarsEnvVar) {
+			args = append(args, ";", "set-environment", "-u", "-t", session, k)
+		}
+		args = append(args, ";", "set-option", "-u", "-t", session, "update-environment")
+		return runTmux(ctx, args)
+	case os.Getenv("STY") != "":
+		for _, k := range append(strings.Fields(os.Getenv(multiplexerVarsEnvVar)), multiplexerVarsEnvVar) {
+			if err := runScreen(ctx, "unsetenv", k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return usererr.New("Devbox isn't running in tmux or screen.")
+}
+
+func (d *Devbox) shareEnvWithTmux(ctx context.Context, session string, env map[string]string) error {
+	session, err := currentTmuxSession(ctx, session)
+	if err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, "tmux", "show-environment", "-g").Output()
+	if err != nil {
+		return redact.Errorf("read the tmux global environment: %w", err)
+	}
+	vars := multiplexerVars(env, parseTmuxEnvironment(string(out)))
+
+	out, err = exec.CommandContext(ctx, "tmux", "show-options", "-gv", "update-environment").Output()
+	if err != nil {
+		return redact.Errorf("read the tmux update-environment option: %w", err)
+	}
+	updateEnv := strings.Fields(string(out))
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	hook := fmt.Sprintf("run-shell -b %s", shellQuote(fmt.Sprintf(
+		"%s integrate tmux --quiet -c %s --session %s", shellQuote(exe), shellQuote(d.projectDir), shellQuote(session))))
+	if err := runTmux(ctx, tmuxShareArgs(session, vars, updateEnv, hook)); err != nil {
+		return err
+	}
+	ux.Fsuccess(d.stderr, "New tmux windows and panes in this session start in the devbox environment.\n")
+	return nil
+}
+
+func (d *Devbox) shareEnvWithScreen(ctx context.Context, env map[string]string) error {
+	vars := multiplexerVars(env, nil /*base*/)
+	for _, k := range sortedKeys(vars) {
+		if err := runScreen(ctx, "setenv", k, vars[k]); err != nil {
+			return err
+		}
+	}
+	ux.Fsuccess(d.stderr, "New screen windows start in the devbox environment.\n")
+	return nil
+}
+
+// multiplexerVars returns the variables of env that differ from base, and
+// multiplexerVarsEnvVar with their names.
+func multiplexerVars(env, base map[string]string) map[string]string {
+	vars := map[string]string{}
+	for k, v := range env {
+		if old, ok := base[k]; (!ok || old != v) && !multiplexerSkippedVars[k] {
+			vars[k] = v
+		}
+	}
+	vars[multiplexerVarsEnvVar] = strings.Join(sortedKeys(vars), " ")
+	return vars
+}
+
+// tmuxShareArgs returns the tmux command that sets the variables in the
+// session. The session's update-environment leaves out the shared variables,
+// so that attaching from outside of devbox doesn't replace them.
+func tmuxShareArgs(session string, vars map[string]string, updateEnv []string, hook string) []string {
+	var args []string
+	for _, k := range sortedKeys(vars) {
+		args = append(args, "set-environment", "-t", session, k, vars[k], ";")
+	}
+	filtered := slices.DeleteFunc(slices.Clone(updateEnv), func(k string) bool {
+		_, ok := vars[k]
+		return ok
+	})
+	if len(filtered) != len(updateEnv) {
+		args = append(args, "set-option", "-t", session, "update-environment", strings.Join(filtered, " "), ";")
+	}
+	return append(args, "set-hook", "-t", session, tmuxHook, hook)
+}
+
+// parseTmuxEnvironment parses the output of tmux show-environment. Lines
+// of removed variables start with a "-".
+func parseTmuxEnvironment(out string) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(k, "-") {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+func currentTmuxSession(ctx context.Context, session string) (string, error) {
+	if session != "" {
+		return session, nil
+	}
+	out, err := exec.CommandContext(ctx, "tmux", "display-message", "-p", "#{session_id}").Output()
+	if err != nil {
+		return "", redact.Errorf("find the tmux session: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func runTmux(ctx context.Context, args []string) error {
+	debug.Log("running tmux %v", args)
+	cmd := exec.CommandContext(ctx, "tmux", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return redact.Errorf("tmux: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func runScreen(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "screen", append([]string{"-S", os.Getenv("STY"), "-X"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return redact.Errorf("screen: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiplexerVars(t *testing.T) {
+	env := map[string]string{
+		"PATH": "/devbox/bin:/usr/bin",
+		"HOME": "/home/me",
+		"PWD":  "/src",
+		"TMUX": "/tmp/tmux-1000/default,1,0",
+		"FOO":  "bar",
+	}
+	base := map[string]string{"PATH": "/usr/bin", "HOME": "/home/me"}
+	require.Equal(t, map[string]string{
+		"PATH":                "/devbox/bin:/usr/bin",
+		"FOO":                 "bar",
+		multiplexerVarsEnvVar: "FOO PATH",
+	}, multiplexerVars(env, base))
+}
+
+func TestParseTmuxEnvironment(t *testing.T) {
+	out := "DISPLAY=:0\n-SSH_AGENT_PID\nPATH=/usr/bin:/bin\nEMPTY=\n"
+	require.Equal(t, map[string]string{"DISPLAY": ":0", "PATH": "/usr/bin:/bin", "EMPTY": ""}, parseTmuxEnvironment(out))
+}
+
+func TestTmuxShareArgs(t *testing.T) {
+	vars := map[string]string{"PATH": "/devbox/bin", "DISPLAY": ":1"}
+	args := tmuxShareArgs("$1", vars, []string{"DISPLAY", "SSH_AUTH_SOCK"}, "run-shell -b true")
+	require.Equal(t, []string{
+		"set-environment", "-t", "$1", "DISPLAY", ":1", ";",
+		"set-environment", "-t", "$1", "PATH", "/devbox/bin", ";",
+		"set-option", "-t", "$1", "update-environment", "SSH_AUTH_SOCK", ";",
+		"set-hook", "-t", "$1", tmuxHook, "run-shell -b true",
+	}, args)
+
+	// The session's update-environment isn't set if it doesn't change.
+	args = tmuxShareArgs("$1", map[string]string{"PATH": "/devbox/bin"}, []string{"DISPLAY"}, "run-shell -b true")
+	require.NotContains(t, args, "update-environment")
+}
+
+func TestShareEnvWithTmux(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux isn't installed")
+	}
+	socket := filepath.Join(t.TempDir(), "tmux")
+	tmux := func(args ...string) string {
+		out, err := exec.Command("tmux", append([]string{"-S", socket}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	tmux("-f", "/dev/null", "new-session", "-d", "-s", "devbox-test")
+	t.Cleanup(func() { _ = exec.Command("tmux", "-S", socket, "kill-server").Run() })
+	// tmux commands find the server from TMUX, like they do in a pane.
+	t.Setenv("TMUX", socket+",0,0")
+
+	d := devboxForTesting(t)
+	ctx := context.Background()
+	err := d.shareEnvWithTmux(ctx, "devbox-test", map[string]string{"DEVBOX_TEST": "it's shared", "PWD": "/src"})
+	require.NoError(t, err)
+	require.Equal(t, "DEVBOX_TEST=it's shared", tmux("show-environment", "-t", "devbox-test", "DEVBOX_TEST"))
+	require.Contains(t, tmux("show-hooks", "-t", "devbox-test"), "integrate tmux")
+
+	require.NoError(t, d.UnshareEnvWithMultiplexer(ctx, "devbox-test"))
+	out, _ := exec.Command("tmux", "-S", socket, "show-environment", "-t", "devbox-test", "DEVBOX_TEST").CombinedOutput()
+	require.NotContains(t, string(out), "it's shared")
+	require.NotContains(t, tmux("show-hooks", "-t", "devbox-test"), "integrate tmux")
+}
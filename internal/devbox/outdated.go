@@ -0,0 +1,156 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// What devbox outdated reports about a package.
+const (
+	// OutdatedNewer is a package with a newer version than the locked one.
+	OutdatedNewer = "outdated"
+	// OutdatedCurrent is a package whose locked version is the latest.
+	OutdatedCurrent = "up-to-date"
+	// OutdatedSkipped is a package without a latest version to compare to,
+	// such as a flake.
+	OutdatedSkipped = "skipped"
+)
+
+// OutdatedPackage compares the locked version of a package to the latest
+// version that the resolver has.
+type OutdatedPackage struct {
+	Package string `json:"package"`
+	Status  string `json:"status"`
+	Current string `json:"current,omitempty"`
+	Latest  string `json:"latest,omitempty"`
+	// Reason says why a package is skipped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Outdated resolves the latest version of every package in the project and
+// compares it to the version in devbox.lock. It doesn't change devbox.lock.
+// The latest version can be outside the version in devbox.json, such as
+// Node.js 22 for nodejs@20, which devbox update wouldn't update to.
+func (d *Devbox) Outdated(ctx context.Context) ([]OutdatedPackage, error) {
+	pkgs := d.AllPackages()
+	report := make([]OutdatedPackage, len(pkgs))
+	latest := map[int]string{}
+	for i, pkg := range pkgs {
+		entry := d.lockfile.Packages[pkg.Raw]
+		report[i] = OutdatedPackage{Package: pkg.Raw}
+		if entry != nil {
+			report[i].Current = entry.Version
+		}
+		raw, reason := latestPackage(pkg.Raw, entry)
+		if reason != "" {
+			report[i].Status = OutdatedSkipped
+			report[i].Reason = reason
+			continue
+		}
+		latest[i] = raw
+	}
+
+	d.lockfile.PrefetchResolutions(ctx, lo.Values(latest))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(lock.ResolveConcurrency())
+	for i, raw := range latest {
+		group.Go(func() error {
+			resolved, err := d.lockfile.FetchResolvedPackage(ctx, raw)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// A package that can't be resolved doesn't stop the others
+			// from being reported.
+			report[i] = compareLatest(report[i], resolved, err)
+			return nil
+		})
+	}
+	return report, group.Wait()
+}
+
+// latestPackage returns the package that resolves to the latest version of
+// pkg, or why pkg doesn't have a latest version to compare to.
+func latestPackage(pkg string, entry *lock.Package) (string, string) {
+	if pkgtype.IsFlake(pkg) {
+		return "", "flakes are updated with devbox update"
+	}
+	name, version, isVersioned := searcher.ParseVersionedPackage(pkg)
+	switch {
+	case !isVersioned:
+		return "", "isn't versioned, run devbox update to lock a version"
+	case entry == nil:
+		return "", "isn't in devbox.lock"
+	case entry.IsPinned():
+		return "", "pinned to a nixpkgs commit in devbox.json"
+	}
+	if pkgtype.IsRunX(pkg) {
+		return name + "@latest", ""
+	}
+	if _, ok := searcher.ParseSystemVersions(version); ok {
+		return "", "has a different version on each system"
+	}
+	_, channel, err := searcher.ParseChannel(version)
+	if err != nil {
+		return "", err.Error()
+	}
+	if channel != "" {
+		return name + "@latest?channel=" + channel, ""
+	}
+	return name + "@latest", ""
+}
+
+func compareLatest(p OutdatedPackage, resolved *lock.Package, err error) OutdatedPackage {
+	if err != nil || resolved == nil {
+		p.Status = OutdatedSkipped
+		p.Reason = "the latest version couldn't be resolved"
+		if err != nil {
+			p.Reason += ": " + err.Error()
+		}
+		return p
+	}
+	p.Latest = resolved.Version
+	p.Status = OutdatedCurrent
+	if searcher.CompareVersions(p.Latest, p.Current) > 0 {
+		p.Status = OutdatedNewer
+	}
+	return p
+}
+
+// PrintOutdated writes a table of the packages in report.
+func PrintOutdated(w io.Writer, report []OutdatedPackage) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tCURRENT\tLATEST\tSTATUS")
+	outdated := 0
+	for _, p := range report {
+		status := p.Status
+		if p.Reason != "" {
+			status += ", " + p.Reason
+		}
+		if p.Status == OutdatedNewer {
+			outdated++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			p.Package, lo.Ternary(p.Current != "", p.Current, "-"), lo.Ternary(p.Latest != "", p.Latest, "-"), status)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if outdated == 0 {
+		fmt.Fprintln(w, "Every package is locked to its latest version.")
+		return
+	}
+	fmt.Fprintf(w, "%d package(s) have newer versions. Run `devbox update` to update packages within "+
+		"the versions in devbox.json, or `devbox add <pkg>@latest` to move to the latest version.\n", outdated)
+}
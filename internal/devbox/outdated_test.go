@@ -0,0 +1,66 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestLatestPackage(t *testing.T) {
+	entry := &lock.Package{Version: "20.11.1"}
+	for pkg, want := range map[string]string{
+		"nodejs@20":                           "nodejs@latest",
+		"nodejs@^20":                          "nodejs@latest",
+		"python@3.12?channel=nixos-24.05":     "python@latest?channel=nixos-24.05",
+		"runx:golangci/golangci-lint@v1.55.2": "runx:golangci/golangci-lint@latest",
+	} {
+		latest, reason := latestPackage(pkg, entry)
+		require.Empty(t, reason, pkg)
+		require.Equal(t, want, latest, pkg)
+	}
+
+	for pkg, entry := range map[string]*lock.Package{
+		"github:F1bonacc1/process-compose": entry,
+		"hello":                            entry,
+		"ripgrep@14":                       nil,
+		"go@aarch64-darwin=1.22,x86_64-linux=1.21": entry,
+	} {
+		latest, reason := latestPackage(pkg, entry)
+		require.NotEmpty(t, reason, pkg)
+		require.Empty(t, latest, pkg)
+	}
+}
+
+func TestCompareLatest(t *testing.T) {
+	current := OutdatedPackage{Package: "nodejs@20", Current: "20.11.1"}
+	require.Equal(t, OutdatedNewer, compareLatest(current, &lock.Package{Version: "22.3.0"}, nil).Status)
+	require.Equal(t, OutdatedCurrent, compareLatest(current, &lock.Package{Version: "20.11.1"}, nil).Status)
+	require.Equal(t, OutdatedCurrent, compareLatest(current, &lock.Package{Version: "20.9.0"}, nil).Status)
+
+	skipped := compareLatest(current, nil, errors.New("search is down"))
+	require.Equal(t, OutdatedSkipped, skipped.Status)
+	require.Contains(t, skipped.Reason, "search is down")
+}
+
+func TestPrintOutdated(t *testing.T) {
+	var out strings.Builder
+	PrintOutdated(&out, []OutdatedPackage{
+		{Package: "nodejs@20", Status: OutdatedNewer, Current: "20.11.1", Latest: "22.3.0"},
+		{Package: "hello@latest", Status: OutdatedCurrent, Current: "2.12.1", Latest: "2.12.1"},
+		{Package: "github:F1bonacc1/process-compose", Status: OutdatedSkipped, Reason: "flakes are updated with devbox update"},
+	})
+	require.Contains(t, out.String(), "nodejs@20                         20.11.1  22.3.0  outdated")
+	require.Contains(t, out.String(), "skipped, flakes are updated with devbox update")
+	require.Contains(t, out.String(), "1 package(s) have newer versions.")
+
+	out.Reset()
+	PrintOutdated(&out, nil)
+	require.Contains(t, out.String(), "Every package is locked to its latest version.")
+}
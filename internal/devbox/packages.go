@@ -31,6 +31,7 @@ import (
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/plugin"
 	"go.jetpack.io/devbox/internal/ux"
@@ -44,10 +45,21 @@ import (
 func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) error {
 	ctx, task := trace.NewTask(ctx, "devboxAdd")
 	defer task.End()
+	d.recordHistory("edit")
 
 	// Track which packages had no changes so we can report that to the user.
 	unchangedPackageNames := []string{}
 
+	// Aliases in devbox.json take precedence over packages with the same
+	// name in search.
+	pkgsNames = lo.Map(pkgsNames, func(name string, _ int) string {
+		expanded, isAlias := d.cfg.Root.ExpandAlias(name)
+		if isAlias {
+			ux.Finfo(d.stderr, "Using alias %s for %s\n", name, expanded)
+		}
+		return expanded
+	})
+
 	// Only add packages that are not already in config. If same canonical exists,
 	// replace it.
 	pkgs := devpkg.PackagesFromStringsWithOptions(lo.Uniq(pkgsNames), d.lockfile, opts)
@@ -56,16 +68,25 @@ func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpt
 	// names of added packages (even if they are already in config). We use this
 	// to know the exact name to mark as allowed insecure later on.
 	addedPackageNames := []string{}
+	// addedPackageOutputs has the outputs that each added package selects,
+	// such as "dev" in "curl@8.1#dev".
+	addedPackageOutputs := map[string][]string{}
 	existingPackageNames := lo.Map(
 		d.cfg.Root.TopLevelPackages(), func(p configfile.Package, _ int) string {
 			return p.VersionedName()
 		})
+	// Resolve the new packages with as few requests to the search service as
+	// possible, instead of one request per package in the loop below.
+	d.lockfile.PrefetchResolutions(ctx, lo.FilterMap(pkgs, func(p *devpkg.Package, _ int) (string, bool) {
+		return p.Versioned(), !slices.Contains(existingPackageNames, p.Versioned())
+	}))
 	for _, pkg := range pkgs {
 		// If exact versioned package is already in the config, we can skip the
 		// next loop that only deals with newPackages.
 		if slices.Contains(existingPackageNames, pkg.Versioned()) {
 			// But we still need to add to addedPackageNames. See its comment.
 			addedPackageNames = append(addedPackageNames, pkg.Versioned())
+			addedPackageOutputs[pkg.Versioned()] = pkg.SelectedOutputs()
 			unchangedPackageNames = append(unchangedPackageNames, pkg.Versioned())
 			ux.Finfo(d.stderr, "Package %q already in devbox.json\n", pkg.Versioned())
 			continue
@@ -98,19 +119,25 @@ func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpt
 			// This means it didn't validate and we don't want to fallback to legacy
 			// Just propagate the error.
 			return err
-		} else if _, err := nix.Search(d.lockfile.LegacyNixpkgsPath(pkg.Raw)); err != nil {
+		} else if _, searchErr := nix.Search(d.lockfile.LegacyNixpkgsPath(pkg.Raw)); searchErr != nil {
 			// This means it looked like a devbox package or attribute path, but we
-			// could not find it in search or in the legacy nixpkgs path.
+			// could not find it in search or in the legacy nixpkgs path. If the
+			// resolver said why, such as the package being a command that
+			// other packages install, that's more helpful.
+			if _, hasHint := usererr.Extract(err); hasHint && errors.Is(err, nix.ErrPackageNotFound) {
+				return err
+			}
 			return usererr.New("Package %s not found", pkg.Raw)
 		}
 
 		ux.Finfo(d.stderr, "Adding package %q to devbox.json\n", packageNameForConfig)
 		d.cfg.PackageMutator().Add(packageNameForConfig)
 		addedPackageNames = append(addedPackageNames, packageNameForConfig)
+		addedPackageOutputs[packageNameForConfig] = pkg.SelectedOutputs()
 	}
 
 	// Options must be set before ensureStateIsUpToDate. See comment in function
-	if err := d.setPackageOptions(addedPackageNames, opts); err != nil {
+	if err := d.setPackageOptions(addedPackageNames, addedPackageOutputs, opts); err != nil {
 		return err
 	}
 
@@ -121,11 +148,12 @@ func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpt
 	if err := d.saveCfg(); err != nil {
 		return err
 	}
+	d.recordHistory("add")
 
 	return d.printPostAddMessage(ctx, pkgs, unchangedPackageNames, opts)
 }
 
-func (d *Devbox) setPackageOptions(pkgs []string, opts devopt.AddOpts) error {
+func (d *Devbox) setPackageOptions(pkgs []string, outputs map[string][]string, opts devopt.AddOpts) error {
 	for _, pkg := range pkgs {
 		if err := d.cfg.PackageMutator().AddPlatforms(
 			d.stderr, pkg, opts.Platforms); err != nil {
@@ -144,7 +172,7 @@ func (d *Devbox) setPackageOptions(pkgs []string, opts devopt.AddOpts) error {
 			return err
 		}
 		if err := d.cfg.PackageMutator().SetOutputs(
-			d.stderr, pkg, opts.Outputs); err != nil {
+			d.stderr, pkg, lo.Uniq(append(slices.Clone(opts.Outputs), outputs[pkg]...))); err != nil {
 			return err
 		}
 		if err := d.cfg.PackageMutator().SetAllowInsecure(
@@ -191,6 +219,7 @@ func (d *Devbox) printPostAddMessage(
 func (d *Devbox) Remove(ctx context.Context, pkgs ...string) error {
 	ctx, task := trace.NewTask(ctx, "devboxRemove")
 	defer task.End()
+	d.recordHistory("edit")
 
 	packagesToUninstall := []string{}
 	missingPkgs := []string{}
@@ -221,7 +250,11 @@ func (d *Devbox) Remove(ctx context.Context, pkgs ...string) error {
 		return err
 	}
 
-	return d.saveCfg()
+	if err := d.saveCfg(); err != nil {
+		return err
+	}
+	d.recordHistory("rm")
+	return nil
 }
 
 // installMode is an enum for helping with ensureStateIsUpToDate implementation
@@ -251,10 +284,15 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 	defer trace.StartRegion(ctx, "devboxEnsureStateIsUpToDate").End()
 	defer debug.FunctionTimer().End()
 
-	upToDate, err := d.lockfile.IsUpToDateAndInstalled(isFishShell())
+	if err := d.ensureTrusted(); err != nil {
+		return err
+	}
+
+	drift, err := d.lockfile.StateDrift(isFishShell())
 	if err != nil {
 		return err
 	}
+	upToDate := !drift.Any()
 
 	// if mode is install or uninstall, then we need to compute some state
 	// like updating the flake or installing packages locally, so must continue
@@ -264,10 +302,15 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		if upToDate {
 			return nil
 		}
-		ux.Finfo(d.stderr, "Ensuring packages are installed.\n")
+		if drift.NeedsInstall() {
+			ux.Finfo(d.stderr, "Ensuring packages are installed.\n")
+		}
 	}
 
-	if mode == install || mode == update || mode == ensure {
+	// In ensure mode, skip installing when only the config changed in ways that
+	// don't affect packages (e.g. env or scripts). Recomputing the state below
+	// is enough to pick those up.
+	if mode == install || mode == update || (mode == ensure && drift.NeedsInstall()) {
 		if err := d.installPackages(ctx, mode); err != nil {
 			return err
 		}
@@ -280,6 +323,14 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		}
 	}
 
+	// The post_package hooks run whenever the packages changed. A failing
+	// hook leaves the lockfile as it was, so that they run again next time.
+	if mode != ensure || drift.NeedsInstall() {
+		if err := d.runPostPackageHooks(ctx); err != nil {
+			return err
+		}
+	}
+
 	// If we're in a devbox shell (global or project), then the environment might
 	// be out of date after the user installs something. If have direnv active
 	// it should reload automatically so we don't need to refresh.
@@ -291,7 +342,16 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		)
 	}
 
-	return d.updateLockfile(recomputeState)
+	if err := d.updateLockfile(recomputeState); err != nil {
+		return err
+	}
+	d.syncJetBrains()
+	d.syncBazel()
+	if mode == ensure {
+		// Changes made by editing devbox.json take effect here.
+		d.recordHistory("edit")
+	}
+	return nil
 }
 
 // updateLockfile will ensure devbox.lock is up to date with the current state of the project.update
@@ -306,6 +366,7 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 			return err
 		}
 	}
+	d.lockfile.RecordOrigins(d.cfg.PackageOrigins())
 
 	// Save the lockfile at the very end, after all other operations were successful.
 	if err := d.lockfile.Save(); err != nil {
@@ -320,10 +381,15 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 		if err != nil {
 			return err
 		}
+		packagesHash, err := d.PackagesHash()
+		if err != nil {
+			return err
+		}
 		return lock.UpdateAndSaveStateHashFile(lock.UpdateStateHashFileArgs{
-			ProjectDir: d.projectDir,
-			ConfigHash: configHash,
-			IsFish:     isFishShell(),
+			ProjectDir:   d.projectDir,
+			ConfigHash:   configHash,
+			PackagesHash: packagesHash,
+			IsFish:       isFishShell(),
 		})
 	}
 	return nil
@@ -394,6 +460,13 @@ func resetProfileDirForFlakes(profileDir string) (err error) {
 
 func (d *Devbox) installPackages(ctx context.Context, mode installMode) error {
 	defer debug.FunctionTimer().End()
+	if err := d.enforceSourcePolicy(); err != nil {
+		return err
+	}
+	if err := d.enforceLicensePolicy(ctx); err != nil {
+		return err
+	}
+
 	// Create plugin directories first because packages might need them
 	for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
 		if err := d.PluginManager().CreateFilesForConfig(pluginConfig); err != nil {
@@ -407,6 +480,14 @@ func (d *Devbox) installPackages(ctx context.Context, mode installMode) error {
 		}
 		return err
 	}
+	if err := d.verifySignatures(ctx); err != nil {
+		return err
+	}
+	if err := d.lockfile.RecordOutputHashes(ctx); err != nil {
+		// The hashes only matter to devbox verify, which reports the
+		// outputs that don't have one.
+		debug.Log("Failed to record output hashes in devbox.lock: %v", err)
+	}
 
 	return d.InstallRunXPackages(ctx)
 }
@@ -424,16 +505,20 @@ func (d *Devbox) handleInstallFailure(ctx context.Context, mode installMode) err
 
 func (d *Devbox) InstallRunXPackages(ctx context.Context) error {
 	for _, pkg := range lo.Filter(d.InstallablePackages(), devpkg.IsRunX) {
-		lockedPkg, err := d.lockfile.Resolve(pkg.Raw)
+		lockedPkg, err := d.lockfile.ResolveContext(ctx, pkg.Raw)
 		if err != nil {
 			return err
 		}
-		if _, err := pkgtype.RunXClient().Install(
+		paths, err := pkgtype.RunXClient().Install(
 			ctx,
 			lockedPkg.Resolved,
-		); err != nil {
+		)
+		if err != nil {
 			return fmt.Errorf("error installing runx package %s: %w", pkg, err)
 		}
+		if err := d.lockfile.VerifyRunXDigest(pkg.Raw, paths); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -475,6 +560,28 @@ func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode
 		strings.Join(packageNames, ", "),
 	)
 
+	eventStart := time.Now()
+	if len(packages) > 1 && installConcurrency() > 1 {
+		err = d.buildPackagesConcurrently(ctx, *args, packages)
+	} else {
+		err = buildPackages(ctx, args, packages)
+	}
+	if err != nil {
+		return err
+	}
+	telemetry.Event(telemetry.EventNixBuildSuccess, telemetry.Metadata{
+		EventStart: eventStart,
+		Packages:   packageNames,
+	})
+
+	d.autoPush(ctx, packages)
+	return nil
+}
+
+// buildPackages realizes packages with one nix build, or two if only some of
+// them allow insecure packages. Nix gets the terminal, so its own progress is
+// shown.
+func buildPackages(ctx context.Context, args *nix.BuildArgs, packages []*devpkg.Package) error {
 	installables := map[bool][]string{false: {}, true: {}}
 	for _, pkg := range packages {
 		pkgInstallables, err := pkg.Installables()
@@ -491,22 +598,19 @@ func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode
 		if len(installables) == 0 {
 			continue
 		}
-		eventStart := time.Now()
 		args.AllowInsecure = allowInsecure
-		err = nix.Build(ctx, args, installables...)
-		if err != nil {
+		if err := nix.Build(ctx, args, installables...); err != nil {
 			return err
 		}
-		telemetry.Event(telemetry.EventNixBuildSuccess, telemetry.Metadata{
-			EventStart: eventStart,
-			Packages:   packageNames,
-		})
 	}
-
 	return nil
 }
 
 func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArgs) error {
+	if !netpolicy.Allowed(netpolicy.Jetify) {
+		debug.Log("network policy disables Jetify, not using Jetify caches")
+		return nil
+	}
 	creds, err := nixcache.CachedCredentials(ctx)
 	if errors.Is(err, auth.ErrNotLoggedIn) {
 		return nil
@@ -525,7 +629,11 @@ func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArg
 		return nil
 	}
 
-	err = nixcache.Configure(ctx)
+	// Nix trusts the substituters of the user that owns a user store, so
+	// only the daemon's nix.conf needs the caches.
+	if nix.UserStore() == "" {
+		err = nixcache.Configure(ctx)
+	}
 	if errors.Is(err, setup.ErrAlreadyRefused) {
 		debug.Log("user previously refused to configure nix cache, not re-prompting")
 		return nil
@@ -550,6 +658,7 @@ func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArg
 	for _, cache := range caches {
 		args.ExtraSubstituters = append(args.ExtraSubstituters, cache.GetUri())
 	}
+	args.ExtraSubstituters = d.allowedCaches(args.ExtraSubstituters)
 	args.Env = append(args.Env, creds.Env()...)
 	return nil
 }
@@ -562,6 +671,12 @@ func (d *Devbox) packagesToInstallInStore(ctx context.Context, mode installMode)
 	if err := devpkg.FillNarInfoCache(ctx, packages...); err != nil {
 		return nil, err
 	}
+	if d.cfg.Root.CADerivations {
+		var err error
+		if packages, err = withoutContentAddressedBuilds(packages); err != nil {
+			return nil, err
+		}
+	}
 
 	// Second, check which packages are not in the nix store
 	packagesToInstall := []*devpkg.Package{}
@@ -584,7 +699,13 @@ func (d *Devbox) packagesToInstallInStore(ctx context.Context, mode installMode)
 		return nil, err
 	}
 
-	for pkg, storePaths := range storePathsForPackage {
+	// Go through packages rather than the map so that packages are
+	// installed, and their progress is shown, in devbox.json order.
+	for _, pkg := range packages {
+		storePaths, ok := storePathsForPackage[pkg]
+		if !ok {
+			continue
+		}
 		for _, storePath := range storePaths {
 			if !storePathMap[storePath] {
 				packagesToInstall = append(packagesToInstall, pkg)
@@ -596,6 +717,26 @@ func (d *Devbox) packagesToInstallInStore(ctx context.Context, mode installMode)
 	return lo.Uniq(packagesToInstall), nil
 }
 
+// withoutContentAddressedBuilds leaves out the packages that a project with
+// ca_derivations builds as content-addressed derivations. nix print-dev-env
+// builds those from the generated flake, and building their installables here
+// would build the input-addressed derivations as well.
+func withoutContentAddressedBuilds(packages []*devpkg.Package) ([]*devpkg.Package, error) {
+	var kept []*devpkg.Package
+	for _, pkg := range packages {
+		ca, err := pkg.BuildsContentAddressed()
+		if err != nil {
+			return nil, err
+		}
+		if ca {
+			debug.Log("%s is built content-addressed by the generated flake", pkg.Raw)
+			continue
+		}
+		kept = append(kept, pkg)
+	}
+	return kept, nil
+}
+
 // moveAllowInsecureFromLockfile will modernize a Devbox project by moving the allow_insecure: boolean
 // setting from the devbox.lock file to the corresponding package in devbox.json.
 //
@@ -0,0 +1,139 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/envpath"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// The sources of the directories on the PATH of the devbox environment.
+const (
+	PathSourceProfile = "devbox profile"
+	PathSourceProject = "devbox project"
+	PathSourceNix     = "nix store"
+	PathSourceDevbox  = "devbox"
+	PathSourceAllowed = "allow list"
+	PathSourceHost    = "host"
+)
+
+// pathDirSource returns where a directory on PATH comes from: the project's
+// packages, plugins and runx binaries, the .devbox/bin link to devbox itself,
+// the Nix store directories of the environment's own PATH (envDirs), the
+// allow list of a hermetic PATH, or else the host.
+func (d *Devbox) pathDirSource(dir string, envDirs []string) string {
+	dir = filepath.Clean(dir)
+	switch {
+	case dir == filepath.Clean(nix.ProfileBinPath(d.projectDir)):
+		return PathSourceProfile
+	case dir == filepath.Clean(dotdevboxBinPath(d)):
+		return PathSourceDevbox
+	case isUnder(dir, filepath.Join(d.projectDir, ".devbox")) || isUnder(dir, filepath.Join(d.projectDir, "devbox.d")):
+		return PathSourceProject
+	case isUnder(dir, "/nix/store") && slices.Contains(envDirs, dir):
+		return PathSourceNix
+	case slices.Contains(d.allowedPathDirs(), dir):
+		return PathSourceAllowed
+	}
+	return PathSourceHost
+}
+
+// hermeticPath removes the host's directories from path, unless the project
+// allows them. Nix store directories only stay if they're on envPath, the
+// PATH of the environment itself, so that ones the host's PATH happens to
+// have are removed too. Devbox stays available through its .devbox/bin link
+// (see createDevboxSymlink) rather than through the directory it's in.
+func (d *Devbox) hermeticPath(path, envPath string) string {
+	envDirs := cleanPathList(envPath)
+	path = filterPathList(path, func(dir string) bool {
+		if d.pathDirSource(dir, envDirs) != PathSourceHost {
+			return true
+		}
+		debug.Log("hermetic PATH: removing host directory %s", dir)
+		return false
+	})
+	return envpath.JoinPathLists(path, dotdevboxBinPath(d))
+}
+
+func cleanPathList(pathList string) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(pathList) {
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs
+}
+
+func (d *Devbox) allowedPathDirs() []string {
+	home, _ := os.UserHomeDir()
+	var dirs []string
+	for _, dir := range d.cfg.Root.Path().Allow {
+		dirs = append(dirs, d.configPath(dir, home))
+	}
+	return dirs
+}
+
+func isUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// PathLookup is a directory on the PATH of the devbox environment, and the
+// command in it, if any.
+type PathLookup struct {
+	Dir    string `json:"dir"`
+	Source string `json:"source"`
+	// Path is the command's path in Dir. It's empty if Dir doesn't have it.
+	Path string `json:"path,omitempty"`
+	// Target is what Path links to, such as a package's store path.
+	Target string `json:"target,omitempty"`
+	// Removed is set for host directories that a hermetic PATH left out.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// Which returns every directory on the environment's PATH in order, with the
+// command if it's in it. The first one with a Path is where the command
+// resolves from, and later ones are shadowed. With a hermetic PATH, the host
+// directories that it removed follow.
+func (d *Devbox) Which(ctx context.Context, command string) ([]PathLookup, error) {
+	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dirs := filepath.SplitList(env["PATH"])
+	envDirs := cleanPathList(env["PATH"])
+	lookups := make([]PathLookup, 0, len(dirs))
+	for _, dir := range dirs {
+		lookups = append(lookups, lookupIn(dir, d.pathDirSource(dir, envDirs), command))
+	}
+	if d.cfg.Root.Path().Hermetic {
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			if !slices.Contains(dirs, dir) && d.pathDirSource(dir, envDirs) == PathSourceHost {
+				lookup := lookupIn(dir, PathSourceHost, command)
+				lookup.Removed = true
+				lookups = append(lookups, lookup)
+			}
+		}
+	}
+	return lookups, nil
+}
+
+func lookupIn(dir, source, command string) PathLookup {
+	lookup := PathLookup{Dir: dir, Source: source}
+	path := filepath.Join(dir, command)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+		return lookup
+	}
+	lookup.Path = path
+	if target, err := filepath.EvalSymlinks(path); err == nil && target != path {
+		lookup.Target = target
+	}
+	return lookup
+}
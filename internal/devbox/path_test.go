@@ -0,0 +1,64 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestHermeticPath(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	err := os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(`{
+		"shell": {"path": {"hermetic": true, "allow": ["~/bin", "tools"]}}
+	}`), 0o644)
+	require.NoError(t, err)
+	d, err := Open(&devopt.Opts{Dir: dir, Stderr: os.Stderr})
+	require.NoError(t, err)
+
+	profile := nix.ProfileBinPath(dir)
+	plugin := filepath.Join(dir, ".devbox", "virtenv", "python", "bin")
+	store := "/nix/store/abc-bash-5.2/bin"
+	hostStore := "/nix/store/def-python3-3.12/bin"
+	devboxBin := filepath.Join(dir, ".devbox", "bin")
+	path := strings.Join([]string{
+		profile, plugin, store, "/usr/local/bin", filepath.Join(home, "bin"),
+		filepath.Join(dir, "tools"), "/usr/bin", hostStore,
+	}, string(filepath.ListSeparator))
+	envPath := strings.Join([]string{plugin, store}, string(filepath.ListSeparator))
+	require.Equal(t, strings.Join([]string{
+		profile, plugin, store, filepath.Join(home, "bin"), filepath.Join(dir, "tools"), devboxBin,
+	}, string(filepath.ListSeparator)), d.hermeticPath(path, envPath))
+
+	envDirs := []string{plugin, store}
+	require.Equal(t, PathSourceProfile, d.pathDirSource(profile, envDirs))
+	require.Equal(t, PathSourceProject, d.pathDirSource(plugin, envDirs))
+	require.Equal(t, PathSourceDevbox, d.pathDirSource(devboxBin, envDirs))
+	require.Equal(t, PathSourceNix, d.pathDirSource(store, envDirs))
+	require.Equal(t, PathSourceHost, d.pathDirSource(hostStore, envDirs))
+	require.Equal(t, PathSourceAllowed, d.pathDirSource(filepath.Join(home, "bin"), envDirs))
+	require.Equal(t, PathSourceHost, d.pathDirSource("/usr/bin", envDirs))
+}
+
+func TestLookupIn(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real-tool")
+	require.NoError(t, os.WriteFile(target, []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Symlink(target, filepath.Join(dir, "tool")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data"), nil, 0o644))
+
+	require.Equal(t, PathLookup{Dir: dir, Source: PathSourceHost, Path: filepath.Join(dir, "tool"), Target: target},
+		lookupIn(dir, PathSourceHost, "tool"))
+	require.Empty(t, lookupIn(dir, PathSourceHost, "data").Path, "not executable")
+	require.Empty(t, lookupIn(dir, PathSourceHost, "missing").Path)
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/services"
+)
+
+// PromptStatus is what shell prompts show about a project. It's the output
+// of devbox prompt.
+type PromptStatus struct {
+	Name       string `json:"name"`
+	ProjectDir string `json:"project_dir"`
+	// Active is true if the shell that runs devbox has the project's
+	// environment, such as in devbox shell or after devbox shellenv.
+	Active bool `json:"active"`
+	// Stale is true if devbox.json or devbox.lock changed since the
+	// environment was activated, and it needs a refresh.
+	Stale           bool `json:"stale"`
+	RunningServices int  `json:"running_services"`
+}
+
+// PromptStatus returns the project's status for shell prompts. It doesn't
+// install anything or run Nix, so that it's fast enough to run for every
+// prompt.
+func (d *Devbox) PromptStatus(ctx context.Context) (*PromptStatus, error) {
+	defer debug.FunctionTimer().End()
+
+	status := &PromptStatus{
+		Name:       d.projectName(),
+		ProjectDir: d.projectDir,
+		Active:     os.Getenv("DEVBOX_PROJECT_ROOT") == d.projectDir,
+	}
+	if status.Active {
+		hash, err := d.envHash()
+		if err != nil {
+			return nil, err
+		}
+		status.Stale = os.Getenv(envir.DevboxEnvHash) != hash
+	}
+
+	if services.ProcessManagerIsRunning(d.projectDir) {
+		processes, err := services.ListServices(ctx, d.projectDir, d.stderr)
+		if err != nil {
+			debug.Log("prompt: unable to list services: %v", err)
+		}
+		for _, p := range processes {
+			if p.Status == "Running" {
+				status.RunningServices++
+			}
+		}
+	}
+	return status, nil
+}
+
+// projectName is the name in devbox.json, or the name of the project's
+// directory if it doesn't have one.
+func (d *Devbox) projectName() string {
+	if d.cfg.Root.Name != "" {
+		return d.cfg.Root.Name
+	}
+	return filepath.Base(d.projectDir)
+}
+
+// envHash changes when devbox.json, its includes or devbox.lock change.
+func (d *Devbox) envHash() (string, error) {
+	configHash, err := d.ConfigHash()
+	if err != nil {
+		return "", err
+	}
+	lockHash, err := cachehash.JSONFile(filepath.Join(d.projectDir, "devbox.lock"))
+	if err != nil {
+		return "", err
+	}
+	return cachehash.Bytes([]byte(configHash + lockHash)), nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestPromptStatus(t *testing.T) {
+	d := devboxForTesting(t)
+	t.Setenv("DEVBOX_PROJECT_ROOT", "")
+
+	status, err := d.PromptStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &PromptStatus{Name: filepath.Base(d.projectDir), ProjectDir: d.projectDir}, status)
+
+	// The environment is up to date right after it's activated.
+	hash, err := d.envHash()
+	require.NoError(t, err)
+	t.Setenv("DEVBOX_PROJECT_ROOT", d.projectDir)
+	t.Setenv(envir.DevboxEnvHash, hash)
+	status, err = d.PromptStatus(context.Background())
+	require.NoError(t, err)
+	require.True(t, status.Active)
+	require.False(t, status.Stale)
+
+	// Editing devbox.json makes it stale.
+	err = os.WriteFile(filepath.Join(d.projectDir, "devbox.json"), []byte(`{"name": "my-app", "env": {"A": "1"}}`), 0o644)
+	require.NoError(t, err)
+	d, err = Open(&devopt.Opts{Dir: d.projectDir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	status, err = d.PromptStatus(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "my-app", status.Name)
+	require.True(t, status.Stale)
+}
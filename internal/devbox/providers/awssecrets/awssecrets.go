@@ -0,0 +1,188 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package awssecrets reads secrets from AWS Secrets Manager and SSM Parameter
+// Store using the ambient AWS credentials (environment variables, shared
+// config and credentials files, SSO, or instance roles).
+//
+// References have the form:
+//
+//	aws-sm://<secret name or ARN>[#<JSON key>]
+//	aws-ssm://<parameter name>
+package awssecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/smithy-go"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// New returns the providers for aws-sm:// and aws-ssm:// references. They
+// share one AWS config and cache every secret they read, so new providers
+// should be created for each environment that's computed.
+func New() (*SecretsManager, *ParameterStore) {
+	s := &session{}
+	return &SecretsManager{session: s}, &ParameterStore{session: s}
+}
+
+// session holds the AWS config once it has loaded. Errors aren't cached, so
+// reading another secret after fixing the credentials (e.g. with
+// `aws sso login`) tries again.
+type session struct {
+	mu  sync.Mutex
+	cfg *aws.Config
+}
+
+func (s *session) config(ctx context.Context) (aws.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg != nil {
+		return *s.cfg, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, usererr.WithUserMessage(err, "Failed to load AWS config")
+	}
+	if cfg.Region == "" {
+		return aws.Config{}, usererr.New(
+			"Cannot read AWS secrets because no region is configured. " +
+				"Set AWS_REGION or configure a region for your AWS profile.",
+		)
+	}
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return aws.Config{}, usererr.WithUserMessage(
+			err,
+			"Cannot read AWS secrets because no AWS credentials were found. "+
+				"Run `aws configure` or `aws sso login`, or set AWS_PROFILE.",
+		)
+	}
+	s.cfg = &cfg
+	return cfg, nil
+}
+
+// SecretsManager reads aws-sm:// references. Secrets are cached by ID, so
+// several env vars can read different JSON keys of the same secret with a
+// single request.
+type SecretsManager struct {
+	session *session
+	cache   sync.Map // secret ID -> func() (string, error)
+}
+
+func (*SecretsManager) Scheme() string {
+	return "aws-sm://"
+}
+
+func (p *SecretsManager) Read(ctx context.Context, ref string) (string, error) {
+	defer debug.FunctionTimer().End()
+	id, key, hasKey := strings.Cut(strings.TrimPrefix(ref, p.Scheme()), "#")
+	if id == "" {
+		return "", usererr.New("Invalid AWS Secrets Manager reference %q. Expected aws-sm://<secret>[#<key>]", ref)
+	}
+
+	fetch, _ := p.cache.LoadOrStore(id, sync.OnceValues(func() (string, error) {
+		cfg, err := p.session.config(ctx)
+		if err != nil {
+			return "", err
+		}
+		out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(id),
+		})
+		if err != nil {
+			return "", apiError(err, ref)
+		}
+		if out.SecretString == nil {
+			return "", usererr.New("AWS secret %s is binary, only string secrets are supported.", id)
+		}
+		return *out.SecretString, nil
+	}))
+	secret, err := fetch.(func() (string, error))()
+	if err != nil || !hasKey {
+		return secret, err
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", usererr.New("AWS secret %s is not a JSON object, so it has no key %q", id, key)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", usererr.New("AWS secret %s has no key %q", id, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// ParameterStore reads aws-ssm:// references. SecureString parameters are
+// decrypted.
+type ParameterStore struct {
+	session *session
+	cache   sync.Map // parameter name -> func() (string, error)
+}
+
+func (*ParameterStore) Scheme() string {
+	return "aws-ssm://"
+}
+
+func (p *ParameterStore) Read(ctx context.Context, ref string) (string, error) {
+	defer debug.FunctionTimer().End()
+	name := strings.TrimPrefix(ref, p.Scheme())
+	if name == "" {
+		return "", usererr.New("Invalid SSM parameter reference %q. Expected aws-ssm://<parameter>", ref)
+	}
+	// Hierarchical names always start with a slash, so both aws-ssm://app/key
+	// and aws-ssm:///app/key refer to /app/key.
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+
+	fetch, _ := p.cache.LoadOrStore(name, sync.OnceValues(func() (string, error) {
+		cfg, err := p.session.config(ctx)
+		if err != nil {
+			return "", err
+		}
+		out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", apiError(err, ref)
+		}
+		return aws.ToString(out.Parameter.Value), nil
+	}))
+	return fetch.(func() (string, error))()
+}
+
+// apiError turns the AWS errors users are most likely to hit into
+// actionable messages.
+func apiError(err error, ref string) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDeniedException":
+			return usererr.WithUserMessage(
+				err,
+				"Access denied reading %s. Check that your AWS identity is allowed to read it "+
+					"(and to decrypt it with its KMS key).",
+				ref,
+			)
+		case "ResourceNotFoundException", "ParameterNotFound":
+			return usererr.WithUserMessage(err, "%s does not exist in this AWS account and region.", ref)
+		}
+	}
+	return redact.Errorf("read %s: %w", ref, err)
+}
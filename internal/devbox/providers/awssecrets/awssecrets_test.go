@@ -0,0 +1,102 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package awssecrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// fakeAWS serves the JSON protocol used by Secrets Manager and SSM.
+func fakeAWS(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if body["SecretId"] == "forbidden" {
+				w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type": "AccessDeniedException", "message": "no"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"Name":         body["SecretId"],
+				"SecretString": `{"username": "admin", "password": "hunter2"}`,
+			})
+		case "AmazonSSM.GetParameter":
+			require.Equal(t, true, body["WithDecryption"])
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"Parameter": map[string]any{"Name": body["Name"], "Value": "value of " + body["Name"].(string)},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRead(t *testing.T) {
+	server := fakeAWS(t)
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	ctx := context.Background()
+
+	sm, ps := New()
+	got, err := sm.Read(ctx, "aws-sm://prod/db#password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", got)
+
+	got, err = sm.Read(ctx, "aws-sm://prod/db")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"username": "admin", "password": "hunter2"}`, got)
+
+	_, err = sm.Read(ctx, "aws-sm://prod/db#missing")
+	require.ErrorContains(t, err, "no key")
+
+	_, err = sm.Read(ctx, "aws-sm://forbidden")
+	userErr, ok := usererr.Extract(err)
+	require.True(t, ok)
+	require.Contains(t, userErr.Error(), "Access denied")
+
+	got, err = ps.Read(ctx, "aws-ssm://app/db/password")
+	require.NoError(t, err)
+	require.Equal(t, "value of /app/db/password", got)
+
+	got, err = ps.Read(ctx, "aws-ssm://plain")
+	require.NoError(t, err)
+	require.Equal(t, "value of plain", got)
+}
+
+func TestConfigErrorsAreNotCached(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	ctx := context.Background()
+
+	s := &session{}
+	_, err := s.config(ctx)
+	require.ErrorContains(t, err, "no region")
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	cfg, err := s.config(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "us-east-1", cfg.Region)
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package credhelper mints short-lived cloud credentials by shelling out to
+// credential helpers such as aws-vault and gcloud, and keeps them fresh while
+// long-running commands use them.
+package credhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+// gcloudTokenLifetime is how long gcloud access tokens are valid for. gcloud
+// doesn't print the expiration, but tokens are issued for an hour.
+const gcloudTokenLifetime = time.Hour
+
+// Credentials are the result of running a helper.
+type Credentials struct {
+	// Env holds the env vars that make the credentials available to tools.
+	Env map[string]string
+	// Expiration is when the credentials expire. It's zero if the helper
+	// didn't say.
+	Expiration time.Time
+
+	// AWS is set if these are AWS credentials.
+	AWS *AWSCredentials
+	// AccessToken is set if these are Google Cloud credentials.
+	AccessToken string
+}
+
+// AWSCredentials are temporary AWS credentials.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Helper mints credentials.
+type Helper interface {
+	Mint(ctx context.Context) (*Credentials, error)
+}
+
+// New returns the helper described by spec.
+func New(spec *configfile.CredentialHelper) Helper {
+	switch spec.Helper {
+	case configfile.CredentialHelperAWSVault:
+		return awsVault{profile: spec.Profile}
+	case configfile.CredentialHelperGcloud:
+		return gcloud{serviceAccount: spec.ImpersonateServiceAccount}
+	default:
+		return command{command: spec.Command}
+	}
+}
+
+// awsVault mints AWS credentials with aws-vault, which may prompt for an MFA
+// code.
+type awsVault struct {
+	profile string
+}
+
+func (a awsVault) Mint(ctx context.Context) (*Credentials, error) {
+	out, err := run(ctx, "aws-vault", "https://github.com/99designs/aws-vault#installing",
+		"export", "--format=json", a.profile)
+	if err != nil {
+		return nil, err
+	}
+	// aws-vault uses the credential_process output format.
+	var resp struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		SessionToken    string    `json:"SessionToken"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, usererr.WithUserMessage(err, "Unexpected output from aws-vault export")
+	}
+
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":     resp.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY": resp.SecretAccessKey,
+		"AWS_SESSION_TOKEN":     resp.SessionToken,
+		// aws-vault checks this to avoid running inside itself.
+		"AWS_VAULT": a.profile,
+	}
+	if !resp.Expiration.IsZero() {
+		env["AWS_CREDENTIAL_EXPIRATION"] = resp.Expiration.Format(time.RFC3339)
+	}
+	return &Credentials{
+		Env:        env,
+		Expiration: resp.Expiration,
+		AWS: &AWSCredentials{
+			AccessKeyID:     resp.AccessKeyID,
+			SecretAccessKey: resp.SecretAccessKey,
+			SessionToken:    resp.SessionToken,
+		},
+	}, nil
+}
+
+// gcloud mints a Google Cloud access token for the logged in account, or for
+// a service account it impersonates.
+type gcloud struct {
+	serviceAccount string
+}
+
+func (g gcloud) Mint(ctx context.Context) (*Credentials, error) {
+	args := []string{"auth", "print-access-token"}
+	if g.serviceAccount != "" {
+		args = append(args, "--impersonate-service-account="+g.serviceAccount)
+	}
+	expiration := time.Now().Add(gcloudTokenLifetime)
+	out, err := run(ctx, "gcloud", "https://cloud.google.com/sdk/docs/install", args...)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(out))
+	return &Credentials{
+		Env: map[string]string{
+			"CLOUDSDK_AUTH_ACCESS_TOKEN": token,
+			// Used by Terraform's Google provider.
+			"GOOGLE_OAUTH_ACCESS_TOKEN": token,
+		},
+		Expiration:  expiration,
+		AccessToken: token,
+	}, nil
+}
+
+// command runs a user-provided helper that prints
+//
+//	{"env": {"NAME": "value", ...}, "expiration": "<RFC 3339 time>"}
+type command struct {
+	command string
+}
+
+func (c command) Mint(ctx context.Context) (*Credentials, error) {
+	sh := cmdutil.GetPathOrDefault("sh", "/bin/sh")
+	out, err := run(ctx, sh, "", "-c", c.command)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Env        map[string]string `json:"env"`
+		Expiration time.Time         `json:"expiration"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, usererr.WithUserMessage(
+			err,
+			"Credential helper %q must print a JSON object with an \"env\" field",
+			c.command,
+		)
+	}
+	return &Credentials{Env: resp.Env, Expiration: resp.Expiration}, nil
+}
+
+// run runs a helper and returns its stdout. The helper's stderr is passed
+// through, since helpers may need to prompt for an MFA code or a browser
+// login.
+func run(ctx context.Context, name, installURL string, args ...string) ([]byte, error) {
+	defer debug.FunctionTimer().End()
+	if installURL != "" && !cmdutil.Exists(name) {
+		return nil, usererr.New(
+			"devbox.json uses %s to mint credentials, but it's not installed. "+
+				"Install it from %s or add it to your devbox.json with `devbox add %s`.",
+			name, installURL, name,
+		)
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, usererr.WithUserMessage(err, "Credential helper %s failed", cmd.String())
+	}
+	return stdout.Bytes(), nil
+}
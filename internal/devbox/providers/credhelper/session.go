@@ -0,0 +1,375 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package credhelper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+const (
+	// refreshMargin is how long before they expire credentials are minted
+	// again.
+	refreshMargin = 5 * time.Minute
+	// refreshInterval is how often a session checks for credentials that are
+	// about to expire. Checking regularly, rather than sleeping until the
+	// expiration, copes with the machine going to sleep.
+	refreshInterval = 30 * time.Second
+
+	// markerPrefix marks the credentials a devbox shell or script has already
+	// minted, so that devbox commands run inside it don't mint them again.
+	markerPrefix = "__DEVBOX_CREDENTIALS_"
+	// markerRefreshing is the marker value for credentials that the parent
+	// devbox process keeps fresh.
+	markerRefreshing = "refreshing"
+)
+
+// awsStaticKeys are hidden from commands when AWS credentials are served by
+// a session, since the AWS SDKs prefer them over the credentials endpoint.
+var awsStaticKeys = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_CREDENTIAL_EXPIRATION",
+}
+
+// Session holds the credentials minted for a devbox shell or script. If it
+// refreshes, it keeps them valid until it's closed:
+//
+//   - AWS credentials are served from a loopback endpoint that the AWS SDKs
+//     and CLI poll through AWS_CONTAINER_CREDENTIALS_FULL_URI.
+//   - Google Cloud access tokens are written to a file that gcloud reads
+//     through CLOUDSDK_AUTH_ACCESS_TOKEN_FILE.
+//
+// Other credentials are only minted once.
+type Session struct {
+	refresh bool
+	entries map[string]*entry
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	server    *http.Server
+	serverURL string
+	authToken string
+	tokenDir  string
+}
+
+type entry struct {
+	name   string
+	helper Helper
+
+	mu        sync.Mutex
+	creds     *Credentials
+	tokenFile string
+	// failing is set while refreshing fails, so that the failure is only
+	// reported once.
+	failing bool
+}
+
+// Start mints credentials with each helper, keyed by the name the helper has
+// in devbox.json. Helpers run one at a time because they may prompt. If
+// refresh is set, the session keeps the credentials fresh until Close is
+// called.
+func Start(ctx context.Context, helpers map[string]Helper, refresh bool) (*Session, error) {
+	defer debug.FunctionTimer().End()
+
+	s := &Session{refresh: refresh, entries: map[string]*entry{}}
+	for name, helper := range helpers {
+		creds, err := helper.Mint(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.entries[name] = &entry{name: name, helper: helper, creds: creds}
+	}
+	if !refresh || len(s.entries) == 0 {
+		return s, nil
+	}
+
+	if err := s.startServer(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.writeTokenFiles(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// The refresh loop outlives the command's context: the shell or script
+	// decides when the session ends by calling Close.
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.refreshLoop(loopCtx)
+	return s, nil
+}
+
+// Apply adds the session's credentials to env, and returns the env vars that
+// hold secrets.
+func (s *Session) Apply(env map[string]string) (secretKeys []string) {
+	for name, e := range s.entries {
+		e.mu.Lock()
+		creds := e.creds
+		tokenFile := e.tokenFile
+		e.mu.Unlock()
+
+		marker := markerEnv(name)
+		switch {
+		case s.refresh && creds.AWS != nil && s.server != nil:
+			for k, v := range creds.Env {
+				env[k] = v
+			}
+			for _, k := range awsStaticKeys {
+				delete(env, k)
+			}
+			env["AWS_CONTAINER_CREDENTIALS_FULL_URI"] = s.serverURL + "/" + url.PathEscape(name)
+			env["AWS_CONTAINER_AUTHORIZATION_TOKEN"] = s.authToken
+			env[marker] = markerRefreshing
+			secretKeys = append(secretKeys, "AWS_CONTAINER_AUTHORIZATION_TOKEN")
+			continue
+		case s.refresh && tokenFile != "":
+			for k, v := range creds.Env {
+				env[k] = v
+			}
+			// gcloud prefers CLOUDSDK_AUTH_ACCESS_TOKEN over the file, but
+			// the value would go stale. GOOGLE_OAUTH_ACCESS_TOKEN is kept for
+			// tools that can't read a file.
+			delete(env, "CLOUDSDK_AUTH_ACCESS_TOKEN")
+			env["CLOUDSDK_AUTH_ACCESS_TOKEN_FILE"] = tokenFile
+			env[marker] = markerRefreshing
+		default:
+			for k, v := range creds.Env {
+				env[k] = v
+			}
+			env[marker] = ""
+			if !creds.Expiration.IsZero() {
+				env[marker] = creds.Expiration.Format(time.RFC3339)
+			}
+		}
+		for k := range creds.Env {
+			if _, ok := env[k]; ok {
+				secretKeys = append(secretKeys, k)
+			}
+		}
+	}
+	return secretKeys
+}
+
+// Close stops refreshing the credentials and removes any files that held
+// them.
+func (s *Session) Close() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	if s.server != nil {
+		s.server.Close()
+	}
+	if s.tokenDir != "" {
+		if err := os.RemoveAll(s.tokenDir); err != nil {
+			debug.Log("credhelper: remove token files: %v", err)
+		}
+	}
+}
+
+// Inherited returns true if env comes from a devbox shell or script that
+// already minted the credentials for name, and they're still valid.
+func Inherited(name string, env map[string]string) bool {
+	marker, ok := env[markerEnv(name)]
+	if !ok {
+		return false
+	}
+	if marker == "" || marker == markerRefreshing {
+		return true
+	}
+	expiration, err := time.Parse(time.RFC3339, marker)
+	return err == nil && time.Until(expiration) > refreshMargin
+}
+
+func markerEnv(name string) string {
+	return markerPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+func (s *Session) refreshLoop(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, e := range s.entries {
+				s.refreshEntry(ctx, e)
+			}
+		}
+	}
+}
+
+// refreshEntry mints new credentials if the current ones are about to
+// expire, and returns the credentials to use.
+func (s *Session) refreshEntry(ctx context.Context, e *entry) *Credentials {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.creds.Expiration.IsZero() || time.Until(e.creds.Expiration) > refreshMargin {
+		return e.creds
+	}
+	if e.creds.AWS == nil && e.tokenFile == "" {
+		// Nothing would see the new credentials.
+		return e.creds
+	}
+
+	debug.Log("credhelper: refreshing %s credentials", e.name)
+	creds, err := e.helper.Mint(ctx)
+	if err == nil && e.tokenFile != "" {
+		err = writeTokenFile(e.tokenFile, creds.AccessToken)
+	}
+	if err != nil {
+		if !e.failing {
+			ux.Fwarning(os.Stderr, "Failed to refresh %s credentials: %v\n", e.name, err)
+		}
+		e.failing = true
+		return e.creds
+	}
+	e.failing = false
+	e.creds = creds
+	return creds
+}
+
+func (s *Session) startServer() error {
+	var aws bool
+	for _, e := range s.entries {
+		aws = aws || e.creds.AWS != nil
+	}
+	if !aws {
+		return nil
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return redact.Errorf("generate credentials endpoint token: %w", err)
+	}
+	s.authToken = hex.EncodeToString(token)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return redact.Errorf("start credentials endpoint: %w", err)
+	}
+	s.serverURL = "http://" + listener.Addr().String()
+	s.server = &http.Server{
+		Handler:           http.HandlerFunc(s.serveAWS),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			debug.Log("credhelper: credentials endpoint stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// serveAWS implements the container credentials endpoint that the AWS SDKs
+// use on ECS.
+func (s *Session) serveAWS(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(s.authToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	e, ok := s.entries[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	creds := s.refreshEntry(r.Context(), e)
+	if creds.AWS == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+		Expiration      string `json:"Expiration,omitempty"`
+	}{
+		AccessKeyID:     creds.AWS.AccessKeyID,
+		SecretAccessKey: creds.AWS.SecretAccessKey,
+		Token:           creds.AWS.SessionToken,
+	}
+	if !creds.Expiration.IsZero() {
+		resp.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		debug.Log("credhelper: write credentials: %v", err)
+	}
+}
+
+func (s *Session) writeTokenFiles() error {
+	for name, e := range s.entries {
+		if e.creds.AccessToken == "" {
+			continue
+		}
+		if s.tokenDir == "" {
+			dir, err := os.MkdirTemp("", "devbox-credentials-")
+			if err != nil {
+				return redact.Errorf("create credentials directory: %w", err)
+			}
+			s.tokenDir = dir
+		}
+		e.tokenFile = filepath.Join(s.tokenDir, markerEnv(name))
+		if err := writeTokenFile(e.tokenFile, e.creds.AccessToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTokenFile replaces the token in path without a window where gcloud
+// could read a partially written file.
+func writeTokenFile(path, token string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".token-*")
+	if err != nil {
+		return redact.Errorf("write access token: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		return redact.Errorf("write access token: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return redact.Errorf("write access token: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return redact.Errorf("write access token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package credhelper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWSVault puts an aws-vault on PATH that prints credentials expiring
+// after lifetime, and returns a function that counts how often it ran.
+func fakeAWSVault(t *testing.T, lifetime time.Duration) func() int {
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	script := "#!/bin/sh\necho x >> " + count + "\n" +
+		`echo '{"AccessKeyId":"AKIATEST","SecretAccessKey":"secret","SessionToken":"session",` +
+		`"Expiration":"` + time.Now().Add(lifetime).UTC().Format(time.RFC3339) + `"}'` + "\n"
+	err := os.WriteFile(filepath.Join(dir, "aws-vault"), []byte(script), 0o755)
+	require.NoError(t, err)
+	t.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	return func() int {
+		data, _ := os.ReadFile(count)
+		return len(data) / 2
+	}
+}
+
+func TestSessionServesAWSCredentials(t *testing.T) {
+	runs := fakeAWSVault(t, time.Minute)
+	s, err := Start(context.Background(), map[string]Helper{"aws": awsVault{profile: "dev"}}, true)
+	require.NoError(t, err)
+	defer s.Close()
+
+	env := map[string]string{"AWS_ACCESS_KEY_ID": "from-outer-shell"}
+	secretKeys := s.Apply(env)
+	require.NotContains(t, env, "AWS_ACCESS_KEY_ID")
+	require.Equal(t, "dev", env["AWS_VAULT"])
+	require.Equal(t, markerRefreshing, env["__DEVBOX_CREDENTIALS_AWS"])
+	require.Contains(t, secretKeys, "AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	require.True(t, Inherited("aws", env))
+
+	get := func(auth string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, env["AWS_CONTAINER_CREDENTIALS_FULL_URI"], nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	require.Equal(t, http.StatusUnauthorized, get("wrong").StatusCode)
+
+	resp := get(env["AWS_CONTAINER_AUTHORIZATION_TOKEN"])
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var creds map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&creds))
+	require.Equal(t, "AKIATEST", creds["AccessKeyId"])
+	require.Equal(t, "session", creds["Token"])
+
+	// The credentials expire within the refresh margin, so each request
+	// mints them again.
+	require.Equal(t, 2, runs())
+}
+
+func TestSessionStaticCredentials(t *testing.T) {
+	fakeAWSVault(t, time.Hour)
+	s, err := Start(context.Background(), map[string]Helper{
+		"aws": awsVault{profile: "dev"},
+		"custom": command{
+			command: `echo '{"env":{"API_TOKEN":"t0ken"},"expiration":"2100-01-01T00:00:00Z"}'`,
+		},
+	}, false)
+	require.NoError(t, err)
+	defer s.Close()
+
+	env := map[string]string{}
+	secretKeys := s.Apply(env)
+	require.Equal(t, "AKIATEST", env["AWS_ACCESS_KEY_ID"])
+	require.Equal(t, "t0ken", env["API_TOKEN"])
+	require.NotContains(t, env, "AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	require.Contains(t, secretKeys, "AWS_SECRET_ACCESS_KEY")
+	require.Contains(t, secretKeys, "API_TOKEN")
+	require.True(t, Inherited("custom", env))
+	require.False(t, Inherited("other", env))
+}
+
+func TestInheritedExpiring(t *testing.T) {
+	env := map[string]string{
+		markerEnv("aws"): time.Now().Add(time.Minute).Format(time.RFC3339),
+	}
+	require.False(t, Inherited("aws", env))
+}
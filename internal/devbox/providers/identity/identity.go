@@ -9,6 +9,8 @@ import (
 	"github.com/go-jose/go-jose/v4/jwt"
 	"go.jetify.com/typeid"
 	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/credstore"
+	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/pkg/api"
 	"go.jetpack.io/pkg/auth"
 	"go.jetpack.io/pkg/auth/session"
@@ -20,6 +22,24 @@ var scopes = []string{"openid", "offline_access", "email", "profile"}
 
 var cachedAccessTokenFromAPIToken *session.Token
 
+// apiTokenCredential is the key that a saved API token is stored under in the
+// credential store. It's used when DEVBOX_API_TOKEN isn't set.
+const apiTokenCredential = "api-token"
+
+// SaveAPIToken stores an API token in the OS keychain, so that it's used
+// without having to set DEVBOX_API_TOKEN.
+func SaveAPIToken(token string) error {
+	if _, err := typeid.Parse[ids.APIToken](token); err != nil {
+		return err
+	}
+	return credstore.Set(apiTokenCredential, token)
+}
+
+// DeleteAPIToken removes a token saved with SaveAPIToken.
+func DeleteAPIToken() error {
+	return credstore.Delete(apiTokenCredential)
+}
+
 func GenSession(ctx context.Context) (*session.Token, error) {
 	if t, err := getAccessTokenFromAPIToken(ctx); err != nil || t != nil {
 		return t, err
@@ -69,7 +89,14 @@ func getAccessTokenFromAPIToken(
 	if cachedAccessTokenFromAPIToken == nil {
 		apiTokenRaw := os.Getenv("DEVBOX_API_TOKEN")
 		if apiTokenRaw == "" {
-			return nil, nil
+			saved, err := credstore.Get(apiTokenCredential)
+			if err != nil {
+				if !errors.Is(err, credstore.ErrNotFound) {
+					debug.Log("identity: error reading saved API token: %v", err)
+				}
+				return nil, nil
+			}
+			apiTokenRaw = saved
 		}
 
 		apiToken, err := typeid.Parse[ids.APIToken](apiTokenRaw)
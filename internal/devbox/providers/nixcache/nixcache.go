@@ -2,7 +2,7 @@ package nixcache
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"slices"
 	"time"
 
@@ -13,6 +13,8 @@ import (
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/credstore"
+	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/devbox/providers/identity"
 	"go.jetpack.io/devbox/internal/goutil"
 	"go.jetpack.io/devbox/internal/redact"
@@ -20,46 +22,55 @@ import (
 	nixv1alpha1 "go.jetpack.io/pkg/api/gen/priv/nix/v1alpha1"
 	"go.jetpack.io/pkg/auth"
 	"go.jetpack.io/pkg/auth/session"
-	"go.jetpack.io/pkg/filecache"
 )
 
 var cachedCredentials = goutil.OnceValuesWithContext(
 	func(ctx context.Context) (AWSCredentials, error) {
-		// Adding version to caches to avoid conflicts if we want to update the schema
-		// or while working on dev.
-		cache := filecache.New[AWSCredentials](fmt.Sprintf(
-			"devbox/%s/providers/nixcache",
-			build.Version,
-		))
 		token, err := identity.GenSession(ctx)
 		if err != nil {
 			return AWSCredentials{}, err
 		}
-		creds, err := cache.GetOrSetWithTime(
-			"credentials-"+getSubOrAccessTokenHash(token),
-			func() (AWSCredentials, time.Time, error) {
-				token, err := identity.GenSession(ctx)
-				if err != nil {
-					return AWSCredentials{}, time.Time{}, err
-				}
-				client := api.NewClient(ctx, build.JetpackAPIHost(), token)
-				creds, err := client.GetAWSCredentials(ctx)
-				if err != nil {
-					return AWSCredentials{}, time.Time{}, err
-				}
-				exp := time.Time{}
-				if t := creds.GetExpiration(); t != nil {
-					exp = t.AsTime()
-				}
-				return newAWSCredentials(creds), exp, nil
-			},
-		)
+		// The credentials grant access to the user's private cache, so they're
+		// kept in the OS keychain (see credstore) rather than a plaintext file.
+		key := "nixcache-credentials-" + getSubOrAccessTokenHash(token)
+		if creds, ok := storedCredentials(key); ok {
+			return creds, nil
+		}
+
+		client := api.NewClient(ctx, build.JetpackAPIHost(), token)
+		proto, err := client.GetAWSCredentials(ctx)
 		if err != nil {
 			return AWSCredentials{}, redact.Errorf("nixcache: get credentials: %w", redact.Safe(err))
 		}
+		creds := newAWSCredentials(proto)
+		if b, err := json.Marshal(creds); err == nil {
+			if err := credstore.Set(key, string(b)); err != nil {
+				debug.Log("nixcache: error saving credentials: %v", err)
+			}
+		}
 		return creds, nil
 	})
 
+// storedCredentials returns credentials saved by a previous run, if they
+// don't expire soon.
+func storedCredentials(key string) (AWSCredentials, bool) {
+	stored, err := credstore.Get(key)
+	if err != nil {
+		if !errors.Is(err, credstore.ErrNotFound) {
+			debug.Log("nixcache: error reading saved credentials: %v", err)
+		}
+		return AWSCredentials{}, false
+	}
+	var creds AWSCredentials
+	if err := json.Unmarshal([]byte(stored), &creds); err != nil {
+		return AWSCredentials{}, false
+	}
+	if creds.Version != 1 || time.Until(creds.Expiration) < time.Minute {
+		return AWSCredentials{}, false
+	}
+	return creds, true
+}
+
 // CachedCredentials fetches short-lived credentials that grant access to the user's
 // private cache.
 func CachedCredentials(ctx context.Context) (AWSCredentials, error) {
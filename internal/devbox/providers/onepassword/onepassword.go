@@ -0,0 +1,63 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package onepassword resolves 1Password secret references such as
+// op://vault/item/field using the op CLI.
+package onepassword
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+const installURL = "https://developer.1password.com/docs/cli/get-started/"
+
+// Provider reads 1Password secret references.
+type Provider struct{}
+
+// Scheme is the prefix of 1Password secret references.
+func (Provider) Scheme() string {
+	return "op://"
+}
+
+// Interactive returns true because op may ask the desktop app to unlock the
+// vault, which prompts the user once per concurrent request.
+func (Provider) Interactive() bool {
+	return true
+}
+
+// Read returns the secret ref points to. Authentication is left to the op
+// CLI, so it works with the desktop app integration, service accounts
+// (OP_SERVICE_ACCOUNT_TOKEN) and 1Password Connect (OP_CONNECT_HOST and
+// OP_CONNECT_TOKEN).
+func (Provider) Read(ctx context.Context, ref string) (string, error) {
+	defer debug.FunctionTimer().End()
+	if !cmdutil.Exists("op") {
+		return "", usererr.New(
+			"Cannot read %s because the 1Password CLI (op) is not installed. "+
+				"Install it from %s",
+			ref,
+			installURL,
+		)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "op", "read", "--no-newline", ref)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", usererr.WithUserMessage(
+			err,
+			"Failed to read %s from 1Password: %s",
+			ref,
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+	return stdout.String(), nil
+}
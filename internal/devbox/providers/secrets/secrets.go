@@ -0,0 +1,65 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package secrets resolves env values that reference a secret stored in an
+// external secrets manager (e.g. op://vault/item/field) instead of containing
+// the secret itself.
+package secrets
+
+import (
+	"context"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/devbox/providers/awssecrets"
+	"go.jetpack.io/devbox/internal/devbox/providers/onepassword"
+	"go.jetpack.io/devbox/internal/devbox/providers/vault"
+)
+
+// Provider reads secrets from one secrets manager.
+type Provider interface {
+	// Scheme is the prefix of the references the provider handles, such as
+	// "op://".
+	Scheme() string
+	// Read returns the secret that ref points to. ref includes the scheme.
+	Read(ctx context.Context, ref string) (string, error)
+}
+
+// IsInteractive returns true if p may prompt the user while reading a secret,
+// in which case its reads must not run concurrently. Providers report this by
+// implementing an Interactive() bool method.
+func IsInteractive(p Provider) bool {
+	i, ok := p.(interface{ Interactive() bool })
+	return ok && i.Interactive()
+}
+
+// Providers returns a new provider for each secrets manager. Providers cache
+// credentials and the secrets they read, so new ones are created for each
+// environment that's computed instead of being kept for the life of the
+// process.
+func Providers() []Provider {
+	secretsManager, parameterStore := awssecrets.New()
+	return []Provider{
+		onepassword.Provider{},
+		&vault.Provider{},
+		secretsManager,
+		parameterStore,
+	}
+}
+
+// ProviderFor returns the provider in providers that handles value, if value
+// is a secret reference.
+func ProviderFor(providers []Provider, value string) (Provider, bool) {
+	for _, p := range providers {
+		if strings.HasPrefix(value, p.Scheme()) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// IsReference returns true if value is a secret reference that some provider
+// can read.
+func IsReference(value string) bool {
+	_, ok := ProviderFor(Providers(), value)
+	return ok
+}
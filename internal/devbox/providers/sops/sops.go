@@ -0,0 +1,81 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package sops decrypts files encrypted with SOPS
+// (https://github.com/getsops/sops). Decryption is done by the sops CLI, so
+// every key type it supports (age, PGP, AWS/GCP KMS, Azure Key Vault, Vault
+// transit) works without extra configuration in devbox.
+package sops
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// Formats of encrypted files, as named by the sops CLI.
+const (
+	FormatDotenv = "dotenv"
+	FormatYAML   = "yaml"
+)
+
+const installURL = "https://github.com/getsops/sops#download"
+
+// SOPS stores its metadata alongside the encrypted values: as sops_* keys in
+// dotenv files and under a top-level "sops" key in YAML files.
+var (
+	dotenvMetadata = regexp.MustCompile(`(?m)^sops_(mac|version)=`)
+	yamlMetadata   = regexp.MustCompile(`(?m)^sops:\s*$`)
+)
+
+// IsEncrypted returns true if data is a file of the given format that was
+// encrypted with SOPS.
+func IsEncrypted(data []byte, format string) bool {
+	switch format {
+	case FormatDotenv:
+		return dotenvMetadata.Match(data)
+	case FormatYAML:
+		return yamlMetadata.Match(data)
+	}
+	return false
+}
+
+// Decrypt decrypts the file at path and returns its plaintext in the same
+// format.
+func Decrypt(ctx context.Context, path, format string) ([]byte, error) {
+	defer debug.FunctionTimer().End()
+	if !cmdutil.Exists("sops") {
+		return nil, usererr.New(
+			"%s is encrypted with SOPS, but the sops CLI is not installed. "+
+				"Install it from %s or add it to your devbox.json with `devbox add sops`.",
+			path,
+			installURL,
+		)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(
+		ctx, "sops", "--decrypt",
+		"--input-type", format,
+		"--output-type", format,
+		path,
+	)
+	cmd.Stdout = stdout
+	// sops doesn't include plaintext in its errors, so this is safe to show.
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, usererr.WithUserMessage(
+			err,
+			"Failed to decrypt %s with sops: %s",
+			path,
+			strings.TrimSpace(stderr.String()),
+		)
+	}
+	return stdout.Bytes(), nil
+}
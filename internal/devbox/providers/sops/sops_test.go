@@ -0,0 +1,27 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package sops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsEncrypted(t *testing.T) {
+	tests := []struct {
+		data   string
+		format string
+		want   bool
+	}{
+		{"A=1\nsops_version=3.8.1\nsops_mac=ENC[AES256_GCM,data:x]\n", FormatDotenv, true},
+		{"A=1\nB=sops_version=3\n", FormatDotenv, false},
+		{"a: ENC[AES256_GCM,data:x]\nsops:\n    version: 3.8.1\n", FormatYAML, true},
+		{"a: 1\nnested:\n  sops: 1\n", FormatYAML, false},
+		{"sops:\n", "json", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, IsEncrypted([]byte(tt.data), tt.format), tt.data)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package vault reads secrets from HashiCorp Vault. References have the form
+// vault://<path>#<field>, where path is what you'd pass to `vault read` (for
+// KV v2 secret engines that includes the "data/" segment, for example
+// vault://secret/data/myapp#password).
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+const scheme = "vault://"
+
+// These are the variables the Vault CLI uses, so an environment that works
+// with `vault read` also works with devbox.
+const (
+	addrEnv      = "VAULT_ADDR"
+	namespaceEnv = "VAULT_NAMESPACE"
+	tokenEnv     = "VAULT_TOKEN"
+	roleIDEnv    = "VAULT_ROLE_ID"
+	secretIDEnv  = "VAULT_SECRET_ID"
+	oidcRoleEnv  = "VAULT_OIDC_ROLE"
+)
+
+const (
+	authToken   = "token"
+	authAppRole = "approle"
+	authOIDC    = "oidc"
+)
+
+// Provider reads vault:// references. It logs in at most once and reuses the
+// token for every secret it reads.
+type Provider struct {
+	mu    sync.Mutex
+	token string
+}
+
+func (*Provider) Scheme() string {
+	return scheme
+}
+
+func (p *Provider) Read(ctx context.Context, ref string) (string, error) {
+	defer debug.FunctionTimer().End()
+	path, field, _ := strings.Cut(strings.TrimPrefix(ref, scheme), "#")
+	path = strings.Trim(path, "/")
+	if path == "" || field == "" {
+		return "", usererr.New(
+			"Invalid Vault reference %q. Expected vault://<path>#<field>, "+
+				"for example vault://secret/data/myapp#password",
+			ref,
+		)
+	}
+	addr := strings.TrimRight(os.Getenv(addrEnv), "/")
+	if addr == "" {
+		return "", usererr.New("Cannot read %s because %s is not set.", ref, addrEnv)
+	}
+
+	token, err := p.login(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := request(ctx, http.MethodGet, addr+"/v1/"+path, token, nil, &secret); err != nil {
+		return "", usererr.WithUserMessage(err, "Failed to read %s from Vault", ref)
+	}
+
+	data := secret.Data
+	// KV v2 nests the secret under data.data, next to its metadata.
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", usererr.New("Vault secret %s has no field %q", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+func (p *Provider) login(ctx context.Context, addr string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" {
+		return p.token, nil
+	}
+
+	var err error
+	switch method := authMethod(); method {
+	case authToken:
+		p.token, err = existingToken()
+	case authAppRole:
+		p.token, err = appRoleLogin(ctx, addr)
+	case authOIDC:
+		p.token, err = oidcLogin(ctx)
+	default:
+		return "", usererr.New(
+			"Unknown Vault auth method %q in %s. Supported methods are %q, %q and %q.",
+			method, envir.DevboxVaultAuthMethod, authToken, authAppRole, authOIDC,
+		)
+	}
+	return p.token, err
+}
+
+// authMethod returns the configured auth method, defaulting to AppRole when
+// its credentials are present and to an existing token otherwise.
+func authMethod() string {
+	if method := os.Getenv(envir.DevboxVaultAuthMethod); method != "" {
+		return method
+	}
+	if os.Getenv(roleIDEnv) != "" && os.Getenv(secretIDEnv) != "" {
+		return authAppRole
+	}
+	return authToken
+}
+
+// existingToken returns the token from VAULT_TOKEN or, like the Vault CLI,
+// from ~/.vault-token which `vault login` writes.
+func existingToken() (string, error) {
+	if token := os.Getenv(tokenEnv); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err == nil {
+		if b, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			if token := strings.TrimSpace(string(b)); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", usererr.New(
+		"No Vault token found. Set %s, run `vault login`, or set %s to %q or %q.",
+		tokenEnv, envir.DevboxVaultAuthMethod, authAppRole, authOIDC,
+	)
+}
+
+func appRoleLogin(ctx context.Context, addr string) (string, error) {
+	roleID, secretID := os.Getenv(roleIDEnv), os.Getenv(secretIDEnv)
+	if roleID == "" || secretID == "" {
+		return "", usererr.New(
+			"Vault AppRole login requires %s and %s to be set.", roleIDEnv, secretIDEnv,
+		)
+	}
+	var res struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": roleID, "secret_id": secretID}
+	err := request(ctx, http.MethodPost, addr+"/v1/auth/approle/login", "", body, &res)
+	if err != nil {
+		return "", usererr.WithUserMessage(err, "Vault AppRole login failed")
+	}
+	return res.Auth.ClientToken, nil
+}
+
+// oidcLogin uses the Vault CLI for the OIDC flow because it needs to open a
+// browser and listen for the callback.
+func oidcLogin(ctx context.Context) (string, error) {
+	if !cmdutil.Exists("vault") {
+		return "", usererr.New("Vault OIDC login requires the vault CLI to be installed.")
+	}
+	args := []string{"login", "-method=oidc", "-token-only", "-no-store"}
+	if role := os.Getenv(oidcRoleEnv); role != "" {
+		args = append(args, "role="+role)
+	}
+	stdout := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "vault", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	// Let the user see the login URL and any prompts.
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", usererr.WithUserMessage(err, "Vault OIDC login failed")
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func request(ctx context.Context, method, url, token string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if ns := os.Getenv(namespaceEnv); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	res, err := httpclient.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errRes struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&errRes)
+		msg := strings.Join(errRes.Errors, "; ")
+		switch res.StatusCode {
+		case http.StatusForbidden:
+			return redact.Errorf("permission denied (check your token's policies): %s", msg)
+		case http.StatusNotFound:
+			return redact.Errorf("secret not found")
+		}
+		return redact.Errorf("vault returned %s: %s", redact.Safe(res.Status), msg)
+	}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return redact.Errorf("decode vault response: %w", err)
+	}
+	return nil
+}
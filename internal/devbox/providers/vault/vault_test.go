@@ -0,0 +1,78 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"auth": {"client_token": "approle-token"}}`))
+	})
+	mux.HandleFunc("GET /v1/secret/data/app", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {
+			"data": {"password": "hunter2", "port": 5432},
+			"metadata": {"version": 3}
+		}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestReadAppRole(t *testing.T) {
+	server := testServer(t)
+	t.Setenv(addrEnv, server.URL)
+	t.Setenv(roleIDEnv, "role")
+	t.Setenv(secretIDEnv, "secret")
+	t.Setenv(envir.DevboxVaultAuthMethod, "")
+
+	p := &Provider{}
+	ctx := context.Background()
+
+	got, err := p.Read(ctx, "vault://secret/data/app#password")
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", got)
+
+	got, err = p.Read(ctx, "vault://secret/data/app#port")
+	require.NoError(t, err)
+	require.Equal(t, "5432", got)
+
+	_, err = p.Read(ctx, "vault://secret/data/app#missing")
+	require.ErrorContains(t, err, "no field")
+
+	_, err = p.Read(ctx, "vault://secret/data/app")
+	require.ErrorContains(t, err, "Invalid Vault reference")
+}
+
+func TestReadPermissionDenied(t *testing.T) {
+	server := testServer(t)
+	t.Setenv(addrEnv, server.URL)
+	t.Setenv(tokenEnv, "wrong-token")
+	t.Setenv(envir.DevboxVaultAuthMethod, authToken)
+
+	_, err := (&Provider{}).Read(context.Background(), "vault://secret/data/app#password")
+	require.ErrorContains(t, err, "permission denied")
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+const (
+	// recordEnvVar is the directory that devbox shell --record keeps its
+	// snapshots in, so that the shell can add its own with
+	// devbox log record-env.
+	recordEnvVar = "__DEVBOX_RECORD"
+	// recordReportEnvVar is the path of the report.
+	recordReportEnvVar = "__DEVBOX_RECORD_REPORT"
+	recordStartFile    = "start.json"
+)
+
+// The stages of a devbox shell that --record snapshots. The host and devbox
+// environments are recorded before the shell starts, and the shell records
+// the others.
+const (
+	RecordAfterRC     = "after-rc"
+	RecordBeforeHooks = "before-hooks"
+	RecordAfterHooks  = "after-hooks"
+)
+
+// recordStart is what devbox knows before the shell starts.
+type recordStart struct {
+	Time       time.Time         `json:"time"`
+	ProjectDir string            `json:"project_dir"`
+	Shell      string            `json:"shell"`
+	Host       map[string]string `json:"host"`
+	Devbox     map[string]string `json:"devbox"`
+	Hooks      []HookPlan        `json:"hooks"`
+}
+
+// recordSnapshot is the environment of the shell at a stage.
+type recordSnapshot struct {
+	Time time.Time         `json:"time"`
+	Env  map[string]string `json:"env"`
+}
+
+// startRecording saves the host and devbox environments for a shell that's
+// recorded to report, and returns the directory of the snapshots. The shell
+// finds it in recordEnvVar, which startRecording adds to env.
+func (d *Devbox) startRecording(report, shell string, host, env map[string]string) (string, error) {
+	report, err := filepath.Abs(report)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	dir, err := os.MkdirTemp("", "devbox-record-")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	start := recordStart{
+		Time:       time.Now(),
+		ProjectDir: d.projectDir,
+		Shell:      shell,
+		Host:       host,
+		Devbox:     env,
+		Hooks:      d.HookPlans(),
+	}
+	if err := writeRecordFile(filepath.Join(dir, recordStartFile), start); err != nil {
+		return "", err
+	}
+	env[recordEnvVar] = dir
+	env[recordReportEnvVar] = report
+	return dir, nil
+}
+
+// RecordShellStage snapshots the environment of a recorded devbox shell at
+// a stage. After the hooks, it writes the report.
+func RecordShellStage(stage string) error {
+	dir := os.Getenv(recordEnvVar)
+	if dir == "" {
+		return usererr.New("This shell isn't recorded. Start one with devbox shell --record.")
+	}
+	snapshot := recordSnapshot{Time: time.Now(), Env: envir.PairsToMap(os.Environ())}
+	for _, k := range []string{recordEnvVar, recordReportEnvVar} {
+		delete(snapshot.Env, k)
+	}
+	if err := writeRecordFile(filepath.Join(dir, stage+".json"), snapshot); err != nil {
+		return err
+	}
+	if stage != RecordAfterHooks {
+		return nil
+	}
+
+	report, err := os.Create(os.Getenv(recordReportEnvVar))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer report.Close()
+	if err := writeRecordReport(report, dir); err != nil {
+		return err
+	}
+	return errors.WithStack(report.Close())
+}
+
+func writeRecordFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, data, 0o600))
+}
+
+func readRecordFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(data, v))
+}
+
+// writeRecordReport writes how the environment changed at each stage of the
+// recorded shell. A stage that the shell didn't record is left out. Secrets
+// are masked, so that the report can be shared.
+func writeRecordReport(w io.Writer, dir string) error {
+	var start recordStart
+	if err := readRecordFile(filepath.Join(dir, recordStartFile), &start); err != nil {
+		return err
+	}
+	var secrets redact.Secrets
+	if !envir.ShowSecrets() {
+		secrets = secretsIn(start.Devbox)
+	}
+	diff := func(before, after map[string]string) {
+		writeEnvDiff(w, before, after, secrets)
+	}
+	snapshots := map[string]*recordSnapshot{}
+	for _, stage := range []string{RecordAfterRC, RecordBeforeHooks, RecordAfterHooks} {
+		var snapshot recordSnapshot
+		if err := readRecordFile(filepath.Join(dir, stage+".json"), &snapshot); err == nil {
+			snapshots[stage] = &snapshot
+		}
+	}
+
+	fmt.Fprintf(w, "Devbox shell environment report\n\n")
+	fmt.Fprintf(w, "Project: %s\n", start.ProjectDir)
+	fmt.Fprintf(w, "Shell: %s\n", start.Shell)
+	fmt.Fprintf(w, "Recorded: %s\n", start.Time.Format(time.RFC3339))
+
+	fmt.Fprintf(w, "\n== Devbox environment, compared to the host ==\n")
+	diff(start.Host, start.Devbox)
+
+	if rc := snapshots[RecordAfterRC]; rc != nil {
+		fmt.Fprintf(w, "\n== Shell rc files, compared to the host ==\n")
+		fmt.Fprintf(w, "Devbox sets its environment again after the rc files run.\n")
+		diff(start.Host, rc.Env)
+	}
+	if before := snapshots[RecordBeforeHooks]; before != nil {
+		fmt.Fprintf(w, "\n== Shell before the hooks, compared to the devbox environment ==\n")
+		diff(start.Devbox, before.Env)
+	}
+
+	fmt.Fprintf(w, "\n== Hooks ==\n")
+	before, after := snapshots[RecordBeforeHooks], snapshots[RecordAfterHooks]
+	if before != nil && after != nil {
+		fmt.Fprintf(w, "The pre_init and init hooks took %s.\n", after.Time.Sub(before.Time).Round(time.Millisecond))
+	}
+	for _, plan := range start.Hooks {
+		if plan.Phase == configfile.HookPostPackage || plan.Phase == configfile.HookPreScript {
+			continue // they don't run when the shell starts
+		}
+		for _, hook := range plan.Hooks {
+			fmt.Fprintf(w, "%s: %s\n", plan.Phase, hook.Source)
+		}
+	}
+	if before != nil && after != nil {
+		fmt.Fprintf(w, "\nChanged by the hooks:\n")
+		diff(before.Env, after.Env)
+	}
+	return nil
+}
+
+// writeEnvDiff writes the variables that were added, modified and removed
+// from before to after, and the PATH entries that were added and removed.
+// Variables that each shell sets for itself, such as PWD, are left out.
+func writeEnvDiff(w io.Writer, before, after map[string]string, secrets redact.Secrets) {
+	var added, modified, removed []string
+	for _, k := range sortedKeys(after) {
+		if shellStateVars[k] {
+			continue
+		}
+		if old, ok := before[k]; !ok {
+			added = append(added, fmt.Sprintf("%s=%s", k, after[k]))
+		} else if old != after[k] && k != "PATH" {
+			modified = append(modified, fmt.Sprintf("%s: %s -> %s", k, old, after[k]))
+		}
+	}
+	for _, k := range sortedKeys(before) {
+		if _, ok := after[k]; !ok && !shellStateVars[k] {
+			removed = append(removed, k)
+		}
+	}
+	oldPath, newPath := filepath.SplitList(before["PATH"]), filepath.SplitList(after["PATH"])
+	var pathAdded, pathRemoved []string
+	for _, p := range newPath {
+		if !slices.Contains(oldPath, p) {
+			pathAdded = append(pathAdded, p)
+		}
+	}
+	for _, p := range oldPath {
+		if !slices.Contains(newPath, p) {
+			pathRemoved = append(pathRemoved, p)
+		}
+	}
+
+	if len(added)+len(modified)+len(removed)+len(pathAdded)+len(pathRemoved) == 0 {
+		fmt.Fprintf(w, "No changes.\n")
+		return
+	}
+	writeList := func(header string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", header, len(lines))
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", secrets.Mask(strings.ReplaceAll(line, "\n", `\n`)))
+		}
+	}
+	writeList("Added", added)
+	writeList("Modified", modified)
+	writeList("Removed", removed)
+	writeList("PATH entries added", pathAdded)
+	writeList("PATH entries removed", pathRemoved)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReport(t *testing.T) {
+	d := devboxForTesting(t)
+	report := filepath.Join(t.TempDir(), "report.txt")
+	host := map[string]string{"PATH": "/usr/bin:/bin", "HOME": "/home/me", "KEEP": "1"}
+	env := map[string]string{
+		"PATH":        "/nix/store/abc-hello/bin:/usr/bin:/bin",
+		"HOME":        "/home/me",
+		"KEEP":        "1",
+		"TOKEN":       "supersecretvalue",
+		secretKeysEnv: "TOKEN",
+	}
+	dir, err := d.startRecording(report, "/bin/bash", host, env)
+	require.NoError(t, err)
+	require.Equal(t, dir, env[recordEnvVar])
+
+	// The shell's rc files drop KEEP, and the init hooks set FOO.
+	now := time.Now()
+	rc := map[string]string{"PATH": "/usr/bin:/bin", "HOME": "/home/me", "PWD": "/tmp"}
+	require.NoError(t, writeRecordFile(filepath.Join(dir, RecordAfterRC+".json"), recordSnapshot{Time: now, Env: rc}))
+	before := map[string]string{"PATH": env["PATH"], "HOME": "/home/me", "TOKEN": env["TOKEN"], secretKeysEnv: "TOKEN"}
+	require.NoError(t, writeRecordFile(filepath.Join(dir, RecordBeforeHooks+".json"), recordSnapshot{Time: now, Env: before}))
+	after := map[string]string{"PATH": env["PATH"], "HOME": "/home/me", "TOKEN": env["TOKEN"], secretKeysEnv: "TOKEN", "FOO": "bar"}
+	require.NoError(t, writeRecordFile(filepath.Join(dir, RecordAfterHooks+".json"), recordSnapshot{Time: now.Add(1500 * time.Millisecond), Env: after}))
+
+	var b strings.Builder
+	require.NoError(t, writeRecordReport(&b, dir))
+	out := b.String()
+	require.Contains(t, out, "PATH entries added (1):\n  /nix/store/abc-hello/bin\n")
+	require.Contains(t, out, "Removed (1):\n  KEEP\n")
+	require.Contains(t, out, "The pre_init and init hooks took 1.5s.\n")
+	require.Contains(t, out, "Changed by the hooks:\nAdded (1):\n  FOO=bar\n")
+	require.NotContains(t, out, "supersecretvalue")
+	require.NotContains(t, out, "PWD")
+
+	// The shell writes the report after the hooks.
+	t.Setenv(recordEnvVar, dir)
+	t.Setenv(recordReportEnvVar, report)
+	require.NoError(t, RecordShellStage(RecordAfterHooks))
+	data, err := os.ReadFile(report)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Devbox shell environment report")
+}
@@ -1,11 +1,78 @@
 package devbox
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
 )
 
+// RefreshExports returns the commands that update the environment of the
+// shell that runs devbox in place, after devbox.json or devbox.lock changed.
+// Unlike EnvExports, it only exports the variables that changed, and it
+// unsets the ones that were removed from devbox.json.
+func (d *Devbox) RefreshExports(ctx context.Context, shell string) (string, error) {
+	dialect, err := ParseShellDialect(shell)
+	if err != nil {
+		return "", err
+	}
+	if dialect == DialectJSON {
+		return "", usererr.New("devbox refresh can't print JSON. Use devbox shellenv --shell json instead.")
+	}
+
+	before := envir.PairsToMap(os.Environ())
+	// Variables from devbox.json don't override the ones that devbox set
+	// before, so they're removed to compute the environment like a new
+	// shell would.
+	for k := range before {
+		if name, ok := strings.CutPrefix(k, devboxSetPrefix); ok {
+			os.Unsetenv(name)
+			os.Unsetenv(k)
+		}
+	}
+	after, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	set, unset := refreshDiff(before, after)
+	exports := []string{}
+	if len(set) > 0 {
+		exports = append(exports, exportEnv(dialect, set))
+	}
+	if u := unsetEnv(dialect, unset); u != "" {
+		exports = append(exports, u)
+	}
+	return strings.Join(exports, "\n"), nil
+}
+
+// refreshDiff returns the variables that changed between two environments,
+// and the ones that devbox set before but not anymore.
+func refreshDiff(before, after map[string]string) (set map[string]string, unset []string) {
+	set = map[string]string{}
+	for k, v := range after {
+		if old, ok := before[k]; !ok || old != v {
+			set[k] = v
+		}
+	}
+	for _, k := range sortedKeys(before) {
+		name, ok := strings.CutPrefix(k, devboxSetPrefix)
+		if !ok {
+			continue
+		}
+		if _, ok := after[k]; !ok {
+			unset = append(unset, k)
+		}
+		if _, ok := after[name]; !ok {
+			unset = append(unset, name)
+		}
+	}
+	return set, unset
+}
+
 func (d *Devbox) IsDirenvActive() bool {
 	return strings.TrimPrefix(os.Getenv("DIRENV_DIR"), "-") == d.projectDir
 }
@@ -41,18 +108,31 @@ func (d *Devbox) refreshAliasName() string {
 }
 
 func (d *Devbox) refreshCmd() string {
-	devboxCmd := fmt.Sprintf("shellenv --preserve-path-stack -c %q", d.projectDir)
+	return d.refreshCmdFor(detectShellDialect())
+}
+
+func (d *Devbox) refreshCmdFor(dialect ShellDialect) string {
+	devboxCmd := fmt.Sprintf("refresh -c %q", d.projectDir)
 	if d.isGlobal() {
 		devboxCmd = "global shellenv --preserve-path-stack -r"
 	}
-	if isFishShell() {
+	switch dialect {
+	case DialectFish:
 		return fmt.Sprintf(`eval (devbox %s  | string collect)`, devboxCmd)
+	case DialectNushell:
+		// Nushell loads the whole environment as JSON, since it can't
+		// evaluate the changes that devbox refresh prints.
+		if !d.isGlobal() {
+			devboxCmd = fmt.Sprintf("shellenv --preserve-path-stack -c %q", d.projectDir)
+		}
+		return nuRefreshCmd(devboxCmd)
 	}
 	return fmt.Sprintf(`eval "$(devbox %s)" && hash -r`, devboxCmd)
 }
 
-func (d *Devbox) refreshAlias() string {
-	if isFishShell() {
+func (d *Devbox) refreshAlias(dialect ShellDialect) string {
+	switch dialect {
+	case DialectFish:
 		return fmt.Sprintf(
 			`if not type %[1]s >/dev/null 2>&1
 	export %[2]s='%[3]s'
@@ -60,8 +140,23 @@ func (d *Devbox) refreshAlias() string {
 end`,
 			d.refreshAliasName(),
 			d.refreshAliasEnvVar(),
-			d.refreshCmd(),
+			d.refreshCmdFor(dialect),
+		)
+	case DialectNushell:
+		// Nushell's aliases can't change the environment, so refresh is
+		// a custom command that does.
+		return fmt.Sprintf(
+			`$env.%[2]s = %[3]s
+def --env %[1]s [] {
+  %[4]s
+}`,
+			d.refreshAliasName(),
+			d.refreshAliasEnvVar(),
+			nuQuote(d.refreshCmdFor(dialect)),
+			d.refreshCmdFor(dialect),
 		)
+	case DialectJSON:
+		return ""
 	}
 	return fmt.Sprintf(
 		`if ! type %[1]s >/dev/null 2>&1; then
@@ -70,6 +165,6 @@ end`,
 fi`,
 		d.refreshAliasName(),
 		d.refreshAliasEnvVar(),
-		d.refreshCmd(),
+		d.refreshCmdFor(dialect),
 	)
 }
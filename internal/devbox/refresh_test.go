@@ -0,0 +1,41 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshDiff(t *testing.T) {
+	before := map[string]string{
+		"HOME":                      "/home/me",
+		"PATH":                      "/old/bin:/usr/bin",
+		"KEPT":                      "1",
+		devboxSetPrefix + "KEPT":    "1",
+		"EDITED":                    "old",
+		devboxSetPrefix + "EDITED":  "1",
+		"REMOVED":                   "1",
+		devboxSetPrefix + "REMOVED": "1",
+	}
+	after := map[string]string{
+		"HOME":                     "/home/me",
+		"PATH":                     "/new/bin:/usr/bin",
+		"KEPT":                     "1",
+		devboxSetPrefix + "KEPT":   "1",
+		"EDITED":                   "new",
+		devboxSetPrefix + "EDITED": "1",
+		"ADDED":                    "1",
+		devboxSetPrefix + "ADDED":  "1",
+	}
+	set, unset := refreshDiff(before, after)
+	require.Equal(t, map[string]string{
+		"PATH":                    "/new/bin:/usr/bin",
+		"EDITED":                  "new",
+		"ADDED":                   "1",
+		devboxSetPrefix + "ADDED": "1",
+	}, set)
+	require.Equal(t, []string{devboxSetPrefix + "REMOVED", "REMOVED"}, unset)
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// remoteProjectsDir is where `devbox remote run` syncs projects to, relative
+// to the remote user's home directory.
+const remoteProjectsDir = ".cache/devbox/remote"
+
+// RemoteRun runs a command in the project's environment on another machine
+// over SSH. It installs the packages locally, syncs devbox.json, devbox.lock
+// and local flakes to the host, and copies the profile's closure to the
+// host's Nix store, so that devbox on the host installs the exact store paths
+// of this machine instead of resolving or building them again.
+//
+// The host needs Nix and devbox in the PATH of non-interactive SSH sessions.
+func (d *Devbox) RemoteRun(ctx context.Context, opts devopt.RemoteRunOpts) error {
+	defer debug.FunctionTimer().End()
+
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return err
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = d.defaultRemoteDir()
+	}
+
+	ux.Finfo(d.stderr, "Syncing the project to %s:%s\n", opts.Host, dir)
+	archive, err := d.remoteArchive()
+	if err != nil {
+		return err
+	}
+	sync := sshCommand(ctx, opts.Host, false /*tty*/, "mkdir -p "+shellQuote(dir)+" && tar -xf - -C "+shellQuote(dir))
+	sync.Stdin = archive
+	sync.Stderr = d.stderr
+	if err := sync.Run(); err != nil {
+		return usererr.WithUserMessage(err, "Unable to sync the project to %s.", opts.Host)
+	}
+
+	if !opts.NoCopy {
+		if err := d.copyClosureToRemote(ctx, opts.Host); err != nil {
+			return err
+		}
+	}
+
+	remoteCmd := "cd " + shellQuote(dir) + " && devbox run"
+	if d.environment != "" && d.environment != "dev" {
+		remoteCmd += " --environment " + shellQuote(d.environment)
+	}
+	remoteCmd += " --"
+	for _, arg := range opts.Cmd {
+		remoteCmd += " " + shellQuote(arg)
+	}
+	run := sshCommand(ctx, opts.Host, isatty.IsTerminal(os.Stdin.Fd()), remoteCmd)
+	run.Stdin = os.Stdin
+	run.Stdout = os.Stdout
+	run.Stderr = os.Stderr
+	return usererr.NewExecError(run.Run())
+}
+
+// defaultRemoteDir returns a directory on the host that's unique to the
+// project's local directory, so that projects with the same name don't share
+// it.
+func (d *Devbox) defaultRemoteDir() string {
+	return path.Join(remoteProjectsDir, filepath.Base(d.projectDir)+"-"+cachehash.Bytes6([]byte(d.projectDir)))
+}
+
+// copyClosureToRemote copies the profile's closure to the host's store. The
+// copy is skipped when the host is a different system, since the store paths
+// in the lockfile are then for another platform.
+func (d *Devbox) copyClosureToRemote(ctx context.Context, host string) error {
+	profile, err := d.profilePath()
+	if err != nil {
+		return err
+	}
+	storePath, err := filepath.EvalSymlinks(profile)
+	if err != nil {
+		// The project has no packages.
+		debug.Log("remote: no profile to copy: %v", err)
+		return nil
+	}
+
+	var system bytes.Buffer
+	check := sshCommand(ctx, host, false, /*tty*/
+		"nix --extra-experimental-features nix-command eval --impure --raw --expr builtins.currentSystem")
+	check.Stdout = &system
+	if err := check.Run(); err != nil {
+		return usererr.WithUserMessage(err, "Unable to run nix on %s. Install Nix on the host, and make sure it's in the PATH of SSH sessions.", host)
+	}
+	if remoteSystem := strings.TrimSpace(system.String()); remoteSystem != nix.System() {
+		ux.Fwarning(d.stderr, "%s is %s, not %s, so it installs the packages itself.\n", host, remoteSystem, nix.System())
+		return nil
+	}
+
+	ux.Finfo(d.stderr, "Copying the packages to %s\n", host)
+	if err := nix.CopyClosure(ctx, d.stderr, "ssh-ng://"+host, storePath); err != nil {
+		return usererr.WithUserMessage(err, "Unable to copy the packages to %s. Use --no-copy to install them on the host instead.", host)
+	}
+	return nil
+}
+
+// remoteArchive returns a tar archive of the files that the host needs to
+// install the environment.
+func (d *Devbox) remoteArchive() (io.Reader, error) {
+	files := []string{configfile.DefaultName, "devbox.lock"}
+	for _, dir := range d.getLocalFlakesDirs() {
+		dir = filepath.Clean(dir)
+		if filepath.IsAbs(dir) || strings.HasPrefix(dir, "..") {
+			ux.Fwarning(d.stderr, "The local flake in %s is outside the project, so it isn't synced.\n", dir)
+			continue
+		}
+		files = append(files, dir)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range files {
+		root := filepath.Join(d.projectDir, name)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(d.projectDir, path)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			header := &tar.Header{
+				Name: filepath.ToSlash(rel),
+				Mode: int64(info.Mode().Perm()),
+				Size: int64(len(data)),
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err = tw.Write(data)
+			return err
+		})
+		if err != nil {
+			return nil, redact.Errorf("archive %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, redact.Errorf("archive project: %w", err)
+	}
+	return &buf, nil
+}
+
+func sshCommand(ctx context.Context, host string, tty bool, remoteCmd string) *exec.Cmd {
+	var args []string
+	if tty {
+		args = append(args, "-t")
+	}
+	args = append(args, "--", host, remoteCmd)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
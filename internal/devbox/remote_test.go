@@ -0,0 +1,42 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteArchive(t *testing.T) {
+	d := devboxForTesting(t)
+	require.NoError(t, os.WriteFile(filepath.Join(d.projectDir, "devbox.lock"), []byte("{}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(d.projectDir, "README.md"), []byte("not synced"), 0o644))
+
+	archive, err := d.remoteArchive()
+	require.NoError(t, err)
+	var names []string
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	require.Equal(t, []string{"devbox.json", "devbox.lock"}, names)
+}
+
+func TestDefaultRemoteDir(t *testing.T) {
+	d := devboxForTesting(t)
+	dir := d.defaultRemoteDir()
+	require.True(t, strings.HasPrefix(dir, remoteProjectsDir+"/"+filepath.Base(d.projectDir)+"-"), dir)
+	require.NotEqual(t, dir, devboxForTesting(t).defaultRemoteDir())
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/sandbox"
+)
+
+var storePathRegexp = regexp.MustCompile(`/nix/store/[0-9a-z]{32}-[^/:\s"']+`)
+
+// sandboxWrapper returns the command that runs a script in a sandbox. The
+// sandbox can only read the store paths that the environment refers to (and
+// their dependencies), the runx binaries and the paths the project declares,
+// and it can only write to the project directory.
+func (d *Devbox) sandboxWrapper(ctx context.Context, env map[string]string) ([]string, error) {
+	roots := []string{}
+	for _, value := range env {
+		for _, path := range storePathRegexp.FindAllString(value, -1) {
+			if _, err := os.Stat(path); err == nil {
+				roots = append(roots, path)
+			}
+		}
+	}
+	if profile, err := filepath.EvalSymlinks(filepath.Join(d.projectDir, nix.ProfilePath)); err == nil {
+		roots = append(roots, profile)
+	}
+	slices.Sort(roots)
+	roots = slices.Compact(roots)
+
+	var readOnly []string
+	if len(roots) > 0 {
+		closure, err := nix.StorePathClosure(ctx, roots...)
+		if err != nil {
+			return nil, err
+		}
+		readOnly = append(readOnly, closure...)
+	}
+	readOnly = append(readOnly, runXTargets(d.projectDir)...)
+
+	home, _ := os.UserHomeDir()
+	cfg := d.cfg.Root.Sandbox()
+	for _, path := range cfg.Paths {
+		readOnly = append(readOnly, d.configPath(path, home))
+	}
+
+	return sandbox.Wrap(sandbox.Policy{
+		ProjectDir: d.projectDir,
+		ReadOnly:   readOnly,
+		Home:       home,
+		Network:    cfg.Network,
+	}, nil)
+}
+
+// configPath resolves a path from devbox.json. Relative paths are relative to
+// the project directory, and a leading ~ is the home directory.
+func (d *Devbox) configPath(path, home string) string {
+	if rest, ok := strings.CutPrefix(path, "~"); ok && home != "" {
+		return filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(d.projectDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// runXTargets returns the directories that hold the runx binaries linked
+// into the project.
+func runXTargets(projectDir string) []string {
+	binDir := filepath.Join(projectDir, ".devbox", "virtenv", "runx", "bin")
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if target, err := filepath.EvalSymlinks(filepath.Join(binDir, entry.Name())); err == nil {
+			dirs = append(dirs, filepath.Dir(target))
+		}
+	}
+	return dirs
+}
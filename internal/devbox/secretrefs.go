@@ -0,0 +1,128 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/providers/secrets"
+	"go.jetpack.io/devbox/internal/redact"
+	"golang.org/x/sync/errgroup"
+)
+
+// secretRefHashPrefix marks env vars whose value was resolved from a secret
+// reference. The marker holds a hash of the reference, so that commands run
+// inside the shell can reuse the resolved value from their environment
+// instead of asking the secrets provider again. Nothing is cached on disk.
+const secretRefHashPrefix = "__DEVBOX_SECRET_REF_"
+
+// secretKeysEnv lists the env vars that hold secrets, separated by commas.
+// Their values are masked in devbox's output, including by devbox commands
+// that run inside a devbox shell or script.
+const secretKeysEnv = "__DEVBOX_SECRET_KEYS"
+
+// markSecrets adds keys to the list of secret env vars in env.
+func markSecrets(env map[string]string, keys ...string) {
+	all := strings.FieldsFunc(env[secretKeysEnv], func(r rune) bool { return r == ',' })
+	for key := range env {
+		if _, ok := env[secretRefHashPrefix+key]; ok {
+			all = append(all, key)
+		}
+	}
+	all = append(all, keys...)
+	if len(all) == 0 {
+		return
+	}
+	slices.Sort(all)
+	env[secretKeysEnv] = strings.Join(slices.Compact(all), ",")
+}
+
+// secretsIn returns the values of the secret env vars listed in env.
+func secretsIn(env map[string]string) redact.Secrets {
+	var values []string
+	for _, key := range strings.Split(env[secretKeysEnv], ",") {
+		if v, ok := env[key]; ok {
+			values = append(values, v)
+		}
+	}
+	return redact.NewSecrets(values...)
+}
+
+// resolveSecretReferences replaces values in env that reference a secret
+// (e.g. op://vault/item/field or vault://secret/data/app#key) with the secret
+// itself.
+func (d *Devbox) resolveSecretReferences(ctx context.Context, env map[string]string) error {
+	defer debug.FunctionTimer().End()
+
+	type secretRef struct {
+		key, ref, hash string
+		provider       secrets.Provider
+	}
+	providers := secrets.Providers()
+	var pending, interactive []secretRef
+	for key, value := range env {
+		provider, ok := secrets.ProviderFor(providers, value)
+		if !ok {
+			continue
+		}
+		hash := cachehash.Bytes([]byte(value))
+		if cached, ok := os.LookupEnv(key); ok && os.Getenv(secretRefHashPrefix+key) == hash {
+			env[key] = cached
+			env[secretRefHashPrefix+key] = hash
+			continue
+		}
+		ref := secretRef{key: key, ref: value, hash: hash, provider: provider}
+		if secrets.IsInteractive(provider) {
+			interactive = append(interactive, ref)
+		} else {
+			pending = append(pending, ref)
+		}
+	}
+	if len(pending) == 0 && len(interactive) == 0 {
+		return nil
+	}
+
+	var mu sync.Mutex
+	resolved := map[string]string{}
+	read := func(ctx context.Context, ref secretRef) error {
+		value, err := ref.provider.Read(ctx, ref.ref)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		resolved[ref.key] = value
+		return nil
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	for _, ref := range pending {
+		group.Go(func() error { return read(ctx, ref) })
+	}
+	// Interactive providers are read one at a time so that the user isn't
+	// asked to authorize several requests at once.
+	group.Go(func() error {
+		for _, ref := range interactive {
+			if err := read(ctx, ref); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	for _, ref := range slices.Concat(pending, interactive) {
+		env[ref.key] = resolved[ref.key]
+		env[secretRefHashPrefix+ref.key] = ref.hash
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/cachehash"
+)
+
+func TestResolveSecretReferencesReusesShellValue(t *testing.T) {
+	ref := "op://dev/database/password"
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv(secretRefHashPrefix+"DB_PASSWORD", cachehash.Bytes([]byte(ref)))
+	// Make sure we'd fail if we tried to call the op CLI.
+	t.Setenv("PATH", t.TempDir())
+
+	env := map[string]string{
+		"DB_PASSWORD": ref,
+		"DB_USER":     "admin",
+	}
+	require.NoError(t, (&Devbox{}).resolveSecretReferences(context.Background(), env))
+	require.Equal(t, map[string]string{
+		"DB_PASSWORD":                       "hunter2",
+		"DB_USER":                           "admin",
+		secretRefHashPrefix + "DB_PASSWORD": cachehash.Bytes([]byte(ref)),
+	}, env)
+
+	// A changed reference must be resolved again.
+	env["DB_PASSWORD"] = "op://prod/database/password"
+	require.Error(t, (&Devbox{}).resolveSecretReferences(context.Background(), env))
+}
+
+func TestResolveSecretReferencesReadsOnePasswordSerially(t *testing.T) {
+	// Fake op CLI that fails if another read is in progress, and otherwise
+	// prints the last path element of the reference.
+	bin := t.TempDir()
+	lock := filepath.Join(t.TempDir(), "lock")
+	script := "#!/bin/sh\n" +
+		"mkdir " + lock + " 2>/dev/null || exit 1\n" +
+		"sleep 0.05\n" +
+		"printf '%s' \"${3##*/}\"\n" +
+		"rmdir " + lock + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "op"), []byte(script), 0o755))
+	t.Setenv("PATH", bin+":/bin:/usr/bin")
+
+	env := map[string]string{
+		"A": "op://dev/item/a",
+		"B": "op://dev/item/b",
+		"C": "op://dev/item/c",
+	}
+	require.NoError(t, (&Devbox{}).resolveSecretReferences(context.Background(), env))
+	for key, want := range map[string]string{"A": "a", "B": "b", "C": "c"} {
+		require.Equal(t, want, env[key])
+		require.Contains(t, env, secretRefHashPrefix+key)
+	}
+}
+
+func TestMarkSecrets(t *testing.T) {
+	env := map[string]string{
+		"DB_PASSWORD":                       "hunter2-resolved",
+		secretRefHashPrefix + "DB_PASSWORD": "hash",
+		"API_TOKEN":                         "from-sops-file",
+		"DB_USER":                           "admin",
+		secretKeysEnv:                       "OLD_SECRET",
+		"OLD_SECRET":                        "inherited-secret",
+	}
+	markSecrets(env, "API_TOKEN")
+	require.Equal(t, "API_TOKEN,DB_PASSWORD,OLD_SECRET", env[secretKeysEnv])
+
+	masked := secretsIn(env).Mask("admin:hunter2-resolved from-sops-file inherited-secret")
+	require.Equal(t, "admin:******** ******** ********", masked)
+}
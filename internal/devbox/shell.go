@@ -5,6 +5,7 @@ package devbox
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"fmt"
 	"io/fs"
@@ -20,6 +21,8 @@ import (
 	"go.jetpack.io/devbox/internal/shellgen"
 	"go.jetpack.io/devbox/internal/telemetry"
 
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/nix"
@@ -34,6 +37,10 @@ var shellrcTmpl = template.Must(template.New("shellrc").Parse(shellrcText))
 var fishrcText string
 var fishrcTmpl = template.Must(template.New("shellrc_fish").Parse(fishrcText))
 
+//go:embed shellrc_nu.tmpl
+var nurcText string
+var nurcTmpl = template.Must(template.New("shellrc_nu").Parse(nurcText))
+
 type name string
 
 const (
@@ -42,9 +49,13 @@ const (
 	shZsh     name = "zsh"
 	shKsh     name = "ksh"
 	shFish    name = "fish"
+	shNushell name = "nu"
 	shPosix   name = "posix"
 )
 
+// shellrcEnvVar is the path of the devbox shellrc in the devbox shell.
+const shellrcEnvVar = "__DEVBOX_SHELLRC"
+
 var ErrNoRecognizableShellFound = errors.New("SHELL in undefined, and couldn't find any common shells in PATH")
 
 // TODO consider splitting this struct's functionality so that there is a simpler
@@ -64,6 +75,10 @@ type DevboxShell struct {
 
 	// shellStartTime is the unix timestamp for when the command was invoked
 	shellStartTime time.Time
+
+	// record makes the shellrc snapshot the environment for
+	// devbox shell --record.
+	record bool
 }
 
 type ShellOption func(*DevboxShell)
@@ -113,6 +128,8 @@ func shellPath(devbox *Devbox) (path string, err error) {
 		fmt.Sprintf("%s#bashInteractive", nix.FlakeNixpkgs(devbox.cfg.NixPkgsCommitHash())),
 	)
 	cmd.Args = append(cmd.Args, nix.ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, nix.StoreFlags()...)
+	cmd.Args = append(cmd.Args, nix.SubstituterFlags()...)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -122,6 +139,8 @@ func shellPath(devbox *Devbox) (path string, err error) {
 	// install bashInteractive in nix/store without creating a symlink to local directory (--no-link)
 	cmd = exec.Command("nix", "build", bashNixStorePath, "--no-link")
 	cmd.Args = append(cmd.Args, nix.ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, nix.StoreFlags()...)
+	cmd.Args = append(cmd.Args, nix.SubstituterFlags()...)
 	err = cmd.Run()
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -162,6 +181,10 @@ func initShellBinaryFields(path string) *DevboxShell {
 	case "fish":
 		shell.name = shFish
 		shell.userShellrcPath = fishConfig()
+	case "nu":
+		// Like fish, nushell reads its own config, and runs the devbox
+		// shellrc after it.
+		shell.name = shNushell
 	case "dash", "ash", "shell":
 		shell.name = shPosix
 		shell.userShellrcPath = os.Getenv(envir.Env)
@@ -216,9 +239,9 @@ func fishConfig() string {
 	return xdg.ConfigSubpath("fish/config.fish")
 }
 
-func (s *DevboxShell) Run() error {
+func (s *DevboxShell) Run(ctx context.Context) error {
 	var cmd *exec.Cmd
-	shellrc, err := s.writeDevboxShellrc()
+	shellrc, err := s.writeDevboxShellrc(ctx)
 	if err != nil {
 		// We don't have a good fallback here, since all the variables we need for anything to work
 		// are in the shellrc file. For now let's fail. Later on, we should remove the vars from the
@@ -236,6 +259,9 @@ func (s *DevboxShell) Run() error {
 		env[k] = v
 	}
 	env["SHELL"] = s.binPath
+	// The shellrc's modification time is when the environment was last
+	// applied, which the shell compares devbox.json and devbox.lock to.
+	env[shellrcEnvVar] = shellrc
 
 	cmd = exec.Command(s.binPath)
 	cmd.Env = envir.MapToPairs(env)
@@ -245,15 +271,13 @@ func (s *DevboxShell) Run() error {
 	cmd.Stderr = os.Stderr
 
 	debug.Log("Executing shell %s with args: %v", s.binPath, cmd.Args)
-	err = cmd.Run()
+	err = cmdutil.RunProcessGroup(cmd)
 
 	// If the error is an ExitError, this means the shell started up fine but there was
-	// an error from executing a shell command or script.
-	//
-	// This could be from one of the generated shellrc commands, but more likely is from
-	// a user's command or script. So, we want to return nil for this.
+	// an error from executing a shell command or script, or the shell exited with a
+	// status, as with `exit 3`. Devbox exits with the same code.
 	if exitErr := (&exec.ExitError{}); errors.As(err, &exitErr) {
-		return nil
+		return usererr.NewExecError(err)
 	}
 
 	// This means that there was an error from devbox's code or nix's code. Not a user
@@ -273,11 +297,24 @@ func (s *DevboxShell) shellRCOverrides(shellrc string) (extraEnv map[string]stri
 		extraEnv = map[string]string{"ENV": shellescape.Quote(shellrc)}
 	case shFish:
 		extraArgs = []string{"-C", ". " + shellrc}
+	case shNushell:
+		extraArgs = []string{"-e", "source " + nuQuote(shellrc)}
 	}
 	return extraEnv, extraArgs
 }
 
-func (s *DevboxShell) writeDevboxShellrc() (path string, err error) {
+// dialect returns the syntax of the shell's shellrc.
+func (s *DevboxShell) dialect() ShellDialect {
+	switch s.name {
+	case shFish:
+		return DialectFish
+	case shNushell:
+		return DialectNushell
+	}
+	return DialectPOSIX
+}
+
+func (s *DevboxShell) writeDevboxShellrc(ctx context.Context) (path string, err error) {
 	// We need a temp dir (as opposed to a temp file) because zsh uses
 	// ZDOTDIR to point to a new directory containing the .zshrc.
 	tmp, err := os.MkdirTemp("", "devbox")
@@ -311,8 +348,19 @@ func (s *DevboxShell) writeDevboxShellrc() (path string, err error) {
 	}()
 
 	tmpl := shellrcTmpl
-	if s.name == shFish {
+	hookExports := ""
+	switch s.name {
+	case shFish:
 		tmpl = fishrcTmpl
+	case shNushell:
+		tmpl = nurcTmpl
+	}
+	if dialect := s.dialect(); dialect != DialectPOSIX {
+		// The hooks are POSIX shell scripts, so they run in sh now and
+		// the shellrc exports their changes to the environment.
+		if hookExports, err = s.devbox.hookExports(ctx, dialect, s.env); err != nil {
+			return "", err
+		}
 	}
 
 	err = tmpl.Execute(shellrcf, struct {
@@ -320,24 +368,30 @@ func (s *DevboxShell) writeDevboxShellrc() (path string, err error) {
 		OriginalInit     string
 		OriginalInitPath string
 		HooksFilePath    string
+		HookExports      string
 		ShellStartTime   string
 		HistoryFile      string
 		ExportEnv        string
+		Record           bool
 
 		RefreshAliasName   string
 		RefreshCmd         string
 		RefreshAliasEnvVar string
+		RefreshAlias       string
 	}{
 		ProjectDir:         s.projectDir,
 		OriginalInit:       string(bytes.TrimSpace(userShellrc)),
 		OriginalInitPath:   s.userShellrcPath,
 		HooksFilePath:      shellgen.ScriptPath(s.projectDir, shellgen.HooksFilename),
+		HookExports:        hookExports,
 		ShellStartTime:     telemetry.FormatShellStart(s.shellStartTime),
 		HistoryFile:        strings.TrimSpace(s.historyFile),
-		ExportEnv:          exportify(s.env),
+		ExportEnv:          exportEnv(s.dialect(), s.env),
+		Record:             s.record,
 		RefreshAliasName:   s.devbox.refreshAliasName(),
-		RefreshCmd:         s.devbox.refreshCmd(),
+		RefreshCmd:         s.devbox.refreshCmdFor(s.dialect()),
 		RefreshAliasEnvVar: s.devbox.refreshAliasEnvVar(),
+		RefreshAlias:       s.devbox.refreshAlias(s.dialect()),
 	})
 	if err != nil {
 		return "", fmt.Errorf("execute shellrc template: %v", err)
@@ -4,6 +4,7 @@
 package devbox
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"io/fs"
@@ -70,7 +71,7 @@ func testWriteDevboxShellrc(t *testing.T, testdirs []string) {
 				projectDir:      "/path/to/projectDir",
 				userShellrcPath: test.shellrcPath,
 			}
-			gotPath, err := s.writeDevboxShellrc()
+			gotPath, err := s.writeDevboxShellrc(context.Background())
 			if err != nil {
 				t.Fatal("Got writeDevboxShellrc error:", err)
 			}
@@ -0,0 +1,235 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/shellgen"
+)
+
+// ShellDialect is the syntax of the commands that devbox prints to activate
+// an environment, such as the output of devbox shellenv.
+type ShellDialect string
+
+const (
+	// DialectPOSIX is for bash, zsh, ksh, dash and other POSIX shells.
+	DialectPOSIX   ShellDialect = "posix"
+	DialectFish    ShellDialect = "fish"
+	DialectNushell ShellDialect = "nu"
+	// DialectJSON prints a JSON object of the variables, for shells and
+	// tools that load it with their own commands.
+	DialectJSON ShellDialect = "json"
+)
+
+// ParseShellDialect returns the dialect of a shell's name or path, such as
+// "zsh" or "/usr/bin/fish". An empty name is the shell that runs devbox.
+func ParseShellDialect(shell string) (ShellDialect, error) {
+	switch filepath.Base(shell) {
+	case "", ".":
+		return detectShellDialect(), nil
+	case "posix", "sh", "bash", "zsh", "ksh", "dash", "ash":
+		return DialectPOSIX, nil
+	case "fish":
+		return DialectFish, nil
+	case "nu", "nushell":
+		return DialectNushell, nil
+	case "json":
+		return DialectJSON, nil
+	}
+	return "", usererr.New("Unsupported shell %q. Use one of bash, zsh, sh, fish, nu or json.", shell)
+}
+
+// detectShellDialect returns the dialect of the shell that runs devbox.
+// Nushell and fish set NU_VERSION and FISH_VERSION, which are more reliable
+// than SHELL, since it's the login shell.
+func detectShellDialect() ShellDialect {
+	switch {
+	case os.Getenv("NU_VERSION") != "":
+		return DialectNushell
+	case isFishShell():
+		return DialectFish
+	case filepath.Base(os.Getenv(envir.Shell)) == "nu":
+		return DialectNushell
+	}
+	return DialectPOSIX
+}
+
+// exportEnv formats vars as commands that export them in a shell. Values are
+// always literal strings, without variable expansion or command
+// substitution.
+func exportEnv(dialect ShellDialect, vars map[string]string) string {
+	switch dialect {
+	case DialectFish:
+		return fishExports(vars)
+	case DialectNushell:
+		return nuExports(vars)
+	case DialectJSON:
+		data, _ := json.MarshalIndent(vars, "", "  ")
+		return string(data)
+	}
+	return exportify(vars)
+}
+
+// unsetEnv formats commands that remove variables from a shell.
+func unsetEnv(dialect ShellDialect, names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	switch dialect {
+	case DialectFish:
+		return "set -e " + strings.Join(names, " ") + ";"
+	case DialectNushell:
+		return "hide-env --ignore-errors " + strings.Join(names, " ")
+	case DialectJSON:
+		return ""
+	}
+	return "unset " + strings.Join(names, " ") + ";"
+}
+
+// fishExports sets the variables globally. Fish splits variables whose name
+// ends in PATH on colons, so those are set to the list of their paths.
+func fishExports(vars map[string]string) string {
+	var b strings.Builder
+	for _, k := range sortedKeys(vars) {
+		values := []string{vars[k]}
+		if strings.HasSuffix(k, "PATH") {
+			values = filepath.SplitList(vars[k])
+		}
+		b.WriteString("set -gx " + k)
+		for _, v := range values {
+			b.WriteString(" " + fishQuote(v))
+		}
+		b.WriteString(";\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// nuReadOnlyVars are variables that nushell doesn't allow load-env to set.
+var nuReadOnlyVars = map[string]bool{"PWD": true, "FILE_PWD": true, "CURRENT_FILE": true}
+
+// nuExports loads the variables with load-env. PATH is a list in nushell,
+// so it's set to the list of its paths.
+func nuExports(vars map[string]string) string {
+	var b strings.Builder
+	b.WriteString("load-env {\n")
+	for _, k := range sortedKeys(vars) {
+		if nuReadOnlyVars[k] {
+			continue
+		}
+		b.WriteString("  " + nuQuote(k) + ": ")
+		if k == "PATH" {
+			paths := filepath.SplitList(vars[k])
+			for i, p := range paths {
+				paths[i] = nuQuote(p)
+			}
+			b.WriteString("[" + strings.Join(paths, ", ") + "]\n")
+		} else {
+			b.WriteString(nuQuote(vars[k]) + "\n")
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// nuQuote returns a nushell raw string, which has no escapes. The number of
+// #s grows until the string can't end the literal early.
+func nuQuote(s string) string {
+	hashes := "#"
+	for strings.Contains(s, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + s + "'" + hashes
+}
+
+// hookEnv runs the project's init hooks in sh, since they're POSIX shell
+// scripts, and returns the variables that they set or unset. It's for
+// shells that can't source the hooks themselves, such as fish and nushell.
+// The hooks' output goes to stderr, so that it doesn't mix with the exports.
+func (d *Devbox) hookEnv(ctx context.Context, env map[string]string) (set map[string]string, unset []string, err error) {
+	hooks := shellgen.ScriptPath(d.projectDir, shellgen.HooksFilename)
+	cmd := exec.CommandContext(ctx, "sh", "-c", `. "$1" >&2 && env -0`, "sh", hooks)
+	cmd.Dir = d.projectDir
+	cmd.Env = envir.MapToPairs(env)
+	cmd.Stderr = d.stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil, redact.Errorf("run init hooks: %w", err)
+	}
+
+	after := map[string]string{}
+	for _, kv := range bytes.Split(out, []byte{0}) {
+		if k, v, ok := strings.Cut(string(kv), "="); ok {
+			after[k] = v
+		}
+	}
+	set = map[string]string{}
+	for k, v := range after {
+		if old, ok := env[k]; (!ok || old != v) && !shellStateVars[k] {
+			set[k] = v
+		}
+	}
+	for _, k := range sortedKeys(env) {
+		if _, ok := after[k]; !ok && !shellStateVars[k] {
+			unset = append(unset, k)
+		}
+	}
+	return set, unset, nil
+}
+
+// shellStateVars are set by sh itself, rather than by the hooks.
+var shellStateVars = map[string]bool{"PWD": true, "OLDPWD": true, "SHLVL": true, "_": true}
+
+// hookExports returns the commands that apply the init hooks' changes to
+// the environment in a shell of another dialect than sh.
+func (d *Devbox) hookExports(ctx context.Context, dialect ShellDialect, env map[string]string) (string, error) {
+	set, unset, err := d.hookEnv(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	exports := []string{}
+	if len(set) > 0 {
+		exports = append(exports, exportEnv(dialect, set))
+	}
+	if u := unsetEnv(dialect, unset); u != "" {
+		exports = append(exports, u)
+	}
+	return strings.Join(exports, "\n"), nil
+}
+
+// mergeHookEnv applies the init hooks' changes to env, for dialects whose
+// output is a single object.
+func (d *Devbox) mergeHookEnv(ctx context.Context, env map[string]string) error {
+	set, unset, err := d.hookEnv(ctx, env)
+	if err != nil {
+		return err
+	}
+	for k, v := range set {
+		env[k] = v
+	}
+	for _, k := range unset {
+		delete(env, k)
+	}
+	return nil
+}
+
+// nuRefreshCmd loads the environment from a JSON export, since nushell
+// can't evaluate the output of a command.
+func nuRefreshCmd(devboxCmd string) string {
+	return fmt.Sprintf(`^devbox %s --shell json | from json | update PATH { split row (char esep) } | load-env`, devboxCmd)
+}
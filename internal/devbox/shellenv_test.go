@@ -0,0 +1,155 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/shellgen"
+)
+
+func TestParseShellDialect(t *testing.T) {
+	for shell, want := range map[string]ShellDialect{
+		"bash":          DialectPOSIX,
+		"/bin/zsh":      DialectPOSIX,
+		"dash":          DialectPOSIX,
+		"/usr/bin/fish": DialectFish,
+		"nu":            DialectNushell,
+		"nushell":       DialectNushell,
+		"json":          DialectJSON,
+	} {
+		got, err := ParseShellDialect(shell)
+		require.NoError(t, err, shell)
+		require.Equal(t, want, got, shell)
+	}
+	_, err := ParseShellDialect("powershell")
+	require.Error(t, err)
+}
+
+// conformanceShells are the shells that every dialect's exports are checked
+// in. Shells that aren't installed are skipped.
+var conformanceShells = []struct {
+	bin     string
+	dialect ShellDialect
+	args    []string
+}{
+	{"bash", DialectPOSIX, []string{"--norc", "-c"}},
+	{"dash", DialectPOSIX, []string{"-c"}},
+	{"zsh", DialectPOSIX, []string{"-f", "-c"}},
+	{"fish", DialectFish, []string{"--no-config", "-c"}},
+	{"nu", DialectNushell, []string{"--no-config-file", "-c"}},
+}
+
+// TestExportEnvConformance evaluates the exports in each shell, and checks
+// that the environment of the commands that the shell runs has the literal
+// values.
+func TestExportEnvConformance(t *testing.T) {
+	envBin, err := exec.LookPath("env")
+	if err != nil {
+		t.Skip("env isn't installed")
+	}
+	vars := map[string]string{
+		"DEVBOX_TEST_PLAIN":  "value",
+		"DEVBOX_TEST_QUOTES": `it's "quoted" \n \' '#`,
+		"DEVBOX_TEST_EXPAND": "$HOME `whoami` $(whoami) {a,b} *",
+		"DEVBOX_TEST_SPACES": "  leading and trailing  ",
+		"DEVBOX_TEST_EMPTY":  "",
+		"PATH":               "/devbox/bin:/path with spaces/bin:" + filepath.Dir(envBin),
+	}
+
+	for _, shell := range conformanceShells {
+		t.Run(shell.bin, func(t *testing.T) {
+			bin, err := exec.LookPath(shell.bin)
+			if err != nil {
+				t.Skipf("%s isn't installed", shell.bin)
+			}
+			script := exportEnv(shell.dialect, vars) + "\n" +
+				unsetEnv(shell.dialect, []string{"DEVBOX_TEST_UNSET"}) + "\n"
+			if shell.dialect == DialectNushell {
+				script += "^"
+			}
+			script += envBin + " -0"
+
+			cmd := exec.Command(bin, append(shell.args, script)...)
+			cmd.Env = append(os.Environ(), "DEVBOX_TEST_UNSET=1")
+			out, err := cmd.Output()
+			require.NoError(t, err, "script:\n%s", script)
+
+			got := map[string]string{}
+			for _, kv := range bytes.Split(out, []byte{0}) {
+				if k, v, ok := strings.Cut(string(kv), "="); ok {
+					got[k] = v
+				}
+			}
+			for k, v := range vars {
+				require.Contains(t, got, k)
+				require.Equal(t, v, got[k], k)
+			}
+			require.NotContains(t, got, "DEVBOX_TEST_UNSET")
+		})
+	}
+}
+
+func TestExportEnvFormats(t *testing.T) {
+	vars := map[string]string{"A": "it's", "PATH": "/a:/b"}
+	require.Equal(t, "export A=\"it's\";\nexport PATH=\"/a:/b\";", exportEnv(DialectPOSIX, vars))
+	require.Equal(t, "set -gx A 'it\\'s';\nset -gx PATH '/a' '/b';", exportEnv(DialectFish, vars))
+	require.Equal(t, "load-env {\n  r#'A'#: r#'it's'#\n  r#'PATH'#: [r#'/a'#, r#'/b'#]\n}", exportEnv(DialectNushell, vars))
+	require.JSONEq(t, `{"A": "it's", "PATH": "/a:/b"}`, exportEnv(DialectJSON, vars))
+
+	require.Equal(t, "unset A B;", unsetEnv(DialectPOSIX, []string{"A", "B"}))
+	require.Equal(t, "set -e A B;", unsetEnv(DialectFish, []string{"A", "B"}))
+	require.Equal(t, "hide-env --ignore-errors A B", unsetEnv(DialectNushell, []string{"A", "B"}))
+	require.Empty(t, unsetEnv(DialectJSON, []string{"A"}))
+}
+
+func TestNuQuote(t *testing.T) {
+	require.Equal(t, "r#'a'#", nuQuote("a"))
+	require.Equal(t, "r##'a'#b'##", nuQuote("a'#b"))
+}
+
+func TestHookEnv(t *testing.T) {
+	d := devboxForTesting(t)
+	var stderr bytes.Buffer
+	d.stderr = &stderr
+	hooks := shellgen.ScriptPath(d.projectDir, shellgen.HooksFilename)
+	require.NoError(t, os.MkdirAll(filepath.Dir(hooks), 0o755))
+	err := os.WriteFile(hooks, []byte(`echo "Welcome to $(basename "$PWD")"
+export DEVBOX_TEST_SET="from hook"
+export DEVBOX_TEST_CHANGED="$DEVBOX_TEST_CHANGED:hook"
+unset DEVBOX_TEST_GONE
+`), 0o644)
+	require.NoError(t, err)
+
+	env := map[string]string{
+		"PATH":                os.Getenv("PATH"),
+		"DEVBOX_TEST_CHANGED": "before",
+		"DEVBOX_TEST_GONE":    "1",
+		"DEVBOX_TEST_KEPT":    "1",
+	}
+	set, unset, err := d.hookEnv(context.Background(), env)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"DEVBOX_TEST_SET":     "from hook",
+		"DEVBOX_TEST_CHANGED": "before:hook",
+	}, set)
+	require.Equal(t, []string{"DEVBOX_TEST_GONE"}, unset)
+	require.Equal(t, "Welcome to "+filepath.Base(d.projectDir)+"\n", stderr.String())
+
+	fish, err := d.hookExports(context.Background(), DialectFish, env)
+	require.NoError(t, err)
+	require.Equal(t, "set -gx DEVBOX_TEST_CHANGED 'before:hook';\nset -gx DEVBOX_TEST_SET 'from hook';\nset -e DEVBOX_TEST_GONE;", fish)
+
+	require.NoError(t, d.mergeHookEnv(context.Background(), env))
+	require.Equal(t, "from hook", env["DEVBOX_TEST_SET"])
+	require.NotContains(t, env, "DEVBOX_TEST_GONE")
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// maxUnsignedPathsShown limits how many paths the error lists, since a
+// package with an unsigned dependency can have many.
+const maxUnsignedPathsShown = 10
+
+// verifySignatures checks that every store path of the project's packages
+// that was substituted from a binary cache is signed by one of the keys in
+// the project's signature_policy. Nix checks signatures too, but against the
+// keys in nix.conf, which the project doesn't control.
+func (d *Devbox) verifySignatures(ctx context.Context) error {
+	keys := d.cfg.Root.PublicKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+	defer debug.FunctionTimer().End()
+
+	var storePaths []string
+	for _, pkg := range lo.Filter(d.InstallablePackages(), devpkg.IsNix) {
+		paths, err := pkg.GetStorePaths(ctx, d.stderr)
+		if err != nil {
+			return err
+		}
+		storePaths = append(storePaths, paths...)
+	}
+	if len(storePaths) == 0 {
+		return nil
+	}
+	infos, err := nix.PathInfosRecursive(ctx, storePaths...)
+	if err != nil {
+		return err
+	}
+	return checkSignatures(infos, keys)
+}
+
+func checkSignatures(infos []nix.PathInfo, keys []nix.PublicKey) error {
+	var unsigned []string
+	for _, info := range infos {
+		if !info.NeedsSignature() {
+			continue
+		}
+		if signers := info.SignedBy(keys); len(signers) > 0 {
+			debug.Log("signatures: %s is signed by %s", info.Path, strings.Join(signers, ", "))
+			continue
+		}
+		problem := "unsigned"
+		if len(info.Signatures) > 0 {
+			names := lo.Map(info.Signatures, func(sig string, _ int) string {
+				name, _, _ := strings.Cut(sig, ":")
+				return name
+			})
+			problem = "signed by " + strings.Join(lo.Uniq(names), ", ")
+		}
+		unsigned = append(unsigned, fmt.Sprintf("%s (%s)", info.Path, problem))
+	}
+	if len(unsigned) == 0 {
+		return nil
+	}
+
+	slices.Sort(unsigned)
+	shown := unsigned
+	if len(shown) > maxUnsignedPathsShown {
+		shown = shown[:maxUnsignedPathsShown]
+	}
+	msg := strings.Join(shown, "\n  ")
+	if more := len(unsigned) - len(shown); more > 0 {
+		msg += fmt.Sprintf("\n  and %d more", more)
+	}
+	trusted := lo.Map(keys, func(key nix.PublicKey, _ int) string { return key.Name })
+	return usererr.New(
+		"The signature_policy in devbox.json requires packages to be signed by %s, "+
+			"but these store paths aren't:\n  %s\n\n"+
+			"They may come from a binary cache you don't trust. "+
+			"Delete them with `nix store delete <path>` and configure Nix to only "+
+			"substitute from caches that sign with a trusted key.",
+		strings.Join(trusted, " or "), msg,
+	)
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestCheckSignatures(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key, err := nix.ParsePublicKey("cache.acme.dev-1:" + base64.StdEncoding.EncodeToString(pub))
+	require.NoError(t, err)
+
+	sign := func(info nix.PathInfo) nix.PathInfo {
+		fingerprint, err := info.Fingerprint()
+		require.NoError(t, err)
+		info.Signatures = append(info.Signatures,
+			"cache.acme.dev-1:"+base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(fingerprint))))
+		return info
+	}
+	narHash := "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"
+	signed := sign(nix.PathInfo{Path: "/nix/store/aaa-hello", NarHash: narHash, NarSize: 8})
+	local := nix.PathInfo{Path: "/nix/store/bbb-local", NarHash: narHash, Ultimate: true}
+	require.NoError(t, checkSignatures([]nix.PathInfo{signed, local}, []nix.PublicKey{key}))
+
+	unsigned := nix.PathInfo{Path: "/nix/store/ccc-unsigned", NarHash: narHash}
+	other := nix.PathInfo{Path: "/nix/store/ddd-other", NarHash: narHash, Signatures: []string{"cache.nixos.org-1:abc="}}
+	err = checkSignatures([]nix.PathInfo{signed, unsigned, other}, []nix.PublicKey{key})
+	require.ErrorContains(t, err, "/nix/store/ccc-unsigned (unsigned)")
+	require.ErrorContains(t, err, "/nix/store/ddd-other (signed by cache.nixos.org-1)")
+	require.NotContains(t, err.Error(), "aaa-hello")
+}
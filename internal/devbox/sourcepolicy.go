@@ -0,0 +1,190 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// sourcePolicy is an organization's policy on where packages can come from.
+// Each list is a set of patterns, which match exactly (ignoring case) or, if
+// they end in "*", by prefix. An empty list allows anything.
+type sourcePolicy struct {
+	// Sources are the flake repositories packages can be installed from,
+	// such as "github:NixOS/nixpkgs", "github:acme/*" or
+	// "https://git.acme.internal/*". Versioned packages come from the
+	// nixpkgs commit they resolve to, and runx packages from the GitHub
+	// repository that publishes them.
+	Sources []string `json:"sources,omitempty"`
+	// Registries are the package search services that versioned packages
+	// can be resolved with.
+	Registries []string `json:"registries,omitempty"`
+	// Caches are the binary caches devbox can tell Nix to use.
+	Caches []string `json:"caches,omitempty"`
+	// Message is added to errors, for example to say how to request a new
+	// source.
+	Message string `json:"message,omitempty"`
+
+	path string
+}
+
+func orgSourcePolicyPath() string {
+	return orgPolicyPath(envir.DevboxSourcePolicy, "source-policy.json")
+}
+
+func loadSourcePolicy() (*sourcePolicy, error) {
+	policy := &sourcePolicy{path: orgSourcePolicyPath()}
+	found, err := readOrgPolicy(policy.path, envir.DevboxSourcePolicy, policy)
+	if err != nil || !found {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func matchSource(patterns []string, source string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	source = strings.ToLower(source)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(source, prefix) {
+			return true
+		}
+		if source == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// reject returns an error explaining that the policy doesn't allow
+// something. what completes the sentence "... isn't allowed by the policy".
+func (p *sourcePolicy) reject(what string, allowed []string) error {
+	msg := fmt.Sprintf(
+		"%s isn't allowed by the package source policy in %s.\nAllowed: %s",
+		what, p.path, strings.Join(allowed, ", "),
+	)
+	if p.Message != "" {
+		msg += "\n\n" + p.Message
+	}
+	return usererr.New("%s", msg)
+}
+
+// enforceSourcePolicy fails if a package comes from a source that the
+// organization's policy doesn't allow. The registries that resolve versioned
+// packages are checked by the lockfile, with CheckRegistry, before it asks
+// them.
+func (d *Devbox) enforceSourcePolicy() error {
+	policy, err := loadSourcePolicy()
+	if err != nil || policy == nil {
+		return err
+	}
+	for _, pkg := range d.InstallablePackages() {
+		if err := policy.checkPackage(d.lockfile, pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckRegistry returns an error if the organization's policy doesn't allow
+// resolving packages with the search service at host.
+func (d *Devbox) CheckRegistry(host string) error {
+	policy, err := loadSourcePolicy()
+	if err != nil || policy == nil {
+		return err
+	}
+	return policy.checkRegistry(host)
+}
+
+func (p *sourcePolicy) checkRegistry(host string) error {
+	if matchSource(p.Registries, host) {
+		return nil
+	}
+	return p.reject("Resolving packages with "+host, p.Registries)
+}
+
+func (p *sourcePolicy) checkPackage(lockfile lock.Locker, pkg *devpkg.Package) error {
+	source, err := packageSource(lockfile, pkg)
+	if err != nil {
+		return err
+	}
+	if source == "" || matchSource(p.Sources, source) {
+		return nil
+	}
+	return p.reject(fmt.Sprintf("%s comes from %s, which", pkg.Raw, source), p.Sources)
+}
+
+// packageSource returns where a package is installed from, in the form used
+// by source policies.
+func packageSource(lockfile lock.Locker, pkg *devpkg.Package) (string, error) {
+	if pkg.IsRunX() {
+		repo, _, _ := strings.Cut(strings.TrimPrefix(pkg.Raw, "runx:"), "@")
+		return "github:" + repo, nil
+	}
+
+	raw := pkg.Raw
+	if !pkgtype.IsFlake(raw) {
+		locked, err := lockfile.Resolve(pkg.Raw)
+		if err != nil {
+			return "", err
+		}
+		raw = locked.ResolvedFor(nix.System())
+	}
+	installable, err := flake.ParseInstallable(raw)
+	if err != nil {
+		debug.Log("source policy: parse %q: %v", raw, err)
+		return raw, nil
+	}
+	return refSource(installable.Ref), nil
+}
+
+func refSource(ref flake.Ref) string {
+	switch ref.Type {
+	case flake.TypeGitHub:
+		if ref.Host != "" {
+			return fmt.Sprintf("https://%s/%s/%s", ref.Host, ref.Owner, ref.Repo)
+		}
+		return fmt.Sprintf("github:%s/%s", ref.Owner, ref.Repo)
+	case flake.TypePath:
+		return "path:" + ref.Path
+	case flake.TypeIndirect:
+		return "flake:" + ref.ID
+	default:
+		return ref.URL
+	}
+}
+
+// allowedCaches drops the binary caches the organization's policy doesn't
+// allow.
+func (d *Devbox) allowedCaches(uris []string) []string {
+	policy, err := loadSourcePolicy()
+	if err != nil {
+		debug.Log("source policy: not using caches: %v", err)
+		return nil
+	}
+	if policy == nil {
+		return uris
+	}
+	var allowed []string
+	for _, uri := range uris {
+		if matchSource(policy.Caches, uri) {
+			allowed = append(allowed, uri)
+			continue
+		}
+		debug.Log("source policy: not using cache %s, which isn't allowed by %s", uri, policy.path)
+	}
+	return allowed
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// testLocker is a lockfile that only has the packages it's created with.
+type testLocker map[string]*lock.Package
+
+func (l testLocker) BinaryCaches() []string                    { return nil }
+func (l testLocker) Get(pkg string) *lock.Package              { return l[pkg] }
+func (l testLocker) LegacyNixpkgsPath(string) string           { return "" }
+func (l testLocker) ProjectDir() string                        { return "/project" }
+func (l testLocker) Resolve(pkg string) (*lock.Package, error) { return l[pkg], nil }
+
+func TestPackageSource(t *testing.T) {
+	lockfile := testLocker{
+		"go@1.22": {Resolved: "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#go"},
+	}
+	tests := map[string]string{
+		"go@1.22":                                  "github:NixOS/nixpkgs",
+		"runx:golangci/golangci-lint@latest":       "github:golangci/golangci-lint",
+		"github:acme/tools#lint":                   "github:acme/tools",
+		"nixpkgs#hello":                            "flake:nixpkgs",
+		"path:./flakes/tools#lint":                 "path:./flakes/tools",
+		"git+https://git.acme.internal/tools#lint": "https://git.acme.internal/tools",
+	}
+	for raw, want := range tests {
+		t.Run(raw, func(t *testing.T) {
+			got, err := packageSource(lockfile, devpkg.PackageFromStringWithDefaults(raw, lockfile))
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func TestSourcePolicyCheckPackage(t *testing.T) {
+	lockfile := testLocker{}
+	policy := &sourcePolicy{
+		Sources: []string{"github:NixOS/nixpkgs", "github:acme/*"},
+		Message: "Ask #platform to allow new sources.",
+		path:    "/etc/devbox/source-policy.json",
+	}
+
+	check := func(raw string) error {
+		return policy.checkPackage(lockfile, devpkg.PackageFromStringWithDefaults(raw, lockfile))
+	}
+	require.NoError(t, check("github:nixos/nixpkgs/nixpkgs-unstable#hello"))
+	require.NoError(t, check("github:acme/tools#lint"))
+
+	err := check("github:evil/tools#lint")
+	require.ErrorContains(t, err, "github:evil/tools")
+	require.ErrorContains(t, err, "/etc/devbox/source-policy.json")
+	require.ErrorContains(t, err, "Ask #platform")
+}
+
+func TestMatchSource(t *testing.T) {
+	require.True(t, matchSource(nil, "anything"))
+	require.True(t, matchSource([]string{"https://cache.acme.internal*"}, "https://cache.acme.internal/main"))
+	require.False(t, matchSource([]string{"https://cache.nixos.org"}, "https://cache.acme.internal"))
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/devbox/envpath"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// ShellLayer is the environment of a project in the stack of nested devbox
+// shells.
+type ShellLayer struct {
+	ProjectDir string `json:"project_dir"`
+	// Path is the PATH entries that the project's environment adds.
+	Path []string `json:"path"`
+}
+
+// ShellStack returns the projects of the nested devbox shells that devbox
+// runs in, innermost first. Each project's variables and PATH entries take
+// precedence over those of the projects after it, and the host's come last.
+func ShellStack() []ShellLayer {
+	dirs := shellStackDirs(envir.PairsToMap(os.Environ()))
+	layers := make([]ShellLayer, 0, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		path := os.Getenv(envpath.Key(cachehash.Bytes([]byte(dir))))
+		layers = append(layers, ShellLayer{ProjectDir: dir, Path: filepath.SplitList(path)})
+	}
+	return layers
+}
+
+// shellStackDirs returns the project directories of the devbox shells in env,
+// outermost first. A shell that devbox shell --stack didn't start, such as
+// one of devbox run, is the project in DEVBOX_PROJECT_ROOT.
+func shellStackDirs(env map[string]string) []string {
+	if stack := env[envir.DevboxShellStack]; stack != "" {
+		return filepath.SplitList(stack)
+	}
+	if dir := env["DEVBOX_PROJECT_ROOT"]; dir != "" && env[envir.DevboxShellEnabled] != "" {
+		return []string{dir}
+	}
+	return nil
+}
+
+// pushShellStack returns the stack of a devbox shell of the project that's
+// nested in the current environment. A project can only be in the stack
+// once, since its environment is already in effect.
+func (d *Devbox) pushShellStack() (string, error) {
+	dirs := shellStackDirs(envir.PairsToMap(os.Environ()))
+	if slices.Contains(dirs, d.projectDir) {
+		return "", usererr.New(
+			"A devbox shell of %s is already in the stack of nested shells. "+
+				"Run `exit` to return to it.", d.projectDir)
+	}
+	return strings.Join(append(dirs, d.projectDir), string(filepath.ListSeparator)), nil
+}
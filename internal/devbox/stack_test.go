@@ -0,0 +1,65 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/devbox/envpath"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestShellStackDirs(t *testing.T) {
+	require.Empty(t, shellStackDirs(map[string]string{}))
+	// A devbox run environment, or a shell of an older devbox.
+	require.Equal(t, []string{"/outer"}, shellStackDirs(map[string]string{
+		envir.DevboxShellEnabled: "1",
+		"DEVBOX_PROJECT_ROOT":    "/outer",
+	}))
+	require.Equal(t, []string{"/outer", "/middle"}, shellStackDirs(map[string]string{
+		envir.DevboxShellEnabled: "1",
+		envir.DevboxShellStack:   "/outer" + string(filepath.ListSeparator) + "/middle",
+		"DEVBOX_PROJECT_ROOT":    "/middle",
+	}))
+}
+
+func TestPushShellStack(t *testing.T) {
+	d := devboxForTesting(t)
+	t.Setenv(envir.DevboxShellEnabled, "")
+	t.Setenv(envir.DevboxShellStack, "")
+
+	stack, err := d.pushShellStack()
+	require.NoError(t, err)
+	require.Equal(t, d.projectDir, stack)
+
+	t.Setenv(envir.DevboxShellEnabled, "1")
+	t.Setenv(envir.DevboxShellStack, "/outer")
+	stack, err = d.pushShellStack()
+	require.NoError(t, err)
+	require.Equal(t, []string{"/outer", d.projectDir}, filepath.SplitList(stack))
+
+	t.Setenv(envir.DevboxShellStack, stack)
+	_, err = d.pushShellStack()
+	_, isUserErr := usererr.Extract(err)
+	require.True(t, isUserErr, err)
+	require.Contains(t, err.Error(), d.projectDir)
+}
+
+func TestShellStack(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	t.Setenv(envir.DevboxShellEnabled, "1")
+	t.Setenv(envir.DevboxShellStack, "/outer"+sep+"/inner")
+	t.Setenv(envpath.Key(cachehash.Bytes([]byte("/outer"))), "/outer/bin")
+	t.Setenv(envpath.Key(cachehash.Bytes([]byte("/inner"))), "/inner/bin"+sep+"/inner/sbin")
+
+	require.Equal(t, []ShellLayer{
+		{ProjectDir: "/inner", Path: []string{"/inner/bin", "/inner/sbin"}},
+		{ProjectDir: "/outer", Path: []string{"/outer/bin"}},
+	}, ShellStack())
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// setSubstituters makes every nix command that devbox runs use the project's
+// binary caches, with their priorities, keys and credentials.
+func (d *Devbox) setSubstituters() {
+	allowed := d.BinaryCaches()
+	trusted := sync.OnceValue(trustedCaches)
+	var subs []nix.Substituter
+	for _, cache := range d.cfg.Root.BinaryCaches {
+		if !slices.Contains(allowed, cache.URL) {
+			continue
+		}
+		sub := nix.Substituter{
+			URL:        cache.URL,
+			Priority:   cache.Priority,
+			PublicKeys: cache.PublicKeys,
+		}
+		if cache.NetrcFile != "" || cache.PasswordEnv != "" {
+			var err error
+			if !trusted()(cache.URL) {
+				err = fmt.Errorf("the cache isn't in substituters or trusted-substituters in nix.conf, " +
+					"or allowed by the source policy")
+			} else {
+				sub.Netrc, err = d.netrcEntry(cache)
+			}
+			if err != nil {
+				ux.Fwarning(d.stderr, "Not using the credentials of binary cache %s: %v\n", cache.URL, err)
+			}
+		}
+		subs = append(subs, sub)
+	}
+	nix.SetSubstituters(subs)
+}
+
+// trustedCaches returns a function that reports whether the user trusts a
+// binary cache with credentials. devbox.json is checked in, so anyone who can
+// change it could point a cache at their own host. A cache is only trusted if
+// it's in substituters or trusted-substituters in nix.conf, or the
+// organization's source policy allows it explicitly.
+func trustedCaches() func(url string) bool {
+	var trusted []string
+	if cfg, err := nix.CurrentConfig(context.Background()); err == nil {
+		trusted = slices.Concat(cfg.Substituters.Value, cfg.TrustedSubstituters.Value)
+	} else {
+		debug.Log("binary caches: reading the Nix config: %v", err)
+	}
+	policy, err := loadSourcePolicy()
+	if err != nil {
+		debug.Log("binary caches: reading the source policy: %v", err)
+	}
+	return func(url string) bool {
+		if policy != nil && len(policy.Caches) > 0 && matchSource(policy.Caches, url) {
+			return true
+		}
+		return slices.ContainsFunc(trusted, func(t string) bool { return sameCacheURL(t, url) })
+	}
+}
+
+// sameCacheURL compares binary cache URLs, ignoring a trailing slash and
+// settings such as ?priority=.
+func sameCacheURL(a, b string) bool {
+	normalize := func(s string) string {
+		s, _, _ = strings.Cut(s, "?")
+		return strings.TrimSuffix(s, "/")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// netrcEntry returns a netrc entry with the credentials of cache: the entry
+// of the cache's host in its netrc_file, or the password in its
+// password_env. It's only ever for the cache's host, so the other entries and
+// the default entry of netrc_file aren't sent to the cache.
+func (d *Devbox) netrcEntry(cache configfile.BinaryCache) (string, error) {
+	u, err := url.Parse(cache.URL)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+
+	var login, password string
+	if cache.NetrcFile != "" {
+		path := os.ExpandEnv(cache.NetrcFile)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(d.projectDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		var ok bool
+		if login, password, ok = nix.NetrcCredentials(string(data), host); !ok {
+			return "", fmt.Errorf("%s has no entry for %s", cache.NetrcFile, host)
+		}
+	} else {
+		password = os.Getenv(cache.PasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("%s isn't set", cache.PasswordEnv)
+		}
+		login = cache.Login
+	}
+
+	entry := "machine " + host
+	if login != "" {
+		entry += " login " + login
+	}
+	return entry + " password " + password + "\n", nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestNetrcEntry(t *testing.T) {
+	dir := t.TempDir()
+	netrc := "machine github.com password gh-token\n" +
+		"machine cache.acme.internal login ci password secret\n" +
+		"default login anonymous password fallback\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "netrc"), []byte(netrc), 0o600))
+	d := &Devbox{projectDir: dir}
+
+	entry, err := d.netrcEntry(configfile.BinaryCache{URL: "https://cache.acme.internal", NetrcFile: "netrc"})
+	require.NoError(t, err)
+	require.Equal(t, "machine cache.acme.internal login ci password secret\n", entry,
+		"only the cache's own entry is used")
+
+	_, err = d.netrcEntry(configfile.BinaryCache{URL: "https://evil.example", NetrcFile: "netrc"})
+	require.Error(t, err, "the default entry isn't sent to other hosts")
+
+	t.Setenv("ACME_CACHE_TOKEN", "token")
+	entry, err = d.netrcEntry(configfile.BinaryCache{URL: "https://cache.acme.internal/", PasswordEnv: "ACME_CACHE_TOKEN"})
+	require.NoError(t, err)
+	require.Equal(t, "machine cache.acme.internal password token\n", entry)
+}
+
+func TestSameCacheURL(t *testing.T) {
+	require.True(t, sameCacheURL("https://cache.acme.internal/", "https://cache.acme.internal"))
+	require.True(t, sameCacheURL("https://cache.acme.internal?priority=10", "https://cache.acme.internal"))
+	require.False(t, sameCacheURL("https://cache.acme.internal.evil.example", "https://cache.acme.internal"))
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// asdfPackages maps asdf plugin names to devbox package names, for plugins
+// whose name isn't also the package name.
+var asdfPackages = map[string]string{
+	"awscli":      "awscli2",
+	"dotnet":      "dotnet-sdk",
+	"dotnet-core": "dotnet-sdk",
+	"gcloud":      "google-cloud-sdk",
+	"golang":      "go",
+	"helm":        "kubernetes-helm",
+	"java":        "jdk",
+	"postgres":    "postgresql",
+	"rust":        "rustc",
+}
+
+// ToolVersion is an entry of an asdf .tool-versions file, or a version read
+// from a language's version file.
+type ToolVersion struct {
+	Plugin  string
+	Version string
+	Line    int
+	// Source is the version file the tool was detected in, such as .nvmrc.
+	Source string
+}
+
+// ImportedTool is how a .tool-versions entry was imported.
+type ImportedTool struct {
+	ToolVersion
+	// Package is the devbox package it was mapped to, such as "go@1.22.1".
+	// It's empty if the entry couldn't be mapped.
+	Package string
+	// Reason explains why the entry couldn't be mapped, or why the version
+	// differs from the one in .tool-versions.
+	Reason string
+}
+
+// ParseToolVersions parses an asdf .tool-versions file. When an entry lists
+// several versions, asdf uses the first one that's installed, so only the
+// first is kept.
+func ParseToolVersions(r io.Reader) ([]ToolVersion, error) {
+	var tools []ToolVersion
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, usererr.New("Line %d of .tool-versions has a tool without a version: %q", line, fields[0])
+		}
+		tools = append(tools, ToolVersion{Plugin: fields[0], Version: fields[1], Line: line})
+	}
+	return tools, scanner.Err()
+}
+
+// ImportToolVersions adds the tools in an asdf .tool-versions file to
+// devbox.json. It returns how each entry was imported.
+func (d *Devbox) ImportToolVersions(ctx context.Context, path string) ([]ImportedTool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to read %s", path)
+	}
+	defer f.Close()
+	tools, err := ParseToolVersions(f)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := ResolveToolVersions(tools)
+	return imported, d.addImportedTools(ctx, imported, path)
+}
+
+// addImportedTools adds the packages that tools imported from path were
+// mapped to.
+func (d *Devbox) addImportedTools(ctx context.Context, imported []ImportedTool, path string) error {
+	var pkgs []string
+	for _, tool := range imported {
+		if tool.Package != "" {
+			pkgs = append(pkgs, tool.Package)
+		}
+	}
+	if len(pkgs) == 0 {
+		ux.Fwarning(d.stderr, "None of the tools in %s could be mapped to devbox packages.\n", path)
+		return nil
+	}
+	return d.Add(ctx, pkgs, devopt.AddOpts{})
+}
+
+type resolveFunc func(name, version string) (*searcher.PackageVersion, error)
+
+var (
+	// asdfVersionPrefix matches the non-numeric prefix of versions such as
+	// "temurin-21.0.2+13.0.LTS" and "go1.22.1".
+	asdfVersionPrefix = regexp.MustCompile(`^[^0-9]*`)
+	// asdfVersionSuffix matches build metadata after the version number.
+	asdfVersionSuffix = regexp.MustCompile(`[+_].*$`)
+)
+
+// mapToolVersion maps a .tool-versions entry to a devbox package, using the
+// closest version the search service has.
+func mapToolVersion(tool ToolVersion, resolve resolveFunc) ImportedTool {
+	result := ImportedTool{ToolVersion: tool}
+	switch {
+	case tool.Version == "system":
+		result.Reason = "uses the version installed on the system"
+		return result
+	case strings.HasPrefix(tool.Version, "ref:"), strings.HasPrefix(tool.Version, "path:"):
+		result.Reason = "is built from a git ref or a local path"
+		return result
+	}
+
+	name := tool.Plugin
+	if mapped, ok := asdfPackages[tool.Plugin]; ok {
+		name = mapped
+	}
+	version := tool.Version
+	if version != "latest" && !strings.HasPrefix(version, "lts") {
+		version = asdfVersionPrefix.ReplaceAllString(version, "")
+		version = asdfVersionSuffix.ReplaceAllString(version, "")
+	}
+	if version == "" || strings.HasPrefix(version, "lts") {
+		// asdf-nodejs's lts aliases don't exist in nixpkgs, so use the
+		// latest release.
+		version = "latest"
+	}
+
+	// Try the version, then less and less specific versions, so that
+	// 3.11.4 falls back to the latest 3.11, then to the latest 3.
+	for candidate := version; candidate != ""; candidate = trimVersionComponent(candidate) {
+		resolved, err := resolve(name, candidate)
+		if errors.Is(err, searcher.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			result.Reason = fmt.Sprintf("search failed: %v", err)
+			return result
+		}
+		// Pin the resolved version, since a shortened candidate would
+		// otherwise float to newer versions on devbox update.
+		result.Package = name + "@" + resolved.Version
+		switch {
+		case version == "latest" && tool.Version != "latest":
+			result.Reason = fmt.Sprintf("%s has no equivalent, so it uses the latest version, %s", tool.Version, resolved.Version)
+		case version != "latest" && resolved.Version != version:
+			result.Reason = fmt.Sprintf("%s isn't available, so it uses %s", tool.Version, resolved.Version)
+		}
+		return result
+	}
+	result.Reason = fmt.Sprintf("no package named %s has version %s", name, version)
+	return result
+}
+
+// trimVersionComponent removes the last dot-separated component of a
+// version. It returns the empty string when there's only one component.
+func trimVersionComponent(version string) string {
+	i := strings.LastIndex(version, ".")
+	if i < 0 {
+		return ""
+	}
+	return version[:i]
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestParseToolVersions(t *testing.T) {
+	tools, err := ParseToolVersions(strings.NewReader(`# runtimes
+nodejs 20.11.0
+python 3.11.4 3.10.0  # fallback
+
+golang system
+`))
+	require.NoError(t, err)
+	require.Equal(t, []ToolVersion{
+		{Plugin: "nodejs", Version: "20.11.0", Line: 2},
+		{Plugin: "python", Version: "3.11.4", Line: 3},
+		{Plugin: "golang", Version: "system", Line: 5},
+	}, tools)
+
+	_, err = ParseToolVersions(strings.NewReader("nodejs\n"))
+	require.Error(t, err)
+}
+
+func TestMapToolVersion(t *testing.T) {
+	available := map[string]string{
+		"go@1.22.1":     "1.22.1",
+		"python@3.11":   "3.11.9",
+		"jdk@21.0.2":    "21.0.2",
+		"nodejs@latest": "21.6.1",
+	}
+	var queries []string
+	resolve := func(name, version string) (*searcher.PackageVersion, error) {
+		queries = append(queries, name+"@"+version)
+		if v, ok := available[name+"@"+version]; ok {
+			return &searcher.PackageVersion{PackageInfo: searcher.PackageInfo{Version: v}}, nil
+		}
+		return nil, searcher.ErrNotFound
+	}
+
+	tests := []struct {
+		tool    ToolVersion
+		pkg     string
+		reason  string
+		queries []string
+	}{
+		{ToolVersion{Plugin: "golang", Version: "1.22.1"}, "go@1.22.1", "", []string{"go@1.22.1"}},
+		{
+			ToolVersion{Plugin: "python", Version: "3.11.4"}, "python@3.11.9",
+			"3.11.4 isn't available, so it uses 3.11.9", []string{"python@3.11.4", "python@3.11"},
+		},
+		{ToolVersion{Plugin: "java", Version: "temurin-21.0.2+13.0.LTS"}, "jdk@21.0.2", "", []string{"jdk@21.0.2"}},
+		{
+			ToolVersion{Plugin: "nodejs", Version: "lts-iron"}, "nodejs@21.6.1",
+			"lts-iron has no equivalent, so it uses the latest version, 21.6.1", []string{"nodejs@latest"},
+		},
+		{ToolVersion{Plugin: "golang", Version: "system"}, "", "uses the version installed on the system", nil},
+		{
+			ToolVersion{Plugin: "unknown", Version: "1.0"}, "",
+			"no package named unknown has version 1.0", []string{"unknown@1.0", "unknown@1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool.Plugin+"@"+tt.tool.Version, func(t *testing.T) {
+			queries = nil
+			result := mapToolVersion(tt.tool, resolve)
+			require.Equal(t, tt.pkg, result.Package)
+			require.Equal(t, tt.reason, result.Reason)
+			require.Equal(t, tt.queries, queries)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mattn/go-isatty"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// trustStore records which projects the user approved to run code, keyed by
+// project directory. The value is the hash of the code that was approved, so
+// that changes to it (for example from a git pull) need approval again.
+type trustStore struct {
+	Projects map[string]string `json:"projects"`
+}
+
+func trustStorePath() string {
+	return xdg.StateSubpath(filepath.Join("devbox", "trusted-projects.json"))
+}
+
+func loadTrustStore() (*trustStore, error) {
+	store := &trustStore{Projects: map[string]string{}}
+	data, err := os.ReadFile(trustStorePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, redact.Errorf("read trusted projects: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, redact.Errorf("parse trusted projects %s: %w", trustStorePath(), err)
+	}
+	if store.Projects == nil {
+		store.Projects = map[string]string{}
+	}
+	return store, nil
+}
+
+func (s *trustStore) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return redact.Errorf("encode trusted projects: %w", err)
+	}
+	path := trustStorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return redact.Errorf("save trusted projects: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return redact.Errorf("save trusted projects: %w", err)
+	}
+	return nil
+}
+
+// ProjectCode describes the code that devbox runs or creates on behalf of the
+// project: init hooks, scripts and the files that included plugins create.
+// Built-in plugins ship with devbox, so they're left out. It returns an
+// empty string if the project doesn't run any code.
+func (d *Devbox) ProjectCode() (string, error) {
+	var b strings.Builder
+	writeSection := func(header, body string) {
+		if strings.TrimSpace(body) == "" {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", header)
+		for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+
+	root := d.cfg.Root
+	writeSection("init_hook in devbox.json", root.InitHook().String())
+	writeHooks := func(source string, cfg *configfile.ConfigFile) {
+		for _, phase := range []configfile.HookPhase{configfile.HookPreInit, configfile.HookPostPackage, configfile.HookPreScript} {
+			cmds, _ := cfg.Hook(phase)
+			writeSection(fmt.Sprintf("%s hook in %s", phase, source), cmds.String())
+		}
+	}
+	writeHooks("devbox.json", &root)
+	scripts := root.Scripts()
+	for _, name := range sortedKeys(scripts) {
+		writeSection(fmt.Sprintf("script %q in devbox.json", name), scripts[name].String())
+	}
+
+	for _, plugin := range d.cfg.IncludedPluginConfigs() {
+		if _, builtin := plugin.Source.(*devpkg.Package); builtin {
+			continue
+		}
+		source := "plugin " + plugin.Source.LockfileKey()
+		writeSection("init_hook in "+source, plugin.InitHook().String())
+		writeHooks(source, &plugin.ConfigFile)
+		scripts := plugin.Scripts()
+		for _, name := range sortedKeys(scripts) {
+			writeSection(fmt.Sprintf("script %q in %s", name, source), scripts[name].String())
+		}
+		for _, file := range sortedKeys(plugin.CreateFiles) {
+			contentPath := plugin.CreateFiles[file]
+			if contentPath == "" {
+				continue
+			}
+			content, err := plugin.Source.FileContent(contentPath)
+			if err != nil {
+				return "", err
+			}
+			writeSection(fmt.Sprintf("file %s created by %s", file, source), string(content))
+		}
+	}
+	return b.String(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+func trustHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsTrusted returns true if the user approved the code the project runs in
+// its current form, or if it doesn't run any.
+func (d *Devbox) IsTrusted() (bool, error) {
+	code, err := d.ProjectCode()
+	if err != nil || code == "" {
+		return true, err
+	}
+	store, err := loadTrustStore()
+	if err != nil {
+		return false, err
+	}
+	return store.Projects[d.projectDir] == trustHash(code), nil
+}
+
+// Trust records that the user approved the code the project currently runs.
+func (d *Devbox) Trust() error {
+	code, err := d.ProjectCode()
+	if err != nil {
+		return err
+	}
+	store, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	store.Projects[d.projectDir] = trustHash(code)
+	return store.save()
+}
+
+// Untrust removes the user's approval of the project, so that they're asked
+// again before it runs any code.
+func (d *Devbox) Untrust() error {
+	store, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	delete(store.Projects, d.projectDir)
+	return store.save()
+}
+
+// ensureTrusted shows the code that an untrusted project would run and asks
+// the user to approve it. This keeps a freshly cloned repository from running
+// commands just because the user ran a devbox command in it.
+func (d *Devbox) ensureTrusted() error {
+	if d.isGlobal() {
+		return nil
+	}
+	code, err := d.ProjectCode()
+	if err != nil || code == "" {
+		return err
+	}
+	store, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	hash := trustHash(code)
+	previous, known := store.Projects[d.projectDir]
+	if previous == hash {
+		return nil
+	}
+	if envir.TrustAll() || envir.IsCI() {
+		debug.Log("trust: approval turned off, trusting %s", d.projectDir)
+		return nil
+	}
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return usererr.New(
+			"The project in %s runs hooks or scripts that you haven't approved. "+
+				"Run `devbox trust` in the project to review and approve them, or set %s=1 to skip approval.",
+			d.projectDir, envir.DevboxTrustAll,
+		)
+	}
+
+	if known {
+		ux.Fwarning(d.stderr, "The hooks, scripts or plugin files of this project changed since you approved them.\n")
+	} else {
+		ux.Finfo(d.stderr, "This project runs the following code, which you haven't approved yet.\n")
+	}
+	fmt.Fprintf(d.stderr, "\n%s\n", code)
+
+	approved := false
+	prompt := &survey.Confirm{Message: fmt.Sprintf("Trust the project in %s and run it?", d.projectDir)}
+	if err := survey.AskOne(prompt, &approved); err != nil {
+		return redact.Errorf("prompt for trust: %w", err)
+	}
+	if !approved {
+		return usererr.New("Not running the project's code because it isn't trusted.")
+	}
+	store.Projects[d.projectDir] = hash
+	return store.save()
+}
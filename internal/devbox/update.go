@@ -20,25 +20,52 @@ import (
 	"go.jetpack.io/devbox/internal/ux"
 )
 
-func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
+// Update updates the lock entries of the packages in opts, installs them and
+// returns how each one changed. Without packages in opts, it only resolves
+// the packages whose spec changed since they were locked, unless opts.All is
+// set.
+func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) ([]PackageUpdate, error) {
+	d.recordHistory("edit")
+	report, err := d.updateLockEntries(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.ensureStateIsUpToDate(ctx, update); err != nil {
+		return nil, err
+	}
+	d.recordHistory("update")
+
+	// I'm not entirely sure this is even needed, so ignoring the error.
+	// It's definitely not needed for non-flakes. (which is 99.9% of packages)
+	// It will return an error if .devbox/gen/flake is missing
+	// TODO: Remove this if it's not needed.
+	_ = nix.FlakeUpdate(shellgen.FlakePath(d))
+	return report, plugin.Update()
+}
+
+// updateLockEntries is the part of Update that changes the lock entries.
+func (d *Devbox) updateLockEntries(ctx context.Context, opts devopt.UpdateOpts) ([]PackageUpdate, error) {
 	inputs, err := d.inputsToUpdate(opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var report []PackageUpdate
 	pendingPackagesToUpdate := []*devpkg.Package{}
 	for _, pkg := range inputs {
 		if pkg.IsLegacy() {
 			fmt.Fprintf(d.stderr, "Updating %s -> %s\n", pkg.Raw, pkg.LegacyToVersioned())
+			old := newLockResolution(d.lockfile.Packages[pkg.Raw])
 
 			// Get the package from the config to get the Platforms and ExcludedPlatforms later
 			cfgPackage, ok := d.cfg.Root.GetPackage(pkg.Raw)
 			if !ok {
-				return fmt.Errorf("package %s not found in config", pkg.Raw)
+				return nil, fmt.Errorf("package %s not found in config", pkg.Raw)
 			}
 
 			if err := d.Remove(ctx, pkg.Raw); err != nil {
-				return err
+				return nil, err
 			}
 			// Calling Add function with the original package names, since
 			// Add will automatically append @latest if search is able to handle that.
@@ -47,35 +74,77 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 				Platforms:        cfgPackage.Platforms,
 				ExcludePlatforms: cfgPackage.ExcludedPlatforms,
 			}); err != nil {
-				return err
+				return nil, err
 			}
+			report = append(report, PackageUpdate{
+				Package: pkg.LegacyToVersioned(),
+				Status:  UpdateChange,
+				Old:     old,
+				New:     newLockResolution(d.lockfile.Packages[pkg.LegacyToVersioned()]),
+			})
 		} else {
 			pendingPackagesToUpdate = append(pendingPackagesToUpdate, pkg)
 		}
 	}
 
+	// Resolve every package up front, concurrently, and then merge the
+	// resolutions in order so that the output doesn't depend on which
+	// request finished first.
+	var toResolve []string
+	for _, pkg := range pendingPackagesToUpdate {
+		if d.planNeedsResolution(opts, pkg, pkg.Raw) {
+			toResolve = append(toResolve, pkg.Raw)
+		}
+	}
+	resolved, err := d.lockfile.FetchResolvedPackages(ctx, toResolve)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pendingPackagesToUpdate {
+		if !lock.IsLockableFlake(pkg.Raw) {
+			continue
+		}
+		if !d.relocks(opts, pkg.Raw) {
+			resolved[pkg.Raw] = d.lockfile.Get(pkg.Raw)
+			continue
+		}
+		if resolved[pkg.Raw], err = d.lockfile.FetchLockedFlake(ctx, pkg.Raw); err != nil {
+			return nil, err
+		}
+	}
+
 	for _, pkg := range pendingPackagesToUpdate {
-		if _, _, isVersioned := searcher.ParseVersionedPackage(pkg.Raw); !isVersioned {
+		// The plan is computed before the lock entry changes, so it
+		// describes what this update does.
+		report = append(report, d.planPackageUpdate(opts, pkg, resolved))
+		if lock.IsLockableFlake(pkg.Raw) {
+			d.updateLockedFlake(pkg, resolved[pkg.Raw])
+		} else if _, _, isVersioned := searcher.ParseVersionedPackage(pkg.Raw); !isVersioned {
 			if err = d.attemptToUpgradeFlake(pkg); err != nil {
-				return err
+				return nil, err
 			}
-		} else {
-			if err = d.updateDevboxPackage(pkg); err != nil {
-				return err
+		} else if !d.planNeedsResolution(opts, pkg, pkg.Raw) {
+			// The lock entry stays as is: exact versions that are fully
+			// locked can't resolve to anything else, and without packages
+			// to update, only the specs that changed are resolved.
+			if d.lockfile.NeedsResolution(pkg.Raw) {
+				ux.Finfo(d.stderr, "Skipped %s, %s. Run `devbox update %s` to update it.\n", pkg, unchangedSpecReason, pkg.Raw)
+			} else {
+				ux.Finfo(d.stderr, "Already up-to-date %s %s\n", pkg, d.lockfile.Get(pkg.Raw).Version)
+			}
+			continue
+		} else if resolved[pkg.Raw] != nil {
+			if err = d.mergeResolvedPackageToLockfile(pkg, resolved[pkg.Raw], d.lockfile); err != nil {
+				return nil, err
 			}
 		}
+		if pkg.IsRunX() {
+			// Installing the package below pins the artifact that its
+			// release has now.
+			d.lockfile.ResetRunXDigests(pkg.Raw)
+		}
 	}
-
-	if err := d.ensureStateIsUpToDate(ctx, update); err != nil {
-		return err
-	}
-
-	// I'm not entirely sure this is even needed, so ignoring the error.
-	// It's definitely not needed for non-flakes. (which is 99.9% of packages)
-	// It will return an error if .devbox/gen/flake is missing
-	// TODO: Remove this if it's not needed.
-	_ = nix.FlakeUpdate(shellgen.FlakePath(d))
-	return plugin.Update()
+	return report, nil
 }
 
 func (d *Devbox) inputsToUpdate(
@@ -98,18 +167,6 @@ func (d *Devbox) inputsToUpdate(
 	return pkgsToUpdate, nil
 }
 
-func (d *Devbox) updateDevboxPackage(pkg *devpkg.Package) error {
-	resolved, err := d.lockfile.FetchResolvedPackage(pkg.Raw)
-	if err != nil {
-		return err
-	}
-	if resolved == nil {
-		return nil
-	}
-
-	return d.mergeResolvedPackageToLockfile(pkg, resolved, d.lockfile)
-}
-
 func (d *Devbox) mergeResolvedPackageToLockfile(
 	pkg *devpkg.Package,
 	resolved *lock.Package,
@@ -122,6 +179,18 @@ func (d *Devbox) mergeResolvedPackageToLockfile(
 		return nil
 	}
 
+	if resolved.IsPinned() {
+		// devbox.json decides what a pinned package resolves to, so it
+		// doesn't matter which one is newer.
+		if existing.Resolved == resolved.Resolved {
+			ux.Finfo(d.stderr, "Already up-to-date %s %s\n", pkg, existing.Version)
+			return nil
+		}
+		ux.Finfo(d.stderr, "Pinned %s to %s\n", pkg, resolved.Resolved)
+		useResolvedPackageInLockfile(lockfile, pkg, resolved, existing)
+		return nil
+	}
+
 	if existing.Version != resolved.Version {
 		if existing.LastModified > resolved.LastModified {
 			ux.Fwarning(
@@ -143,27 +212,7 @@ func (d *Devbox) mergeResolvedPackageToLockfile(
 			lockfile.Packages[pkg.Raw].Systems = map[string]*lock.SystemInfo{}
 		}
 
-		userSystem := nix.System()
-		updated := false
-		for sysName, newSysInfo := range resolved.Systems {
-			// Check whether we are actually updating any system info.
-			if sysName == userSystem {
-				// The resolved pkg has a system info for the user's system, so add/overwrite it.
-				if !newSysInfo.Equals(existing.Systems[userSystem]) {
-					// We only guard this so that the ux messaging is accurate. We could overwrite every time.
-					updated = true
-				}
-			} else {
-				// Add other system infos if they don't exist, or if we have a different StorePath. This may
-				// overwrite an existing StorePath, but to ensure correctness we should ensure that all StorePaths
-				// come from the same package version.
-				existingSysInfo, exists := existing.Systems[sysName]
-				if !exists || !existingSysInfo.Equals(newSysInfo) {
-					updated = true
-				}
-			}
-		}
-		if updated {
+		if systemInfosChanged(existing, resolved) {
 			// if we are updating the system info, then we should also update the other fields
 			useResolvedPackageInLockfile(lockfile, pkg, resolved, existing)
 
@@ -176,6 +225,31 @@ func (d *Devbox) mergeResolvedPackageToLockfile(
 	return nil
 }
 
+// systemInfosChanged reports whether resolved has system infos that existing
+// is missing or has different store paths for. Devbox then replaces all of
+// the package's system infos, so that the store paths of every system come
+// from the same package version.
+func systemInfosChanged(existing, resolved *lock.Package) bool {
+	for sysName, newSysInfo := range resolved.Systems {
+		if !newSysInfo.Equals(existing.Systems[sysName]) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateLockedFlake locks a flake to locked, the revision that its flake
+// reference points to now.
+func (d *Devbox) updateLockedFlake(pkg *devpkg.Package, locked *lock.Package) {
+	existing := d.lockfile.Get(pkg.Raw)
+	if locked == nil || locked.Resolved == "" || (existing != nil && existing.Resolved == locked.Resolved) {
+		ux.Finfo(d.stderr, "Already up-to-date %s\n", pkg)
+		return
+	}
+	ux.Finfo(d.stderr, "Locked %s to %s\n", pkg, locked.Resolved)
+	d.lockfile.Packages[pkg.Raw] = locked
+}
+
 // attemptToUpgradeFlake attempts to upgrade a flake using `nix profile upgrade`
 // and prints an error if it fails, but does not propagate upgrade errors.
 func (d *Devbox) attemptToUpgradeFlake(pkg *devpkg.Package) error {
@@ -0,0 +1,292 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/samber/lo"
+
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// What devbox update would do to a package's lock entry.
+const (
+	UpdateChange    = "update"
+	UpdateNew       = "new"
+	UpdateUnchanged = "unchanged"
+	// UpdateKept is a package that resolved to a different version that's
+	// older than the locked one, which devbox update doesn't downgrade to.
+	UpdateKept = "kept"
+	// UpdateSkipped is a package that devbox update doesn't resolve, such as
+	// a flake, which it upgrades with nix profile upgrade instead.
+	UpdateSkipped = "skipped"
+)
+
+// PackageUpdate is how devbox update would change the lock entry of a
+// package.
+type PackageUpdate struct {
+	Package string `json:"package"`
+	Status  string `json:"status"`
+	// Reason says why the package is skipped, or why an unchanged package
+	// isn't updated.
+	Reason string          `json:"reason,omitempty"`
+	Old    *LockResolution `json:"old,omitempty"`
+	New    *LockResolution `json:"new,omitempty"`
+	// SameVersion is set on an unchanged package that resolved to the
+	// locked version from a different nixpkgs commit. devbox update keeps
+	// the locked commit, because switching would download another nixpkgs
+	// for the same version.
+	SameVersion bool `json:"same_version,omitempty"`
+}
+
+// LockResolution is what a package resolves to in devbox.lock.
+type LockResolution struct {
+	Version      string `json:"version,omitempty"`
+	Resolved     string `json:"resolved,omitempty"`
+	Commit       string `json:"commit,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// StorePaths are the store paths of the default outputs on each system.
+	StorePaths map[string][]string `json:"store_paths,omitempty"`
+}
+
+func newLockResolution(pkg *lock.Package) *LockResolution {
+	if pkg == nil {
+		return nil
+	}
+	res := &LockResolution{
+		Version:      pkg.Version,
+		Resolved:     pkg.Resolved,
+		LastModified: pkg.LastModified,
+	}
+	if inst, err := flake.ParseInstallable(pkg.Resolved); err == nil {
+		res.Commit = cmp.Or(inst.Ref.Rev, inst.Ref.Ref)
+	}
+	for sys, info := range pkg.Systems {
+		var paths []string
+		for _, out := range info.DefaultOutputs() {
+			paths = append(paths, out.Path)
+		}
+		if len(paths) > 0 {
+			if res.StorePaths == nil {
+				res.StorePaths = map[string][]string{}
+			}
+			res.StorePaths[sys] = paths
+		}
+	}
+	return res
+}
+
+// UpdatePlan resolves the packages that devbox update would update, and
+// returns how their lock entries would change, without changing devbox.lock.
+func (d *Devbox) UpdatePlan(ctx context.Context, opts devopt.UpdateOpts) ([]PackageUpdate, error) {
+	inputs, err := d.inputsToUpdate(opts)
+	if err != nil {
+		return nil, err
+	}
+	var toResolve []string
+	for _, pkg := range inputs {
+		if raw := planRaw(pkg); d.planNeedsResolution(opts, pkg, raw) {
+			toResolve = append(toResolve, raw)
+		}
+	}
+	resolved, err := d.lockfile.FetchResolvedPackages(ctx, toResolve)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range inputs {
+		if !lock.IsLockableFlake(pkg.Raw) {
+			continue
+		}
+		if !d.relocks(opts, pkg.Raw) {
+			resolved[pkg.Raw] = d.lockfile.Get(pkg.Raw)
+			continue
+		}
+		if resolved[pkg.Raw], err = d.lockfile.FetchLockedFlake(ctx, pkg.Raw); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := make([]PackageUpdate, 0, len(inputs))
+	for _, pkg := range inputs {
+		plan = append(plan, d.planPackageUpdate(opts, pkg, resolved))
+	}
+	return plan, nil
+}
+
+// planRaw is the package that devbox update resolves for pkg. It replaces
+// legacy packages with their versioned package.
+func planRaw(pkg *devpkg.Package) string {
+	if pkg.IsLegacy() {
+		return pkg.LegacyToVersioned()
+	}
+	return pkg.Raw
+}
+
+func (d *Devbox) planNeedsResolution(opts devopt.UpdateOpts, pkg *devpkg.Package, raw string) bool {
+	if _, _, isVersioned := searcher.ParseVersionedPackage(raw); !isVersioned {
+		return false
+	}
+	return pkg.IsLegacy() || (d.lockfile.NeedsResolution(raw) && d.relocks(opts, raw))
+}
+
+// unchangedSpecReason is why devbox update skips a package that isn't named
+// when only the specs that changed are resolved.
+const unchangedSpecReason = "its spec didn't change since it was locked"
+
+// relocks returns true if devbox update resolves or locks raw again. It does
+// for every package that's named or with --all, and otherwise only for the
+// packages whose spec changed since they were locked.
+func (d *Devbox) relocks(opts devopt.UpdateOpts, raw string) bool {
+	return opts.All || len(opts.Pkgs) > 0 || d.lockfile.SpecChanged(raw)
+}
+
+func (d *Devbox) planPackageUpdate(
+	opts devopt.UpdateOpts, pkg *devpkg.Package, resolutions map[string]*lock.Package,
+) PackageUpdate {
+	raw := planRaw(pkg)
+	update := PackageUpdate{Package: raw, Old: newLockResolution(d.lockfile.Packages[pkg.Raw])}
+	if lock.IsLockableFlake(raw) {
+		return planFlakeUpdate(update, d.lockfile.Packages[pkg.Raw], resolutions[raw])
+	}
+	if _, _, isVersioned := searcher.ParseVersionedPackage(raw); !isVersioned {
+		update.Status = UpdateSkipped
+		update.Reason = "upgraded with nix profile upgrade"
+		return update
+	}
+	if !d.planNeedsResolution(opts, pkg, raw) {
+		update.Status = UpdateUnchanged
+		if d.lockfile.NeedsResolution(raw) {
+			update.Status = UpdateSkipped
+			update.Reason = unchangedSpecReason
+		}
+		return update
+	}
+
+	resolved := resolutions[raw]
+	if resolved == nil {
+		update.Status = UpdateSkipped
+		update.Reason = "not resolved by devbox"
+		return update
+	}
+	update.New = newLockResolution(resolved)
+	existing := d.lockfile.Packages[pkg.Raw]
+	switch {
+	case existing == nil:
+		update.Status = UpdateNew
+	case resolved.IsPinned():
+		update.Status = lo.Ternary(existing.Resolved == resolved.Resolved, UpdateUnchanged, UpdateChange)
+	case existing.Version != resolved.Version && existing.LastModified > resolved.LastModified:
+		update.Status = UpdateKept
+	case existing.Version != resolved.Version:
+		update.Status = UpdateChange
+	case featureflag.RemoveNixpkgs.Enabled() && systemInfosChanged(existing, resolved):
+		// The same version with new store paths.
+		update.Status = UpdateChange
+	default:
+		update.Status = UpdateUnchanged
+		if existing.Resolved != resolved.Resolved {
+			update.SameVersion = true
+			update.Reason = "it resolved to the same version from a different commit"
+		}
+	}
+	return update
+}
+
+// planFlakeUpdate is planPackageUpdate for a flake that devbox.lock pins to
+// a revision.
+func planFlakeUpdate(update PackageUpdate, existing, locked *lock.Package) PackageUpdate {
+	if locked == nil || locked.Resolved == "" {
+		update.Status = UpdateSkipped
+		update.Reason = "can't be locked while offline"
+		return update
+	}
+	update.New = newLockResolution(locked)
+	switch {
+	case existing == nil || existing.Resolved == "":
+		update.Status = UpdateNew
+	case existing.Resolved == locked.Resolved:
+		update.Status = UpdateUnchanged
+	default:
+		update.Status = UpdateChange
+	}
+	return update
+}
+
+// PrintUpdatePlan writes a diff of the lock entries in plan.
+func PrintUpdatePlan(w io.Writer, plan []PackageUpdate) {
+	changes := 0
+	for _, update := range plan {
+		switch update.Status {
+		case UpdateUnchanged:
+			fmt.Fprintf(w, "  %s: up-to-date", update.Package)
+			if update.Old != nil && update.Old.Version != "" {
+				fmt.Fprintf(w, " (%s)", update.Old.Version)
+			}
+			if update.Reason != "" {
+				fmt.Fprintf(w, ", %s", update.Reason)
+			}
+			fmt.Fprintln(w)
+			continue
+		case UpdateSkipped:
+			fmt.Fprintf(w, "  %s: skipped, %s\n", update.Package, update.Reason)
+			continue
+		case UpdateKept:
+			fmt.Fprintf(w, "~ %s: kept %s, it resolves to the older %s\n",
+				update.Package, update.Old.Version, update.New.Version)
+			continue
+		}
+		changes++
+		old := update.Old
+		if old == nil {
+			old = &LockResolution{}
+		}
+		fmt.Fprintf(w, "%s %s\n", lo.Ternary(update.Status == UpdateNew, "+", "*"), update.Package)
+		writeLockFieldDiff(w, "version", old.Version, update.New.Version)
+		writeLockFieldDiff(w, "commit", old.Commit, update.New.Commit)
+		writeLockFieldDiff(w, "resolved", old.Resolved, update.New.Resolved)
+		systems := lo.Uniq(append(lo.Keys(old.StorePaths), lo.Keys(update.New.StorePaths)...))
+		slices.Sort(systems)
+		for _, sys := range systems {
+			before, after := old.StorePaths[sys], update.New.StorePaths[sys]
+			if slices.Equal(before, after) {
+				continue
+			}
+			fmt.Fprintf(w, "    store paths (%s):\n", sys)
+			for _, p := range before {
+				fmt.Fprintf(w, "      - %s\n", p)
+			}
+			for _, p := range after {
+				fmt.Fprintf(w, "      + %s\n", p)
+			}
+		}
+	}
+	if changes == 0 {
+		fmt.Fprintln(w, "devbox update wouldn't change devbox.lock.")
+		return
+	}
+	fmt.Fprintf(w, "devbox update would change %d package(s) in devbox.lock.\n", changes)
+}
+
+func writeLockFieldDiff(w io.Writer, field, before, after string) {
+	switch {
+	case before == after:
+		if after != "" {
+			fmt.Fprintf(w, "    %s: %s\n", field, after)
+		}
+	case before == "":
+		fmt.Fprintf(w, "    %s: %s\n", field, after)
+	default:
+		fmt.Fprintf(w, "    %s: %s -> %s\n", field, before, after)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestNewLockResolution(t *testing.T) {
+	require.Nil(t, newLockResolution(nil))
+	res := newLockResolution(&lock.Package{
+		Version:  "1.2.3",
+		Resolved: "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+		Systems: map[string]*lock.SystemInfo{
+			"x86_64-linux": {Outputs: []lock.Output{
+				{Name: "out", Path: "/nix/store/abc-hello-1.2.3", Default: true},
+				{Name: "man", Path: "/nix/store/abc-hello-1.2.3-man"},
+			}},
+		},
+	})
+	require.Equal(t, "75a52265bda7fd25e06e3a67dee3f0354e73243c", res.Commit)
+	require.Equal(t, map[string][]string{"x86_64-linux": {"/nix/store/abc-hello-1.2.3"}}, res.StorePaths)
+}
+
+func TestPlanPackageUpdateSkipsFlakes(t *testing.T) {
+	d := devboxForTesting(t)
+	update := d.planPackageUpdate(devopt.UpdateOpts{}, devpkg.PackageFromStringWithDefaults("github:F1bonacc1/process-compose", d.lockfile), nil)
+	require.Equal(t, UpdateSkipped, update.Status)
+}
+
+func TestPlanPackageUpdateSameVersion(t *testing.T) {
+	d := devboxForTesting(t)
+	d.lockfile.Packages["hello@latest"] = &lock.Package{
+		LastModified: "2024-03-21T09:22:22Z",
+		Resolved:     "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+		Source:       "devbox-search",
+		Version:      "2.12.1",
+	}
+	pkg := devpkg.PackageFromStringWithDefaults("hello@latest", d.lockfile)
+	update := d.planPackageUpdate(devopt.UpdateOpts{All: true}, pkg, map[string]*lock.Package{"hello@latest": {
+		LastModified: "2024-04-02T10:00:00Z",
+		Resolved:     "github:NixOS/nixpkgs/5233fd2ba76a3accb5aaa999c00509a11fd0793c#hello",
+		Source:       "devbox-search",
+		Version:      "2.12.1",
+	}})
+	require.Equal(t, UpdateUnchanged, update.Status)
+	require.True(t, update.SameVersion, "the same version from another commit isn't updated")
+	require.Equal(t, "5233fd2ba76a3accb5aaa999c00509a11fd0793c", update.New.Commit)
+}
+
+func TestPlanFlakeUpdate(t *testing.T) {
+	old := &lock.Package{Resolved: "github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a?narHash=sha256-old%3D"}
+	locked := &lock.Package{Resolved: "github:numtide/flake-utils/5233fd2ba76a3accb5aaa999c00509a11fd0793c?narHash=sha256-new%3D"}
+
+	require.Equal(t, UpdateNew, planFlakeUpdate(PackageUpdate{}, nil, locked).Status)
+	require.Equal(t, UpdateChange, planFlakeUpdate(PackageUpdate{}, old, locked).Status)
+	require.Equal(t, UpdateUnchanged, planFlakeUpdate(PackageUpdate{}, locked, locked).Status)
+	require.Equal(t, UpdateSkipped, planFlakeUpdate(PackageUpdate{}, old, &lock.Package{}).Status)
+}
+
+func TestPrintUpdatePlan(t *testing.T) {
+	var out strings.Builder
+	PrintUpdatePlan(&out, []PackageUpdate{
+		{
+			Package: "hello@latest",
+			Status:  UpdateChange,
+			Old: &LockResolution{
+				Version:    "2.12",
+				Commit:     "aaa",
+				StorePaths: map[string][]string{"x86_64-linux": {"/nix/store/aaa-hello-2.12"}},
+			},
+			New: &LockResolution{
+				Version:    "2.12.1",
+				Commit:     "bbb",
+				StorePaths: map[string][]string{"x86_64-linux": {"/nix/store/bbb-hello-2.12.1"}},
+			},
+		},
+		{Package: "go@1.22", Status: UpdateUnchanged, Old: &LockResolution{Version: "1.22.2"}},
+		{Package: "github:numtide/flake-utils", Status: UpdateSkipped, Reason: "upgraded with nix profile upgrade"},
+	})
+	require.Equal(t, strings.Join([]string{
+		"* hello@latest",
+		"    version: 2.12 -> 2.12.1",
+		"    commit: aaa -> bbb",
+		"    store paths (x86_64-linux):",
+		"      - /nix/store/aaa-hello-2.12",
+		"      + /nix/store/bbb-hello-2.12.1",
+		"  go@1.22: up-to-date (1.22.2)",
+		"  github:numtide/flake-utils: skipped, upgraded with nix profile upgrade",
+		"devbox update would change 1 package(s) in devbox.lock.",
+		"",
+	}, "\n"), out.String())
+}
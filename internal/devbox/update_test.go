@@ -1,11 +1,18 @@
 package devbox
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
 )
@@ -195,6 +202,84 @@ func TestUpdateOtherSysInfoIsReplaced(t *testing.T) {
 	require.Equal(t, "store_path2", lockfile.Packages[raw].Systems[sys2].Outputs[0].Path)
 }
 
+func TestUpdateOnlyResolvesChangedSpecs(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [
+		{"name": "hello", "version": "2.12.1", "systems": {"`+currentSystem(t)+`": {
+			"flake_installable": {"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}, "attr_path": "hello"},
+			"last_updated": "2024-03-21T09:22:22Z"
+		}}},
+		{"name": "go", "version": "1.21.8", "systems": {"`+currentSystem(t)+`": {
+			"flake_installable": {"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}, "attr_path": "go_1_21"},
+			"last_updated": "2024-03-21T09:22:22Z"
+		}}}
+	]}`), 0o644))
+	t.Setenv(envir.DevboxPackageIndex, index)
+	t.Setenv(envir.DevboxOffline, "1")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"),
+		[]byte(`{"packages": ["hello@latest", "go@1.21"]}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.lock"), []byte(`{
+		"lockfile_version": "1",
+		"packages": {
+			"hello@latest": {
+				"last_modified": "2024-01-01T00:00:00Z",
+				"resolved": "github:NixOS/nixpkgs/5d7db4668d7a0c6cc5fc8cf6ef33b008b2b1ed8b#hello",
+				"source": "devbox-search",
+				"version": "2.12.0",
+				"systems": {"`+currentSystem(t)+`": {"outputs": [{"name": "out", "path": "/nix/store/abc-hello-2.12.0", "default": true}]}}
+			}
+		}
+	}`), 0o644))
+	var stderr bytes.Buffer
+	box, err := Open(&devopt.Opts{Dir: dir, Stderr: &stderr})
+	require.NoError(t, err)
+	locked, err := json.Marshal(box.lockfile.Packages["hello@latest"])
+	require.NoError(t, err)
+
+	// go@1.21 was added to devbox.json, so it's the only package that's
+	// resolved. hello keeps its entry, even though there's a newer version.
+	_, err = box.updateLockEntries(context.Background(), devopt.UpdateOpts{})
+	require.NoError(t, err)
+	require.Equal(t, "1.21.8", box.lockfile.Packages["go@1.21"].Version)
+	unchanged, err := json.Marshal(box.lockfile.Packages["hello@latest"])
+	require.NoError(t, err)
+	require.JSONEq(t, string(locked), string(unchanged))
+	require.Contains(t, stderr.String(), "Skipped hello@latest")
+
+	// Naming the package resolves it again.
+	_, err = box.updateLockEntries(context.Background(), devopt.UpdateOpts{Pkgs: []string{"hello"}})
+	require.NoError(t, err)
+	require.Equal(t, "2.12.1", box.lockfile.Packages["hello@latest"].Version)
+}
+
+func TestUpdatePinnedPackageFollowsPin(t *testing.T) {
+	devbox := devboxForTesting(t)
+
+	raw := "hello@2.12.1"
+	devPkg := devpkg.PackageFromStringWithDefaults(raw, nil)
+	pinned := &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/5d7db4668d7a0c6cc5fc8cf6ef33b008b2b1ed8b#hello",
+		Version:  "2.12.1",
+		Source:   "pinned",
+	}
+	lockfile := &lock.File{
+		Packages: map[string]*lock.Package{
+			raw: {
+				Resolved:     "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+				Version:      "2.12.1",
+				LastModified: "2024-01-01T00:00:00Z",
+				Source:       "devbox-search",
+			},
+		},
+	}
+
+	err := devbox.mergeResolvedPackageToLockfile(devPkg, pinned, lockfile)
+	require.NoError(t, err, "update failed")
+	require.Equal(t, pinned.Resolved, lockfile.Packages[raw].Resolved)
+}
+
 func currentSystem(_t *testing.T) string {
 	sys := nix.System() // NOTE: we could mock this too, if it helps.
 	return sys
@@ -0,0 +1,139 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// The results of checking a locked output against the Nix store.
+const (
+	// VerifyOK is an output whose store path has the hash in devbox.lock.
+	VerifyOK = "ok"
+	// VerifyMismatch is an output whose store path has a different hash
+	// than devbox.lock.
+	VerifyMismatch = "mismatch"
+	// VerifyModified is an output whose contents changed since Nix added
+	// it to the store.
+	VerifyModified = "modified"
+	// VerifyMissing is an output that isn't in the Nix store.
+	VerifyMissing = "missing"
+	// VerifyUnhashed is an output that is in the store but doesn't have a
+	// hash in devbox.lock to check it against.
+	VerifyUnhashed = "unhashed"
+)
+
+// OutputVerification is the result of checking one output of a locked
+// package against the Nix store.
+type OutputVerification struct {
+	Package string `json:"package"`
+	Output  string `json:"output"`
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	// Locked is the hash in devbox.lock, and Actual is the hash of the
+	// store path, if they're known.
+	Locked string `json:"locked_hash,omitempty"`
+	Actual string `json:"actual_hash,omitempty"`
+}
+
+// Failed reports whether the output doesn't match devbox.lock. Unhashed
+// outputs only fail when strict is set.
+func (v OutputVerification) Failed(strict bool) bool {
+	return v.Status != VerifyOK && (v.Status != VerifyUnhashed || strict)
+}
+
+// Verify checks the store paths that devbox.lock locks for the current
+// system against the Nix store: that they are in the store, that their NAR
+// hashes match the ones in devbox.lock and that their contents haven't been
+// modified since.
+func (d *Devbox) Verify(ctx context.Context) ([]OutputVerification, error) {
+	defer debug.FunctionTimer().End()
+
+	results := lockedOutputs(d.lockfile, nix.System())
+	if len(results) == 0 {
+		return nil, nil
+	}
+	paths := lo.Uniq(lo.Map(results, func(v OutputVerification, _ int) string { return v.Path }))
+	infos, err := nix.PathInfos(ctx, paths...)
+	if err != nil {
+		return nil, err
+	}
+	checkOutputHashes(results, infos)
+
+	inStore := lo.FilterMap(results, func(v OutputVerification, _ int) (string, bool) {
+		return v.Path, v.Status != VerifyMissing
+	})
+	modified, err := nix.ModifiedStorePaths(ctx, lo.Uniq(inStore)...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if slices.Contains(modified, results[i].Path) {
+			results[i].Status = VerifyModified
+		}
+	}
+	return results, nil
+}
+
+// lockedOutputs lists the outputs that lockfile locks for system, sorted by
+// package. Packages without store paths, such as flakes, aren't listed.
+func lockedOutputs(lockfile *lock.File, system string) []OutputVerification {
+	keys := lo.Keys(lockfile.Packages)
+	slices.Sort(keys)
+
+	var outputs []OutputVerification
+	for _, key := range keys {
+		sysInfo := lockfile.Packages[key].Systems[system]
+		if sysInfo == nil {
+			continue
+		}
+		for _, out := range sysInfo.Outputs {
+			if out.Path == "" {
+				continue
+			}
+			outputs = append(outputs, OutputVerification{
+				Package: key,
+				Output:  out.Name,
+				Path:    out.Path,
+				Locked:  out.Hash,
+			})
+		}
+	}
+	return outputs
+}
+
+// checkOutputHashes sets the status of each output by comparing its locked
+// hash with the NAR hash in the store path infos.
+func checkOutputHashes(outputs []OutputVerification, infos []nix.PathInfo) {
+	actual := map[string]string{}
+	for _, info := range infos {
+		hash, err := info.Base32NarHash()
+		if err != nil {
+			debug.Log("verify: %s: %v", info.Path, err)
+			hash = info.NarHash
+		}
+		actual[info.Path] = hash
+	}
+	for i := range outputs {
+		out := &outputs[i]
+		hash, ok := actual[out.Path]
+		out.Actual = hash
+		switch {
+		case !ok:
+			out.Status = VerifyMissing
+		case out.Locked == "":
+			out.Status = VerifyUnhashed
+		case out.Locked != hash:
+			out.Status = VerifyMismatch
+		default:
+			out.Status = VerifyOK
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestCheckOutputHashes(t *testing.T) {
+	const (
+		emptyHash = "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"
+		otherHash = "sha256:1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s"
+	)
+	lockfile := &lock.File{Packages: map[string]*lock.Package{
+		"hello@2.12.1": {Systems: map[string]*lock.SystemInfo{
+			"x86_64-linux": {Outputs: []lock.Output{
+				{Name: "out", Path: "/nix/store/aaa-hello", Hash: emptyHash, Default: true},
+				{Name: "man", Path: "/nix/store/bbb-hello-man", Hash: emptyHash},
+			}},
+			"aarch64-darwin": {Outputs: []lock.Output{{Name: "out", Path: "/nix/store/ccc-hello"}}},
+		}},
+		"curl@8": {Systems: map[string]*lock.SystemInfo{
+			"x86_64-linux": {Outputs: []lock.Output{
+				{Name: "bin", Path: "/nix/store/ddd-curl-bin"},
+				{Name: "out", Path: "/nix/store/eee-curl", Hash: emptyHash},
+			}},
+		}},
+		"github:nixos/nixpkgs#jq": {},
+	}}
+
+	results := lockedOutputs(lockfile, "x86_64-linux")
+	checkOutputHashes(results, []nix.PathInfo{
+		// Nix 2.19 and later print SRI hashes.
+		{Path: "/nix/store/aaa-hello", NarHash: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+		{Path: "/nix/store/bbb-hello-man", NarHash: otherHash},
+		{Path: "/nix/store/ddd-curl-bin", NarHash: emptyHash},
+	})
+
+	statuses := map[string]string{}
+	for _, result := range results {
+		statuses[result.Path] = result.Status
+	}
+	require.Equal(t, map[string]string{
+		"/nix/store/aaa-hello":     VerifyOK,
+		"/nix/store/bbb-hello-man": VerifyMismatch,
+		"/nix/store/ddd-curl-bin":  VerifyUnhashed,
+		"/nix/store/eee-curl":      VerifyMissing,
+	}, statuses)
+	require.Equal(t, "curl@8", results[0].Package, "results are sorted by package")
+
+	unhashed := OutputVerification{Status: VerifyUnhashed}
+	require.False(t, unhashed.Failed(false))
+	require.True(t, unhashed.Failed(true))
+}
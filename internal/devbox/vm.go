@@ -0,0 +1,48 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/vm"
+)
+
+// VM returns the project's Colima VM, which runs colima from the devbox
+// environment.
+func (d *Devbox) VM(ctx context.Context) (*vm.Colima, error) {
+	if d.cfg.Root.VM == nil {
+		return nil, usererr.New(
+			"The project doesn't have a VM. Add a \"vm\" section to devbox.json, such as " +
+				`"vm": {"cpus": 4, "memory": 8}.`,
+		)
+	}
+	env, err := d.ensureStateIsUpToDateAndComputeEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &vm.Colima{
+		Profile:    d.vmProfile(),
+		Config:     d.cfg.Root.VM,
+		ProjectDir: d.projectDir,
+		Env:        env,
+	}, nil
+}
+
+func (d *Devbox) vmProfile() string {
+	return vm.ProfileName(d.projectDir, d.ProjectDirHash())
+}
+
+// vmEnv points Docker clients in the environment at the project's VM.
+// Variables in devbox.json's env take precedence.
+func (d *Devbox) vmEnv() map[string]string {
+	if d.cfg.Root.VM == nil {
+		return nil
+	}
+	return map[string]string{
+		"COLIMA_PROFILE": d.vmProfile(),
+		"DOCKER_HOST":    vm.DockerHost(d.vmProfile()),
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"go.jetpack.io/devbox/internal/cachehash"
 	"go.jetpack.io/devbox/internal/devbox/shellcmd"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/httpclient"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/plugin"
 )
@@ -25,6 +26,11 @@ type Config struct {
 
 	pluginData *plugin.PluginOnlyData // pointer by design, to allow for nil
 
+	// origin is what added this config to the project, such as
+	// "include:./plugins/redis" or "plugin:php@8.1". It's empty for
+	// devbox.json.
+	origin string
+
 	included []*Config
 }
 
@@ -85,7 +91,7 @@ func LoadConfigFromURL(ctx context.Context, url string) (*Config, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpclient.Client().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -141,6 +147,7 @@ func (c *Config) loadRecursive(
 		seen[pluginConfig.Source.Hash()] = true
 
 		includable := createIncludableFromPluginConfig(pluginConfig)
+		includable.origin = "include:" + includeRef
 
 		if err := includable.loadRecursive(
 			lockfile, maps.Clone(seen), newCyclePath); err != nil {
@@ -162,6 +169,7 @@ func (c *Config) loadRecursive(
 		includable := &Config{
 			Root:       builtIn.ConfigFile,
 			pluginData: &builtIn.PluginOnlyData,
+			origin:     "plugin:" + builtIn.Source.LockfileKey(),
 		}
 		newCyclePath := fmt.Sprintf("%s -> %s", cyclePath, builtIn.Source.LockfileKey())
 		if err := includable.loadRecursive(
@@ -239,6 +247,28 @@ func (c *Config) Env() map[string]string {
 	return env
 }
 
+// EnvSchema returns the env vars declared by the config and its includes. The
+// root config's declarations take precedence.
+func (c *Config) EnvSchema() map[string]*configfile.EnvVarSpec {
+	schema := map[string]*configfile.EnvVarSpec{}
+	for _, i := range c.included {
+		maps.Copy(schema, i.EnvSchema())
+	}
+	maps.Copy(schema, c.Root.EnvSchema)
+	return schema
+}
+
+// Credentials returns the credential helpers configured by the config and its
+// includes, keyed by name. The root config's helpers take precedence.
+func (c *Config) Credentials() map[string]*configfile.CredentialHelper {
+	creds := map[string]*configfile.CredentialHelper{}
+	for _, i := range c.included {
+		maps.Copy(creds, i.Credentials())
+	}
+	maps.Copy(creds, c.Root.Credentials)
+	return creds
+}
+
 func (c *Config) InitHook() *shellcmd.Commands {
 	commands := shellcmd.Commands{}
 	for _, i := range c.included {
@@ -248,6 +278,36 @@ func (c *Config) InitHook() *shellcmd.Commands {
 	return &commands
 }
 
+// PhaseHook is the commands that a config runs in a hook phase.
+type PhaseHook struct {
+	// Source is the plugin that the hook is from, or devbox.json.
+	Source    string                 `json:"source"`
+	Commands  *shellcmd.Commands     `json:"commands"`
+	OnFailure configfile.HookFailure `json:"on_failure"`
+}
+
+// Hooks returns the hooks of a phase in the order that they run: the hooks of
+// included plugins and configs in the order that they're included, and then
+// the config's own.
+func (c *Config) Hooks(phase configfile.HookPhase) []PhaseHook {
+	var hooks []PhaseHook
+	for _, i := range c.included {
+		hooks = append(hooks, i.Hooks(phase)...)
+	}
+	if cmds, onFailure := c.Root.Hook(phase); len(cmds.Cmds) > 0 {
+		hooks = append(hooks, PhaseHook{Source: c.source(), Commands: cmds, OnFailure: onFailure})
+	}
+	return hooks
+}
+
+// source describes where the config is from, for messages.
+func (c *Config) source() string {
+	if c.pluginData != nil && c.pluginData.Source != nil {
+		return "plugin " + c.pluginData.Source.LockfileKey()
+	}
+	return "devbox.json"
+}
+
 func (c *Config) Scripts() configfile.Scripts {
 	scripts := configfile.Scripts{}
 	for _, i := range c.included {
@@ -42,3 +42,41 @@ func TestDefault(t *testing.T) {
 		t.Errorf("got different JSON after load/save/load:\ninput:\n%s\noutput:\n%s", inBytes, outBytes)
 	}
 }
+
+func TestPackageTree(t *testing.T) {
+	load := func(origin, json string) *Config {
+		t.Helper()
+		cfg, err := loadBytes([]byte(json))
+		if err != nil {
+			t.Fatal("got load error:", err)
+		}
+		cfg.origin = origin
+		return cfg
+	}
+	redis := load("include:./plugins/redis", `{"packages": ["redis@7", "go@1.22"]}`)
+	php := load("plugin:php@8.1", `{"packages": ["php81Extensions.ds@latest"]}`)
+	root := load("", `{"packages": ["go@1.22", "php@8.1"]}`)
+	root.included = []*Config{redis, php}
+
+	want := &PackageTree{
+		Source:   "devbox.json",
+		Packages: []string{"go@1.22", "php@8.1"},
+		Included: []*PackageTree{
+			{Source: "include:./plugins/redis", Packages: []string{"redis@7", "go@1.22"}},
+			{Source: "plugin:php@8.1", Packages: []string{"php81Extensions.ds@latest"}},
+		},
+	}
+	if diff := cmp.Diff(want, root.PackageTree()); diff != "" {
+		t.Errorf("wrong package tree (-want +got):\n%s", diff)
+	}
+
+	wantOrigins := map[string][]string{
+		"go@1.22":                   {"devbox.json", "include:./plugins/redis"},
+		"php@8.1":                   {"devbox.json"},
+		"redis@7":                   {"include:./plugins/redis"},
+		"php81Extensions.ds@latest": {"plugin:php@8.1"},
+	}
+	if diff := cmp.Diff(wantOrigins, root.PackageOrigins()); diff != "" {
+		t.Errorf("wrong package origins (-want +got):\n%s", diff)
+	}
+}
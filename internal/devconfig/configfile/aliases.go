@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"regexp"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// aliasName matches the names that aliases can have, and the package names
+// that a version given with an alias can replace the version of.
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+func validateAliases(cfg *ConfigFile) error {
+	for alias, pkg := range cfg.Aliases {
+		if !aliasName.MatchString(alias) {
+			return usererr.New(
+				"aliases in devbox.json has %q, which isn't a package name. Aliases can have "+
+					"letters, digits and the characters . _ + -", alias)
+		}
+		if strings.TrimSpace(pkg) == "" {
+			return usererr.New("alias %q in devbox.json doesn't name a package", alias)
+		}
+		name, _, _ := strings.Cut(pkg, "@")
+		if _, ok := cfg.Aliases[name]; ok {
+			return usererr.New("alias %q in devbox.json refers to %q, which is also an alias", alias, name)
+		}
+	}
+	return nil
+}
+
+// ExpandAlias returns the package that pkg refers to if its name is one of
+// the aliases in devbox.json. A version given with the alias replaces the
+// alias's version, so with "node": "nodejs@20", "node@18" is "nodejs@18".
+// Outputs such as "#dev" are kept.
+func (c *ConfigFile) ExpandAlias(pkg string) (string, bool) {
+	ref, outputs, hasOutputs := strings.Cut(pkg, "#")
+	name, version, hasVersion := strings.Cut(ref, "@")
+	target, ok := c.Aliases[name]
+	if !ok {
+		return pkg, false
+	}
+	if hasVersion {
+		targetName, _, _ := strings.Cut(target, "@")
+		if !aliasName.MatchString(targetName) {
+			// Aliases of flakes and runx: packages can't be given
+			// another version.
+			return pkg, false
+		}
+		target = targetName + "@" + version
+	}
+	if hasOutputs {
+		target += "#" + outputs
+	}
+	return target, true
+}
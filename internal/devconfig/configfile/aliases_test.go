@@ -0,0 +1,51 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAliases(t *testing.T) {
+	assert.NoError(t, validateAliases(&ConfigFile{}))
+	assert.NoError(t, validateAliases(&ConfigFile{Aliases: map[string]string{
+		"node": "nodejs@20",
+		"lint": "runx:golangci/golangci-lint@v1.55.2",
+		"py":   "python312Packages.python",
+	}}))
+
+	assert.Error(t, validateAliases(&ConfigFile{Aliases: map[string]string{"my node": "nodejs@20"}}))
+	assert.Error(t, validateAliases(&ConfigFile{Aliases: map[string]string{"node@20": "nodejs@20"}}))
+	assert.Error(t, validateAliases(&ConfigFile{Aliases: map[string]string{"node": " "}}))
+	assert.Error(t, validateAliases(&ConfigFile{Aliases: map[string]string{
+		"node": "js@20",
+		"js":   "nodejs",
+	}}), "aliases can't refer to other aliases")
+}
+
+func TestExpandAlias(t *testing.T) {
+	cfg := &ConfigFile{Aliases: map[string]string{
+		"node": "nodejs@20",
+		"lint": "runx:golangci/golangci-lint@v1.55.2",
+	}}
+	for pkg, want := range map[string]string{
+		"node":         "nodejs@20",
+		"node@18":      "nodejs@18",
+		"node@latest":  "nodejs@latest",
+		"node#dev":     "nodejs@20#dev",
+		"node@18#dev":  "nodejs@18#dev",
+		"lint":         "runx:golangci/golangci-lint@v1.55.2",
+		"nodejs@20":    "nodejs@20",
+		"lint@v1.56.0": "lint@v1.56.0",
+	} {
+		got, _ := cfg.ExpandAlias(pkg)
+		assert.Equal(t, want, got, pkg)
+	}
+	_, ok := cfg.ExpandAlias("nodejs@20")
+	assert.False(t, ok)
+	_, ok = cfg.ExpandAlias("lint@v1.56.0")
+	assert.False(t, ok, "runx: aliases can't be given another version")
+}
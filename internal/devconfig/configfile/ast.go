@@ -277,6 +277,37 @@ func (c *configAST) convertVersionToObject(pkg *hujson.Value) {
 	pkg.Value = obj
 }
 
+// setScript sets the commands of a script in shell.scripts, adding the
+// objects that don't exist.
+func (c *configAST) setScript(name, command string) {
+	shell := c.objectMember(c.root.Value.(*hujson.Object), "shell")
+	scripts := c.objectMember(shell, "scripts")
+	value := hujson.Value{Value: hujson.String(command)}
+	if i := c.memberIndex(scripts, name); i != -1 {
+		scripts.Members[i].Value = value
+	} else {
+		scripts.Members = append(scripts.Members, hujson.ObjectMember{
+			Name:  hujson.Value{Value: hujson.String(name), BeforeExtra: []byte{'\n'}},
+			Value: value,
+		})
+	}
+	c.root.Format()
+}
+
+// objectMember returns the object value of an object member, adding an
+// empty one if obj doesn't have the member.
+func (c *configAST) objectMember(obj *hujson.Object, name string) *hujson.Object {
+	if i := c.memberIndex(obj, name); i != -1 {
+		return obj.Members[i].Value.Value.(*hujson.Object)
+	}
+	member := &hujson.Object{AfterExtra: []byte{'\n'}}
+	obj.Members = append(obj.Members, hujson.ObjectMember{
+		Name:  hujson.Value{Value: hujson.String(name), BeforeExtra: []byte{'\n'}},
+		Value: hujson.Value{Value: member},
+	})
+	return member
+}
+
 // memberIndex returns the index of an object member.
 func (*configAST) memberIndex(obj *hujson.Object, name string) int {
 	return slices.IndexFunc(obj.Members, func(m hujson.ObjectMember) bool {
@@ -0,0 +1,109 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// binaryCacheSchemes are the kinds of stores that devbox can look store
+// paths up in.
+var binaryCacheSchemes = []string{"http", "https", "s3"}
+
+// BinaryCache is a Nix binary cache in binary_caches. In devbox.json, it's
+// either the cache's URL, or an object with the URL and how to use the cache.
+type BinaryCache struct {
+	URL string `json:"url"`
+
+	// Priority is the cache's priority among Nix's substituters, which
+	// are tried from the lowest priority to the highest. cache.nixos.org
+	// has priority 40. Zero keeps the priority that the cache advertises.
+	Priority int `json:"priority,omitempty"`
+
+	// PublicKeys are the keys that the cache signs store paths with, in
+	// the format of Nix's trusted-public-keys setting.
+	PublicKeys []string `json:"public_keys,omitempty"`
+
+	// NetrcFile is a netrc file with the credentials of the cache. A
+	// relative path is relative to the project.
+	NetrcFile string `json:"netrc_file,omitempty"`
+
+	// PasswordEnv is the environment variable that has the cache's
+	// password or token, which is sent with Login, if it's set.
+	PasswordEnv string `json:"password_env,omitempty"`
+	Login       string `json:"login,omitempty"`
+}
+
+func (c *BinaryCache) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.URL)
+	}
+	type binaryCache BinaryCache
+	return json.Unmarshal(data, (*binaryCache)(c))
+}
+
+func (c BinaryCache) MarshalJSON() ([]byte, error) {
+	if c.Priority == 0 && len(c.PublicKeys) == 0 && c.NetrcFile == "" && c.PasswordEnv == "" && c.Login == "" {
+		return json.Marshal(c.URL)
+	}
+	type binaryCache BinaryCache
+	return json.Marshal(binaryCache(c))
+}
+
+// HasCredentials reports whether devbox.json says where the cache's
+// credentials are.
+func (c *BinaryCache) HasCredentials() bool {
+	return c.NetrcFile != "" || c.PasswordEnv != ""
+}
+
+// BinaryCacheURLs returns the URLs of the binary caches, in order.
+func (c *ConfigFile) BinaryCacheURLs() []string {
+	urls := make([]string, len(c.BinaryCaches))
+	for i, cache := range c.BinaryCaches {
+		urls[i] = cache.URL
+	}
+	return urls
+}
+
+func validateBinaryCaches(cfg *ConfigFile) error {
+	for _, cache := range cfg.BinaryCaches {
+		u, err := url.Parse(cache.URL)
+		if err != nil || !slices.Contains(binaryCacheSchemes, u.Scheme) || u.Host == "" {
+			return usererr.New(
+				"binary_caches in devbox.json has %q, which isn't an http://, https:// or s3:// URL of a binary cache",
+				cache.URL,
+			)
+		}
+		if cache.Priority < 0 {
+			return usererr.New("binary_caches in devbox.json: the priority of %s can't be negative", cache.URL)
+		}
+		for _, key := range cache.PublicKeys {
+			if _, err := nix.ParsePublicKey(key); err != nil {
+				return usererr.New("binary_caches in devbox.json: %s: %v", cache.URL, err)
+			}
+		}
+		if cache.NetrcFile != "" && cache.PasswordEnv != "" {
+			return usererr.New(
+				"binary_caches in devbox.json: %s can have either netrc_file or password_env, but not both",
+				cache.URL,
+			)
+		}
+		if cache.Login != "" && cache.PasswordEnv == "" {
+			return usererr.New("binary_caches in devbox.json: the login of %s needs a password_env", cache.URL)
+		}
+		if cache.HasCredentials() && u.Scheme == "s3" {
+			return usererr.New(
+				"binary_caches in devbox.json: %s is an S3 bucket, which uses AWS credentials from the environment "+
+					"instead of netrc_file or password_env",
+				cache.URL,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBinaryCaches(t *testing.T) {
+	assert.NoError(t, validateBinaryCaches(&ConfigFile{BinaryCaches: []BinaryCache{
+		{URL: "https://cache.acme.internal"},
+		{URL: "s3://acme-nix-cache?region=us-west-2"},
+		{URL: "https://private.acme.internal", Priority: 30, PasswordEnv: "CACHE_TOKEN", Login: "ci"},
+		{URL: "https://other.acme.internal", NetrcFile: "$HOME/.config/nix/netrc"},
+	}}))
+
+	invalid := []BinaryCache{
+		{URL: "cache.acme.internal"},
+		{URL: "ssh://builder"},
+		{URL: "https://"},
+		{URL: "https://cache.acme.internal", Priority: -1},
+		{URL: "https://cache.acme.internal", PublicKeys: []string{"not-a-key"}},
+		{URL: "https://cache.acme.internal", NetrcFile: "netrc", PasswordEnv: "CACHE_TOKEN"},
+		{URL: "https://cache.acme.internal", Login: "ci"},
+		{URL: "s3://acme-nix-cache", PasswordEnv: "CACHE_TOKEN"},
+	}
+	for _, cache := range invalid {
+		assert.Error(t, validateBinaryCaches(&ConfigFile{BinaryCaches: []BinaryCache{cache}}), cache.URL)
+	}
+}
+
+func TestBinaryCacheJSON(t *testing.T) {
+	var caches []BinaryCache
+	err := json.Unmarshal([]byte(`[
+		"https://cache.acme.internal",
+		{"url": "https://private.acme.internal", "priority": 30, "password_env": "CACHE_TOKEN"}
+	]`), &caches)
+	assert.NoError(t, err)
+	assert.Equal(t, []BinaryCache{
+		{URL: "https://cache.acme.internal"},
+		{URL: "https://private.acme.internal", Priority: 30, PasswordEnv: "CACHE_TOKEN"},
+	}, caches)
+
+	data, err := json.Marshal(caches)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		"https://cache.acme.internal",
+		{"url": "https://private.acme.internal", "priority": 30, "password_env": "CACHE_TOKEN"}
+	]`, string(data))
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// Credential helpers that can be used in the credentials section.
+const (
+	CredentialHelperAWSVault = "aws-vault"
+	CredentialHelperGcloud   = "gcloud"
+	CredentialHelperCommand  = "command"
+)
+
+var credentialHelpers = []string{
+	CredentialHelperAWSVault,
+	CredentialHelperGcloud,
+	CredentialHelperCommand,
+}
+
+// CredentialHelper configures a tool that mints short-lived cloud
+// credentials for the devbox environment.
+type CredentialHelper struct {
+	// Helper is one of the CredentialHelper constants.
+	Helper string `json:"helper"`
+	// Profile is the aws-vault profile to get credentials for.
+	Profile string `json:"profile,omitempty"`
+	// ImpersonateServiceAccount is the service account gcloud mints a token
+	// for, instead of the logged in account.
+	ImpersonateServiceAccount string `json:"impersonate_service_account,omitempty"`
+	// Command prints credentials as JSON for the "command" helper.
+	Command string `json:"command,omitempty"`
+	// Scripts limits the credentials to these scripts. If it's empty, they're
+	// minted for devbox shell and every script.
+	Scripts []string `json:"scripts,omitempty"`
+}
+
+// AppliesTo returns true if the credentials are needed to run script. An
+// empty script means devbox shell.
+func (c *CredentialHelper) AppliesTo(script string) bool {
+	return len(c.Scripts) == 0 || (script != "" && slices.Contains(c.Scripts, script))
+}
+
+func validateCredentials(cfg *ConfigFile) error {
+	for name, c := range cfg.Credentials {
+		if c == nil {
+			return usererr.New("credentials.%s in devbox.json must be an object", name)
+		}
+		var missing string
+		switch c.Helper {
+		case CredentialHelperAWSVault:
+			if c.Profile == "" {
+				missing = "profile"
+			}
+		case CredentialHelperGcloud:
+		case CredentialHelperCommand:
+			if c.Command == "" {
+				missing = "command"
+			}
+		default:
+			return usererr.New(
+				"credentials.%s in devbox.json has unknown helper %q. Supported helpers are: %s",
+				name, c.Helper, strings.Join(credentialHelpers, ", "),
+			)
+		}
+		if missing != "" {
+			return usererr.New("credentials.%s in devbox.json uses %s, which requires %q", name, c.Helper, missing)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialsValidation(t *testing.T) {
+	assert.NoError(t, validateCredentials(&ConfigFile{Credentials: map[string]*CredentialHelper{
+		"aws": {Helper: CredentialHelperAWSVault, Profile: "dev"},
+		"gcp": {Helper: CredentialHelperGcloud},
+	}}))
+	assert.Error(t, validateCredentials(&ConfigFile{Credentials: map[string]*CredentialHelper{
+		"aws": {Helper: CredentialHelperAWSVault},
+	}}))
+	assert.Error(t, validateCredentials(&ConfigFile{Credentials: map[string]*CredentialHelper{
+		"azure": {Helper: "az"},
+	}}))
+}
+
+func TestCredentialHelperAppliesTo(t *testing.T) {
+	all := &CredentialHelper{}
+	assert.True(t, all.AppliesTo(""))
+	assert.True(t, all.AppliesTo("deploy"))
+
+	deploy := &CredentialHelper{Scripts: []string{"deploy"}}
+	assert.False(t, deploy.AppliesTo(""))
+	assert.True(t, deploy.AppliesTo("deploy"))
+	assert.False(t, deploy.AppliesTo("test"))
+}
@@ -1,6 +1,26 @@
 package configfile
 
+import (
+	"path/filepath"
+	"strings"
+)
+
 func (c *ConfigFile) IsEnvsecEnabled() bool {
 	// envsec for legacy.
 	return c.EnvFrom == "envsec" || c.EnvFrom == "jetpack-cloud"
 }
+
+// IsEnvFromFile returns true if env_from points to a dotenv file, such as
+// ".env" or "config/dev.env", or to a YAML file of variables, such as
+// "secrets.enc.yaml". Either may be encrypted with SOPS.
+func (c *ConfigFile) IsEnvFromFile() bool {
+	if c.IsEnvsecEnabled() {
+		return false
+	}
+	base := filepath.Base(c.EnvFrom)
+	switch filepath.Ext(base) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return strings.HasSuffix(base, ".env") || strings.HasPrefix(base, ".env")
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// Types of env vars that can be declared in env_schema.
+const (
+	EnvTypeString  = "string"
+	EnvTypeInteger = "integer"
+	EnvTypeNumber  = "number"
+	EnvTypeBoolean = "boolean"
+	EnvTypeURL     = "url"
+)
+
+var envTypes = []string{EnvTypeString, EnvTypeInteger, EnvTypeNumber, EnvTypeBoolean, EnvTypeURL}
+
+// EnvVarSpec declares an env var that the project expects, so that devbox can
+// tell developers when it's missing or invalid.
+type EnvVarSpec struct {
+	Description string `json:"description,omitempty"`
+	// Type is one of the EnvType constants. It defaults to string.
+	Type     string `json:"type,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	// Enum, if set, lists the only values allowed.
+	Enum []string `json:"enum,omitempty"`
+}
+
+// Check returns a description of what's wrong with value, or an empty string
+// if it's valid.
+func (s *EnvVarSpec) Check(value string) string {
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		return fmt.Sprintf("must be one of %s", strings.Join(s.Enum, ", "))
+	}
+
+	var err error
+	switch s.Type {
+	case EnvTypeInteger:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case EnvTypeNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	case EnvTypeBoolean:
+		_, err = strconv.ParseBool(value)
+	case EnvTypeURL:
+		var u *url.URL
+		u, err = url.Parse(value)
+		// Require a host, so that "localhost:5432" isn't taken as a URL
+		// with the scheme "localhost".
+		if err == nil && (u.Scheme == "" || (u.Host == "" && u.Scheme != "file")) {
+			err = errors.New("missing scheme or host")
+		}
+	}
+	if err != nil {
+		return "must be " + typeNames[s.Type]
+	}
+	return ""
+}
+
+var typeNames = map[string]string{
+	EnvTypeInteger: "an integer",
+	EnvTypeNumber:  "a number",
+	EnvTypeBoolean: "a boolean (true or false)",
+	EnvTypeURL:     "a URL",
+}
+
+func validateEnvSchema(cfg *ConfigFile) error {
+	for name, spec := range cfg.EnvSchema {
+		if spec == nil {
+			return usererr.New("env_schema.%s in devbox.json must be an object", name)
+		}
+		if spec.Type != "" && !slices.Contains(envTypes, spec.Type) {
+			return usererr.New(
+				"env_schema.%s in devbox.json has unknown type %q. Supported types are: %s",
+				name, spec.Type, strings.Join(envTypes, ", "),
+			)
+		}
+		for _, v := range spec.Enum {
+			if problem := (&EnvVarSpec{Type: spec.Type}).Check(v); problem != "" {
+				return usererr.New("env_schema.%s in devbox.json allows %q, but values %s", name, v, problem)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvVarSpecCheck(t *testing.T) {
+	testCases := map[string]struct {
+		spec  EnvVarSpec
+		value string
+		want  string
+	}{
+		"string":          {EnvVarSpec{}, "anything", ""},
+		"integer":         {EnvVarSpec{Type: EnvTypeInteger}, "8080", ""},
+		"invalid_integer": {EnvVarSpec{Type: EnvTypeInteger}, "80.5", "must be an integer"},
+		"number":          {EnvVarSpec{Type: EnvTypeNumber}, "0.5", ""},
+		"boolean":         {EnvVarSpec{Type: EnvTypeBoolean}, "true", ""},
+		"invalid_boolean": {EnvVarSpec{Type: EnvTypeBoolean}, "yes", "must be a boolean (true or false)"},
+		"url":             {EnvVarSpec{Type: EnvTypeURL}, "postgres://localhost:5432/db", ""},
+		"invalid_url":     {EnvVarSpec{Type: EnvTypeURL}, "localhost:5432", "must be a URL"},
+		"enum":            {EnvVarSpec{Enum: []string{"dev", "prod"}}, "dev", ""},
+		"invalid_enum":    {EnvVarSpec{Enum: []string{"dev", "prod"}}, "test", "must be one of dev, prod"},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.want, testCase.spec.Check(testCase.value))
+		})
+	}
+}
+
+func TestEnvSchemaValidation(t *testing.T) {
+	assert.NoError(t, validateEnvSchema(&ConfigFile{EnvSchema: map[string]*EnvVarSpec{
+		"PORT": {Type: EnvTypeInteger, Required: true},
+	}}))
+	assert.Error(t, validateEnvSchema(&ConfigFile{EnvSchema: map[string]*EnvVarSpec{
+		"PORT": {Type: "int"},
+	}}))
+	assert.Error(t, validateEnvSchema(&ConfigFile{EnvSchema: map[string]*EnvVarSpec{
+		"PORT": {Type: EnvTypeInteger, Enum: []string{"80", "http"}},
+	}}))
+}
@@ -42,6 +42,48 @@ type ConfigFile struct {
 	// Only allows "envsec" for now
 	EnvFrom string `json:"env_from,omitempty"`
 
+	// EnvSchema declares the env vars the project expects, keyed by name.
+	EnvSchema map[string]*EnvVarSpec `json:"env_schema,omitempty"`
+
+	// Credentials configures helpers that mint short-lived cloud credentials,
+	// keyed by a name of the user's choosing.
+	Credentials map[string]*CredentialHelper `json:"credentials,omitempty"`
+
+	// LicensePolicy restricts the licenses of the packages the project can
+	// install.
+	LicensePolicy *LicensePolicy `json:"license_policy,omitempty"`
+
+	// BinaryCaches are Nix binary caches, such as an internal mirror, to
+	// find prebuilt packages in before cache.nixos.org. Devbox queries them
+	// in order, and Nix by their priority.
+	BinaryCaches []BinaryCache `json:"binary_caches,omitempty"`
+
+	// SignaturePolicy requires packages from binary caches to be signed by
+	// trusted keys.
+	SignaturePolicy *SignaturePolicy `json:"signature_policy,omitempty"`
+
+	// CADerivations builds the packages that aren't in a binary cache as
+	// content-addressed derivations, by setting __contentAddressed on them.
+	// Packages that are in a binary cache keep their input-addressed store
+	// paths, so that they're still downloaded instead of built.
+	CADerivations bool `json:"ca_derivations,omitempty"`
+
+	// PushCache is the binary cache that devbox cache push copies the
+	// project's packages to.
+	PushCache *PushCache `json:"push_cache,omitempty"`
+
+	// Resolver selects what resolves the versions of the project's
+	// packages. The search service does by default.
+	Resolver *Resolver `json:"resolver,omitempty"`
+
+	// Aliases map short names to packages, such as "node" to "nodejs@20",
+	// which devbox add and devbox search use before asking the search
+	// service.
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	// VM configures the Colima VM that runs the project's containers.
+	VM *VM `json:"vm,omitempty"`
+
 	// Shell configures the devbox shell environment.
 	Shell *shellConfig `json:"shell,omitempty"`
 	// Nixpkgs specifies the repository to pull packages from
@@ -62,6 +104,12 @@ type shellConfig struct {
 	// InitHook contains commands that will run at shell startup.
 	InitHook *shellcmd.Commands            `json:"init_hook,omitempty"`
 	Scripts  map[string]*shellcmd.Commands `json:"scripts,omitempty"`
+	// Hooks run in the other phases of the environment's lifecycle.
+	Hooks *HooksConfig `json:"hooks,omitempty"`
+	// Sandbox declares what `devbox run --sandbox` gives scripts access to.
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+	// Path controls which host directories are on the environment's PATH.
+	Path *PathConfig `json:"path,omitempty"`
 }
 
 type NixpkgsConfig struct {
@@ -154,7 +202,17 @@ func LoadBytes(b []byte) (*ConfigFile, error) {
 func validateConfig(cfg *ConfigFile) error {
 	fns := []func(cfg *ConfigFile) error{
 		ValidateNixpkg,
+		validatePackageVersions,
 		validateScripts,
+		validateEnvSchema,
+		validateCredentials,
+		validateLicensePolicy,
+		validateSignaturePolicy,
+		validateBinaryCaches,
+		validatePushCache,
+		validateResolver,
+		validateAliases,
+		validateVM,
 	}
 
 	for _, fn := range fns {
@@ -684,6 +684,63 @@ func TestSetAllowInsecure(t *testing.T) {
 	}
 }
 
+func TestSetScript(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {}
+}
+-- want --
+{
+  "packages": {},
+  "shell": {
+    "scripts": {
+      "install-requirements": "pip install -r requirements.txt"
+    }
+  }
+}`)
+
+	in.SetScript("install-requirements", "pip install -r requirements.txt")
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetScriptExisting(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "shell": {
+    "init_hook": ["echo hi"],
+    "scripts": {
+      "test": "go test ./..."
+    }
+  }
+}
+-- want --
+{
+  "shell": {
+    "init_hook": ["echo hi"],
+    "scripts": {
+      "test":  "go test -race ./...",
+      "build": "go build ./..."
+    }
+  }
+}`)
+
+	in.SetScript("test", "go test -race ./...")
+	in.SetScript("build", "go build ./...")
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
 func TestNixpkgsValidation(t *testing.T) {
 	testCases := map[string]struct {
 		commit   string
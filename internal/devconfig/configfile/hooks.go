@@ -0,0 +1,121 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.jetpack.io/devbox/internal/devbox/shellcmd"
+)
+
+// HookPhase is when in the environment's lifecycle a hook runs.
+type HookPhase string
+
+const (
+	// HookPreInit runs before the init hooks, every time they run.
+	HookPreInit HookPhase = "pre_init"
+	// HookInit is init_hook. It's sourced into the shell of devbox shell,
+	// scripts and services, so that it can change their environment.
+	HookInit HookPhase = "init"
+	// HookPostPackage runs after devbox installs, updates or removes
+	// packages.
+	HookPostPackage HookPhase = "post_package"
+	// HookPreScript runs before each script of devbox run, after the init
+	// hooks.
+	HookPreScript HookPhase = "pre_script"
+)
+
+// HookPhases are the phases in the order that they first run.
+var HookPhases = []HookPhase{HookPreInit, HookInit, HookPostPackage, HookPreScript}
+
+// HookFailure is what happens when a command of a hook fails. The hook stops
+// at the first command that fails.
+type HookFailure string
+
+const (
+	// HookFailureFail stops the devbox command, and skips the rest of the
+	// phase.
+	HookFailureFail HookFailure = "fail"
+	// HookFailureWarn prints a warning and continues.
+	HookFailureWarn HookFailure = "warn"
+	// HookFailureIgnore continues silently.
+	HookFailureIgnore HookFailure = "ignore"
+)
+
+// DefaultHookFailure returns the failure policy of a phase's hooks that
+// don't set on_failure. Init hooks always continue after a command fails,
+// since they're sourced into the shell.
+func DefaultHookFailure(phase HookPhase) HookFailure {
+	switch phase {
+	case HookPostPackage:
+		return HookFailureWarn
+	case HookInit:
+		return HookFailureIgnore
+	}
+	return HookFailureFail
+}
+
+// Hook is the commands of a hook phase. In devbox.json, it's either the
+// commands, or an object with the commands and an on_failure policy.
+type Hook struct {
+	Commands  shellcmd.Commands `json:"commands"`
+	OnFailure HookFailure       `json:"on_failure,omitempty"`
+}
+
+func (h *Hook) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && (data[0] == '"' || data[0] == '[') {
+		return h.Commands.UnmarshalJSON(data)
+	}
+	type hook Hook
+	if err := json.Unmarshal(data, (*hook)(h)); err != nil {
+		return err
+	}
+	switch h.OnFailure {
+	case "", HookFailureFail, HookFailureWarn, HookFailureIgnore:
+		return nil
+	}
+	return fmt.Errorf("invalid on_failure %q: must be fail, warn or ignore", h.OnFailure)
+}
+
+func (h Hook) MarshalJSON() ([]byte, error) {
+	if h.OnFailure == "" {
+		return h.Commands.MarshalJSON()
+	}
+	type hook Hook
+	return json.Marshal(hook(h))
+}
+
+// HooksConfig is the hooks of each phase besides init, which is init_hook.
+type HooksConfig struct {
+	PreInit     *Hook `json:"pre_init,omitempty"`
+	PostPackage *Hook `json:"post_package,omitempty"`
+	PreScript   *Hook `json:"pre_script,omitempty"`
+}
+
+// Hook returns the config's hook for a phase, and the phase's failure
+// policy. The hook has no commands if the config doesn't have one.
+func (c *ConfigFile) Hook(phase HookPhase) (*shellcmd.Commands, HookFailure) {
+	if phase == HookInit {
+		return c.InitHook(), HookFailureIgnore
+	}
+	var hook *Hook
+	if c != nil && c.Shell != nil && c.Shell.Hooks != nil {
+		switch phase {
+		case HookPreInit:
+			hook = c.Shell.Hooks.PreInit
+		case HookPostPackage:
+			hook = c.Shell.Hooks.PostPackage
+		case HookPreScript:
+			hook = c.Shell.Hooks.PreScript
+		}
+	}
+	if hook == nil {
+		return &shellcmd.Commands{}, DefaultHookFailure(phase)
+	}
+	if hook.OnFailure == "" {
+		return &hook.Commands, DefaultHookFailure(phase)
+	}
+	return &hook.Commands, hook.OnFailure
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookUnmarshal(t *testing.T) {
+	var cfg shellConfig
+	err := json.Unmarshal([]byte(`{
+		"hooks": {
+			"pre_init": "echo a",
+			"post_package": ["echo b", "echo c"],
+			"pre_script": {"commands": "echo d", "on_failure": "ignore"}
+		}
+	}`), &cfg)
+	require.NoError(t, err)
+	file := &ConfigFile{Shell: &cfg}
+
+	cmds, onFailure := file.Hook(HookPreInit)
+	assert.Equal(t, []string{"echo a"}, cmds.Cmds)
+	assert.Equal(t, HookFailureFail, onFailure)
+
+	cmds, onFailure = file.Hook(HookPostPackage)
+	assert.Equal(t, []string{"echo b", "echo c"}, cmds.Cmds)
+	assert.Equal(t, HookFailureWarn, onFailure)
+
+	cmds, onFailure = file.Hook(HookPreScript)
+	assert.Equal(t, []string{"echo d"}, cmds.Cmds)
+	assert.Equal(t, HookFailureIgnore, onFailure)
+
+	out, err := json.Marshal(cfg.Hooks)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"pre_init": "echo a",
+		"post_package": ["echo b", "echo c"],
+		"pre_script": {"commands": "echo d", "on_failure": "ignore"}
+	}`, string(out))
+
+	err = json.Unmarshal([]byte(`{"hooks": {"pre_init": {"commands": "x", "on_failure": "retry"}}}`), &cfg)
+	assert.Error(t, err)
+}
+
+func TestHookMissing(t *testing.T) {
+	var file *ConfigFile
+	cmds, onFailure := file.Hook(HookPreScript)
+	assert.Empty(t, cmds.Cmds)
+	assert.Equal(t, HookFailureFail, onFailure)
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// How a license policy is enforced.
+const (
+	LicenseEnforcementWarn  = "warn"
+	LicenseEnforcementBlock = "block"
+)
+
+// LicensePolicy restricts the licenses of the packages a project can
+// install. Licenses are SPDX identifiers, such as "MIT" or "GPL-3.0-only",
+// or nixpkgs license names (such as "unfree") for licenses that don't have
+// one. Patterns can end in "*" to match a family of licenses, such as
+// "AGPL-*".
+type LicensePolicy struct {
+	// Allow lists the only licenses that are allowed. If it's empty, any
+	// license that isn't denied is allowed.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists licenses that aren't allowed.
+	Deny []string `json:"deny,omitempty"`
+	// Enforcement is LicenseEnforcementWarn (the default) or
+	// LicenseEnforcementBlock.
+	Enforcement string `json:"enforcement,omitempty"`
+	// Exceptions are packages the policy doesn't apply to.
+	Exceptions []string `json:"exceptions,omitempty"`
+}
+
+// Blocks returns true if packages that violate the policy can't be
+// installed.
+func (p *LicensePolicy) Blocks() bool {
+	return p.Enforcement == LicenseEnforcementBlock
+}
+
+// Check returns why a package with the given licenses violates the policy,
+// or an empty string if it doesn't. A package with several licenses can be
+// used under any of them, so it only violates the policy if none of them is
+// acceptable.
+func (p *LicensePolicy) Check(licenses []string) string {
+	if len(licenses) == 0 {
+		if len(p.Allow) > 0 {
+			return "its license is unknown"
+		}
+		return ""
+	}
+
+	var reasons []string
+	for _, license := range licenses {
+		switch {
+		case matchLicense(p.Deny, license):
+			reasons = append(reasons, license+" is denied")
+		case len(p.Allow) > 0 && !matchLicense(p.Allow, license):
+			reasons = append(reasons, license+" isn't allowed")
+		default:
+			return ""
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// IsException returns true if the policy doesn't apply to the package.
+func (p *LicensePolicy) IsException(pkg string) bool {
+	return slices.Contains(p.Exceptions, pkg)
+}
+
+func matchLicense(patterns []string, license string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(license)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLicensePolicy(cfg *ConfigFile) error {
+	return ValidateLicensePolicy(cfg.LicensePolicy, "devbox.json")
+}
+
+// ValidateLicensePolicy checks that a policy from devbox.json or an
+// organization policy file is well-formed. source names where it came from
+// in error messages.
+func ValidateLicensePolicy(p *LicensePolicy, source string) error {
+	if p == nil {
+		return nil
+	}
+	switch p.Enforcement {
+	case "", LicenseEnforcementWarn, LicenseEnforcementBlock:
+	default:
+		return usererr.New(
+			"license_policy.enforcement in %s must be %q or %q, not %q",
+			source, LicenseEnforcementWarn, LicenseEnforcementBlock, p.Enforcement,
+		)
+	}
+	for _, pattern := range slices.Concat(p.Allow, p.Deny) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return usererr.New("license_policy in %s has an invalid pattern %q", source, pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLicensePolicyCheck(t *testing.T) {
+	policy := &LicensePolicy{
+		Allow: []string{"MIT", "Apache-2.0", "BSD-*", "GPL-3.0-only"},
+		Deny:  []string{"GPL-*"},
+	}
+	testCases := map[string]struct {
+		licenses []string
+		want     string
+	}{
+		"allowed":         {[]string{"MIT"}, ""},
+		"case":            {[]string{"apache-2.0"}, ""},
+		"pattern":         {[]string{"BSD-3-Clause"}, ""},
+		"denied":          {[]string{"GPL-3.0-only"}, "GPL-3.0-only is denied"},
+		"not_allowed":     {[]string{"BUSL-1.1"}, "BUSL-1.1 isn't allowed"},
+		"dual_licensed":   {[]string{"GPL-2.0-or-later", "MIT"}, ""},
+		"all_unavailable": {[]string{"GPL-2.0-only", "unfree"}, "GPL-2.0-only is denied, unfree isn't allowed"},
+		"unknown":         {nil, "its license is unknown"},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.want, policy.Check(testCase.licenses))
+		})
+	}
+
+	denyOnly := &LicensePolicy{Deny: []string{"unfree"}}
+	assert.Equal(t, "", denyOnly.Check(nil))
+	assert.Equal(t, "unfree is denied", denyOnly.Check([]string{"unfree"}))
+}
+
+func TestLicensePolicyValidation(t *testing.T) {
+	assert.NoError(t, ValidateLicensePolicy(&LicensePolicy{Deny: []string{"AGPL-*"}}, "devbox.json"))
+	assert.Error(t, ValidateLicensePolicy(&LicensePolicy{Enforcement: "fail"}, "devbox.json"))
+	assert.Error(t, ValidateLicensePolicy(&LicensePolicy{Deny: []string{"GPL-[2"}}, "devbox.json"))
+}
@@ -4,6 +4,7 @@ package configfile
 import (
 	"encoding/json"
 	"io"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -154,6 +155,26 @@ func (pkgs *PackagesMutator) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// validatePackageVersions checks the commits that packages are pinned to
+// and the versions of packages that have one for each system.
+func validatePackageVersions(cfg *ConfigFile) error {
+	for i := range cfg.PackagesMutator.collection {
+		if err := cfg.PackagesMutator.collection[i].validateCommit(); err != nil {
+			return err
+		}
+		if err := cfg.PackagesMutator.collection[i].validateSystemVersions(); err != nil {
+			return err
+		}
+		if err := cfg.PackagesMutator.collection[i].validateChannel(); err != nil {
+			return err
+		}
+		if err := cfg.PackagesMutator.collection[i].validateVersionRanges(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (pkgs *PackagesMutator) SetPatchGLibc(versionedName string, v bool) error {
 	name, version := parseVersionedName(versionedName)
 	i := pkgs.index(name, version)
@@ -244,12 +265,79 @@ type Package struct {
 	PatchGlibc bool `json:"patch_glibc,omitempty"`
 
 	// Outputs is the list of outputs to use for this package, assuming
-	// it is a nix package. If empty, the default output is used.
+	// it is a nix package. If empty, the default output is used. The
+	// version can also end in "#<output>,...", as in "curl@8.1#dev".
 	Outputs []string `json:"outputs,omitempty"`
 
 	// AllowInsecure is a whitelist of packages that may be marked insecure
 	// in nixpkgs, but are allowed by the user to be installed.
 	AllowInsecure []string `json:"allow_insecure,omitempty"`
+
+	// Commit pins the package to a nixpkgs commit. Devbox installs the
+	// package's attribute from that commit, instead of the commit that the
+	// search API resolves the version to. The version can also end in
+	// "#commit=<hash>", as in "hello@2.12.1#commit=<hash>".
+	Commit string `json:"commit,omitempty"`
+
+	// systemVersions is the version object of a package that has a
+	// different version on each system, such as {"x86_64-linux": "18"}.
+	// Version holds the same versions in searcher.FormatSystemVersions form.
+	systemVersions map[string]string
+}
+
+// commitPinPrefix starts the shorthand for Commit at the end of a version.
+const commitPinPrefix = "#commit="
+
+// splitCommitPin returns the version without a "#commit=<hash>" pin, and
+// the commit.
+func splitCommitPin(version string) (string, string) {
+	version, commit, _ := strings.Cut(version, commitPinPrefix)
+	return version, commit
+}
+
+// outputSelectorRegex matches the comma-separated output names that can
+// follow a "#" at the end of a version.
+var outputSelectorRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+(,[a-zA-Z0-9_-]+)*$`)
+
+// splitOutputSelector returns the version without a "#<output>,..." suffix,
+// and the outputs it selects.
+func splitOutputSelector(version string) (string, []string) {
+	base, selector, found := strings.Cut(version, "#")
+	if !found || !outputSelectorRegex.MatchString(selector) {
+		return version, nil
+	}
+	return base, strings.Split(selector, ",")
+}
+
+// SplitOutputSelector splits the selected outputs off a versioned package
+// name like "curl@8.1#dev,lib", returning "curl@8.1" and [dev lib]. Names
+// without a version are returned unchanged, since "name#output" is a flake
+// installable.
+func SplitOutputSelector(versionedName string) (string, []string) {
+	name, version, found := searcher.ParseVersionedPackage(versionedName)
+	if !found || strings.ContainsAny(name, ":/#") {
+		return versionedName, nil
+	}
+	version, outputs := splitOutputSelector(version)
+	if outputs == nil {
+		return versionedName, nil
+	}
+	return name + "@" + version, outputs
+}
+
+// validateCommit checks that the package's commit is a full nixpkgs commit
+// hash, since Nix reads anything else as a branch or tag name.
+func (p *Package) validateCommit() error {
+	if p.Commit == "" {
+		return nil
+	}
+	if len(p.Commit) != 40 || strings.Trim(p.Commit, "0123456789abcdef") != "" {
+		return usererr.New("The commit %q of package %s isn't a full nixpkgs commit hash.", p.Commit, p.Name)
+	}
+	if p.Version == "" {
+		return usererr.New("Package %s is pinned to a commit, so it needs a version, such as %s@latest.", p.Name, p.Name)
+	}
+	return nil
 }
 
 func NewVersionOnlyPackage(name, version string) Package {
@@ -265,6 +353,10 @@ func NewVersionOnlyPackage(name, version string) Package {
 // except those.
 func (p *Package) IsEnabledOnPlatform() bool {
 	platform := nix.System()
+	if versions, ok := p.SystemVersions(); ok {
+		_, ok := versions[platform]
+		return ok
+	}
 	if len(p.Platforms) > 0 {
 		for _, plt := range p.Platforms {
 			if plt == platform {
@@ -293,18 +385,123 @@ func (p *Package) UnmarshalJSON(data []byte) error {
 	// First, attempt to unmarshal as a version-only string
 	var version string
 	if err := json.Unmarshal(data, &version); err == nil {
-		p.Version = version
+		version, p.Outputs = splitOutputSelector(version)
+		p.Version, p.Commit = splitCommitPin(version)
 		return nil
 	}
 
 	// Second, attempt to unmarshal as a Package struct
 	type packageAlias Package // Use an alias-type to avoid infinite recursion
-	alias := &packageAlias{}
+	alias := &struct {
+		*packageAlias
+		// Version is a string, or an object with the version for each system.
+		Version json.RawMessage `json:"version,omitempty"`
+	}{packageAlias: (*packageAlias)(p)}
 	if err := json.Unmarshal(data, alias); err != nil {
 		return errors.WithStack(err)
 	}
+	if err := p.unmarshalVersion(alias.Version); err != nil {
+		return err
+	}
+
+	if version, outputs := splitOutputSelector(p.Version); outputs != nil {
+		p.Version = version
+		for _, o := range outputs {
+			if !slices.Contains(p.Outputs, o) {
+				p.Outputs = append(p.Outputs, o)
+			}
+		}
+	}
+	if version, commit := splitCommitPin(p.Version); commit != "" {
+		p.Version = version
+		p.Commit = commit
+	}
+	return nil
+}
+
+func (p *Package) unmarshalVersion(data json.RawMessage) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, &p.systemVersions); err == nil {
+		p.Version = searcher.FormatSystemVersions(p.systemVersions)
+		return nil
+	}
+	return errors.WithStack(json.Unmarshal(data, &p.Version))
+}
 
-	*p = Package(*alias)
+// SystemVersions returns the version of the package on each system, if
+// devbox.json gives it a different version on each one.
+func (p *Package) SystemVersions() (map[string]string, bool) {
+	return searcher.ParseSystemVersions(p.Version)
+}
+
+// validateSystemVersions checks the version of a package that has one for
+// each system.
+func (p *Package) validateSystemVersions() error {
+	if p.systemVersions == nil {
+		return nil
+	}
+	if len(p.systemVersions) == 0 {
+		return usererr.New("Package %s needs a version for at least one system.", p.Name)
+	}
+	for system, version := range p.systemVersions {
+		if err := nix.EnsureValidPlatform(system); err != nil {
+			return err
+		}
+		if version == "" || strings.ContainsAny(version, "=,#@") {
+			return usererr.New("Package %s has an invalid version %q for %s.", p.Name, version, system)
+		}
+	}
+	if p.Commit != "" {
+		return usererr.New("Package %s has a version for each system, so it can't be pinned to a commit.", p.Name)
+	}
+	if len(p.Platforms) > 0 || len(p.ExcludedPlatforms) > 0 {
+		return usererr.New(
+			"Package %s is only installed on the systems that it has a version for, "+
+				"so it can't also set platforms or excluded_platforms.", p.Name,
+		)
+	}
+	return nil
+}
+
+// validateVersionRanges checks the versions of the package that are semver
+// ranges, such as ^1.21.
+func (p *Package) validateVersionRanges() error {
+	versions := map[string]string{"": p.Version}
+	if p.systemVersions != nil {
+		versions = p.systemVersions
+	}
+	for system, version := range versions {
+		version, _, _ = searcher.ParseChannel(version)
+		if !searcher.IsVersionConstraint(version) {
+			continue
+		}
+		if _, err := searcher.ParseVersionConstraint(version); err != nil {
+			if system != "" {
+				return usererr.New("Package %s has an invalid version range for %s: %v.", p.Name, system, err)
+			}
+			return usererr.New("Package %s has an invalid version range: %v.", p.Name, err)
+		}
+		if p.Commit != "" {
+			return usererr.New("Package %s has a version range, so it can't be pinned to a commit.", p.Name)
+		}
+	}
+	return nil
+}
+
+// validateChannel checks the nixpkgs channel that the package's version
+// selects, as in go@1.23?channel=nixpkgs-unstable.
+func (p *Package) validateChannel() error {
+	if !strings.Contains(p.Version, "?") {
+		return nil
+	}
+	if _, _, err := searcher.ParseChannel(p.Version); err != nil {
+		return usererr.New("Package %s has an invalid channel: %v.", p.Name, err)
+	}
+	if p.Commit != "" {
+		return usererr.New("Package %s selects a channel, so it can't be pinned to a commit.", p.Name)
+	}
 	return nil
 }
 
@@ -326,7 +523,10 @@ func packagesFromLegacyList(packages []string) []Package {
 	packagesList := []Package{}
 	for _, p := range packages {
 		name, version := parseVersionedName(p)
-		packagesList = append(packagesList, NewVersionOnlyPackage(name, version))
+		pkg := NewVersionOnlyPackage(name, version)
+		version, pkg.Outputs = splitOutputSelector(version)
+		pkg.Version, pkg.Commit = splitCommitPin(version)
+		packagesList = append(packagesList, pkg)
 	}
 	return packagesList
 }
@@ -1,11 +1,14 @@
 package configfile
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/tailscale/hujson"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
 )
 
 // TestJsonifyConfigPackages tests the jsonMarshal and jsonUnmarshal of the Config.Packages field
@@ -302,3 +305,193 @@ func TestParseVersionedName(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageCommitPin(t *testing.T) {
+	commit := "75a52265bda7fd25e06e3a67dee3f0354e73243c"
+	for name, packages := range map[string]string{
+		"array":     `["hello@2.12.1#commit=` + commit + `"]`,
+		"version":   `{"hello": "2.12.1#commit=` + commit + `"}`,
+		"field":     `{"hello": {"version": "2.12.1", "commit": "` + commit + `"}}`,
+		"shorthand": `{"hello": {"version": "2.12.1#commit=` + commit + `"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := LoadBytes([]byte(`{"packages": ` + packages + `}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgs := cfg.TopLevelPackages()
+			if len(pkgs) != 1 {
+				t.Fatalf("got %d packages, want 1", len(pkgs))
+			}
+			if got := pkgs[0].VersionedName(); got != "hello@2.12.1" {
+				t.Errorf("got versioned name %q, want hello@2.12.1", got)
+			}
+			if pkgs[0].Commit != commit {
+				t.Errorf("got commit %q, want %q", pkgs[0].Commit, commit)
+			}
+		})
+	}
+}
+
+func TestPackageCommitPinInvalid(t *testing.T) {
+	for name, packages := range map[string]string{
+		"short":      `{"hello": "2.12.1#commit=75a5226"}`,
+		"branch":     `{"hello": {"version": "2.12.1", "commit": "nixos-unstable"}}`,
+		"no-version": `{"hello": {"commit": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadBytes([]byte(`{"packages": ` + packages + `}`)); err == nil {
+				t.Error("got nil error for an invalid commit")
+			}
+		})
+	}
+}
+
+func TestPackageOutputSelector(t *testing.T) {
+	for name, packages := range map[string]string{
+		"array":     `["curl@8.1#dev,lib"]`,
+		"version":   `{"curl": "8.1#dev,lib"}`,
+		"shorthand": `{"curl": {"version": "8.1#dev,lib"}}`,
+		"merged":    `{"curl": {"version": "8.1#lib", "outputs": ["dev"]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := LoadBytes([]byte(`{"packages": ` + packages + `}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgs := cfg.TopLevelPackages()
+			if len(pkgs) != 1 {
+				t.Fatalf("got %d packages, want 1", len(pkgs))
+			}
+			if got := pkgs[0].VersionedName(); got != "curl@8.1" {
+				t.Errorf("got versioned name %q, want curl@8.1", got)
+			}
+			got := slices.Clone(pkgs[0].Outputs)
+			slices.Sort(got)
+			if want := []string{"dev", "lib"}; !slices.Equal(got, want) {
+				t.Errorf("got outputs %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitOutputSelector(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		name    string
+		outputs []string
+	}{
+		{"curl@8.1#dev", "curl@8.1", []string{"dev"}},
+		{"curl@latest#out,dev", "curl@latest", []string{"out", "dev"}},
+		{"curl@8.1", "curl@8.1", nil},
+		{"curl#dev", "curl#dev", nil},
+		{"hello@2.12.1#commit=abc", "hello@2.12.1#commit=abc", nil},
+		{"github:org/repo@v1#pkg", "github:org/repo@v1#pkg", nil},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			name, outputs := SplitOutputSelector(tt.in)
+			if name != tt.name || !slices.Equal(outputs, tt.outputs) {
+				t.Errorf("got %q %v, want %q %v", name, outputs, tt.name, tt.outputs)
+			}
+		})
+	}
+}
+
+func TestPackageSystemVersions(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"packages": {
+		"nodejs": {"version": {"aarch64-darwin": "20", "x86_64-linux": "18"}}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := cfg.TopLevelPackages()[0]
+	if got, want := pkg.VersionedName(), "nodejs@aarch64-darwin=20,x86_64-linux=18"; got != want {
+		t.Errorf("got versioned name %q, want %q", got, want)
+	}
+	versions, ok := pkg.SystemVersions()
+	if !ok || versions["aarch64-darwin"] != "20" || versions["x86_64-linux"] != "18" {
+		t.Errorf("got system versions %v, %v", versions, ok)
+	}
+
+	// The package is only installed on the systems that have a version.
+	other := "i686-linux"
+	if nix.System() == other {
+		other = "armv7l-linux"
+	}
+	for system, want := range map[string]bool{nix.System(): true, other: false} {
+		pkg := Package{Name: "nodejs", Version: searcher.FormatSystemVersions(map[string]string{system: "18"})}
+		if got := pkg.IsEnabledOnPlatform(); got != want {
+			t.Errorf("got IsEnabledOnPlatform() = %v with a version for %s on %s", got, system, nix.System())
+		}
+	}
+}
+
+func TestPackageSystemVersionsInvalid(t *testing.T) {
+	for name, pkg := range map[string]string{
+		"empty":    `{"version": {}}`,
+		"system":   `{"version": {"x86_64-windows": "18"}}`,
+		"version":  `{"version": {"x86_64-linux": ""}}`,
+		"commit":   `{"version": {"x86_64-linux": "18"}, "commit": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}`,
+		"platform": `{"version": {"x86_64-linux": "18"}, "platforms": ["x86_64-linux"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadBytes([]byte(`{"packages": {"nodejs": ` + pkg + `}}`)); err == nil {
+				t.Error("got nil error for invalid per-system versions")
+			}
+		})
+	}
+}
+
+func TestPackageVersionRanges(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"packages": {"go": "^1.21", "nodejs": {"version": ">=18 <21"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range cfg.PackagesMutator.collection {
+		got = append(got, pkg.VersionedName())
+	}
+	want := []string{"go@^1.21", "nodejs@>=18 <21"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got packages %v, want %v", got, want)
+	}
+
+	for name, pkg := range map[string]string{
+		"range":  `"^abc"`,
+		"system": `{"version": {"x86_64-linux": "^1.2.3.4"}}`,
+		"commit": `{"version": "^18", "commit": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadBytes([]byte(`{"packages": {"nodejs": ` + pkg + `}}`)); err == nil {
+				t.Error("got nil error for an invalid version range")
+			}
+		})
+	}
+}
+
+func TestPackageChannels(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"packages": ["go@1.23?channel=nixpkgs-unstable", "nodejs@^20?channel=nixos-24.05"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range cfg.PackagesMutator.collection {
+		got = append(got, pkg.VersionedName())
+	}
+	want := []string{"go@1.23?channel=nixpkgs-unstable", "nodejs@^20?channel=nixos-24.05"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got packages %v, want %v", got, want)
+	}
+
+	for name, pkg := range map[string]string{
+		"channel": `"18?channel=main"`,
+		"param":   `"18?branch=nixpkgs-unstable"`,
+		"commit":  `{"version": "18?channel=nixpkgs-unstable", "commit": "75a52265bda7fd25e06e3a67dee3f0354e73243c"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadBytes([]byte(`{"packages": {"nodejs": ` + pkg + `}}`)); err == nil {
+				t.Error("got nil error for an invalid channel")
+			}
+		})
+	}
+}
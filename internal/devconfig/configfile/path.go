@@ -0,0 +1,26 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+// PathConfig controls which directories the PATH of the devbox environment
+// has.
+type PathConfig struct {
+	// Hermetic removes the host's directories from PATH, so that only the
+	// project's packages, plugins and the Nix store are on it, along with
+	// the directories in Allow.
+	Hermetic bool `json:"hermetic,omitempty"`
+	// Allow are host directories that a hermetic PATH keeps. Relative paths
+	// are relative to the project directory, and a leading ~ is the user's
+	// home directory.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// Path returns the project's PATH settings, which are empty if it doesn't
+// have any.
+func (c *ConfigFile) Path() PathConfig {
+	if c == nil || c.Shell == nil || c.Shell.Path == nil {
+		return PathConfig{}
+	}
+	return *c.Shell.Path
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"net/url"
+	"slices"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// PushCache is the binary cache that devbox cache push copies the project's
+// packages to, so that a package one teammate builds from source is a
+// download for everyone else.
+type PushCache struct {
+	// URI is the Nix store to copy to, such as
+	// "s3://acme-nix-cache?region=us-west-2", the URL of an attic cache or
+	// "ssh-ng://nix-serve.acme.internal".
+	URI string `json:"uri"`
+	// SigningKey is the path of the secret key, made by
+	// `nix key generate-secret`, that store paths are signed with before
+	// they're copied. Environment variables in it are expanded, and a
+	// relative path is relative to devbox.json.
+	SigningKey string `json:"signing_key,omitempty"`
+	// AutoPush pushes the packages that devbox builds from source to the
+	// cache after they're built.
+	AutoPush bool `json:"auto_push,omitempty"`
+}
+
+// pushCacheSchemes are the kinds of stores that nix copy can copy to.
+var pushCacheSchemes = []string{"file", "http", "https", "s3", "ssh", "ssh-ng"}
+
+func validatePushCache(cfg *ConfigFile) error {
+	if cfg.PushCache == nil {
+		return nil
+	}
+	u, err := url.Parse(cfg.PushCache.URI)
+	if err != nil || !slices.Contains(pushCacheSchemes, u.Scheme) || (u.Host == "" && u.Path == "") {
+		return usererr.New(
+			"push_cache.uri in devbox.json is %q, which isn't the URI of a Nix store, such as "+
+				"s3://bucket, https://cache.example.com or ssh-ng://host",
+			cfg.PushCache.URI,
+		)
+	}
+	return nil
+}
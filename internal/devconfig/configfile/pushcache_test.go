@@ -0,0 +1,25 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePushCache(t *testing.T) {
+	assert.NoError(t, validatePushCache(&ConfigFile{}))
+	for _, uri := range []string{
+		"s3://acme-nix-cache?region=us-west-2",
+		"https://attic.acme.internal/acme",
+		"ssh-ng://nix-serve.acme.internal",
+		"file:///srv/nix-cache",
+	} {
+		assert.NoError(t, validatePushCache(&ConfigFile{PushCache: &PushCache{URI: uri}}), uri)
+	}
+	for _, uri := range []string{"", "acme-nix-cache", "ftp://cache.acme.internal", "https://"} {
+		assert.Error(t, validatePushCache(&ConfigFile{PushCache: &PushCache{URI: uri}}), uri)
+	}
+}
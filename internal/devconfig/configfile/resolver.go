@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// Resolver selects what resolves the versions of the project's packages to
+// the nixpkgs revisions that devbox.lock pins.
+type Resolver struct {
+	// Backend is "search" for the search service, which is the default,
+	// "index" for a package index file or "nixpkgs" to evaluate packages
+	// in a nixpkgs flake. Builds of devbox can register other backends.
+	Backend string `json:"backend,omitempty"`
+	// Index is the package index file that the "index" backend reads,
+	// relative to devbox.json.
+	Index string `json:"index,omitempty"`
+	// Nixpkgs is the flake reference of the nixpkgs that the "nixpkgs"
+	// backend evaluates, which defaults to the nixpkgs-unstable branch.
+	Nixpkgs string `json:"nixpkgs,omitempty"`
+	// Options are settings for a backend that a build of devbox registers.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+func validateResolver(cfg *ConfigFile) error {
+	r := cfg.Resolver
+	if r == nil {
+		return nil
+	}
+	if (r.Backend == "index") != (r.Index != "") {
+		return usererr.New(`resolver in devbox.json must set index exactly when backend is "index"`)
+	}
+	if r.Nixpkgs != "" {
+		if r.Backend != "nixpkgs" {
+			return usererr.New(`resolver.nixpkgs in devbox.json is only used when backend is "nixpkgs"`)
+		}
+		if _, err := flake.ParseRef(r.Nixpkgs); err != nil {
+			return usererr.New("resolver.nixpkgs in devbox.json isn't a flake reference: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResolver(t *testing.T) {
+	assert.NoError(t, validateResolver(&ConfigFile{}))
+	assert.NoError(t, validateResolver(&ConfigFile{Resolver: &Resolver{Backend: "index", Index: "index.json"}}))
+	assert.NoError(t, validateResolver(&ConfigFile{Resolver: &Resolver{
+		Backend: "nixpkgs",
+		Nixpkgs: "github:NixOS/nixpkgs/nixos-24.05",
+	}}))
+	assert.NoError(t, validateResolver(&ConfigFile{Resolver: &Resolver{
+		Backend: "acme",
+		Options: map[string]string{"url": "https://resolve.acme.internal"},
+	}}))
+
+	assert.Error(t, validateResolver(&ConfigFile{Resolver: &Resolver{Backend: "index"}}))
+	assert.Error(t, validateResolver(&ConfigFile{Resolver: &Resolver{Index: "index.json"}}))
+	assert.Error(t, validateResolver(&ConfigFile{Resolver: &Resolver{Nixpkgs: "github:NixOS/nixpkgs"}}))
+	assert.Error(t, validateResolver(&ConfigFile{Resolver: &Resolver{Backend: "nixpkgs", Nixpkgs: "::"}}))
+}
@@ -0,0 +1,24 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+// SandboxConfig declares what scripts run with `devbox run --sandbox` can
+// access besides the project directory and its packages.
+type SandboxConfig struct {
+	// Network allows network access.
+	Network bool `json:"network,omitempty"`
+	// Paths are extra paths that scripts can read. Relative paths are
+	// relative to the project directory, and a leading ~ is the user's home
+	// directory.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Sandbox returns the project's sandbox declaration, which is empty if it
+// doesn't have one.
+func (c *ConfigFile) Sandbox() SandboxConfig {
+	if c == nil || c.Shell == nil || c.Shell.Sandbox == nil {
+		return SandboxConfig{}
+	}
+	return *c.Shell.Sandbox
+}
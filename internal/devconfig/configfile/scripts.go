@@ -32,6 +32,22 @@ func (c *ConfigFile) Scripts() Scripts {
 	return result
 }
 
+// SetScript adds a script to the config, or replaces the commands of the
+// script if it already exists.
+func (c *ConfigFile) SetScript(name, command string) {
+	if c.Shell == nil {
+		c.Shell = &shellConfig{}
+	}
+	if c.Shell.Scripts == nil {
+		c.Shell.Scripts = map[string]*shellcmd.Commands{}
+	}
+	c.Shell.Scripts[name] = &shellcmd.Commands{
+		MarshalAs: shellcmd.CmdString,
+		Cmds:      []string{command},
+	}
+	c.ast.setScript(name, command)
+}
+
 func (s Scripts) WithRelativePaths(projectDir string) Scripts {
 	result := make(Scripts, len(s))
 	for name, s := range s {
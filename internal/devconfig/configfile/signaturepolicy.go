@@ -0,0 +1,73 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"slices"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// SignaturePolicy requires the store paths that are substituted from binary
+// caches, and the package resolutions of the search service, to be signed by
+// a trusted key.
+type SignaturePolicy struct {
+	// TrustedPublicKeys are the keys that signatures are checked against, in
+	// the format of Nix's trusted-public-keys setting, such as
+	// "cache.nixos.org-1:6NCHdD59X431o0gWypbMrAURkbJ16ZPMQFGspcDShjY=".
+	TrustedPublicKeys []string `json:"trusted_public_keys,omitempty"`
+	// SearchPublicKeys are the keys that the search service's responses
+	// must be signed by, in the same format as TrustedPublicKeys.
+	SearchPublicKeys []string `json:"search_public_keys,omitempty"`
+}
+
+// PublicKeys returns the parsed trusted keys, or nil if the project doesn't
+// require signatures.
+func (c *ConfigFile) PublicKeys() []nix.PublicKey {
+	if c.SignaturePolicy == nil {
+		return nil
+	}
+	var keys []nix.PublicKey
+	for _, s := range c.SignaturePolicy.TrustedPublicKeys {
+		// Keys are checked when the config is loaded.
+		if key, err := nix.ParsePublicKey(s); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// SearchPublicKeys returns the parsed keys that search service responses
+// must be signed by, or nil if the project doesn't require signed
+// responses.
+func (c *ConfigFile) SearchPublicKeys() []nix.PublicKey {
+	if c.SignaturePolicy == nil {
+		return nil
+	}
+	var keys []nix.PublicKey
+	for _, s := range c.SignaturePolicy.SearchPublicKeys {
+		if key, err := nix.ParsePublicKey(s); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func validateSignaturePolicy(cfg *ConfigFile) error {
+	if cfg.SignaturePolicy == nil {
+		return nil
+	}
+	policy := cfg.SignaturePolicy
+	if len(policy.TrustedPublicKeys) == 0 && len(policy.SearchPublicKeys) == 0 {
+		return usererr.New("signature_policy in devbox.json must list at least one key in " +
+			"trusted_public_keys or search_public_keys")
+	}
+	for _, s := range slices.Concat(policy.TrustedPublicKeys, policy.SearchPublicKeys) {
+		if _, err := nix.ParsePublicKey(s); err != nil {
+			return usererr.New("signature_policy in devbox.json: %v", err)
+		}
+	}
+	return nil
+}
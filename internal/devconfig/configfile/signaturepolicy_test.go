@@ -0,0 +1,27 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSignaturePolicy(t *testing.T) {
+	const key = "search.example.com-1:6NCHdD59X431o0gWypbMrAURkbJ16ZPMQFGspcDShjY="
+
+	searchOnly := &ConfigFile{SignaturePolicy: &SignaturePolicy{SearchPublicKeys: []string{key}}}
+	assert.NoError(t, validateSignaturePolicy(searchOnly))
+	if keys := searchOnly.SearchPublicKeys(); assert.Len(t, keys, 1) {
+		assert.Equal(t, "search.example.com-1", keys[0].Name)
+	}
+
+	assert.Error(t, validateSignaturePolicy(&ConfigFile{SignaturePolicy: &SignaturePolicy{}}))
+	assert.Error(t, validateSignaturePolicy(&ConfigFile{SignaturePolicy: &SignaturePolicy{
+		TrustedPublicKeys: []string{key},
+		SearchPublicKeys:  []string{"search.example.com-1"},
+	}}))
+	assert.Nil(t, (&ConfigFile{}).SearchPublicKeys())
+}
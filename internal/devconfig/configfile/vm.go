@@ -0,0 +1,47 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"slices"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// VM configures the Colima virtual machine that `devbox vm` runs containers
+// in.
+type VM struct {
+	// CPUs is the number of CPUs the VM has.
+	CPUs int `json:"cpus,omitempty"`
+	// Memory is the VM's memory in GiB.
+	Memory int `json:"memory,omitempty"`
+	// Disk is the size of the VM's disk in GiB.
+	Disk int `json:"disk,omitempty"`
+	// Mounts are the host directories the VM can access, in addition to the
+	// project directory. Append ":w" to make a mount writable.
+	Mounts []string `json:"mounts,omitempty"`
+	// Runtime is the container runtime, "docker" or "containerd".
+	Runtime string `json:"runtime,omitempty"`
+	// VMType is the virtualization backend, "qemu" or "vz".
+	VMType string `json:"vm_type,omitempty"`
+	// Arch is the VM's architecture, such as "aarch64" or "x86_64".
+	Arch string `json:"arch,omitempty"`
+}
+
+func validateVM(cfg *ConfigFile) error {
+	vm := cfg.VM
+	if vm == nil {
+		return nil
+	}
+	if vm.CPUs < 0 || vm.Memory < 0 || vm.Disk < 0 {
+		return usererr.New("vm.cpus, vm.memory and vm.disk in devbox.json can't be negative")
+	}
+	if vm.Runtime != "" && !slices.Contains([]string{"docker", "containerd"}, vm.Runtime) {
+		return usererr.New("vm.runtime in devbox.json must be \"docker\" or \"containerd\", not %q", vm.Runtime)
+	}
+	if vm.VMType != "" && !slices.Contains([]string{"qemu", "vz"}, vm.VMType) {
+		return usererr.New("vm.vm_type in devbox.json must be \"qemu\" or \"vz\", not %q", vm.VMType)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devconfig
+
+import (
+	"slices"
+)
+
+// PackageTree is a config, the packages that it adds and the configs that it
+// includes. Built-in plugins are included by the config whose package
+// triggered them.
+type PackageTree struct {
+	// Source is "devbox.json", or what included the config, such as
+	// "include:./plugins/redis" or "plugin:php@8.1".
+	Source   string         `json:"source"`
+	Packages []string       `json:"packages,omitempty"`
+	Included []*PackageTree `json:"included,omitempty"`
+}
+
+// PackageTree returns the tree of configs that add packages to the project,
+// starting at devbox.json. Packages are their versioned names, which are the
+// keys of their lock entries.
+func (c *Config) PackageTree() *PackageTree {
+	tree := &PackageTree{Source: c.origin}
+	if tree.Source == "" {
+		tree.Source = "devbox.json"
+	}
+	for _, pkg := range c.Root.TopLevelPackages() {
+		tree.Packages = append(tree.Packages, pkg.VersionedName())
+	}
+	for _, i := range c.included {
+		tree.Included = append(tree.Included, i.PackageTree())
+	}
+	return tree
+}
+
+// PackageOrigins returns what added each package to the project, keyed by
+// the package's versioned name. A package that's in several configs has each
+// of their sources, sorted.
+func (c *Config) PackageOrigins() map[string][]string {
+	origins := map[string][]string{}
+	var walk func(tree *PackageTree)
+	walk = func(tree *PackageTree) {
+		for _, pkg := range tree.Packages {
+			if !slices.Contains(origins[pkg], tree.Source) {
+				origins[pkg] = append(origins[pkg], tree.Source)
+			}
+		}
+		for _, included := range tree.Included {
+			walk(included)
+		}
+	}
+	walk(c.PackageTree())
+	for _, sources := range origins {
+		slices.Sort(sources)
+	}
+	return origins
+}
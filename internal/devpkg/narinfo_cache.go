@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -17,15 +18,14 @@ import (
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
 	"go.jetpack.io/devbox/internal/goutil"
+	"go.jetpack.io/devbox/internal/httpclient"
 	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
 	"golang.org/x/sync/errgroup"
 )
 
-// binaryCache is the store from which to fetch this package's binaries.
-// It is used as FromStore in builtins.fetchClosure.
-const binaryCache = "https://cache.nixos.org"
-
 // useDefaultOutputs is a special value for the outputName parameter of
 // fetchNarInfoStatusOnce, which indicates that the default outputs should be
 // used.
@@ -123,8 +123,9 @@ func (p *Package) areExpectedOutputsInCacheOnce(outputName string) (bool, error)
 }
 
 // fetchNarInfoStatusOnce fetches the cache status for the package and output.
-// It returns a map of outputs to cache URIs for each cache hit. Missing
-// outputs are not returned, and if no outputs are found, an nil map is returned.
+// It returns a map of outputs to cache URIs for each cache hit, or to
+// localStore for outputs that only the local Nix store has. Missing outputs
+// are not returned, and if no outputs are found, an nil map is returned.
 //
 // This function caches the result of the first call to avoid multiple calls
 // even if there are multiple package structs for the same package.
@@ -137,7 +138,7 @@ func (p *Package) fetchNarInfoStatusOnce(
 	ctx := context.TODO()
 
 	outputToCache := map[string]string{}
-	caches, err := readCaches(ctx)
+	caches, err := readCaches(ctx, p.lockfile)
 	if err != nil {
 		return nil, err
 	}
@@ -148,32 +149,87 @@ func (p *Package) fetchNarInfoStatusOnce(
 	}
 
 	for _, output := range outputs {
-		pathParts := nix.NewStorePathParts(output.Path)
-		hash := pathParts.Hash
-		for _, cache := range caches {
-			inCache := false
-			if strings.HasPrefix(cache, "s3") {
-				inCache, err = fetchNarInfoStatusFromS3(ctx, cache, hash)
-				if err != nil {
-					return nil, err
-				}
-			} else {
-				inCache, err = fetchNarInfoStatusFromHTTP(ctx, cache, hash)
-				if err != nil {
-					return nil, err
-				}
-			}
-			if inCache {
-				// Found it, no need to check more caches.
-				outputToCache[output.Name] = cache
-				break
-			}
+		if cache := findOutput(ctx, caches, output.Path); cache != "" {
+			outputToCache[output.Name] = cache
+			continue
 		}
+		warnUnfetchable(p, output.Path)
 	}
 
 	return outputToCache, nil
 }
 
+// localStore is the cache URI of an output that's already in the local Nix
+// store, which doesn't need a binary cache to be installed.
+const localStore = "local"
+
+// findOutput returns the first of caches that has the store path, or
+// localStore if none of them do but the local Nix store does. It returns ""
+// if the path can't be fetched at all, such as when the public cache has
+// garbage-collected an old path. A cache that can't be reached counts as not
+// having the path, so that the package is installed from its flake reference
+// instead of failing.
+func findOutput(ctx context.Context, caches []string, path string) string {
+	hash := nix.NewStorePathParts(path).Hash
+	for _, cache := range caches {
+		var inCache bool
+		var err error
+		if strings.HasPrefix(cache, "s3") {
+			inCache, err = fetchNarInfoStatusFromS3(ctx, cache, hash)
+		} else {
+			inCache, err = fetchNarInfoStatusFromHTTP(ctx, cache, hash)
+		}
+		if err != nil {
+			debug.Log("checking %s for %s: %v", cache, path, err)
+			continue
+		}
+		if inCache {
+			return cache
+		}
+	}
+	if isInLocalStore(ctx, path) {
+		return localStore
+	}
+	return ""
+}
+
+// storePathIsValid reports whether path is in the local Nix store. Tests
+// replace it.
+var storePathIsValid = func(ctx context.Context, path string) (bool, error) {
+	valid, err := nix.StorePathsAreInStore(ctx, []string{path})
+	return valid[path], err
+}
+
+func isInLocalStore(ctx context.Context, path string) bool {
+	fetch, _ := narInfoStatusFnCache.LoadOrStore(localStore+":"+path, sync.OnceValues(
+		func() (bool, error) {
+			return storePathIsValid(ctx, path)
+		},
+	))
+	valid, err := fetch.(func() (bool, error))()
+	if err != nil {
+		// nix path-info fails for paths that aren't in the store.
+		debug.Log("checking the local store for %s: %v", path, err)
+	}
+	return err == nil && valid
+}
+
+var warnedUnfetchable = sync.Map{}
+
+// warnUnfetchable tells the user, once per path, that a store path in
+// devbox.lock can't be fetched, so the package is installed from its flake
+// reference instead.
+func warnUnfetchable(p *Package, path string) {
+	if _, warned := warnedUnfetchable.LoadOrStore(path, true); warned {
+		return
+	}
+	ux.Fwarning(os.Stderr,
+		"devbox.lock has the store path %s for %s, but it isn't in the local Nix store or in any "+
+			"of the binary caches, which may have garbage-collected it. Devbox is installing %[2]s "+
+			"from its flake reference instead, which may need to build it.\n",
+		path, p.Raw)
+}
+
 func (p *Package) AreAllOutputsInCache(
 	ctx context.Context, w io.Writer, cacheURI string,
 ) (bool, error) {
@@ -294,7 +350,8 @@ func fetchNarInfoStatusFromHTTP(
 			if err != nil {
 				return false, err
 			}
-			res, err := http.DefaultClient.Do(req)
+			nix.SetNetrcAuth(req)
+			res, err := httpclient.Client().Do(req)
 			if err != nil {
 				return false, err
 			}
@@ -342,9 +399,12 @@ func fetchNarInfoStatusFromS3(
 
 var nixCacheIsConfigured = goutil.OnceValueWithContext(nixcache.IsConfigured)
 
-func readCaches(ctx context.Context) ([]string, error) {
-	cacheURIs := []string{binaryCache}
-	if !nixCacheIsConfigured.Do(ctx) {
+// readCaches returns the binary caches to check for a package's outputs: the
+// project's and the public Nix cache, followed by the Jetify caches the user
+// has access to.
+func readCaches(ctx context.Context, lockfile lock.Locker) ([]string, error) {
+	cacheURIs := lockfile.BinaryCaches()
+	if !netpolicy.Allowed(netpolicy.Jetify) || !nixCacheIsConfigured.Do(ctx) {
 		return cacheURIs, nil
 	}
 
@@ -0,0 +1,55 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devpkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestFindOutput(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	const (
+		cached = "/nix/store/aissiwd8r0ggjk5zbm9fxf1ncb2xbvb1-python3-3.12.2"
+		local  = "/nix/store/1kig3zjx0dnqkyb8c8a0br5yn9hg8d57-python3-3.12.2"
+		gone   = "/nix/store/0000000000000000000000000000000a-hello-2.12.1"
+	)
+	cache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/aissiwd8r0ggjk5zbm9fxf1ncb2xbvb1") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer cache.Close()
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+
+	isValid := storePathIsValid
+	storePathIsValid = func(_ context.Context, path string) (bool, error) {
+		if path == local {
+			return true, nil
+		}
+		return false, errors.New("path is not valid")
+	}
+	t.Cleanup(func() {
+		storePathIsValid = isValid
+		ClearNarInfoCache()
+	})
+
+	caches := []string{unreachable.URL, cache.URL}
+	if got := findOutput(context.Background(), caches, cached); got != cache.URL {
+		t.Errorf("got %q for a path in the cache, want %q", got, cache.URL)
+	}
+	if got := findOutput(context.Background(), caches, local); got != localStore {
+		t.Errorf("got %q for a path in the local store, want %q", got, localStore)
+	}
+	if got := findOutput(context.Background(), caches, gone); got != "" {
+		t.Errorf("got %q for a garbage-collected path, want none", got)
+	}
+}
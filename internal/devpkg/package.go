@@ -133,6 +133,9 @@ func PackageFromStringWithOptions(raw string, locker lock.Locker, opts devopt.Ad
 }
 
 func newPackage(raw string, isInstallable func() bool, locker lock.Locker) *Package {
+	// A versioned Devbox package can select its outputs with a "#" suffix
+	// ("curl@8.1#dev"), which would otherwise parse as a flake installable.
+	raw, selectedOutputs := configfile.SplitOutputSelector(raw)
 	pkg := &Package{
 		Raw:           raw,
 		lockfile:      locker,
@@ -147,12 +150,11 @@ func newPackage(raw string, isInstallable func() bool, locker lock.Locker) *Pack
 	if err != nil || pkgtype.IsAmbiguous(raw, parsed) {
 		pkg.IsDevboxPackage = true
 		pkg.resolve = sync.OnceValue(func() error { return resolve(pkg) })
+		pkg.outputs = outputs{selectedNames: selectedOutputs}
 		return pkg
 	}
 
-	// We currently don't lock flake references in devbox.lock, so there's
-	// nothing to resolve.
-	pkg.resolve = sync.OnceValue(func() error { return nil })
+	pkg.resolve = sync.OnceValue(func() error { return resolveFlake(pkg) })
 	pkg.setInstallable(parsed, locker.ProjectDir())
 	pkg.outputs = outputs{selectedNames: strings.Split(parsed.Outputs, ",")}
 	return pkg
@@ -165,7 +167,7 @@ func resolve(pkg *Package) error {
 	if err != nil {
 		return err
 	}
-	parsed, err := flake.ParseInstallable(resolved.Resolved)
+	parsed, err := flake.ParseInstallable(resolved.ResolvedFor(nix.System()))
 	if err != nil {
 		return err
 	}
@@ -177,6 +179,28 @@ func resolve(pkg *Package) error {
 	return nil
 }
 
+// resolveFlake replaces the installable of a flake with the one that
+// devbox.lock pins it to. Local path flakes aren't pinned, and neither are
+// flakes that couldn't be locked while offline.
+func resolveFlake(pkg *Package) error {
+	if !lock.IsLockableFlake(pkg.Raw) {
+		return nil
+	}
+	locked, err := pkg.lockfile.Resolve(pkg.LockfileKey())
+	if err != nil {
+		return err
+	}
+	if locked.Resolved == "" {
+		return nil
+	}
+	parsed, err := flake.ParseInstallable(locked.Resolved)
+	if err != nil {
+		return err
+	}
+	pkg.setInstallable(parsed, pkg.lockfile.ProjectDir())
+	return nil
+}
+
 func (p *Package) setInstallable(i flake.Installable, projectDir string) {
 	if i.Ref.Type == flake.TypePath && !filepath.IsAbs(i.Ref.Path) {
 		i.Ref.Path = filepath.Join(projectDir, i.Ref.Path)
@@ -479,7 +503,7 @@ func (p *Package) CanonicalName() string {
 	if !p.IsDevboxPackage {
 		return ""
 	}
-	name, _, _ := strings.Cut(p.Raw, "@")
+	name, _, _ := p.cutVersion()
 	return name
 }
 
@@ -538,12 +562,21 @@ func (p *Package) version() string {
 	if !p.IsDevboxPackage {
 		return ""
 	}
-	_, version, _ := strings.Cut(p.Raw, "@")
+	_, version, _ := p.cutVersion()
 	return version
 }
 
 func (p *Package) isVersioned() bool {
-	return p.IsDevboxPackage && strings.Contains(p.Raw, "@")
+	_, _, versioned := p.cutVersion()
+	return p.IsDevboxPackage && versioned
+}
+
+// cutVersion splits the raw string at the "@" that starts its version. The
+// "@" of a scoped npm package ("npm:@angular/cli@17") is part of the name.
+func (p *Package) cutVersion() (name, version string, versioned bool) {
+	scope := pkgtype.EcosystemScope(p.Raw)
+	name, version, versioned = strings.Cut(strings.TrimPrefix(p.Raw, scope), "@")
+	return scope + name, version, versioned
 }
 
 func (p *Package) HashFromNixPkgsURL() string {
@@ -601,6 +634,31 @@ func (p *Package) InputAddressedPathForOutput(output string) (string, error) {
 	return "", errors.Errorf("Output %q not found for package %q", output, p.Raw)
 }
 
+// IsContentAddressedOutput reports whether devbox.lock records the store path
+// of output as content-addressed. Fetching a content-addressed path checks it
+// against its contents, so it isn't fetched as an input-addressed one.
+func (p *Package) IsContentAddressedOutput(output string) bool {
+	entry := p.lockfile.Get(p.LockfileKey())
+	if entry == nil {
+		return false
+	}
+	out, err := entry.Systems[nix.System()].Output(output)
+	return err == nil && out.CA != ""
+}
+
+// BuildsContentAddressed reports whether a project with ca_derivations builds
+// p as a content-addressed derivation. That's the case for packages from
+// nixpkgs that aren't in a binary cache: the packages that are keep their
+// input-addressed store paths, since the caches don't have content-addressed
+// builds of them.
+func (p *Package) BuildsContentAddressed() (bool, error) {
+	if !p.IsNix() || p.PatchGlibc() || !nix.IsGithubNixpkgsURL(p.URLForFlakeInput()) {
+		return false, nil
+	}
+	inCache, err := p.IsInBinaryCache()
+	return !inCache, err
+}
+
 func (p *Package) HasAllowInsecure() bool {
 	return len(p.AllowInsecure) > 0
 }
@@ -670,12 +728,25 @@ func (p *Package) DocsURL() string {
 		path, _, _ := strings.Cut(p.RunXPath(), "@")
 		return fmt.Sprintf("https://www.github.com/%s", path)
 	}
+	if attrPath, ok := pkgtype.EcosystemAttrPath(p.CanonicalName()); ok {
+		return fmt.Sprintf("https://www.nixhub.io/packages/%s", attrPath)
+	}
 	if p.IsDevboxPackage {
 		return fmt.Sprintf("https://www.nixhub.io/packages/%s", p.CanonicalName())
 	}
 	return ""
 }
 
+// SelectedOutputs returns the outputs selected in devbox.json, with
+// --outputs or with a "#" suffix on a versioned package. It's empty when the
+// package uses its default outputs.
+func (p *Package) SelectedOutputs() []string {
+	if !p.IsDevboxPackage {
+		return nil
+	}
+	return p.outputs.selectedNames
+}
+
 // GetOutputNames returns the names of the nix package outputs. Outputs can be
 // specified in devbox.json package fields or as part of the flake reference.
 func (p *Package) GetOutputNames() ([]string, error) {
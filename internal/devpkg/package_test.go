@@ -6,6 +6,7 @@ package devpkg
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -108,6 +109,10 @@ func (l *lockfile) ProjectDir() string {
 	return l.projectDir
 }
 
+func (l *lockfile) BinaryCaches() []string {
+	return nil
+}
+
 func (l *lockfile) LegacyNixpkgsPath(pkg string) string {
 	return fmt.Sprintf(
 		"github:NixOS/nixpkgs/%s#%s",
@@ -189,9 +194,13 @@ func TestCanonicalName(t *testing.T) {
 		{"go", "go"},
 		{"go@latest", "go"},
 		{"go@1.21", "go"},
+		{"nodejs@aarch64-darwin=20,x86_64-linux=18", "nodejs"},
 		{"runx:golangci/golangci-lint@latest", "runx:golangci/golangci-lint"},
 		{"runx:golangci/golangci-lint@v0.0.2", "runx:golangci/golangci-lint"},
 		{"runx:golangci/golangci-lint", "runx:golangci/golangci-lint"},
+		{"npm:prettier@3", "npm:prettier"},
+		{"npm:@angular/cli@17", "npm:@angular/cli"},
+		{"npm:@angular/cli", "npm:@angular/cli"},
 		{"github:NixOS/nixpkgs/12345", ""},
 		{"path:/to/my/file", ""},
 	}
@@ -206,3 +215,33 @@ func TestCanonicalName(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputSelector(t *testing.T) {
+	tests := []struct {
+		pkgName         string
+		expectedRaw     string
+		expectedOutputs []string
+		isDevboxPackage bool
+	}{
+		{"curl@8.1#dev", "curl@8.1", []string{"dev"}, true},
+		{"curl@latest#out,dev,lib", "curl@latest", []string{"out", "dev", "lib"}, true},
+		{"curl@8.1", "curl@8.1", nil, true},
+		{"nixpkgs#hello", "nixpkgs#hello", nil, false},
+		{"github:NixOS/nixpkgs@abc#hello", "github:NixOS/nixpkgs@abc#hello", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkgName, func(t *testing.T) {
+			pkg := PackageFromStringWithDefaults(tt.pkgName, &lockfile{})
+			if pkg.Raw != tt.expectedRaw {
+				t.Errorf("Expected raw %q, but got %q", tt.expectedRaw, pkg.Raw)
+			}
+			if pkg.IsDevboxPackage != tt.isDevboxPackage {
+				t.Errorf("Expected IsDevboxPackage %v, but got %v", tt.isDevboxPackage, pkg.IsDevboxPackage)
+			}
+			if got := pkg.SelectedOutputs(); !slices.Equal(got, tt.expectedOutputs) {
+				t.Errorf("Expected outputs %v, but got %v", tt.expectedOutputs, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package pkgtype
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ecosystemAttrSets maps the prefix of a language ecosystem package, such as
+// "npm:prettier@3", to the nixpkgs attribute set that builds the packages of
+// that ecosystem.
+var ecosystemAttrSets = map[string]string{
+	"npm:": "nodePackages",
+	"pip:": "python3Packages",
+	"gem:": "rubyPackages",
+}
+
+// IsEcosystem reports whether s is a package from a language ecosystem, such
+// as "npm:prettier@3", "pip:black@24" or "gem:rubocop".
+func IsEcosystem(s string) bool {
+	_, ok := ecosystemPrefix(s)
+	return ok
+}
+
+func ecosystemPrefix(s string) (string, bool) {
+	prefix, _, found := strings.Cut(s, ":")
+	if !found {
+		return "", false
+	}
+	prefix += ":"
+	_, ok := ecosystemAttrSets[prefix]
+	return prefix, ok
+}
+
+// EcosystemScope returns the part of an ecosystem package that comes before
+// its version can start: the prefix, and the "@" of a scoped npm package
+// such as "npm:@angular/cli@17". It returns "" if s isn't an ecosystem
+// package.
+func EcosystemScope(s string) string {
+	prefix, ok := ecosystemPrefix(s)
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(s[len(prefix):], "@") {
+		return prefix + "@"
+	}
+	return prefix
+}
+
+// safeAttrName matches the attribute names that don't need to be quoted in a
+// nixpkgs attribute path.
+var safeAttrName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_'-]*$`)
+
+// pipSeparators are the runs of characters that PyPI treats as the same
+// separator in a name, so that "Flask_SQLAlchemy" is "flask-sqlalchemy".
+var pipSeparators = regexp.MustCompile(`[-_.]+`)
+
+// EcosystemAttrPath returns the nixpkgs attribute path of the unversioned
+// ecosystem package name, for example "nodePackages.prettier" for
+// "npm:prettier" and `nodePackages."@angular/cli"` for "npm:@angular/cli".
+// Python package names are normalized the way PyPI normalizes them, which is
+// how nixpkgs names them. ok is false if name isn't an ecosystem package or
+// has no name after the prefix.
+func EcosystemAttrPath(name string) (path string, ok bool) {
+	prefix, ok := ecosystemPrefix(name)
+	if !ok {
+		return "", false
+	}
+	attr := name[len(prefix):]
+	if attr == "" {
+		return "", false
+	}
+	if prefix == "pip:" {
+		attr = pipSeparators.ReplaceAllString(strings.ToLower(attr), "-")
+	}
+	if !safeAttrName.MatchString(attr) {
+		attr = strconv.Quote(attr)
+	}
+	return ecosystemAttrSets[prefix] + "." + attr, true
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package pkgtype
+
+import "testing"
+
+func TestEcosystemAttrPath(t *testing.T) {
+	cases := map[string]string{
+		"npm:prettier":         "nodePackages.prettier",
+		"npm:@angular/cli":     `nodePackages."@angular/cli"`,
+		"pip:black":            "python3Packages.black",
+		"pip:Flask_SQLAlchemy": "python3Packages.flask-sqlalchemy",
+		"pip:zope.interface":   "python3Packages.zope-interface",
+		"gem:rubocop":          "rubyPackages.rubocop",
+		"npm:":                 "",
+		"cargo:ripgrep":        "",
+		"prettier":             "",
+	}
+	for name, want := range cases {
+		got, ok := EcosystemAttrPath(name)
+		if got != want || ok != (want != "") {
+			t.Errorf("EcosystemAttrPath(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+}
+
+func TestEcosystemScope(t *testing.T) {
+	cases := map[string]string{
+		"npm:prettier@3":      "npm:",
+		"npm:@angular/cli@17": "npm:@",
+		"gem:rubocop":         "gem:",
+		"runx:golangci/lint":  "",
+		"hello@1":             "",
+	}
+	for s, want := range cases {
+		if got := EcosystemScope(s); got != want {
+			t.Errorf("EcosystemScope(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -4,23 +4,131 @@
 package envir
 
 const (
-	DevboxCache         = "DEVBOX_CACHE"
+	// DevboxAttestationKey holds the PEM encoded key that devbox attest signs
+	// attestations with, for CI systems that provide it as a secret.
+	DevboxAttestationKey = "DEVBOX_ATTESTATION_KEY"
+	// DevboxAutoRefresh makes devbox shell update its environment before the
+	// next prompt when devbox.json or devbox.lock changes, instead of asking
+	// to run refresh.
+	DevboxAutoRefresh = "DEVBOX_AUTO_REFRESH"
+	DevboxCache       = "DEVBOX_CACHE"
+	// DevboxCredentialStore selects where devbox stores credentials and
+	// tokens: "keychain" or "file". By default the OS keychain is used when
+	// it's available.
+	DevboxCredentialStore = "DEVBOX_CREDENTIAL_STORE"
+	// DevboxEnvHash is set in a project's environment to a hash of its
+	// devbox.json and devbox.lock, so that devbox prompt can tell when the
+	// environment is out of date.
+	DevboxEnvHash       = "DEVBOX_ENV_HASH"
 	DevboxFeaturePrefix = "DEVBOX_FEATURE_"
 	DevboxGateway       = "DEVBOX_GATEWAY"
+	// DevboxInstallConcurrency is the maximum number of packages devbox
+	// install realizes in the Nix store at the same time. 1 installs them
+	// with a single nix build, the way older versions did.
+	DevboxInstallConcurrency = "DEVBOX_INSTALL_CONCURRENCY"
 	// DevboxLatestVersion is the latest version available of the devbox CLI binary.
 	// NOTE: it should NOT start with v (like 0.4.8)
-	DevboxLatestVersion  = "DEVBOX_LATEST_VERSION"
-	DevboxRegion         = "DEVBOX_REGION"
-	DevboxSearchHost     = "DEVBOX_SEARCH_HOST"
-	DevboxShellEnabled   = "DEVBOX_SHELL_ENABLED"
+	DevboxLatestVersion = "DEVBOX_LATEST_VERSION"
+	// DevboxLicensePolicy is the path to an organization-wide license policy
+	// that applies to every project, in addition to the project's own.
+	DevboxLicensePolicy = "DEVBOX_LICENSE_POLICY"
+	// DevboxNetworkPolicy is the path to a policy that disables or redirects
+	// the network endpoints devbox contacts.
+	DevboxNetworkPolicy = "DEVBOX_NETWORK_POLICY"
+	// DevboxNixStore is the root of a user-owned Nix store, such as
+	// ~/.devbox/nix, that devbox uses instead of the Nix daemon's store, for
+	// machines that can't run the daemon.
+	DevboxNixStore = "DEVBOX_NIX_STORE"
+	// DevboxOffline makes devbox resolve packages only from devbox.lock and
+	// refuse to contact the network. devbox --offline sets it.
+	DevboxOffline = "DEVBOX_OFFLINE"
+	// DevboxPackageIndex is the path to a JSON file of /v2/resolve responses
+	// that devbox resolves packages from instead of the search service.
+	DevboxPackageIndex = "DEVBOX_PACKAGE_INDEX"
+	// DevboxProjectName is set in a project's environment to the name in
+	// devbox.json, or the name of the project's directory.
+	DevboxProjectName = "DEVBOX_PROJECT_NAME"
+	// DevboxPrompt set to "status" makes devbox shell show the output of
+	// devbox prompt in the prompt, instead of "(devbox)".
+	DevboxPrompt = "DEVBOX_PROMPT"
+	DevboxRegion = "DEVBOX_REGION"
+	// DevboxResolveCacheTTL is how long devbox caches the search service's
+	// package resolutions on disk, as a Go duration. 0 disables the cache.
+	DevboxResolveCacheTTL = "DEVBOX_RESOLVE_CACHE_TTL"
+	// DevboxResolveConcurrency is the maximum number of packages devbox
+	// update resolves at the same time.
+	DevboxResolveConcurrency = "DEVBOX_RESOLVE_CONCURRENCY"
+	// DevboxRunXMirror is the URL of a proxy that serves the GitHub hosts
+	// that runx: packages are downloaded from, each under a path named after
+	// the host. It takes precedence over the network policy's runx redirect.
+	DevboxRunXMirror = "DEVBOX_RUNX_MIRROR"
+	// DevboxSearchBackoff is how long devbox waits before retrying a failed
+	// request to the search service, as a Go duration like "500ms". The wait
+	// doubles with each retry.
+	DevboxSearchBackoff = "DEVBOX_SEARCH_BACKOFF"
+	// DevboxSearchCacheTTL is how long devbox reuses a response from the
+	// search service before revalidating it, as a Go duration. 0 turns the
+	// response cache off.
+	DevboxSearchCacheTTL = "DEVBOX_SEARCH_CACHE_TTL"
+	// DevboxSearchAuth selects how devbox authenticates to a private search
+	// service that doesn't accept a static token: "jetify" sends the access
+	// token of the devbox auth login session.
+	DevboxSearchAuth = "DEVBOX_SEARCH_AUTH"
+	DevboxSearchHost = "DEVBOX_SEARCH_HOST"
+	// DevboxSearchRetries is how many times devbox retries a request to the
+	// search service that timed out or failed with a server error.
+	DevboxSearchRetries = "DEVBOX_SEARCH_RETRIES"
+	// DevboxSearchTimeout limits each request to the search service, as a Go
+	// duration like "15s". 0 turns the limit off.
+	DevboxSearchTimeout = "DEVBOX_SEARCH_TIMEOUT"
+	// DevboxSearchToken is a bearer token that devbox sends to the search
+	// service, for private services that host their own packages.
+	DevboxSearchToken  = "DEVBOX_SEARCH_TOKEN"
+	DevboxShellEnabled = "DEVBOX_SHELL_ENABLED"
+	// DevboxShellStack is the project directories of the nested devbox
+	// shells that devbox shell --stack started, outermost first, as a PATH
+	// style list.
+	DevboxShellStack     = "DEVBOX_SHELL_STACK"
 	DevboxShellStartTime = "DEVBOX_SHELL_START_TIME"
-	DevboxVM             = "DEVBOX_VM"
+	// DevboxSourcePolicy is the path to an organization-wide policy that
+	// restricts where packages and binary caches can come from.
+	DevboxSourcePolicy = "DEVBOX_SOURCE_POLICY"
+	// DevboxShowSecrets turns off masking of secret env values in the output
+	// of devbox run.
+	DevboxShowSecrets = "DEVBOX_SHOW_SECRETS"
+	// DevboxStorePathConcurrency is the maximum number of store paths devbox
+	// looks up in binary caches at the same time, across all packages.
+	DevboxStorePathConcurrency = "DEVBOX_STORE_PATH_CONCURRENCY"
+	// DevboxStorePathTimeout limits each store path lookup in a binary
+	// cache, as a Go duration like "30s". 0 turns the limit off.
+	DevboxStorePathTimeout = "DEVBOX_STORE_PATH_TIMEOUT"
+	// DevboxTrustAll skips asking for approval before running the hooks and
+	// scripts of projects, for automation that only runs projects it owns.
+	DevboxTrustAll = "DEVBOX_TRUST_ALL"
+	// DevboxVaultAuthMethod selects how devbox logs in to HashiCorp Vault to
+	// read vault:// env values. One of "token", "approle" or "oidc".
+	DevboxVaultAuthMethod = "DEVBOX_VAULT_AUTH_METHOD"
+	DevboxVM              = "DEVBOX_VM"
+	// DevboxWSLDistro is the WSL distro that the Windows binary runs devbox
+	// in. It defaults to Ubuntu.
+	DevboxWSLDistro = "DEVBOX_WSL_DISTRO"
 
 	LauncherVersion = "LAUNCHER_VERSION"
 	LauncherPath    = "LAUNCHER_PATH"
 
+	// Codespaces is set to "true" in GitHub Codespaces, and
+	// CodespaceVSCodeFolder is the repository's directory in the codespace.
+	Codespaces            = "CODESPACES"
+	CodespaceVSCodeFolder = "CODESPACE_VSCODE_FOLDER"
+	// RemoteContainers is set to "true" by VS Code Dev Containers and the
+	// devcontainer CLI.
+	RemoteContainers = "REMOTE_CONTAINERS"
+
 	GitHubUsername = "GITHUB_USER_NAME"
-	SSHTTY         = "SSH_TTY"
+	// NVDAPIKey raises the rate limit for queries to the National
+	// Vulnerability Database made by devbox audit.
+	NVDAPIKey = "NVD_API_KEY"
+	SSHTTY    = "SSH_TTY"
 
 	XDGDataHome   = "XDG_DATA_HOME"
 	XDGConfigHome = "XDG_CONFIG_HOME"
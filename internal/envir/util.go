@@ -30,6 +30,39 @@ func IsInBrowser() bool { // TODO: a better name
 	return inBrowser
 }
 
+// ShowSecrets returns true if the user turned off masking of secret values.
+func ShowSecrets() bool {
+	show, _ := strconv.ParseBool(os.Getenv(DevboxShowSecrets))
+	return show
+}
+
+// TrustAll returns true if the user turned off approval of project hooks and
+// scripts.
+func TrustAll() bool {
+	trust, _ := strconv.ParseBool(os.Getenv(DevboxTrustAll))
+	return trust
+}
+
+// IsOffline returns true if the user asked devbox not to contact the network,
+// with DEVBOX_OFFLINE or --offline.
+func IsOffline() bool {
+	offline, _ := strconv.ParseBool(os.Getenv(DevboxOffline))
+	return offline
+}
+
+// IsCodespaces returns true when running in a GitHub codespace.
+func IsCodespaces() bool {
+	codespaces, _ := strconv.ParseBool(os.Getenv(Codespaces))
+	return codespaces
+}
+
+// IsDevcontainer returns true when running in a dev container, including
+// GitHub Codespaces.
+func IsDevcontainer() bool {
+	remote, _ := strconv.ParseBool(os.Getenv(RemoteContainers))
+	return remote || IsCodespaces()
+}
+
 func IsCI() bool {
 	ci, err := strconv.ParseBool(os.Getenv("CI"))
 	return ci && err == nil
@@ -0,0 +1,89 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package httpclient provides the HTTP client shared by everything in devbox
+// that talks to the network (search, binary caches, plugins, RunX). Sharing a
+// single client lets requests to the same host reuse keep-alive connections,
+// which matters for commands that issue many small requests such as checking
+// narinfos for every package in a project.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/netpolicy"
+)
+
+const (
+	// maxConnsPerHost bounds how many connections we open to a single host so
+	// that fanning out (e.g. one request per package) doesn't overwhelm it.
+	maxConnsPerHost     = 32
+	maxIdleConnsPerHost = 16
+	maxIdleConns        = 100
+)
+
+var (
+	transport = &http.Transport{
+		// Honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	// client has no overall timeout. Callers are expected to bound requests
+	// with a context, since appropriate timeouts vary a lot between a HEAD
+	// request for a narinfo and downloading an archive.
+	client = &http.Client{Transport: policyTransport{transport}}
+
+	setDefaultOnce sync.Once
+)
+
+// Client returns the shared HTTP client.
+func Client() *http.Client {
+	return client
+}
+
+// SetDefault makes the shared transport the one used by http.DefaultClient.
+// Third-party libraries we depend on (such as RunX) use the default client, so
+// this is how they end up sharing our connection pool.
+func SetDefault() {
+	setDefaultOnce.Do(func() {
+		http.DefaultTransport = policyTransport{transport}
+		http.DefaultClient.Transport = policyTransport{transport}
+	})
+}
+
+// policyTransport refuses requests to endpoints that the network policy
+// disables, and sends requests for mirrored hosts to their mirror.
+type policyTransport struct {
+	base http.RoundTripper
+}
+
+func (t policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := netpolicy.CheckHost(req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	if mirrored, ok := netpolicy.MirrorURL(req.URL); ok {
+		debug.Log("httpclient: fetching %s from mirror %s", req.URL.Redacted(), mirrored.Redacted())
+		req = req.Clone(req.Context())
+		req.URL = mirrored
+		req.Host = mirrored.Host
+	}
+	return t.base.RoundTrip(req)
+}
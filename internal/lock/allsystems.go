@@ -0,0 +1,154 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// LockAllSystems locks pkg and adds store paths to its lock entry for every
+// system that the search service has the package for, not only the ones
+// that were locked when it was resolved. That way a project locked on macOS
+// can install on Linux without resolving anything.
+//
+// The entry keeps its version and installable. A system is only added if
+// the search service still resolves the locked version to the same
+// installable, so that its store paths are for the same build. The returned
+// systems are the ones that the search service knows about but that
+// couldn't be locked, sorted.
+func (f *File) LockAllSystems(ctx context.Context, pkg string) ([]string, error) {
+	entry, err := f.ResolveContext(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsFromSearch() || pkgtype.IsRunX(pkg) {
+		// Flakes, pinned packages and RunX packages don't have store
+		// paths in devbox.lock.
+		return nil, nil
+	}
+
+	name, version, _ := searcher.ParseVersionedPackage(pkg)
+	systemVersions, hasSystemVersions := searcher.ParseSystemVersions(version)
+	_, channel, _ := searcher.ParseChannel(version)
+
+	// Systems that are locked to the same version share a resolution.
+	type resolution struct {
+		pkg     *Package
+		systems []string
+	}
+	resolutions := map[string]*resolution{}
+	fetch := func(version string) (*resolution, error) {
+		if r, ok := resolutions[version]; ok {
+			return r, nil
+		}
+		fetched, systems, err := f.fetchAllSystems(ctx, name, searcher.WithChannel(version, channel))
+		if err != nil {
+			return nil, err
+		}
+		resolutions[version] = &resolution{fetched, systems}
+		return resolutions[version], nil
+	}
+
+	// A package with a version for each system only needs those systems.
+	systems := lo.Keys(systemVersions)
+	if !hasSystemVersions {
+		r, err := fetch(entry.Version)
+		if err != nil {
+			return nil, err
+		}
+		systems = r.systems
+	}
+	slices.Sort(systems)
+
+	var missing []string
+	for _, sys := range systems {
+		if info := entry.Systems[sys]; info != nil && len(info.Outputs) > 0 {
+			continue
+		}
+		r, err := fetch(entry.VersionFor(sys))
+		if err != nil {
+			return nil, err
+		}
+		info := r.pkg.Systems[sys]
+		if info == nil || r.pkg.Resolved != entry.ResolvedFor(sys) {
+			missing = append(missing, sys)
+			continue
+		}
+		locked := &SystemInfo{Outputs: info.Outputs, StorePath: info.StorePath}
+		if hasSystemVersions {
+			locked.Resolved = entry.ResolvedFor(sys)
+			locked.Version = entry.VersionFor(sys)
+		}
+		if entry.Systems == nil {
+			entry.Systems = map[string]*SystemInfo{}
+		}
+		entry.Systems[sys] = locked
+	}
+	return missing, nil
+}
+
+// fetchAllSystems asks the search service to resolve name@version, without
+// the resolution cache, and returns the lock entry along with every system
+// that the service has the package for, including the ones without store
+// paths.
+func (f *File) fetchAllSystems(ctx context.Context, name, version string) (*Package, []string, error) {
+	resolver, err := f.resolver()
+	if err != nil {
+		return nil, nil, err
+	}
+	if r, ok := resolver.(*indexResolver); ok {
+		index, err := searcher.LoadPackageIndex(r.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved, err := r.resolve(index, name, version)
+		if errors.Is(err, searcher.ErrNotFound) {
+			return nil, nil, redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return packageFromV2(resolved), lo.Keys(resolved.Systems), nil
+	}
+	if envir.IsOffline() {
+		return nil, nil, usererr.New(
+			"Devbox can't lock %s@%s for every system because it's offline.", name, version)
+	}
+
+	if err := f.checkRegistry(); err != nil {
+		return nil, nil, err
+	}
+	client := searcher.Client().WithPublicKeys(f.searchPublicKeys())
+	if featureflag.ResolveV2.Enabled() {
+		resolved, err := client.ResolveV2(ctx, name, version)
+		if errors.Is(err, searcher.ErrNotFound) {
+			return nil, nil, redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return packageFromV2(resolved), lo.Keys(resolved.Systems), nil
+	}
+
+	packageVersion, err := client.ResolveContext(ctx, name, version)
+	if err != nil {
+		return nil, nil, errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
+	}
+	pkg, err := f.packageFromV1(ctx, name, packageVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, lo.Keys(packageVersion.Systems), nil
+}
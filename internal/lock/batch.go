@@ -0,0 +1,141 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// prefetchedResolutions are the resolutions that PrefetchResolutions got from
+// the search service, keyed by name@version, until the search resolver uses
+// them.
+type prefetchedResolutions struct {
+	mu       sync.Mutex
+	resolved map[string]*searcher.ResolveResponse
+}
+
+// take returns and forgets the prefetched resolution of name@version, so that
+// a later resolution of the package asks the search service again.
+func (p *prefetchedResolutions) take(name, version string) *searcher.ResolveResponse {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := name + "@" + version
+	resolved := p.resolved[key]
+	delete(p.resolved, key)
+	return resolved
+}
+
+// PrefetchResolutions resolves the packages that the search service would
+// resolve with as few requests as possible, so that resolving many packages
+// isn't dominated by a round trip to the service for each one. Resolving the
+// packages afterwards uses the prefetched resolutions instead of requests.
+//
+// Prefetching is only an optimization: packages that aren't prefetched,
+// because the service doesn't support batches or the batch failed, are
+// resolved one at a time like before.
+func (f *File) PrefetchResolutions(ctx context.Context, pkgs []string) {
+	requests := f.batchableRequests(pkgs)
+	if len(requests) < 2 {
+		return
+	}
+	defer debug.FunctionTimer().End()
+	if err := f.checkRegistry(); err != nil {
+		// The packages resolve one at a time instead, which reports the
+		// error.
+		debug.Log("not prefetching resolutions: %v", err)
+		return
+	}
+
+	if f.prefetched == nil {
+		f.prefetched = &prefetchedResolutions{resolved: map[string]*searcher.ResolveResponse{}}
+	}
+	client := searcher.Client().WithPublicKeys(f.searchPublicKeys())
+	for _, batch := range lo.Chunk(requests, searcher.MaxBatchSize) {
+		resolved, err := client.ResolveBatch(ctx, batch)
+		if errors.Is(err, searcher.ErrBatchUnsupported) {
+			debug.Log("resolving packages one at a time: %v", err)
+			return
+		}
+		if err != nil {
+			debug.Log("batch resolve of %d packages: %v", len(batch), err)
+			return
+		}
+
+		f.prefetched.mu.Lock()
+		for i, req := range batch {
+			// Packages that weren't found are left to the search
+			// resolver, which explains what's missing.
+			if resolved[i] == nil || len(resolved[i].Systems) == 0 {
+				continue
+			}
+			// The resolutions are in the order of the request, which
+			// a broken or malicious service could get wrong, so a
+			// resolution that isn't of the requested package is
+			// resolved again on its own.
+			if !resolvesRequest(resolved[i], req) {
+				debug.Log("batch resolve: got %s@%s for %s@%s",
+					resolved[i].Name, resolved[i].Version, req.Name, req.Version)
+				continue
+			}
+			f.prefetched.resolved[req.Name+"@"+req.Version] = resolved[i]
+		}
+		f.prefetched.mu.Unlock()
+	}
+}
+
+// resolvesRequest reports whether resolved is a resolution of the package
+// version that req asks for.
+func resolvesRequest(resolved *searcher.ResolveResponse, req searcher.ResolveRequest) bool {
+	version, _, err := searcher.ParseChannel(req.Version)
+	if err != nil || searcher.CheckChannel(req.Version, resolved.Channel) != nil {
+		return false
+	}
+	return resolved.Name == req.Name && versionMatches(resolved.Version, version)
+}
+
+// batchableRequests returns the packages in pkgs that FetchResolvedPackage
+// would resolve with a single /v2/resolve request that isn't cached.
+func (f *File) batchableRequests(pkgs []string) []searcher.ResolveRequest {
+	if !featureflag.ResolveV2.Enabled() || envir.IsOffline() {
+		return nil
+	}
+	resolver, err := f.resolver()
+	if err != nil {
+		return nil
+	}
+	if _, isSearch := resolver.(*searchResolver); !isSearch {
+		return nil
+	}
+
+	var requests []searcher.ResolveRequest
+	for _, pkg := range lo.Uniq(pkgs) {
+		if pkgtype.IsFlake(pkg) || pkgtype.IsRunX(pkg) || f.pinnedCommit(pkg) != "" {
+			continue
+		}
+		name, version, _ := searcher.ParseVersionedPackage(pkg)
+		if version == "" || searcher.IsVersionConstraint(version) {
+			continue
+		}
+		if _, ok := searcher.ParseSystemVersions(version); ok {
+			continue
+		}
+		if f.isResolutionCached(name, version) {
+			continue
+		}
+		requests = append(requests, searcher.ResolveRequest{Name: name, Version: version})
+	}
+	return requests
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/cuecfg"
+)
+
+// Lockfiles in large projects can be several megabytes, so they are streamed
+// to and from disk instead of being held in memory as a single byte slice.
+// The buffered readers and writers are pooled because a single command may
+// read the lockfile several times (e.g. to check whether it's dirty).
+var (
+	readerPool = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, 64*1024) }}
+	writerPool = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, 64*1024) }}
+)
+
+// readLockFile decodes the lockfile at path into f. Like cuecfg.ParseFile, it
+// returns an error wrapping fs.ErrNotExist if the file doesn't exist.
+func readLockFile(path string, f *File) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	r := readerPool.Get().(*bufio.Reader)
+	r.Reset(file)
+	defer func() {
+		r.Reset(nil)
+		readerPool.Put(r)
+	}()
+
+	return errors.WithStack(json.NewDecoder(r).Decode(f))
+}
+
+// ReadFile reads the lockfile at path and upgrades it to lockFileVersion in
+// memory. Unlike GetFile, it doesn't need a project, so the lockfile can be
+// inspected and written back with WriteFile, but not resolved or saved.
+func ReadFile(path string) (*File, error) {
+	f := &File{Packages: map[string]*Package{}}
+	if err := readLockFile(path, f); err != nil {
+		return nil, err
+	}
+	if err := f.migrate(); err != nil {
+		return nil, err
+	}
+	ensurePackagesHaveOutputs(f.Packages)
+	return f, nil
+}
+
+// WriteFile writes f to path in the same form as Save, without keeping a
+// snapshot in the lock history.
+func WriteFile(path string, f *File) error {
+	if f.LockFileVersion == "" {
+		f.LockFileVersion = lockFileVersion
+	}
+	_, err := writeLockFile(path, f)
+	return err
+}
+
+// formatLastModified formats a package's last_modified time. It's always in
+// UTC, so that the lockfile doesn't depend on the time zone of the machine or
+// server that resolved the package, and so that the times sort as strings.
+func formatLastModified(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// normalize puts f in the one form that it's written in, so that two files
+// with the same contents encode to the same bytes. encoding/json already
+// sorts map keys. Slices keep their order, because the order of a package's
+// outputs and licenses comes from nixpkgs and means something.
+func (f *File) normalize() {
+	if f.Packages == nil {
+		f.Packages = map[string]*Package{}
+	}
+	for key, pkg := range f.Packages {
+		if pkg == nil {
+			delete(f.Packages, key)
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, pkg.LastModified); err == nil {
+			pkg.LastModified = formatLastModified(t)
+		}
+		slices.Sort(pkg.SourceOf)
+		for sys, info := range pkg.Systems {
+			if info == nil {
+				delete(pkg.Systems, sys)
+			}
+		}
+	}
+}
+
+// writeLockFile encodes f to path using the same formatting as
+// cuecfg.WriteFile. The file is written to a temporary file first and then
+// renamed so that a failed write never leaves a truncated lockfile behind.
+// If path already has the same contents, it's left untouched so that its
+// modification time doesn't change, and writeLockFile returns false.
+func writeLockFile(path string, f *File) (bool, error) {
+	f.normalize()
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".devbox.lock.*")
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := writerPool.Get().(*bufio.Writer)
+	w.Reset(tmp)
+	defer func() {
+		w.Reset(nil)
+		writerPool.Put(w)
+	}()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", cuecfg.Indent)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(f); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := w.Flush(); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if same, err := sameContents(tmp.Name(), path); err != nil || same {
+		return false, err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// sameContents reports whether the files at a and b have the same bytes. A
+// file that doesn't exist isn't the same as any other.
+func sameContents(a, b string) (bool, error) {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errors.Is(errA, fs.ErrNotExist) || errors.Is(errB, fs.ErrNotExist) {
+		return false, nil
+	}
+	if errA != nil || errB != nil {
+		return false, errors.WithStack(cmp.Or(errA, errB))
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// flakeSource is a flake installable from devbox.json that is locked to a
+// revision and source hash.
+const flakeSource = "flake"
+
+// lockFlakeRef is nix.LockFlakeRef, replaced in tests.
+var lockFlakeRef = nix.LockFlakeRef
+
+// IsLockableFlake reports whether pkg is a flake installable that devbox.lock
+// pins to a revision. Local path flakes aren't pinned, since their contents
+// change whenever their files do.
+func IsLockableFlake(pkg string) bool {
+	if !pkgtype.IsFlake(pkg) {
+		return false
+	}
+	installable, err := flake.ParseInstallable(pkg)
+	return err == nil && installable.Ref.Type != flake.TypePath
+}
+
+// FetchLockedFlake locks a flake installable to the revision and source hash
+// that its flake reference resolves to now. Like FetchResolvedPackage, it
+// doesn't write the result to the lockfile. The locked installable keeps the
+// attribute path and outputs of pkg.
+func (f *File) FetchLockedFlake(ctx context.Context, pkg string) (*Package, error) {
+	installable, err := flake.ParseInstallable(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if envir.IsOffline() {
+		// Nix may still have the flake cached, so leave it unlocked
+		// rather than failing.
+		debug.Log("offline, so %s isn't locked", pkg)
+		return &Package{}, nil
+	}
+
+	metadata, err := lockFlakeRef(ctx, installable.Ref.String())
+	if err != nil {
+		return nil, err
+	}
+	installable.Ref = metadata.Locked
+	return &Package{
+		LastModified: formatLastModified(metadata.LastModified),
+		Resolved:     installable.String(),
+		Version:      metadata.Locked.Rev,
+		Source:       flakeSource,
+	}, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// RecordOutputHashes fills in the hash, and the content address if it has one,
+// of each output of the current system that is in the Nix store but doesn't
+// have a hash in the lockfile yet.
+// Existing hashes are never replaced, so that devbox verify can tell when a
+// store path no longer matches what was first installed.
+func (f *File) RecordOutputHashes(ctx context.Context) error {
+	if f.frozen {
+		return nil
+	}
+	unhashed := f.unhashedOutputs(nix.System())
+	if len(unhashed) == 0 {
+		return nil
+	}
+	infos, err := nix.PathInfos(ctx, lo.Keys(unhashed)...)
+	if err != nil {
+		return err
+	}
+	setOutputHashes(unhashed, infos)
+	return nil
+}
+
+// unhashedOutputs returns the outputs of system without a hash, keyed by
+// store path. Several packages can lock the same store path. Entries in the
+// legacy store_path format are skipped, since saving a hash would rewrite
+// them in the newer format.
+func (f *File) unhashedOutputs(system string) map[string][]*Output {
+	unhashed := map[string][]*Output{}
+	for _, pkg := range f.Packages {
+		sysInfo := pkg.Systems[system]
+		if sysInfo == nil || sysInfo.outputIsFromStorePath {
+			continue
+		}
+		for i := range sysInfo.Outputs {
+			out := &sysInfo.Outputs[i]
+			if out.Path != "" && out.Hash == "" {
+				unhashed[out.Path] = append(unhashed[out.Path], out)
+			}
+		}
+	}
+	return unhashed
+}
+
+func setOutputHashes(outputs map[string][]*Output, infos []nix.PathInfo) {
+	for _, info := range infos {
+		hash, err := info.Base32NarHash()
+		if err != nil {
+			debug.Log("lock: not recording the hash of %s: %v", info.Path, err)
+			continue
+		}
+		for _, out := range outputs[info.Path] {
+			out.Hash = hash
+			out.CA = info.CA
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestSetOutputHashes(t *testing.T) {
+	const hash = "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"
+	f := testLockfile(t, 2)
+	f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"].Outputs[1].Hash = "sha256:recorded"
+	legacy := &SystemInfo{StorePath: "/nix/store/legacy-pkg"}
+	legacy.addOutputFromLegacyStorePath()
+	f.Packages["legacy@1"] = &Package{Systems: map[string]*SystemInfo{"x86_64-linux": legacy}}
+
+	unhashed := f.unhashedOutputs("x86_64-linux")
+	require.Len(t, unhashed, 5, "recorded hashes and legacy entries are skipped")
+
+	setOutputHashes(unhashed, []nix.PathInfo{
+		{Path: f.Packages["pkg-0@1.0.0"].Systems["x86_64-linux"].Outputs[0].Path, NarHash: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+		{Path: f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"].Outputs[0].Path, NarHash: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", CA: "fixed:r:sha256:" + hash[len("sha256:"):]},
+	})
+	outputs := f.Packages["pkg-0@1.0.0"].Systems["x86_64-linux"].Outputs
+	require.Equal(t, hash, outputs[0].Hash)
+	require.Empty(t, outputs[0].CA, "input-addressed paths don't have a content address")
+	require.Equal(t, "fixed:r:sha256:"+hash[len("sha256:"):], f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"].Outputs[0].CA)
+	require.Empty(t, outputs[1].Hash, "paths that aren't in the store aren't hashed")
+	require.Equal(t, "sha256:recorded", f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"].Outputs[1].Hash)
+	require.Empty(t, f.Packages["pkg-0@1.0.0"].Systems["aarch64-linux"].Outputs[0].Hash)
+
+	// Recording a hash doesn't change which store paths are locked.
+	withoutHash := *f.Packages["pkg-0@1.0.0"].Systems["x86_64-linux"]
+	withoutHash.Outputs = append([]Output{}, withoutHash.Outputs...)
+	withoutHash.Outputs[0].Hash = ""
+	require.True(t, withoutHash.Equals(f.Packages["pkg-0@1.0.0"].Systems["x86_64-linux"]))
+	withoutCA := *f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"]
+	withoutCA.Outputs = append([]Output{}, withoutCA.Outputs...)
+	withoutCA.Outputs[0].CA = ""
+	require.True(t, withoutCA.Equals(f.Packages["pkg-1@1.0.1"].Systems["x86_64-linux"]))
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+const (
+	// lockHistoryDir keeps a copy of devbox.lock each time devbox writes it,
+	// so that a bad update can be rolled back.
+	lockHistoryDir = ".devbox/lock-history"
+	// maxSnapshots is how many copies lockHistoryDir keeps. Older ones are
+	// deleted.
+	maxSnapshots = 20
+
+	// snapshotIDFormat is a time format that sorts as a string and is safe
+	// to use in a file name.
+	snapshotIDFormat = "20060102T150405.000000Z"
+)
+
+// Snapshot is a copy of devbox.lock from the lock history.
+type Snapshot struct {
+	// ID is when devbox wrote the lockfile, which also names the snapshot.
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Packages are the packages that the lockfile locks, and their
+	// versions.
+	Packages map[string]string `json:"packages"`
+
+	path string
+}
+
+func lockHistoryPath(projectDir string) string {
+	return filepath.Join(projectDir, lockHistoryDir)
+}
+
+// snapshot copies the lockfile at path, which was just written, to the lock
+// history. Failing to do that doesn't fail the write.
+func (f *File) snapshot(path string) {
+	if err := addSnapshot(f.devboxProject.ProjectDir(), path, time.Now()); err != nil {
+		debug.Log("lock history: %v", err)
+	}
+}
+
+// snapshotBaseline adds the lockfile at path to the lock history before
+// devbox overwrites it for the first time, so that the first change can be
+// rolled back too.
+func (f *File) snapshotBaseline(path string) {
+	dir := f.devboxProject.ProjectDir()
+	snapshots, err := Snapshots(dir)
+	if err != nil || len(snapshots) > 0 {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if err := addSnapshot(dir, path, info.ModTime()); err != nil {
+		debug.Log("lock history: %v", err)
+	}
+}
+
+func addSnapshot(projectDir, path string, t time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dir := lockHistoryPath(projectDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	name := t.UTC().Format(snapshotIDFormat) + ".lock"
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	return pruneSnapshots(dir)
+}
+
+// pruneSnapshots deletes all but the newest maxSnapshots snapshots in dir.
+func pruneSnapshots(dir string) error {
+	names, err := snapshotNames(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names[:max(0, len(names)-maxSnapshots)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// snapshotNames returns the file names of the snapshots in dir, oldest first.
+func snapshotNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".lock")
+		if _, err := time.Parse(snapshotIDFormat, id); ok && err == nil && entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Snapshots returns the project's lock history, newest first. Snapshots that
+// can't be read are skipped.
+func Snapshots(projectDir string) ([]*Snapshot, error) {
+	dir := lockHistoryPath(projectDir)
+	names, err := snapshotNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*Snapshot, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		snapshot, err := readSnapshot(filepath.Join(dir, name))
+		if err != nil {
+			debug.Log("lock history: skipping %s: %v", name, err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+func readSnapshot(path string) (*Snapshot, error) {
+	id := strings.TrimSuffix(filepath.Base(path), ".lock")
+	t, err := time.Parse(snapshotIDFormat, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f := &File{}
+	if err := readLockFile(path, f); err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{ID: id, Time: t, Packages: map[string]string{}, path: path}
+	for key, pkg := range f.Packages {
+		if pkg != nil {
+			snapshot.Packages[key] = pkg.Version
+		}
+	}
+	return snapshot, nil
+}
+
+// Rollback replaces the lockfile's packages with the ones in the snapshot
+// with the given ID, and saves it. The rolled back lockfile becomes the
+// newest snapshot, so the rollback can be undone the same way.
+func (f *File) Rollback(id string) (*Snapshot, error) {
+	snapshots, err := Snapshots(f.devboxProject.ProjectDir())
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(snapshots, func(s *Snapshot) bool { return s.ID == id })
+	if i == -1 {
+		return nil, usererr.New(
+			"The lock history doesn't have a snapshot %q. Run `devbox lock rollback --list` to list them.", id)
+	}
+
+	snapshot := &File{}
+	if err := readLockFile(snapshots[i].path, snapshot); err != nil {
+		return nil, err
+	}
+	if err := snapshot.migrate(); err != nil {
+		return nil, err
+	}
+	ensurePackagesHaveOutputs(snapshot.Packages)
+	f.Packages = snapshot.Packages
+	return snapshots[i], f.Save()
+}
+
+// PreviousSnapshot returns the newest snapshot in the lock history that's
+// different from devbox.lock, which is what the lockfile was before the last
+// change. It returns nil if there isn't one.
+func (f *File) PreviousSnapshot() (*Snapshot, error) {
+	snapshots, err := Snapshots(f.devboxProject.ProjectDir())
+	if err != nil {
+		return nil, err
+	}
+	path := lockFilePath(f.devboxProject.ProjectDir())
+	for _, snapshot := range snapshots {
+		same, err := sameContents(snapshot.path, path)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return snapshot, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockHistory(t *testing.T) {
+	project := &testProject{dir: t.TempDir(), packages: []string{"go@1.22", "hello@2"}}
+	save := func(versions map[string]string) {
+		t.Helper()
+		f, err := GetFile(project)
+		require.NoError(t, err)
+		f.Packages = map[string]*Package{}
+		for key, version := range versions {
+			f.Packages[key] = &Package{Resolved: "github:NixOS/nixpkgs/abc#" + key, Version: version}
+		}
+		require.NoError(t, f.Save())
+		// Snapshot IDs have microseconds, so make sure each save gets its own.
+		time.Sleep(time.Millisecond)
+	}
+
+	save(map[string]string{"go@1.22": "1.22.3"})
+	save(map[string]string{"go@1.22": "1.22.3"})
+	save(map[string]string{"go@1.22": "1.22.5", "hello@2": "2.12.1"})
+
+	snapshots, err := Snapshots(project.dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2, "saving the same lockfile again doesn't add a snapshot")
+	require.Equal(t, map[string]string{"go@1.22": "1.22.5", "hello@2": "2.12.1"}, snapshots[0].Packages)
+	require.Equal(t, map[string]string{"go@1.22": "1.22.3"}, snapshots[1].Packages)
+
+	f, err := GetFile(project)
+	require.NoError(t, err)
+	previous, err := f.PreviousSnapshot()
+	require.NoError(t, err)
+	require.Equal(t, snapshots[1].ID, previous.ID)
+
+	restored, err := f.Rollback(previous.ID)
+	require.NoError(t, err)
+	require.Equal(t, previous.ID, restored.ID)
+	f, err = GetFile(project)
+	require.NoError(t, err)
+	require.Equal(t, "1.22.3", f.Packages["go@1.22"].Version)
+	require.NotContains(t, f.Packages, "hello@2")
+
+	snapshots, err = Snapshots(project.dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3, "the rollback is a snapshot too")
+	previous, err = f.PreviousSnapshot()
+	require.NoError(t, err)
+	require.Equal(t, "2.12.1", previous.Packages["hello@2"], "a rollback can be undone")
+
+	_, err = f.Rollback("20000101T000000.000000Z")
+	require.Error(t, err)
+}
+
+func TestLockHistoryBaselineAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devbox.lock")
+	require.NoError(t, os.WriteFile(path, []byte(`{"lockfile_version": "1", "packages": {}}`), 0o644))
+	old := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	f := &File{devboxProject: &testProject{dir: dir}}
+	f.snapshotBaseline(path)
+	snapshots, err := Snapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.Equal(t, "20240601T120000.000000Z", snapshots[0].ID,
+		"the lockfile from before devbox changed it is named after its modification time")
+
+	for i := range maxSnapshots + 5 {
+		require.NoError(t, addSnapshot(dir, path, old.Add(time.Duration(i+1)*time.Minute)))
+	}
+	snapshots, err = Snapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, maxSnapshots)
+	require.Equal(t, old.Add((maxSnapshots+5)*time.Minute), snapshots[0].Time)
+}
@@ -3,14 +3,33 @@
 
 package lock
 
+import "go.jetpack.io/devbox/internal/nix"
+
 type devboxProject interface {
+	// BinaryCaches are the project's own binary caches, in the order that
+	// they should be queried.
+	BinaryCaches() []string
 	ConfigHash() (string, error)
 	NixPkgsCommitHash() string
+	PackagesHash() (string, error)
 	AllPackageNamesIncludingRemovedTriggerPackages() []string
+	// PinnedCommit is the nixpkgs commit that devbox.json pins a package
+	// to, or "".
+	PinnedCommit(pkg string) string
 	ProjectDir() string
+	// ResolverConfig selects the resolver that locks the project's
+	// packages.
+	ResolverConfig() ResolverConfig
+	// SearchPublicKeys are the keys that the search service's resolutions
+	// must be signed by. Without keys, resolutions aren't checked.
+	SearchPublicKeys() []nix.PublicKey
+	// CheckRegistry returns an error if the project isn't allowed to
+	// resolve packages with the search service at host.
+	CheckRegistry(host string) error
 }
 
 type Locker interface {
+	BinaryCaches() []string
 	Get(string) *Package
 	LegacyNixpkgsPath(string) string
 	ProjectDir() string
@@ -7,19 +7,26 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/pkg/runx/impl/types"
-
-	"go.jetpack.io/devbox/internal/cuecfg"
 )
 
+// lockFileVersion is the format of devbox.lock that this version of devbox
+// writes. Older lockfiles are upgraded by migrate.
 const lockFileVersion = "1"
 
 // Lightly inspired by package-lock.json
@@ -30,22 +37,37 @@ type File struct {
 
 	// Packages is keyed by "canonicalName@version"
 	Packages map[string]*Package `json:"packages"`
+
+	// frozen makes resolving packages that aren't locked and saving changes
+	// fail. See Freeze.
+	frozen bool
+
+	// migratedFrom is the lockfile_version of the file on disk, if it's
+	// older than lockFileVersion and hasn't been saved since.
+	migratedFrom string
+
+	// prefetched has the resolutions that PrefetchResolutions got in
+	// batches.
+	prefetched *prefetchedResolutions
 }
 
 func GetFile(project devboxProject) (*File, error) {
 	lockFile := &File{
 		devboxProject: project,
 
-		LockFileVersion: lockFileVersion,
-		Packages:        map[string]*Package{},
+		Packages: map[string]*Package{},
 	}
-	err := cuecfg.ParseFile(lockFilePath(project.ProjectDir()), lockFile)
+	err := readLockFile(lockFilePath(project.ProjectDir()), lockFile)
 	if errors.Is(err, fs.ErrNotExist) {
+		lockFile.LockFileVersion = lockFileVersion
 		return lockFile, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	if err := lockFile.migrate(); err != nil {
+		return nil, err
+	}
 
 	// If the lockfile has legacy StorePath fields, we need to convert them to the new format
 	ensurePackagesHaveOutputs(lockFile.Packages)
@@ -72,13 +94,30 @@ func (f *File) Remove(pkgs ...string) error {
 // Resolve updates the in memory copy for performance but does not write to disk
 // This avoids writing values that may need to be removed in case of error.
 func (f *File) Resolve(pkg string) (*Package, error) {
+	return f.ResolveContext(context.Background(), pkg)
+}
+
+// ResolveContext is Resolve with a context that bounds the requests to the
+// search service.
+func (f *File) ResolveContext(ctx context.Context, pkg string) (*Package, error) {
 	entry, hasEntry := f.Packages[pkg]
 
-	if !hasEntry || entry.Resolved == "" {
+	if !hasEntry || entry.Resolved == "" || f.pinChanged(pkg, entry) {
 		locked := &Package{}
 		var err error
-		if _, _, versioned := searcher.ParseVersionedPackage(pkg); pkgtype.IsRunX(pkg) || versioned {
-			locked, err = f.FetchResolvedPackage(pkg)
+		if _, _, versioned := searcher.ParseVersionedPackage(pkg); pkgtype.IsRunX(pkg) || pkgtype.IsEcosystem(pkg) || versioned {
+			if f.frozen {
+				return nil, usererr.New("%s isn't in devbox.lock, which is frozen", pkg)
+			}
+			locked, err = f.FetchResolvedPackage(ctx, pkg)
+			if err != nil {
+				return nil, err
+			}
+		} else if IsLockableFlake(pkg) {
+			if f.frozen {
+				return nil, usererr.New("%s isn't in devbox.lock, which is frozen", pkg)
+			}
+			locked, err = f.FetchLockedFlake(ctx, pkg)
 			if err != nil {
 				return nil, err
 			}
@@ -111,9 +150,17 @@ func (f *File) Save() error {
 	if err != nil {
 		return err
 	}
-	if !isDirty {
+	if !isDirty && f.migratedFrom == "" {
 		return nil
 	}
+	if f.frozen {
+		if !isDirty {
+			// Upgrading the format doesn't change what's locked, so
+			// it waits until the lockfile isn't frozen.
+			return nil
+		}
+		return usererr.New("devbox.lock is frozen, but installing would change it")
+	}
 
 	// In SystemInfo, preserve legacy StorePath field and clear out modern Outputs before writing
 	// Reason: We want to update `devbox.lock` file only upon a user action
@@ -129,7 +176,42 @@ func (f *File) Save() error {
 	// users of the `lock.File` struct will have the correct data.
 	defer ensurePackagesHaveOutputs(f.Packages)
 
-	return cuecfg.WriteFile(lockFilePath(f.devboxProject.ProjectDir()), f)
+	path := lockFilePath(f.devboxProject.ProjectDir())
+	if f.migratedFrom != "" {
+		// The upgrade message points to the lock history for the old
+		// lockfile, so it's copied there even if the history has older
+		// snapshots already.
+		if err := addSnapshot(f.devboxProject.ProjectDir(), path, time.Now()); err != nil {
+			return err
+		}
+	} else {
+		f.snapshotBaseline(path)
+	}
+	written, err := writeLockFile(path, f)
+	if err != nil {
+		return err
+	}
+	if written {
+		f.snapshot(path)
+	}
+	if f.migratedFrom != "" {
+		ux.Finfo(os.Stderr, "Upgraded devbox.lock from lockfile_version %s to %s. "+
+			"A copy of the old lockfile is in %s.\n",
+			f.migratedFrom, f.LockFileVersion, lockHistoryDir)
+		f.migratedFrom = ""
+	}
+	return nil
+}
+
+// Freeze stops the lockfile from changing: resolving a package that isn't
+// locked yet, or saving any change, returns an error instead.
+func (f *File) Freeze() {
+	f.frozen = true
+}
+
+// IsFrozen reports whether Freeze stopped the lockfile from changing.
+func (f *File) IsFrozen() bool {
+	return f.frozen
 }
 
 func (f *File) LegacyNixpkgsPath(pkg string) string {
@@ -148,6 +230,51 @@ func (f *File) Get(pkg string) *Package {
 	return entry
 }
 
+// NeedsResolution returns true if pkg has to go through the resolver to be
+// up-to-date. Packages pinned to an exact version that is already locked
+// (including store paths for the current system) can't resolve to anything
+// different, so `devbox update` leaves their lock entries untouched.
+func (f *File) NeedsResolution(pkg string) bool {
+	entry := f.Get(pkg)
+	if entry == nil {
+		return true
+	}
+	_, version, versioned := searcher.ParseVersionedPackage(pkg)
+	// The channel only says where to resolve the version from.
+	version, _, _ = searcher.ParseChannel(version)
+	if !versioned || version != entry.Version || f.pinChanged(pkg, entry) {
+		return true
+	}
+	if entry.IsPinned() || entry.IsEvaluated() {
+		// Pinned and evaluated packages don't have store paths to look up.
+		return false
+	}
+	if featureflag.RemoveNixpkgs.Enabled() && !pkgtype.IsRunX(pkg) {
+		if _, ok := entry.Systems[nix.System()]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SpecChanged returns true if pkg's lock entry no longer matches its spec in
+// devbox.json: pkg was added or its version changed, so there's no entry for
+// it, devbox.json pins it to another commit, or the entry has no store paths
+// for the current system when the search service gives it some. Without packages to update, devbox update only
+// resolves these, so that the other entries stay as they are.
+func (f *File) SpecChanged(pkg string) bool {
+	entry := f.Get(pkg)
+	if entry == nil || entry.Resolved == "" || f.pinChanged(pkg, entry) {
+		return true
+	}
+	if featureflag.RemoveNixpkgs.Enabled() && !entry.IsPinned() && !entry.IsEvaluated() && !pkgtype.IsRunX(pkg) && !pkgtype.IsFlake(pkg) {
+		if _, ok := entry.Systems[nix.System()]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *File) HasAllowInsecurePackages() bool {
 	for _, pkg := range f.Packages {
 		if pkg.AllowInsecure {
@@ -173,30 +300,80 @@ func IsLegacyPackage(pkg string) bool {
 // Tidy ensures that the lockfile has the set of packages corresponding to the devbox.json config.
 // It gets rid of older packages that are no longer needed.
 func (f *File) Tidy() {
-	f.Packages = lo.PickByKeys(
-		f.Packages,
-		f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages(),
-	)
+	f.Prune()
+}
+
+// RecordOrigins sets the SourceOf of each locked package to its origins,
+// which are keyed by lock key. A frozen lockfile keeps the origins it has,
+// because they don't change what's installed.
+func (f *File) RecordOrigins(origins map[string][]string) {
+	if f.frozen {
+		return
+	}
+	for key, pkg := range f.Packages {
+		pkg.SourceOf = slices.Clone(origins[key])
+	}
+}
+
+// StalePackages returns the sorted keys of the locked packages that neither
+// devbox.json nor its plugins reference anymore.
+func (f *File) StalePackages() []string {
+	referenced := f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages()
+	stale := lo.Filter(lo.Keys(f.Packages), func(pkg string, _ int) bool {
+		return !slices.Contains(referenced, pkg)
+	})
+	slices.Sort(stale)
+	return stale
+}
+
+// Prune removes the stale packages from the lockfile, without saving it, and
+// returns the ones it removed.
+func (f *File) Prune() []string {
+	stale := f.StalePackages()
+	for _, pkg := range stale {
+		delete(f.Packages, pkg)
+	}
+	return stale
 }
 
 // IsUpToDateAndInstalled returns true if the lockfile is up to date and the
 // local hashes match, which generally indicates all packages are correctly
 // installed and print-dev-env has been computed and cached.
 func (f *File) IsUpToDateAndInstalled(isFish bool) (bool, error) {
-	if dirty, err := f.isDirty(); err != nil {
+	drift, err := f.StateDrift(isFish)
+	if err != nil {
 		return false, err
-	} else if dirty {
-		return false, nil
 	}
+	return !drift.Any(), nil
+}
+
+// StateDrift compares the project against the hashes recorded the last time
+// the state was computed. Callers can use it to only redo the work affected by
+// what changed instead of recomputing everything.
+func (f *File) StateDrift(isFish bool) (StateDrift, error) {
 	configHash, err := f.devboxProject.ConfigHash()
 	if err != nil {
-		return false, err
+		return StateDrift{}, err
+	}
+	packagesHash, err := f.devboxProject.PackagesHash()
+	if err != nil {
+		return StateDrift{}, err
 	}
-	return isStateUpToDate(UpdateStateHashFileArgs{
-		ProjectDir: f.devboxProject.ProjectDir(),
-		ConfigHash: configHash,
-		IsFish:     isFish,
+	drift, err := stateDrift(UpdateStateHashFileArgs{
+		ProjectDir:   f.devboxProject.ProjectDir(),
+		ConfigHash:   configHash,
+		PackagesHash: packagesHash,
+		IsFish:       isFish,
 	})
+	if err != nil {
+		return StateDrift{}, err
+	}
+	if dirty, err := f.isDirty(); err != nil {
+		return StateDrift{}, err
+	} else if dirty {
+		drift.Lock = true
+	}
+	return drift, nil
 }
 
 func (f *File) isDirty() (bool, error) {
@@ -219,6 +396,9 @@ func lockFilePath(projectDir string) string {
 	return filepath.Join(projectDir, "devbox.lock")
 }
 
+// ResolveRunXPackage resolves the version of a runx: package to a release
+// tag. Tags can move, so the release artifact itself is pinned by the digest
+// that VerifyRunXDigest records when the package is first installed.
 func ResolveRunXPackage(ctx context.Context, pkg string) (types.PkgRef, error) {
 	ref, err := types.NewPkgRef(strings.TrimPrefix(pkg, pkgtype.RunXPrefix))
 	if err != nil {
@@ -0,0 +1,585 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cuecfg"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+type testProject struct {
+	dir      string
+	pins     map[string]string
+	caches   []string
+	packages []string
+	keys     []nix.PublicKey
+	resolver ResolverConfig
+	// registryErr is returned by CheckRegistry.
+	registryErr error
+}
+
+func (p *testProject) BinaryCaches() []string         { return p.caches }
+func (p *testProject) ConfigHash() (string, error)    { return "", nil }
+func (p *testProject) NixPkgsCommitHash() string      { return "" }
+func (p *testProject) PackagesHash() (string, error)  { return "", nil }
+func (p *testProject) ProjectDir() string             { return p.dir }
+func (p *testProject) PinnedCommit(pkg string) string { return p.pins[pkg] }
+
+func (p *testProject) SearchPublicKeys() []nix.PublicKey { return p.keys }
+func (p *testProject) ResolverConfig() ResolverConfig    { return p.resolver }
+func (p *testProject) CheckRegistry(string) error        { return p.registryErr }
+
+func (p *testProject) AllPackageNamesIncludingRemovedTriggerPackages() []string {
+	return p.packages
+}
+
+// testLockfile returns a lockfile with n packages, each with every system and
+// a few outputs, to approximate the lockfile of a large monorepo.
+func testLockfile(tb testing.TB, n int) *File {
+	tb.Helper()
+	f := &File{
+		devboxProject:   &testProject{dir: tb.TempDir()},
+		LockFileVersion: lockFileVersion,
+		Packages:        make(map[string]*Package, n),
+	}
+	for i := range n {
+		systems := map[string]*SystemInfo{}
+		for _, sys := range []string{"aarch64-darwin", "aarch64-linux", "x86_64-darwin", "x86_64-linux"} {
+			systems[sys] = &SystemInfo{Outputs: []Output{
+				{Name: "out", Path: fmt.Sprintf("/nix/store/%032d-pkg-%d", i, i), Default: true},
+				{Name: "dev", Path: fmt.Sprintf("/nix/store/%032d-pkg-%d-dev", i, i)},
+				{Name: "man", Path: fmt.Sprintf("/nix/store/%032d-pkg-%d-man", i, i)},
+			}}
+		}
+		f.Packages[fmt.Sprintf("pkg-%d@1.0.%d", i, i)] = &Package{
+			LastModified: "2024-01-01T00:00:00Z",
+			Resolved:     fmt.Sprintf("github:NixOS/nixpkgs/%040d#pkg-%d", i, i),
+			Source:       devboxSearchSource,
+			Version:      fmt.Sprintf("1.0.%d", i),
+			Systems:      systems,
+		}
+	}
+	return f
+}
+
+func TestSaveMatchesConfigFormat(t *testing.T) {
+	f := testLockfile(t, 3)
+	require.NoError(t, f.Save())
+
+	got, err := os.ReadFile(lockFilePath(f.ProjectDir()))
+	require.NoError(t, err)
+	want, err := cuecfg.MarshalJSON(f)
+	require.NoError(t, err)
+	require.Equal(t, string(want)+"\n", string(got))
+
+	loaded, err := GetFile(f.devboxProject)
+	require.NoError(t, err)
+	require.Equal(t, f.Packages, loaded.Packages)
+
+	dirty, err := loaded.isDirty()
+	require.NoError(t, err)
+	require.False(t, dirty)
+}
+
+func TestSaveKeepsFilePermissions(t *testing.T) {
+	f := testLockfile(t, 1)
+	path := lockFilePath(f.ProjectDir())
+	require.NoError(t, os.WriteFile(path, []byte("{}"), 0o600))
+
+	require.NoError(t, f.Save())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func BenchmarkGetFile(b *testing.B) {
+	f := testLockfile(b, 500)
+	if err := f.Save(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := GetFile(f.devboxProject); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	f := testLockfile(b, 500)
+	path := lockFilePath(f.ProjectDir())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		// Save is a no-op when nothing changed, so remove the file to force a
+		// write on every iteration.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			b.Fatal(err)
+		}
+		if err := f.Save(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIsDirty(b *testing.B) {
+	f := testLockfile(b, 500)
+	if err := f.Save(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := f.isDirty(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestResolvePinnedPackage(t *testing.T) {
+	commit := "75a52265bda7fd25e06e3a67dee3f0354e73243c"
+	project := &testProject{dir: t.TempDir(), pins: map[string]string{"hello@2.12.1": commit}}
+	f := &File{devboxProject: project, LockFileVersion: lockFileVersion, Packages: map[string]*Package{}}
+
+	// The pin is resolved without the search API.
+	pkg, err := f.Resolve("hello@2.12.1")
+	require.NoError(t, err)
+	require.Equal(t, "github:NixOS/nixpkgs/"+commit+"#hello", pkg.Resolved)
+	require.Equal(t, "2.12.1", pkg.Version)
+	require.True(t, pkg.IsPinned())
+	require.False(t, f.NeedsResolution("hello@2.12.1"))
+
+	// Changing the pin resolves the package again.
+	newCommit := "5d7db4668d7a0c6cc5fc8cf6ef33b008b2b1ed8b"
+	project.pins["hello@2.12.1"] = newCommit
+	require.True(t, f.NeedsResolution("hello@2.12.1"))
+	pkg, err = f.Resolve("hello@2.12.1")
+	require.NoError(t, err)
+	require.Equal(t, "github:NixOS/nixpkgs/"+newCommit+"#hello", pkg.Resolved)
+
+	// So does removing it.
+	delete(project.pins, "hello@2.12.1")
+	require.True(t, f.NeedsResolution("hello@2.12.1"))
+}
+
+func TestResolveOffline(t *testing.T) {
+	t.Setenv(envir.DevboxOffline, "1")
+	f := testLockfile(t, 1)
+
+	// Locked packages resolve from devbox.lock.
+	pkg, err := f.Resolve("pkg-0@1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", pkg.Version)
+
+	// Unlocked packages fail instead of asking the search API.
+	_, err = f.Resolve("hello@2.12.1")
+	require.Error(t, err)
+	_, isUserErr := usererr.Extract(err)
+	require.True(t, isUserErr)
+	require.NotContains(t, f.Packages, "hello@2.12.1")
+
+	// Pins don't need the network.
+	commit := "75a52265bda7fd25e06e3a67dee3f0354e73243c"
+	f.devboxProject = &testProject{dir: t.TempDir(), pins: map[string]string{"hello@2.12.1": commit}}
+	pkg, err = f.Resolve("hello@2.12.1")
+	require.NoError(t, err)
+	require.True(t, pkg.IsPinned())
+}
+
+func TestFetchResolvedPackages(t *testing.T) {
+	t.Setenv(envir.DevboxResolveConcurrency, "2")
+	require.Equal(t, 2, ResolveConcurrency())
+
+	pins := map[string]string{}
+	var pkgs []string
+	for i := range 5 {
+		pkg := fmt.Sprintf("pkg-%d@1.0", i)
+		pins[pkg] = fmt.Sprintf("%040d", i)
+		pkgs = append(pkgs, pkg)
+	}
+	f := &File{devboxProject: &testProject{dir: t.TempDir(), pins: pins}, Packages: map[string]*Package{}}
+
+	resolved, err := f.FetchResolvedPackages(context.Background(), append(pkgs, "github:numtide/flake-utils"))
+	require.NoError(t, err)
+	require.Len(t, resolved, 6)
+	for i, pkg := range pkgs {
+		require.Equal(t, fmt.Sprintf("github:NixOS/nixpkgs/%040d#pkg-%d", i, i), resolved[pkg].Resolved)
+	}
+	require.Nil(t, resolved["github:numtide/flake-utils"], "flakes aren't resolved")
+	require.Empty(t, f.Packages, "resolutions aren't written to the lockfile")
+
+	// One failure fails the batch.
+	_, err = f.FetchResolvedPackages(context.Background(), append(pkgs, "hello@"))
+	require.Error(t, err)
+
+	t.Setenv(envir.DevboxResolveConcurrency, "zero")
+	require.Equal(t, defaultResolveConcurrency, ResolveConcurrency())
+}
+
+func TestResolveSystemVersions(t *testing.T) {
+	commit20, commit18 := strings.Repeat("2", 40), strings.Repeat("1", 40)
+	f := &File{
+		devboxProject: &testProject{dir: t.TempDir(), pins: map[string]string{
+			"nodejs@20": commit20,
+			"nodejs@18": commit18,
+		}},
+		Packages: map[string]*Package{},
+	}
+
+	pkg := "nodejs@" + searcher.FormatSystemVersions(map[string]string{
+		"aarch64-darwin": "20",
+		"x86_64-darwin":  "20",
+		"x86_64-linux":   "18",
+	})
+	locked, err := f.Resolve(pkg)
+	require.NoError(t, err)
+	require.Len(t, locked.Systems, 3)
+	require.Equal(t, pinnedRef("nodejs", commit20), locked.Resolved, "the first system's resolution")
+
+	require.Equal(t, pinnedRef("nodejs", commit18), locked.ResolvedFor("x86_64-linux"))
+	require.Equal(t, "18", locked.VersionFor("x86_64-linux"))
+	require.Equal(t, pinnedRef("nodejs", commit20), locked.ResolvedFor("x86_64-darwin"))
+	require.Equal(t, "20", locked.VersionFor("aarch64-darwin"))
+	require.Nil(t, locked.Systems["aarch64-linux"], "systems without a version aren't locked")
+}
+
+func TestResolveVersionRange(t *testing.T) {
+	commit := strings.Repeat("3", 40)
+	f := &File{
+		devboxProject: &testProject{dir: t.TempDir(), pins: map[string]string{"go@1.22.1": commit}},
+		Packages:      map[string]*Package{},
+	}
+	list := packageVersions
+	t.Cleanup(func() { packageVersions = list })
+	packageVersions = func(_ context.Context, name string) ([]string, error) {
+		return []string{"1.20.14", "1.21.8", "1.22.1", "2.0.0"}, nil
+	}
+
+	locked, err := f.Resolve("go@^1.21")
+	require.NoError(t, err)
+	require.Equal(t, "1.22.1", locked.Version, "the highest version in the range")
+	require.Equal(t, pinnedRef("go", commit), locked.Resolved)
+	require.Contains(t, f.Packages, "go@^1.21", "the range is the lock key")
+
+	_, err = f.FetchResolvedPackage(context.Background(), "go@>=3")
+	require.ErrorContains(t, err, "None of the versions of go match >=3")
+	_, err = f.FetchResolvedPackage(context.Background(), "go@^x")
+	require.Error(t, err)
+}
+
+func TestResolveFromPackageIndex(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [{
+		"name": "hello",
+		"version": "2.12.1",
+		"systems": {
+			"x86_64-linux": {
+				"flake_installable": {
+					"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+					"attr_path": "hello"
+				},
+				"last_updated": "2024-03-21T09:22:22Z",
+				"outputs": [{"name": "out", "path": "/nix/store/abc-hello-2.12.1", "default": true}]
+			}
+		}
+	}]}`), 0o644))
+	t.Setenv(envir.DevboxPackageIndex, index)
+	t.Setenv(envir.DevboxOffline, "1")
+
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{}}
+	locked, err := f.Resolve("hello@latest")
+	require.NoError(t, err, "an air-gapped machine resolves from the index")
+	require.Equal(t, "2.12.1", locked.Version)
+	require.Equal(t, "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello", locked.Resolved)
+	require.Equal(t, "/nix/store/abc-hello-2.12.1", locked.Systems["x86_64-linux"].Outputs[0].Path)
+
+	_, err = f.Resolve("hello@3")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+	userErr, ok := usererr.Extract(err)
+	require.True(t, ok)
+	require.Contains(t, userErr.Error(), "couldn't find version 3 of hello")
+
+	_, err = f.Resolve("helo@latest")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+	userErr, ok = usererr.Extract(err)
+	require.True(t, ok)
+	require.Contains(t, userErr.Error(), "Did you mean hello?")
+}
+
+func TestResolveEcosystemPackage(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [{
+		"name": "nodePackages.prettier",
+		"version": "3.3.3",
+		"systems": {
+			"x86_64-linux": {
+				"flake_installable": {
+					"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+					"attr_path": "nodePackages.prettier"
+				},
+				"last_updated": "2024-03-21T09:22:22Z"
+			}
+		}
+	}]}`), 0o644))
+	t.Setenv(envir.DevboxPackageIndex, index)
+
+	project := &testProject{dir: t.TempDir(), pins: map[string]string{
+		"npm:prettier@3.3.3": "0000000000000000000000000000000000000001",
+	}}
+	f := &File{devboxProject: project, Packages: map[string]*Package{}}
+	locked, err := f.Resolve("npm:prettier@3")
+	require.NoError(t, err)
+	require.Equal(t, "3.3.3", locked.Version)
+	require.Equal(t, "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#nodePackages.prettier", locked.Resolved)
+	require.Contains(t, f.Packages, "npm:prettier@3", "the lock entry is keyed by the name in devbox.json")
+
+	locked, err = f.Resolve("npm:prettier@3.3.3")
+	require.NoError(t, err)
+	require.Equal(t, "github:NixOS/nixpkgs/0000000000000000000000000000000000000001#nodePackages.prettier", locked.Resolved)
+
+	_, err = f.Resolve("npm:prettier")
+	require.ErrorContains(t, err, "No version specified")
+}
+
+func TestLockAllSystems(t *testing.T) {
+	installable := `"flake_installable": {
+		"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+		"attr_path": "hello"
+	}`
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [{
+		"name": "hello",
+		"version": "2.12.1",
+		"systems": {
+			"aarch64-darwin": {`+installable+`, "outputs": [{"name": "out", "path": "/nix/store/aaa-hello-2.12.1", "default": true}]},
+			"x86_64-linux": {`+installable+`, "outputs": [{"name": "out", "path": "/nix/store/bbb-hello-2.12.1", "default": true}]},
+			"aarch64-linux": {`+installable+`}
+		}
+	}]}`), 0o644))
+	t.Setenv(envir.DevboxPackageIndex, index)
+
+	resolved := "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello"
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{
+		"hello@2.12.1": {
+			Resolved: resolved,
+			Source:   devboxSearchSource,
+			Version:  "2.12.1",
+			Systems: map[string]*SystemInfo{
+				"aarch64-darwin": {Outputs: []Output{{Name: "out", Path: "/nix/store/aaa-hello-2.12.1", Default: true, Hash: "sha256:abc"}}},
+			},
+		},
+	}}
+	missing, err := f.LockAllSystems(context.Background(), "hello@2.12.1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"aarch64-linux"}, missing, "aarch64-linux doesn't have store paths")
+
+	entry := f.Packages["hello@2.12.1"]
+	require.Equal(t, "/nix/store/bbb-hello-2.12.1", entry.Systems["x86_64-linux"].Outputs[0].Path)
+	require.Equal(t, "sha256:abc", entry.Systems["aarch64-darwin"].Outputs[0].Hash,
+		"systems that are already locked are left alone")
+
+	// A lock entry from another commit doesn't get the store paths of this one.
+	entry.Resolved = "github:NixOS/nixpkgs/0000000000000000000000000000000000000000#hello"
+	delete(entry.Systems, "x86_64-linux")
+	missing, err = f.LockAllSystems(context.Background(), "hello@2.12.1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"aarch64-linux", "x86_64-linux"}, missing)
+	require.NotContains(t, entry.Systems, "x86_64-linux")
+}
+
+func TestRecordOrigins(t *testing.T) {
+	f := &File{Packages: map[string]*Package{
+		"go@1.22": {Resolved: "github:NixOS/nixpkgs/abc#go"},
+		"hello@2": {Resolved: "github:NixOS/nixpkgs/abc#hello", SourceOf: []string{"include:./old"}},
+	}}
+	f.RecordOrigins(map[string][]string{"go@1.22": {"devbox.json", "plugin:php@8.1"}})
+	require.Equal(t, []string{"devbox.json", "plugin:php@8.1"}, f.Packages["go@1.22"].SourceOf)
+	require.Nil(t, f.Packages["hello@2"].SourceOf, "packages without origins lose the stale ones")
+
+	f.Freeze()
+	f.RecordOrigins(map[string][]string{"go@1.22": {"devbox.json"}})
+	require.Equal(t, []string{"devbox.json", "plugin:php@8.1"}, f.Packages["go@1.22"].SourceOf,
+		"a frozen lockfile keeps its origins")
+}
+
+func TestWriteLockFileIsDeterministic(t *testing.T) {
+	newFile := func() *File {
+		return &File{LockFileVersion: lockFileVersion, Packages: map[string]*Package{
+			"hello@2.12.1": {
+				LastModified: "2024-03-21T11:22:22+02:00",
+				Resolved:     "github:NixOS/nixpkgs/abc#hello",
+				SourceOf:     []string{"include:./plugins/hello", "devbox.json"},
+				Systems: map[string]*SystemInfo{
+					"x86_64-linux":   {Outputs: []Output{{Name: "out", Path: "/nix/store/abc-hello-2.12.1", Default: true}}},
+					"aarch64-darwin": nil,
+				},
+			},
+			"removed@1": nil,
+		}}
+	}
+	path := filepath.Join(t.TempDir(), "devbox.lock")
+	written, err := writeLockFile(path, newFile())
+	require.NoError(t, err)
+	require.True(t, written)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"last_modified": "2024-03-21T09:22:22Z"`, "times are in UTC")
+	require.Contains(t, string(data), `"source_of": [
+        "devbox.json",
+        "include:./plugins/hello"
+      ]`)
+	require.NotContains(t, string(data), "null")
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(path, old, old))
+	written, err = writeLockFile(path, newFile())
+	require.NoError(t, err)
+	require.False(t, written)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, old, info.ModTime(), "a lockfile with the same contents isn't rewritten")
+	rewritten, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(rewritten))
+}
+
+func TestNeedsResolutionWithChannel(t *testing.T) {
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{
+		"go@1.23.1?channel=nixpkgs-unstable": {
+			Resolved: "github:NixOS/nixpkgs/abc#go",
+			Source:   devboxSearchSource,
+			Version:  "1.23.1",
+			Systems: map[string]*SystemInfo{
+				nix.System(): {Outputs: []Output{{Name: "out", Path: "/nix/store/abc-go-1.23.1", Default: true}}},
+			},
+		},
+	}}
+	require.False(t, f.NeedsResolution("go@1.23.1?channel=nixpkgs-unstable"),
+		"the channel isn't part of the locked version")
+}
+
+func TestEvaluatedPackageIsCurrent(t *testing.T) {
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{
+		"hello@2.12.1": {
+			LastModified: "2024-03-21T09:22:22Z",
+			Resolved:     "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+			Source:       nixpkgsEvalSource,
+			Version:      "2.12.1",
+		},
+	}}
+	require.False(t, f.NeedsResolution("hello@2.12.1"),
+		"an evaluated package doesn't have store paths to resolve")
+	require.False(t, f.SpecChanged("hello@2.12.1"))
+}
+
+func TestBuildLockSystemInfosQueriesCachesInOrder(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	caches := map[string]map[string]string{
+		"https://cache.acme.internal": {"aaa": "/nix/store/aaa-hello-2.12.1"},
+		"https://cache.nixos.org":     {"aaa": "/nix/store/aaa-wrong", "bbb": "/nix/store/bbb-hello-2.12.1"},
+	}
+	var mu sync.Mutex
+	var queried []string
+	lookup := storePathFromHashPart
+	t.Cleanup(func() { storePathFromHashPart = lookup })
+	storePathFromHashPart = func(_ context.Context, hash, cache string) (string, error) {
+		mu.Lock()
+		queried = append(queried, cache+" "+hash)
+		mu.Unlock()
+		if path, ok := caches[cache][hash]; ok {
+			return path, nil
+		}
+		return "", fmt.Errorf("%s isn't in %s", hash, cache)
+	}
+
+	f := &File{devboxProject: &testProject{dir: t.TempDir(), caches: []string{"https://cache.acme.internal"}}}
+	require.Equal(t, []string{"https://cache.acme.internal", "https://cache.nixos.org"}, f.BinaryCaches())
+
+	systems, err := buildLockSystemInfos(context.Background(), &searcher.PackageVersion{
+		Systems: map[string]searcher.PackageInfo{
+			"x86_64-linux":   {StoreHash: "aaa", StoreName: "hello-2.12.1"},
+			"aarch64-darwin": {StoreHash: "bbb", StoreName: "hello-2.12.1"},
+			"aarch64-linux":  {StoreHash: "ccc", StoreName: "hello-2.12.1"},
+		},
+	}, f.BinaryCaches())
+	require.NoError(t, err)
+	require.Equal(t, "/nix/store/aaa-hello-2.12.1", systems["x86_64-linux"].Outputs[0].Path)
+	require.Equal(t, "/nix/store/bbb-hello-2.12.1", systems["aarch64-darwin"].Outputs[0].Path)
+	require.NotContains(t, systems, "aarch64-linux", "store paths that aren't cached are left out")
+	require.NotContains(t, queried, "https://cache.nixos.org aaa", "later caches aren't queried after a hit")
+}
+
+func TestPrune(t *testing.T) {
+	f := &File{
+		devboxProject: &testProject{
+			dir:      t.TempDir(),
+			packages: []string{"go@1.22", "github:numtide/flake-utils"},
+		},
+		LockFileVersion: lockFileVersion,
+		Packages: map[string]*Package{
+			"go@1.22":                    {Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.22.1"},
+			"go@1.21":                    {Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.21.8"},
+			"github:numtide/flake-utils": {Resolved: "github:numtide/flake-utils"},
+			"python@3.11":                {Resolved: "github:NixOS/nixpkgs/abc#python311", Version: "3.11.8"},
+		},
+	}
+
+	require.Equal(t, []string{"go@1.21", "python@3.11"}, f.StalePackages())
+	require.Len(t, f.Packages, 4, "StalePackages doesn't remove anything")
+
+	require.Equal(t, []string{"go@1.21", "python@3.11"}, f.Prune())
+	require.ElementsMatch(t, []string{"go@1.22", "github:numtide/flake-utils"}, lo.Keys(f.Packages))
+	require.Empty(t, f.Prune())
+}
+
+func TestResolveLockedFlake(t *testing.T) {
+	rev := "b1d9ab70662946ef0850d488da1c9019f3a9752a"
+	var locked []string
+	lockRef := lockFlakeRef
+	t.Cleanup(func() { lockFlakeRef = lockRef })
+	lockFlakeRef = func(_ context.Context, ref string) (nix.FlakeMetadata, error) {
+		locked = append(locked, ref)
+		return nix.FlakeMetadata{
+			Locked: flake.Ref{Type: flake.TypeGitHub, Owner: "numtide", Repo: "flake-utils", Rev: rev, NarHash: "sha256-abc="},
+		}, nil
+	}
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{}}
+
+	pkg, err := f.Resolve("github:numtide/flake-utils#lib")
+	require.NoError(t, err)
+	require.Equal(t, "github:numtide/flake-utils/"+rev+"?narHash=sha256-abc%3D#lib", pkg.Resolved)
+	require.Equal(t, rev, pkg.Version)
+	require.Equal(t, []string{"github:numtide/flake-utils"}, locked)
+
+	// Locked flakes aren't locked again, and local flakes aren't locked.
+	_, err = f.Resolve("github:numtide/flake-utils#lib")
+	require.NoError(t, err)
+	pkg, err = f.Resolve("path:./my-flake#hello")
+	require.NoError(t, err)
+	require.Empty(t, pkg.Resolved)
+	require.Len(t, locked, 1)
+
+	f.Freeze()
+	_, err = f.Resolve("github:nix-community/fenix#stable.toolchain")
+	require.Error(t, err, "a frozen lockfile can't lock new flakes")
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"strconv"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// migrations upgrade a lockfile's contents from one lockfile_version to the
+// next. migrations[i] upgrades version i+1 to version i+2, so the current
+// version is len(migrations)+1. To change the format, add a migration and
+// bump lockFileVersion.
+//
+// The lock history keeps a copy of the lockfile from before it was upgraded.
+var migrations = []func(f *File){}
+
+// migrate upgrades f, which was just read from disk, to lockFileVersion. It
+// refuses to read a lockfile from a newer version of devbox, because saving
+// it would drop what this version doesn't understand.
+func (f *File) migrate() error {
+	if f.LockFileVersion == "" {
+		// Lockfiles have always had a version, but treat one that's
+		// missing it as the first.
+		f.LockFileVersion = "1"
+	}
+	version, err := strconv.Atoi(f.LockFileVersion)
+	if err != nil || version < 1 {
+		return usererr.New("devbox.lock has an invalid lockfile_version %q.", f.LockFileVersion)
+	}
+	if current := len(migrations) + 1; version > current {
+		return usererr.New(
+			"devbox.lock has lockfile_version %d, but this version of devbox only supports up to %d. "+
+				"It was written by a newer version of devbox, which everyone on the project needs "+
+				"to use. Run `devbox version update` to upgrade.",
+			version, current,
+		)
+	}
+
+	from := f.LockFileVersion
+	for ; version <= len(migrations); version++ {
+		migrations[version-1](f)
+	}
+	f.LockFileVersion = strconv.Itoa(version)
+	if f.LockFileVersion != from {
+		f.migratedFrom = from
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const lockfileV1 = `{
+  "lockfile_version": "1",
+  "packages": {
+    "hello@2.12.1": {
+      "resolved": "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello",
+      "source": "devbox-search",
+      "version": "2.12.1",
+      "systems": {
+        "x86_64-linux": {
+          "store_path": "/nix/store/abc-hello-2.12.1"
+        }
+      }
+    }
+  }
+}
+`
+
+func TestLockFileVersionMatchesMigrations(t *testing.T) {
+	require.Equal(t, strconv.Itoa(len(migrations)+1), lockFileVersion,
+		"bump lockFileVersion when adding a migration")
+}
+
+// withMigration adds a migration from the current lockFileVersion to the
+// next one for the rest of the test.
+func withMigration(t *testing.T) {
+	t.Helper()
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(slices.Clone(saved), func(f *File) {
+		for _, pkg := range f.Packages {
+			pkg.Version += "-migrated"
+		}
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	withMigration(t)
+	project := &testProject{dir: t.TempDir()}
+	path := lockFilePath(project.ProjectDir())
+	require.NoError(t, os.WriteFile(path, []byte(lockfileV1), 0o644))
+
+	f, err := GetFile(project)
+	require.NoError(t, err)
+	require.Equal(t, "2", f.LockFileVersion)
+	require.Equal(t, "2.12.1-migrated", f.Packages["hello@2.12.1"].Version)
+
+	require.NoError(t, f.Save())
+	snapshots, err := Snapshots(project.ProjectDir())
+	require.NoError(t, err)
+	require.NotEmpty(t, snapshots)
+	old, err := os.ReadFile(snapshots[len(snapshots)-1].path)
+	require.NoError(t, err)
+	require.Equal(t, lockfileV1, string(old), "the lock history keeps the old lockfile")
+	require.NoFileExists(t, path+".v1.bak")
+
+	reopened, err := GetFile(project)
+	require.NoError(t, err)
+	require.Empty(t, reopened.migratedFrom, "the upgraded lockfile is saved")
+	require.Equal(t, f.Packages, reopened.Packages)
+}
+
+func TestMigrateWithHistory(t *testing.T) {
+	withMigration(t)
+	project := &testProject{dir: t.TempDir()}
+	path := lockFilePath(project.ProjectDir())
+	require.NoError(t, os.WriteFile(path, []byte(`{"lockfile_version": "1"}`), 0o644))
+	require.NoError(t, addSnapshot(project.ProjectDir(), path, time.Now().Add(-time.Hour)))
+	require.NoError(t, os.WriteFile(path, []byte(lockfileV1), 0o644))
+
+	f, err := GetFile(project)
+	require.NoError(t, err)
+	require.NoError(t, f.Save())
+	snapshots, err := Snapshots(project.ProjectDir())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	old, err := os.ReadFile(snapshots[1].path)
+	require.NoError(t, err)
+	require.Equal(t, lockfileV1, string(old), "the old lockfile is kept even though the history isn't empty")
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	project := &testProject{dir: t.TempDir()}
+	path := lockFilePath(project.ProjectDir())
+	require.NoError(t, os.WriteFile(path, []byte(lockfileV1), 0o644))
+
+	f, err := GetFile(project)
+	require.NoError(t, err)
+	require.Equal(t, lockFileVersion, f.LockFileVersion)
+	require.Empty(t, f.migratedFrom)
+	sysInfo := f.Packages["hello@2.12.1"].Systems["x86_64-linux"]
+	require.Equal(t, []Output{{Default: true, Name: "out", Path: "/nix/store/abc-hello-2.12.1"}}, sysInfo.Outputs)
+
+	require.NoError(t, f.Save())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, lockfileV1, string(data), "an unchanged lockfile isn't rewritten")
+}
+
+func TestMigrateFrozen(t *testing.T) {
+	withMigration(t)
+	project := &testProject{dir: t.TempDir()}
+	path := lockFilePath(project.ProjectDir())
+	require.NoError(t, os.WriteFile(path, []byte(lockfileV1), 0o644))
+
+	f, err := GetFile(project)
+	require.NoError(t, err)
+	f.Freeze()
+	require.NoError(t, f.Save(), "a frozen lockfile isn't upgraded, but that isn't an error")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, lockfileV1, string(data))
+}
+
+func TestMigrateRefusesNewerVersion(t *testing.T) {
+	project := &testProject{dir: t.TempDir()}
+	newer := strconv.Itoa(len(migrations) + 2)
+	require.NoError(t, os.WriteFile(lockFilePath(project.ProjectDir()),
+		[]byte(`{"lockfile_version": "`+newer+`", "packages": {}}`), 0o644))
+
+	_, err := GetFile(project)
+	require.ErrorContains(t, err, "newer version of devbox")
+
+	require.NoError(t, os.WriteFile(lockFilePath(project.ProjectDir()),
+		[]byte(`{"lockfile_version": "two", "packages": {}}`), 0o644))
+	_, err = GetFile(project)
+	require.ErrorContains(t, err, "invalid lockfile_version")
+}
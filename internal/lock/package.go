@@ -11,15 +11,25 @@ import (
 const (
 	nixpkgSource       string = "nixpkg"
 	devboxSearchSource string = "devbox-search"
+	// pinnedSource is a package that devbox.json pins to a nixpkgs commit.
+	pinnedSource string = "pinned"
 )
 
 type Package struct {
 	AllowInsecure bool   `json:"allow_insecure,omitempty"`
 	LastModified  string `json:"last_modified,omitempty"`
-	PluginVersion string `json:"plugin_version,omitempty"`
-	Resolved      string `json:"resolved,omitempty"`
-	Source        string `json:"source,omitempty"`
-	Version       string `json:"version,omitempty"`
+	// License holds the SPDX identifiers of the package's licenses, or
+	// nixpkgs license names for licenses that don't have one.
+	License       []string `json:"license,omitempty"`
+	PluginVersion string   `json:"plugin_version,omitempty"`
+	Resolved      string   `json:"resolved,omitempty"`
+	Source        string   `json:"source,omitempty"`
+	// SourceOf is what adds the package to the project: "devbox.json", a
+	// built-in plugin such as "plugin:php@8.1", or an included config such
+	// as "include:./plugins/redis". It's recorded the next time the lockfile
+	// is saved, and isn't used to install the package.
+	SourceOf []string `json:"source_of,omitempty"`
+	Version  string   `json:"version,omitempty"`
 	// Systems is keyed by the system name
 	Systems map[string]*SystemInfo `json:"systems,omitempty"`
 
@@ -29,6 +39,17 @@ type Package struct {
 type SystemInfo struct {
 	Outputs []Output `json:"outputs,omitempty"`
 
+	// Resolved and Version are set when devbox.json gives the package a
+	// different version on each system. They replace the package's
+	// Resolved and Version on this system.
+	Resolved string `json:"resolved,omitempty"`
+	Version  string `json:"version,omitempty"`
+
+	// Digest is the digest of the files that a runx: package installed on
+	// this system, which pins the release artifact that the tag pointed to
+	// when it was first installed. See RunXDigest.
+	Digest string `json:"digest,omitempty"`
+
 	// Legacy Format
 	StorePath             string `json:"store_path,omitempty"`
 	outputIsFromStorePath bool
@@ -50,6 +71,52 @@ type Output struct {
 	// Default indicates if Nix installs this output by
 	// default.
 	Default bool `json:"default,omitempty"`
+
+	// Hash is the NAR hash of the output's contents in the
+	// "sha256:<nix base32>" form, recorded the first time
+	// devbox installs the output on this system. devbox verify
+	// checks the Nix store against it.
+	Hash string `json:"hash,omitempty"`
+
+	// CA is the content address that Nix reports for the output, such as
+	// "fixed:r:sha256:...", if its store path is content-addressed. Such a
+	// path is computed from the output's contents instead of from the
+	// derivation that built it. It's recorded along with Hash.
+	CA string `json:"ca,omitempty"`
+}
+
+// IsPinned reports whether devbox.json pins the package to a nixpkgs commit.
+func (p *Package) IsPinned() bool {
+	return p != nil && p.Source == pinnedSource
+}
+
+// IsEvaluated reports whether Nix resolved the package by evaluating a
+// nixpkgs flake. Evaluating only gives the package's version, so its entry
+// doesn't have store paths for any system.
+func (p *Package) IsEvaluated() bool {
+	return p != nil && p.Source == nixpkgsEvalSource
+}
+
+// ResolvedFor returns the installable that the package is locked to on
+// system.
+func (p *Package) ResolvedFor(system string) string {
+	if info := p.Systems[system]; info != nil && info.Resolved != "" {
+		return info.Resolved
+	}
+	return p.Resolved
+}
+
+// VersionFor returns the version that the package is locked to on system.
+func (p *Package) VersionFor(system string) string {
+	if info := p.Systems[system]; info != nil && info.Version != "" {
+		return info.Version
+	}
+	return p.Version
+}
+
+// IsFromSearch reports whether the package was resolved by the search service.
+func (p *Package) IsFromSearch() bool {
+	return p != nil && p.Source == devboxSearchSource
 }
 
 func (p *Package) GetSource() string {
@@ -113,7 +180,17 @@ func (i *SystemInfo) Equals(other *SystemInfo) bool {
 		return i == other
 	}
 
-	return slices.Equal(i.Outputs, other.Outputs)
+	if i.Resolved != other.Resolved || i.Version != other.Version {
+		return false
+	}
+	// Hashes, content addresses and digests are recorded after resolving,
+	// so they don't make two system infos with the same store paths
+	// different.
+	return slices.EqualFunc(i.Outputs, other.Outputs, func(a, b Output) bool {
+		a.Hash, b.Hash = "", ""
+		a.CA, b.CA = "", ""
+		return a == b
+	})
 }
 
 // If we have a StorePath and no Outputs, we need to convert to the new format.
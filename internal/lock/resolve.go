@@ -6,28 +6,87 @@ package lock
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/boxcli/featureflag"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/searcher"
 	"golang.org/x/sync/errgroup"
 )
 
+// defaultResolveConcurrency is how many packages FetchResolvedPackages
+// resolves at the same time, unless DEVBOX_RESOLVE_CONCURRENCY says otherwise.
+const defaultResolveConcurrency = 8
+
+// ResolveConcurrency returns the maximum number of packages that devbox
+// resolves at the same time.
+func ResolveConcurrency() int {
+	env := os.Getenv(envir.DevboxResolveConcurrency)
+	if env == "" {
+		return defaultResolveConcurrency
+	}
+	n, err := strconv.Atoi(env)
+	if err != nil || n < 1 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxResolveConcurrency, env)
+		return defaultResolveConcurrency
+	}
+	return n
+}
+
+// FetchResolvedPackages is FetchResolvedPackage for many packages at once.
+// It resolves up to ResolveConcurrency packages concurrently and returns the
+// resolutions keyed by package. The first error cancels the resolutions that
+// haven't finished yet. Packages that the search service resolves are fetched
+// in batches first, with PrefetchResolutions.
+func (f *File) FetchResolvedPackages(ctx context.Context, pkgs []string) (map[string]*Package, error) {
+	defer debug.FunctionTimer().End()
+	f.PrefetchResolutions(ctx, pkgs)
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(ResolveConcurrency())
+
+	var mu sync.Mutex
+	resolved := make(map[string]*Package, len(pkgs))
+	for _, pkg := range lo.Uniq(pkgs) {
+		group.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			p, err := f.FetchResolvedPackage(ctx, pkg)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			resolved[pkg] = p
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
 // FetchResolvedPackage fetches a resolution but does not write it to the lock
 // struct. This allows testing new versions of packages without writing to the
 // lock. This is useful to avoid changing nixpkgs commit hashes when version has
 // not changed. This can happen when doing `devbox update` and search has
 // a newer hash than the lock file but same version. In that case we don't want
 // to update because it would be slow and wasteful.
-func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
+func (f *File) FetchResolvedPackage(ctx context.Context, pkg string) (*Package, error) {
 	if pkgtype.IsFlake(pkg) {
 		return nil, nil
 	}
@@ -36,9 +95,39 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 	if version == "" {
 		return nil, usererr.New("No version specified for %q.", name)
 	}
+	if pkgtype.IsEcosystem(pkg) {
+		// Ecosystem packages resolve like the nixpkgs package that
+		// builds them, but are locked under the name in devbox.json.
+		name, _ = pkgtype.EcosystemAttrPath(name)
+	}
 
+	if versions, ok := searcher.ParseSystemVersions(version); ok && !pkgtype.IsRunX(pkg) {
+		return f.resolveSystemVersions(ctx, name, versions)
+	}
+	if commit := f.pinnedCommit(pkg); commit != "" && !pkgtype.IsRunX(pkg) {
+		return pinnedPackage(name, version, commit), nil
+	}
+	resolver, err := f.resolver()
+	if err != nil {
+		return nil, err
+	}
+	if _, isSearch := resolver.(*searchResolver); isSearch {
+		if envir.IsOffline() {
+			return nil, usererr.New(
+				"Devbox can't resolve %s because it's offline, so only packages that are already "+
+					"in devbox.lock can be used. Run `devbox install` while online to lock it.", pkg,
+			)
+		}
+		if err := f.checkRegistry(); err != nil {
+			return nil, err
+		}
+	}
+
+	if searcher.IsVersionConstraint(version) && !pkgtype.IsRunX(pkg) {
+		return f.resolveVersionRange(ctx, resolver, name, version)
+	}
 	if pkgtype.IsRunX(pkg) {
-		ref, err := ResolveRunXPackage(context.TODO(), pkg)
+		ref, err := ResolveRunXPackage(ctx, pkg)
 		if err != nil {
 			return nil, err
 		}
@@ -47,18 +136,23 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 			Version:  ref.Version,
 		}, nil
 	}
-	if featureflag.ResolveV2.Enabled() {
-		return resolveV2(context.TODO(), name, version)
-	}
-
-	packageVersion, err := searcher.Client().Resolve(name, version)
-	if err != nil {
-		return nil, errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
+	if _, isSearch := resolver.(*searchResolver); !isSearch {
+		// Only the search service's resolutions are cached. A package
+		// index is as fast to read as the cache, and caching it would
+		// hide changes to the index.
+		return resolver.Resolve(ctx, name, version)
 	}
+	return f.cachedResolve(name, version, func() (*Package, error) {
+		return resolver.Resolve(ctx, name, version)
+	})
+}
 
+// packageFromV1 is the lock entry of a /v1/resolve response.
+func (f *File) packageFromV1(ctx context.Context, name string, packageVersion *searcher.PackageVersion) (*Package, error) {
 	sysInfos := map[string]*SystemInfo{}
 	if featureflag.RemoveNixpkgs.Enabled() {
-		sysInfos, err = buildLockSystemInfos(packageVersion)
+		var err error
+		sysInfos, err = buildLockSystemInfos(ctx, packageVersion, f.BinaryCaches())
 		if err != nil {
 			return nil, err
 		}
@@ -73,32 +167,134 @@ func (f *File) FetchResolvedPackage(pkg string) (*Package, error) {
 	}
 
 	return &Package{
-		LastModified: time.Unix(int64(packageInfo.LastUpdated), 0).UTC().
-			Format(time.RFC3339),
+		LastModified: formatLastModified(time.Unix(int64(packageInfo.LastUpdated), 0)),
 		Resolved: fmt.Sprintf(
 			"github:NixOS/nixpkgs/%s#%s",
 			packageInfo.CommitHash,
 			packageInfo.AttrPaths[0],
 		),
+		License: packageInfo.License,
 		Version: packageInfo.Version,
 		Source:  devboxSearchSource,
 		Systems: sysInfos,
 	}, nil
 }
 
-func resolveV2(ctx context.Context, name, version string) (*Package, error) {
-	resolved, err := searcher.Client().ResolveV2(ctx, name, version)
-	if errors.Is(err, searcher.ErrNotFound) {
-		return nil, redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
+// pinnedPackage is the lock entry of a package that devbox.json pins to a
+// nixpkgs commit. The search API isn't asked, so the name must be the
+// package's attribute path in nixpkgs, and the version is what devbox.json
+// says.
+func pinnedPackage(name, version, commit string) *Package {
+	return &Package{
+		Resolved: pinnedRef(name, commit),
+		Version:  version,
+		Source:   pinnedSource,
 	}
-	if err != nil {
-		return nil, err
+}
+
+func pinnedRef(name, commit string) string {
+	return fmt.Sprintf("github:NixOS/nixpkgs/%s#%s", commit, name)
+}
+
+// pinnedCommit is the commit that devbox.json pins pkg to, or "". A File
+// that isn't read from a project has no pins.
+func (f *File) pinnedCommit(pkg string) string {
+	if f.devboxProject == nil {
+		return ""
+	}
+	return f.PinnedCommit(pkg)
+}
+
+// BinaryCaches returns the binary caches to look store paths up in, in order:
+// the project's binary_caches, and then the public Nix cache unless the
+// network policy disables it.
+func (f *File) BinaryCaches() []string {
+	var caches []string
+	if f.devboxProject != nil {
+		caches = slices.Clone(f.devboxProject.BinaryCaches())
+	}
+	if netpolicy.Allowed(netpolicy.Cache) {
+		caches = append(caches, netpolicy.URL(netpolicy.Cache))
+	}
+	return caches
+}
+
+// searchPublicKeys are the keys that the search service's resolutions must be
+// signed by, if the project requires signed resolutions.
+func (f *File) searchPublicKeys() []nix.PublicKey {
+	if f.devboxProject == nil {
+		return nil
+	}
+	return f.devboxProject.SearchPublicKeys()
+}
+
+// checkRegistry returns an error if the project isn't allowed to resolve
+// packages with the search service. It's checked before every request to the
+// service, so that resolvers that don't use it aren't affected.
+func (f *File) checkRegistry() error {
+	if f.devboxProject == nil {
+		return nil
 	}
+	return f.devboxProject.CheckRegistry(searcher.Host())
+}
 
+// pinChanged reports whether the commit that devbox.json pins pkg to, if
+// any, isn't the one that its lock entry is resolved to.
+func (f *File) pinChanged(pkg string, entry *Package) bool {
+	if pkgtype.IsFlake(pkg) || pkgtype.IsRunX(pkg) {
+		return false
+	}
+	commit := f.pinnedCommit(pkg)
+	if commit == "" {
+		return entry.IsPinned()
+	}
+	name, _, _ := searcher.ParseVersionedPackage(pkg)
+	return entry.Resolved != pinnedRef(name, commit)
+}
+
+// withSuggestions adds a "did you mean" hint to notFound, the error for the
+// resolver not finding name@version, when it has suggestions of packages with
+// similar names. If name is the only suggestion, the hint is that only the
+// version wasn't found. Otherwise, name may be a command rather than a
+// package, so the hint names the packages that providers returns, which
+// install a program called name, before any similar names.
+func withSuggestions(notFound error, name, version string, suggestions []string, providers func() []string) error {
+	if slices.Equal(suggestions, []string{name}) {
+		return usererr.WithUserMessage(notFound,
+			"Devbox couldn't find version %s of %s. Run `devbox search %s` to list its versions.",
+			version, name, name)
+	}
+	switch names := providers(); len(names) {
+	case 0:
+	case 1:
+		return usererr.WithUserMessage(notFound,
+			"Devbox couldn't find a package named %s, but %s has a %s command. Did you mean %s?",
+			name, names[0], name, names[0])
+	default:
+		return usererr.WithUserMessage(notFound,
+			"Devbox couldn't find a package named %s, but these packages have a %s command: %s. "+
+				"Run `devbox search --provides %s` to compare them.",
+			name, name, strings.Join(names, ", "), name)
+	}
+	switch len(suggestions) {
+	case 0:
+		return notFound
+	case 1:
+		return usererr.WithUserMessage(notFound,
+			"Devbox couldn't find a package named %s. Did you mean %s?", name, suggestions[0])
+	}
+	return usererr.WithUserMessage(notFound,
+		"Devbox couldn't find a package named %s. Did you mean one of %s?", name, strings.Join(suggestions, ", "))
+}
+
+// packageFromV2 is the lock entry of a /v2/resolve response. Systems that
+// don't have outputs are left out.
+func packageFromV2(resolved *searcher.ResolveResponse) *Package {
 	// /v2/resolve never returns a success with no systems.
 	sysPkg, _ := selectForSystem(resolved.Systems)
 	pkg := &Package{
-		LastModified: sysPkg.LastUpdated.Format(time.RFC3339),
+		LastModified: formatLastModified(sysPkg.LastUpdated),
+		License:      resolved.License,
 		Resolved:     sysPkg.FlakeInstallable.String(),
 		Source:       devboxSearchSource,
 		Version:      resolved.Version,
@@ -136,7 +332,7 @@ func resolveV2(ctx context.Context, name, version string) (*Package, error) {
 			}
 		}
 	}
-	return pkg, nil
+	return pkg
 }
 
 func selectForSystem[V any](systems map[string]V) (v V, err error) {
@@ -152,32 +348,48 @@ func selectForSystem[V any](systems map[string]V) (v V, err error) {
 	return v, redact.Errorf("no systems found")
 }
 
-func buildLockSystemInfos(pkg *searcher.PackageVersion) (map[string]*SystemInfo, error) {
+// storePathFromHashPart looks up a store path in a binary cache. Tests replace
+// it to avoid running Nix.
+var storePathFromHashPart = nix.StorePathFromHashPart
+
+// buildLockSystemInfos looks up the store path of each system's package in
+// caches, in order. Systems whose store path isn't in any of them are left
+// out, and install via the slow path. The lookups share a concurrency limit
+// with every other package that's being resolved.
+func buildLockSystemInfos(ctx context.Context, pkg *searcher.PackageVersion, caches []string) (map[string]*SystemInfo, error) {
 	// guard against missing search data
 	systems := lo.PickBy(pkg.Systems, func(sysName string, sysInfo searcher.PackageInfo) bool {
 		return sysInfo.StoreHash != "" && sysInfo.StoreName != ""
 	})
 
-	group, ctx := errgroup.WithContext(context.Background())
+	group, ctx := errgroup.WithContext(ctx)
+	storePathProgress.add(len(systems))
 
 	var storePathLock sync.RWMutex
 	sysStorePaths := map[string]string{}
-	for _sysName, _sysInfo := range systems {
-		sysName := _sysName // capture range variable
-		sysInfo := _sysInfo // capture range variable
-
+	for sysName, sysInfo := range systems {
 		group.Go(func() error {
-			// We should use devpkg.BinaryCache here, but it'll cause a circular reference
-			// Just hardcoding for now. Maybe we should move that to nix.DefaultBinaryCache?
-			path, err := nix.StorePathFromHashPart(ctx, sysInfo.StoreHash, "https://cache.nixos.org")
-			if err != nil {
+			defer storePathProgress.finish()
+			var path string
+			for _, cache := range caches {
+				var err error
+				path, err = lookUpStorePath(ctx, sysInfo.StoreHash, cache)
+				if err == nil {
+					break
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				// Should we report this to sentry to collect data?
 				debug.Log(
-					"Failed to resolve store path for %s with storeHash %s. Error is %s.\n",
+					"Failed to resolve store path for %s with storeHash %s in %s. Error is %s.\n",
 					sysName,
 					sysInfo.StoreHash,
+					cache,
 					err,
 				)
+			}
+			if path == "" {
 				// Instead of erroring, we can just skip this package. It can install via the slow path.
 				return nil
 			}
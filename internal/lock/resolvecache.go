@@ -0,0 +1,113 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/xdg"
+	"go.jetpack.io/pkg/filecache"
+)
+
+// defaultResolveCacheTTL is how long a resolution from the search service is
+// reused, unless DEVBOX_RESOLVE_CACHE_TTL says otherwise. It's short so that
+// devbox update still sees new versions the same day.
+const defaultResolveCacheTTL = time.Hour
+
+var resolveCache = filecache.New(
+	"devbox/resolve",
+	filecache.WithCacheDir[*Package](xdg.CacheSubpath("")),
+)
+
+// ClearResolveCache removes every cached resolution, so that the next
+// resolution of each package asks the search service again.
+func ClearResolveCache() error {
+	return resolveCache.Clear()
+}
+
+// ResolveCacheTTL returns how long devbox caches resolutions. 0 means they
+// aren't cached.
+func ResolveCacheTTL() time.Duration {
+	env := os.Getenv(envir.DevboxResolveCacheTTL)
+	if env == "" {
+		return defaultResolveCacheTTL
+	}
+	ttl, err := time.ParseDuration(env)
+	if err != nil || ttl < 0 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxResolveCacheTTL, env)
+		return defaultResolveCacheTTL
+	}
+	return ttl
+}
+
+// cachedResolve returns the cached resolution of name@version, or calls
+// resolve and caches what it returns. A cache that can't be read or written
+// only costs a request to the search service, so its errors are ignored.
+func (f *File) cachedResolve(name, version string, resolve func() (*Package, error)) (*Package, error) {
+	ttl := ResolveCacheTTL()
+	if ttl == 0 {
+		return resolve()
+	}
+
+	key := f.resolveCacheKey(name, version)
+	if pkg, err := resolveCache.Get(key); err == nil && pkg != nil {
+		debug.Log("using cached resolution of %s@%s", name, version)
+		return pkg, nil
+	} else if err != nil && !filecache.IsCacheMiss(err) {
+		debug.Log("reading cached resolution of %s@%s: %v", name, version, err)
+	}
+
+	pkg, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	if !pkg.IsFromSearch() {
+		// The search service was unavailable and the package was
+		// resolved without it, so resolve it again once it's back.
+		return pkg, nil
+	}
+	if err := resolveCache.Set(key, pkg, ttl); err != nil {
+		debug.Log("caching resolution of %s@%s: %v", name, version, err)
+	}
+	return pkg, nil
+}
+
+// isResolutionCached reports whether cachedResolve has a resolution of
+// name@version that it can return without calling resolve.
+func (f *File) isResolutionCached(name, version string) bool {
+	if ResolveCacheTTL() == 0 {
+		return false
+	}
+	pkg, err := resolveCache.Get(f.resolveCacheKey(name, version))
+	return err == nil && pkg != nil
+}
+
+var unsafeCacheKeyChars = regexp.MustCompile(`[^\w.-]`)
+
+// resolveCacheKey identifies a resolution of name@version on this system. It
+// also hashes everything else that changes what the resolution is: the search
+// host, the binary caches that store paths are looked up in, the keys that
+// resolutions must be signed by and the feature flags that pick the API.
+func (f *File) resolveCacheKey(name, version string) string {
+	settings := strings.Join([]string{
+		searcher.Host(),
+		strings.Join(f.BinaryCaches(), " "),
+		strings.Join(lo.Map(f.searchPublicKeys(), func(k nix.PublicKey, _ int) string { return k.Name }), " "),
+		strconv.FormatBool(featureflag.ResolveV2.Enabled()),
+		strconv.FormatBool(featureflag.RemoveNixpkgs.Enabled()),
+	}, "\n")
+	key := unsafeCacheKeyChars.ReplaceAllString(name+"@"+version, "_")
+	return key + "-" + nix.System() + "-" + cachehash.Bytes6([]byte(settings))
+}
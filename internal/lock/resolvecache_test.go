@@ -0,0 +1,63 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestResolveCacheTTL(t *testing.T) {
+	for env, want := range map[string]time.Duration{
+		"":      defaultResolveCacheTTL,
+		"10m":   10 * time.Minute,
+		"0":     0,
+		"-1h":   defaultResolveCacheTTL,
+		"never": defaultResolveCacheTTL,
+	} {
+		t.Setenv(envir.DevboxResolveCacheTTL, env)
+		require.Equal(t, want, ResolveCacheTTL(), "%s=%q", envir.DevboxResolveCacheTTL, env)
+	}
+}
+
+func TestResolveCacheKey(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}}
+
+	key := f.resolveCacheKey("nodejs", ">=18 <21")
+	require.Regexp(t, `^nodejs___18__21-`+regexp.QuoteMeta(nix.System())+`-[0-9a-f]{6}$`, key)
+	require.Equal(t, key, f.resolveCacheKey("nodejs", ">=18 <21"))
+
+	withCache := &File{devboxProject: &testProject{
+		dir:    t.TempDir(),
+		caches: []string{"https://cache.acme.internal"},
+	}}
+	require.NotEqual(t, key, withCache.resolveCacheKey("nodejs", ">=18 <21"),
+		"binary caches change the store paths of a resolution")
+
+	t.Setenv(envir.DevboxSearchHost, "https://search.acme.internal")
+	require.NotEqual(t, key, f.resolveCacheKey("nodejs", ">=18 <21"))
+}
+
+func TestCachedResolveDisabled(t *testing.T) {
+	t.Setenv(envir.DevboxResolveCacheTTL, "0")
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}}
+
+	calls := 0
+	resolve := func() (*Package, error) {
+		calls++
+		return &Package{Version: "2.12.1"}, nil
+	}
+	for range 2 {
+		pkg, err := f.cachedResolve("hello", "2.12.1", resolve)
+		require.NoError(t, err)
+		require.Equal(t, "2.12.1", pkg.Version)
+	}
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,343 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/featureflag"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/internal/ux"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// Resolver resolves a package name and version from devbox.json to the lock
+// entry that installs it. FetchResolvedPackage asks the project's resolver for
+// every package that isn't a flake, a RunX package or pinned to a nixpkgs
+// commit, after it has picked a version from a version range.
+type Resolver interface {
+	// Resolve returns the lock entry of name@version, where version is a
+	// plain version, such as "1.22" or "latest", that may have a ?channel=
+	// parameter. If there's no such version, the error wraps
+	// nix.ErrPackageNotFound.
+	Resolve(ctx context.Context, name, version string) (*Package, error)
+
+	// Versions lists the versions of the package with the given name that
+	// Resolve can resolve, to pick one from a version range.
+	Versions(ctx context.Context, name string) ([]string, error)
+}
+
+// The resolver backends that devbox.json can select.
+const (
+	// ResolverSearch asks the search service, which is the default.
+	ResolverSearch = "search"
+	// ResolverIndex reads a package index file, without any network.
+	ResolverIndex = "index"
+	// ResolverNixpkgs evaluates packages in a nixpkgs flake with Nix.
+	ResolverNixpkgs = "nixpkgs"
+)
+
+// defaultNixpkgsRef is the nixpkgs flake that ResolverNixpkgs evaluates
+// unless devbox.json says otherwise.
+const defaultNixpkgsRef = "github:NixOS/nixpkgs/nixpkgs-unstable"
+
+// nixpkgsEvalSource is a package that Nix resolved by evaluating a nixpkgs
+// flake.
+const nixpkgsEvalSource = "nixpkgs-eval"
+
+// ResolverConfig is the resolver that a project's devbox.json selects.
+type ResolverConfig struct {
+	// Backend is one of the Resolver* backends, or a backend that's
+	// registered with RegisterResolver. "" is ResolverSearch.
+	Backend string
+	// Index is the absolute path of the package index that ResolverIndex
+	// reads.
+	Index string
+	// Nixpkgs is the flake reference that ResolverNixpkgs evaluates.
+	Nixpkgs string
+	// Options are settings for a registered backend.
+	Options map[string]string
+}
+
+// ResolverFactory creates the resolver of a project.
+type ResolverFactory func(cfg ResolverConfig) (Resolver, error)
+
+var (
+	resolversMu sync.Mutex
+	resolvers   = map[string]ResolverFactory{}
+)
+
+// RegisterResolver adds a resolver backend that devbox.json can select by
+// name, so that a build of devbox can resolve packages with its own service.
+// Register backends in an init function. Like sql.Register, it panics if a
+// backend with the same name is already registered.
+func RegisterResolver(backend string, factory ResolverFactory) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	switch backend {
+	case "", ResolverSearch, ResolverIndex, ResolverNixpkgs:
+		panic(fmt.Sprintf("lock: resolver backend %q is built in", backend))
+	}
+	if _, ok := resolvers[backend]; ok {
+		panic(fmt.Sprintf("lock: resolver backend %q is already registered", backend))
+	}
+	resolvers[backend] = factory
+}
+
+// resolver returns the project's resolver. DEVBOX_PACKAGE_INDEX selects the
+// index backend regardless of devbox.json, so that an air-gapped machine
+// never reaches for the network.
+func (f *File) resolver() (Resolver, error) {
+	if path := searcher.PackageIndexPath(); path != "" {
+		return &indexResolver{path: path}, nil
+	}
+	var cfg ResolverConfig
+	if f.devboxProject != nil {
+		cfg = f.devboxProject.ResolverConfig()
+	}
+
+	switch cfg.Backend {
+	case "", ResolverSearch:
+		return &searchResolver{f: f}, nil
+	case ResolverIndex:
+		return &indexResolver{path: cfg.Index}, nil
+	case ResolverNixpkgs:
+		return &nixpkgsResolver{ref: cmp.Or(cfg.Nixpkgs, defaultNixpkgsRef)}, nil
+	}
+	resolversMu.Lock()
+	factory, ok := resolvers[cfg.Backend]
+	resolversMu.Unlock()
+	if !ok {
+		return nil, usererr.New(
+			"resolver.backend in devbox.json is %q, which isn't a resolver that this build of devbox has. "+
+				"Use %q, %q or %q.", cfg.Backend, ResolverSearch, ResolverIndex, ResolverNixpkgs)
+	}
+	return factory(cfg)
+}
+
+// searchResolver resolves packages with the search service. While the
+// service is unavailable, it falls back to evaluating the project's nixpkgs.
+type searchResolver struct {
+	f *File
+}
+
+func (r *searchResolver) Resolve(ctx context.Context, name, version string) (*Package, error) {
+	f := r.f
+	if err := f.checkRegistry(); err != nil {
+		return nil, err
+	}
+	client := searcher.Client().WithPublicKeys(f.searchPublicKeys())
+	if featureflag.ResolveV2.Enabled() {
+		if resolved := f.prefetched.take(name, version); resolved != nil {
+			return packageFromV2(resolved), nil
+		}
+		resolved, err := client.ResolveV2(ctx, name, version)
+		if errors.Is(err, searcher.ErrNotFound) {
+			notFound := redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
+			return nil, withSuggestions(notFound, name, version, client.Suggest(ctx, name), func() []string {
+				return client.ProviderNames(ctx, name)
+			})
+		}
+		if fallback := r.fallback(err); fallback != nil {
+			return fallback.Resolve(ctx, name, version)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return packageFromV2(resolved), nil
+	}
+
+	packageVersion, err := client.ResolveContext(ctx, name, version)
+	if errors.Is(err, searcher.ErrNotFound) {
+		notFound := errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
+		return nil, withSuggestions(notFound, name, version, client.Suggest(ctx, name), func() []string {
+			return client.ProviderNames(ctx, name)
+		})
+	}
+	if fallback := r.fallback(err); fallback != nil {
+		return fallback.Resolve(ctx, name, version)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
+	}
+	return f.packageFromV1(ctx, name, packageVersion)
+}
+
+func (r *searchResolver) Versions(ctx context.Context, name string) ([]string, error) {
+	if err := r.f.checkRegistry(); err != nil {
+		return nil, err
+	}
+	versions, err := packageVersions(ctx, name)
+	if fallback := r.fallback(err); fallback != nil {
+		return fallback.Versions(ctx, name)
+	}
+	return versions, err
+}
+
+// fallbackWarning makes devbox warn only once that it's resolving packages
+// without the search service.
+var fallbackWarning sync.Once
+
+// fallback returns the resolver to use instead of the search service when a
+// request to it failed with err, or nil if devbox shouldn't fall back. An
+// outage of the search service shouldn't stop packages from being added, so
+// devbox resolves them from the nixpkgs commit in devbox.json, which Nix
+// usually has already, the same way the "nixpkgs" backend does. Projects that
+// require signed resolutions never fall back: evaluating nixpkgs locally
+// can't be checked against the search service's keys.
+func (r *searchResolver) fallback(err error) Resolver {
+	if !searcher.IsUnavailable(err) || len(r.f.searchPublicKeys()) > 0 {
+		return nil
+	}
+	debug.Log("resolver: search service is unavailable: %v", err)
+
+	ref := defaultNixpkgsRef
+	if r.f.devboxProject != nil && r.f.NixPkgsCommitHash() != "" {
+		ref = "github:NixOS/nixpkgs/" + r.f.NixPkgsCommitHash()
+	}
+	fallbackWarning.Do(func() {
+		ux.Fwarning(os.Stderr, "The Devbox search service is unavailable, so Devbox is resolving packages "+
+			"by evaluating %s with Nix instead. This is slower, and only finds the version of each "+
+			"package that's in that nixpkgs.\n", ref)
+	})
+	return &nixpkgsResolver{ref: ref}
+}
+
+// indexResolver resolves packages from a package index file, which has the
+// /v2/resolve response of each package version.
+type indexResolver struct {
+	path string
+}
+
+func (r *indexResolver) Resolve(_ context.Context, name, version string) (*Package, error) {
+	index, err := searcher.LoadPackageIndex(r.path)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := r.resolve(index, name, version)
+	if errors.Is(err, searcher.ErrNotFound) {
+		notFound := redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound)
+		return nil, withSuggestions(notFound, name, version, index.Suggest(name), func() []string {
+			return index.ProviderNames(name)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return packageFromV2(resolved), nil
+}
+
+// resolve resolves name@version in index. An index doesn't know which channel
+// its packages are from, so it only resolves the version.
+func (r *indexResolver) resolve(index *searcher.PackageIndex, name, version string) (*searcher.ResolveResponse, error) {
+	unchanneled, _, err := searcher.ParseChannel(version)
+	if err != nil {
+		return nil, redact.Errorf("%s@%s: %w", name, version, err)
+	}
+	return index.Resolve(name, unchanneled)
+}
+
+func (r *indexResolver) Versions(_ context.Context, name string) ([]string, error) {
+	index, err := searcher.LoadPackageIndex(r.path)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := index.Versions(name)
+	if errors.Is(err, searcher.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(versions))
+	for i, v := range versions {
+		result[i] = v.Version
+	}
+	return result, nil
+}
+
+// evalNixpkgsPackage evaluates the version and licenses of the package that
+// installable refers to. Tests replace it to avoid running Nix.
+var evalNixpkgsPackage = func(ctx context.Context, installable string) (string, []string, error) {
+	version, err := nix.PackageVersion(ctx, installable)
+	if err != nil {
+		return "", nil, err
+	}
+	licenses, err := nix.PackageLicenses(ctx, installable)
+	if err != nil {
+		// The license is only informational, so a package without a
+		// readable one still resolves.
+		debug.Log("resolver: %v", err)
+	}
+	return version, licenses, nil
+}
+
+// nixpkgsResolver resolves packages by evaluating them in a nixpkgs flake. A
+// nixpkgs revision has one version of each package, so it can only resolve
+// the version that the flake currently has.
+type nixpkgsResolver struct {
+	ref string
+}
+
+func (r *nixpkgsResolver) Resolve(ctx context.Context, name, version string) (*Package, error) {
+	version, channel, err := searcher.ParseChannel(version)
+	if err != nil {
+		return nil, usererr.New("Package %s@%s: %v.", name, version, err)
+	}
+	ref := r.ref
+	if channel != "" {
+		ref = "github:NixOS/nixpkgs/" + channel
+	}
+	metadata, err := lockFlakeRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	installable := flake.Installable{Ref: metadata.Locked, AttrPath: name}.String()
+	evaluated, licenses, err := evalNixpkgsPackage(ctx, installable)
+	if err != nil {
+		debug.Log("resolver: %v", err)
+		return nil, redact.Errorf("%s isn't in %s: %w", name, ref, nix.ErrPackageNotFound)
+	}
+	if !versionMatches(evaluated, version) {
+		return nil, usererr.WithUserMessage(
+			redact.Errorf("%s@%s: %w", name, version, nix.ErrPackageNotFound),
+			"%s has %s %s, which isn't version %s. Devbox resolves packages from that nixpkgs, "+
+				"which has one version of each package.", ref, name, evaluated, version)
+	}
+	return &Package{
+		LastModified: formatLastModified(metadata.LastModified),
+		License:      licenses,
+		Resolved:     installable,
+		Source:       nixpkgsEvalSource,
+		Version:      evaluated,
+	}, nil
+}
+
+func (r *nixpkgsResolver) Versions(ctx context.Context, name string) ([]string, error) {
+	pkg, err := r.Resolve(ctx, name, "latest")
+	if errors.Is(err, nix.ErrPackageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string{pkg.Version}, nil
+}
+
+// versionMatches reports whether a resolved version is what version asks for,
+// the same way the search service matches versions: "latest" matches any
+// version, and "3.12" matches "3.12" and "3.12.2".
+func versionMatches(resolved, version string) bool {
+	return version == "latest" || resolved == version || strings.HasPrefix(resolved, version+".")
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+func TestNixpkgsResolver(t *testing.T) {
+	rev := "75a52265bda7fd25e06e3a67dee3f0354e73243c"
+	lockRef := lockFlakeRef
+	evalPackage := evalNixpkgsPackage
+	t.Cleanup(func() {
+		lockFlakeRef = lockRef
+		evalNixpkgsPackage = evalPackage
+	})
+	var locked []string
+	lockFlakeRef = func(_ context.Context, ref string) (nix.FlakeMetadata, error) {
+		locked = append(locked, ref)
+		return nix.FlakeMetadata{
+			Locked:       flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: rev},
+			LastModified: time.Date(2024, 3, 21, 9, 22, 22, 0, time.UTC),
+		}, nil
+	}
+	evalNixpkgsPackage = func(_ context.Context, installable string) (string, []string, error) {
+		if installable != "github:NixOS/nixpkgs/"+rev+"#hello" {
+			return "", nil, os.ErrNotExist
+		}
+		return "2.12.1", []string{"GPL-3.0-or-later"}, nil
+	}
+	f := &File{
+		devboxProject: &testProject{dir: t.TempDir(), resolver: ResolverConfig{Backend: ResolverNixpkgs}},
+		Packages:      map[string]*Package{},
+	}
+
+	pkg, err := f.Resolve("hello@2.12")
+	require.NoError(t, err)
+	require.Equal(t, "2.12.1", pkg.Version)
+	require.Equal(t, nixpkgsEvalSource, pkg.Source)
+	require.Equal(t, "github:NixOS/nixpkgs/"+rev+"#hello", pkg.Resolved)
+	require.Equal(t, []string{"GPL-3.0-or-later"}, pkg.License)
+	require.Equal(t, []string{defaultNixpkgsRef}, locked)
+
+	_, err = f.Resolve("hello@2.10")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+	_, ok := usererr.Extract(err)
+	require.True(t, ok, "a version that nixpkgs doesn't have is a user error")
+
+	_, err = f.Resolve("helo@latest")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+
+	_, err = f.Resolve("hello@latest?channel=nixos-24.05")
+	require.NoError(t, err)
+	require.Equal(t, "github:NixOS/nixpkgs/nixos-24.05", locked[len(locked)-1])
+}
+
+func TestSearchResolverFallsBackToNixpkgs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+	t.Setenv(envir.DevboxSearchRetries, "0")
+	t.Setenv(envir.DevboxResolveCacheTTL, "1h")
+
+	rev := "75a52265bda7fd25e06e3a67dee3f0354e73243c"
+	lockRef := lockFlakeRef
+	evalPackage := evalNixpkgsPackage
+	t.Cleanup(func() {
+		lockFlakeRef = lockRef
+		evalNixpkgsPackage = evalPackage
+	})
+	var locked []string
+	lockFlakeRef = func(_ context.Context, ref string) (nix.FlakeMetadata, error) {
+		locked = append(locked, ref)
+		return nix.FlakeMetadata{Locked: flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: rev}}, nil
+	}
+	evalNixpkgsPackage = func(context.Context, string) (string, []string, error) {
+		return "2.12.1", nil, nil
+	}
+
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{}}
+	pkg, err := f.Resolve("hello@latest")
+	require.NoError(t, err, "an outage of the search service falls back to nixpkgs")
+	require.Equal(t, nixpkgsEvalSource, pkg.Source)
+	require.Equal(t, "2.12.1", pkg.Version)
+	require.Equal(t, []string{defaultNixpkgsRef}, locked)
+
+	pkg, err = f.FetchResolvedPackage(context.Background(), "hello@latest")
+	require.NoError(t, err)
+	require.Equal(t, nixpkgsEvalSource, pkg.Source)
+	require.Len(t, locked, 2, "resolutions without the search service aren't cached")
+
+	signed := &File{
+		devboxProject: &testProject{dir: t.TempDir(), keys: []nix.PublicKey{{Name: "search.acme.internal-1"}}},
+		Packages:      map[string]*Package{},
+	}
+	_, err = signed.Resolve("hello@latest")
+	require.Error(t, err, "projects that require signed resolutions don't fall back")
+	require.Len(t, locked, 2)
+}
+
+func TestCheckRegistry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+	t.Setenv(envir.DevboxSearchRetries, "0")
+	t.Setenv(envir.DevboxResolveCacheTTL, "1h")
+
+	rejected := usererr.New("Resolving packages with %s isn't allowed", server.URL)
+	f := &File{devboxProject: &testProject{dir: t.TempDir(), registryErr: rejected}, Packages: map[string]*Package{}}
+	f.PrefetchResolutions(context.Background(), []string{"hello@2.12.1", "go@1.22"})
+	_, err := f.Resolve("hello@2.12.1")
+	require.ErrorIs(t, err, rejected)
+	_, err = f.Resolve("go@^1.21")
+	require.ErrorIs(t, err, rejected)
+	require.Zero(t, requests.Load(), "a registry that isn't allowed isn't asked")
+
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [{
+		"name": "hello",
+		"version": "2.12.1",
+		"systems": {
+			"x86_64-linux": {
+				"flake_installable": {
+					"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+					"attr_path": "hello"
+				}
+			}
+		}
+	}]}`), 0o644))
+	f.devboxProject = &testProject{
+		dir:         t.TempDir(),
+		resolver:    ResolverConfig{Backend: ResolverIndex, Index: index},
+		registryErr: rejected,
+	}
+	_, err = f.Resolve("hello@2.12.1")
+	require.NoError(t, err, "the registry policy doesn't apply to other resolvers")
+}
+
+func TestIndexResolverFromConfig(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [{
+		"name": "hello",
+		"version": "2.12.1",
+		"programs": ["hello", "greet"],
+		"systems": {
+			"x86_64-linux": {
+				"flake_installable": {
+					"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+					"attr_path": "hello"
+				},
+				"outputs": [{"name": "out", "path": "/nix/store/abc-hello-2.12.1", "default": true}]
+			}
+		}
+	}]}`), 0o644))
+	f := &File{
+		devboxProject: &testProject{dir: t.TempDir(), resolver: ResolverConfig{Backend: ResolverIndex, Index: index}},
+		Packages:      map[string]*Package{},
+	}
+
+	pkg, err := f.Resolve("hello@2")
+	require.NoError(t, err)
+	require.Equal(t, "2.12.1", pkg.Version)
+	require.Equal(t, "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello", pkg.Resolved)
+
+	_, err = f.Resolve("greet@latest")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+	userErr, ok := usererr.Extract(err)
+	require.True(t, ok)
+	require.Contains(t, userErr.Error(), "hello has a greet command", "a command suggests the package that installs it")
+}
+
+type staticResolver struct{ version string }
+
+func (r *staticResolver) Resolve(_ context.Context, name, version string) (*Package, error) {
+	return &Package{Resolved: "github:acme/pkgs#" + name, Source: "acme", Version: r.version}, nil
+}
+
+func (r *staticResolver) Versions(context.Context, string) ([]string, error) {
+	return []string{r.version}, nil
+}
+
+func TestRegisterResolver(t *testing.T) {
+	RegisterResolver("test-static", func(cfg ResolverConfig) (Resolver, error) {
+		return &staticResolver{version: cfg.Options["version"]}, nil
+	})
+	require.Panics(t, func() {
+		RegisterResolver("test-static", func(ResolverConfig) (Resolver, error) { return nil, nil })
+	})
+	require.Panics(t, func() {
+		RegisterResolver(ResolverSearch, func(ResolverConfig) (Resolver, error) { return nil, nil })
+	})
+
+	f := &File{
+		devboxProject: &testProject{dir: t.TempDir(), resolver: ResolverConfig{
+			Backend: "test-static",
+			Options: map[string]string{"version": "1.0.0"},
+		}},
+		Packages: map[string]*Package{},
+	}
+	pkg, err := f.Resolve("hello@latest")
+	require.NoError(t, err)
+	require.Equal(t, "github:acme/pkgs#hello", pkg.Resolved)
+	require.Equal(t, "1.0.0", pkg.Version)
+
+	f.devboxProject = &testProject{dir: t.TempDir(), resolver: ResolverConfig{Backend: "unregistered"}}
+	_, err = f.Resolve("ripgrep@latest")
+	_, ok := usererr.Extract(err)
+	require.True(t, ok, "an unknown backend is a user error")
+}
+
+func TestVersionMatches(t *testing.T) {
+	require.True(t, versionMatches("3.12.2", "latest"))
+	require.True(t, versionMatches("3.12.2", "3.12"))
+	require.True(t, versionMatches("3.12.2", "3.12.2"))
+	require.False(t, versionMatches("3.120.0", "3.12"))
+	require.False(t, versionMatches("3.11.9", "3.12"))
+}
+
+func TestFetchResolvedPackagesInBatches(t *testing.T) {
+	installable := `{
+		"flake_installable": {
+			"ref": {"type": "github", "owner": "NixOS", "repo": "nixpkgs", "rev": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+			"attr_path": "%s"
+		},
+		"outputs": [{"name": "out", "path": "/nix/store/abc-%s", "default": true}]
+	}`
+	var batches, resolves atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/resolve/batch":
+			batches.Add(1)
+			fmt.Fprintf(w, `{"packages": [
+				{"name": "go", "version": "1.22.1", "systems": {"x86_64-linux": `+installable+`}},
+				{"name": "hello", "version": "2.12.1", "systems": {"x86_64-linux": `+installable+`}}
+			]}`, "go", "go", "hello", "hello")
+		default:
+			resolves.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+	t.Setenv(envir.DevboxResolveCacheTTL, "0")
+
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{}}
+	resolved, err := f.FetchResolvedPackages(context.Background(), []string{"go@1.22", "hello@latest"})
+	require.NoError(t, err)
+	require.Equal(t, "1.22.1", resolved["go@1.22"].Version)
+	require.Equal(t, "github:NixOS/nixpkgs/75a52265bda7fd25e06e3a67dee3f0354e73243c#hello", resolved["hello@latest"].Resolved)
+	require.EqualValues(t, 1, batches.Load())
+	require.Zero(t, resolves.Load(), "prefetched packages aren't resolved one at a time")
+
+	// Prefetched resolutions are only used once.
+	_, err = f.FetchResolvedPackage(context.Background(), "hello@latest")
+	require.ErrorIs(t, err, nix.ErrPackageNotFound)
+	require.NotZero(t, resolves.Load())
+}
+
+func TestPrefetchResolutionsChecksPackages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The resolutions are in the wrong order.
+		fmt.Fprint(w, `{"packages": [
+			{"name": "hello", "version": "2.12.1", "systems": {"x86_64-linux": {}}},
+			{"name": "go", "version": "1.22.1", "systems": {"x86_64-linux": {}}},
+			{"name": "python3", "version": "3.12.2", "systems": {"x86_64-linux": {}}},
+			{"name": "nodejs", "version": "20.11.1", "systems": {"x86_64-linux": {}}}
+		]}`)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+	t.Setenv(envir.DevboxResolveCacheTTL, "0")
+
+	f := &File{devboxProject: &testProject{dir: t.TempDir()}, Packages: map[string]*Package{}}
+	f.PrefetchResolutions(context.Background(), []string{
+		"go@1.22", "hello@latest", "python3@3.12", "nodejs@20?channel=nixos-24.05",
+	})
+	require.Nil(t, f.prefetched.take("go", "1.22"))
+	require.Nil(t, f.prefetched.take("hello", "latest"))
+	require.NotNil(t, f.prefetched.take("python3", "3.12"))
+	require.Nil(t, f.prefetched.take("nodejs", "20?channel=nixos-24.05"), "it isn't from the channel")
+}
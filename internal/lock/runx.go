@@ -0,0 +1,119 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// RunXDigest returns the digest of the files that a RunX package installed in
+// paths, in the SRI "sha256-<base64>" form. It covers the name, executable
+// bit and contents of every file, so that it changes if the release artifact
+// is replaced by a different one under the same tag.
+func RunXDigest(paths []string) (string, error) {
+	hash := sha256.New()
+	for i, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%d\x00%s\x00%t\x00", i, filepath.ToSlash(rel), info.Mode()&0o111 != 0)
+			if info.Mode()&fs.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(hash, "link\x00%s\x00", target)
+				return nil
+			}
+			fmt.Fprintf(hash, "%d\x00", info.Size())
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(hash, f)
+			return err
+		})
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return "sha256-" + base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyRunXDigest checks the files that RunX installed in paths for pkg
+// against the digest that devbox.lock pins for this system. The first install
+// on a system pins the digest, unless the lockfile is frozen. Afterwards, a
+// release artifact that doesn't match the digest, for example because the
+// release was re-uploaded or a mirror serves a different file, is an error.
+func (f *File) VerifyRunXDigest(pkg string, paths []string) error {
+	entry, ok := f.Packages[pkg]
+	if !ok {
+		return nil
+	}
+	digest, err := RunXDigest(paths)
+	if err != nil {
+		return err
+	}
+
+	system := nix.System()
+	info := entry.Systems[system]
+	if info == nil || info.Digest == "" {
+		if f.frozen {
+			return nil
+		}
+		if entry.Systems == nil {
+			entry.Systems = map[string]*SystemInfo{}
+		}
+		if info == nil {
+			info = &SystemInfo{}
+			entry.Systems[system] = info
+		}
+		info.Digest = digest
+		return nil
+	}
+	if info.Digest != digest {
+		return usererr.New(
+			"The files installed for %s have digest %s, but devbox.lock pins %s on %s. "+
+				"The release artifact changed since it was locked. If the new artifact is "+
+				"expected, run `devbox update %s` to lock it again.",
+			pkg, digest, info.Digest, system, pkg)
+	}
+	return nil
+}
+
+// ResetRunXDigests forgets the digests that devbox.lock pins for pkg, so that
+// installing it pins the release artifact again. devbox update does this for
+// the runx: packages it updates.
+func (f *File) ResetRunXDigests(pkg string) {
+	entry, ok := f.Packages[pkg]
+	if !ok {
+		return
+	}
+	for system, info := range entry.Systems {
+		info.Digest = ""
+		if len(info.Outputs) == 0 && info.Resolved == "" && info.Version == "" && info.StorePath == "" {
+			delete(entry.Systems, system)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestVerifyRunXDigest(t *testing.T) {
+	const pkg = "runx:golangci/golangci-lint@v1.55.2"
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "golangci-lint")
+	require.NoError(t, os.WriteFile(bin, []byte("binary"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("license"), 0o644))
+
+	f := testLockfile(t, 0)
+	f.Packages[pkg] = &Package{Resolved: "golangci/golangci-lint@v1.55.2", Version: "v1.55.2"}
+
+	// The first install pins the digest.
+	require.NoError(t, f.VerifyRunXDigest(pkg, []string{dir}))
+	digest := f.Packages[pkg].Systems[nix.System()].Digest
+	require.Regexp(t, `^sha256-[A-Za-z0-9+/]+=*$`, digest)
+	require.NoError(t, f.VerifyRunXDigest(pkg, []string{dir}))
+
+	// Changing a file, or only its executable bit, changes the digest.
+	require.NoError(t, os.WriteFile(bin, []byte("replaced"), 0o755))
+	err := f.VerifyRunXDigest(pkg, []string{dir})
+	require.ErrorContains(t, err, "devbox update "+pkg)
+	require.NoError(t, os.WriteFile(bin, []byte("binary"), 0o755))
+	require.NoError(t, os.Chmod(bin, 0o644))
+	require.Error(t, f.VerifyRunXDigest(pkg, []string{dir}))
+
+	// Resetting the digest pins the new artifact.
+	f.ResetRunXDigests(pkg)
+	require.Empty(t, f.Packages[pkg].Systems)
+	require.NoError(t, f.VerifyRunXDigest(pkg, []string{dir}))
+	require.NotEqual(t, digest, f.Packages[pkg].Systems[nix.System()].Digest)
+
+	// A frozen lockfile doesn't pin digests.
+	f.ResetRunXDigests(pkg)
+	f.frozen = true
+	require.NoError(t, f.VerifyRunXDigest(pkg, []string{dir}))
+	require.Empty(t, f.Packages[pkg].Systems)
+}
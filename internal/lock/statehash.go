@@ -29,11 +29,45 @@ type stateHashFile struct {
 	LockFileHash           string `json:"lock_file_hash"`
 	NixPrintDevEnvHash     string `json:"nix_print_dev_env_hash"`
 	NixProfileManifestHash string `json:"nix_profile_manifest_hash"`
+	// PackagesHash only covers the package list (including plugin packages), so
+	// edits to env, scripts or hooks can be told apart from edits that require
+	// installing something.
+	PackagesHash string `json:"packages_hash"`
+}
+
+// StateDrift reports which of the inputs recorded in the state file no longer
+// match the project. The zero value means everything is up to date.
+type StateDrift struct {
+	// Config is true if devbox.json or any included config changed.
+	Config bool
+	// Packages is true if the set of packages (or their options) changed.
+	Packages bool
+	// Lock is true if devbox.lock changed on disk or has unsaved changes.
+	Lock bool
+	// Profile is true if the nix profile or print-dev-env cache changed.
+	Profile bool
+	// Shell is true if the user switched between fish and non-fish shells.
+	Shell bool
+	// Version is true if the state was computed by a different devbox version.
+	Version bool
+}
+
+// Any returns true if anything drifted and the state needs to be recomputed.
+func (d StateDrift) Any() bool {
+	return d != StateDrift{}
+}
+
+// NeedsInstall returns true if the drift may require nix to build or fetch
+// packages. Config-only changes (env, scripts, init hooks) and shell switches
+// only need the generated files and environment to be recomputed.
+func (d StateDrift) NeedsInstall() bool {
+	return d.Packages || d.Lock || d.Profile || d.Version
 }
 
 type UpdateStateHashFileArgs struct {
-	ProjectDir string
-	ConfigHash string
+	ProjectDir   string
+	ConfigHash   string
+	PackagesHash string
 	// IsFish is an arg because in the future we may allow the user
 	// to specify shell in devbox.json which should be passed in here.
 	IsFish bool
@@ -54,21 +88,29 @@ func SetIgnoreShellMismatch(ignore bool) {
 	ignoreShellMismatch = ignore
 }
 
-func isStateUpToDate(args UpdateStateHashFileArgs) (bool, error) {
+func stateDrift(args UpdateStateHashFileArgs) (StateDrift, error) {
 	filesystemStateHash, err := readStateHashFile(args.ProjectDir)
 	if err != nil {
-		return false, err
+		return StateDrift{}, err
 	}
 	newStateHash, err := getCurrentStateHash(args)
 	if err != nil {
-		return false, err
+		return StateDrift{}, err
 	}
 
-	if ignoreShellMismatch {
-		filesystemStateHash.IsFish = newStateHash.IsFish
-	}
+	return compareStateHashes(filesystemStateHash, newStateHash), nil
+}
 
-	return *filesystemStateHash == *newStateHash, nil
+func compareStateHashes(old, current *stateHashFile) StateDrift {
+	return StateDrift{
+		Config:   old.ConfigHash != current.ConfigHash,
+		Packages: old.PackagesHash != current.PackagesHash,
+		Lock:     old.LockFileHash != current.LockFileHash,
+		Profile: old.NixProfileManifestHash != current.NixProfileManifestHash ||
+			old.NixPrintDevEnvHash != current.NixPrintDevEnvHash,
+		Shell:   !ignoreShellMismatch && old.IsFish != current.IsFish,
+		Version: old.DevboxVersion != current.DevboxVersion,
+	}
 }
 
 func readStateHashFile(projectDir string) (*stateHashFile, error) {
@@ -106,6 +148,7 @@ func getCurrentStateHash(args UpdateStateHashFileArgs) (*stateHashFile, error) {
 		LockFileHash:           lockfileHash,
 		NixPrintDevEnvHash:     printDevEnvCacheHash,
 		NixProfileManifestHash: nixHash,
+		PackagesHash:           args.PackagesHash,
 	}
 
 	return newLock, nil
@@ -0,0 +1,76 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareStateHashes(t *testing.T) {
+	base := stateHashFile{
+		ConfigHash:             "config",
+		DevboxVersion:          "0.10.0",
+		LockFileHash:           "lock",
+		NixPrintDevEnvHash:     "printdevenv",
+		NixProfileManifestHash: "manifest",
+		PackagesHash:           "packages",
+	}
+
+	testCases := []struct {
+		name         string
+		mutate       func(*stateHashFile)
+		expected     StateDrift
+		needsInstall bool
+	}{
+		{
+			name:     "unchanged",
+			mutate:   func(*stateHashFile) {},
+			expected: StateDrift{},
+		},
+		{
+			name:     "env or scripts changed",
+			mutate:   func(s *stateHashFile) { s.ConfigHash = "new-config" },
+			expected: StateDrift{Config: true},
+		},
+		{
+			name: "package added",
+			mutate: func(s *stateHashFile) {
+				s.ConfigHash = "new-config"
+				s.PackagesHash = "new-packages"
+			},
+			expected:     StateDrift{Config: true, Packages: true},
+			needsInstall: true,
+		},
+		{
+			name:         "lockfile changed",
+			mutate:       func(s *stateHashFile) { s.LockFileHash = "new-lock" },
+			expected:     StateDrift{Lock: true},
+			needsInstall: true,
+		},
+		{
+			name:         "profile changed",
+			mutate:       func(s *stateHashFile) { s.NixProfileManifestHash = "new-manifest" },
+			expected:     StateDrift{Profile: true},
+			needsInstall: true,
+		},
+		{
+			name:     "shell changed",
+			mutate:   func(s *stateHashFile) { s.IsFish = true },
+			expected: StateDrift{Shell: true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			current := base
+			testCase.mutate(&current)
+			drift := compareStateHashes(&base, &current)
+			require.Equal(t, testCase.expected, drift)
+			require.Equal(t, testCase.expected != StateDrift{}, drift.Any())
+			require.Equal(t, testCase.needsInstall, drift.NeedsInstall())
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux/stepper"
+)
+
+const (
+	// defaultStorePathConcurrency is how many store paths devbox looks up
+	// in binary caches at the same time, unless
+	// DEVBOX_STORE_PATH_CONCURRENCY says otherwise. Each lookup runs Nix,
+	// and the limit is shared by all of the packages that are resolved at
+	// the same time.
+	defaultStorePathConcurrency = 8
+	// defaultStorePathTimeout limits each lookup, unless
+	// DEVBOX_STORE_PATH_TIMEOUT says otherwise.
+	defaultStorePathTimeout = 30 * time.Second
+)
+
+// storePathConcurrency returns the maximum number of store path lookups that
+// run at the same time.
+func storePathConcurrency() int {
+	env := os.Getenv(envir.DevboxStorePathConcurrency)
+	if env == "" {
+		return defaultStorePathConcurrency
+	}
+	n, err := strconv.Atoi(env)
+	if err != nil || n < 1 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxStorePathConcurrency, env)
+		return defaultStorePathConcurrency
+	}
+	return n
+}
+
+// storePathTimeout returns how long each store path lookup can take. 0 means
+// there's no limit.
+func storePathTimeout() time.Duration {
+	env := os.Getenv(envir.DevboxStorePathTimeout)
+	if env == "" {
+		return defaultStorePathTimeout
+	}
+	timeout, err := time.ParseDuration(env)
+	if err != nil || timeout < 0 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxStorePathTimeout, env)
+		return defaultStorePathTimeout
+	}
+	return timeout
+}
+
+// storePathLookups has a slot for each lookup that can run at the same time.
+// It's a variable so tests can replace it.
+var storePathLookups = sync.OnceValue(func() chan struct{} {
+	return make(chan struct{}, storePathConcurrency())
+})
+
+// lookUpStorePath waits for a free lookup slot and then looks up the store
+// path with the given hash part in cache.
+func lookUpStorePath(ctx context.Context, hash, cache string) (string, error) {
+	slots := storePathLookups()
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	if timeout := storePathTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	path, err := storePathFromHashPart(ctx, hash, cache)
+	debug.Log("looked up store path %s in %s in %s", hash, cache, time.Since(start).Round(time.Millisecond))
+	return path, err
+}
+
+// lookupProgress counts the systems whose store paths are being looked up,
+// across the packages that are resolved at the same time, and shows the
+// count in a spinner on a terminal. With --debug, each lookup is logged
+// instead.
+type lookupProgress struct {
+	mu      sync.Mutex
+	total   int
+	done    int
+	spinner *stepper.Stepper
+}
+
+var storePathProgress = &lookupProgress{}
+
+// add counts n more systems to look up.
+func (p *lookupProgress) add(n int) {
+	if n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+	switch {
+	case p.spinner != nil:
+		p.spinner.Display(p.message())
+	case isatty.IsTerminal(os.Stderr.Fd()) && !debug.IsEnabled():
+		p.spinner = stepper.Start(os.Stderr, p.message())
+	}
+}
+
+// finish counts a system as looked up. Once every system is, it clears the
+// spinner and starts counting again from 0.
+func (p *lookupProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	debug.Log("store paths: %s", p.message())
+	if p.done < p.total {
+		if p.spinner != nil {
+			p.spinner.Display(p.message())
+		}
+		return
+	}
+	if p.spinner != nil {
+		p.spinner.Clear()
+	}
+	p.total, p.done, p.spinner = 0, 0, nil
+}
+
+func (p *lookupProgress) message() string {
+	return fmt.Sprintf("Looking up store paths in binary caches (%d/%d)", p.done, p.total)
+}
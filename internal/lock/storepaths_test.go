@@ -0,0 +1,60 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestBuildLockSystemInfosLimitsLookups(t *testing.T) {
+	slots := make(chan struct{}, 2)
+	lookups := storePathLookups
+	t.Cleanup(func() { storePathLookups = lookups })
+	storePathLookups = func() chan struct{} { return slots }
+
+	var running, maxRunning atomic.Int32
+	lookup := storePathFromHashPart
+	t.Cleanup(func() { storePathFromHashPart = lookup })
+	storePathFromHashPart = func(ctx context.Context, hash, cache string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		if hash == "slow" {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		time.Sleep(5 * time.Millisecond)
+		return "/nix/store/" + hash + "-hello-2.12.1", nil
+	}
+	t.Setenv(envir.DevboxStorePathTimeout, "20ms")
+
+	systems := map[string]searcher.PackageInfo{
+		"x86_64-linux": {StoreHash: "slow", StoreName: "hello-2.12.1"},
+	}
+	for i := range 8 {
+		systems[fmt.Sprintf("system-%d", i)] = searcher.PackageInfo{
+			StoreHash: fmt.Sprintf("hash%d", i), StoreName: "hello-2.12.1",
+		}
+	}
+	infos, err := buildLockSystemInfos(context.Background(), &searcher.PackageVersion{Systems: systems},
+		[]string{"https://cache.nixos.org"})
+	require.NoError(t, err)
+	require.Len(t, infos, 8)
+	require.NotContains(t, infos, "x86_64-linux", "lookups that time out are left out")
+	require.LessOrEqual(t, maxRunning.Load(), int32(2))
+	require.Zero(t, storePathProgress.total, "progress resets once every lookup finishes")
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// resolveSystemVersions resolves a package that devbox.json gives a different
+// version on each system, such as nodejs 20 on aarch64-darwin and 18 on
+// x86_64-linux. The lock entry only has the systems that have a version, and
+// each one records what its version resolved to. The entry's own fields come
+// from the first system in sorted order, so that every machine writes the
+// same entry.
+func (f *File) resolveSystemVersions(ctx context.Context, name string, versions map[string]string) (*Package, error) {
+	systems := lo.Keys(versions)
+	slices.Sort(systems)
+
+	// Systems that have the same version share a resolution.
+	resolutions := map[string]*Package{}
+	var locked *Package
+	for _, system := range systems {
+		version := versions[system]
+		resolved, ok := resolutions[version]
+		if !ok {
+			var err error
+			resolved, err = f.FetchResolvedPackage(ctx, name+"@"+version)
+			if err != nil {
+				return nil, err
+			}
+			resolutions[version] = resolved
+		}
+
+		if locked == nil {
+			entry := *resolved
+			entry.Systems = map[string]*SystemInfo{}
+			locked = &entry
+		}
+		sysInfo := &SystemInfo{}
+		if info := resolved.Systems[system]; info != nil {
+			copied := *info
+			sysInfo = &copied
+		}
+		sysInfo.Resolved = resolved.Resolved
+		sysInfo.Version = resolved.Version
+		locked.Systems[system] = sysInfo
+	}
+	return locked, nil
+}
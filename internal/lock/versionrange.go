@@ -0,0 +1,61 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// packageVersions lists every version of a package that the search service
+// knows. It's a variable so tests can replace it.
+var packageVersions = func(ctx context.Context, name string) ([]string, error) {
+	results, err := searcher.Client().Search(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, pkg := range results.Packages {
+		if pkg.Name != name {
+			continue
+		}
+		for _, version := range pkg.Versions {
+			versions = append(versions, version.Version)
+		}
+	}
+	return versions, nil
+}
+
+// resolveVersionRange resolves a package whose devbox.json version is a
+// semver range, such as go@^1.21. It locks the highest version in the range
+// but keeps the range as the lock key, so devbox update only moves the
+// package within the range.
+func (f *File) resolveVersionRange(ctx context.Context, resolver Resolver, name, version string) (*Package, error) {
+	version, channel, err := searcher.ParseChannel(version)
+	if err != nil {
+		return nil, usererr.New("Package %s@%s: %v.", name, version, err)
+	}
+	constraint, err := searcher.ParseVersionConstraint(version)
+	if err != nil {
+		return nil, usererr.New("Package %s@%s: %v.", name, version, err)
+	}
+	versions, err := resolver.Versions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	best := constraint.Best(versions)
+	if best == "" {
+		if len(versions) == 0 {
+			return nil, usererr.New("Devbox couldn't find any versions of %s.", name)
+		}
+		return nil, usererr.New(
+			"None of the versions of %s match %s. Available versions: %s",
+			name, version, strings.Join(versions, ", "),
+		)
+	}
+	return f.FetchResolvedPackage(ctx, name+"@"+searcher.WithChannel(best, channel))
+}
@@ -0,0 +1,372 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package netpolicy describes the network endpoints devbox contacts and lets
+// users disable or redirect each class of them, for example to use internal
+// mirrors in a network without Internet access.
+//
+// The policy is read from the file in DEVBOX_NETWORK_POLICY, or from
+// devbox/network-policy.json in the user's config directory:
+//
+//	{
+//	  "disable": ["telemetry", "runx"],
+//	  "redirect": {"search": "https://search.mirror.acme.internal"}
+//	}
+//
+// Most redirects replace the base URL that devbox sends requests to. RunX
+// downloads are made by a library, so the shared HTTP client sends them to
+// the runx redirect instead, under a path named after the GitHub host they
+// were for (see MirrorURL).
+package netpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tailscale/hujson"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/build"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// Class is a group of endpoints that serve the same purpose.
+type Class string
+
+const (
+	// Search resolves package versions and powers devbox search.
+	Search Class = "search"
+	// Cache is the public Nix binary cache, which devbox checks for
+	// prebuilt packages.
+	Cache Class = "cache"
+	// Jetify is the Jetify API, used for logging in and Jetify caches.
+	Jetify Class = "jetify"
+	// Telemetry is usage and error reporting.
+	Telemetry Class = "telemetry"
+	// RunX downloads runx: packages from GitHub releases.
+	RunX Class = "runx"
+	// Plugins fetches plugins included from GitHub.
+	Plugins Class = "plugins"
+	// Vulnerabilities is the vulnerability data devbox audit queries.
+	// Redirecting it replaces the OSV API, and NVD can only be disabled.
+	Vulnerabilities Class = "vulnerabilities"
+)
+
+// Endpoint is a service devbox may contact.
+type Endpoint struct {
+	Class       Class
+	Description string
+	// URL is where devbox sends requests, after applying redirects.
+	URL string
+	// Redirectable is false for endpoints that are contacted by libraries
+	// that don't let devbox change the URL, and that the shared HTTP client
+	// can't send to a mirror either. They can only be disabled.
+	Redirectable bool
+	// Hosts are the hostnames requests to this endpoint go to.
+	Hosts []string
+}
+
+type endpointDef struct {
+	Endpoint
+	// redirectEnv is an environment variable that overrides the URL, which
+	// takes precedence over the policy.
+	redirectEnv string
+	// hostMirrored means that a redirect is a mirror of all of Hosts, which
+	// serves each host's paths under a path named after the host.
+	hostMirrored bool
+}
+
+func definitions() []endpointDef {
+	return []endpointDef{
+		{Endpoint: Endpoint{
+			Class:        Search,
+			Description:  "package search and version resolution",
+			URL:          "https://search.devbox.sh",
+			Redirectable: true,
+		}, redirectEnv: envir.DevboxSearchHost},
+		{Endpoint: Endpoint{
+			Class:        Cache,
+			Description:  "public Nix binary cache lookups",
+			URL:          "https://cache.nixos.org",
+			Redirectable: true,
+		}},
+		{Endpoint: Endpoint{
+			Class:       Jetify,
+			Description: "Jetify login, API and caches",
+			URL:         build.JetpackAPIHost(),
+			Hosts:       []string{hostOf(build.JetpackAPIHost()), hostOf(build.Issuer()), hostOf(build.DashboardHostname())},
+		}},
+		{Endpoint: Endpoint{
+			Class:       Telemetry,
+			Description: "usage and error reporting (also turned off by DO_NOT_TRACK=1)",
+			URL:         "https://api.segment.io",
+			Hosts:       []string{"api.segment.io", sentryHost()},
+		}},
+		{Endpoint: Endpoint{
+			Class:        RunX,
+			Description:  "runx: package releases",
+			URL:          "https://api.github.com",
+			Redirectable: true,
+			Hosts:        []string{"api.github.com", "github.com", "objects.githubusercontent.com"},
+		}, redirectEnv: envir.DevboxRunXMirror, hostMirrored: true},
+		{Endpoint: Endpoint{
+			Class:        Plugins,
+			Description:  "plugins included from GitHub",
+			URL:          "https://raw.githubusercontent.com",
+			Redirectable: true,
+		}},
+		{Endpoint: Endpoint{
+			Class:        Vulnerabilities,
+			Description:  "vulnerability data for devbox audit",
+			URL:          "https://api.osv.dev",
+			Redirectable: true,
+			Hosts:        []string{"api.osv.dev", "services.nvd.nist.gov"},
+		}},
+	}
+}
+
+// Policy disables or redirects classes of endpoints.
+type Policy struct {
+	// Disable lists the classes devbox must not contact.
+	Disable []Class `json:"disable,omitempty"`
+	// Redirect replaces the URL of a class, such as a mirror of the search
+	// service.
+	Redirect map[Class]string `json:"redirect,omitempty"`
+
+	path string
+}
+
+// Path returns where the policy is read from.
+func Path() string {
+	if path := os.Getenv(envir.DevboxNetworkPolicy); path != "" {
+		return path
+	}
+	return xdg.ConfigSubpath(filepath.Join("devbox", "network-policy.json"))
+}
+
+var current = sync.OnceValues(func() (*Policy, error) { return load(Path()) })
+
+// Current returns the policy in effect, which is empty if there's no policy
+// file.
+func Current() (*Policy, error) {
+	return current()
+}
+
+func load(path string) (*Policy, error) {
+	policy := &Policy{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv(envir.DevboxNetworkPolicy) == "" {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to read the network policy in %s", path)
+	}
+	data, err = hujson.Standardize(data)
+	if err == nil {
+		err = json.Unmarshal(data, policy)
+	}
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "The network policy in %s isn't valid JSON", path)
+	}
+	return policy, policy.validate()
+}
+
+func (p *Policy) validate() error {
+	known := map[Class]endpointDef{}
+	for _, def := range definitions() {
+		known[def.Class] = def
+	}
+	for _, class := range p.Disable {
+		if _, ok := known[class]; !ok {
+			return usererr.New("The network policy in %s disables unknown class %q. Classes are: %s",
+				p.path, class, classNames())
+		}
+	}
+	for class, redirect := range p.Redirect {
+		def, ok := known[class]
+		if !ok {
+			return usererr.New("The network policy in %s redirects unknown class %q. Classes are: %s",
+				p.path, class, classNames())
+		}
+		if !def.Redirectable {
+			return usererr.New("The network policy in %s redirects %s, which can only be disabled.", p.path, class)
+		}
+		if u, err := url.Parse(redirect); err != nil || u.Scheme == "" || u.Host == "" {
+			return usererr.New("The network policy in %s redirects %s to %q, which isn't a URL.", p.path, class, redirect)
+		}
+	}
+	return nil
+}
+
+func classNames() string {
+	names := make([]string, 0, len(definitions()))
+	for _, def := range definitions() {
+		names = append(names, string(def.Class))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Disabled returns true if the policy doesn't allow contacting class. Every
+// class is disabled when devbox is offline.
+func (p *Policy) Disabled(class Class) bool {
+	if envir.IsOffline() {
+		return true
+	}
+	return p != nil && slices.Contains(p.Disable, class)
+}
+
+// Endpoints returns every endpoint devbox may contact, with the policy's
+// redirects applied.
+func (p *Policy) Endpoints() []Endpoint {
+	defs := definitions()
+	endpoints := make([]Endpoint, 0, len(defs))
+	for _, def := range defs {
+		endpoint := def.Endpoint
+		if redirect := p.redirect(def); redirect != "" {
+			endpoint.URL = redirect
+		}
+		if endpoint.Hosts == nil {
+			endpoint.Hosts = []string{hostOf(def.URL)}
+		}
+		if host := hostOf(endpoint.URL); !slices.Contains(endpoint.Hosts, host) {
+			endpoint.Hosts = append(slices.Clone(endpoint.Hosts), host)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// redirect returns the URL that def is redirected to, or "" if it isn't.
+func (p *Policy) redirect(def endpointDef) string {
+	if env := def.redirectEnv; env != "" && os.Getenv(env) != "" {
+		return os.Getenv(env)
+	}
+	if p != nil {
+		return p.Redirect[def.Class]
+	}
+	return ""
+}
+
+// MirrorURL returns the URL of the mirror that serves u, if u is for a host
+// of a class that's redirected to a mirror of its hosts. The mirror serves
+// each host under a path named after it, so with the runx class redirected to
+// https://proxy.acme.internal/gh, https://github.com/o/r/releases is fetched
+// from https://proxy.acme.internal/gh/github.com/o/r/releases.
+func MirrorURL(u *url.URL) (*url.URL, bool) {
+	policy, err := Current()
+	if err != nil {
+		debug.Log("network policy: %v", err)
+	}
+	return policy.mirrorURL(u)
+}
+
+func (p *Policy) mirrorURL(u *url.URL) (*url.URL, bool) {
+	host := strings.ToLower(u.Hostname())
+	for _, def := range definitions() {
+		if !def.hostMirrored || !slices.Contains(def.Hosts, host) {
+			continue
+		}
+		mirror, err := url.Parse(p.redirect(def))
+		if err != nil || mirror.Host == "" {
+			return nil, false
+		}
+		mirrored := mirror.JoinPath(host, u.EscapedPath())
+		mirrored.RawQuery = u.RawQuery
+		return mirrored, true
+	}
+	return nil, false
+}
+
+// URL returns the base URL for class. If the policy can't be read, it
+// returns the default.
+func URL(class Class) string {
+	policy, err := Current()
+	if err != nil {
+		debug.Log("network policy: %v", err)
+	}
+	for _, endpoint := range policy.Endpoints() {
+		if endpoint.Class == class {
+			return endpoint.URL
+		}
+	}
+	return ""
+}
+
+// Check returns an error if the policy doesn't allow contacting class. An
+// invalid policy doesn't allow anything.
+func Check(class Class) error {
+	policy, err := Current()
+	if err != nil {
+		return err
+	}
+	if policy.Disabled(class) {
+		return policy.disabledError(class)
+	}
+	return nil
+}
+
+// Allowed returns true if devbox may contact class.
+func Allowed(class Class) bool {
+	return Check(class) == nil
+}
+
+// CheckHost returns an error if host belongs to a class that the policy
+// disables. It's used by the shared HTTP client to catch requests that don't
+// check the policy themselves.
+func CheckHost(host string) error {
+	policy, err := Current()
+	if err != nil {
+		return err
+	}
+	return policy.checkHost(host)
+}
+
+func (p *Policy) checkHost(host string) error {
+	if len(p.Disable) == 0 && !envir.IsOffline() {
+		return nil
+	}
+	host = strings.ToLower(host)
+	for _, endpoint := range p.Endpoints() {
+		if p.Disabled(endpoint.Class) && slices.Contains(endpoint.Hosts, host) {
+			return p.disabledError(endpoint.Class)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) disabledError(class Class) error {
+	if envir.IsOffline() {
+		return usererr.New(
+			"Devbox can't contact %s endpoints because it's offline. Unset %s or drop --offline to go online.",
+			class, envir.DevboxOffline,
+		)
+	}
+	return usererr.New(
+		"Devbox can't contact %s endpoints because the network policy in %s disables them. "+
+			"Run `devbox debug network` to see which endpoints devbox uses.",
+		class, p.path,
+	)
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func sentryHost() string {
+	if build.SentryDSN == "" {
+		return "sentry.io"
+	}
+	return hostOf(build.SentryDSN)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package netpolicy
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "network-policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv(envir.DevboxSearchHost, "")
+	policy, err := load(writePolicy(t, `{
+		// Comments are allowed.
+		"disable": ["telemetry", "runx"],
+		"redirect": {"search": "https://search.acme.internal", "cache": "https://cache.acme.internal"},
+	}`))
+	require.NoError(t, err)
+	require.True(t, policy.Disabled(Telemetry))
+	require.False(t, policy.Disabled(Search))
+
+	urls := map[Class]string{}
+	for _, endpoint := range policy.Endpoints() {
+		urls[endpoint.Class] = endpoint.URL
+	}
+	require.Equal(t, "https://search.acme.internal", urls[Search])
+	require.Equal(t, "https://cache.acme.internal", urls[Cache])
+	require.Equal(t, "https://raw.githubusercontent.com", urls[Plugins])
+
+	require.Error(t, policy.checkHost("api.github.com"))
+	require.Error(t, policy.checkHost("API.segment.io"))
+	require.NoError(t, policy.checkHost("search.acme.internal"))
+
+	// DEVBOX_SEARCH_HOST takes precedence over the policy.
+	t.Setenv(envir.DevboxSearchHost, "https://search.local")
+	for _, endpoint := range policy.Endpoints() {
+		if endpoint.Class == Search {
+			require.Equal(t, "https://search.local", endpoint.URL)
+		}
+	}
+}
+
+func TestOffline(t *testing.T) {
+	policy, err := load(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	require.NoError(t, policy.checkHost("search.devbox.sh"))
+
+	t.Setenv(envir.DevboxOffline, "true")
+	for _, def := range definitions() {
+		require.True(t, policy.Disabled(def.Class), def.Class)
+	}
+	err = policy.checkHost("search.devbox.sh")
+	require.ErrorContains(t, err, "offline")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown class":       `{"disable": ["dns"]}`,
+		"not redirectable":    `{"redirect": {"jetify": "https://api.acme.internal"}}`,
+		"invalid redirect":    `{"redirect": {"search": "search.acme.internal"}}`,
+		"invalid json":        `{"disable": `,
+		"unknown class redir": `{"redirect": {"dns": "https://dns"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(writePolicy(t, content))
+			require.Error(t, err)
+		})
+	}
+
+	policy, err := load(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	require.False(t, policy.Disabled(Telemetry))
+
+	t.Setenv(envir.DevboxNetworkPolicy, "set")
+	_, err = load(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err, "a policy set explicitly must exist")
+}
+
+func TestMirrorURL(t *testing.T) {
+	t.Setenv(envir.DevboxRunXMirror, "")
+	policy, err := load(writePolicy(t, `{"redirect": {"runx": "https://proxy.acme.internal/gh/"}}`))
+	require.NoError(t, err)
+
+	u, err := url.Parse("https://github.com/jetify-com/devbox/releases/download/0.1.0/devbox.tar.gz?x=1")
+	require.NoError(t, err)
+	mirrored, ok := policy.mirrorURL(u)
+	require.True(t, ok)
+	require.Equal(t,
+		"https://proxy.acme.internal/gh/github.com/jetify-com/devbox/releases/download/0.1.0/devbox.tar.gz?x=1",
+		mirrored.String())
+
+	// Requests to the mirror itself, and to hosts of other classes, are
+	// sent as they are.
+	_, ok = policy.mirrorURL(mirrored)
+	require.False(t, ok)
+	u, err = url.Parse("https://search.devbox.sh/v2/search")
+	require.NoError(t, err)
+	_, ok = policy.mirrorURL(u)
+	require.False(t, ok)
+
+	// DEVBOX_RUNX_MIRROR takes precedence over the policy.
+	t.Setenv(envir.DevboxRunXMirror, "https://runx.local")
+	u, err = url.Parse("https://api.github.com/repos/jetify-com/devbox/releases")
+	require.NoError(t, err)
+	mirrored, ok = policy.mirrorURL(u)
+	require.True(t, ok)
+	require.Equal(t, "https://runx.local/api.github.com/repos/jetify-com/devbox/releases", mirrored.String())
+
+	empty, err := load(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	t.Setenv(envir.DevboxRunXMirror, "")
+	_, ok = empty.mirrorURL(u)
+	require.False(t, ok)
+}
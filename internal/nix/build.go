@@ -18,6 +18,8 @@ type BuildArgs struct {
 	ExtraSubstituters []string
 	Flags             []string
 	Writer            io.Writer
+	// OnProgress is called each time the progress of the build changes.
+	OnProgress func(BuildProgress)
 }
 
 func Build(ctx context.Context, args *BuildArgs, installables ...string) error {
@@ -40,20 +42,19 @@ func Build(ctx context.Context, args *BuildArgs, installables ...string) error {
 		cmd.Env = allowInsecureEnv(cmd.Env)
 	}
 
-	// If nix build runs as tty, the output is much nicer. If we ever
-	// need to change this to our own writers, consider that you may need
-	// to implement your own nicer output. --print-build-logs flag may be useful.
+	// Nix's own progress bar is replaced by the one that runLogged draws
+	// from the JSON log, so the build doesn't need a terminal.
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = args.Writer
-	cmd.Stderr = args.Writer
 
 	debug.Log("Running cmd: %s\n", cmd)
-	if err := cmd.Run(); err != nil {
+	nixErrors, err := runLogged(cmd, "build", args.Writer, args.OnProgress)
+	if err != nil {
 		if exitErr := (&exec.ExitError{}); errors.As(err, &exitErr) {
-			debug.Log("Nix build exit code: %d, output: %s\n", exitErr.ExitCode(), exitErr.Stderr)
+			debug.Log("Nix build exit code: %d, output: %s\n", exitErr.ExitCode(), nixErrors)
 			return redact.Errorf("nix build exit code: %d, output: %s, err: %w",
 				redact.Safe(exitErr.ExitCode()),
-				exitErr.Stderr,
+				nixErrors,
 				err,
 			)
 		}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
 )
 
 func CopyInstallableToCache(
@@ -37,3 +40,36 @@ func CopyInstallableToCache(
 
 	return cmd.Run()
 }
+
+// CopyClosure copies the closures of store paths to another store, such as
+// ssh-ng://host. The destination substitutes the paths that its binary
+// caches have instead of receiving them from this machine.
+func CopyClosure(ctx context.Context, out io.Writer, to string, paths ...string) error {
+	cmd := commandContext(ctx, append([]string{"copy", "--substitute-on-destination", "--to", to}, paths...)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// SignPaths signs the closures of storePaths in the local store with the
+// secret key in keyFile, so that a store that doesn't sign paths as they're
+// copied to it, such as ssh-ng://host, has signed paths.
+func SignPaths(ctx context.Context, keyFile string, storePaths ...string) error {
+	cmd := commandContext(ctx, append([]string{"store", "sign", "--recursive", "--key-file", keyFile}, storePaths...)...)
+	debug.Log("Running cmd %s", cmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return redact.Errorf("nix store sign: %w: %s", err, out)
+	}
+	return nil
+}
+
+// CopyPathsToCache copies the closures of storePaths to the store at to.
+// Paths that the store already has aren't copied again.
+func CopyPathsToCache(ctx context.Context, out io.Writer, to string, env []string, storePaths ...string) error {
+	cmd := commandContext(ctx, append([]string{"copy", "--to", to}, storePaths...)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append(os.Environ(), env...)
+	debug.Log("Running cmd %s", cmd)
+	return cmd.Run()
+}
@@ -12,6 +12,8 @@ func command(args ...string) *exec.Cmd {
 func commandContext(ctx context.Context, args ...string) *exec.Cmd {
 	cmd := exec.CommandContext(ctx, "nix", args...)
 	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, StoreFlags()...)
+	cmd.Args = append(cmd.Args, SubstituterFlags()...)
 	return cmd
 }
 
@@ -0,0 +1,103 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// flakeInputURLRegexp matches the URLs of the inputs in a flake.nix, such as
+//
+//	nixpkgs.url = "github:NixOS/nixpkgs/nixos-unstable";
+//	mine = { url = "path:/home/user/my-flake"; };
+var flakeInputURLRegexp = regexp.MustCompile(`\burl\s*=\s*"([^"]*)"`)
+
+// printDevEnvInputHash returns a hash of everything that the output of nix
+// print-dev-env for the flake in flakeDir depends on: the files in flakeDir,
+// the devbox.lock they were generated from, and the version and system of
+// Nix. It returns "" if the output can't be cached because the flake depends
+// on local files outside of flakeDir, which can change without changing the
+// hash.
+func printDevEnvInputHash(flakeDir, lockfileHash string) (string, error) {
+	flake, err := os.ReadFile(filepath.Join(flakeDir, "flake.nix"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	for _, m := range flakeInputURLRegexp.FindAllStringSubmatch(string(flake), -1) {
+		if !isContentLocked(flakeDir, m[1]) {
+			debug.Log("nix: not caching print-dev-env: flake input %q is a local path", m[1])
+			return "", nil
+		}
+	}
+
+	version, err := Version()
+	if err != nil {
+		debug.Log("nix: not caching print-dev-env: %v", err)
+		return "", nil
+	}
+
+	files := map[string]string{}
+	err = filepath.WalkDir(flakeDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(flakeDir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)], err = cachehash.File(path)
+		return err
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return cachehash.JSON(struct {
+		Files      map[string]string
+		Lockfile   string
+		NixVersion string
+		System     string
+	}{files, lockfileHash, version.Version, version.System})
+}
+
+// isContentLocked reports whether the contents of the flake input at url are
+// either pinned by the flake.lock in flakeDir or inside flakeDir itself.
+// Remote inputs, and inputs from the flake registry, are locked to a revision
+// and a hash the first time the flake is evaluated. A local path isn't, so
+// that editing it is picked up by the next evaluation.
+func isContentLocked(flakeDir, url string) bool {
+	path, ok := localFlakePath(url)
+	if !ok || strings.HasPrefix(path, "/nix/store/") {
+		return true
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(flakeDir, path)
+	}
+	rel, err := filepath.Rel(flakeDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// localFlakePath returns the path that a flake reference to a local directory
+// points to.
+func localFlakePath(url string) (string, bool) {
+	url, _, _ = strings.Cut(url, "?")
+	for _, scheme := range []string{"path:", "git+file:", "file:"} {
+		if rest, ok := strings.CutPrefix(url, scheme); ok {
+			// path:///dir is the URL form of path:/dir.
+			return strings.TrimPrefix(rest, "//"), true
+		}
+	}
+	if strings.HasPrefix(url, "/") || strings.HasPrefix(url, ".") {
+		return url, true
+	}
+	return "", false
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsContentLocked(t *testing.T) {
+	flakeDir := "/project/.devbox/gen/flake"
+	tests := map[string]bool{
+		"github:NixOS/nixpkgs/nixos-unstable":                     true,
+		"nixpkgs/75a5ebf473cd60148ba9aec0d219f72e5cf52519":        true,
+		"https://example.com/flake.tar.gz":                        true,
+		"path:./glibc-patch":                                      true,
+		"path:/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-source": true,
+		"path:/project/.devbox/gen/flake/glibc-patch":             true,
+		"path:../../../my-flake":                                  false,
+		"path:/home/user/my-flake":                                false,
+		"path:///home/user/my-flake?dir=sub":                      false,
+		"git+file:///home/user/my-flake":                          false,
+		"./..":                                                    false,
+		"/home/user/my-flake":                                     false,
+	}
+	for url, want := range tests {
+		if got := isContentLocked(flakeDir, url); got != want {
+			t.Errorf("isContentLocked(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func stubVersion(t *testing.T, version string) {
+	t.Helper()
+	saved := versionInfo
+	versionInfo = func() (VersionInfo, error) {
+		return VersionInfo{Version: version, System: "x86_64-linux"}, nil
+	}
+	t.Cleanup(func() { versionInfo = saved })
+}
+
+func writeFlake(t *testing.T, dir, flake string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "flake.nix"), []byte(flake), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrintDevEnvInputHash(t *testing.T) {
+	stubVersion(t, "2.18.1")
+	dir := t.TempDir()
+	writeFlake(t, dir, `{ inputs = { nixpkgs.url = "github:NixOS/nixpkgs"; }; }`)
+
+	hash, err := printDevEnvInputHash(dir, "lock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "" {
+		t.Fatal("got an empty hash for a flake without local inputs")
+	}
+	if again, _ := printDevEnvInputHash(dir, "lock1"); again != hash {
+		t.Errorf("got hash %s for the same inputs, want %s", again, hash)
+	}
+	if other, _ := printDevEnvInputHash(dir, "lock2"); other == hash {
+		t.Error("got the same hash after the lockfile changed")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "flake.lock"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := printDevEnvInputHash(dir, "lock1"); other == hash {
+		t.Error("got the same hash after flake.lock was written")
+	}
+
+	stubVersion(t, "2.19.0")
+	withLock, _ := printDevEnvInputHash(dir, "lock1")
+	stubVersion(t, "2.18.1")
+	if again, _ := printDevEnvInputHash(dir, "lock1"); again == withLock {
+		t.Error("got the same hash after the Nix version changed")
+	}
+
+	writeFlake(t, dir, `{ inputs = { mine.url = "path:/home/user/my-flake"; }; }`)
+	if hash, _ := printDevEnvInputHash(dir, "lock1"); hash != "" {
+		t.Errorf("got hash %s for a flake with a local input, want none", hash)
+	}
+}
+
+func TestPrintDevEnvReusesCache(t *testing.T) {
+	stubVersion(t, "2.18.1")
+	dir := t.TempDir()
+	writeFlake(t, dir, `{ inputs = { nixpkgs.url = "github:NixOS/nixpkgs"; }; }`)
+	hash, err := printDevEnvInputHash(dir, "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(t.TempDir(), "cache")
+	cached := PrintDevEnvOut{
+		Variables: map[string]Variable{"FOO": {Type: "exported", Value: "bar"}},
+		InputHash: hash,
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running nix would fail, since the flake has no outputs.
+	out, err := (&Nix{}).PrintDevEnv(context.Background(), &PrintDevEnvArgs{
+		FlakeDir:             dir,
+		PrintDevEnvCachePath: cachePath,
+		LockfileHash:         "lock",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Variables["FOO"].Value; got != "bar" {
+		t.Errorf("got FOO=%v, want the cached value bar", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// Check is one of the things that Doctor checks about the Nix installation.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	// Fix says how to fix a check that isn't OK.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Doctor checks that Nix is installed, that its version works with devbox and
+// that devbox can reach the store.
+func Doctor(ctx context.Context) []Check {
+	path, err := exec.LookPath("nix")
+	if err != nil {
+		fix := "Run `devbox nix install` to install Nix."
+		if dirExists() {
+			fix = "Nix is in /nix, but not in your PATH. Restart your terminal or reinstall Nix."
+		}
+		return []Check{{Name: "binary", Detail: "nix isn't in your PATH", Fix: fix}}
+	}
+	checks := []Check{{Name: "binary", OK: true, Detail: path}}
+
+	info, err := Version()
+	checks = append(checks, versionCheck(info, err))
+	if installer := InstalledBy(); installer != "" {
+		checks = append(checks, Check{Name: "installer", OK: true, Detail: installerNames[installer]})
+	}
+	return append(checks, storeCheck(ctx, info))
+}
+
+var installerNames = map[string]string{
+	InstallerNixOS:       "the nixos.org install script or another installer",
+	InstallerDeterminate: "the Determinate installer",
+}
+
+func versionCheck(info VersionInfo, err error) Check {
+	check := Check{Name: "version"}
+	switch {
+	case err != nil:
+		check.Detail = fmt.Sprintf("nix --version failed: %v", err)
+		check.Fix = "Reinstall Nix with `devbox nix install`."
+	case !info.AtLeast(MinVersion):
+		check.Detail = fmt.Sprintf("Nix %s is older than %s, the oldest version that devbox supports", info.Version, MinVersion)
+		check.Fix = "Run `devbox nix upgrade`."
+	case !info.AtLeast(Version2_17):
+		check.OK = true
+		check.Detail = fmt.Sprintf("Nix %s works, but fetching store paths without evaluating nixpkgs "+
+			"needs Nix %s or later", info.Version, Version2_17)
+		check.Fix = "Run `devbox nix upgrade` for faster installs."
+	default:
+		check.OK = true
+		check.Detail = "Nix " + info.Version
+	}
+	return check
+}
+
+// storeCheck checks that devbox can use the store: the user store if
+// DEVBOX_NIX_STORE is set, the daemon for a multi-user install, or /nix
+// otherwise.
+func storeCheck(ctx context.Context, info VersionInfo) Check {
+	check := Check{Name: "store"}
+	if root := UserStore(); root != "" {
+		if runtime.GOOS != "linux" {
+			check.Detail = fmt.Sprintf("%s=%s, but user stores only work on Linux", envir.DevboxNixStore, root)
+			check.Fix = "Unset " + envir.DevboxNixStore + "."
+			return check
+		}
+		check.OK = true
+		check.Detail = fmt.Sprintf("user store in %s, without the daemon", root)
+		return check
+	}
+	if !multiUser() {
+		check.OK = true
+		check.Detail = "single-user install, without the daemon"
+		return check
+	}
+
+	daemon, err := DaemonVersion(ctx)
+	if err != nil {
+		check.Detail = fmt.Sprintf("can't connect to the daemon: %v", err)
+		check.Fix = "Restart the daemon with " + restartDaemonCommand() + "."
+		return check
+	}
+	check.OK = true
+	check.Detail = "daemon is running"
+	if daemon != "" {
+		check.Detail += " Nix " + daemon
+		if info.Version != "" && daemon != info.Version {
+			check.Detail += fmt.Sprintf(", but the nix in your PATH is %s", info.Version)
+			check.Fix = "Run `devbox nix upgrade` to upgrade both to the same version."
+		}
+	}
+	return check
+}
+
+func restartDaemonCommand() string {
+	if runtime.GOOS == "darwin" {
+		return "`sudo launchctl kickstart -k system/org.nixos.nix-daemon`"
+	}
+	return "`sudo systemctl restart nix-daemon`"
+}
@@ -1,9 +1,12 @@
 package nix
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"strconv"
+
+	"go.jetpack.io/devbox/internal/redact"
 )
 
 func EvalPackageName(path string) (string, error) {
@@ -46,6 +49,61 @@ func PackageKnownVulnerabilities(path string) []string {
 	return vulnerabilities
 }
 
+// PackageVersion evaluates the version of the package that installable
+// refers to, such as "github:NixOS/nixpkgs/<rev>#go".
+func PackageVersion(ctx context.Context, installable string) (string, error) {
+	cmd := commandContext(ctx, "eval", "--raw", installable+".version")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", redact.Errorf("nix eval %s.version: %w", installable, err)
+	}
+	return string(out), nil
+}
+
+// PackageLicenses returns the licenses in a package's meta.license as SPDX
+// identifiers. Licenses without an SPDX identifier, such as "unfree", are
+// returned by their nixpkgs short name.
+func PackageLicenses(ctx context.Context, installable string) ([]string, error) {
+	cmd := commandContext(ctx, "eval", "--json", installable+".meta.license")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, redact.Errorf("nix eval %s.meta.license: %w", installable, err)
+	}
+	return parseLicenses(out)
+}
+
+func parseLicenses(data []byte) ([]string, error) {
+	type license struct {
+		SPDXID    string `json:"spdxId"`
+		ShortName string `json:"shortName"`
+	}
+	// meta.license is a single license, a list of licenses or, in some older
+	// packages, a plain string.
+	var licenses []license
+	if err := json.Unmarshal(data, &licenses); err != nil {
+		var one license
+		if err := json.Unmarshal(data, &one); err != nil {
+			var name string
+			if err := json.Unmarshal(data, &name); err != nil {
+				return nil, redact.Errorf("unexpected meta.license value: %s", data)
+			}
+			one.ShortName = name
+		}
+		licenses = []license{one}
+	}
+
+	var ids []string
+	for _, l := range licenses {
+		switch {
+		case l.SPDXID != "":
+			ids = append(ids, l.SPDXID)
+		case l.ShortName != "":
+			ids = append(ids, l.ShortName)
+		}
+	}
+	return ids, nil
+}
+
 // Eval is raw nix eval. Needs to be parsed. Useful for stuff like
 // nix eval --raw nixpkgs/9ef09e06806e79e32e30d17aee6879d69c011037#fuse3
 // to determine if a package if a package can be installed in system.
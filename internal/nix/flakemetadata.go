@@ -0,0 +1,58 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// FlakeMetadata is what `nix flake metadata` reports about a flake.
+type FlakeMetadata struct {
+	// Locked is the flake reference pinned to the flake's current revision,
+	// along with the hash of its source.
+	Locked flake.Ref
+
+	// LastModified is when the locked revision was committed or, for
+	// sources without commits, last changed.
+	LastModified time.Time
+}
+
+// LockFlakeRef fetches the flake that ref refers to and returns a reference
+// locked to the revision and source hash that it currently resolves to.
+func LockFlakeRef(ctx context.Context, ref string) (FlakeMetadata, error) {
+	defer debug.FunctionTimer().End()
+	cmd := commandContext(ctx, "flake", "metadata", "--json", ref)
+	out, err := cmd.Output()
+	if err != nil {
+		return FlakeMetadata{}, redact.Errorf("nix flake metadata %s: %w", ref, err)
+	}
+	return parseFlakeMetadata(out)
+}
+
+func parseFlakeMetadata(data []byte) (FlakeMetadata, error) {
+	var metadata struct {
+		Locked       flake.Ref `json:"locked"`
+		LastModified int64     `json:"lastModified"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return FlakeMetadata{}, redact.Errorf("parse nix flake metadata: %w", err)
+	}
+	if metadata.Locked.NarHash == "" {
+		return FlakeMetadata{}, redact.Errorf("nix flake metadata didn't return a locked narHash")
+	}
+	if metadata.Locked.Type == flake.TypeGitHub && metadata.Locked.Rev != "" {
+		// GitHub refs can't have a branch or tag and a revision.
+		metadata.Locked.Ref = ""
+	}
+	return FlakeMetadata{
+		Locked:       metadata.Locked,
+		LastModified: time.Unix(metadata.LastModified, 0).UTC(),
+	}, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"testing"
+	"time"
+
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+func TestParseFlakeMetadata(t *testing.T) {
+	out := []byte(`{
+		"description": "Pure Nix flake utility functions",
+		"lastModified": 1710146030,
+		"locked": {
+			"lastModified": 1710146030,
+			"narHash": "sha256-SZ5L6eA7HJ/nmkzGG7/ISclqe6oZdOZTNoesiInkXPQ=",
+			"owner": "numtide",
+			"repo": "flake-utils",
+			"rev": "b1d9ab70662946ef0850d488da1c9019f3a9752a",
+			"type": "github"
+		},
+		"original": {"owner": "numtide", "repo": "flake-utils", "type": "github"},
+		"originalUrl": "github:numtide/flake-utils",
+		"url": "github:numtide/flake-utils/b1d9ab70662946ef0850d488da1c9019f3a9752a"
+	}`)
+	got, err := parseFlakeMetadata(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := flake.Ref{
+		Type:    flake.TypeGitHub,
+		Owner:   "numtide",
+		Repo:    "flake-utils",
+		Rev:     "b1d9ab70662946ef0850d488da1c9019f3a9752a",
+		NarHash: "sha256-SZ5L6eA7HJ/nmkzGG7/ISclqe6oZdOZTNoesiInkXPQ=",
+	}
+	if got.Locked != want {
+		t.Errorf("got locked ref %+v, want %+v", got.Locked, want)
+	}
+	if wantTime := time.Unix(1710146030, 0).UTC(); !got.LastModified.Equal(wantTime) {
+		t.Errorf("got last modified %s, want %s", got.LastModified, wantTime)
+	}
+
+	if _, err := parseFlakeMetadata([]byte(`{"locked": {"type": "path", "path": "/flake"}}`)); err == nil {
+		t.Error("got nil error for metadata without a narHash")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// Generation is one generation of a Nix profile. Nix keeps each generation as
+// a "<profile>-<number>-link" symlink next to the profile, and each one is a
+// garbage collector root until it's removed.
+type Generation struct {
+	Profile string    `json:"profile"`
+	Number  int       `json:"number"`
+	Link    string    `json:"link"`
+	Path    string    `json:"path"`
+	Created time.Time `json:"created"`
+	// Current is true for the generation that the profile points to.
+	Current bool `json:"current"`
+}
+
+// ProfileGenerations returns the generations of profile, oldest first. A
+// profile that doesn't exist has no generations.
+func ProfileGenerations(profile string) ([]Generation, error) {
+	dir, name := filepath.Split(profile)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	current, _ := os.Readlink(profile)
+
+	linkRegexp := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-(\d+)-link$`)
+	var gens []Generation
+	for _, entry := range entries {
+		m := linkRegexp.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		number, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		link := filepath.Join(dir, entry.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			debug.Log("nix: reading profile generation %s: %v", link, err)
+			continue
+		}
+		// Nix dates a generation by the time its link was created.
+		info, err := os.Lstat(link)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		gens = append(gens, Generation{
+			Profile: profile,
+			Number:  number,
+			Link:    link,
+			Path:    target,
+			Created: info.ModTime(),
+			Current: current == entry.Name() || current == link,
+		})
+	}
+	slices.SortFunc(gens, func(a, b Generation) int { return a.Number - b.Number })
+	return gens, nil
+}
+
+// GCRoot is a garbage collector root and the store path it keeps alive.
+type GCRoot struct {
+	// Link is the root, such as a profile generation link. Nix prints
+	// runtime roots that the user may not see as "{censored}", so several
+	// roots can have the same Link.
+	Link string
+	Path string
+}
+
+// GCRoots returns the garbage collector roots of the store.
+func GCRoots(ctx context.Context) ([]GCRoot, error) {
+	defer debug.FunctionTimer().End()
+	cmd := exec.CommandContext(ctx, "nix-store", append([]string{"--gc", "--print-roots"}, StoreFlags()...)...)
+	debug.Log("Running cmd %s", cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, redact.Errorf("nix-store --gc --print-roots: %w: %s", err, exitErr.Stderr)
+		}
+		return nil, redact.Errorf("nix-store --gc --print-roots: %w", err)
+	}
+	return parseGCRoots(out), nil
+}
+
+// parseGCRoots parses the output of nix-store --gc --print-roots, which has a
+// line for each root such as
+//
+//	/home/user/project/.devbox/nix/profile/default-2-link -> /nix/store/...-profile
+//	{censored} -> /nix/store/...-glibc-2.39
+func parseGCRoots(out []byte) []GCRoot {
+	var roots []GCRoot
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		link, path, found := strings.Cut(scanner.Text(), " -> ")
+		if found && strings.HasPrefix(path, "/") {
+			roots = append(roots, GCRoot{Link: link, Path: path})
+		}
+	}
+	return roots
+}
+
+// DeleteStorePaths deletes storePaths from the store. Nix refuses to delete a
+// path that's still reachable from a garbage collector root.
+func DeleteStorePaths(ctx context.Context, storePaths ...string) error {
+	defer debug.FunctionTimer().End()
+	if len(storePaths) == 0 {
+		return nil
+	}
+	cmd := commandContext(ctx, append([]string{"store", "delete"}, storePaths...)...)
+	debug.Log("Running cmd %s", cmd)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return redact.Errorf("nix store delete: %w: %s", err, out)
+	}
+	return nil
+}
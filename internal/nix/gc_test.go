@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProfileGenerations(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "default")
+	links := map[string]string{
+		"default-1-link":  "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-profile",
+		"default-2-link":  "/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-profile",
+		"default-10-link": "/nix/store/cccccccccccccccccccccccccccccccc-profile",
+		"other-1-link":    "/nix/store/dddddddddddddddddddddddddddddddd-profile",
+		"default":         "default-2-link",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gens, err := ProfileGenerations(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gens) != 3 {
+		t.Fatalf("got %d generations, want 3: %+v", len(gens), gens)
+	}
+	for i, want := range []int{1, 2, 10} {
+		if gens[i].Number != want {
+			t.Errorf("got generation %d at index %d, want %d", gens[i].Number, i, want)
+		}
+		if gens[i].Current != (want == 2) {
+			t.Errorf("got generation %d current = %v", want, gens[i].Current)
+		}
+		if gens[i].Created.IsZero() || time.Since(gens[i].Created) > time.Hour {
+			t.Errorf("got generation %d created at %v, want the time its link was made", want, gens[i].Created)
+		}
+	}
+	if got, want := gens[2].Path, links["default-10-link"]; got != want {
+		t.Errorf("got generation 10 path %q, want %q", got, want)
+	}
+
+	gens, err = ProfileGenerations(filepath.Join(dir, "missing", "default"))
+	if err != nil || len(gens) != 0 {
+		t.Errorf("got generations %v, error %v for a missing profile, want none", gens, err)
+	}
+}
+
+func TestParseGCRoots(t *testing.T) {
+	out := []byte(`/home/user/project/.devbox/nix/profile/default-2-link -> /nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-profile
+/proc/1234/maps -> /nix/store/eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee-glibc-2.39
+{censored} -> /nix/store/ffffffffffffffffffffffffffffffff-bash-5.2
+{censored} -> /nix/store/gggggggggggggggggggggggggggggggg-coreutils-9.5
+{temp:5678} -> {censored}
+`)
+	roots := parseGCRoots(out)
+	want := []GCRoot{
+		{"/home/user/project/.devbox/nix/profile/default-2-link", "/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-profile"},
+		{"/proc/1234/maps", "/nix/store/eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee-glibc-2.39"},
+		{"{censored}", "/nix/store/ffffffffffffffffffffffffffffffff-bash-5.2"},
+		{"{censored}", "/nix/store/gggggggggggggggggggggggggggggggg-coreutils-9.5"},
+	}
+	if !slices.Equal(roots, want) {
+		t.Errorf("got roots %v, want %v", roots, want)
+	}
+}
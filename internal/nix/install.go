@@ -5,11 +5,16 @@ package nix
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	_ "embed"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
+	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/fatih/color"
@@ -20,33 +25,114 @@ import (
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/cmdutil"
 	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/httpclient"
 	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/ux"
 )
 
 const rootError = "warning: installing Nix as root is not supported by this script!"
 
+// InstallVersion is the version of Nix that devbox installs when it isn't
+// asked for a different one.
+const InstallVersion = "2.18.1"
+
+// The installers that devbox can install Nix with.
+const (
+	// InstallerNixOS is the install script published with each Nix release
+	// on releases.nixos.org.
+	InstallerNixOS = "nixos"
+
+	// InstallerDeterminate is the Determinate Systems installer. It always
+	// installs the daemon, survives macOS upgrades and can uninstall Nix.
+	InstallerDeterminate = "determinate"
+)
+
+// determinateReceipt is the file that the Determinate installer leaves behind
+// so that it can uninstall Nix later.
+const determinateReceipt = "/nix/receipt.json"
+
+// InstallOpts are the options for InstallWith.
+type InstallOpts struct {
+	// Version is the Nix version to install. It defaults to InstallVersion.
+	Version string
+
+	// Installer is InstallerNixOS or InstallerDeterminate. It defaults to
+	// InstallerNixOS.
+	Installer string
+
+	// Daemon has 3 states: nil lets the installer decide, false installs
+	// single-user Nix and true installs the daemon.
+	Daemon *bool
+}
+
+var installVersionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// installScript returns the shell command that installs Nix for the system,
+// such as "x86_64-linux".
+func installScript(opts InstallOpts, system string) (string, error) {
+	version := cmp.Or(opts.Version, InstallVersion)
+	if !installVersionRegexp.MatchString(version) {
+		return "", usererr.New("%q isn't a Nix release. Use a version such as %s.", version, InstallVersion)
+	}
+
+	switch cmp.Or(opts.Installer, InstallerNixOS) {
+	case InstallerNixOS:
+		script := fmt.Sprintf("curl -L https://releases.nixos.org/nix/nix-%s/install | sh -s", version)
+		if opts.Daemon != nil {
+			if *opts.Daemon {
+				script += " -- --daemon"
+			} else {
+				script += " -- --no-daemon"
+			}
+		}
+		return script, nil
+	case InstallerDeterminate:
+		if opts.Daemon != nil && !*opts.Daemon {
+			return "", usererr.New("The Determinate installer always installs the Nix daemon. " +
+				"Use --installer nixos to install Nix in single-user mode.")
+		}
+		return fmt.Sprintf("curl --proto '=https' --tlsv1.2 -sSf -L https://install.determinate.systems/nix | "+
+			"sh -s -- install --no-confirm --nix-package-url https://releases.nixos.org/nix/nix-%[1]s/nix-%[1]s-%[2]s.tar.xz",
+			version, system), nil
+	default:
+		return "", usererr.New("Unknown Nix installer %q. Use %q or %q.", opts.Installer, InstallerNixOS, InstallerDeterminate)
+	}
+}
+
+// hostSystem returns the Nix system of this machine without asking Nix, which
+// might not be installed yet.
+func hostSystem() string {
+	arch := runtime.GOARCH
+	switch arch {
+	case "amd64":
+		arch = "x86_64"
+	case "arm64":
+		arch = "aarch64"
+	}
+	return arch + "-" + runtime.GOOS
+}
+
 // Install runs the install script for Nix. daemon has 3 states
 // nil is unset. false is --no-daemon. true is --daemon.
 func Install(writer io.Writer, daemon *bool) error {
+	return InstallWith(writer, InstallOpts{Daemon: daemon})
+}
+
+// InstallWith installs Nix with the installer and version in opts.
+func InstallWith(writer io.Writer, opts InstallOpts) error {
 	if isRoot() && build.OS() == build.OSWSL {
 		return usererr.New("Nix cannot be installed as root on WSL. Please run as a normal user with sudo access.")
 	}
+	installScript, err := installScript(opts, hostSystem())
+	if err != nil {
+		return err
+	}
 	r, w, err := os.Pipe()
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer r.Close()
 
-	installScript := "curl -L https://releases.nixos.org/nix/nix-2.18.1/install | sh -s"
-	if daemon != nil {
-		if *daemon {
-			installScript += " -- --daemon"
-		} else {
-			installScript += " -- --no-daemon"
-		}
-	}
-
 	fmt.Fprintf(writer, "Installing nix with: %s\nThis may require sudo access.\n", installScript)
 
 	cmd := exec.Command("sh", "-c", installScript)
@@ -120,7 +206,7 @@ func EnsureNixInstalled(writer io.Writer, withDaemonFunc func() *bool) (err erro
 		if !version.AtLeast(MinVersion) {
 			err = usererr.New(
 				"Devbox requires nix of version >= %s. Your version is %s. "+
-					"Please upgrade nix and try again.\n",
+					"Run `devbox nix upgrade` to upgrade it and try again.\n",
 				MinVersion,
 				version,
 			)
@@ -169,3 +255,130 @@ func EnsureNixInstalled(writer io.Writer, withDaemonFunc func() *bool) (err erro
 	fmt.Fprintln(writer, "Nix installed successfully. Devbox is ready to use!")
 	return nil
 }
+
+// InstalledBy returns InstallerDeterminate if the Determinate installer
+// installed Nix on this machine. Otherwise it returns InstallerNixOS if there's
+// a /nix directory, which is what the nixos.org script and most other ways of
+// installing Nix create, or "" if there's no /nix.
+func InstalledBy() string {
+	if fileutil.Exists(determinateReceipt) {
+		return InstallerDeterminate
+	}
+	if dirExists() {
+		return InstallerNixOS
+	}
+	return ""
+}
+
+// multiUser reports whether Nix was installed with a daemon, which makes
+// changes to the installation require root.
+func multiUser() bool {
+	return fileutil.Exists("/nix/var/nix/daemon-socket")
+}
+
+// Upgrade upgrades the installed Nix to version, or to the latest stable
+// release if version is "". Multi-user installs are upgraded with sudo.
+func Upgrade(ctx context.Context, writer io.Writer, version string) error {
+	if !BinaryInstalled() {
+		return usererr.New("Nix isn't installed. Run `devbox nix install` to install it.")
+	}
+	args := []string{"upgrade-nix"}
+	if version != "" {
+		if !installVersionRegexp.MatchString(version) {
+			return usererr.New("%q isn't a Nix release. Use a version such as %s.", version, InstallVersion)
+		}
+		path, err := releaseStorePath(ctx, version, hostSystem())
+		if err != nil {
+			return err
+		}
+		args = append(args, "--nix-store-path", path)
+	}
+	args = append(args, ExperimentalFlags()...)
+
+	name := "nix"
+	if multiUser() && !isRoot() {
+		// sudo -i runs the root user's Nix, which is the one that the
+		// daemon runs.
+		name, args = "sudo", append([]string{"-i", "nix"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	fmt.Fprintf(writer, "Upgrading nix with: %s\n", cmd)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Run(); err != nil {
+		return redact.Errorf("nix: upgrade: %w", err)
+	}
+	return nil
+}
+
+// fallbackPathRegexp matches the store path of one system in a release's
+// fallback-paths.nix, such as
+//
+//	x86_64-linux = "/nix/store/...-nix-2.18.1";
+var fallbackPathRegexp = regexp.MustCompile(`(?m)^\s*"?([\w-]+)"?\s*=\s*"(/nix/store/[^"]+)"`)
+
+// releaseStorePath returns the store path of the Nix release for system,
+// which nix upgrade-nix substitutes from cache.nixos.org.
+func releaseStorePath(ctx context.Context, version, system string) (string, error) {
+	url := fmt.Sprintf("https://releases.nixos.org/nix/nix-%s/fallback-paths.nix", version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	resp, err := httpclient.Client().Do(req)
+	if err != nil {
+		return "", redact.Errorf("nix: get release %s: %w", redact.Safe(version), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", usererr.New("Nix %s isn't a Nix release.", version)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", redact.Errorf("nix: get release %s: %s", redact.Safe(version), redact.Safe(resp.Status))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	path, ok := parseFallbackPaths(body)[system]
+	if !ok {
+		return "", usererr.New("Nix %s wasn't released for %s.", version, system)
+	}
+	return path, nil
+}
+
+// parseFallbackPaths returns the store path of each system in a release's
+// fallback-paths.nix.
+func parseFallbackPaths(data []byte) map[string]string {
+	paths := map[string]string{}
+	for _, m := range fallbackPathRegexp.FindAllSubmatch(data, -1) {
+		paths[string(m[1])] = string(m[2])
+	}
+	return paths
+}
+
+// Uninstall removes Nix from this machine. Only installs made by the
+// Determinate installer can be uninstalled automatically, since the nixos.org
+// script doesn't keep track of what it changed.
+func Uninstall(ctx context.Context, writer io.Writer) error {
+	switch InstalledBy() {
+	case "":
+		return usererr.New("Nix isn't installed in /nix.")
+	case InstallerDeterminate:
+		// The uninstaller asks for confirmation itself, so it gets the
+		// terminal.
+		cmd := exec.CommandContext(ctx, "/nix/nix-installer", "uninstall")
+		fmt.Fprintf(writer, "Uninstalling nix with: %s\nThis may require sudo access.\n", cmd)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = writer
+		cmd.Stderr = writer
+		if err := cmd.Run(); err != nil {
+			return redact.Errorf("nix: uninstall: %w", err)
+		}
+		return nil
+	default:
+		return usererr.New("Nix wasn't installed by the Determinate installer, so devbox can't uninstall it. " +
+			"Follow https://nix.dev/manual/nix/stable/installation/uninstall to remove it.")
+	}
+}
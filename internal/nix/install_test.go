@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestInstallScript(t *testing.T) {
+	tests := []struct {
+		opts InstallOpts
+		want string
+	}{
+		{
+			opts: InstallOpts{},
+			want: "curl -L https://releases.nixos.org/nix/nix-" + InstallVersion + "/install | sh -s",
+		},
+		{
+			opts: InstallOpts{Version: "2.24.9", Daemon: lo.ToPtr(true)},
+			want: "curl -L https://releases.nixos.org/nix/nix-2.24.9/install | sh -s -- --daemon",
+		},
+		{
+			opts: InstallOpts{Installer: InstallerNixOS, Daemon: lo.ToPtr(false)},
+			want: "curl -L https://releases.nixos.org/nix/nix-" + InstallVersion + "/install | sh -s -- --no-daemon",
+		},
+		{
+			opts: InstallOpts{Installer: InstallerDeterminate, Version: "2.24.9"},
+			want: "curl --proto '=https' --tlsv1.2 -sSf -L https://install.determinate.systems/nix | " +
+				"sh -s -- install --no-confirm --nix-package-url " +
+				"https://releases.nixos.org/nix/nix-2.24.9/nix-2.24.9-aarch64-darwin.tar.xz",
+		},
+	}
+	for _, test := range tests {
+		got, err := installScript(test.opts, "aarch64-darwin")
+		if err != nil {
+			t.Errorf("installScript(%+v) error: %v", test.opts, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("installScript(%+v) = %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestInstallScriptErrors(t *testing.T) {
+	tests := []InstallOpts{
+		{Version: "latest"},
+		{Version: "2.18.1; rm -rf ~"},
+		{Installer: "brew"},
+		{Installer: InstallerDeterminate, Daemon: lo.ToPtr(false)},
+	}
+	for _, opts := range tests {
+		if got, err := installScript(opts, "x86_64-linux"); err == nil {
+			t.Errorf("installScript(%+v) = %q, want error", opts, got)
+		}
+	}
+}
+
+func TestParseFallbackPaths(t *testing.T) {
+	data := []byte(`{
+  x86_64-linux = "/nix/store/azvn85cras6xv4z5j85fiy406f24r1q0-nix-2.18.1";
+  i686-linux = "/nix/store/9njs2dbk5dpw4y8v0hrmnp9zmr9f6ny8-nix-2.18.1";
+  aarch64-darwin = "/nix/store/iqmbsaa884h9yg1b2gsgawz1nqqzat2s-nix-2.18.1";
+}
+`)
+	got := parseFallbackPaths(data)
+	if len(got) != 3 {
+		t.Errorf("got %d systems, want 3: %v", len(got), got)
+	}
+	want := "/nix/store/iqmbsaa884h9yg1b2gsgawz1nqqzat2s-nix-2.18.1"
+	if got["aarch64-darwin"] != want {
+		t.Errorf("got aarch64-darwin = %q, want %q", got["aarch64-darwin"], want)
+	}
+}
+
+func TestVersionCheck(t *testing.T) {
+	tests := []struct {
+		version string
+		ok      bool
+		fix     bool
+	}{
+		{version: "2.11.1", ok: false, fix: true},
+		{version: "2.16.0", ok: true, fix: true},
+		{version: "2.18.1", ok: true, fix: false},
+	}
+	for _, test := range tests {
+		got := versionCheck(VersionInfo{Version: test.version}, nil)
+		if got.OK != test.ok || (got.Fix != "") != test.fix {
+			t.Errorf("versionCheck(%s) = %+v, want OK=%v and a fix=%v", test.version, got, test.ok, test.fix)
+		}
+		if !strings.Contains(got.Detail, test.version) {
+			t.Errorf("versionCheck(%s).Detail = %q, want it to mention the version", test.version, got.Detail)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/ux/stepper"
+)
+
+// logFormatFlags make Nix write its log to stderr as JSON events, one per line
+// after an "@nix " prefix, instead of drawing its own progress bar.
+var logFormatFlags = []string{"--log-format", "internal-json"}
+
+// logLinePrefix starts every line of the internal-json log.
+const logLinePrefix = "@nix "
+
+// The types of the activities in the internal-json log that devbox follows.
+// They're defined in Nix's logging.hh.
+const (
+	actCopyPath   = 100
+	actCopyPaths  = 103
+	actBuilds     = 104
+	actBuild      = 105
+	actSubstitute = 108
+)
+
+// The types of the results in the internal-json log that devbox follows.
+const (
+	resBuildLogLine     = 101
+	resSetPhase         = 104
+	resProgress         = 105
+	resPostBuildLogLine = 107
+)
+
+// The levels of Nix messages.
+const (
+	lvlError = 0
+	lvlInfo  = 3
+)
+
+// logEvent is a line of the internal-json log.
+type logEvent struct {
+	Action string `json:"action"`
+	ID     uint64 `json:"id"`
+	Level  int    `json:"level"`
+	Type   int    `json:"type"`
+	Text   string `json:"text"`
+	Msg    string `json:"msg"`
+	Fields []any  `json:"fields"`
+}
+
+func (e *logEvent) field(i int) string {
+	if i >= len(e.Fields) {
+		return ""
+	}
+	if s, ok := e.Fields[i].(string); ok {
+		return s
+	}
+	return fmt.Sprint(e.Fields[i])
+}
+
+func (e *logEvent) intField(i int) int64 {
+	if i >= len(e.Fields) {
+		return 0
+	}
+	n, _ := e.Fields[i].(float64)
+	return int64(n)
+}
+
+// BuildProgress is what a nix command is doing, as reported by its log.
+type BuildProgress struct {
+	// Copied and Copies count the store paths that have been, and will be,
+	// downloaded or copied into the store.
+	Copied int64 `json:"copied"`
+	Copies int64 `json:"copies"`
+	// CopiedBytes and CopyBytes are the bytes of those paths.
+	CopiedBytes int64 `json:"copied_bytes"`
+	CopyBytes   int64 `json:"copy_bytes"`
+	// Built and Builds count the derivations that have been, and will be,
+	// built.
+	Built  int64 `json:"built"`
+	Builds int64 `json:"builds"`
+	// Building is the name of a derivation that's being built, and Phase is
+	// its current build phase.
+	Building string `json:"building,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+}
+
+// String summarizes p on one line, such as
+//
+//	downloaded 3/12 paths (40.2/118.5 MiB), building hello-2.12.1 (buildPhase)
+func (p BuildProgress) String() string {
+	var parts []string
+	if p.Copies > 0 {
+		part := fmt.Sprintf("downloaded %d/%d paths", p.Copied, p.Copies)
+		if p.CopyBytes > 0 {
+			part += fmt.Sprintf(" (%.1f/%.1f MiB)", float64(p.CopiedBytes)/(1<<20), float64(p.CopyBytes)/(1<<20))
+		}
+		parts = append(parts, part)
+	}
+	if p.Builds > 0 && p.Building == "" {
+		parts = append(parts, fmt.Sprintf("built %d/%d", p.Built, p.Builds))
+	}
+	if p.Building != "" {
+		part := "building " + p.Building
+		if p.Phase != "" {
+			part += " (" + p.Phase + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+type activity struct {
+	typ int
+	// done and expected are the activity's last progress.
+	done, expected int64
+}
+
+// logWriter is the stderr of a nix command that runs with logFormatFlags. It
+// writes the messages that Nix would print to out, shows a progress line
+// instead of Nix's progress bar, and saves everything, including build logs,
+// to log.
+type logWriter struct {
+	mu         sync.Mutex
+	out        io.Writer
+	log        io.Writer
+	onProgress func(BuildProgress)
+	spinner    *stepper.Stepper
+	tty        bool
+
+	partial []byte
+	// passthrough is set while the rest of a line that isn't a log line
+	// is still to come.
+	passthrough bool
+	activities  map[uint64]*activity
+	progress    BuildProgress
+	// copiedBytes and copyBytes add up the paths that finished copying.
+	copiedBytes, copyBytes int64
+	// errors are the error messages that Nix logged.
+	errors []string
+}
+
+func newLogWriter(out, log io.Writer, onProgress func(BuildProgress)) *logWriter {
+	w := &logWriter{
+		out:        out,
+		log:        log,
+		onProgress: onProgress,
+		activities: map[uint64]*activity{},
+	}
+	if f, ok := out.(*os.File); ok && isatty.IsTerminal(f.Fd()) && !debug.IsEnabled() {
+		w.tty = true
+	}
+	return w
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for len(w.partial) > 0 {
+		i := bytes.IndexByte(w.partial, '\n')
+		if w.passthrough || !isLogLineStart(w.partial) {
+			// Nix writes some output, such as the prompts about
+			// flake settings, without going through the logger and
+			// without a newline before it waits for an answer, so
+			// it's written as soon as it's clear it isn't a log line.
+			chunk := w.partial
+			if i >= 0 {
+				chunk = w.partial[:i+1]
+			}
+			w.printRaw(chunk)
+			w.passthrough = i < 0
+			w.partial = w.partial[len(chunk):]
+			continue
+		}
+		if i < 0 {
+			break
+		}
+		w.handleLine(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// isLogLineStart returns true if b is, or could become, a line of the
+// internal-json log.
+func isLogLineStart(b []byte) bool {
+	if len(b) < len(logLinePrefix) {
+		return strings.HasPrefix(logLinePrefix, string(b))
+	}
+	return bytes.HasPrefix(b, []byte(logLinePrefix))
+}
+
+// Close handles the last line if it didn't end with a newline and clears the
+// progress line.
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		w.printRaw([]byte("\n"))
+		w.passthrough = false
+	}
+	if len(w.partial) > 0 {
+		w.handleLine(string(w.partial))
+		w.partial = nil
+	}
+	w.clearProgress()
+	return nil
+}
+
+// Errors returns the error messages that Nix logged.
+func (w *logWriter) Errors() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.errors, "\n")
+}
+
+func (w *logWriter) handleLine(line string) {
+	data, ok := strings.CutPrefix(line, logLinePrefix)
+	if !ok {
+		// Write passes other output through, so this is the start of
+		// the prefix that Close found at the end.
+		w.print(line)
+		return
+	}
+	var event logEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		debug.Log("nix: parsing log line %q: %v", line, err)
+		w.print(line)
+		return
+	}
+
+	switch event.Action {
+	case "msg":
+		if event.Level == lvlError {
+			w.errors = append(w.errors, stripANSI(event.Msg))
+		}
+		w.print(event.Msg)
+	case "start":
+		w.activities[event.ID] = &activity{typ: event.Type}
+		if event.Text != "" && event.Level <= lvlInfo {
+			w.writeLog(event.Text)
+		}
+		if event.Type != actBuild && event.Type != actSubstitute {
+			return
+		}
+		// Without a progress line, say what's downloaded and built
+		// the way Nix does.
+		if !w.tty && event.Text != "" {
+			w.printOut(event.Text)
+		}
+		if event.Type == actBuild {
+			w.progress.Building, w.progress.Phase = derivationName(event.field(0)), ""
+			w.updateProgress()
+		}
+	case "stop":
+		act := w.activities[event.ID]
+		delete(w.activities, event.ID)
+		switch {
+		case act == nil:
+		case act.typ == actBuild:
+			w.progress.Building, w.progress.Phase = "", ""
+			w.updateProgress()
+		case act.typ == actCopyPath:
+			w.copiedBytes += act.done
+			w.copyBytes += act.expected
+		}
+	case "result":
+		w.handleResult(&event)
+	}
+}
+
+func (w *logWriter) handleResult(event *logEvent) {
+	act := w.activities[event.ID]
+	switch event.Type {
+	case resBuildLogLine, resPostBuildLogLine:
+		w.writeLog(event.field(0))
+	case resSetPhase:
+		if act != nil && act.typ == actBuild {
+			w.progress.Phase = event.field(0)
+			w.updateProgress()
+		}
+	case resProgress:
+		if act == nil {
+			return
+		}
+		act.done, act.expected = event.intField(0), event.intField(1)
+		switch act.typ {
+		case actCopyPaths:
+			w.progress.Copied, w.progress.Copies = act.done, act.expected
+		case actBuilds:
+			w.progress.Built, w.progress.Builds = act.done, act.expected
+		case actCopyPath:
+			w.progress.CopiedBytes, w.progress.CopyBytes = w.copiedBytes, w.copyBytes
+			for _, a := range w.activities {
+				if a.typ == actCopyPath {
+					w.progress.CopiedBytes += a.done
+					w.progress.CopyBytes += a.expected
+				}
+			}
+		default:
+			return
+		}
+		w.updateProgress()
+	}
+}
+
+// print writes a message that Nix would have printed to out and the log.
+func (w *logWriter) print(msg string) {
+	w.writeLog(msg)
+	w.printOut(msg)
+}
+
+func (w *logWriter) printOut(msg string) {
+	if w.spinner != nil {
+		// Clear the progress line so that the message doesn't end
+		// up on it. The next update draws it again.
+		w.clearProgress()
+	}
+	fmt.Fprintln(w.out, msg)
+}
+
+// printRaw writes output that isn't a log line to out and the log as is.
+func (w *logWriter) printRaw(b []byte) {
+	if w.spinner != nil {
+		w.clearProgress()
+	}
+	_, _ = w.out.Write(b)
+	if w.log != nil {
+		fmt.Fprint(w.log, stripANSI(string(b)))
+	}
+}
+
+func (w *logWriter) writeLog(line string) {
+	if w.log != nil {
+		fmt.Fprintln(w.log, stripANSI(line))
+	}
+}
+
+func (w *logWriter) updateProgress() {
+	if w.onProgress != nil {
+		w.onProgress(w.progress)
+	}
+	if !w.tty {
+		return
+	}
+	msg := w.progress.String()
+	switch {
+	case msg == "":
+		w.clearProgress()
+	case w.spinner == nil:
+		w.spinner = stepper.Start(w.out, "%s", msg)
+	default:
+		w.spinner.Display("%s", msg)
+	}
+}
+
+func (w *logWriter) clearProgress() {
+	if w.spinner != nil {
+		w.spinner.Clear()
+		w.spinner = nil
+	}
+}
+
+// derivationName returns the name of the derivation at drvPath, without its
+// hash and extension, such as "hello-2.12.1".
+func derivationName(drvPath string) string {
+	name := strings.TrimSuffix(path.Base(drvPath), ".drv")
+	if _, after, ok := strings.Cut(name, "-"); ok {
+		return after
+	}
+	return name
+}
+
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func stripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const sampleLog = `@nix {"action":"start","id":1,"level":0,"parent":0,"text":"","type":104,"fields":[]}
+@nix {"action":"start","id":2,"level":0,"parent":0,"text":"","type":103,"fields":[]}
+@nix {"action":"result","id":2,"type":105,"fields":[0,2,0,0]}
+@nix {"action":"start","id":3,"level":3,"parent":0,"text":"copying path '/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12.1' from 'https://cache.nixos.org'","type":108,"fields":["/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12.1","https://cache.nixos.org"]}
+@nix {"action":"start","id":4,"level":0,"parent":3,"text":"","type":100,"fields":[]}
+@nix {"action":"result","id":4,"type":105,"fields":[1048576,2097152,0,0]}
+@nix {"action":"stop","id":4}
+@nix {"action":"stop","id":3}
+@nix {"action":"result","id":2,"type":105,"fields":[1,2,0,0]}
+@nix {"action":"result","id":1,"type":105,"fields":[0,1,0,0]}
+@nix {"action":"start","id":5,"level":3,"parent":0,"text":"building '/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv'","type":105,"fields":["/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv","",1,1]}
+@nix {"action":"result","id":5,"type":104,"fields":["buildPhase"]}
+@nix {"action":"result","id":5,"type":101,"fields":["gcc -O2 -c jq.c"]}
+@nix {"action":"msg","level":0,"msg":"\u001b[31;1merror:\u001b[0m builder for '/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv' failed with exit code 2"}
+@nix {"action":"stop","id":5}
+warning: not a JSON line`
+
+func TestLogWriter(t *testing.T) {
+	var out, log bytes.Buffer
+	var progress []BuildProgress
+	w := newLogWriter(&out, &log, func(p BuildProgress) { progress = append(progress, p) })
+	// Nix doesn't write whole lines at a time.
+	for _, chunk := range strings.SplitAfter(sampleLog, "}") {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	wantOut := "copying path '/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12.1' from 'https://cache.nixos.org'\n" +
+		"building '/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv'\n" +
+		"\x1b[31;1merror:\x1b[0m builder for '/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv' failed with exit code 2\n" +
+		"warning: not a JSON line\n"
+	if out.String() != wantOut {
+		t.Errorf("got output:\n%s\nwant:\n%s", out.String(), wantOut)
+	}
+	if !strings.Contains(log.String(), "gcc -O2 -c jq.c\n") {
+		t.Errorf("got log without the build's output:\n%s", log.String())
+	}
+	if strings.Contains(log.String(), "\x1b[") {
+		t.Errorf("got log with terminal escapes:\n%q", log.String())
+	}
+	wantErrors := "error: builder for '/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7.drv' failed with exit code 2"
+	if got := w.Errors(); got != wantErrors {
+		t.Errorf("got errors %q, want %q", got, wantErrors)
+	}
+
+	want := []string{
+		"downloaded 0/2 paths",
+		"downloaded 0/2 paths (1.0/2.0 MiB)",
+		"downloaded 1/2 paths (1.0/2.0 MiB)",
+		"downloaded 1/2 paths (1.0/2.0 MiB), built 0/1",
+		"downloaded 1/2 paths (1.0/2.0 MiB), building jq-1.7",
+		"downloaded 1/2 paths (1.0/2.0 MiB), building jq-1.7 (buildPhase)",
+		"downloaded 1/2 paths (1.0/2.0 MiB), built 0/1",
+	}
+	if len(progress) != len(want) {
+		t.Fatalf("got %d progress updates, want %d: %v", len(progress), len(want), progress)
+	}
+	for i := range want {
+		if got := progress[i].String(); got != want[i] {
+			t.Errorf("got progress %d %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestLogWriterPassesThroughPrompts(t *testing.T) {
+	var out, log bytes.Buffer
+	w := newLogWriter(&out, &log, nil)
+	prompt := "do you want to allow configuration setting 'extra-substituters' to be set to 'https://cache.acme.internal' (y/N)? "
+	for _, chunk := range []string{"@n", "ix {\"action\":\"msg\",\"level\":3,\"msg\":\"hi\"}\n", prompt[:10], prompt[10:]} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The prompt is written before Nix gets an answer, so before the line
+	// ends.
+	if want := "hi\n" + prompt; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+	if _, err := w.Write([]byte("\n@nix {\"action\":\"msg\",\"level\":3,\"msg\":\"bye\"}\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if want := "hi\n" + prompt + "\nbye\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+}
@@ -34,6 +34,11 @@ const ProfilePath = ".devbox/nix/profile/default"
 
 type PrintDevEnvOut struct {
 	Variables map[string]Variable // the key is the name.
+
+	// InputHash is the printDevEnvInputHash of the flake that the
+	// variables were computed from, if they can be reused until it
+	// changes.
+	InputHash string `json:",omitempty"`
 }
 
 type Variable struct {
@@ -45,62 +50,106 @@ type PrintDevEnvArgs struct {
 	FlakeDir             string
 	PrintDevEnvCachePath string
 	UsePrintDevEnvCache  bool
+
+	// LockfileHash is the hash of the devbox.lock that the flake was
+	// generated from. Without UsePrintDevEnvCache, the cache is still used
+	// if it was computed from the same flake and lockfile.
+	LockfileHash string
 }
 
 // PrintDevEnv calls `nix print-dev-env -f <path>` and returns its output. The output contains
 // all the environment variables and bash functions required to create a nix shell.
+//
+// The output is cached in PrintDevEnvCachePath along with a hash of its
+// inputs, so that it's only evaluated again when the flake, the lockfile or
+// Nix changes. A flake whose output is cached that way is evaluated with
+// --pure-eval, which makes sure that the output doesn't depend on anything
+// else, such as environment variables.
 func (*Nix) PrintDevEnv(ctx context.Context, args *PrintDevEnvArgs) (*PrintDevEnvOut, error) {
 	defer debug.FunctionTimer().End()
 	defer trace.StartRegion(ctx, "nixPrintDevEnv").End()
 
-	var data []byte
-	var err error
-	var out PrintDevEnvOut
-
-	if args.UsePrintDevEnvCache {
-		data, err = os.ReadFile(args.PrintDevEnvCachePath)
-		if err == nil {
-			if err := json.Unmarshal(data, &out); err != nil {
-				return nil, errors.WithStack(err)
-			}
-		} else if !errors.Is(err, fs.ErrNotExist) {
-			return nil, errors.WithStack(err)
+	cached, err := readPrintDevEnvCache(args.PrintDevEnvCachePath)
+	if err != nil && args.UsePrintDevEnvCache {
+		return nil, err
+	}
+	var inputHash string
+	if !args.UsePrintDevEnvCache || cached == nil {
+		inputHash, err = printDevEnvInputHash(args.FlakeDir, args.LockfileHash)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil && (inputHash == "" || cached.InputHash != inputHash) {
+			cached = nil
 		}
 	}
+	if cached != nil {
+		debug.Log("Using cached print-dev-env output from %s", args.PrintDevEnvCachePath)
+		return cached, nil
+	}
 
 	flakeDirResolved, err := filepath.EvalSymlinks(args.FlakeDir)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	if len(data) == 0 {
-		cmd := exec.CommandContext(
-			ctx,
-			"nix", "print-dev-env",
-			"path:"+flakeDirResolved,
-		)
-		cmd.Args = append(cmd.Args, ExperimentalFlags()...)
-		cmd.Args = append(cmd.Args, "--json")
-		debug.Log("Running print-dev-env cmd: %s\n", cmd)
-		data, err = cmd.Output()
-		if insecure, insecureErr := IsExitErrorInsecurePackage(err, "" /*pkgName*/, "" /*installable*/); insecure {
-			return nil, insecureErr
-		} else if err != nil {
-			return nil, redact.Errorf("nix print-dev-env --json \"path:%s\": %w", flakeDirResolved, err)
-		}
+	var out PrintDevEnvOut
+	cmd := exec.CommandContext(
+		ctx,
+		"nix", "print-dev-env",
+		"path:"+flakeDirResolved,
+	)
+	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, StoreFlags()...)
+	cmd.Args = append(cmd.Args, SubstituterFlags()...)
+	cmd.Args = append(cmd.Args, "--json")
+	if inputHash != "" {
+		cmd.Args = append(cmd.Args, "--pure-eval")
+	}
+	debug.Log("Running print-dev-env cmd: %s\n", cmd)
+	data, err := cmd.Output()
+	if insecure, insecureErr := IsExitErrorInsecurePackage(err, "" /*pkgName*/, "" /*installable*/); insecure {
+		return nil, insecureErr
+	} else if err != nil {
+		return nil, redact.Errorf("nix print-dev-env --json \"path:%s\": %w", flakeDirResolved, err)
+	}
 
-		if err := json.Unmarshal(data, &out); err != nil {
-			return nil, redact.Errorf("unmarshal nix print-dev-env output: %w", redact.Safe(err))
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, redact.Errorf("unmarshal nix print-dev-env output: %w", redact.Safe(err))
+	}
+	if inputHash != "" {
+		// Evaluating a flake can write its flake.lock, so the
+		// hash is taken again for the next evaluation to match.
+		out.InputHash, err = printDevEnvInputHash(args.FlakeDir, args.LockfileHash)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if err = savePrintDevEnvCache(args.PrintDevEnvCachePath, out); err != nil {
-			return nil, redact.Errorf("savePrintDevEnvCache: %w", redact.Safe(err))
-		}
+	if err = savePrintDevEnvCache(args.PrintDevEnvCachePath, out); err != nil {
+		return nil, redact.Errorf("savePrintDevEnvCache: %w", redact.Safe(err))
 	}
 
 	return &out, nil
 }
 
+// readPrintDevEnvCache returns the output that savePrintDevEnvCache saved to
+// path, or nil if there isn't any.
+func readPrintDevEnvCache(path string) (*PrintDevEnvOut, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var out PrintDevEnvOut
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &out, nil
+}
+
 func savePrintDevEnvCache(path string, out PrintDevEnvOut) error {
 	data, err := json.Marshal(out)
 	if err != nil {
@@ -162,6 +211,7 @@ func ComputeSystem() error {
 			"nix", "eval", "--impure", "--raw", "--expr", "builtins.currentSystem",
 		)
 		cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+		cmd.Args = append(cmd.Args, StoreFlags()...)
 		out, err := cmd.Output()
 		if err != nil {
 			return err
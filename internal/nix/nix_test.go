@@ -250,3 +250,26 @@ func TestVersionInfoAtLeast(t *testing.T) {
 		info.AtLeast(v)
 	})
 }
+
+func TestParseLicenses(t *testing.T) {
+	cases := map[string][]string{
+		`{"free": true, "shortName": "mit", "spdxId": "MIT"}`: {"MIT"},
+		`[{"shortName": "asl20", "spdxId": "Apache-2.0"}, {"shortName": "bsd3", "spdxId": "BSD-3-Clause"}]`: {
+			"Apache-2.0", "BSD-3-Clause",
+		},
+		`{"free": false, "shortName": "unfree"}`: {"unfree"},
+		`"GPLv2+"`:                               {"GPLv2+"},
+	}
+	for input, want := range cases {
+		got, err := parseLicenses([]byte(input))
+		if err != nil {
+			t.Errorf("parseLicenses(%s) error: %v", input, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("parseLicenses(%s) = %v, want %v", input, got, want)
+		}
+	}
+	if _, err := parseLicenses([]byte(`42`)); err == nil {
+		t.Error("parseLicenses(42) returned no error")
+	}
+}
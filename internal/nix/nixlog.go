@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// maxLogs is how many logs of nix commands are kept for devbox log.
+const maxLogs = 10
+
+// logDir is a variable so that tests can keep logs out of the user's state
+// directory.
+var logDir = xdg.StateSubpath(filepath.FromSlash("devbox/logs"))
+
+// Logs returns the paths of the saved logs of nix commands, newest first.
+func Logs() ([]string, error) {
+	entries, err := os.ReadDir(logDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var logs []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			logs = append(logs, filepath.Join(logDir, entry.Name()))
+		}
+	}
+	// The names start with the time, so they sort oldest first.
+	slices.Sort(logs)
+	slices.Reverse(logs)
+	return logs, nil
+}
+
+// newLogFile creates the file that the full log of a nix subcommand is saved
+// to, and removes the oldest logs past maxLogs. It returns nil if the file
+// can't be created, since the log is only for troubleshooting.
+func newLogFile(subcommand string) *os.File {
+	if err := os.MkdirAll(logDir, 0o700); err != nil {
+		debug.Log("nix: creating log directory: %v", err)
+		return nil
+	}
+	// Concurrent installs can start builds at the same time, so the name
+	// ends with a random part.
+	pattern := fmt.Sprintf("%s-%s-*.log", time.Now().Format("20060102-150405.000000000"), subcommand)
+	f, err := os.CreateTemp(logDir, pattern)
+	if err != nil {
+		debug.Log("nix: creating log file: %v", err)
+		return nil
+	}
+
+	logs, _ := Logs()
+	for _, old := range logs[min(len(logs), maxLogs):] {
+		if old != f.Name() {
+			_ = os.Remove(old)
+		}
+	}
+	return f
+}
+
+// runLogged runs cmd, the nix subcommand name, with logFormatFlags. Nix's
+// messages and a progress line go to out, and the full log, including the
+// output of builds, is saved for devbox log. If cmd fails, it returns the
+// errors that Nix logged along with the error.
+func runLogged(cmd *exec.Cmd, name string, out io.Writer, onProgress func(BuildProgress)) (nixErrors string, err error) {
+	cmd.Args = append(cmd.Args, logFormatFlags...)
+	var log io.Writer
+	logFile := newLogFile(strings.ReplaceAll(name, " ", "-"))
+	if logFile != nil {
+		defer logFile.Close()
+		fmt.Fprintf(logFile, "$ %s\n", cmd)
+		log = logFile
+	}
+
+	w := newLogWriter(out, log, onProgress)
+	cmd.Stderr = w
+	err = cmd.Run()
+	w.Close()
+	if err == nil {
+		return "", nil
+	}
+	if logFile != nil {
+		fmt.Fprintf(out, "The full log of nix %s is in %s. Run `devbox log` to see it.\n", name, logFile.Name())
+	}
+	return w.Errors(), err
+}
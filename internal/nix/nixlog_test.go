@@ -0,0 +1,39 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogRotation(t *testing.T) {
+	dir := logDir
+	t.Cleanup(func() { logDir = dir })
+	logDir = t.TempDir()
+
+	var newest string
+	for range maxLogs + 3 {
+		f := newLogFile("build")
+		if f == nil {
+			t.Fatal("got no log file")
+		}
+		newest = f.Name()
+		f.Close()
+	}
+	logs, err := Logs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != maxLogs {
+		t.Errorf("got %d logs, want %d", len(logs), maxLogs)
+	}
+	if len(logs) > 0 && logs[0] != newest {
+		t.Errorf("got newest log %s, want %s", logs[0], newest)
+	}
+	if !strings.Contains(filepath.Base(newest), "-build-") {
+		t.Errorf("got log name %s, want it named after the subcommand", newest)
+	}
+}
@@ -43,6 +43,7 @@ func EnsureNixpkgsPrefetched(w io.Writer, commit string) error {
 		FlakeNixpkgs(commit),
 	)
 	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, StoreFlags()...)
 	cmd.Stdout = w
 	cmd.Stderr = cmd.Stdout
 	if err := cmd.Run(); err != nil {
@@ -77,6 +78,8 @@ func saveToNixpkgsCommitFile(commit string, commitToLocation map[string]string)
 		FlakeNixpkgs(commit),
 	)
 	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, StoreFlags()...)
+	cmd.Args = append(cmd.Args, SubstituterFlags()...)
 	out, err := cmd.Output()
 	if err != nil {
 		return errors.WithStack(err)
@@ -64,15 +64,15 @@ func ProfileInstall(ctx context.Context, args *ProfileInstallArgs) error {
 	cmd.Args = append(cmd.Args, args.Installable)
 	cmd.Env = allowUnfreeEnv(os.Environ())
 
-	// If nix profile install runs as tty, the output is much nicer. If we ever
-	// need to change this to our own writers, consider that you may need
-	// to implement your own nicer output. --print-build-logs flag may be useful.
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = args.Writer
-	cmd.Stderr = args.Writer
 
 	debug.Log("running command: %s\n", cmd)
-	return cmd.Run()
+	nixErrors, err := runLogged(cmd, "profile install", args.Writer, nil)
+	if nixErrors != "" {
+		return redact.Errorf("%w: %s", err, nixErrors)
+	}
+	return err
 }
 
 // ProfileRemove removes packages from a profile.
@@ -8,13 +8,23 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
+
+	"golang.org/x/term"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cmdutil"
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
 )
 
-func RunScript(projectDir, cmdWithArgs string, env map[string]string) error {
+// RunScript runs cmdWithArgs with sh in projectDir. Any secrets are masked in
+// the command's stdout and stderr. If wrapper is set, sh runs as its
+// arguments, for example to run in a sandbox. The command runs in its own
+// process group, which gets the signals that devbox gets. When there are
+// secrets to mask and devbox runs in a terminal, the command runs in a
+// terminal of its own (see cmdutil.RunInPTY).
+func RunScript(projectDir, cmdWithArgs string, env map[string]string, secrets redact.Secrets, wrapper ...string) error {
 	if cmdWithArgs == "" {
 		return errors.New("attempted to run an empty command or script")
 	}
@@ -26,14 +36,35 @@ func RunScript(projectDir, cmdWithArgs string, env map[string]string) error {
 
 	// Try to find sh in the PATH, if not, default to a well known absolute path.
 	shPath := cmdutil.GetPathOrDefault("sh", "/bin/sh")
-	cmd := exec.Command(shPath, "-c", cmdWithArgs)
+	args := slices.Concat(wrapper, []string{shPath, "-c", cmdWithArgs})
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Env = envPairs
 	cmd.Dir = projectDir
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if !secrets.Empty() {
+		stdout := redact.NewSecretWriter(os.Stdout, secrets)
+		defer stdout.Flush()
+		if isTerminal(os.Stdin) && isTerminal(os.Stdout) && isTerminal(os.Stderr) {
+			// The command gets a terminal of its own, such as for the
+			// process-compose TUI, whose output is masked on its way
+			// to devbox's terminal.
+			debug.Log("Executing in a pty: %v", cmd.Args)
+			return usererr.NewExecError(cmdutil.RunInPTY(cmd, stdout))
+		}
+		// This makes the command's output a pipe instead of the terminal.
+		stderr := redact.NewSecretWriter(os.Stderr, secrets)
+		defer stderr.Flush()
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
 
 	debug.Log("Executing: %v", cmd.Args)
 	// Report error as exec error when executing scripts.
-	return usererr.NewExecError(cmd.Run())
+	return usererr.NewExecError(cmdutil.RunProcessGroup(cmd))
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
 }
@@ -0,0 +1,201 @@
+package nix
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// PathInfo is the metadata Nix keeps about a store path, as printed by
+// `nix path-info --json`.
+type PathInfo struct {
+	Path       string   `json:"path"`
+	NarHash    string   `json:"narHash"`
+	NarSize    int64    `json:"narSize"`
+	References []string `json:"references"`
+	Signatures []string `json:"signatures"`
+	// Ultimate is true for paths that were built on this machine.
+	Ultimate bool `json:"ultimate"`
+	// CA is set for content-addressed paths, whose contents are checked
+	// against their path instead of a signature.
+	CA string `json:"ca"`
+}
+
+// PathInfosRecursive returns the metadata of storePaths and every path they
+// depend on. The paths must already be in the store.
+func PathInfosRecursive(ctx context.Context, storePaths ...string) ([]PathInfo, error) {
+	defer debug.FunctionTimer().End()
+	return pathInfos(ctx, []string{"--recursive"}, storePaths)
+}
+
+// PathInfos returns the metadata of the storePaths that are in the store.
+// Paths that aren't are left out.
+func PathInfos(ctx context.Context, storePaths ...string) ([]PathInfo, error) {
+	defer debug.FunctionTimer().End()
+	return pathInfos(ctx, nil, storePaths)
+}
+
+func pathInfos(ctx context.Context, flags, storePaths []string) ([]PathInfo, error) {
+	args := slices.Concat([]string{"path-info", "--json", "--offline"}, flags, storePaths)
+	cmd := commandContext(ctx, args...)
+	debug.Log("Running cmd %s", cmd)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, redact.Errorf("nix path-info --json: %w: %s", err, exitErr.Stderr)
+	}
+	if err != nil {
+		return nil, redact.Errorf("nix path-info --json: %w", err)
+	}
+	return parsePathInfos(out)
+}
+
+// parsePathInfos parses both the map keyed by store path that Nix 2.19 and
+// later print, and the list that older versions print.
+func parsePathInfos(data []byte) ([]PathInfo, error) {
+	var byPath map[string]*PathInfo
+	if err := json.Unmarshal(data, &byPath); err == nil {
+		infos := make([]PathInfo, 0, len(byPath))
+		for storePath, info := range byPath {
+			if info == nil {
+				continue
+			}
+			info.Path = storePath
+			infos = append(infos, *info)
+		}
+		return infos, nil
+	}
+	var infos []PathInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, redact.Errorf("parse nix path-info output: %w", err)
+	}
+	// Older versions list paths that aren't in the store without a hash.
+	return slices.DeleteFunc(infos, func(info PathInfo) bool { return info.NarHash == "" }), nil
+}
+
+// Base32NarHash returns the path's NAR hash in the "sha256:<nix base32>"
+// form that binary caches use, whichever form Nix printed it in.
+func (p PathInfo) Base32NarHash() (string, error) {
+	return nixBase32NarHash(p.NarHash)
+}
+
+// Fingerprint returns the string that binary caches sign for a store path.
+// It's the same as ValidPathInfo::fingerprint in Nix.
+func (p PathInfo) Fingerprint() (string, error) {
+	narHash, err := nixBase32NarHash(p.NarHash)
+	if err != nil {
+		return "", err
+	}
+	storeDir := path.Dir(p.Path)
+	refs := make([]string, len(p.References))
+	for i, ref := range p.References {
+		if !strings.HasPrefix(ref, "/") {
+			ref = path.Join(storeDir, ref)
+		}
+		refs[i] = ref
+	}
+	return fmt.Sprintf("1;%s;%s;%s;%s",
+		p.Path, narHash, strconv.FormatInt(p.NarSize, 10), strings.Join(refs, ",")), nil
+}
+
+// NeedsSignature returns true if the path came from a binary cache, which is
+// only trustworthy if it's signed. Paths that were built locally or are
+// content-addressed don't need a signature.
+func (p PathInfo) NeedsSignature() bool {
+	return !p.Ultimate && p.CA == ""
+}
+
+// SignedBy returns the names of the trusted keys that have a valid signature
+// of the path.
+func (p PathInfo) SignedBy(keys []PublicKey) []string {
+	fingerprint, err := p.Fingerprint()
+	if err != nil {
+		debug.Log("signatures: %s: %v", p.Path, err)
+		return nil
+	}
+	var signers []string
+	for _, sig := range p.Signatures {
+		name, encoded, ok := strings.Cut(sig, ":")
+		if !ok {
+			continue
+		}
+		sigBytes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if key.Name == name && ed25519.Verify(key.Key, []byte(fingerprint), sigBytes) {
+				signers = append(signers, name)
+			}
+		}
+	}
+	return signers
+}
+
+// PublicKey is a binary cache signing key, such as the one in
+// "cache.nixos.org-1:6NCHdD59X431o0gWypbMrAURkbJ16ZPMQFGspcDShjY=".
+type PublicKey struct {
+	Name string
+	Key  ed25519.PublicKey
+}
+
+// ParsePublicKey parses a public key in the format of Nix's
+// trusted-public-keys setting.
+func ParsePublicKey(s string) (PublicKey, error) {
+	name, encoded, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || name == "" {
+		return PublicKey{}, fmt.Errorf("public key %q isn't of the form <name>:<base64 key>", s)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return PublicKey{}, fmt.Errorf("public key %q isn't a base64-encoded ed25519 key", s)
+	}
+	return PublicKey{Name: name, Key: key}, nil
+}
+
+// nixBase32NarHash converts a NAR hash to the "sha256:<nix base32>" form
+// used in fingerprints. Newer versions of Nix print SRI hashes instead.
+func nixBase32NarHash(hash string) (string, error) {
+	if strings.HasPrefix(hash, "sha256:") {
+		return hash, nil
+	}
+	encoded, ok := strings.CutPrefix(hash, "sha256-")
+	if !ok {
+		return "", fmt.Errorf("unsupported NAR hash %q", hash)
+	}
+	digest, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid NAR hash %q: %w", hash, err)
+	}
+	return "sha256:" + nixBase32(digest), nil
+}
+
+const nixBase32Chars = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// nixBase32 encodes bytes with Nix's variant of base32, which uses its own
+// alphabet and starts from the last byte.
+func nixBase32(b []byte) string {
+	n := (len(b)*8-1)/5 + 1
+	out := make([]byte, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		bit := i * 5
+		j, k := bit/8, uint(bit%8)
+		c := b[j] >> k
+		if j+1 < len(b) {
+			c |= b[j+1] << (8 - k)
+		}
+		out = append(out, nixBase32Chars[c&0x1f])
+	}
+	return string(out)
+}
@@ -0,0 +1,68 @@
+package nix
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNixBase32(t *testing.T) {
+	sum := sha256.Sum256(nil)
+	require.Equal(t, "0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73", nixBase32(sum[:]))
+
+	sri := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+	hash, err := nixBase32NarHash(sri)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73", hash)
+}
+
+func TestParsePathInfos(t *testing.T) {
+	modern := `{"/nix/store/aaa-hello":{"narHash":"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=","narSize":8,"references":["/nix/store/bbb-glibc"],"signatures":["k:sig"]},"/nix/store/missing":null}`
+	infos, err := parsePathInfos([]byte(modern))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, "/nix/store/aaa-hello", infos[0].Path)
+	require.Equal(t, []string{"k:sig"}, infos[0].Signatures)
+
+	legacy := `[{"path":"/nix/store/aaa-hello","narHash":"sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73","narSize":8,"ultimate":true},{"path":"/nix/store/missing","valid":false}]`
+	infos, err = parsePathInfos([]byte(legacy))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.False(t, infos[0].NeedsSignature())
+}
+
+func TestPathInfoSignedBy(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key, err := ParsePublicKey("cache.acme.dev-1:" + base64.StdEncoding.EncodeToString(pub))
+	require.NoError(t, err)
+
+	info := PathInfo{
+		Path:       "/nix/store/aaa-hello",
+		NarHash:    "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73",
+		NarSize:    8,
+		References: []string{"bbb-glibc", "/nix/store/aaa-hello"},
+	}
+	fingerprint, err := info.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t,
+		"1;/nix/store/aaa-hello;sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73;8;/nix/store/bbb-glibc,/nix/store/aaa-hello",
+		fingerprint)
+	require.True(t, info.NeedsSignature())
+	require.Empty(t, info.SignedBy([]PublicKey{key}))
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(fingerprint)))
+	info.Signatures = []string{"cache.nixos.org-1:" + sig, "cache.acme.dev-1:" + sig}
+	require.Equal(t, []string{"cache.acme.dev-1"}, info.SignedBy([]PublicKey{key}))
+
+	info.NarSize = 9
+	require.Empty(t, info.SignedBy([]PublicKey{key}), "signature of different contents")
+
+	_, err = ParsePublicKey("no-name")
+	require.Error(t, err)
+	_, err = ParsePublicKey("name:c2hvcnQ=")
+	require.Error(t, err)
+}
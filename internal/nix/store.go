@@ -1,12 +1,14 @@
 package nix
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 
 	"go.jetpack.io/devbox/internal/debug"
@@ -74,6 +76,56 @@ func StorePathsAreInStore(ctx context.Context, storePaths []string) (map[string]
 	return parseStorePathFromInstallableOutput(output)
 }
 
+// StorePathClosure returns the runtime closure of storePaths: the paths
+// themselves and every path they depend on. The paths must already be in the
+// store.
+func StorePathClosure(ctx context.Context, storePaths ...string) ([]string, error) {
+	defer debug.FunctionTimer().End()
+	cmd := commandContext(ctx, append([]string{"path-info", "--recursive", "--offline"}, storePaths...)...)
+	debug.Log("Running cmd %s", cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, redact.Errorf("nix path-info --recursive: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// ModifiedStorePaths checks the contents of storePaths against the NAR hashes
+// in the Nix database and returns the paths whose contents changed since they
+// were added to the store. It doesn't check signatures.
+func ModifiedStorePaths(ctx context.Context, storePaths ...string) ([]string, error) {
+	defer debug.FunctionTimer().End()
+	if len(storePaths) == 0 {
+		return nil, nil
+	}
+	cmd := commandContext(ctx, append([]string{"store", "verify", "--no-trust", "--offline"}, storePaths...)...)
+	debug.Log("Running cmd %s", cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return nil, nil
+	}
+	if modified := parseModifiedStorePaths(stderr.Bytes()); len(modified) > 0 {
+		return modified, nil
+	}
+	return nil, redact.Errorf("nix store verify: %w: %s", err, stderr.Bytes())
+}
+
+// modifiedPathRegex matches the error that nix store verify prints for a
+// path with changed contents, such as:
+//
+//	path '/nix/store/...-hello-2.12.1' was modified! expected hash 'sha256-...', got 'sha256-...'
+var modifiedPathRegex = regexp.MustCompile(`path '(/[^']+)' was modified!`)
+
+func parseModifiedStorePaths(stderr []byte) []string {
+	var paths []string
+	for _, match := range modifiedPathRegex.FindAllSubmatch(stderr, -1) {
+		paths = append(paths, string(match[1]))
+	}
+	return paths
+}
+
 // Older nix versions (like 2.17) are an array of objects that contain path and valid fields
 type LegacyPathInfo struct {
 	Path  string `json:"path"`
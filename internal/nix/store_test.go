@@ -56,3 +56,17 @@ func TestParseStorePathFromInstallableOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestParseModifiedStorePaths(t *testing.T) {
+	stderr := `checking path '/nix/store/aaa-hello-2.12.1'...
+error: path '/nix/store/aaa-hello-2.12.1' was modified! expected hash 'sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=', got 'sha256-LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564='
+2 paths checked, 1 paths corrupted
+`
+	got := parseModifiedStorePaths([]byte(stderr))
+	if len(got) != 1 || got[0] != "/nix/store/aaa-hello-2.12.1" {
+		t.Errorf("Expected the modified hello path but got %v", got)
+	}
+	if got := parseModifiedStorePaths([]byte("error: cannot connect to socket")); len(got) != 0 {
+		t.Errorf("Expected no modified paths but got %v", got)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/xdg"
+)
+
+// Substituter is a binary cache that every nix command devbox runs can
+// substitute store paths from.
+type Substituter struct {
+	URL string
+	// Priority overrides the priority that the cache advertises, unless
+	// it's zero.
+	Priority   int
+	PublicKeys []string
+	// Netrc is the netrc entries with the cache's credentials, if it
+	// needs any.
+	Netrc string
+}
+
+// substitutionURL returns the URL of s in Nix's substituters setting.
+func (s Substituter) substitutionURL() string {
+	if s.Priority == 0 {
+		return s.URL
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return s.URL
+	}
+	query := u.Query()
+	query.Set("priority", strconv.Itoa(s.Priority))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+var substituters struct {
+	sync.Mutex
+	subs []Substituter
+	// netrcFile is written the first time a command needs it.
+	netrcFile func() string
+}
+
+// SetSubstituters sets the binary caches that SubstituterFlags adds to nix
+// commands.
+func SetSubstituters(subs []Substituter) {
+	substituters.Lock()
+	defer substituters.Unlock()
+	substituters.subs = subs
+	substituters.netrcFile = sync.OnceValue(func() string { return writeNetrc(subs) })
+}
+
+// SubstituterFlags returns the flags that make a nix command use the binary
+// caches in SetSubstituters, trust their keys and use their credentials.
+//
+// Nix only uses extra substituters and keys from the command line for the
+// trusted users of the daemon, or for caches that nix.conf already lists in
+// trusted-substituters.
+func SubstituterFlags() []string {
+	substituters.Lock()
+	subs, netrcFile := substituters.subs, substituters.netrcFile
+	substituters.Unlock()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	var urls, keys []string
+	for _, s := range subs {
+		urls = append(urls, s.substitutionURL())
+		keys = append(keys, s.PublicKeys...)
+	}
+	flags := []string{"--extra-substituters", strings.Join(urls, " ")}
+	if len(keys) > 0 {
+		flags = append(flags, "--extra-trusted-public-keys", strings.Join(keys, " "))
+	}
+	if path := netrcFile(); path != "" {
+		flags = append(flags, "--netrc-file", path)
+	}
+	return flags
+}
+
+// netrcDir is where the netrc files that are passed to Nix are written.
+var netrcDir = xdg.StateSubpath(filepath.FromSlash("devbox/netrc"))
+
+// writeNetrc writes the netrc entries of subs, after the ones in the netrc
+// file that Nix is configured with, to a file that's only readable by the
+// user. Nix reads a single netrc file, so the file replaces the configured
+// one. It returns "" if none of subs have credentials.
+func writeNetrc(subs []Substituter) string {
+	var entries []string
+	for _, s := range subs {
+		if s.Netrc != "" {
+			entries = append(entries, strings.TrimSpace(s.Netrc))
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	if configured := configuredNetrcFile(); configured != "" {
+		if data, err := os.ReadFile(configured); err == nil {
+			entries = append([]string{strings.TrimSpace(string(data))}, entries...)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("nix: reading netrc file %s: %v", configured, err)
+		}
+	}
+	data := []byte(strings.Join(entries, "\n") + "\n")
+
+	if err := os.MkdirAll(netrcDir, 0o700); err != nil {
+		debug.Log("nix: creating netrc directory: %v", err)
+		return ""
+	}
+	// The name changes with the contents, so that the file is never
+	// rewritten while another devbox command passes it to Nix.
+	path := filepath.Join(netrcDir, cachehash.Bytes(data))
+	defer pruneNetrcFiles(path)
+	if _, err := os.Stat(path); err == nil {
+		// Mark the file as in use, so that it isn't pruned.
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return path
+	}
+	tmp, err := os.CreateTemp(netrcDir, ".netrc-*")
+	if err != nil {
+		debug.Log("nix: writing netrc file: %v", err)
+		return ""
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		debug.Log("nix: writing netrc file: %v", err)
+		return ""
+	}
+	return path
+}
+
+// netrcMaxAge is how long a netrc file that no devbox command has used stays
+// in netrcDir. Files are kept for a while since another devbox command may
+// still be passing an older one to Nix.
+const netrcMaxAge = 24 * time.Hour
+
+// pruneNetrcFiles deletes the netrc files in netrcDir, other than keep, that
+// haven't been used for netrcMaxAge.
+func pruneNetrcFiles(keep string) {
+	entries, err := os.ReadDir(netrcDir)
+	if err != nil {
+		debug.Log("nix: pruning netrc files: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(netrcDir, entry.Name())
+		info, err := entry.Info()
+		if path == keep || err != nil || time.Since(info.ModTime()) < netrcMaxAge {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			debug.Log("nix: pruning netrc files: %v", err)
+		}
+	}
+}
+
+// configuredNetrcFile returns the netrc-file setting of Nix. It doesn't use
+// CurrentConfig, since that runs nix with SubstituterFlags.
+func configuredNetrcFile() string {
+	cmd := exec.Command("nix", "show-config", "--json")
+	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	out, err := cmd.Output()
+	if err != nil {
+		debug.Log("nix: reading netrc-file setting: %v", err)
+		return ""
+	}
+	var cfg struct {
+		NetrcFile ConfigField[string] `json:"netrc-file"`
+	}
+	if err := json.Unmarshal(out, &cfg); err != nil {
+		debug.Log("nix: reading netrc-file setting: %v", err)
+		return ""
+	}
+	return cfg.NetrcFile.Value
+}
+
+// SetNetrcAuth adds the credentials of the substituter that req is for to
+// req, for the requests that devbox makes to binary caches itself. The
+// credentials of a cache are only sent to the cache's own URL, so not to
+// another host, port or path, or over another scheme, even if devbox.json
+// lists one.
+func SetNetrcAuth(req *http.Request) {
+	substituters.Lock()
+	subs := substituters.subs
+	substituters.Unlock()
+	host := req.URL.Hostname()
+	for _, s := range subs {
+		if u, err := url.Parse(s.URL); s.Netrc == "" || err != nil || !isUnderURL(req.URL, u) {
+			continue
+		}
+		if login, password, ok := NetrcCredentials(s.Netrc, host); ok {
+			req.SetBasicAuth(login, password)
+			return
+		}
+	}
+}
+
+// isUnderURL reports whether u is base or a path under it.
+func isUnderURL(u, base *url.URL) bool {
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return false
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+	return u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// NetrcCredentials looks up the login and password of host in netrc. Like
+// curl, it uses the first entry of a host. The default entry is ignored, since
+// its credentials would go to every binary cache.
+func NetrcCredentials(netrc, host string) (login, password string, ok bool) {
+	var fields []string
+	scanner := bufio.NewScanner(strings.NewReader(netrc))
+	for scanner.Scan() {
+		if line := scanner.Text(); !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			fields = append(fields, strings.Fields(line)...)
+		}
+	}
+
+	type entry struct{ login, password string }
+	machines := map[string]*entry{}
+	var current *entry
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			current = &entry{}
+			if i+1 < len(fields) {
+				i++
+				if machines[fields[i]] == nil {
+					machines[fields[i]] = current
+				}
+			}
+		case "default":
+			current = nil
+		case "login", "password":
+			if current == nil || i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if fields[i-1] == "login" {
+				current.login = fields[i]
+			} else {
+				current.password = fields[i]
+			}
+		}
+	}
+	e := machines[host]
+	if e == nil {
+		return "", "", false
+	}
+	return e.login, e.password, true
+}
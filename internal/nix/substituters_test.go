@@ -0,0 +1,148 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func setTestSubstituters(t *testing.T, subs []Substituter) {
+	t.Helper()
+	savedDir := netrcDir
+	netrcDir = t.TempDir()
+	SetSubstituters(subs)
+	t.Cleanup(func() {
+		SetSubstituters(nil)
+		netrcDir = savedDir
+	})
+}
+
+func TestSubstitutionURL(t *testing.T) {
+	tests := []struct {
+		sub  Substituter
+		want string
+	}{
+		{Substituter{URL: "https://cache.acme.internal"}, "https://cache.acme.internal"},
+		{Substituter{URL: "https://cache.acme.internal", Priority: 30}, "https://cache.acme.internal?priority=30"},
+		{
+			Substituter{URL: "s3://acme-nix-cache?region=us-west-2", Priority: 5},
+			"s3://acme-nix-cache?priority=5&region=us-west-2",
+		},
+	}
+	for _, test := range tests {
+		if got := test.sub.substitutionURL(); got != test.want {
+			t.Errorf("substitutionURL(%q, %d) = %q, want %q", test.sub.URL, test.sub.Priority, got, test.want)
+		}
+	}
+}
+
+func TestSubstituterFlags(t *testing.T) {
+	setTestSubstituters(t, nil)
+	if flags := SubstituterFlags(); flags != nil {
+		t.Errorf("got flags %v without substituters, want none", flags)
+	}
+
+	setTestSubstituters(t, []Substituter{
+		{URL: "https://cache.acme.internal", Priority: 30, PublicKeys: []string{"acme:key1"}},
+		{URL: "https://other.acme.internal"},
+	})
+	want := []string{
+		"--extra-substituters", "https://cache.acme.internal?priority=30 https://other.acme.internal",
+		"--extra-trusted-public-keys", "acme:key1",
+	}
+	if got := SubstituterFlags(); !slices.Equal(got, want) {
+		t.Errorf("got flags %v, want %v", got, want)
+	}
+}
+
+func TestSubstituterFlagsNetrc(t *testing.T) {
+	// Don't read the netrc file that the host's Nix is configured with.
+	t.Setenv("PATH", t.TempDir())
+	setTestSubstituters(t, []Substituter{
+		{URL: "https://cache.acme.internal", Netrc: "machine cache.acme.internal password secret"},
+	})
+	flags := SubstituterFlags()
+	i := slices.Index(flags, "--netrc-file")
+	if i < 0 || i+1 >= len(flags) {
+		t.Fatalf("got flags %v, want a --netrc-file", flags)
+	}
+	info, err := os.Stat(flags[i+1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("got netrc file mode %o, want 600", perm)
+	}
+	data, err := os.ReadFile(flags[i+1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "machine cache.acme.internal password secret\n"; got != want {
+		t.Errorf("got netrc file %q, want %q", got, want)
+	}
+}
+
+func TestSetNetrcAuth(t *testing.T) {
+	setTestSubstituters(t, []Substituter{
+		{URL: "https://cache.acme.internal", Netrc: "machine cache.acme.internal login ci password secret"},
+		{URL: "https://public.acme.internal"},
+		{URL: "https://shared.acme.internal/team/", Netrc: "machine shared.acme.internal login team password shared"},
+	})
+	for url, want := range map[string]bool{
+		"https://cache.acme.internal/abc.narinfo":           true,
+		"https://cache.acme.internal/nix-cache-info":        true,
+		"https://public.acme.internal/abc.narinfo":          false,
+		"https://cache.nixos.org/abc.narinfo":               false,
+		"http://cache.acme.internal/abc.narinfo":            false,
+		"https://cache.acme.internal:8443/abc.narinfo":      false,
+		"https://shared.acme.internal/team/abc.narinfo":     true,
+		"https://shared.acme.internal/other/nix-cache-info": false,
+	} {
+		req, err := http.NewRequest(http.MethodHead, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetNetrcAuth(req)
+		login, password, ok := req.BasicAuth()
+		if ok != want {
+			t.Errorf("%s: got credentials %v, want %v", url, ok, want)
+		}
+		if ok && strings.HasPrefix(url, "https://cache.") && (login != "ci" || password != "secret") {
+			t.Errorf("%s: got credentials %s:%s, want ci:secret", url, login, password)
+		}
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	netrc := `# comment
+machine cache.acme.internal
+  login ci
+  password first
+machine cache.acme.internal password second
+machine other.acme.internal password other
+default login anonymous password fallback
+`
+	tests := []struct {
+		host, login, password string
+		ok                    bool
+	}{
+		{"cache.acme.internal", "ci", "first", true},
+		{"other.acme.internal", "", "other", true},
+		{"unknown.acme.internal", "", "", false},
+	}
+	for _, test := range tests {
+		login, password, ok := NetrcCredentials(netrc, test.host)
+		if login != test.login || password != test.password || ok != test.ok {
+			t.Errorf("NetrcCredentials(%q) = %q, %q, %v, want %q, %q, %v",
+				test.host, login, password, ok, test.login, test.password, test.ok)
+		}
+	}
+	if _, _, ok := NetrcCredentials("machine a.example password x", "b.example"); ok {
+		t.Error("got credentials for a host that isn't in the netrc file")
+	}
+}
@@ -38,6 +38,8 @@ func FlakeUpdate(ProfileDir string) error {
 	}
 	cmd.Args = append(cmd.Args, ProfileDir)
 	cmd.Args = append(cmd.Args, ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, StoreFlags()...)
+	cmd.Args = append(cmd.Args, SubstituterFlags()...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return redact.Errorf(
@@ -0,0 +1,53 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// userStoreNamespaceEnv is set to the root of the user store in the devbox
+// process that ReexecInUserStore starts, which has the store mounted at /nix.
+const userStoreNamespaceEnv = "__DEVBOX_NIX_STORE_NAMESPACE"
+
+// UserStore returns the root of the user-owned Nix store that devbox uses
+// instead of the Nix daemon's store, or "" to use the daemon. A store with
+// root ~/.devbox/nix keeps /nix/store/<path> in ~/.devbox/nix/nix/store, so
+// it's substituted from the same binary caches as /nix/store.
+func UserStore() string {
+	root := os.Getenv(envir.DevboxNixStore)
+	if root == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(root, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, rest)
+		}
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return root
+}
+
+// StoreFlags returns the flags that make a nix command use the user store,
+// or nil when devbox uses the daemon's store.
+func StoreFlags() []string {
+	root := UserStore()
+	if root == "" {
+		return nil
+	}
+	return []string{"--store", root}
+}
+
+// InUserStoreNamespace reports whether devbox runs with the user store
+// mounted at /nix, where the programs in the store can run.
+func InUserStoreNamespace() bool {
+	root := UserStore()
+	return root != "" && os.Getenv(userStoreNamespaceEnv) == root
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build linux
+
+package nix
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// ReexecInUserStore runs devbox again, with the same arguments, in a user
+// and mount namespace that has the user store mounted at /nix. The store's
+// programs refer to /nix/store, so they only run in the namespace. This
+// needs neither root nor the Nix daemon, only unprivileged user namespaces.
+//
+// ran is false when devbox doesn't use a user store or already is in the
+// namespace, in which case the caller goes on as usual. Otherwise, code is
+// the exit code of the devbox that ran in the namespace.
+func ReexecInUserStore() (code int, ran bool, err error) {
+	root := UserStore()
+	if root == "" {
+		return 0, false, nil
+	}
+	if InUserStoreNamespace() {
+		return 0, false, mountUserStore(root)
+	}
+	for _, dir := range []string{"nix/store", "nix/var/nix"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return 0, false, redact.Errorf("create the Nix store in %s: %w", root, err)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, false, redact.Errorf("find the devbox executable: %w", err)
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Args[0] = os.Args[0]
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), userStoreNamespaceEnv+"="+root)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+	}
+	debug.Log("running devbox in a namespace with the Nix store in %s", root)
+
+	// The terminal sends signals to the devbox in the namespace too, which
+	// decides whether to exit.
+	signal.Ignore(os.Interrupt, syscall.SIGQUIT)
+	err = cmd.Run()
+	signal.Reset(os.Interrupt, syscall.SIGQUIT)
+	if exitErr := (&exec.ExitError{}); errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true, nil
+	}
+	if err != nil {
+		return 0, false, usererr.WithUserMessage(err,
+			"Devbox couldn't mount the Nix store in %s (set by %s). The store needs unprivileged user "+
+				"namespaces, which the kernel.unprivileged_userns_clone or "+
+				"user.max_user_namespaces sysctl may turn off.",
+			root, envir.DevboxNixStore)
+	}
+	return 0, true, nil
+}
+
+// mountUserStore mounts the user store at /nix. If there's no /nix to mount
+// it on, which only root could create, it makes a root directory that has
+// the same entries as / and a /nix, and changes to it. The new root is a
+// tmpfs that only exists in the namespace, so no one else can add entries to
+// it.
+func mountUserStore(root string) error {
+	// Keep the mounts in the namespace from propagating to the host.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return redact.Errorf("make the mounts private: %w", err)
+	}
+	store := filepath.Join(root, "nix")
+	if info, err := os.Stat("/nix"); err == nil && info.IsDir() {
+		return bindMount(store, "/nix")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return redact.Errorf("get the working directory: %w", err)
+	}
+	newRoot := filepath.Join(root, "chroot")
+	if err := os.MkdirAll(newRoot, 0o700); err != nil {
+		return redact.Errorf("create %s: %w", newRoot, err)
+	}
+	if err := checkOwnDir(newRoot); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", newRoot, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return redact.Errorf("mount a tmpfs on %s: %w", newRoot, err)
+	}
+	entries, err := os.ReadDir("/")
+	if err != nil {
+		return redact.Errorf("list /: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "nix" {
+			continue
+		}
+		if err := mirrorRootEntry(newRoot, entry); err != nil {
+			return err
+		}
+	}
+	if err := mkdirMountPoint(filepath.Join(newRoot, "nix"), true); err != nil {
+		return err
+	}
+	if err := bindMount(store, filepath.Join(newRoot, "nix")); err != nil {
+		return err
+	}
+	if err := syscall.Chroot(newRoot); err != nil {
+		return redact.Errorf("change the root to %s: %w", newRoot, err)
+	}
+	return os.Chdir(cwd)
+}
+
+// checkOwnDir returns an error if path isn't a directory (and not a symlink
+// to one) that the current user owns.
+func checkOwnDir(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return redact.Errorf("check %s: %w", path, err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() {
+		return usererr.New("%s must be a directory owned by you.", path)
+	}
+	return nil
+}
+
+// mirrorRootEntry makes the entry of / appear in newRoot, by bind mounting
+// files and directories and copying symlinks.
+func mirrorRootEntry(newRoot string, entry fs.DirEntry) error {
+	src := "/" + entry.Name()
+	dst := filepath.Join(newRoot, entry.Name())
+	if entry.Type()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return redact.Errorf("read the link %s: %w", src, err)
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return redact.Errorf("create the link %s: %w", dst, err)
+		}
+		return nil
+	}
+	if err := mkdirMountPoint(dst, entry.IsDir()); err != nil {
+		return err
+	}
+	return bindMount(src, dst)
+}
+
+// mkdirMountPoint creates an empty directory or file at path. path must not
+// exist yet.
+func mkdirMountPoint(path string, isDir bool) error {
+	var err error
+	if isDir {
+		err = os.Mkdir(path, 0o755)
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL, 0o644); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return redact.Errorf("create the mount point %s: %w", path, err)
+	}
+	return nil
+}
+
+func bindMount(src, dst string) error {
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return redact.Errorf("mount %s on %s: %w", src, dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !linux
+
+package nix
+
+import (
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// ReexecInUserStore fails if devbox is set to use a user store, because
+// running the programs in one needs Linux user namespaces.
+func ReexecInUserStore() (code int, ran bool, err error) {
+	if UserStore() == "" {
+		return 0, false, nil
+	}
+	return 0, false, usererr.New(
+		"%s is only supported on Linux. On this OS, Nix needs its store in /nix.", envir.DevboxNixStore)
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestUserStore(t *testing.T) {
+	t.Setenv(envir.DevboxNixStore, "")
+	if flags := StoreFlags(); flags != nil {
+		t.Errorf("got store flags %v without a user store", flags)
+	}
+
+	home := t.TempDir()
+	t.Setenv(envir.Home, home)
+	t.Setenv(envir.DevboxNixStore, "~/.devbox/nix")
+	want := filepath.Join(home, ".devbox", "nix")
+	if got := UserStore(); got != want {
+		t.Errorf("got user store %q, want %q", got, want)
+	}
+	if got := StoreFlags(); !slices.Equal(got, []string{"--store", want}) {
+		t.Errorf("got store flags %v, want --store %s", got, want)
+	}
+
+	if InUserStoreNamespace() {
+		t.Error("devbox isn't in the namespace until it's re-executed")
+	}
+	t.Setenv(userStoreNamespaceEnv, want)
+	if !InUserStoreNamespace() {
+		t.Error("devbox is in the namespace of the user store")
+	}
+}
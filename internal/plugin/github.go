@@ -15,6 +15,8 @@ import (
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/nix/flake"
 	"go.jetpack.io/pkg/filecache"
 )
@@ -77,8 +79,7 @@ func (p *githubPlugin) FileContent(subpath string) ([]byte, error) {
 				return nil, 0, err
 			}
 
-			client := &http.Client{}
-			res, err := client.Do(req)
+			res, err := httpclient.Client().Do(req)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -108,7 +109,7 @@ func (p *githubPlugin) url(subpath string) (string, error) {
 	// Github redirects "master" to "main" in new repos. They don't do the reverse
 	// so setting master here is better.
 	return url.JoinPath(
-		"https://raw.githubusercontent.com/",
+		netpolicy.URL(netpolicy.Plugins),
 		p.ref.Owner,
 		p.ref.Repo,
 		cmp.Or(p.ref.Rev, p.ref.Ref, "master"),
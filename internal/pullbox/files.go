@@ -5,7 +5,6 @@ package pullbox
 
 import (
 	"io/fs"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +14,7 @@ import (
 	"go.jetpack.io/devbox/internal/devconfig"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/httpclient"
 )
 
 func (p *pullbox) copyToProfile(src string) error {
@@ -80,7 +80,7 @@ func isModifiedConfig(path string) bool {
 
 // urlIsArchive checks if a file URL points to an archive file
 func urlIsArchive(url string) (bool, error) {
-	response, err := http.Head(url)
+	response, err := httpclient.Client().Head(url)
 	if err != nil {
 		return false, err
 	}
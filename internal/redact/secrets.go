@@ -0,0 +1,132 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package redact
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+)
+
+// SecretMask replaces secret values in masked output.
+const SecretMask = "********"
+
+// minSecretLen is the length below which values aren't masked. Masking very
+// short values (e.g. "1", "true" or "admin") would garble output without
+// protecting anything.
+const minSecretLen = 6
+
+// Secrets is a set of secret values to mask in strings and output streams.
+// The zero value masks nothing.
+type Secrets struct {
+	values [][]byte // longest first, so overlapping secrets are fully masked
+}
+
+// NewSecrets returns the set of values that are long enough to mask.
+func NewSecrets(values ...string) Secrets {
+	s := Secrets{}
+	for _, v := range values {
+		if len(v) >= minSecretLen {
+			s.values = append(s.values, []byte(v))
+		}
+	}
+	slices.SortFunc(s.values, func(a, b []byte) int { return len(b) - len(a) })
+	s.values = slices.CompactFunc(s.values, bytes.Equal)
+	return s
+}
+
+// Empty returns true if there is nothing to mask.
+func (s Secrets) Empty() bool {
+	return len(s.values) == 0
+}
+
+// Mask replaces every secret in str with SecretMask.
+func (s Secrets) Mask(str string) string {
+	if s.Empty() {
+		return str
+	}
+	out, _ := s.mask(nil, []byte(str), true)
+	return string(out)
+}
+
+// mask appends src to dst with secrets masked. Unless final is set, it stops
+// at a trailing partial match and returns the unprocessed rest of src, so
+// that a secret split across writes is still masked.
+func (s Secrets) mask(dst, src []byte, final bool) (out, rest []byte) {
+	start := 0
+	for i := 0; i < len(src); {
+		match, partial := s.matchAt(src[i:])
+		switch {
+		case match > 0:
+			dst = append(dst, src[start:i]...)
+			dst = append(dst, SecretMask...)
+			i += match
+			start = i
+		case partial && !final:
+			return append(dst, src[start:i]...), src[i:]
+		default:
+			i++
+		}
+	}
+	return append(dst, src[start:]...), nil
+}
+
+// matchAt returns the length of the secret that b starts with, or whether b
+// is the beginning of a secret.
+func (s Secrets) matchAt(b []byte) (match int, partial bool) {
+	for _, v := range s.values {
+		if bytes.HasPrefix(b, v) {
+			return len(v), false
+		}
+		if len(b) < len(v) && bytes.HasPrefix(v, b) {
+			partial = true
+		}
+	}
+	return 0, partial
+}
+
+// SecretWriter masks secrets in everything written to an underlying writer.
+// Output that might be the start of a secret is held back until the next
+// Write or Flush.
+type SecretWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets Secrets
+	pending []byte
+}
+
+// NewSecretWriter returns a writer that masks secrets before writing to w.
+func NewSecretWriter(w io.Writer, secrets Secrets) *SecretWriter {
+	return &SecretWriter{w: w, secrets: secrets}
+}
+
+func (sw *SecretWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	out, rest := sw.secrets.mask(nil, append(sw.pending, p...), false)
+	sw.pending = bytes.Clone(rest)
+	if len(out) == 0 {
+		return len(p), nil
+	}
+	if _, err := sw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any output that was held back.
+func (sw *SecretWriter) Flush() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if len(sw.pending) == 0 {
+		return nil
+	}
+	out, _ := sw.secrets.mask(nil, sw.pending, true)
+	sw.pending = nil
+	_, err := sw.w.Write(out)
+	return err
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretsMask(t *testing.T) {
+	s := NewSecrets("hunter2", "hunter2-long", "abc", "")
+	got := s.Mask("password=hunter2 token=hunter2-long short=abc")
+	want := "password=******** token=******** short=abc"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := (Secrets{}).Mask("hunter2"); got != "hunter2" {
+		t.Errorf("zero Secrets masked %q", got)
+	}
+}
+
+func TestSecretWriterSplitWrites(t *testing.T) {
+	input := "connecting with hunter2 and again hunter2\npartial hunt"
+	for size := 1; size <= len(input); size++ {
+		buf := &strings.Builder{}
+		w := NewSecretWriter(buf, NewSecrets("hunter2"))
+		for _, chunk := range chunks(input, size) {
+			if _, err := w.Write([]byte(chunk)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want := "connecting with ******** and again ********\npartial hunt"
+		if buf.String() != want {
+			t.Errorf("chunk size %d: got %q, want %q", size, buf.String(), want)
+		}
+	}
+}
+
+func chunks(s string, size int) []string {
+	var chunks []string
+	for len(s) > size {
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package sandbox runs commands with restricted access to the filesystem and
+// network, using bubblewrap on Linux and sandbox-exec on macOS.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+)
+
+// systemPaths are read-only in the sandbox so that system tools such as sh
+// keep working. They don't hold user data.
+var systemPaths = map[string][]string{
+	"linux":  {"/bin", "/etc", "/lib", "/lib32", "/lib64", "/sbin", "/usr"},
+	"darwin": {"/bin", "/private/etc", "/Library", "/sbin", "/System", "/usr", "/private/var/db/dyld"},
+}
+
+// Policy describes what a sandboxed command can access.
+type Policy struct {
+	// ProjectDir is readable and writable, and is the working directory.
+	ProjectDir string
+	// ReadOnly are paths that can be read, such as the profile's store paths.
+	ReadOnly []string
+	// Home is replaced with an empty, writable directory.
+	Home string
+	// Network allows network access.
+	Network bool
+}
+
+// Wrap returns the command line that runs args in the sandbox.
+func Wrap(p Policy, args []string) ([]string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if !cmdutil.Exists("bwrap") {
+			return nil, usererr.New(
+				"Running scripts in a sandbox needs bubblewrap (bwrap). " +
+					"Install it with your system's package manager, for example `apt install bubblewrap`.")
+		}
+		return slices.Concat([]string{"bwrap"}, bwrapArgs(p), []string{"--"}, args), nil
+	case "darwin":
+		return slices.Concat([]string{"/usr/bin/sandbox-exec", "-p", darwinProfile(p)}, args), nil
+	default:
+		return nil, usererr.New("Running scripts in a sandbox isn't supported on %s.", runtime.GOOS)
+	}
+}
+
+func bwrapArgs(p Policy) []string {
+	args := []string{"--die-with-parent", "--unshare-all", "--new-session"}
+	if p.Network {
+		args = append(args, "--share-net")
+	}
+	for _, path := range systemPaths["linux"] {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	args = append(args,
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+	)
+	if p.Home != "" {
+		args = append(args, "--tmpfs", p.Home)
+	}
+	for _, path := range readOnlyPaths(p) {
+		args = append(args, "--ro-bind", path, path)
+	}
+	return append(args,
+		"--bind", p.ProjectDir, p.ProjectDir,
+		"--chdir", p.ProjectDir,
+	)
+}
+
+// darwinProfile returns a sandbox-exec profile. Unlike bubblewrap, it can't
+// replace the home directory, so it just isn't readable.
+func darwinProfile(p Policy) string {
+	var b strings.Builder
+	b.WriteString("(version 1)\n(deny default)\n")
+	b.WriteString("(allow process*)\n(allow signal)\n(allow sysctl-read)\n(allow mach-lookup)\n(allow ipc-posix*)\n")
+	// Resolving paths needs their parents' metadata.
+	b.WriteString("(allow file-read-metadata)\n")
+
+	fmt.Fprintf(&b, "(allow file-read*\n    (literal \"/\")\n")
+	for _, path := range slices.Concat(systemPaths["darwin"], readOnlyPaths(p)) {
+		fmt.Fprintf(&b, "    (subpath %q)\n", path)
+	}
+	b.WriteString(")\n")
+
+	fmt.Fprintf(&b, "(allow file-read* file-write*\n    (subpath %q)\n    (subpath \"/dev\")\n", p.ProjectDir)
+	for _, tmp := range []string{"/private/tmp", "/private/var/folders", os.TempDir()} {
+		fmt.Fprintf(&b, "    (subpath %q)\n", tmp)
+	}
+	b.WriteString(")\n")
+
+	if p.Network {
+		b.WriteString("(allow network*)\n")
+	}
+	return b.String()
+}
+
+// readOnlyPaths returns p.ReadOnly without duplicates or paths that are
+// already inside another path.
+func readOnlyPaths(p Policy) []string {
+	paths := slices.Clone(p.ReadOnly)
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	var result []string
+	for _, path := range paths {
+		if len(result) > 0 && isWithin(path, result[len(result)-1]) {
+			continue
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
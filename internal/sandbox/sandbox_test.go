@@ -0,0 +1,52 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	p := Policy{
+		ProjectDir: "/home/me/project",
+		ReadOnly: []string{
+			"/nix/store/00000000000000000000000000000000-go-1.22",
+			"/nix/store/00000000000000000000000000000000-go-1.22",
+			"/home/me/.cache/runx/bin",
+			"/home/me/.cache/runx/bin/lint",
+		},
+		Home: "/home/me",
+	}
+	args := strings.Join(bwrapArgs(p), " ")
+	require.Contains(t, args, "--unshare-all")
+	require.NotContains(t, args, "--share-net")
+	require.Contains(t, args, "--tmpfs /home/me")
+	require.Equal(t, 1, strings.Count(args, "--ro-bind /nix/store/00000000000000000000000000000000-go-1.22"))
+	require.Contains(t, args, "--ro-bind /home/me/.cache/runx/bin /home/me/.cache/runx/bin")
+	require.NotContains(t, args, "runx/bin/lint")
+	require.True(t, strings.HasSuffix(args, "--bind /home/me/project /home/me/project --chdir /home/me/project"))
+	// The home directory is replaced before anything inside it is mounted.
+	require.Less(t, strings.Index(args, "--tmpfs /home/me"), strings.Index(args, "/home/me/.cache"))
+
+	p.Network = true
+	require.Contains(t, bwrapArgs(p), "--share-net")
+}
+
+func TestDarwinProfile(t *testing.T) {
+	p := Policy{
+		ProjectDir: "/Users/me/project",
+		ReadOnly:   []string{"/nix/store/00000000000000000000000000000000-go-1.22"},
+	}
+	profile := darwinProfile(p)
+	require.Contains(t, profile, "(deny default)")
+	require.Contains(t, profile, `(subpath "/nix/store/00000000000000000000000000000000-go-1.22")`)
+	require.Contains(t, profile, "(allow file-read* file-write*\n    (subpath \"/Users/me/project\")")
+	require.NotContains(t, profile, "network")
+
+	p.Network = true
+	require.Contains(t, darwinProfile(p), "(allow network*)")
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/credstore"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devbox/providers/identity"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// SearchAuthJetify is the DEVBOX_SEARCH_AUTH value that authenticates to the
+// search service with the devbox auth login session.
+const SearchAuthJetify = "jetify"
+
+// tokenCredential is the prefix of the credential store keys that search
+// tokens are saved under, followed by the host of the search service.
+const tokenCredential = "search-token:"
+
+// SaveToken stores a bearer token for the search service at host, such as
+// "search.acme.internal", in the OS keychain. Devbox sends it with every
+// request to that host unless DEVBOX_SEARCH_TOKEN is set.
+func SaveToken(host, token string) error {
+	if token == "" {
+		return usererr.New("The search token is empty.")
+	}
+	return credstore.Set(tokenCredential+host, token)
+}
+
+// DeleteToken removes the token that SaveToken saved for host.
+func DeleteToken(host string) error {
+	return credstore.Delete(tokenCredential + host)
+}
+
+var (
+	tokensMu sync.Mutex
+	// tokens caches the token of each host for the life of the process,
+	// so that the keychain isn't read for every request.
+	tokens = map[string]string{}
+)
+
+// authToken returns the bearer token to send to the search service at u, or
+// "" to send none. DEVBOX_SEARCH_TOKEN takes precedence over a token saved
+// with SaveToken, which takes precedence over DEVBOX_SEARCH_AUTH=jetify. Only
+// DEVBOX_SEARCH_TOKEN, which is set on purpose, is sent over plain HTTP.
+func authToken(ctx context.Context, u *url.URL) (string, error) {
+	if token := os.Getenv(envir.DevboxSearchToken); token != "" {
+		return token, nil
+	}
+	if u.Scheme != "https" {
+		return "", nil
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	if token, ok := tokens[u.Host]; ok {
+		return token, nil
+	}
+	token, err := credstore.Get(tokenCredential + u.Host)
+	if err != nil && !errors.Is(err, credstore.ErrNotFound) {
+		debug.Log("searcher: reading the saved token for %s: %v", u.Host, err)
+	}
+	if token == "" && os.Getenv(envir.DevboxSearchAuth) == SearchAuthJetify {
+		session, err := identity.GenSession(ctx)
+		if err != nil {
+			return "", usererr.WithUserMessage(err,
+				"%s=%s requires logging in to devbox. Run `devbox auth login`.",
+				envir.DevboxSearchAuth, SearchAuthJetify)
+		}
+		token = session.AccessToken
+	}
+	tokens[u.Host] = token
+	return token, nil
+}
+
+// authenticate adds the search token, if there is one, to req.
+func authenticate(req *http.Request) error {
+	token, err := authToken(req.Context(), req.URL)
+	if err != nil || token == "" {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// authError explains a response that refused the request's credentials.
+func authError(err error, u *url.URL, authenticated bool) error {
+	if authenticated {
+		return usererr.WithUserMessage(err,
+			"The search service at %s rejected the search token. Check %s, the token saved with "+
+				"`devbox auth search-token set`, or %s.",
+			u.Host, envir.DevboxSearchToken, envir.DevboxSearchAuth)
+	}
+	return usererr.WithUserMessage(err,
+		"The search service at %s requires authentication. Set %s, save a token with "+
+			"`devbox auth search-token set`, or set %s=%s to use your devbox login.",
+		u.Host, envir.DevboxSearchToken, envir.DevboxSearchAuth, SearchAuthJetify)
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestSearchToken(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchRetries, "0")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"num_results": 0}`))
+	}))
+	t.Cleanup(server.Close)
+	c := &client{host: server.URL}
+
+	_, err := c.Search(context.Background(), "hello")
+	userErr, ok := usererr.Extract(err)
+	require.True(t, ok, "a service that requires a token is a user error, not an outage")
+	require.Contains(t, userErr.Error(), "requires authentication")
+	require.False(t, IsUnavailable(err))
+
+	t.Setenv(envir.DevboxSearchToken, "wrong")
+	_, err = c.Search(context.Background(), "hello")
+	userErr, ok = usererr.Extract(err)
+	require.True(t, ok)
+	require.Contains(t, userErr.Error(), "rejected the search token")
+
+	t.Setenv(envir.DevboxSearchToken, "s3cret")
+	_, err = c.Search(context.Background(), "hello")
+	require.NoError(t, err)
+}
+
+func TestSearchTokenOnlyOverHTTPS(t *testing.T) {
+	t.Setenv(envir.DevboxSearchToken, "")
+	t.Setenv(envir.DevboxSearchAuth, SearchAuthJetify)
+	token, err := authToken(context.Background(), &url.URL{Scheme: "http", Host: "search.acme.internal"})
+	require.NoError(t, err)
+	require.Empty(t, token, "saved tokens and logins aren't sent in plain text")
+}
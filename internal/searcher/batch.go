@@ -0,0 +1,110 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// MaxBatchSize is how many packages ResolveBatch resolves with one request.
+const MaxBatchSize = 100
+
+// ErrBatchUnsupported means that the search service doesn't have the batch
+// resolve endpoint, so packages must be resolved one at a time.
+var ErrBatchUnsupported = errors.New("the search service can't resolve packages in batches")
+
+// ResolveRequest is a package version for ResolveBatch to resolve.
+type ResolveRequest struct {
+	Name string
+	// Version is a version like the ones that ResolveV2 resolves, which may
+	// have a ?channel= parameter.
+	Version string
+}
+
+type batchRequest struct {
+	Packages []batchPackage `json:"packages"`
+}
+
+type batchPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Channel string `json:"channel,omitempty"`
+}
+
+type batchResponse struct {
+	// Packages has a resolution for each requested package, in the same
+	// order, and null for the packages that weren't found.
+	Packages []*ResolveResponse `json:"packages"`
+}
+
+// ResolveBatch resolves up to MaxBatchSize package versions with a single
+// request to the /v2/resolve/batch endpoint, instead of a /v2/resolve request
+// for each one. It returns the resolution of each requested package in the
+// same order, and nil for packages that weren't found. If the search service
+// doesn't have the endpoint, it returns ErrBatchUnsupported.
+func (c *client) ResolveBatch(ctx context.Context, pkgs []ResolveRequest) ([]*ResolveResponse, error) {
+	if len(pkgs) > MaxBatchSize {
+		return nil, redact.Errorf("can't resolve %d packages in one batch, the maximum is %d", len(pkgs), MaxBatchSize)
+	}
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.resolveBatch(pkgs)
+	}
+
+	req := batchRequest{Packages: make([]batchPackage, len(pkgs))}
+	for i, pkg := range pkgs {
+		version, channel, err := ParseChannel(pkg.Version)
+		if err != nil {
+			return nil, redact.Errorf("%s@%s: %w", pkg.Name, pkg.Version, err)
+		}
+		req.Packages[i] = batchPackage{Name: pkg.Name, Version: version, Channel: channel}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, redact.Errorf("marshal batch resolve request: %w", err)
+	}
+	endpoint, err := url.JoinPath(c.host, "v2/resolve/batch")
+	if err != nil {
+		return nil, redact.Errorf("invalid search endpoint host %q: %w", redact.Safe(c.host), redact.Safe(err))
+	}
+
+	resp, err := execPost[batchResponse](ctx, endpoint, body, c.publicKeys)
+	var statusErr *statusError
+	if errors.Is(err, ErrNotFound) || errors.As(err, &statusErr) && statusErr.code == http.StatusMethodNotAllowed {
+		return nil, ErrBatchUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Packages) != len(pkgs) {
+		return nil, redact.Errorf("batch resolve returned %d packages for a request of %d",
+			len(resp.Packages), len(pkgs))
+	}
+	return resp.Packages, nil
+}
+
+// resolveBatch is ResolveBatch for the packages in an index.
+func (i *PackageIndex) resolveBatch(pkgs []ResolveRequest) ([]*ResolveResponse, error) {
+	resolved := make([]*ResolveResponse, len(pkgs))
+	for j, pkg := range pkgs {
+		version, _, err := ParseChannel(pkg.Version)
+		if err != nil {
+			return nil, redact.Errorf("%s@%s: %w", pkg.Name, pkg.Version, err)
+		}
+		resolved[j], err = i.Resolve(pkg.Name, version)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
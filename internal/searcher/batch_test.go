@@ -0,0 +1,55 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestResolveBatch(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchRetries, "0")
+
+	var got batchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/resolve/batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"packages": [{"name": "go", "version": "1.22.1", "channel": "nixos-24.05"}, null]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	c := &client{host: server.URL}
+	resolved, err := c.ResolveBatch(context.Background(), []ResolveRequest{
+		{Name: "go", Version: "1.22?channel=nixos-24.05"},
+		{Name: "helo", Version: "latest"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resolved, 2)
+	require.Equal(t, "1.22.1", resolved[0].Version)
+	require.Nil(t, resolved[1], "packages that weren't found are nil")
+	require.Equal(t, []batchPackage{
+		{Name: "go", Version: "1.22", Channel: "nixos-24.05"},
+		{Name: "helo", Version: "latest"},
+	}, got.Packages)
+
+	old := &client{host: server.URL + "/old"}
+	_, err = old.ResolveBatch(context.Background(), []ResolveRequest{{Name: "go", Version: "latest"}})
+	require.ErrorIs(t, err, ErrBatchUnsupported)
+
+	_, err = c.ResolveBatch(context.Background(), make([]ResolveRequest, MaxBatchSize+1))
+	require.Error(t, err)
+}
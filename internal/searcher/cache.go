@@ -0,0 +1,108 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/xdg"
+	"go.jetpack.io/pkg/filecache"
+)
+
+// defaultResponseCacheTTL is how long a response from the search service is
+// reused without asking the service again, unless DEVBOX_SEARCH_CACHE_TTL
+// says otherwise. After that, the cached response is revalidated with its
+// ETag, which costs a request but not the response body.
+const defaultResponseCacheTTL = 5 * time.Minute
+
+// maxResponseAge is how long a response is kept for revalidation.
+const maxResponseAge = 7 * 24 * time.Hour
+
+// cachedResponse is a GET response from the search service.
+type cachedResponse struct {
+	ETag string `json:"etag,omitempty"`
+	// Signature is the response's signature header, which is checked
+	// again each time the response is used.
+	Signature string `json:"signature,omitempty"`
+	Body      []byte `json:"body"`
+	// Validated is when the service last sent or confirmed the response.
+	Validated time.Time `json:"validated"`
+}
+
+// responseStore is where responses are cached. Tests replace it.
+type responseStore interface {
+	Get(key string) (*cachedResponse, error)
+	Set(key string, resp *cachedResponse, ttl time.Duration) error
+	Clear() error
+}
+
+var responseCache responseStore = filecache.New(
+	"devbox/search",
+	filecache.WithCacheDir[*cachedResponse](xdg.CacheSubpath("")),
+)
+
+// ClearResponseCache removes every cached response from the search service.
+func ClearResponseCache() error {
+	return responseCache.Clear()
+}
+
+// ResponseCacheTTL returns how long responses are reused without asking the
+// search service. 0 turns the cache off.
+func ResponseCacheTTL() time.Duration {
+	env := os.Getenv(envir.DevboxSearchCacheTTL)
+	if env == "" {
+		return defaultResponseCacheTTL
+	}
+	ttl, err := time.ParseDuration(env)
+	if err != nil || ttl < 0 {
+		debug.Log("ignoring invalid %s=%q", envir.DevboxSearchCacheTTL, env)
+		return defaultResponseCacheTTL
+	}
+	return ttl
+}
+
+// responseCacheKey identifies the response to req. The credentials are part
+// of the key, so that a private service's response to one token isn't
+// returned for another.
+func responseCacheKey(req *http.Request) string {
+	return cachehash.Bytes([]byte(req.URL.String() + "\n" + req.Header.Get("Authorization")))
+}
+
+// cachedResponseFor returns the cached response to req, or nil if there isn't
+// one. Only GET requests are cached.
+func cachedResponseFor(req *http.Request) *cachedResponse {
+	if req.Method != http.MethodGet || ResponseCacheTTL() == 0 {
+		return nil
+	}
+	cached, err := responseCache.Get(responseCacheKey(req))
+	if err != nil {
+		if !filecache.IsCacheMiss(err) {
+			debug.Log("searcher: reading cached response to %s: %v", req.URL.Redacted(), err)
+		}
+		return nil
+	}
+	return cached
+}
+
+// fresh reports whether the response can be used without revalidating it.
+func (c *cachedResponse) fresh() bool {
+	return time.Since(c.Validated) < ResponseCacheTTL()
+}
+
+// cacheResponse saves the response to req. Like the resolution cache, a
+// cache that can't be written only costs a request later.
+func cacheResponse(req *http.Request, resp *cachedResponse) {
+	if req.Method != http.MethodGet || ResponseCacheTTL() == 0 {
+		return
+	}
+	resp.Validated = time.Now()
+	if err := responseCache.Set(responseCacheKey(req), resp, maxResponseAge); err != nil {
+		debug.Log("searcher: caching response to %s: %v", req.URL.Redacted(), err)
+	}
+}
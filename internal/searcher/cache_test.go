@@ -0,0 +1,117 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/pkg/filecache"
+)
+
+// memoryStore is a responseStore that keeps responses in memory, so that
+// tests don't write to the user's cache directory.
+type memoryStore struct {
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+}
+
+func (s *memoryStore) Get(key string) (*cachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp, ok := s.responses[key]; ok {
+		copied := *resp
+		return &copied, nil
+	}
+	return nil, filecache.NotFound
+}
+
+func (s *memoryStore) Set(key string, resp *cachedResponse, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *resp
+	s.responses[key] = &copied
+	return nil
+}
+
+func (s *memoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = map[string]*cachedResponse{}
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	responseCache = &memoryStore{responses: map[string]*cachedResponse{}}
+	os.Exit(m.Run())
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchRetries, "0")
+	t.Setenv(envir.DevboxSearchCacheTTL, "1h")
+	require.NoError(t, ClearResponseCache())
+
+	requests, revalidations := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"num_results": 1, "packages": [{"name": "ripgrep"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	c := &client{host: server.URL}
+
+	for range 2 {
+		results, err := c.Search(context.Background(), "ripgrep")
+		require.NoError(t, err)
+		require.Equal(t, "ripgrep", results.Packages[0].Name)
+	}
+	require.Equal(t, 1, requests, "a fresh response is reused without a request")
+
+	// Once the response is stale, it's revalidated with its ETag.
+	t.Setenv(envir.DevboxSearchCacheTTL, "1ns")
+	results, err := c.Search(context.Background(), "ripgrep")
+	require.NoError(t, err)
+	require.Equal(t, "ripgrep", results.Packages[0].Name)
+	require.Equal(t, 2, requests)
+	require.Equal(t, 1, revalidations)
+
+	// Other queries aren't answered from the cache.
+	_, err = c.Search(context.Background(), "fd")
+	require.NoError(t, err)
+	require.Equal(t, 3, requests)
+	require.Equal(t, 1, revalidations)
+
+	// With the cache off, every search is a full request.
+	t.Setenv(envir.DevboxSearchCacheTTL, "0")
+	_, err = c.Search(context.Background(), "ripgrep")
+	require.NoError(t, err)
+	require.Equal(t, 4, requests)
+	require.Equal(t, 1, revalidations)
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	for env, want := range map[string]time.Duration{
+		"":        defaultResponseCacheTTL,
+		"30s":     30 * time.Second,
+		"0":       0,
+		"-1m":     defaultResponseCacheTTL,
+		"invalid": defaultResponseCacheTTL,
+	} {
+		t.Setenv(envir.DevboxSearchCacheTTL, env)
+		require.Equal(t, want, ResponseCacheTTL(), "%s=%q", envir.DevboxSearchCacheTTL, env)
+	}
+}
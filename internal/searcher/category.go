@@ -0,0 +1,101 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Category is a tag that packages are grouped by, such as "databases".
+type Category struct {
+	Name        string `json:"name"`
+	NumPackages int    `json:"num_packages"`
+}
+
+// CategoriesResults is a response from the /v1/categories endpoint.
+type CategoriesResults struct {
+	Categories []Category `json:"categories"`
+}
+
+// Category lists the packages tagged with category, with their versions like
+// Search. Categories aren't case sensitive. If there's no such category, it
+// returns ErrNotFound.
+func (c *client) Category(ctx context.Context, category string) (*SearchResults, error) {
+	if category == "" {
+		return nil, errors.New("category should not be empty")
+	}
+	category = strings.ToLower(category)
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.Category(category)
+	}
+
+	endpoint, err := url.JoinPath(c.host, "v1/categories", url.PathEscape(category))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return execGet[SearchResults](ctx, endpoint, c.publicKeys)
+}
+
+// Categories lists the categories that packages are tagged with, by name.
+func (c *client) Categories(ctx context.Context) ([]Category, error) {
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.Categories(), nil
+	}
+
+	endpoint, err := url.JoinPath(c.host, "v1/categories")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	results, err := execGet[CategoriesResults](ctx, endpoint, c.publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	return results.Categories, nil
+}
+
+// Category is client.Category for the packages in the index.
+func (i *PackageIndex) Category(category string) (*SearchResults, error) {
+	results := i.search(func(resolved *ResolveResponse) bool {
+		return slices.ContainsFunc(resolved.Tags, func(tag string) bool {
+			return strings.EqualFold(tag, category)
+		})
+	})
+	if len(results.Packages) == 0 {
+		return nil, ErrNotFound
+	}
+	return results, nil
+}
+
+// Categories is client.Categories for the packages in the index.
+func (i *PackageIndex) Categories() []Category {
+	packages := map[string]map[string]bool{}
+	for _, resolved := range i.Packages {
+		for _, tag := range resolved.Tags {
+			tag = strings.ToLower(tag)
+			if packages[tag] == nil {
+				packages[tag] = map[string]bool{}
+			}
+			packages[tag][resolved.Name] = true
+		}
+	}
+	categories := make([]Category, 0, len(packages))
+	for name, names := range packages {
+		categories = append(categories, Category{Name: name, NumPackages: len(names)})
+	}
+	slices.SortFunc(categories, func(a, b Category) int { return strings.Compare(a.Name, b.Name) })
+	return categories
+}
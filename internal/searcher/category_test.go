@@ -0,0 +1,70 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestPackageIndexCategory(t *testing.T) {
+	index := &PackageIndex{Packages: []ResolveResponse{
+		{Name: "postgresql", Version: "15.6", Tags: []string{"databases"}},
+		{Name: "postgresql", Version: "16.2", Tags: []string{"Databases", "sql"}},
+		{Name: "redis", Version: "7.2.4", Tags: []string{"databases"}},
+		{Name: "go", Version: "1.22.1", Tags: []string{"languages"}},
+		{Name: "hello", Version: "2.12.1"},
+	}}
+
+	results, err := index.Category("databases")
+	require.NoError(t, err)
+	require.Len(t, results.Packages, 2)
+	require.Equal(t, "postgresql", results.Packages[0].Name)
+	require.Len(t, results.Packages[0].Versions, 2)
+	require.Equal(t, []string{"databases", "sql"}, results.Packages[0].Tags)
+
+	_, err = index.Category("games")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.Equal(t, []Category{
+		{Name: "databases", NumPackages: 2},
+		{Name: "languages", NumPackages: 1},
+		{Name: "sql", NumPackages: 1},
+	}, index.Categories())
+}
+
+func TestCategory(t *testing.T) {
+	t.Setenv(envir.DevboxNetworkPolicy, "")
+	t.Setenv(envir.DevboxSearchRetries, "0")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/categories/databases":
+			w.Write([]byte(`{"num_results": 1, "packages": [{"name": "redis", "num_versions": 1, "tags": ["databases"]}]}`))
+		case "/v1/categories":
+			w.Write([]byte(`{"categories": [{"name": "databases", "num_packages": 1}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	c := &client{host: server.URL}
+	results, err := c.Category(context.Background(), "Databases")
+	require.NoError(t, err)
+	require.Equal(t, "redis", results.Packages[0].Name)
+	require.Equal(t, []string{"databases"}, results.Packages[0].Tags)
+
+	_, err = c.Category(context.Background(), "games")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	categories, err := c.Categories(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Category{{Name: "databases", NumPackages: 1}}, categories)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// channelRegex matches the nixpkgs and NixOS channels, such as
+// nixpkgs-unstable, nixos-unstable, nixos-24.05 and nixpkgs-24.05-darwin.
+var channelRegex = regexp.MustCompile(`^(nixpkgs|nixos)-(unstable|\d{2}\.\d{2}(-darwin|-small)?)$`)
+
+// ParseChannel splits a package version that selects a nixpkgs channel, such
+// as "1.23?channel=nixpkgs-unstable", into the version and the channel. The
+// search service then resolves the version from that channel instead of
+// from the default one. Versions without a channel return a channel of "".
+func ParseChannel(version string) (string, string, error) {
+	version, query, found := strings.Cut(version, "?")
+	if !found {
+		return version, "", nil
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid query %q: %w", query, err)
+	}
+	for param := range params {
+		if param != "channel" {
+			return "", "", fmt.Errorf("unknown parameter %q, only channel is supported", param)
+		}
+	}
+	channel := params.Get("channel")
+	if !channelRegex.MatchString(channel) {
+		return "", "", fmt.Errorf(
+			"%q isn't a nixpkgs channel, such as nixpkgs-unstable or nixos-24.05", channel)
+	}
+	return version, channel, nil
+}
+
+// WithChannel appends the channel to version in the form that ParseChannel
+// parses. A channel of "" returns version as is.
+func WithChannel(version, channel string) string {
+	if channel == "" {
+		return version
+	}
+	return version + "?channel=" + channel
+}
+
+// CheckChannel returns an error if the search service resolved version from
+// a channel other than the one that version selects. A service that doesn't
+// know about channels ignores the channel parameter and resolves the version
+// from its default channel, which would otherwise go unnoticed.
+func CheckChannel(version, resolvedChannel string) error {
+	_, channel, err := ParseChannel(version)
+	if err != nil {
+		return err
+	}
+	if channel != "" && resolvedChannel != channel {
+		return fmt.Errorf("the search service didn't resolve it from channel %s", channel)
+	}
+	return nil
+}
+
+// resolveURL is the URL of a resolve endpoint for name@version, with the
+// channel that the version selects, if any.
+func resolveURL(endpoint, name, version string) (string, error) {
+	version, channel, err := ParseChannel(version)
+	if err != nil {
+		return "", err
+	}
+	resolveURL := endpoint +
+		"?name=" + url.QueryEscape(name) +
+		"&version=" + url.QueryEscape(version)
+	if channel != "" {
+		resolveURL += "&channel=" + url.QueryEscape(channel)
+	}
+	return resolveURL, nil
+}
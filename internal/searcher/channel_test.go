@@ -0,0 +1,66 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import "testing"
+
+func TestParseChannel(t *testing.T) {
+	for _, test := range []struct {
+		in, version, channel string
+	}{
+		{"1.23", "1.23", ""},
+		{"1.23?channel=nixpkgs-unstable", "1.23", "nixpkgs-unstable"},
+		{"latest?channel=nixos-24.05", "latest", "nixos-24.05"},
+		{"^20?channel=nixpkgs-24.05-darwin", "^20", "nixpkgs-24.05-darwin"},
+	} {
+		version, channel, err := ParseChannel(test.in)
+		if err != nil {
+			t.Errorf("ParseChannel(%q) got error: %v", test.in, err)
+			continue
+		}
+		if version != test.version || channel != test.channel {
+			t.Errorf("ParseChannel(%q) = %q, %q, want %q, %q",
+				test.in, version, channel, test.version, test.channel)
+		}
+		if got := WithChannel(version, channel); got != test.in {
+			t.Errorf("WithChannel(%q, %q) = %q, want %q", version, channel, got, test.in)
+		}
+	}
+
+	for _, in := range []string{"1.23?channel=", "1.23?channel=master", "1.23?rev=abc", "1.23?channel=nixos-24"} {
+		if _, _, err := ParseChannel(in); err == nil {
+			t.Errorf("ParseChannel(%q) got nil error", in)
+		}
+	}
+}
+
+func TestResolveURLChannel(t *testing.T) {
+	got, err := resolveURL("https://search.devbox.sh/v2/resolve", "go", "1.23?channel=nixpkgs-unstable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://search.devbox.sh/v2/resolve?name=go&version=1.23&channel=nixpkgs-unstable"
+	if got != want {
+		t.Errorf("got URL %q, want %q", got, want)
+	}
+}
+
+func TestCheckChannel(t *testing.T) {
+	for _, test := range []struct {
+		version, resolved string
+		wantErr           bool
+	}{
+		{"1.23", "", false},
+		{"1.23", "nixpkgs-unstable", false},
+		{"1.23?channel=nixpkgs-unstable", "nixpkgs-unstable", false},
+		{"1.23?channel=nixpkgs-unstable", "", true},
+		{"1.23?channel=nixpkgs-unstable", "nixos-24.05", true},
+	} {
+		err := CheckChannel(test.version, test.resolved)
+		if (err != nil) != test.wantErr {
+			t.Errorf("CheckChannel(%q, %q) got error %v, want error: %v",
+				test.version, test.resolved, err, test.wantErr)
+		}
+	}
+}
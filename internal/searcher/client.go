@@ -4,6 +4,7 @@
 package searcher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,28 +13,50 @@ import (
 	"net/url"
 
 	"github.com/pkg/errors"
-	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/netpolicy"
+	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/redact"
 )
 
-const searchAPIEndpoint = "https://search.devbox.sh"
-
 var ErrNotFound = errors.New("Not found")
 
 type client struct {
 	host string
+	// publicKeys, if any, are the keys that responses must be signed by.
+	publicKeys []nix.PublicKey
 }
 
 func Client() *client {
-	return &client{
-		host: envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint),
-	}
+	return &client{host: Host()}
+}
+
+// WithPublicKeys returns a copy of the client that rejects responses that
+// aren't signed by one of keys. Without keys, responses aren't checked.
+func (c *client) WithPublicKeys(keys []nix.PublicKey) *client {
+	verifying := *c
+	verifying.publicKeys = keys
+	return &verifying
+}
+
+// Host returns the URL of the search service that resolves package versions.
+// It can be changed with DEVBOX_SEARCH_HOST or a network policy.
+func Host() string {
+	return netpolicy.URL(netpolicy.Search)
 }
 
-func (c *client) Search(query string) (*SearchResults, error) {
+func (c *client) Search(ctx context.Context, query string) (*SearchResults, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query should not be empty")
 	}
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.Search(query), nil
+	}
 
 	endpoint, err := url.JoinPath(c.host, "v1/search")
 	if err != nil {
@@ -41,12 +64,17 @@ func (c *client) Search(query string) (*SearchResults, error) {
 	}
 	searchURL := endpoint + "?q=" + url.QueryEscape(query)
 
-	return execGet[SearchResults](context.TODO(), searchURL)
+	return execGet[SearchResults](ctx, searchURL, c.publicKeys)
 }
 
 // Resolve calls the /resolve endpoint of the search service. This returns
 // the latest version of the package that matches the version constraint.
 func (c *client) Resolve(name, version string) (*PackageVersion, error) {
+	return c.ResolveContext(context.Background(), name, version)
+}
+
+// ResolveContext is Resolve with a context that can cancel the request.
+func (c *client) ResolveContext(ctx context.Context, name, version string) (*PackageVersion, error) {
 	if name == "" || version == "" {
 		return nil, fmt.Errorf("name and version should not be empty")
 	}
@@ -55,11 +83,19 @@ func (c *client) Resolve(name, version string) (*PackageVersion, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	searchURL := endpoint +
-		"?name=" + url.QueryEscape(name) +
-		"&version=" + url.QueryEscape(version)
+	searchURL, err := resolveURL(endpoint, name, version)
+	if err != nil {
+		return nil, err
+	}
 
-	return execGet[PackageVersion](context.TODO(), searchURL)
+	packageVersion, err := execGet[PackageVersion](ctx, searchURL, c.publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckChannel(version, packageVersion.Channel); err != nil {
+		return nil, fmt.Errorf("%s@%s: %w", name, version, err)
+	}
+	return packageVersion, nil
 }
 
 // Resolve calls the /resolve endpoint of the search service. This returns
@@ -71,45 +107,136 @@ func (c *client) ResolveV2(ctx context.Context, name, version string) (*ResolveR
 	if version == "" {
 		return nil, redact.Errorf("version is empty")
 	}
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		// An index doesn't know which channel its packages are from, so
+		// it only resolves the version.
+		unchanneled, _, err := ParseChannel(version)
+		if err != nil {
+			return nil, redact.Errorf("%s@%s: %w", name, version, err)
+		}
+		return index.Resolve(name, unchanneled)
+	}
 
 	endpoint, err := url.JoinPath(c.host, "v2/resolve")
 	if err != nil {
 		return nil, redact.Errorf("invalid search endpoint host %q: %w", redact.Safe(c.host), redact.Safe(err))
 	}
-	searchURL := endpoint +
-		"?name=" + url.QueryEscape(name) +
-		"&version=" + url.QueryEscape(version)
+	searchURL, err := resolveURL(endpoint, name, version)
+	if err != nil {
+		return nil, redact.Errorf("%s@%s: %w", name, version, err)
+	}
 
-	return execGet[ResolveResponse](ctx, searchURL)
+	resolved, err := execGet[ResolveResponse](ctx, searchURL, c.publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckChannel(version, resolved.Channel); err != nil {
+		return nil, redact.Errorf("%s@%s: %w", name, version, err)
+	}
+	return resolved, nil
 }
 
-func execGet[T any](ctx context.Context, url string) (*T, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// execGet sends a GET request to url and decodes the JSON response. Requests
+// that time out or fail with a server error are retried according to the
+// DEVBOX_SEARCH_* environment variables. With keys, the response must be
+// signed by one of them.
+func execGet[T any](ctx context.Context, url string, keys []nix.PublicKey) (*T, error) {
+	return execRequest[T](ctx, http.MethodGet, url, nil, keys)
+}
+
+// execPost is execGet for a POST request with a JSON body.
+func execPost[T any](ctx context.Context, url string, body []byte, keys []nix.PublicKey) (*T, error) {
+	return execRequest[T](ctx, http.MethodPost, url, body, keys)
+}
+
+func execRequest[T any](ctx context.Context, method, url string, body []byte, keys []nix.PublicKey) (*T, error) {
+	var result *T
+	err := currentRetryPolicy().do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = execOnce[T](ctx, method, url, body, keys)
+		return err
+	})
+	return result, err
+}
+
+func execOnce[T any](ctx context.Context, method, url string, body []byte, keys []nix.PublicKey) (*T, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
-		return nil, redact.Errorf("GET %s: %w", redact.Safe(url), redact.Safe(err))
+		return nil, redact.Errorf("%s %s: %w", redact.Safe(method), redact.Safe(url), redact.Safe(err))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := authenticate(req); err != nil {
+		return nil, err
+	}
+	cached := cachedResponseFor(req)
+	if cached != nil && cached.fresh() {
+		debug.Log("searcher: using cached response to %s", req.URL.Redacted())
+		return decodeResponse[T](req, body, cached.Body, cached.Signature, keys)
 	}
-	response, err := http.DefaultClient.Do(req)
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	response, err := httpclient.Client().Do(req)
 	if err != nil {
-		return nil, redact.Errorf("GET %s: %w", redact.Safe(url), redact.Safe(err))
+		return nil, redact.Errorf("%s %s: %w", redact.Safe(method), redact.Safe(url), redact.Safe(err))
 	}
 	defer response.Body.Close()
 	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, redact.Errorf("GET %s: read respoonse body: %w", redact.Safe(url), redact.Safe(err))
+		return nil, redact.Errorf("%s %s: read respoonse body: %w", redact.Safe(method), redact.Safe(url), redact.Safe(err))
+	}
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		cacheResponse(req, cached)
+		return decodeResponse[T](req, body, cached.Body, cached.Signature, keys)
 	}
 	if response.StatusCode == http.StatusNotFound {
 		return nil, ErrNotFound
 	}
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		err := redact.Errorf("%s %s: unexpected status code %s", redact.Safe(method), redact.Safe(url), redact.Safe(response.Status))
+		return nil, authError(err, req.URL, req.Header.Get("Authorization") != "")
+	}
 	if response.StatusCode >= 400 {
-		return nil, redact.Errorf("GET %s: unexpected status code %s: %s",
+		return nil, &statusError{code: response.StatusCode, err: redact.Errorf("%s %s: unexpected status code %s: %s",
+			redact.Safe(method),
 			redact.Safe(url),
 			redact.Safe(response.Status),
 			redact.Safe(data),
-		)
+		)}
+	}
+	signature := response.Header.Get(signatureHeader)
+	result, err := decodeResponse[T](req, body, data, signature, keys)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusOK {
+		cacheResponse(req, &cachedResponse{ETag: response.Header.Get("ETag"), Signature: signature, Body: data})
+	}
+	return result, nil
+}
+
+// decodeResponse checks the signature of a response to req, which was sent
+// with reqBody, if there are keys, and decodes its JSON.
+func decodeResponse[T any](req *http.Request, reqBody, data []byte, signature string, keys []nix.PublicKey) (*T, error) {
+	if len(keys) > 0 {
+		if err := verifySignature(keys, req.URL, reqBody, signature, data); err != nil {
+			return nil, err
+		}
 	}
 	var result T
 	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, redact.Errorf("GET %s: unmarshal response JSON: %w", redact.Safe(url), redact.Safe(err))
+		return nil, redact.Errorf("%s %s: unmarshal response JSON: %w",
+			redact.Safe(req.Method), redact.Safe(req.URL.String()), redact.Safe(err))
 	}
 	return &result, nil
 }
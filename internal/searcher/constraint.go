@@ -0,0 +1,165 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VersionConstraint is a semver range that a package version can be
+// constrained to in devbox.json, such as "^1.21" or ">=18 <21". A version
+// matches when it satisfies every comparator in the range. Unlike a plain
+// version, which the search service resolves, devbox picks the best match
+// itself from all of the package's versions.
+type VersionConstraint struct {
+	raw         string
+	comparators []comparator
+}
+
+type comparator struct {
+	op      string
+	version string // canonical semver, with a "v" prefix
+}
+
+// IsVersionConstraint reports whether version is a range instead of a plain
+// version. Ranges start with one of ^, ~, <, > or =.
+func IsVersionConstraint(version string) bool {
+	return version != "" && strings.ContainsAny(version[:1], "^~<>=")
+}
+
+// ParseVersionConstraint parses a range made of comparators separated by
+// spaces. Each comparator is a version with one of these prefixes:
+//
+//   - ^1.21 allows changes that don't modify the first non-zero part, so
+//     >=1.21.0 <2.0.0
+//   - ~1.21 allows patch versions, so >=1.21.0 <1.22.0
+//   - >=, >, <=, < and = compare with the version
+//
+// Versions can leave out their minor and patch parts.
+func ParseVersionConstraint(constraint string) (*VersionConstraint, error) {
+	c := &VersionConstraint{raw: constraint}
+	fields := strings.Fields(constraint)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty version range")
+	}
+	for _, field := range fields {
+		comparators, err := parseComparator(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q: %w", constraint, err)
+		}
+		c.comparators = append(c.comparators, comparators...)
+	}
+	return c, nil
+}
+
+func parseComparator(field string) ([]comparator, error) {
+	op := strings.TrimRight(field[:min(2, len(field))], "0123456789.v")
+	version := field[len(op):]
+	parts, ok := versionParts(version)
+	if !ok {
+		return nil, fmt.Errorf("%q isn't a version with up to 3 numeric parts", version)
+	}
+	lower := canonicalVersion(parts)
+
+	switch op {
+	case ">=", ">", "<=", "<", "=":
+		return []comparator{{op, lower}}, nil
+	case "^":
+		// Bump the first non-zero part, or the last given part if they're
+		// all zero.
+		i := slices.IndexFunc(parts, func(n int) bool { return n != 0 })
+		if i == -1 {
+			i = len(parts) - 1
+		}
+		return []comparator{{">=", lower}, {"<", bumpVersion(parts, i)}}, nil
+	case "~":
+		// ~1 allows any minor version, and ~1.21 and ~1.21.3 only allow
+		// patch versions.
+		return []comparator{{">=", lower}, {"<", bumpVersion(parts, min(1, len(parts)-1))}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// versionParts parses a version such as "1", "1.21" or "1.21.3".
+func versionParts(version string) ([]int, bool) {
+	split := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(split) > 3 {
+		return nil, false
+	}
+	parts := make([]int, len(split))
+	for i, s := range split {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func canonicalVersion(parts []int) string {
+	full := [3]int{}
+	copy(full[:], parts)
+	return fmt.Sprintf("v%d.%d.%d", full[0], full[1], full[2])
+}
+
+// bumpVersion increments part i of the version and drops the parts after it.
+func bumpVersion(parts []int, i int) string {
+	bumped := slices.Clone(parts[:i+1])
+	bumped[i]++
+	return canonicalVersion(bumped)
+}
+
+// Matches reports whether version satisfies the range. Versions that aren't
+// semver, and pre-releases, never match.
+func (c *VersionConstraint) Matches(version string) bool {
+	v := semver.Canonical("v" + strings.TrimPrefix(version, "v"))
+	if v == "" || semver.Prerelease(v) != "" {
+		return false
+	}
+	for _, cmp := range c.comparators {
+		result := semver.Compare(v, cmp.version)
+		var ok bool
+		switch cmp.op {
+		case ">=":
+			ok = result >= 0
+		case ">":
+			ok = result > 0
+		case "<=":
+			ok = result <= 0
+		case "<":
+			ok = result < 0
+		case "=":
+			ok = result == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Best returns the highest of versions that matches the range, or "" if
+// none of them do.
+func (c *VersionConstraint) Best(versions []string) string {
+	best := ""
+	for _, version := range versions {
+		if !c.Matches(version) {
+			continue
+		}
+		if best == "" || semver.Compare("v"+strings.TrimPrefix(version, "v"), "v"+strings.TrimPrefix(best, "v")) > 0 {
+			best = version
+		}
+	}
+	return best
+}
+
+func (c *VersionConstraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import "testing"
+
+func TestVersionConstraint(t *testing.T) {
+	versions := []string{"1.20.14", "1.21.0", "1.21.8", "1.22.1", "1.23rc1", "2.0.0", "unstable-2024-01-01"}
+	testCases := []struct {
+		constraint string
+		best       string
+	}{
+		{"^1.21", "1.22.1"},
+		{"~1.21", "1.21.8"},
+		{"~1", "1.22.1"},
+		{">=1.21 <1.22", "1.21.8"},
+		{">1.21.0 <=1.21.8", "1.21.8"},
+		{"=1.21.0", "1.21.0"},
+		{">=2", "2.0.0"},
+		{"<1.20", ""},
+		{"^0.3", ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.constraint, func(t *testing.T) {
+			if !IsVersionConstraint(testCase.constraint) {
+				t.Errorf("%q isn't a version constraint", testCase.constraint)
+			}
+			c, err := ParseVersionConstraint(testCase.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if best := c.Best(versions); best != testCase.best {
+				t.Errorf("got best version %q, want %q", best, testCase.best)
+			}
+		})
+	}
+}
+
+func TestCaretConstraintWithZeroMajor(t *testing.T) {
+	c, err := ParseVersionConstraint("^0.3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version, want := range map[string]bool{"0.3.1": true, "0.3.9": true, "0.4.0": false, "0.3.0": false} {
+		if got := c.Matches(version); got != want {
+			t.Errorf("^0.3.1 matches %s = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestInvalidVersionConstraint(t *testing.T) {
+	for _, constraint := range []string{"^", ">=abc", "^1.2.3.4", ">=1 2", "!1", "=>1"} {
+		if _, err := ParseVersionConstraint(constraint); err == nil {
+			t.Errorf("ParseVersionConstraint(%q) succeeded, want an error", constraint)
+		}
+	}
+	for _, version := range []string{"1.21", "latest", ""} {
+		if IsVersionConstraint(version) {
+			t.Errorf("%q is a version constraint", version)
+		}
+	}
+}
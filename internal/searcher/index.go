@@ -0,0 +1,135 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+	"golang.org/x/mod/semver"
+)
+
+// PackageIndex is a local mirror of the search service for air-gapped
+// machines. It holds the /v2/resolve response of each package version that
+// can be installed, and devbox resolves packages from it without any network
+// when DEVBOX_PACKAGE_INDEX points to it.
+type PackageIndex struct {
+	Packages []ResolveResponse `json:"packages"`
+}
+
+// PackageIndexPath returns the package index set by DEVBOX_PACKAGE_INDEX, or
+// "" if packages are resolved by the search service.
+func PackageIndexPath() string {
+	return os.Getenv(envir.DevboxPackageIndex)
+}
+
+var (
+	indexMu sync.Mutex
+	indexes = map[string]*PackageIndex{}
+)
+
+// LoadPackageIndex reads the package index at path. Each index is only read
+// once per process.
+func LoadPackageIndex(path string) (*PackageIndex, error) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if index, ok := indexes[path]; ok {
+		return index, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Devbox can't read the package index %s.", path)
+	}
+	index := &PackageIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, usererr.New("%s isn't a valid package index: %v", path, err)
+	}
+	indexes[path] = index
+	return index, nil
+}
+
+// Resolve returns the version of name that the search service would resolve
+// version to: the highest version for "latest", an exact match, or else the
+// highest version that starts with version, so that "3.12" matches "3.12.2".
+func (i *PackageIndex) Resolve(name, version string) (*ResolveResponse, error) {
+	var best *ResolveResponse
+	for j := range i.Packages {
+		pkg := &i.Packages[j]
+		if pkg.Name != name {
+			continue
+		}
+		if pkg.Version == version {
+			return pkg, nil
+		}
+		if version != "latest" && !strings.HasPrefix(pkg.Version, version+".") {
+			continue
+		}
+		if best == nil || CompareVersions(pkg.Version, best.Version) > 0 {
+			best = pkg
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+	return best, nil
+}
+
+// Search lists the packages whose names contain query, with all of their
+// versions.
+func (i *PackageIndex) Search(query string) *SearchResults {
+	return i.search(func(resolved *ResolveResponse) bool {
+		return strings.Contains(resolved.Name, query)
+	})
+}
+
+// search lists the packages with a version that match reports true for, with
+// all of those versions.
+func (i *PackageIndex) search(match func(*ResolveResponse) bool) *SearchResults {
+	results := &SearchResults{}
+	byName := map[string]int{}
+	for k := range i.Packages {
+		resolved := &i.Packages[k]
+		if !match(resolved) {
+			continue
+		}
+		j, ok := byName[resolved.Name]
+		if !ok {
+			j = len(results.Packages)
+			byName[resolved.Name] = j
+			results.Packages = append(results.Packages, Package{Name: resolved.Name})
+		}
+		pkg := &results.Packages[j]
+		pkg.Tags = lo.Union(pkg.Tags, lo.Map(resolved.Tags, func(tag string, _ int) string {
+			return strings.ToLower(tag)
+		}))
+		pkg.Versions = append(pkg.Versions, PackageVersion{
+			Name: resolved.Name,
+			PackageInfo: PackageInfo{
+				Version:         resolved.Version,
+				Summary:         resolved.Summary,
+				License:         resolved.License,
+				Vulnerabilities: resolved.Vulnerabilities,
+			},
+		})
+		pkg.NumVersions++
+	}
+	results.NumResults = len(results.Packages)
+	return results
+}
+
+// CompareVersions compares two package versions as semver when they both
+// are, and as strings otherwise.
+func CompareVersions(a, b string) int {
+	va, vb := "v"+strings.TrimPrefix(a, "v"), "v"+strings.TrimPrefix(b, "v")
+	if semver.IsValid(va) && semver.IsValid(vb) {
+		return semver.Compare(va, vb)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPackageIndexResolve(t *testing.T) {
+	index := &PackageIndex{Packages: []ResolveResponse{
+		{Name: "python", Version: "3.11.8"},
+		{Name: "python", Version: "3.12.1"},
+		{Name: "python", Version: "3.12.2"},
+		{Name: "python3Packages.pip", Version: "24.0"},
+		{Name: "hello", Version: "2.12.1"},
+	}}
+
+	testCases := map[string]string{
+		"python@latest": "3.12.2",
+		"python@3":      "3.12.2",
+		"python@3.11":   "3.11.8",
+		"python@3.12.1": "3.12.1",
+		"hello@latest":  "2.12.1",
+	}
+	for pkg, want := range testCases {
+		name, version, _ := ParseVersionedPackage(pkg)
+		resolved, err := index.Resolve(name, version)
+		if err != nil {
+			t.Errorf("Resolve(%q) got error: %v", pkg, err)
+			continue
+		}
+		if resolved.Version != want {
+			t.Errorf("Resolve(%q) got version %s, want %s", pkg, resolved.Version, want)
+		}
+	}
+
+	for _, pkg := range []string{"python@3.1", "python@2", "ruby@latest"} {
+		name, version, _ := ParseVersionedPackage(pkg)
+		if _, err := index.Resolve(name, version); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Resolve(%q) got error %v, want ErrNotFound", pkg, err)
+		}
+	}
+}
+
+func TestPackageIndexSearch(t *testing.T) {
+	index := &PackageIndex{Packages: []ResolveResponse{
+		{Name: "python", Version: "3.11.8"},
+		{Name: "python", Version: "3.12.2"},
+		{Name: "python3Packages.pip", Version: "24.0"},
+		{Name: "hello", Version: "2.12.1"},
+	}}
+
+	results := index.Search("python")
+	if results.NumResults != 2 {
+		t.Fatalf("got %d results, want 2", results.NumResults)
+	}
+	if pkg := results.Packages[0]; pkg.Name != "python" || pkg.NumVersions != 2 || pkg.Versions[1].Version != "3.12.2" {
+		t.Errorf("got package %+v, want python with its 2 versions", pkg)
+	}
+}
+
+func TestPackageIndexVersions(t *testing.T) {
+	index := &PackageIndex{}
+	err := json.Unmarshal([]byte(`{"packages": [
+		{"name": "python", "version": "3.11.8", "systems": {
+			"x86_64-linux": {"last_updated": "2024-01-02T00:00:00Z"}
+		}},
+		{"name": "python", "version": "3.12.2", "systems": {
+			"x86_64-linux": {"last_updated": "2024-03-01T00:00:00Z"},
+			"aarch64-darwin": {"last_updated": "2024-03-04T00:00:00Z"}
+		}},
+		{"name": "hello", "version": "2.12.1"}
+	]}`), index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := index.Versions("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResolvableVersion{
+		{
+			Version:     "3.12.2",
+			Systems:     []string{"aarch64-darwin", "x86_64-linux"},
+			LastUpdated: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Version:     "3.11.8",
+			Systems:     []string{"x86_64-linux"},
+			LastUpdated: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("got versions %+v, want %+v", versions, want)
+	}
+
+	if _, err := index.Versions("ruby"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Versions(ruby) got error %v, want ErrNotFound", err)
+	}
+}
@@ -18,6 +18,8 @@ type Package struct {
 	Name        string           `json:"name"`
 	NumVersions int              `json:"num_versions"`
 	Versions    []PackageVersion `json:"versions,omitempty"`
+	// Tags are the categories that the package is in, such as "databases".
+	Tags []string `json:"tags,omitempty"`
 }
 
 type PackageVersion struct {
@@ -25,6 +27,9 @@ type PackageVersion struct {
 
 	Name    string                 `json:"name"`
 	Systems map[string]PackageInfo `json:"systems,omitempty"`
+	// Channel is the nixpkgs channel that the version was resolved from,
+	// if the request selected one.
+	Channel string `json:"channel,omitempty"`
 }
 
 type PackageInfo struct {
@@ -40,6 +45,26 @@ type PackageInfo struct {
 	AttrPaths    []string `json:"attr_paths"`
 	Version      string   `json:"version"`
 	Summary      string   `json:"summary"`
+	License      []string `json:"license,omitempty"`
+	// Vulnerabilities are the known vulnerabilities in this version.
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Vulnerability is a known vulnerability in a package version, as the search
+// service reports it.
+type Vulnerability struct {
+	// ID is the vulnerability's identifier, such as a CVE or GHSA ID.
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	// Severity is "low", "medium", "high" or "critical", if it's known.
+	Severity string `json:"severity,omitempty"`
+	// Score is the CVSS base score, or 0 if it's unknown.
+	Score float64 `json:"score,omitempty"`
+	// FixedIn lists the versions of the package that fix the
+	// vulnerability, if they're known.
+	FixedIn []string `json:"fixed_in,omitempty"`
+	URL     string   `json:"url,omitempty"`
 }
 
 // ResolveResponse is a response from the /v2/resolve endpoint.
@@ -52,9 +77,28 @@ type ResolveResponse struct {
 	// Version is the resolved package version.
 	Version string `json:"version"`
 
+	// Channel is the nixpkgs channel that the version was resolved from,
+	// if the request selected one.
+	Channel string `json:"channel,omitempty"`
+
 	// Summary is a short package description.
 	Summary string `json:"summary,omitempty"`
 
+	// License holds the SPDX identifiers of the package's licenses.
+	License []string `json:"license,omitempty"`
+
+	// Programs are the commands that the package installs in its bin
+	// directory, such as "dig" for dnsutils.
+	Programs []string `json:"programs,omitempty"`
+
+	// Tags are the categories that the package is in, such as "databases"
+	// or "languages".
+	Tags []string `json:"tags,omitempty"`
+
+	// Vulnerabilities are the known vulnerabilities in the resolved
+	// version.
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+
 	// Systems contains information about the package that can vary across
 	// systems. It will always have at least one system. The keys match a
 	// Nix system identifier (aarch64-darwin, x86_64-linux, etc.).
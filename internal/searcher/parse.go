@@ -4,6 +4,8 @@
 package searcher
 
 import (
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -20,8 +22,47 @@ func ParseVersionedPackage(versionedName string) (name, version string, found bo
 		// example: `emacsPackages.@`
 		return "", "", false
 	}
+	if atSymbolIndex > 0 && versionedName[atSymbolIndex-1] == ':' {
+		// This is the scope of an npm package without a version
+		// example: `npm:@angular/cli`
+		return "", "", false
+	}
 
 	// Common case: package@version
 	name, version = versionedName[:atSymbolIndex], versionedName[atSymbolIndex+1:]
 	return name, version, true
 }
+
+// systemVersionRegex matches one "<system>=<version>" pair of a per-system
+// version.
+var systemVersionRegex = regexp.MustCompile(`^([a-z0-9_]+-[a-z]+)=([^=,#@]+)$`)
+
+// FormatSystemVersions encodes a package version that's different on each
+// system as a single version string, such as
+// "aarch64-darwin=20,x86_64-linux=18". The systems are sorted so the same
+// versions always make the same string.
+func FormatSystemVersions(versions map[string]string) string {
+	pairs := make([]string, 0, len(versions))
+	for system, version := range versions {
+		pairs = append(pairs, system+"="+version)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseSystemVersions parses a version made by FormatSystemVersions into the
+// version for each system. It returns false for ordinary versions.
+func ParseSystemVersions(version string) (map[string]string, bool) {
+	if !strings.Contains(version, "=") {
+		return nil, false
+	}
+	versions := map[string]string{}
+	for _, pair := range strings.Split(version, ",") {
+		match := systemVersionRegex.FindStringSubmatch(pair)
+		if match == nil {
+			return nil, false
+		}
+		versions[match[1]] = match[2]
+	}
+	return versions, true
+}
@@ -1,6 +1,7 @@
 package searcher
 
 import (
+	"maps"
 	"testing"
 )
 
@@ -47,6 +48,20 @@ func TestParseVersionedPackage(t *testing.T) {
 			expectedName:    "",
 			expectedVersion: "",
 		},
+		{
+			name:            "scoped-npm-package",
+			input:           "npm:@angular/cli@17",
+			expectedFound:   true,
+			expectedName:    "npm:@angular/cli",
+			expectedVersion: "17",
+		},
+		{
+			name:            "scoped-npm-package-no-version",
+			input:           "npm:@angular/cli",
+			expectedFound:   false,
+			expectedName:    "",
+			expectedVersion: "",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -64,3 +79,21 @@ func TestParseVersionedPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestSystemVersions(t *testing.T) {
+	versions := map[string]string{"x86_64-linux": "18", "aarch64-darwin": "20.11"}
+	version := FormatSystemVersions(versions)
+	if want := "aarch64-darwin=20.11,x86_64-linux=18"; version != want {
+		t.Errorf("got version %q, want %q", version, want)
+	}
+	got, ok := ParseSystemVersions(version)
+	if !ok || !maps.Equal(got, versions) {
+		t.Errorf("got versions %v, %v, want %v", got, ok, versions)
+	}
+
+	for _, version := range []string{"18", "latest", "1.2.3#commit=abc", "x86_64-linux=", "linux=18"} {
+		if _, ok := ParseSystemVersions(version); ok {
+			t.Errorf("version %q parsed as per-system versions", version)
+		}
+	}
+}
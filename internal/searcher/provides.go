@@ -0,0 +1,127 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// Provider is a package that installs a program.
+type Provider struct {
+	// Name is the package's name, which can be added to devbox.json.
+	Name string `json:"name"`
+	// Version is the newest version of the package that has the program.
+	Version string `json:"version"`
+	Summary string `json:"summary,omitempty"`
+	// MainProgram reports whether the program is the package's main
+	// program in nixpkgs, which makes the package the likely one to add.
+	MainProgram bool `json:"main_program,omitempty"`
+}
+
+// ProvidesResults is a response from the /v1/provides endpoint.
+type ProvidesResults struct {
+	Program  string     `json:"program"`
+	Packages []Provider `json:"packages,omitempty"`
+}
+
+// Provides returns the packages that install a program with the given name,
+// according to the programs in nixpkgs package metadata, so that a command
+// such as "dig" can be mapped to the packages that ship it. Packages whose
+// main program it is come first.
+func (c *client) Provides(ctx context.Context, program string) ([]Provider, error) {
+	if program == "" {
+		return nil, errors.New("program should not be empty")
+	}
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.Provides(program), nil
+	}
+
+	endpoint, err := url.JoinPath(c.host, "v1/provides")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	results, err := execGet[ProvidesResults](ctx, endpoint+"?program="+url.QueryEscape(program), c.publicKeys)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sortProviders(results.Packages), nil
+}
+
+// Provides returns the packages in the index that install program, like
+// client.Provides. A package's main program is its name, unless the index
+// says otherwise.
+func (i *PackageIndex) Provides(program string) []Provider {
+	byName := map[string]int{}
+	var providers []Provider
+	for _, resolved := range i.Packages {
+		if !slices.Contains(resolved.Programs, program) {
+			continue
+		}
+		provider := Provider{
+			Name:        resolved.Name,
+			Version:     resolved.Version,
+			Summary:     resolved.Summary,
+			MainProgram: resolved.Name == program || len(resolved.Programs) == 1,
+		}
+		j, ok := byName[resolved.Name]
+		if !ok {
+			byName[resolved.Name] = len(providers)
+			providers = append(providers, provider)
+		} else if CompareVersions(resolved.Version, providers[j].Version) > 0 {
+			providers[j] = provider
+		}
+	}
+	return sortProviders(providers)
+}
+
+func sortProviders(providers []Provider) []Provider {
+	slices.SortStableFunc(providers, func(a, b Provider) int {
+		if a.MainProgram != b.MainProgram {
+			if a.MainProgram {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return providers
+}
+
+// ProviderNames returns the names of up to maxSuggestions packages that
+// install program, for a hint when a package with the program's name doesn't
+// exist. Like Suggest, it's best effort.
+func (c *client) ProviderNames(ctx context.Context, program string) []string {
+	providers, err := c.Provides(ctx, program)
+	if err != nil {
+		debug.Log("packages that provide %s: %v", program, err)
+		return nil
+	}
+	return providerNames(providers)
+}
+
+// ProviderNames is client.ProviderNames for the index.
+func (i *PackageIndex) ProviderNames(program string) []string {
+	return providerNames(i.Provides(program))
+}
+
+func providerNames(providers []Provider) []string {
+	names := make([]string, 0, min(len(providers), maxSuggestions))
+	for _, p := range providers[:min(len(providers), maxSuggestions)] {
+		names = append(names, p.Name)
+	}
+	return names
+}
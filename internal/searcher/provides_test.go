@@ -0,0 +1,40 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPackageIndexProvides(t *testing.T) {
+	index := &PackageIndex{Packages: []ResolveResponse{
+		{Name: "dnsutils", Version: "9.18.24", Programs: []string{"dig", "host", "nslookup"}},
+		{Name: "dnsutils", Version: "9.18.28", Programs: []string{"dig", "host", "nslookup"}},
+		{Name: "bind", Version: "9.18.28", Programs: []string{"dig", "named"}},
+		{Name: "dig", Version: "1.0.0", Programs: []string{"dig"}},
+		{Name: "hello", Version: "2.12.1", Programs: []string{"hello"}},
+	}}
+
+	providers := index.Provides("dig")
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name
+	}
+	if want := []string{"dig", "bind", "dnsutils"}; !slices.Equal(names, want) {
+		t.Errorf("Provides(dig) = %v, want %v", names, want)
+	}
+	if !providers[0].MainProgram || providers[1].MainProgram {
+		t.Errorf("Provides(dig) main programs = %v, %v, want true, false", providers[0].MainProgram, providers[1].MainProgram)
+	}
+	if got := providers[2].Version; got != "9.18.28" {
+		t.Errorf("Provides(dig) dnsutils version = %s, want the newest, 9.18.28", got)
+	}
+	if got := index.ProviderNames("nslookup"); !slices.Equal(got, []string{"dnsutils"}) {
+		t.Errorf("ProviderNames(nslookup) = %v, want [dnsutils]", got)
+	}
+	if got := index.Provides("kubectl"); len(got) != 0 {
+		t.Errorf("Provides(kubectl) = %v, want none", got)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+const (
+	defaultTimeout = 15 * time.Second
+	defaultRetries = 2
+	defaultBackoff = 500 * time.Millisecond
+	maxBackoff     = 8 * time.Second
+)
+
+// retryPolicy bounds how long a request to the search service can take.
+type retryPolicy struct {
+	// timeout limits each attempt.
+	timeout time.Duration
+	// retries is how many more attempts are made after the first one
+	// fails with a network error, a timeout or a server error.
+	retries int
+	// backoff is the wait before the first retry, which doubles for each
+	// retry after that.
+	backoff time.Duration
+}
+
+// currentRetryPolicy reads the policy from DEVBOX_SEARCH_TIMEOUT,
+// DEVBOX_SEARCH_RETRIES and DEVBOX_SEARCH_BACKOFF. Values that can't be
+// parsed are ignored.
+func currentRetryPolicy() retryPolicy {
+	return retryPolicy{
+		timeout: durationEnv(envir.DevboxSearchTimeout, defaultTimeout),
+		retries: intEnv(envir.DevboxSearchRetries, defaultRetries),
+		backoff: durationEnv(envir.DevboxSearchBackoff, defaultBackoff),
+	}
+}
+
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	env := os.Getenv(name)
+	if env == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(env)
+	if err != nil || d < 0 {
+		debug.Log("searcher: ignoring invalid %s=%q", name, env)
+		return fallback
+	}
+	return d
+}
+
+func intEnv(name string, fallback int) int {
+	env := os.Getenv(name)
+	if env == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(env)
+	if err != nil || n < 0 {
+		debug.Log("searcher: ignoring invalid %s=%q", name, env)
+		return fallback
+	}
+	return n
+}
+
+// delay is how long to wait before retry number attempt, counting from 1.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// do calls attempt until it succeeds, returns an error that isn't worth
+// retrying, or runs out of retries. Each call gets a context that's limited
+// to the policy's timeout. A timeout of 0 only uses ctx's deadline.
+func (p retryPolicy) do(ctx context.Context, attempt func(ctx context.Context) error) error {
+	for i := 0; ; i++ {
+		err := p.try(ctx, attempt)
+		if err == nil || i >= p.retries || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+		wait := p.delay(i + 1)
+		debug.Log("searcher: attempt %d failed, retrying in %s: %v", i+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (p retryPolicy) try(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return attempt(ctx)
+}
+
+// statusError is an unexpected HTTP status from the search service.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// IsUnavailable reports whether err means that the search service didn't
+// answer, because of a network error, a timeout or a server error, rather than
+// that it answered with an error, such as a package that doesn't exist.
+func IsUnavailable(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled) && isRetryable(err)
+}
+
+// isRetryable reports whether a request that failed with err may succeed if
+// it's sent again. Client errors like 404 Not Found won't.
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	// Anything else failed before getting a response: a network error or
+	// the attempt's timeout. The exception is the network policy refusing
+	// the request, which is a user error.
+	_, isUserErr := usererr.Extract(err)
+	return !isUserErr
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestExecGetRetries(t *testing.T) {
+	t.Setenv(envir.DevboxSearchBackoff, "1ms")
+	t.Setenv(envir.DevboxSearchTimeout, "50ms")
+	// The same requests are sent more than once, which the cache would answer.
+	t.Setenv(envir.DevboxSearchCacheTTL, "0")
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if requests.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"name": "hello", "version": "2.12.1"}`))
+		case "/slow":
+			requests.Add(1)
+			<-r.Context().Done()
+		default:
+			requests.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// Server errors are retried.
+	pkg, err := execGet[PackageVersion](context.Background(), server.URL+"/flaky", nil)
+	require.NoError(t, err)
+	require.Equal(t, "2.12.1", pkg.Version)
+	require.EqualValues(t, 3, requests.Load())
+
+	// Not found isn't.
+	requests.Store(0)
+	_, err = execGet[PackageVersion](context.Background(), server.URL+"/missing", nil)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.EqualValues(t, 1, requests.Load())
+
+	// Each attempt times out, and then the request is retried until it runs
+	// out of retries.
+	requests.Store(0)
+	start := time.Now()
+	_, err = execGet[PackageVersion](context.Background(), server.URL+"/slow", nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualValues(t, 1+defaultRetries, requests.Load())
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	t.Setenv(envir.DevboxSearchRetries, "0")
+	requests.Store(0)
+	_, err = execGet[PackageVersion](context.Background(), server.URL+"/flaky", nil)
+	require.Error(t, err)
+	require.EqualValues(t, 1, requests.Load())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := retryPolicy{backoff: time.Second}
+	require.Equal(t, time.Second, p.delay(1))
+	require.Equal(t, 4*time.Second, p.delay(3))
+	require.Equal(t, maxBackoff, p.delay(10))
+}
+
+func TestRetryPolicyStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := retryPolicy{retries: 5, backoff: time.Hour}.do(ctx, func(context.Context) error {
+		attempts++
+		cancel()
+		return errors.New("connection refused")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// signatureHeader has the search service's signatures of a response, as a
+// comma-separated list of "<key name>:<base64 ed25519 signature>", like the
+// signatures of a Nix store path. There can be more than one so that keys
+// can be rotated.
+const signatureHeader = "X-Devbox-Signature"
+
+// signedMessage is what the search service signs for a response: the path
+// and query of the request, a newline, the hex SHA-256 of the request body
+// (which is empty for a GET), a newline, and then the response body. Signing
+// the request too stops a signed response from being replayed as the
+// response to a different request, such as a batch of other packages.
+func signedMessage(u *url.URL, reqBody, body []byte) []byte {
+	reqHash := sha256.Sum256(reqBody)
+	return append([]byte(u.RequestURI()+"\n"+hex.EncodeToString(reqHash[:])+"\n"), body...)
+}
+
+// verifySignature checks that header has a valid signature of the response to
+// u, whose request had reqBody, by one of keys. It returns a user error
+// otherwise, so that a tampered response never reaches devbox.lock.
+func verifySignature(keys []nix.PublicKey, u *url.URL, reqBody []byte, header string, body []byte) error {
+	if header == "" {
+		return usererr.New(
+			"The search service at %s didn't sign its response to %s, but signature_policy.search_public_keys "+
+				"in devbox.json requires it.", u.Host, u.Path)
+	}
+	message := signedMessage(u, reqBody, body)
+	for _, sig := range strings.Split(header, ",") {
+		name, encoded, ok := strings.Cut(strings.TrimSpace(sig), ":")
+		if !ok {
+			continue
+		}
+		sigBytes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if key.Name == name && ed25519.Verify(key.Key, message, sigBytes) {
+				return nil
+			}
+		}
+	}
+	return usererr.New(
+		"The response of the search service at %s to %s isn't signed by any of the keys in "+
+			"signature_policy.search_public_keys in devbox.json. It may have been tampered with.", u.Host, u.Path)
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestExecGetVerifiesSignatures(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []nix.PublicKey{{Name: "search.example.com-1", Key: pub}}
+
+	body := []byte(`{"name": "hello", "version": "2.12.1"}`)
+	sign := func(requestURI string, reqBody, body []byte) string {
+		u := &url.URL{Path: requestURI}
+		sig := ed25519.Sign(priv, signedMessage(u, reqBody, body))
+		return "search.example.com-1:" + base64.StdEncoding.EncodeToString(sig)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/signed":
+			w.Header().Set(signatureHeader, sign(r.URL.RequestURI(), reqBody, body))
+			w.Write(body)
+		case "/rotated":
+			w.Header().Set(signatureHeader, "old-key:AAAA, "+sign(r.URL.RequestURI(), reqBody, body))
+			w.Write(body)
+		case "/tampered":
+			w.Header().Set(signatureHeader, sign(r.URL.RequestURI(), reqBody, body))
+			w.Write([]byte(`{"name": "hello", "version": "6.6.6"}`))
+		case "/replayed":
+			w.Header().Set(signatureHeader, sign("/signed", reqBody, body))
+			w.Write(body)
+		case "/batch-replayed":
+			w.Header().Set(signatureHeader, sign(r.URL.RequestURI(), []byte(`{"packages": []}`), body))
+			w.Write(body)
+		default:
+			w.Write(body)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	for _, path := range []string{"/signed", "/rotated"} {
+		pkg, err := execGet[PackageVersion](context.Background(), server.URL+path, keys)
+		if err != nil {
+			t.Errorf("GET %s: got error: %v", path, err)
+			continue
+		}
+		if pkg.Version != "2.12.1" {
+			t.Errorf("GET %s: got version %q, want %q", path, pkg.Version, "2.12.1")
+		}
+	}
+
+	for _, path := range []string{"/tampered", "/replayed", "/unsigned"} {
+		_, err := execGet[PackageVersion](context.Background(), server.URL+path, keys)
+		if _, ok := usererr.Extract(err); !ok {
+			t.Errorf("GET %s: got error %v, want a user error", path, err)
+		}
+	}
+
+	// The signature of a POST covers the request body, so a response to
+	// another batch of packages is rejected.
+	reqBody := []byte(`{"packages": [{"name": "hello", "version": "2.12.1"}]}`)
+	if _, err := execPost[PackageVersion](context.Background(), server.URL+"/signed", reqBody, keys); err != nil {
+		t.Errorf("POST /signed: got error: %v", err)
+	}
+	if _, err := execPost[PackageVersion](context.Background(), server.URL+"/batch-replayed", reqBody, keys); err == nil {
+		t.Error("POST /batch-replayed: got nil error")
+	}
+
+	// A response that's signed by a key with the pinned name, but not the
+	// pinned key, is rejected.
+	wrongKey := []nix.PublicKey{{Name: "search.example.com-1", Key: otherPub}}
+	if _, err := execGet[PackageVersion](context.Background(), server.URL+"/signed", wrongKey); err == nil {
+		t.Error("GET /signed with the wrong key: got nil error")
+	}
+
+	// Without keys, responses aren't checked.
+	if _, err := execGet[PackageVersion](context.Background(), server.URL+"/unsigned", nil); err != nil {
+		t.Errorf("GET /unsigned without keys: got error: %v", err)
+	}
+}
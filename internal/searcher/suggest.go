@@ -0,0 +1,107 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// maxSuggestions is how many names Suggest returns at most.
+const maxSuggestions = 3
+
+// Suggest returns up to 3 package names that are close to name, closest
+// first, for a "did you mean" hint when name doesn't resolve. If name is a
+// package that the search service knows, it's the only suggestion, which
+// means that only its version wasn't found. Suggestions are best effort, so
+// a failed search returns no suggestions.
+func (c *client) Suggest(ctx context.Context, name string) []string {
+	return suggest(name, func(query string) (*SearchResults, error) {
+		return c.Search(ctx, query)
+	})
+}
+
+// Suggest returns the package names in the index that are close to name,
+// like client.Suggest.
+func (i *PackageIndex) Suggest(name string) []string {
+	return suggest(name, func(query string) (*SearchResults, error) {
+		return i.Search(query), nil
+	})
+}
+
+func suggest(name string, search func(query string) (*SearchResults, error)) []string {
+	if name == "" {
+		return nil
+	}
+	// A typo usually doesn't change the start of the name, and a search
+	// for the start finds names that a search for the typo doesn't.
+	queries := []string{name}
+	if len(name) > 3 {
+		if prefix := name[:max(3, len(name)/2)]; prefix != name {
+			queries = append(queries, prefix)
+		}
+	}
+
+	maxDistance := max(1, len(name)/3)
+	distances := map[string]int{}
+	for _, query := range queries {
+		results, err := search(query)
+		if err != nil {
+			debug.Log("suggestions for %s: %v", name, err)
+			return nil
+		}
+		for _, pkg := range results.Packages {
+			if pkg.Name == name {
+				return []string{name}
+			}
+			if d := editDistance(name, pkg.Name); d <= maxDistance {
+				distances[pkg.Name] = d
+			}
+		}
+		if len(distances) > 0 {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(distances))
+	for n := range distances {
+		names = append(names, n)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(distances[a], distances[b]), strings.Compare(a, b))
+	})
+	return names[:min(len(names), maxSuggestions)]
+}
+
+// editDistance is the number of single character insertions, deletions,
+// substitutions and swaps of adjacent characters that turn a into b, so that
+// "ripgerp" is 1 away from "ripgrep".
+func editDistance(a, b string) int {
+	// d[i][j] is the distance between a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
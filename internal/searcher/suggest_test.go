@@ -0,0 +1,62 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"ripgrep", "ripgrep", 0},
+		{"ripgerp", "ripgrep", 1},
+		{"ripgre", "ripgrep", 1},
+		{"rpigrep", "ripgrep", 1},
+		{"ripgrap", "ripgrep", 1},
+		{"rg", "ripgrep", 5},
+		{"", "go", 2},
+	}
+	for _, testCase := range testCases {
+		if got := editDistance(testCase.a, testCase.b); got != testCase.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", testCase.a, testCase.b, got, testCase.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	err := os.WriteFile(index, []byte(`{"packages": [
+		{"name": "ripgrep", "version": "14.1.0"},
+		{"name": "ripgrep-all", "version": "0.10.6"},
+		{"name": "ripsecrets", "version": "0.1.7"},
+		{"name": "jq", "version": "1.7.1"}
+	]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envir.DevboxPackageIndex, index)
+
+	testCases := map[string][]string{
+		"ripgerp": {"ripgrep"},
+		"ripgrep": {"ripgrep"},
+		"nodejs":  nil,
+		"jq":      {"jq"},
+		"jw":      nil,
+		"j":       {"jq"},
+	}
+	for name, want := range testCases {
+		if got := Client().Suggest(context.Background(), name); !slices.Equal(got, want) {
+			t.Errorf("Suggest(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// ResolvableVersion is a version of a package that the search service can
+// resolve, and so that devbox can lock.
+type ResolvableVersion struct {
+	Version string `json:"version"`
+	// Systems are the systems that the version can be installed on, sorted.
+	Systems []string `json:"systems"`
+	// LastUpdated is the most recent change to the version on any system.
+	LastUpdated time.Time `json:"last_updated"`
+	License     []string  `json:"license,omitempty"`
+	// Vulnerabilities are the known vulnerabilities in the version.
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Versions lists every version of the package with the given name that the
+// search service can resolve, newest first. It returns ErrNotFound if the
+// service doesn't know the package.
+func (c *client) Versions(ctx context.Context, name string) ([]ResolvableVersion, error) {
+	if path := PackageIndexPath(); path != "" {
+		index, err := LoadPackageIndex(path)
+		if err != nil {
+			return nil, err
+		}
+		return index.Versions(name)
+	}
+
+	results, err := c.Search(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []ResolvableVersion
+	for _, pkg := range results.Packages {
+		if pkg.Name != name {
+			continue
+		}
+		for _, v := range pkg.Versions {
+			if v.Version == "" {
+				continue
+			}
+			version := ResolvableVersion{
+				Version:         v.Version,
+				Systems:         lo.Keys(v.Systems),
+				License:         v.License,
+				Vulnerabilities: v.Vulnerabilities,
+			}
+			for _, info := range v.Systems {
+				if updated := time.Unix(int64(info.LastUpdated), 0); info.LastUpdated > 0 && updated.After(version.LastUpdated) {
+					version.LastUpdated = updated.UTC()
+				}
+			}
+			versions = append(versions, version)
+		}
+	}
+	return sortVersions(versions)
+}
+
+// Versions lists the versions of the package with the given name in the
+// index, like client.Versions.
+func (i *PackageIndex) Versions(name string) ([]ResolvableVersion, error) {
+	var versions []ResolvableVersion
+	for _, pkg := range i.Packages {
+		if pkg.Name != name {
+			continue
+		}
+		version := ResolvableVersion{
+			Version:         pkg.Version,
+			Systems:         lo.Keys(pkg.Systems),
+			License:         pkg.License,
+			Vulnerabilities: pkg.Vulnerabilities,
+		}
+		for _, sys := range pkg.Systems {
+			if sys.LastUpdated.After(version.LastUpdated) {
+				version.LastUpdated = sys.LastUpdated.UTC()
+			}
+		}
+		versions = append(versions, version)
+	}
+	return sortVersions(versions)
+}
+
+func sortVersions(versions []ResolvableVersion) ([]ResolvableVersion, error) {
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	for _, v := range versions {
+		slices.Sort(v.Systems)
+	}
+	slices.SortStableFunc(versions, func(a, b ResolvableVersion) int {
+		return CompareVersions(b.Version, a.Version)
+	})
+	return versions, nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
 	"go.jetpack.io/devbox/internal/cuecfg"
 	"go.jetpack.io/devbox/internal/xdg"
 )
@@ -161,7 +162,11 @@ func StartProcessManager(
 }
 
 func runProcessManagerInForeground(cmd *exec.Cmd, config *globalProcessComposeConfig, port int, projectDir string, w io.Writer) error {
-	if err := cmd.Start(); err != nil {
+	// process-compose's terminal UI needs the terminal, and Ctrl-C should
+	// stop the services that it started too.
+	cmd.Stdin = os.Stdin
+	group, err := cmdutil.StartProcessGroup(cmd)
+	if err != nil {
 		return fmt.Errorf("failed to start process-compose: %w", err)
 	}
 
@@ -172,7 +177,7 @@ func runProcessManagerInForeground(cmd *exec.Cmd, config *globalProcessComposeCo
 
 	config.Instances[projectDir] = projectConfig
 
-	err := writeGlobalProcessComposeJSON(config, config.File)
+	err = writeGlobalProcessComposeJSON(config, config.File)
 	if err != nil {
 		return err
 	}
@@ -180,7 +185,7 @@ func runProcessManagerInForeground(cmd *exec.Cmd, config *globalProcessComposeCo
 	// We're waiting now, so we can unlock the file
 	config.File.Close()
 
-	err = cmd.Wait()
+	err = group.Wait()
 	if err != nil {
 		if err.Error() == "exit status 1" {
 			fmt.Fprintf(w, "Process-compose was terminated remotely, %s\n", err.Error())
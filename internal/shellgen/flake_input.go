@@ -20,6 +20,11 @@ type flakeInput struct {
 	Name     string
 	Packages []*devpkg.Package
 	URL      string
+
+	// CADerivations is set when the project has ca_derivations, which
+	// builds the input's packages that aren't in a binary cache as
+	// content-addressed derivations with CAOverrideName.
+	CADerivations bool
 }
 
 // IsNixpkgs returns true if the input is a nixpkgs flake of the form:
@@ -51,6 +56,28 @@ func (f *flakeInput) PkgImportName() string {
 	return f.Name + "-pkgs"
 }
 
+// CAOverrideName is the name of the function that makes a package from the
+// nixpkgs import a content-addressed derivation. Only the package itself is
+// content-addressed: its dependencies keep their input-addressed store paths,
+// so that they're still downloaded from binary caches.
+func (f *flakeInput) CAOverrideName() string {
+	return f.Name + "-content-addressed"
+}
+
+// pkgExprFor returns the expression for pkg, whose attribute path in the
+// nixpkgs import is attr.
+func (f *flakeInput) pkgExprFor(pkg *devpkg.Package, attr string) (string, error) {
+	expr := f.PkgImportName() + "." + attr
+	if !f.CADerivations {
+		return expr, nil
+	}
+	ca, err := pkg.BuildsContentAddressed()
+	if err != nil || !ca {
+		return expr, err
+	}
+	return "(" + f.CAOverrideName() + " " + expr + ")", nil
+}
+
 type SymlinkJoin struct {
 	Name  string
 	Paths []string
@@ -72,12 +99,14 @@ func (f *flakeInput) BuildInputsForSymlinkJoin() ([]*SymlinkJoin, error) {
 
 		// Skip packages that are already in the binary cache. These will be directly
 		// included in the buildInputs using `builtins.fetchClosure` of their store paths.
-		inCache, err := pkg.IsInBinaryCache()
-		if err != nil {
-			return nil, err
-		}
-		if inCache {
-			continue
+		if featureflag.RemoveNixpkgs.Enabled() {
+			inCache, err := pkg.IsInBinaryCache()
+			if err != nil {
+				return nil, err
+			}
+			if inCache {
+				continue
+			}
 		}
 
 		attributePath, err := pkg.FullPackageAttributePath()
@@ -93,15 +122,18 @@ func (f *flakeInput) BuildInputsForSymlinkJoin() ([]*SymlinkJoin, error) {
 		if err != nil {
 			return nil, err
 		}
+		pkgExpr := f.Name + "." + attributePath
+		if f.IsNixpkgs() {
+			parts := strings.Split(attributePath, ".")
+			if pkgExpr, err = f.pkgExprFor(pkg, strings.Join(parts[2:], ".")); err != nil {
+				return nil, err
+			}
+		}
 
 		joins = append(joins, &SymlinkJoin{
 			Name: pkg.String() + "-combined",
 			Paths: lo.Map(outputNames, func(outputName string, _ int) string {
-				if !f.IsNixpkgs() {
-					return f.Name + "." + attributePath + "." + outputName
-				}
-				parts := strings.Split(attributePath, ".")
-				return f.PkgImportName() + "." + strings.Join(parts[2:], ".") + "." + outputName
+				return pkgExpr + "." + outputName
 			}),
 		})
 	}
@@ -143,11 +175,15 @@ func (f *flakeInput) BuildInputs() ([]string, error) {
 			return f.Name + "." + pkg
 		}), nil
 	}
-	return lo.Map(attributePaths, func(pkg string, _ int) string {
-		parts := strings.Split(pkg, ".")
+	inputs := make([]string, len(attributePaths))
+	for i, attributePath := range attributePaths {
+		parts := strings.Split(attributePath, ".")
 		// Ugh, not sure if this is reliable?
-		return f.PkgImportName() + "." + strings.Join(parts[2:], ".")
-	}), nil
+		if inputs[i], err = f.pkgExprFor(packages[i], strings.Join(parts[2:], ".")); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
 }
 
 // flakeInputs returns a list of flake inputs for the top level flake.nix
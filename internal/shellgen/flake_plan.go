@@ -39,6 +39,11 @@ func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
 	}
 
 	flakeInputs := flakeInputs(ctx, packages)
+	if devbox.Config().Root.CADerivations {
+		for i := range flakeInputs {
+			flakeInputs[i].CADerivations = flakeInputs[i].IsNixpkgs()
+		}
+	}
 	nixpkgsInfo := getNixpkgsInfo(devbox.Config().NixPkgsCommitHash())
 
 	// This is an optimization. Try to reuse the nixpkgs info from the flake
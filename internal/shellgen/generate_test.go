@@ -49,6 +49,30 @@ func TestWriteFromTemplate(t *testing.T) {
 		}
 		cmpGoldenFile(t, outPath, "testdata/flake-empty.nix.golden")
 	})
+	t.Run("CADerivations", func(t *testing.T) {
+		caPlan := *testFlakeTmplPlan
+		caPlan.FlakeInputs = []flakeInput{testFlakeTmplPlan.FlakeInputs[0]}
+		caPlan.FlakeInputs[0].CADerivations = true
+		caPlan.FlakeInputs[0].Packages = caPlan.FlakeInputs[0].Packages[:2]
+		err = writeFromTemplate(dir, &caPlan, "flake.nix", "flake.nix")
+		if err != nil {
+			t.Fatal("got error writing flake template:", err)
+		}
+		cmpGoldenFile(t, outPath, "testdata/flake-ca.nix.golden")
+	})
+	t.Run("RemoveNixpkgs", func(t *testing.T) {
+		plan := &flakePlan{
+			FlakeInputs: testFlakeTmplPlan.FlakeInputs,
+			NixpkgsInfo: &NixpkgsInfo{URL: testFlakeTmplPlan.NixpkgsInfo.URL},
+			Packages:    testFlakeTmplPlan.FlakeInputs[0].Packages,
+			System:      "x86_64-linux",
+		}
+		err = writeFromTemplate(dir, plan, "flake_remove_nixpkgs.nix", "flake.nix")
+		if err != nil {
+			t.Fatal("got error writing flake template:", err)
+		}
+		cmpGoldenFile(t, outPath, "testdata/flake-remove-nixpkgs.nix.golden")
+	})
 }
 
 func cmpGoldenFile(t *testing.T, gotPath, wantGoldenPath string) {
@@ -146,3 +170,7 @@ func (*lockmock) LegacyNixpkgsPath(pkg string) string {
 func (*lockmock) ProjectDir() string {
 	return ""
 }
+
+func (*lockmock) BinaryCaches() []string {
+	return nil
+}
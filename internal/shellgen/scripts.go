@@ -14,6 +14,7 @@ import (
 	"go.jetpack.io/devbox/internal/boxcli/featureflag"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/plugin"
@@ -34,6 +35,10 @@ const scriptsDir = ".devbox/gen/scripts"
 const (
 	HooksFilename    = ".hooks"
 	rawHooksFilename = ".raw-hooks"
+
+	// The pre_init and pre_script hooks of the project and its plugins.
+	preInitHooksFilename   = ".pre-init-hooks"
+	preScriptHooksFilename = ".pre-script-hooks"
 )
 
 type devboxer interface {
@@ -69,6 +74,18 @@ func WriteScriptsToFiles(devbox devboxer) error {
 	}
 	written[rawHooksFilename] = struct{}{}
 
+	// Always write the phase hooks too, since the wrappers run them.
+	for phase, name := range map[configfile.HookPhase]string{
+		configfile.HookPreInit:   preInitHooksFilename,
+		configfile.HookPreScript: preScriptHooksFilename,
+	} {
+		err = WriteScriptFile(devbox, name, HookPhaseScript(phase, devbox.Config().Hooks(phase)))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		written[name] = struct{}{}
+	}
+
 	err = writeInitHookWrapperFile(devbox)
 	if err != nil {
 		return errors.WithStack(err)
@@ -121,8 +138,9 @@ func writeInitHookWrapperFile(devbox devboxer) (err error) {
 	defer script.Close() // best effort: close file
 
 	return initHookWrapperTmpl.Execute(script, map[string]string{
-		"InitHookHash": "__DEVBOX_INIT_HOOK_" + devbox.ProjectDirHash(),
-		"RawHooksFile": ScriptPath(devbox.ProjectDir(), rawHooksFilename),
+		"InitHookHash":     "__DEVBOX_INIT_HOOK_" + devbox.ProjectDirHash(),
+		"PreInitHooksFile": ScriptPath(devbox.ProjectDir(), preInitHooksFilename),
+		"RawHooksFile":     ScriptPath(devbox.ProjectDir(), rawHooksFilename),
 	})
 }
 
@@ -167,12 +185,41 @@ func ScriptPath(projectDir, scriptName string) string {
 func ScriptBody(d devboxer, body string) (string, error) {
 	var buf bytes.Buffer
 	err := scriptWrapperTmpl.Execute(&buf, map[string]string{
-		"Body":         body,
-		"InitHookHash": "__DEVBOX_INIT_HOOK_" + d.ProjectDirHash(),
-		"InitHookPath": ScriptPath(d.ProjectDir(), HooksFilename),
+		"Body":               body,
+		"InitHookHash":       "__DEVBOX_INIT_HOOK_" + d.ProjectDirHash(),
+		"InitHookPath":       ScriptPath(d.ProjectDir(), HooksFilename),
+		"PreScriptHooksPath": ScriptPath(d.ProjectDir(), preScriptHooksFilename),
 	})
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
 	return buf.String(), nil
 }
+
+// HookPhaseScript returns a POSIX shell script that runs the hooks of a phase
+// in order. Each hook runs in its own sh -e, so that it stops at its first
+// failing command, and then its failure policy decides whether the rest of
+// the phase runs.
+func HookPhaseScript(phase configfile.HookPhase, hooks []devconfig.PhaseHook) string {
+	var b strings.Builder
+	for _, hook := range hooks {
+		run := "sh -ec " + shellQuote(hook.Commands.String())
+		fmt.Fprintf(&b, "# %s\n", hook.Source)
+		switch hook.OnFailure {
+		case configfile.HookFailureIgnore:
+			fmt.Fprintf(&b, "%s || true\n", run)
+		case configfile.HookFailureWarn:
+			fmt.Fprintf(&b, "%s || echo %s >&2\n", run,
+				shellQuote(fmt.Sprintf("Warning: the %s hook of %s failed.", phase, hook.Source)))
+		default:
+			fmt.Fprintf(&b, "if ! %s; then\n\techo %s >&2\n\texit 1\nfi\n", run,
+				shellQuote(fmt.Sprintf("Error: the %s hook of %s failed.", phase, hook.Source)))
+		}
+	}
+	return b.String()
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
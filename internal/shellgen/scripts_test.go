@@ -0,0 +1,43 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package shellgen
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.jetpack.io/devbox/internal/devbox/shellcmd"
+	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestHookPhaseScript(t *testing.T) {
+	hook := func(source string, onFailure configfile.HookFailure, cmds ...string) devconfig.PhaseHook {
+		return devconfig.PhaseHook{Source: source, Commands: &shellcmd.Commands{Cmds: cmds}, OnFailure: onFailure}
+	}
+	run := func(hooks ...devconfig.PhaseHook) (string, error) {
+		script := HookPhaseScript(configfile.HookPreScript, hooks)
+		out, err := exec.Command("sh", "-c", script).CombinedOutput()
+		return string(out), err
+	}
+
+	// Each hook stops at its first failing command, and the policy decides
+	// whether the next one runs.
+	out, err := run(
+		hook("plugin a", configfile.HookFailureIgnore, "echo a1", "false", "echo a2"),
+		hook("plugin b", configfile.HookFailureWarn, "echo 'b1 it''s'", "exit 3"),
+		hook("devbox.json", configfile.HookFailureFail, "echo c1"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "a1\nb1 its\nWarning: the pre_script hook of plugin b failed.\nc1\n", out)
+
+	out, err = run(
+		hook("plugin a", configfile.HookFailureFail, "false"),
+		hook("devbox.json", configfile.HookFailureFail, "echo never"),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "Error: the pre_script hook of plugin a failed.\n", out)
+}
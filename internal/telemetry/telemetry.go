@@ -29,6 +29,7 @@ import (
 
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/xdg"
 )
@@ -59,6 +60,9 @@ func Start() {
 	if started || envir.DoNotTrack() || build.SentryDSN == "" || build.TelemetryKey == "" {
 		return
 	}
+	if !netpolicy.Allowed(netpolicy.Telemetry) {
+		return
+	}
 
 	const deviceSalt = "64ee464f-9450-4b14-8d9c-014c0012ac1a"
 	deviceID, _ = machineid.ProtectedID(deviceSalt)
@@ -277,6 +281,9 @@ var (
 )
 
 func Upload() {
+	if !netpolicy.Allowed(netpolicy.Telemetry) {
+		return
+	}
 	wg := sync.WaitGroup{} //nolint:varnamelen
 	wg.Add(2)
 	go func() {
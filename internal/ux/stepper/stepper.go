@@ -47,6 +47,12 @@ func (s *Stepper) Success(format string, a ...any) {
 	s.spinner.Stop()
 }
 
+// Clear stops the spinner and erases it without printing a message.
+func (s *Stepper) Clear() {
+	s.spinner.FinalMSG = ""
+	s.spinner.Stop()
+}
+
 func (s *Stepper) Display(format string, a ...any) {
 	msg := fmt.Sprintf(format, a...)
 	// we need to add a space prefix to give a small gap between the spinner animation and the msg
@@ -65,7 +65,9 @@ func CheckVersion(w io.Writer, commandPath string) {
 		return
 	}
 
-	if envir.IsDevboxCloud() {
+	// Dev container images pin the devbox version they're built with, so a
+	// notice would show in every container until the image is rebuilt.
+	if envir.IsDevboxCloud() || envir.IsDevcontainer() {
 		return
 	}
 
@@ -0,0 +1,223 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package vm manages the Colima VM that runs a project's containers. Colima
+// creates Lima VMs with a container runtime, which replaces Docker Desktop
+// on macOS.
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// Colima is a project's Colima VM.
+type Colima struct {
+	// Profile is the Colima profile, which is the VM's name.
+	Profile    string
+	Config     *configfile.VM
+	ProjectDir string
+	// Env is the environment colima runs in. Its PATH must have colima.
+	Env map[string]string
+}
+
+var profileChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// ProfileName returns the Colima profile of a project. It has the directory
+// name to be recognizable in `colima list`, and a hash of the path to be
+// unique.
+func ProfileName(projectDir, projectDirHash string) string {
+	name := strings.Trim(profileChars.ReplaceAllString(filepath.Base(projectDir), "-"), "-")
+	if len(projectDirHash) > 8 {
+		projectDirHash = projectDirHash[:8]
+	}
+	return strings.ToLower(fmt.Sprintf("devbox-%s-%s", name, projectDirHash))
+}
+
+// DockerHost returns the DOCKER_HOST of a profile's Docker socket.
+func DockerHost(profile string) string {
+	return "unix://" + filepath.Join(colimaHome(), profile, "docker.sock")
+}
+
+func colimaHome() string {
+	if home := os.Getenv("COLIMA_HOME"); home != "" {
+		return home
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".colima")
+}
+
+// StartArgs returns the arguments of `colima start` for the VM's config.
+// The project directory is always mounted, so that bind mounts of project
+// files work.
+func (c *Colima) StartArgs() []string {
+	args := []string{"start", "--profile", c.Profile}
+	cfg := c.Config
+	if cfg == nil {
+		cfg = &configfile.VM{}
+	}
+	if cfg.CPUs > 0 {
+		args = append(args, "--cpu", strconv.Itoa(cfg.CPUs))
+	}
+	if cfg.Memory > 0 {
+		args = append(args, "--memory", strconv.Itoa(cfg.Memory))
+	}
+	if cfg.Disk > 0 {
+		args = append(args, "--disk", strconv.Itoa(cfg.Disk))
+	}
+	if cfg.Runtime != "" {
+		args = append(args, "--runtime", cfg.Runtime)
+	}
+	if cfg.VMType != "" {
+		args = append(args, "--vm-type", cfg.VMType)
+	}
+	if cfg.Arch != "" {
+		args = append(args, "--arch", cfg.Arch)
+	}
+	args = append(args, "--mount", c.ProjectDir+":w")
+	for _, mount := range cfg.Mounts {
+		args = append(args, "--mount", mount)
+	}
+	return args
+}
+
+// Start creates the VM if it doesn't exist and starts it. Colima only
+// applies the CPU, memory and mounts when the VM starts, so a running VM
+// must be stopped to change them. If foreground is true, Start returns when
+// the VM stops, which is how a process manager runs it.
+func (c *Colima) Start(ctx context.Context, foreground bool, stdout, stderr io.Writer) error {
+	args := c.StartArgs()
+	if foreground {
+		args = append(args, "--foreground")
+	}
+	return c.run(ctx, stdout, stderr, args...)
+}
+
+// Stop stops the VM, keeping its disk.
+func (c *Colima) Stop(ctx context.Context, stdout, stderr io.Writer) error {
+	return c.run(ctx, stdout, stderr, "stop", "--profile", c.Profile)
+}
+
+// Delete deletes the VM and its disk, including container images.
+func (c *Colima) Delete(ctx context.Context, stdout, stderr io.Writer) error {
+	return c.run(ctx, stdout, stderr, "delete", "--profile", c.Profile, "--force")
+}
+
+// SSH runs a command in the VM, or a shell if there's none.
+func (c *Colima) SSH(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+	args := []string{"ssh", "--profile", c.Profile}
+	if len(command) > 0 {
+		args = append(append(args, "--"), command...)
+	}
+	cmd, err := c.command(ctx, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	return cmd.Run()
+}
+
+// Status is the state of the VM, as reported by `colima status --json`.
+type Status struct {
+	Profile string `json:"profile"`
+	Running bool   `json:"running"`
+	Arch    string `json:"arch,omitempty"`
+	Runtime string `json:"runtime,omitempty"`
+	CPUs    int    `json:"cpus,omitempty"`
+	// Memory and Disk are in bytes.
+	Memory     int64  `json:"memory,omitempty"`
+	Disk       int64  `json:"disk,omitempty"`
+	DockerHost string `json:"docker_host"`
+}
+
+// Status returns the VM's status. A VM that doesn't exist or isn't running
+// isn't an error.
+func (c *Colima) Status(ctx context.Context) (*Status, error) {
+	status := &Status{Profile: c.Profile, DockerHost: DockerHost(c.Profile)}
+	cmd, err := c.command(ctx, "status", "--profile", c.Profile, "--json")
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// colima status exits with an error when the VM isn't running.
+		debug.Log("colima status: %v: %s", err, exitErr.Stderr)
+		return status, nil
+	}
+	if err != nil {
+		return nil, redact.Errorf("colima status: %w", err)
+	}
+	return status, parseStatus(out, status)
+}
+
+func parseStatus(data []byte, status *Status) error {
+	var out struct {
+		Arch         string `json:"arch"`
+		Runtime      string `json:"runtime"`
+		CPU          int    `json:"cpu"`
+		Memory       int64  `json:"memory"`
+		Disk         int64  `json:"disk"`
+		DockerSocket string `json:"docker_socket"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return redact.Errorf("parse colima status: %w", err)
+	}
+	status.Running = true
+	status.Arch, status.Runtime = out.Arch, out.Runtime
+	status.CPUs, status.Memory, status.Disk = out.CPU, out.Memory, out.Disk
+	if out.DockerSocket != "" {
+		status.DockerHost = out.DockerSocket
+	}
+	return nil
+}
+
+func (c *Colima) run(ctx context.Context, stdout, stderr io.Writer, args ...string) error {
+	cmd, err := c.command(ctx, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return redact.Errorf("colima %s: %w", redact.Safe(args[0]), err)
+	}
+	return nil
+}
+
+func (c *Colima) command(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	path := lookPath("colima", c.Env["PATH"])
+	if path == "" {
+		return nil, usererr.New("colima isn't installed in the project. Run `devbox add colima` to install it.")
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = envir.MapToPairs(c.Env)
+	cmd.Dir = c.ProjectDir
+	debug.Log("Running cmd %s", cmd)
+	return cmd, nil
+}
+
+// lookPath finds an executable in a PATH other than the process's.
+func lookPath(name, path string) string {
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return candidate
+		}
+	}
+	return ""
+}
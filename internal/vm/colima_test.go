@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestProfileName(t *testing.T) {
+	require.Equal(t, "devbox-my-app-0123abcd", ProfileName("/home/user/My App", "0123abcdef456789"))
+	require.Equal(t, "devbox-api-ab", ProfileName("/src/api", "ab"))
+}
+
+func TestDockerHost(t *testing.T) {
+	t.Setenv("COLIMA_HOME", "/tmp/colima")
+	require.Equal(t, "unix:///tmp/colima/devbox-api-ab/docker.sock", DockerHost("devbox-api-ab"))
+}
+
+func TestStartArgs(t *testing.T) {
+	c := &Colima{
+		Profile:    "devbox-api-ab",
+		ProjectDir: "/src/api",
+		Config: &configfile.VM{
+			CPUs:    4,
+			Memory:  8,
+			Mounts:  []string{"~/data:w"},
+			Runtime: "containerd",
+		},
+	}
+	require.Equal(t, []string{
+		"start", "--profile", "devbox-api-ab",
+		"--cpu", "4",
+		"--memory", "8",
+		"--runtime", "containerd",
+		"--mount", "/src/api:w",
+		"--mount", "~/data:w",
+	}, c.StartArgs())
+
+	c.Config = nil
+	require.Equal(t, []string{
+		"start", "--profile", "devbox-api-ab", "--mount", "/src/api:w",
+	}, c.StartArgs())
+}
+
+func TestParseStatus(t *testing.T) {
+	status := &Status{Profile: "devbox-api-ab", DockerHost: "unix:///default.sock"}
+	err := parseStatus([]byte(`{
+		"display_name": "colima [profile=devbox-api-ab]",
+		"driver": "QEMU",
+		"arch": "aarch64",
+		"runtime": "docker",
+		"docker_socket": "unix:///Users/me/.colima/devbox-api-ab/docker.sock",
+		"cpu": 4,
+		"memory": 8589934592,
+		"disk": 64424509440
+	}`), status)
+	require.NoError(t, err)
+	require.Equal(t, &Status{
+		Profile:    "devbox-api-ab",
+		Running:    true,
+		Arch:       "aarch64",
+		Runtime:    "docker",
+		CPUs:       4,
+		Memory:     8 << 30,
+		Disk:       60 << 30,
+		DockerHost: "unix:///Users/me/.colima/devbox-api-ab/docker.sock",
+	}, status)
+
+	require.Error(t, parseStatus([]byte("not json"), &Status{}))
+}
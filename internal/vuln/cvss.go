@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the metric values from the CVSS v3.1 specification.
+// Privileges required has different weights when the scope changes, which
+// scoreCVSS3 handles separately.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// scoreCVSS3 computes the base score of a CVSS v3.0 or v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". It returns false if the
+// vector is invalid.
+//
+// See https://www.first.org/cvss/v3.1/specification-document#7-4-Metric-Values
+func scoreCVSS3(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, false
+	}
+	metrics := map[string]string{}
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return 0, false
+		}
+		metrics[key] = value
+	}
+
+	weights := map[string]float64{}
+	for metric, values := range cvss3Weights {
+		w, ok := values[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		weights[metric] = w
+	}
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, false
+	}
+	if changed {
+		switch metrics["PR"] {
+		case "L":
+			weights["PR"] = 0.68
+		case "H":
+			weights["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-weights["C"])*(1-weights["I"])*(1-weights["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * weights["AV"] * weights["AC"] * weights["PR"] * weights["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp rounds up to one decimal place the way the CVSS v3.1 specification
+// does, avoiding floating point errors such as 4.000000001 becoming 4.1.
+func roundUp(x float64) float64 {
+	i := math.Round(x * 100000)
+	if math.Mod(i, 10000) == 0 {
+		return i / 100000
+	}
+	return (math.Floor(i/10000) + 1) / 10
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+const nvdAPI = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+// NVD allows 5 requests in a rolling 30 second window, or 50 with an API
+// key.
+const (
+	nvdInterval        = 6 * time.Second
+	nvdIntervalWithKey = 600 * time.Millisecond
+	nvdMaxRetries      = 3
+)
+
+// nvdProducts maps nixpkgs package names to CPE product names where they
+// differ.
+var nvdProducts = map[string]string{
+	"nodejs":      "node.js",
+	"nodejs-slim": "node.js",
+	"openjdk":     "jdk",
+	"python":      "python",
+	"python3":     "python",
+}
+
+var (
+	// nixVersionSuffix matches the version suffix of attribute names such as
+	// nodejs_20 or postgresql_16.
+	nixVersionSuffix = regexp.MustCompile(`(_\d+)+$`)
+	pythonInterp     = regexp.MustCompile(`^python\d+$`)
+)
+
+// nvd queries the National Vulnerability Database by matching package names
+// and versions against CPE names, the way vulnix does for nixpkgs.
+//
+// See https://nvd.nist.gov/developers/vulnerabilities
+type nvd struct {
+	endpoint string
+	apiKey   string
+	interval time.Duration
+}
+
+func newNVD() nvd {
+	n := nvd{endpoint: nvdAPI, apiKey: os.Getenv(envir.NVDAPIKey), interval: nvdInterval}
+	if n.apiKey != "" {
+		n.interval = nvdIntervalWithKey
+	}
+	return n
+}
+
+func (nvd) Name() string { return SourceNVD }
+
+// NVDRateLimited returns true if queries to NVD are slowed down because
+// NVD_API_KEY isn't set.
+func NVDRateLimited() bool {
+	return os.Getenv(envir.NVDAPIKey) == ""
+}
+
+// nvdProduct returns the CPE product name to look up for a package.
+func nvdProduct(p Package) (string, bool) {
+	// Language packages are looked up in OSV, which knows their ecosystem.
+	if _, ok := osvQueryPackage(p); ok || strings.Contains(p.Name, ".") {
+		return "", false
+	}
+	name := strings.ToLower(nixVersionSuffix.ReplaceAllString(p.Name, ""))
+	if product, ok := nvdProducts[name]; ok {
+		return product, true
+	}
+	if pythonInterp.MatchString(name) {
+		return "python", true
+	}
+	return name, name != ""
+}
+
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		CVE nvdCVE `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdCVE struct {
+	ID           string `json:"id"`
+	Descriptions []struct {
+		Lang  string `json:"lang"`
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Metrics struct {
+		V31 []nvdMetric `json:"cvssMetricV31"`
+		V30 []nvdMetric `json:"cvssMetricV30"`
+		V2  []nvdMetric `json:"cvssMetricV2"`
+	} `json:"metrics"`
+	Configurations []struct {
+		Nodes []struct {
+			CPEMatch []struct {
+				Vulnerable          bool   `json:"vulnerable"`
+				Criteria            string `json:"criteria"`
+				VersionEndExcluding string `json:"versionEndExcluding"`
+			} `json:"cpeMatch"`
+		} `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvdMetric struct {
+	CVSSData struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+func (n nvd) Query(ctx context.Context, pkgs []Package) (map[int][]Vulnerability, error) {
+	defer debug.FunctionTimer().End()
+
+	results := map[int][]Vulnerability{}
+	var last time.Time
+	for i, p := range pkgs {
+		product, ok := nvdProduct(p)
+		if !ok || p.Version == "" {
+			continue
+		}
+
+		var resp nvdResponse
+		for attempt := 0; ; attempt++ {
+			if wait := n.interval - time.Since(last); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+			last = time.Now()
+			err := n.get(ctx, product, p.Version, &resp)
+			if httpErr := (&httpError{}); errors.As(err, &httpErr) && attempt < nvdMaxRetries &&
+				(httpErr.status == http.StatusForbidden || httpErr.status == http.StatusTooManyRequests) {
+				debug.Log("nvd: rate limited, retrying %s", product)
+				last = time.Now().Add(30 * time.Second)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		for _, v := range resp.Vulnerabilities {
+			results[i] = append(results[i], v.CVE.vulnerability(product))
+		}
+	}
+	return results, nil
+}
+
+func (n nvd) get(ctx context.Context, product, version string, result *nvdResponse) error {
+	// A wildcard vendor matches products regardless of who publishes them.
+	// The version is escaped because CPE names use ":" as a separator.
+	cpe := "cpe:2.3:a:*:" + cpeEscape(product) + ":" + cpeEscape(version)
+	endpoint := n.endpoint + "?noRejected&virtualMatchString=" + url.QueryEscape(cpe)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return redact.Errorf("create NVD request: %w", err)
+	}
+	if n.apiKey != "" {
+		req.Header.Set("apiKey", n.apiKey)
+	}
+	return doJSON(req, result)
+}
+
+func (c *nvdCVE) vulnerability(product string) Vulnerability {
+	v := Vulnerability{
+		ID:     c.ID,
+		URL:    "https://nvd.nist.gov/vuln/detail/" + c.ID,
+		Source: SourceNVD,
+	}
+	for _, d := range c.Descriptions {
+		if d.Lang == "en" {
+			v.Summary, _, _ = strings.Cut(d.Value, "\n")
+			break
+		}
+	}
+	for _, metrics := range [][]nvdMetric{c.Metrics.V31, c.Metrics.V30, c.Metrics.V2} {
+		if len(metrics) > 0 {
+			v.Score = metrics[0].CVSSData.BaseScore
+			v.Severity = SeverityFromScore(v.Score)
+			break
+		}
+	}
+	for _, config := range c.Configurations {
+		for _, node := range config.Nodes {
+			for _, m := range node.CPEMatch {
+				parts := strings.Split(m.Criteria, ":")
+				if m.Vulnerable && m.VersionEndExcluding != "" && len(parts) > 4 && parts[4] == cpeEscape(product) {
+					v.FixedIn = append(v.FixedIn, m.VersionEndExcluding)
+				}
+			}
+		}
+	}
+	slices.Sort(v.FixedIn)
+	v.FixedIn = slices.Compact(v.FixedIn)
+	return v
+}
+
+// cpeEscape escapes the characters that have a special meaning in CPE 2.3
+// formatted strings.
+func cpeEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,277 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/httpclient"
+	"go.jetpack.io/devbox/internal/netpolicy"
+	"go.jetpack.io/devbox/internal/redact"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// osvBatchSize is the most queries the OSV API accepts in one batch.
+	osvBatchSize = 1000
+)
+
+// osvEcosystems map nixpkgs attribute paths and store path names of
+// language packages to their OSV ecosystem. OSV has no nixpkgs ecosystem, so
+// other packages are left to the other sources.
+var osvEcosystems = []struct {
+	pattern   *regexp.Regexp
+	ecosystem string
+}{
+	{regexp.MustCompile(`^python\d*Packages\.(.+)$`), "PyPI"},
+	{regexp.MustCompile(`^python\d+(?:\.\d+)?-(.+)$`), "PyPI"},
+	{regexp.MustCompile(`^nodePackages(?:_latest)?\.(.+)$`), "npm"},
+	{regexp.MustCompile(`^rubyPackages(?:_\d+_\d+)?\.(.+)$`), "RubyGems"},
+	{regexp.MustCompile(`^haskellPackages\.(.+)$`), "Hackage"},
+	{regexp.MustCompile(`^rPackages\.(.+)$`), "CRAN"},
+}
+
+// osv queries the Open Source Vulnerabilities database.
+//
+// See https://google.github.io/osv.dev/api/
+type osv struct {
+	host string
+}
+
+func newOSV() osv {
+	return osv{host: netpolicy.URL(netpolicy.Vulnerabilities)}
+}
+
+func (osv) Name() string { return SourceOSV }
+
+type osvPackage struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+type osvVuln struct {
+	ID       string   `json:"id"`
+	Summary  string   `json:"summary"`
+	Details  string   `json:"details"`
+	Aliases  []string `json:"aliases"`
+	Severity []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected []struct {
+		Package osvPackage `json:"package"`
+		Ranges  []struct {
+			Events []struct {
+				Fixed string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// osvQueryPackage returns the OSV package to look up for a package.
+func osvQueryPackage(p Package) (osvPackage, bool) {
+	for _, e := range osvEcosystems {
+		if m := e.pattern.FindStringSubmatch(p.Name); m != nil {
+			name := m[1]
+			if e.ecosystem == "PyPI" {
+				name = strings.ToLower(name)
+			}
+			return osvPackage{Name: name, Ecosystem: e.ecosystem}, true
+		}
+	}
+	return osvPackage{}, false
+}
+
+func (o osv) Query(ctx context.Context, pkgs []Package) (map[int][]Vulnerability, error) {
+	defer debug.FunctionTimer().End()
+
+	type query struct {
+		Package osvPackage `json:"package"`
+		Version string     `json:"version"`
+	}
+	var queries []query
+	var indexes []int
+	for i, p := range pkgs {
+		if op, ok := osvQueryPackage(p); ok && p.Version != "" {
+			queries = append(queries, query{Package: op, Version: p.Version})
+			indexes = append(indexes, i)
+		}
+	}
+
+	ids := map[int][]string{}
+	for start := 0; start < len(queries); start += osvBatchSize {
+		end := min(start+osvBatchSize, len(queries))
+		var resp struct {
+			Results []struct {
+				Vulns []struct {
+					ID string `json:"id"`
+				} `json:"vulns"`
+			} `json:"results"`
+		}
+		body := map[string]any{"queries": queries[start:end]}
+		if err := o.do(ctx, http.MethodPost, "v1/querybatch", body, &resp); err != nil {
+			return nil, err
+		}
+		for j, result := range resp.Results {
+			for _, v := range result.Vulns {
+				ids[indexes[start+j]] = append(ids[indexes[start+j]], v.ID)
+			}
+		}
+	}
+
+	// The batch API only returns IDs, so look up the details of each
+	// vulnerability, once per ID.
+	details := map[string]*osvVuln{}
+	var mu sync.Mutex
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(8)
+	for _, list := range ids {
+		for _, id := range list {
+			mu.Lock()
+			_, seen := details[id]
+			details[id] = nil
+			mu.Unlock()
+			if seen {
+				continue
+			}
+			group.Go(func() error {
+				v := &osvVuln{}
+				if err := o.do(ctx, http.MethodGet, "v1/vulns/"+url.PathEscape(id), nil, v); err != nil {
+					return err
+				}
+				mu.Lock()
+				details[id] = v
+				mu.Unlock()
+				return nil
+			})
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	results := map[int][]Vulnerability{}
+	for i, list := range ids {
+		op, _ := osvQueryPackage(pkgs[i])
+		for _, id := range list {
+			results[i] = append(results[i], details[id].vulnerability(op))
+		}
+	}
+	return results, nil
+}
+
+// vulnerability converts an OSV record to a Vulnerability, taking the fixed
+// versions from the ranges that apply to pkg.
+func (v *osvVuln) vulnerability(pkg osvPackage) Vulnerability {
+	result := Vulnerability{
+		ID:      v.ID,
+		Aliases: v.Aliases,
+		Summary: v.Summary,
+		URL:     "https://osv.dev/vulnerability/" + v.ID,
+		Source:  SourceOSV,
+	}
+	if result.Summary == "" {
+		result.Summary, _, _ = strings.Cut(v.Details, "\n")
+	}
+	for _, s := range v.Severity {
+		if score, ok := scoreCVSS3(s.Score); ok && s.Type == "CVSS_V3" {
+			result.Score = score
+			result.Severity = SeverityFromScore(score)
+		}
+	}
+
+	for _, affected := range v.Affected {
+		if !strings.EqualFold(affected.Package.Name, pkg.Name) ||
+			affected.Package.Ecosystem != pkg.Ecosystem {
+			continue
+		}
+		for _, r := range affected.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" {
+					result.FixedIn = append(result.FixedIn, e.Fixed)
+				}
+			}
+		}
+		if result.Severity == SeverityUnknown {
+			result.Severity, _ = ParseSeverity(affected.DatabaseSpecific.Severity)
+		}
+	}
+	if result.Severity == SeverityUnknown {
+		result.Severity, _ = ParseSeverity(v.DatabaseSpecific.Severity)
+	}
+	return result
+}
+
+func (o osv) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return redact.Errorf("encode OSV request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	endpoint, err := url.JoinPath(o.host, path)
+	if err != nil {
+		return redact.Errorf("invalid OSV host %q: %w", redact.Safe(o.host), err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return redact.Errorf("create OSV request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(req, result)
+}
+
+// doJSON sends a request and decodes the JSON response into result.
+func doJSON(req *http.Request, result any) error {
+	resp, err := httpclient.Client().Do(req)
+	if err != nil {
+		return redact.Errorf("query %s: %w", redact.Safe(req.URL.Host), err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return redact.Errorf("read response from %s: %w", redact.Safe(req.URL.Host), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &httpError{host: req.URL.Host, status: resp.StatusCode, body: bytes.TrimSpace(data)}
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return redact.Errorf("parse response from %s: %w", redact.Safe(req.URL.Host), err)
+	}
+	return nil
+}
+
+// httpError is returned for unsuccessful responses, so that callers can
+// retry when they're rate limited.
+type httpError struct {
+	host   string
+	status int
+	body   []byte
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("query %s: %s: %s", e.host, http.StatusText(e.status), e.body)
+}
+
+func (e *httpError) Redact() string {
+	return fmt.Sprintf("query %s: %s", e.host, http.StatusText(e.status))
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// search asks the Devbox search service, which knows the vulnerabilities of
+// each nixpkgs package version that it resolves. Unlike OSV, it covers
+// packages that aren't from a language ecosystem, but only the packages in
+// devbox.json, not their dependencies.
+type search struct{}
+
+func (search) Name() string { return SourceSearch }
+
+func (search) Query(ctx context.Context, pkgs []Package) (map[int][]Vulnerability, error) {
+	client := searcher.Client()
+	var indexes []int
+	var requests []searcher.ResolveRequest
+	for i, pkg := range pkgs {
+		if pkg.DependencyOf != "" || pkg.Version == "" {
+			continue
+		}
+		indexes = append(indexes, i)
+		requests = append(requests, searcher.ResolveRequest{Name: pkg.Name, Version: pkg.Version})
+	}
+
+	results := map[int][]Vulnerability{}
+	for start, batch := range lo.Chunk(requests, searcher.MaxBatchSize) {
+		resolved, err := client.ResolveBatch(ctx, batch)
+		if errors.Is(err, searcher.ErrBatchUnsupported) {
+			resolved, err = resolveEach(ctx, batch)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for j, r := range resolved {
+			// The service resolves a partial version to a newer one,
+			// whose vulnerabilities aren't the locked version's.
+			if r == nil || r.Version != batch[j].Version {
+				continue
+			}
+			i := indexes[start*searcher.MaxBatchSize+j]
+			for _, v := range r.Vulnerabilities {
+				results[i] = append(results[i], fromSearch(v))
+			}
+		}
+	}
+	return results, nil
+}
+
+// resolveEach resolves the packages one at a time, for a search service
+// without the batch endpoint.
+func resolveEach(ctx context.Context, pkgs []searcher.ResolveRequest) ([]*searcher.ResolveResponse, error) {
+	client := searcher.Client()
+	resolved := make([]*searcher.ResolveResponse, len(pkgs))
+	for i, pkg := range pkgs {
+		r, err := client.ResolveV2(ctx, pkg.Name, pkg.Version)
+		if errors.Is(err, searcher.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = r
+	}
+	return resolved, nil
+}
+
+func fromSearch(v searcher.Vulnerability) Vulnerability {
+	severity, err := ParseSeverity(v.Severity)
+	if err != nil {
+		severity = SeverityFromScore(v.Score)
+	}
+	return Vulnerability{
+		ID:       v.ID,
+		Aliases:  v.Aliases,
+		Summary:  v.Summary,
+		Score:    v.Score,
+		Severity: severity,
+		FixedIn:  v.FixedIn,
+		URL:      v.URL,
+		Source:   SourceSearch,
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package vuln looks up known vulnerabilities in packages by cross-referencing
+// their names and versions with vulnerability databases.
+package vuln
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/debug"
+)
+
+// Package is a package to check for vulnerabilities.
+type Package struct {
+	// Name is the package's name, such as "openssl" or
+	// "python312Packages.requests".
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// StorePath is the Nix store path of the package's default output, if
+	// known.
+	StorePath string `json:"store_path,omitempty"`
+	// DependencyOf is the name of the top-level package that depends on this
+	// one. It's empty for packages listed in devbox.json.
+	DependencyOf string `json:"dependency_of,omitempty"`
+}
+
+// Vulnerability is a known vulnerability in a package.
+type Vulnerability struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	// Score is the CVSS base score, or 0 if it's unknown.
+	Score    float64  `json:"score,omitempty"`
+	Severity Severity `json:"severity"`
+	// FixedIn lists the versions that fix the vulnerability, if known.
+	FixedIn []string `json:"fixed_in,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Source  string   `json:"source"`
+}
+
+// Finding is a vulnerability that affects a package.
+type Finding struct {
+	Package       Package       `json:"package"`
+	Vulnerability Vulnerability `json:"vulnerability"`
+}
+
+// Source is a vulnerability database.
+type Source interface {
+	Name() string
+	// Query returns the vulnerabilities that affect each package, keyed by
+	// the package's index in pkgs.
+	Query(ctx context.Context, pkgs []Package) (map[int][]Vulnerability, error)
+}
+
+// Sources that can be passed to NewSource.
+const (
+	SourceSearch = "search"
+	SourceOSV    = "osv"
+	SourceNVD    = "nvd"
+	SourceVulnix = "vulnix"
+)
+
+// DefaultSources are queried when none are chosen.
+var DefaultSources = []string{SourceSearch, SourceOSV, SourceNVD}
+
+// NewSource returns the vulnerability database called name.
+func NewSource(name string) (Source, error) {
+	switch name {
+	case SourceSearch:
+		return search{}, nil
+	case SourceOSV:
+		return newOSV(), nil
+	case SourceNVD:
+		return newNVD(), nil
+	case SourceVulnix:
+		return vulnix{}, nil
+	default:
+		return nil, usererr.New(
+			"Unknown vulnerability source %q. Supported sources are: %s",
+			name, strings.Join([]string{SourceSearch, SourceOSV, SourceNVD, SourceVulnix}, ", "),
+		)
+	}
+}
+
+// Scan queries each source for vulnerabilities in pkgs. The same
+// vulnerability reported by several sources (for example, as both a GHSA and
+// a CVE) is only reported once.
+func Scan(ctx context.Context, pkgs []Package, sources []Source) ([]Finding, error) {
+	defer debug.FunctionTimer().End()
+
+	found := make([][]Vulnerability, len(pkgs))
+	for _, source := range sources {
+		results, err := source.Query(ctx, pkgs)
+		if err != nil {
+			return nil, err
+		}
+		for i, vulns := range results {
+			for _, v := range vulns {
+				found[i] = merge(found[i], v)
+			}
+		}
+	}
+
+	var findings []Finding
+	for i, vulns := range found {
+		for _, v := range vulns {
+			findings = append(findings, Finding{Package: pkgs[i], Vulnerability: v})
+		}
+	}
+	slices.SortFunc(findings, func(a, b Finding) int {
+		if c := b.Vulnerability.Severity - a.Vulnerability.Severity; c != 0 {
+			return int(c)
+		}
+		if c := strings.Compare(a.Package.Name, b.Package.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Vulnerability.ID, b.Vulnerability.ID)
+	})
+	return findings, nil
+}
+
+// merge adds v to vulns unless it's already there under one of its aliases,
+// in which case the missing details are filled in.
+func merge(vulns []Vulnerability, v Vulnerability) []Vulnerability {
+	for i := range vulns {
+		existing := &vulns[i]
+		if !existing.sameAs(v) {
+			continue
+		}
+		if existing.Score == 0 {
+			existing.Score = v.Score
+		}
+		existing.Severity = max(existing.Severity, v.Severity)
+		if len(existing.FixedIn) == 0 {
+			existing.FixedIn = v.FixedIn
+		}
+		if existing.Summary == "" {
+			existing.Summary = v.Summary
+		}
+		for _, alias := range append([]string{v.ID}, v.Aliases...) {
+			if alias != existing.ID && !slices.Contains(existing.Aliases, alias) {
+				existing.Aliases = append(existing.Aliases, alias)
+			}
+		}
+		return vulns
+	}
+	return append(vulns, v)
+}
+
+func (v Vulnerability) sameAs(other Vulnerability) bool {
+	ids := append([]string{v.ID}, v.Aliases...)
+	if slices.Contains(ids, other.ID) {
+		return true
+	}
+	for _, alias := range other.Aliases {
+		if slices.Contains(ids, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// Severity is how bad a vulnerability is, following the CVSS v3 qualitative
+// ratings.
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"unknown", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return severityNames[SeverityUnknown]
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses a severity name, case-insensitively. GitHub's
+// "moderate" is the same as "medium".
+func ParseSeverity(s string) (Severity, error) {
+	s = strings.ToLower(s)
+	if s == "moderate" {
+		return SeverityMedium, nil
+	}
+	if i := slices.Index(severityNames, s); i > 0 {
+		return Severity(i), nil
+	}
+	return SeverityUnknown, usererr.New(
+		"Unknown severity %q. Valid severities are: %s",
+		s, strings.Join(severityNames[1:], ", "),
+	)
+}
+
+// SeverityFromScore returns the CVSS v3 rating for a base score.
+func SeverityFromScore(score float64) Severity {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	if name == severityNames[SeverityUnknown] {
+		*s = SeverityUnknown
+		return nil
+	}
+	parsed, err := ParseSeverity(name)
+	if err != nil {
+		return fmt.Errorf("invalid severity: %w", err)
+	}
+	*s = parsed
+	return nil
+}
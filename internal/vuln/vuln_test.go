@@ -0,0 +1,175 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestScoreCVSS3(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10.0},
+		{"CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N", 5.9},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:L/I:L/A:N", 6.4},
+		{"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:N/I:N/A:N", 0},
+	}
+	for _, tt := range tests {
+		got, ok := scoreCVSS3(tt.vector)
+		require.True(t, ok, tt.vector)
+		require.Equal(t, tt.want, got, tt.vector)
+	}
+
+	_, ok := scoreCVSS3("CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N")
+	require.False(t, ok)
+	_, ok = scoreCVSS3("CVSS:3.1/AV:N/AC:L")
+	require.False(t, ok)
+}
+
+func TestParseSeverity(t *testing.T) {
+	s, err := ParseSeverity("HIGH")
+	require.NoError(t, err)
+	require.Equal(t, SeverityHigh, s)
+
+	s, err = ParseSeverity("moderate")
+	require.NoError(t, err)
+	require.Equal(t, SeverityMedium, s)
+
+	_, err = ParseSeverity("severe")
+	require.Error(t, err)
+
+	require.Equal(t, SeverityCritical, SeverityFromScore(9.8))
+	require.Equal(t, SeverityLow, SeverityFromScore(3.1))
+}
+
+func TestMergeAliases(t *testing.T) {
+	vulns := merge(nil, Vulnerability{ID: "GHSA-xxxx", Aliases: []string{"CVE-2024-1"}, Severity: SeverityMedium})
+	vulns = merge(vulns, Vulnerability{ID: "CVE-2024-1", Score: 7.5, Severity: SeverityHigh})
+	vulns = merge(vulns, Vulnerability{ID: "CVE-2024-2"})
+	require.Len(t, vulns, 2)
+	require.Equal(t, SeverityHigh, vulns[0].Severity)
+	require.Equal(t, 7.5, vulns[0].Score)
+}
+
+func TestPackageMapping(t *testing.T) {
+	pkg, ok := osvQueryPackage(Package{Name: "python312Packages.Django"})
+	require.True(t, ok)
+	require.Equal(t, osvPackage{Name: "django", Ecosystem: "PyPI"}, pkg)
+	pkg, ok = osvQueryPackage(Package{Name: "python3.12-requests"})
+	require.True(t, ok)
+	require.Equal(t, osvPackage{Name: "requests", Ecosystem: "PyPI"}, pkg)
+	_, ok = osvQueryPackage(Package{Name: "openssl"})
+	require.False(t, ok)
+
+	for name, want := range map[string]string{
+		"openssl":       "openssl",
+		"nodejs_20":     "node.js",
+		"python312":     "python",
+		"postgresql_16": "postgresql",
+	} {
+		got, ok := nvdProduct(Package{Name: name})
+		require.True(t, ok, name)
+		require.Equal(t, want, got, name)
+	}
+	_, ok = nvdProduct(Package{Name: "nodePackages.prettier"})
+	require.False(t, ok)
+}
+
+func TestOSVQuery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/querybatch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Queries []struct {
+				Package osvPackage `json:"package"`
+				Version string     `json:"version"`
+			} `json:"queries"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.Queries, 1)
+		require.Equal(t, "requests", req.Queries[0].Package.Name)
+		w.Write([]byte(`{"results":[{"vulns":[{"id":"GHSA-j8r2-6x86-q33q"}]}]}`))
+	})
+	mux.HandleFunc("GET /v1/vulns/GHSA-j8r2-6x86-q33q", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"id": "GHSA-j8r2-6x86-q33q",
+			"summary": "Unintended leak of Proxy-Authorization header in requests",
+			"aliases": ["CVE-2023-32681"],
+			"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:C/C:H/I:N/A:N"}],
+			"affected": [{
+				"package": {"ecosystem": "PyPI", "name": "requests"},
+				"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "2.3.0"}, {"fixed": "2.31.0"}]}]
+			}]
+		}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pkgs := []Package{
+		{Name: "openssl", Version: "3.0.13"},
+		{Name: "python312Packages.requests", Version: "2.30.0"},
+	}
+	findings, err := Scan(context.Background(), pkgs, []Source{osv{host: server.URL}})
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	require.Equal(t, pkgs[1], findings[0].Package)
+	v := findings[0].Vulnerability
+	require.Equal(t, "GHSA-j8r2-6x86-q33q", v.ID)
+	require.Equal(t, []string{"2.31.0"}, v.FixedIn)
+	require.Equal(t, 6.1, v.Score)
+	require.Equal(t, SeverityMedium, v.Severity)
+}
+
+func TestMatchVulnixResults(t *testing.T) {
+	pkgs := []Package{{Name: "curl", Version: "8.4.0"}, {Name: "zlib", Version: "1.3"}}
+	results := matchVulnixResults(pkgs, []vulnixResult{{
+		PName:      "zlib",
+		Version:    "1.3",
+		AffectedBy: []string{"CVE-2023-45853"},
+		Scores:     map[string]float64{"CVE-2023-45853": 9.8},
+	}, {
+		PName:      "glibc",
+		Version:    "2.38",
+		AffectedBy: []string{"CVE-2023-4911"},
+	}})
+	require.Len(t, results, 1)
+	require.Equal(t, SeverityCritical, results[1][0].Severity)
+}
+
+func TestSearchSource(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(index, []byte(`{"packages": [
+		{"name": "openssl", "version": "3.0.7", "vulnerabilities": [
+			{"id": "CVE-2023-0286", "severity": "high", "fixed_in": ["3.0.8"]},
+			{"id": "CVE-2023-0215", "score": 7.5}
+		]},
+		{"name": "openssl", "version": "3.0.8"}
+	]}`), 0o644))
+	t.Setenv(envir.DevboxPackageIndex, index)
+
+	results, err := search{}.Query(context.Background(), []Package{
+		{Name: "openssl", Version: "3.0.8"},
+		{Name: "openssl", Version: "3.0.7"},
+		{Name: "zlib", Version: "1.3"},
+		{Name: "openssl", Version: "3.0.7", DependencyOf: "curl"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1, "only the vulnerable package in devbox.json has findings")
+	require.Len(t, results[1], 2)
+	require.Equal(t, SeverityHigh, results[1][0].Severity)
+	require.Equal(t, []string{"3.0.8"}, results[1][0].FixedIn)
+	require.Equal(t, SeverityHigh, results[1][1].Severity, "the severity comes from the score")
+	require.Equal(t, SourceSearch, results[1][1].Source)
+}
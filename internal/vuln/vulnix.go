@@ -0,0 +1,102 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vuln
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
+)
+
+// vulnixFound is the exit code vulnix uses when it finds vulnerabilities.
+const vulnixFound = 2
+
+// vulnix runs the vulnix scanner, which checks the store paths' closures
+// against NVD with the nixpkgs-specific name mappings and whitelists
+// maintained for NixOS.
+//
+// See https://github.com/nix-community/vulnix
+type vulnix struct{}
+
+func (vulnix) Name() string { return SourceVulnix }
+
+type vulnixResult struct {
+	PName      string             `json:"pname"`
+	Version    string             `json:"version"`
+	AffectedBy []string           `json:"affected_by"`
+	Scores     map[string]float64 `json:"cvssv3_basescore"`
+	Details    map[string]string  `json:"description"`
+}
+
+func (vulnix) Query(ctx context.Context, pkgs []Package) (map[int][]Vulnerability, error) {
+	defer debug.FunctionTimer().End()
+
+	var paths []string
+	for _, p := range pkgs {
+		if p.StorePath != "" {
+			paths = append(paths, p.StorePath)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if !cmdutil.Exists("vulnix") {
+		return nil, usererr.New(
+			"The vulnix source requires vulnix to be installed. " +
+				"Add it to your devbox.json with `devbox add vulnix` or choose another --source.",
+		)
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "vulnix", append([]string{"--json"}, paths...)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if exitErr := (&exec.ExitError{}); err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == vulnixFound) {
+		return nil, redact.Errorf("run vulnix: %w", err)
+	}
+	var found []vulnixResult
+	if err := json.Unmarshal(stdout.Bytes(), &found); err != nil {
+		return nil, redact.Errorf("parse vulnix output: %w", err)
+	}
+	return matchVulnixResults(pkgs, found), nil
+}
+
+// matchVulnixResults attributes vulnix's results to packages by name and
+// version. vulnix scans whole closures, so it can report dependencies that
+// aren't in pkgs. Those are only logged.
+func matchVulnixResults(pkgs []Package, found []vulnixResult) map[int][]Vulnerability {
+	results := map[int][]Vulnerability{}
+	for _, r := range found {
+		matched := false
+		for i, p := range pkgs {
+			if p.Name != r.PName || p.Version != r.Version {
+				continue
+			}
+			matched = true
+			for _, id := range r.AffectedBy {
+				results[i] = append(results[i], Vulnerability{
+					ID:       id,
+					Summary:  r.Details[id],
+					Score:    r.Scores[id],
+					Severity: SeverityFromScore(r.Scores[id]),
+					URL:      "https://nvd.nist.gov/vuln/detail/" + id,
+					Source:   SourceVulnix,
+				})
+			}
+		}
+		if !matched {
+			debug.Log("vulnix: %s-%s isn't a scanned package, use --closure to include it", r.PName, r.Version)
+		}
+	}
+	return results
+}
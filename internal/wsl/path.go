@@ -0,0 +1,81 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package wsl
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// LinuxPath returns the path in WSL of a Windows path. Drive paths, such as
+// C:\Users\me, are under /mnt, and \\wsl$\<distro>\ or
+// \\wsl.localhost\<distro>\ paths are the distro's own files. Relative paths
+// only have their separators replaced. It returns false for paths that WSL
+// can't see, such as network shares.
+func LinuxPath(winPath string) (string, bool) {
+	if len(winPath) >= 2 && isDriveLetter(winPath[0]) && winPath[1] == ':' &&
+		(len(winPath) == 2 || winPath[2] == '\\' || winPath[2] == '/') {
+		rest := strings.ReplaceAll(winPath[2:], `\`, "/")
+		return "/mnt/" + strings.ToLower(winPath[:1]) + strings.TrimSuffix(rest, "/"), true
+	}
+	for _, prefix := range []string{`\\wsl$\`, `\\wsl.localhost\`} {
+		if len(winPath) < len(prefix) || !strings.EqualFold(winPath[:len(prefix)], prefix) {
+			continue
+		}
+		// Skip the distro's name.
+		_, rest, _ := strings.Cut(winPath[len(prefix):], `\`)
+		return "/" + strings.ReplaceAll(rest, `\`, "/"), true
+	}
+	if strings.HasPrefix(winPath, `\\`) {
+		return "", false
+	}
+	return strings.ReplaceAll(winPath, `\`, "/"), true
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// translateArgs translates the Windows paths in devbox's arguments, such as
+// `--config C:\src\app` or `--config=.\app`, to paths in WSL. Other arguments
+// are kept as is, since backslashes are common in commands, such as regular
+// expressions.
+func translateArgs(args []string) []string {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		translated[i] = arg
+		if flag, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(flag, "-") {
+			if path, ok := translatePathArg(value); ok {
+				translated[i] = flag + "=" + path
+			}
+		} else if path, ok := translatePathArg(arg); ok {
+			translated[i] = path
+		}
+	}
+	return translated
+}
+
+func translatePathArg(arg string) (string, bool) {
+	isPath := strings.HasPrefix(arg, `.\`) || strings.HasPrefix(arg, `..\`) || strings.HasPrefix(arg, `\\`) ||
+		(len(arg) >= 3 && isDriveLetter(arg[0]) && arg[1] == ':' && (arg[2] == '\\' || arg[2] == '/'))
+	if !isPath {
+		return "", false
+	}
+	return LinuxPath(arg)
+}
+
+// decodeWSLOutput decodes the output of wsl.exe's own commands, such as
+// `wsl.exe --list`, which is UTF-16 even when it's redirected.
+func decodeWSLOutput(data []byte) string {
+	hasBOM := len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe
+	if !hasBOM && (len(data) < 2 || data[1] != 0) {
+		// Newer versions print UTF-8 when WSL_UTF8=1.
+		return string(data)
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+	}
+	return strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+}
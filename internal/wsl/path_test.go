@@ -0,0 +1,58 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package wsl
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinuxPath(t *testing.T) {
+	tests := []struct {
+		winPath string
+		want    string
+		ok      bool
+	}{
+		{`C:\Users\me\src\app`, "/mnt/c/Users/me/src/app", true},
+		{`d:/data/`, "/mnt/d/data", true},
+		{`C:\`, "/mnt/c", true},
+		{`\\wsl$\Ubuntu\home\me\app`, "/home/me/app", true},
+		{`\\wsl.localhost\Ubuntu\home\me`, "/home/me", true},
+		{`.\app\devbox.json`, "./app/devbox.json", true},
+		{`\\fileserver\share\app`, "", false},
+	}
+	for _, test := range tests {
+		got, ok := LinuxPath(test.winPath)
+		require.Equal(t, test.ok, ok, test.winPath)
+		require.Equal(t, test.want, got, test.winPath)
+	}
+}
+
+func TestTranslateArgs(t *testing.T) {
+	got := translateArgs([]string{
+		"run", "--config", `C:\src\app`, `--config=..\app`, "--", "grep", `\d+`, "a=b",
+	})
+	require.Equal(t, []string{
+		"run", "--config", "/mnt/c/src/app", "--config=../app", "--", "grep", `\d+`, "a=b",
+	}, got)
+}
+
+func TestParseDistroList(t *testing.T) {
+	utf16le := func(s string) []byte {
+		var out []byte
+		for _, u := range utf16.Encode([]rune(s)) {
+			out = append(out, byte(u), byte(u>>8))
+		}
+		return out
+	}
+	require.Equal(t, []string{"Ubuntu", "Debian"}, parseDistroList(utf16le("\ufeffUbuntu\r\nDebian\r\n")))
+	require.Equal(t, []string{"Ubuntu"}, parseDistroList([]byte("Ubuntu\n")))
+}
+
+func TestWSLENV(t *testing.T) {
+	require.Equal(t, "USERPROFILE/p:DEVBOX_DEBUG",
+		wslenv([]string{"WSLENV=USERPROFILE/p", "DEVBOX_DEBUG=1", "PATH=C:\\Windows"}))
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package wsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// terminalFragment is a Windows Terminal JSON fragment, which adds profiles
+// without editing the user's settings.json.
+type terminalFragment struct {
+	Profiles []terminalProfile `json:"profiles"`
+}
+
+type terminalProfile struct {
+	Name              string `json:"name"`
+	Commandline       string `json:"commandline"`
+	StartingDirectory string `json:"startingDirectory"`
+}
+
+// WriteTerminalProfile adds a Windows Terminal profile that opens devbox
+// shell in a project directory, which defaults to the working directory. It
+// returns the path of the fragment it wrote.
+func WriteTerminalProfile(distro, dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	fragments := filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Windows Terminal", "Fragments", "Devbox")
+	if err := os.MkdirAll(fragments, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(newTerminalFragment(distro, exe, dir), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(fragments, fragmentName(dir))
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("write the Windows Terminal profile: %w", err)
+	}
+	return path, nil
+}
+
+// newTerminalFragment returns the fragment of a project's profile. The
+// profile runs this binary, so that it translates the directory and sets up
+// the distro like any other devbox command.
+func newTerminalFragment(distro, exe, dir string) terminalFragment {
+	commandline := `"` + exe + `" shell`
+	if distro != defaultDistro {
+		commandline = fmt.Sprintf(`cmd.exe /c set "%s=%s" && %s`, envir.DevboxWSLDistro, distro, commandline)
+	}
+	return terminalFragment{Profiles: []terminalProfile{{
+		Name:              "Devbox: " + filepath.Base(dir),
+		Commandline:       commandline,
+		StartingDirectory: dir,
+	}}}
+}
+
+// fragmentName returns the fragment's file name, which is unique to the
+// project so that each project gets a profile.
+func fragmentName(dir string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == ':' || r == '\\' || r == '/' || r == ' ' {
+			return '-'
+		}
+		return r
+	}, strings.Trim(dir, `\/`))
+	return strings.ToLower(name) + ".json"
+}
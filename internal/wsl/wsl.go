@@ -0,0 +1,205 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package wsl is the devbox CLI on Windows. Devbox needs Nix, which only
+// runs on Linux, so the Windows binary provisions a WSL distro with Nix and
+// devbox and proxies each invocation into it, translating Windows paths in
+// the working directory and arguments.
+package wsl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+const defaultDistro = "Ubuntu"
+
+// notProvisionedExitCode is the exit code of the proxy script when the
+// distro doesn't have devbox yet.
+const notProvisionedExitCode = 199
+
+// proxyScript runs devbox in a login shell, so that the PATH has Nix and
+// devbox.
+var proxyScript = fmt.Sprintf(`command -v devbox >/dev/null || exit %d; exec devbox "$@"`, notProvisionedExitCode)
+
+// Main runs devbox in WSL with os.Args, and exits with its exit code.
+// `devbox wsl ...` commands manage the WSL integration itself.
+func Main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) > 0 && args[0] == "wsl" {
+		if err := wslCmd(args[1:]); err != nil {
+			ux.Ferror(os.Stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if _, err := exec.LookPath("wsl.exe"); err != nil {
+		ux.Ferror(os.Stderr, "Devbox on Windows runs in WSL, which isn't installed. "+
+			"Run `wsl --install` in an administrator PowerShell, restart, and try again.\n")
+		return 1
+	}
+
+	distro := Distro()
+	code, err := proxy(distro, args)
+	if err == nil && code == notProvisionedExitCode {
+		ux.Finfo(os.Stderr, "Devbox isn't installed in the %s WSL distro yet. Installing it now.\n", distro)
+		if err = Setup(distro); err == nil {
+			code, err = proxy(distro, args)
+		}
+	}
+	if err != nil {
+		ux.Ferror(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return code
+}
+
+// Distro returns the name of the WSL distro that devbox runs in.
+func Distro() string {
+	if distro := os.Getenv(envir.DevboxWSLDistro); distro != "" {
+		return distro
+	}
+	return defaultDistro
+}
+
+// proxy runs devbox in the distro, in the WSL path of the working directory,
+// and returns its exit code.
+func proxy(distro string, args []string) (int, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command("wsl.exe", "--distribution", distro)
+	if dir, ok := LinuxPath(wd); ok {
+		cmd.Args = append(cmd.Args, "--cd", dir)
+	} else {
+		ux.Fwarning(os.Stderr, "WSL can't access %s, so devbox runs in your WSL home directory.\n", wd)
+		cmd.Args = append(cmd.Args, "--cd", "~")
+	}
+	cmd.Args = append(cmd.Args, "--exec", "bash", "-lc", proxyScript, "devbox")
+	cmd.Args = append(cmd.Args, translateArgs(args)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "WSLENV="+wslenv(os.Environ()))
+
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	} else if err != nil {
+		return 0, fmt.Errorf("run wsl.exe: %w", err)
+	}
+	return 0, nil
+}
+
+// wslenv returns the WSLENV variable that shares the DEVBOX_ variables with
+// WSL, in addition to the ones that WSLENV already shares.
+func wslenv(environ []string) string {
+	var names []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.EqualFold(name, "WSLENV") && value != "" {
+			names = append(names, strings.Split(value, ":")...)
+		}
+	}
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "DEVBOX_") && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ":")
+}
+
+// installedDistros returns the names of the WSL distros.
+func installedDistros() ([]string, error) {
+	out, err := exec.Command("wsl.exe", "--list", "--quiet").Output()
+	if err != nil {
+		// wsl.exe exits with an error when there are no distros.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list WSL distros: %w", err)
+	}
+	return parseDistroList(out), nil
+}
+
+func parseDistroList(out []byte) []string {
+	var distros []string
+	for _, line := range strings.Split(decodeWSLOutput(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			distros = append(distros, line)
+		}
+	}
+	return distros
+}
+
+// Setup installs the distro if needed, then installs devbox and Nix in it.
+// The distro's installer asks for the name and password of its user, and
+// installing devbox asks for the password to write to /usr/local/bin.
+func Setup(distro string) error {
+	distros, err := installedDistros()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(distros, func(d string) bool { return strings.EqualFold(d, distro) }) {
+		ux.Finfo(os.Stderr, "Installing the %s WSL distro.\n", distro)
+		if err := runInteractive("wsl.exe", "--install", "--distribution", distro); err != nil {
+			return fmt.Errorf("install the %s WSL distro: %w", distro, err)
+		}
+	}
+	for _, step := range []struct{ name, script string }{
+		{"devbox", "command -v devbox >/dev/null || curl -fsSL https://get.jetify.com/devbox | bash -s -- -f"},
+		{"Nix", "devbox setup nix"},
+	} {
+		ux.Finfo(os.Stderr, "Installing %s in %s.\n", step.name, distro)
+		err := runInteractive("wsl.exe", "--distribution", distro, "--cd", "~", "--exec", "bash", "-lc", step.script)
+		if err != nil {
+			return fmt.Errorf("install %s in %s: %w", step.name, distro, err)
+		}
+	}
+	ux.Fsuccess(os.Stderr, "Devbox is ready in the %s WSL distro.\n", distro)
+	return nil
+}
+
+func runInteractive(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func wslCmd(args []string) error {
+	usage := fmt.Errorf("usage: devbox wsl setup | devbox wsl terminal-profile [dir]")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "setup":
+		return Setup(Distro())
+	case "terminal-profile":
+		dir := ""
+		if len(args) > 1 {
+			dir = args[1]
+		}
+		path, err := WriteTerminalProfile(Distro(), dir)
+		if err != nil {
+			return err
+		}
+		ux.Fsuccess(os.Stderr, "Added a Windows Terminal profile in %s. Restart Windows Terminal to see it.\n", path)
+		return nil
+	}
+	return usage
+}
@@ -67,6 +67,12 @@ type Ref struct {
 	// or "git". Note that the URL is not the same as the raw unparsed
 	// flake ref.
 	URL string `json:"url,omitempty"`
+
+	// NarHash is the expected SRI hash of the flake's source tree, such as
+	// "sha256-...". Nix refuses to use a source with a different hash. It
+	// corresponds to the optional "narHash" query parameter when Type is
+	// "github", "git", or "tarball", and is set in locked flake refs.
+	NarHash string `json:"narHash,omitempty"`
 }
 
 // ParseRef parses a raw flake reference. Nix supports a variety of flake ref
@@ -159,6 +165,7 @@ func parseURLRef(ref string) (parsed Ref, fragment string, err error) {
 	case "http", "https", "file":
 		if isArchive(refURL.Path) {
 			parsed.Type = TypeTarball
+			parsed.NarHash = refURL.Query().Get("narHash")
 		} else {
 			parsed.Type = TypeFile
 		}
@@ -167,6 +174,7 @@ func parseURLRef(ref string) (parsed Ref, fragment string, err error) {
 	case "tarball+http", "tarball+https", "tarball+file":
 		parsed.Type = TypeTarball
 		parsed.Dir = refURL.Query().Get("dir")
+		parsed.NarHash = refURL.Query().Get("narHash")
 
 		refURL.Scheme = refURL.Scheme[8:] // remove tarball+
 		parsed.URL = refURL.String()
@@ -182,11 +190,13 @@ func parseURLRef(ref string) (parsed Ref, fragment string, err error) {
 		parsed.Dir = q.Get("dir")
 		parsed.Ref = q.Get("ref")
 		parsed.Rev = q.Get("rev")
+		parsed.NarHash = q.Get("narHash")
 
-		// ref and rev get stripped from the query parameters, but dir
-		// stays.
+		// ref, rev and narHash get stripped from the query parameters,
+		// but dir stays.
 		q.Del("ref")
 		q.Del("rev")
+		q.Del("narHash")
 		refURL.RawQuery = q.Encode()
 		if len(refURL.Scheme) > 3 {
 			refURL.Scheme = refURL.Scheme[4:] // remove git+
@@ -226,6 +236,7 @@ func parseGitHubRef(refURL *url.URL, parsed *Ref) error {
 
 	parsed.Host = refURL.Query().Get("host")
 	parsed.Dir = refURL.Query().Get("dir")
+	parsed.NarHash = refURL.Query().Get("narHash")
 	if qRef := refURL.Query().Get("ref"); qRef != "" {
 		if parsed.Rev != "" {
 			return redact.Errorf("github flake reference has a ref and a rev")
@@ -283,7 +294,7 @@ func (r Ref) String() string {
 		// (but not other parameters) after parsing. If they're empty,
 		// we can skip parsing the URL. Otherwise, we need to add them
 		// back.
-		if r.Ref == "" && r.Rev == "" {
+		if r.Ref == "" && r.Rev == "" && r.NarHash == "" {
 			return r.URL
 		}
 		url, err := url.Parse(r.URL)
@@ -292,7 +303,7 @@ func (r Ref) String() string {
 			// messed with the parsed URL.
 			return ""
 		}
-		url.RawQuery = buildQueryString("ref", r.Ref, "rev", r.Rev, "dir", r.Dir)
+		url.RawQuery = buildQueryString("ref", r.Ref, "rev", r.Rev, "dir", r.Dir, "narHash", r.NarHash)
 		return url.String()
 	case TypeGitHub:
 		if r.Owner == "" || r.Repo == "" {
@@ -301,7 +312,7 @@ func (r Ref) String() string {
 		url := &url.URL{
 			Scheme:   "github",
 			Opaque:   buildEscapedPath(r.Owner, r.Repo, r.Rev, r.Ref),
-			RawQuery: buildQueryString("host", r.Host, "dir", r.Dir),
+			RawQuery: buildQueryString("host", r.Host, "dir", r.Dir, "narHash", r.NarHash),
 		}
 		return url.String()
 	case TypeIndirect:
@@ -338,7 +349,7 @@ func (r Ref) String() string {
 		if !strings.HasPrefix(r.URL, "tarball") {
 			r.URL = "tarball+" + r.URL
 		}
-		if r.Dir == "" {
+		if r.Dir == "" && r.NarHash == "" {
 			return r.URL
 		}
 
@@ -348,7 +359,18 @@ func (r Ref) String() string {
 			// messed with the parsed URL.
 			return ""
 		}
-		url.RawQuery = buildQueryString("dir", r.Dir)
+
+		// Unlike git URLs, the query is part of the tarball's URL, so
+		// its other parameters (such as access tokens) are kept.
+		q := url.Query()
+		for key, value := range map[string]string{"dir": r.Dir, "narHash": r.NarHash} {
+			if value == "" {
+				q.Del(key)
+			} else {
+				q.Set(key, value)
+			}
+		}
+		url.RawQuery = q.Encode()
 		return url.String()
 	default:
 		return ""
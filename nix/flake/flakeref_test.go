@@ -59,12 +59,13 @@ func TestParseFlakeRef(t *testing.T) {
 		"github:NixOS/nix?ref=v1.2.3": {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "v1.2.3"},
 		"github:NixOS/nix?ref=5233fd2ba76a3accb5aaa999c00509a11fd0793c": {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
 		"github:NixOS/nix/main": {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "main"},
-		"github:NixOS/nix/main/5233fd2ba76a3accb5aaa999c00509a11fd0793c": {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "main/5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
-		"github:NixOS/nix/5233fd2bb76a3accb5aaa999c00509a11fd0793z":      {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2bb76a3accb5aaa999c00509a11fd0793z"},
-		"github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c":      {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
-		"github:NixOS/nix?rev=5233fd2ba76a3accb5aaa999c00509a11fd0793c":  {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
-		"github:NixOS/nix?host=example.com":                              {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Host: "example.com"},
-		"github:NixOS/nix?host=example.com&dir=subdir":                   {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Host: "example.com", Dir: "subdir"},
+		"github:NixOS/nix/main/5233fd2ba76a3accb5aaa999c00509a11fd0793c":                  {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "main/5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
+		"github:NixOS/nix/5233fd2bb76a3accb5aaa999c00509a11fd0793z":                       {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2bb76a3accb5aaa999c00509a11fd0793z"},
+		"github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c":                       {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
+		"github:NixOS/nix?rev=5233fd2ba76a3accb5aaa999c00509a11fd0793c":                   {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"},
+		"github:NixOS/nix?host=example.com":                                               {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Host: "example.com"},
+		"github:NixOS/nix?host=example.com&dir=subdir":                                    {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Host: "example.com", Dir: "subdir"},
+		"github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c?narHash=sha256-abc%3D": {Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c", NarHash: "sha256-abc="},
 
 		// The github type allows clone-style URLs. The username and
 		// host are ignored.
@@ -81,7 +82,8 @@ func TestParseFlakeRef(t *testing.T) {
 		"git+ssh://git@example.com/repo/flake": {Type: TypeGit, URL: "ssh://git@example.com/repo/flake"},
 		"git:/repo/flake":                      {Type: TypeGit, URL: "git:/repo/flake"},
 		"git+file:///repo/flake":               {Type: TypeGit, URL: "file:///repo/flake"},
-		"git://example.com/repo/flake?ref=unstable&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4&dir=subdir": {Type: TypeGit, URL: "git://example.com/repo/flake?dir=subdir", Ref: "unstable", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", Dir: "subdir"},
+		"git://example.com/repo/flake?ref=unstable&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4&dir=subdir":     {Type: TypeGit, URL: "git://example.com/repo/flake?dir=subdir", Ref: "unstable", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", Dir: "subdir"},
+		"git+https://example.com/repo/flake?rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4&narHash=sha256-abc%3D": {Type: TypeGit, URL: "https://example.com/repo/flake", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", NarHash: "sha256-abc="},
 
 		// Tarball references.
 		"tarball+http://example.com/flake":  {Type: TypeTarball, URL: "http://example.com/flake"},
@@ -91,22 +93,23 @@ func TestParseFlakeRef(t *testing.T) {
 		// Regular URLs have the tarball type if they have a known
 		// archive extension:
 		// .zip, .tar, .tgz, .tar.gz, .tar.xz, .tar.bz2 or .tar.zst
-		"http://example.com/flake.zip":            {Type: TypeTarball, URL: "http://example.com/flake.zip"},
-		"http://example.com/flake.tar":            {Type: TypeTarball, URL: "http://example.com/flake.tar"},
-		"http://example.com/flake.tgz":            {Type: TypeTarball, URL: "http://example.com/flake.tgz"},
-		"http://example.com/flake.tar.gz":         {Type: TypeTarball, URL: "http://example.com/flake.tar.gz"},
-		"http://example.com/flake.tar.xz":         {Type: TypeTarball, URL: "http://example.com/flake.tar.xz"},
-		"http://example.com/flake.tar.bz2":        {Type: TypeTarball, URL: "http://example.com/flake.tar.bz2"},
-		"http://example.com/flake.tar.zst":        {Type: TypeTarball, URL: "http://example.com/flake.tar.zst"},
-		"http://example.com/flake.tar?dir=subdir": {Type: TypeTarball, URL: "http://example.com/flake.tar?dir=subdir", Dir: "subdir"},
-		"file:///flake.zip":                       {Type: TypeTarball, URL: "file:///flake.zip"},
-		"file:///flake.tar":                       {Type: TypeTarball, URL: "file:///flake.tar"},
-		"file:///flake.tgz":                       {Type: TypeTarball, URL: "file:///flake.tgz"},
-		"file:///flake.tar.gz":                    {Type: TypeTarball, URL: "file:///flake.tar.gz"},
-		"file:///flake.tar.xz":                    {Type: TypeTarball, URL: "file:///flake.tar.xz"},
-		"file:///flake.tar.bz2":                   {Type: TypeTarball, URL: "file:///flake.tar.bz2"},
-		"file:///flake.tar.zst":                   {Type: TypeTarball, URL: "file:///flake.tar.zst"},
-		"file:///flake.tar?dir=subdir":            {Type: TypeTarball, URL: "file:///flake.tar?dir=subdir", Dir: "subdir"},
+		"http://example.com/flake.zip":                        {Type: TypeTarball, URL: "http://example.com/flake.zip"},
+		"http://example.com/flake.tar":                        {Type: TypeTarball, URL: "http://example.com/flake.tar"},
+		"http://example.com/flake.tgz":                        {Type: TypeTarball, URL: "http://example.com/flake.tgz"},
+		"http://example.com/flake.tar.gz":                     {Type: TypeTarball, URL: "http://example.com/flake.tar.gz"},
+		"http://example.com/flake.tar.xz":                     {Type: TypeTarball, URL: "http://example.com/flake.tar.xz"},
+		"http://example.com/flake.tar.bz2":                    {Type: TypeTarball, URL: "http://example.com/flake.tar.bz2"},
+		"http://example.com/flake.tar.zst":                    {Type: TypeTarball, URL: "http://example.com/flake.tar.zst"},
+		"http://example.com/flake.tar?dir=subdir":             {Type: TypeTarball, URL: "http://example.com/flake.tar?dir=subdir", Dir: "subdir"},
+		"file:///flake.zip":                                   {Type: TypeTarball, URL: "file:///flake.zip"},
+		"file:///flake.tar":                                   {Type: TypeTarball, URL: "file:///flake.tar"},
+		"file:///flake.tgz":                                   {Type: TypeTarball, URL: "file:///flake.tgz"},
+		"file:///flake.tar.gz":                                {Type: TypeTarball, URL: "file:///flake.tar.gz"},
+		"file:///flake.tar.xz":                                {Type: TypeTarball, URL: "file:///flake.tar.xz"},
+		"file:///flake.tar.bz2":                               {Type: TypeTarball, URL: "file:///flake.tar.bz2"},
+		"file:///flake.tar.zst":                               {Type: TypeTarball, URL: "file:///flake.tar.zst"},
+		"file:///flake.tar?dir=subdir":                        {Type: TypeTarball, URL: "file:///flake.tar?dir=subdir", Dir: "subdir"},
+		"https://example.com/flake.tar?narHash=sha256-abc%3D": {Type: TypeTarball, URL: "https://example.com/flake.tar?narHash=sha256-abc%3D", NarHash: "sha256-abc="},
 
 		// File URL references.
 		"file+file:///flake":                           {Type: TypeFile, URL: "file:///flake"},
@@ -229,13 +232,14 @@ func TestFlakeRefString(t *testing.T) {
 		{Type: TypeIndirect, ID: "indirect", Ref: "ref", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"}: "flake:indirect/ref/5233fd2ba76a3accb5aaa999c00509a11fd0793c",
 
 		// GitHub references.
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix"}:                                                  "github:NixOS/nix",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "v1.2.3"}:                                   "github:NixOS/nix/v1.2.3",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "my/ref"}:                                   "github:NixOS/nix/my%2Fref",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"}: "github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2bb76a3accb5aaa999c00509a11fd0793z"}: "github:NixOS/nix/5233fd2bb76a3accb5aaa999c00509a11fd0793z",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Dir: "sub/dir"}:                                  "github:NixOS/nix?dir=sub%2Fdir",
-		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Dir: "sub/dir", Host: "example.com"}:             "github:NixOS/nix?dir=sub%2Fdir&host=example.com",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix"}:                                                                          "github:NixOS/nix",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "v1.2.3"}:                                                           "github:NixOS/nix/v1.2.3",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "my/ref"}:                                                           "github:NixOS/nix/my%2Fref",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2ba76a3accb5aaa999c00509a11fd0793c"}:                         "github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Ref: "5233fd2bb76a3accb5aaa999c00509a11fd0793z"}:                         "github:NixOS/nix/5233fd2bb76a3accb5aaa999c00509a11fd0793z",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Dir: "sub/dir"}:                                                          "github:NixOS/nix?dir=sub%2Fdir",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Dir: "sub/dir", Host: "example.com"}:                                     "github:NixOS/nix?dir=sub%2Fdir&host=example.com",
+		{Type: TypeGitHub, Owner: "NixOS", Repo: "nix", Rev: "5233fd2ba76a3accb5aaa999c00509a11fd0793c", NarHash: "sha256-abc="}: "github:NixOS/nix/5233fd2ba76a3accb5aaa999c00509a11fd0793c?narHash=sha256-abc%3D",
 
 		// Git references.
 		{Type: TypeGit, URL: "git://example.com/repo/flake"}:                                                                     "git://example.com/repo/flake",
@@ -246,12 +250,15 @@ func TestFlakeRefString(t *testing.T) {
 		{Type: TypeGit, URL: "ssh://git@example.com/repo/flake", Ref: "my/ref", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4"}: "git+ssh://git@example.com/repo/flake?ref=my%2Fref&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4",
 		{Type: TypeGit, URL: "ssh://git@example.com/repo/flake?dir=sub%2Fdir", Ref: "my/ref", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", Dir: "sub/dir"}: "git+ssh://git@example.com/repo/flake?dir=sub%2Fdir&ref=my%2Fref&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4",
 		{Type: TypeGit, URL: "git:repo/flake?dir=sub%2Fdir", Ref: "my/ref", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", Dir: "sub/dir"}:                   "git:repo/flake?dir=sub%2Fdir&ref=my%2Fref&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4",
+		{Type: TypeGit, URL: "https://example.com/repo/flake", Rev: "e486d8d40e626a20e06d792db8cc5ac5aba9a5b4", NarHash: "sha256-abc="}:                        "git+https://example.com/repo/flake?narHash=sha256-abc%3D&rev=e486d8d40e626a20e06d792db8cc5ac5aba9a5b4",
 
 		// Tarball references.
-		{Type: TypeTarball, URL: "http://example.com/flake"}:                  "tarball+http://example.com/flake",
-		{Type: TypeTarball, URL: "https://example.com/flake"}:                 "tarball+https://example.com/flake",
-		{Type: TypeTarball, URL: "https://example.com/flake", Dir: "sub/dir"}: "tarball+https://example.com/flake?dir=sub%2Fdir",
-		{Type: TypeTarball, URL: "file:///home/flake"}:                        "tarball+file:///home/flake",
+		{Type: TypeTarball, URL: "http://example.com/flake"}:                                           "tarball+http://example.com/flake",
+		{Type: TypeTarball, URL: "https://example.com/flake"}:                                          "tarball+https://example.com/flake",
+		{Type: TypeTarball, URL: "https://example.com/flake", Dir: "sub/dir"}:                          "tarball+https://example.com/flake?dir=sub%2Fdir",
+		{Type: TypeTarball, URL: "https://example.com/flake", NarHash: "sha256-abc="}:                  "tarball+https://example.com/flake?narHash=sha256-abc%3D",
+		{Type: TypeTarball, URL: "https://example.com/flake.tar.gz?token=abc", NarHash: "sha256-abc="}: "tarball+https://example.com/flake.tar.gz?narHash=sha256-abc%3D&token=abc",
+		{Type: TypeTarball, URL: "file:///home/flake"}:                                                 "tarball+file:///home/flake",
 
 		// File URL references.
 		{Type: TypeFile, URL: "file:///flake"}:                                              "file+file:///flake",
@@ -0,0 +1,86 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lockfile
+
+import (
+	"slices"
+
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// The conversions copy every field, so that the caller can't change the
+// lockfile that Devbox reads or writes through a shared slice or map. The
+// legacy store_path isn't copied: lock.File already turns it into an output.
+
+func fromLock(f *lock.File) *File {
+	file := &File{LockFileVersion: f.LockFileVersion, Packages: make(map[string]*Package, len(f.Packages))}
+	for key, pkg := range f.Packages {
+		if pkg == nil {
+			continue
+		}
+		p := &Package{
+			AllowInsecure: pkg.AllowInsecure,
+			LastModified:  pkg.LastModified,
+			License:       slices.Clone(pkg.License),
+			PluginVersion: pkg.PluginVersion,
+			Resolved:      pkg.Resolved,
+			Source:        pkg.Source,
+			SourceOf:      slices.Clone(pkg.SourceOf),
+			Version:       pkg.Version,
+		}
+		for sys, info := range pkg.Systems {
+			if info == nil {
+				continue
+			}
+			if p.Systems == nil {
+				p.Systems = map[string]*SystemInfo{}
+			}
+			s := &SystemInfo{Resolved: info.Resolved, Version: info.Version, Digest: info.Digest}
+			for _, out := range info.Outputs {
+				s.Outputs = append(s.Outputs, Output{
+					Name: out.Name, Path: out.Path, Default: out.Default, Hash: out.Hash, CA: out.CA,
+				})
+			}
+			p.Systems[sys] = s
+		}
+		file.Packages[key] = p
+	}
+	return file
+}
+
+func (f *File) toLock() *lock.File {
+	file := &lock.File{LockFileVersion: f.LockFileVersion, Packages: make(map[string]*lock.Package, len(f.Packages))}
+	for key, pkg := range f.Packages {
+		if pkg == nil {
+			continue
+		}
+		p := &lock.Package{
+			AllowInsecure: pkg.AllowInsecure,
+			LastModified:  pkg.LastModified,
+			License:       slices.Clone(pkg.License),
+			PluginVersion: pkg.PluginVersion,
+			Resolved:      pkg.Resolved,
+			Source:        pkg.Source,
+			SourceOf:      slices.Clone(pkg.SourceOf),
+			Version:       pkg.Version,
+		}
+		for sys, info := range pkg.Systems {
+			if info == nil {
+				continue
+			}
+			if p.Systems == nil {
+				p.Systems = map[string]*lock.SystemInfo{}
+			}
+			s := &lock.SystemInfo{Resolved: info.Resolved, Version: info.Version, Digest: info.Digest}
+			for _, out := range info.Outputs {
+				s.Outputs = append(s.Outputs, lock.Output{
+					Name: out.Name, Path: out.Path, Default: out.Default, Hash: out.Hash, CA: out.CA,
+				})
+			}
+			p.Systems[sys] = s
+		}
+		file.Packages[key] = p
+	}
+	return file
+}
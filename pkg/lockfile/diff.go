@@ -0,0 +1,86 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lockfile
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Op is how a package changed between two lockfiles.
+type Op string
+
+const (
+	Added   Op = "added"
+	Removed Op = "removed"
+	Changed Op = "changed"
+)
+
+// Change is a package that's different in two lockfiles.
+type Change struct {
+	Key string `json:"key"`
+	Op  Op     `json:"op"`
+	// Before and After are the package in each lockfile. Before is nil for
+	// an added package, and After for a removed one.
+	Before *Package `json:"before,omitempty"`
+	After  *Package `json:"after,omitempty"`
+	// Fields are the JSON names of the fields that changed, such as
+	// "version" and "systems", for a changed package.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Diff returns the packages that are different in before and after, sorted
+// by key.
+func Diff(before, after *File) []Change {
+	keys := lo.Uniq(append(lo.Keys(before.Packages), lo.Keys(after.Packages)...))
+	slices.Sort(keys)
+
+	var changes []Change
+	for _, key := range keys {
+		b, a := before.Packages[key], after.Packages[key]
+		switch {
+		case b == nil && a == nil:
+		case b == nil:
+			changes = append(changes, Change{Key: key, Op: Added, After: a})
+		case a == nil:
+			changes = append(changes, Change{Key: key, Op: Removed, Before: b})
+		default:
+			if fields := changedFields(b, a); len(fields) > 0 {
+				changes = append(changes, Change{Key: key, Op: Changed, Before: b, After: a, Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+// changedFields returns the JSON names of the fields of a and b that aren't
+// equal, in the order that they're declared.
+func changedFields(a, b *Package) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var fields []string
+	for i := range va.NumField() {
+		x, y := va.Field(i), vb.Field(i)
+		if isEmpty(x) && isEmpty(y) {
+			// A nil and an empty slice or map are both left out of the
+			// lockfile.
+			continue
+		}
+		if !reflect.DeepEqual(x.Interface(), y.Interface()) {
+			name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
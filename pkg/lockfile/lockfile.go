@@ -0,0 +1,171 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package lockfile reads, inspects, changes and writes devbox.lock files, for
+// tools that audit or generate Devbox projects.
+//
+// The types mirror the lockfile's JSON format, which is the stable part of
+// this API: fields are added when the format grows, but never renamed or
+// removed. Load upgrades lockfiles written by older versions of Devbox, and
+// Save writes them the same way Devbox does, so that a lockfile that's loaded
+// and saved without changes keeps its bytes.
+//
+// The package doesn't resolve packages. Changes that need the search service,
+// such as adding a package, are made with the devbox CLI.
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// FileName is the name of the lockfile in a project directory.
+const FileName = "devbox.lock"
+
+// File is a devbox.lock file.
+type File struct {
+	// LockFileVersion is the version of the lockfile format. Load upgrades
+	// older lockfiles to the version that this package writes.
+	LockFileVersion string `json:"lockfile_version"`
+	// Packages are the locked packages, keyed by their name and version in
+	// devbox.json, such as "go@1.22" or "github:nixos/nixpkgs#hello".
+	Packages map[string]*Package `json:"packages"`
+}
+
+// Package is a locked package.
+type Package struct {
+	AllowInsecure bool `json:"allow_insecure,omitempty"`
+	// LastModified is when the nixpkgs commit that the package was resolved
+	// to was made, in RFC 3339 format.
+	LastModified string `json:"last_modified,omitempty"`
+	// License holds the SPDX identifiers of the package's licenses.
+	License       []string `json:"license,omitempty"`
+	PluginVersion string   `json:"plugin_version,omitempty"`
+	// Resolved is the installable that the package is locked to, such as
+	// "github:NixOS/nixpkgs/<commit>#go".
+	Resolved string `json:"resolved,omitempty"`
+	// Source is how the package was resolved, such as "devbox-search".
+	Source string `json:"source,omitempty"`
+	// SourceOf is what adds the package to the project, such as
+	// "devbox.json" or "include:./plugins/redis".
+	SourceOf []string `json:"source_of,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	// Systems is keyed by system, such as "x86_64-linux".
+	Systems map[string]*SystemInfo `json:"systems,omitempty"`
+}
+
+// SystemInfo is what a package is locked to on one system.
+type SystemInfo struct {
+	Outputs []Output `json:"outputs,omitempty"`
+	// Resolved and Version are set when the package has a different version
+	// on each system, and replace the package's Resolved and Version.
+	Resolved string `json:"resolved,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// Digest pins the files that a runx: package installed on this system.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Output is a locked output of a package.
+type Output struct {
+	Name string `json:"name,omitempty"`
+	// Path is the output's store path.
+	Path    string `json:"path,omitempty"`
+	Default bool   `json:"default,omitempty"`
+	// Hash is the NAR hash of the output, in "sha256:<nix base32>" form.
+	Hash string `json:"hash,omitempty"`
+	// CA is the output's content address, if its store path is
+	// content-addressed.
+	CA string `json:"ca,omitempty"`
+}
+
+// New returns an empty lockfile.
+func New() *File {
+	return &File{Packages: map[string]*Package{}}
+}
+
+// Load reads a lockfile. path is the lockfile or the project directory that
+// has it. Lockfiles from older versions of Devbox are upgraded in memory, and
+// lockfiles from newer ones return an error.
+func Load(path string) (*File, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, FileName)
+	}
+	f, err := lock.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromLock(f), nil
+}
+
+// Save writes the lockfile to path, which is the lockfile or the project
+// directory that has it, in the form that Devbox writes it.
+func (f *File) Save(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, FileName)
+	}
+	return lock.WriteFile(path, f.toLock())
+}
+
+// Keys returns the keys of the locked packages, sorted.
+func (f *File) Keys() []string {
+	keys := lo.Keys(f.Packages)
+	slices.Sort(keys)
+	return keys
+}
+
+// Get returns the package locked with key, or nil if it isn't locked.
+func (f *File) Get(key string) *Package {
+	return f.Packages[key]
+}
+
+// Set locks key to pkg, replacing any package that it was locked to.
+func (f *File) Set(key string, pkg *Package) {
+	if f.Packages == nil {
+		f.Packages = map[string]*Package{}
+	}
+	f.Packages[key] = pkg
+}
+
+// Delete removes the packages with the given keys.
+func (f *File) Delete(keys ...string) {
+	for _, key := range keys {
+		delete(f.Packages, key)
+	}
+}
+
+// ResolvedFor returns the installable that the package is locked to on
+// system.
+func (p *Package) ResolvedFor(system string) string {
+	if info := p.Systems[system]; info != nil && info.Resolved != "" {
+		return info.Resolved
+	}
+	return p.Resolved
+}
+
+// VersionFor returns the version that the package is locked to on system.
+func (p *Package) VersionFor(system string) string {
+	if info := p.Systems[system]; info != nil && info.Version != "" {
+		return info.Version
+	}
+	return p.Version
+}
+
+// StorePaths returns the store paths of the package's outputs on system, or
+// nil if the lockfile doesn't have them.
+func (p *Package) StorePaths(system string) []string {
+	info := p.Systems[system]
+	if info == nil {
+		return nil
+	}
+	var paths []string
+	for _, out := range info.Outputs {
+		if out.Path != "" {
+			paths = append(paths, out.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+const testLockfile = `{
+  "lockfile_version": "1",
+  "packages": {
+    "go@1.22": {
+      "last_modified": "2024-05-01T00:00:00Z",
+      "resolved": "github:NixOS/nixpkgs/aaaa#go",
+      "source": "devbox-search",
+      "version": "1.22.2",
+      "systems": {
+        "x86_64-linux": {
+          "store_path": "/nix/store/aaaa-go-1.22.2"
+        }
+      }
+    },
+    "hello@latest": {
+      "resolved": "github:NixOS/nixpkgs/bbbb#hello",
+      "source": "devbox-search",
+      "version": "2.12.1"
+    }
+  }
+}
+`
+
+func TestLoadAndSave(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(testLockfile), 0o644))
+
+	f, err := Load(dir)
+	require.NoError(t, err)
+	require.Equal(t, "1", f.LockFileVersion)
+	require.Equal(t, []string{"go@1.22", "hello@latest"}, f.Keys())
+	gopkg := f.Get("go@1.22")
+	require.Equal(t, "1.22.2", gopkg.VersionFor("x86_64-linux"))
+	require.Equal(t, []string{"/nix/store/aaaa-go-1.22.2"}, gopkg.StorePaths("x86_64-linux"))
+	require.Nil(t, gopkg.StorePaths("aarch64-darwin"))
+
+	f.Delete("hello@latest")
+	f.Set("jq@1.7", &Package{Resolved: "github:NixOS/nixpkgs/cccc#jq", Source: "devbox-search", Version: "1.7.1"})
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, f.Save(path))
+
+	saved, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, f, saved)
+
+	// Saving without changes keeps the bytes that Devbox wrote.
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, saved.Save(dir))
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(before), string(after))
+
+	_, err = Load(filepath.Join(t.TempDir(), FileName))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	want := &lock.File{
+		LockFileVersion: "1",
+		Packages: map[string]*lock.Package{
+			"runx:golangci/golangci-lint@v1.59.1": {
+				AllowInsecure: true,
+				LastModified:  "2024-05-01T00:00:00Z",
+				License:       []string{"GPL-3.0-only"},
+				PluginVersion: "0.0.2",
+				Resolved:      "golangci/golangci-lint@v1.59.1",
+				Source:        "devbox-search",
+				SourceOf:      []string{"devbox.json"},
+				Version:       "v1.59.1",
+				Systems: map[string]*lock.SystemInfo{
+					"x86_64-linux": {
+						Outputs: []lock.Output{{
+							Name:    "out",
+							Path:    "/nix/store/aaaa-golangci-lint",
+							Default: true,
+							Hash:    "sha256:aaaa",
+							CA:      "fixed:r:sha256:aaaa",
+						}},
+						Resolved: "golangci/golangci-lint@v1.59.1",
+						Version:  "v1.59.1",
+						Digest:   "sha256:bbbb",
+					},
+				},
+			},
+		},
+	}
+	// Every field is set, so that a field that's added to internal/lock but
+	// not to the conversions fails the test. The legacy store_path is turned
+	// into an output when the lockfile is read, so it isn't converted.
+	pkg := want.Packages["runx:golangci/golangci-lint@v1.59.1"]
+	requireFieldsSet(t, *want)
+	requireFieldsSet(t, *pkg)
+	requireFieldsSet(t, *pkg.Systems["x86_64-linux"], "StorePath")
+	requireFieldsSet(t, pkg.Systems["x86_64-linux"].Outputs[0])
+
+	require.Equal(t, want, fromLock(want).toLock())
+}
+
+// requireFieldsSet fails if an exported field of the struct v, other than
+// the ones in skip, has its zero value.
+func requireFieldsSet(t *testing.T, v any, skip ...string) {
+	t.Helper()
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() || field.Anonymous || slices.Contains(skip, field.Name) {
+			continue
+		}
+		require.False(t, rv.Field(i).IsZero(), "%s.%s isn't set", rv.Type().Name(), field.Name)
+	}
+}
+
+func TestLoadRejectsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"lockfile_version": "99", "packages": {}}`), 0o644))
+	_, err := Load(path)
+	require.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	before := New()
+	before.Set("go@1.22", &Package{Version: "1.22.2", Resolved: "github:NixOS/nixpkgs/aaaa#go"})
+	before.Set("hello@latest", &Package{Version: "2.12.1", License: []string{}})
+	before.Set("jq@1.7", &Package{Version: "1.7.1"})
+
+	after := New()
+	after.Set("go@1.22", &Package{Version: "1.22.3", Resolved: "github:NixOS/nixpkgs/bbbb#go"})
+	after.Set("hello@latest", &Package{Version: "2.12.1"})
+	after.Set("ripgrep@14", &Package{Version: "14.1.0"})
+
+	changes := Diff(before, after)
+	require.Len(t, changes, 3)
+	require.Equal(t, Change{
+		Key:    "go@1.22",
+		Op:     Changed,
+		Before: before.Get("go@1.22"),
+		After:  after.Get("go@1.22"),
+		Fields: []string{"resolved", "version"},
+	}, changes[0])
+	require.Equal(t, Change{Key: "jq@1.7", Op: Removed, Before: before.Get("jq@1.7")}, changes[1])
+	require.Equal(t, Change{Key: "ripgrep@14", Op: Added, After: after.Get("ripgrep@14")}, changes[2])
+	require.Empty(t, Diff(after, after))
+}
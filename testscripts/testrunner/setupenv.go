@@ -24,6 +24,8 @@ func setupTestEnv(t *testing.T, envs *testscript.Env) error {
 	}
 
 	envs.Setenv(debug.DevboxDebug, os.Getenv(debug.DevboxDebug))
+	// Tests run non-interactively, so they can't approve project hooks.
+	envs.Setenv(envir.DevboxTrustAll, "1")
 	return nil
 }
 